@@ -0,0 +1,63 @@
+package v1alpha1
+
+// Capability names a feature of klaudio a spec may rely on. Specs list the capabilities they need in spec.requires,
+// so a controller from an older release refuses them instead of silently ignoring the fields it doesn't know; that's
+// useful during staged rollouts of the operator.
+type Capability string
+
+const (
+	CapabilityForEach               Capability = "ForEach"
+	CapabilityExportedOutputs       Capability = "ExportedOutputs"
+	CapabilityApproval              Capability = "Approval"
+	CapabilityBlastRadius           Capability = "BlastRadius"
+	CapabilityPlacementSelector     Capability = "PlacementSelector"
+	CapabilityNamespacePerPlacement Capability = "NamespacePerPlacement"
+	CapabilityRemoteClusters        Capability = "RemoteClusters"
+	CapabilityWriteOutputsTo        Capability = "WriteOutputsTo"
+	CapabilitySensitiveOutputs      Capability = "SensitiveOutputs"
+	CapabilitySecretRefs            Capability = "SecretRefs"
+	CapabilityRefSelectors          Capability = "RefSelectors"
+	CapabilityProvisionerObjectName Capability = "ProvisionerObjectName"
+	CapabilityExternalRefs          Capability = "ExternalRefs"
+	CapabilityPlacementApproval     Capability = "PlacementApproval"
+	CapabilityVerification          Capability = "Verification"
+	CapabilityExpressionLanguage    Capability = "ExpressionLanguage"
+)
+
+// SupportedCapabilities are the capabilities of this release of klaudio
+var SupportedCapabilities = []Capability{
+	CapabilityForEach,
+	CapabilityExportedOutputs,
+	CapabilityApproval,
+	CapabilityBlastRadius,
+	CapabilityPlacementSelector,
+	CapabilityNamespacePerPlacement,
+	CapabilityRemoteClusters,
+	CapabilityWriteOutputsTo,
+	CapabilitySensitiveOutputs,
+	CapabilitySecretRefs,
+	CapabilityRefSelectors,
+	CapabilityProvisionerObjectName,
+	CapabilityExternalRefs,
+	CapabilityPlacementApproval,
+	CapabilityVerification,
+	CapabilityExpressionLanguage,
+}
+
+// UnsupportedCapabilities returns the required capabilities this release of klaudio doesn't have
+func UnsupportedCapabilities(required []Capability) []Capability {
+	unsupported := make([]Capability, 0)
+	for _, capability := range required {
+		supported := false
+		for _, candidate := range SupportedCapabilities {
+			if candidate == capability {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			unsupported = append(unsupported, capability)
+		}
+	}
+	return unsupported
+}
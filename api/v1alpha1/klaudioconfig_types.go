@@ -0,0 +1,76 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KlaudioConfigName is the name of the KlaudioConfig of an installation; a KlaudioConfig with any other name is ignored
+const KlaudioConfigName = "klaudio"
+
+// KlaudioConfigSpec defines the configuration of a klaudio installation
+type KlaudioConfigSpec struct {
+	// FeatureGates enable or disable features cluster-wide
+	// +optional
+	FeatureGates KlaudioFeatureGates `json:"featureGates,omitempty"`
+}
+
+// KlaudioFeatureGates enable or disable features cluster-wide
+type KlaudioFeatureGates struct {
+	// Provisioners enables or disables each provisioner, plugins included, by name; provisioners that aren't listed
+	// are enabled. ResourceRefs can't be created with a disabled provisioner, and Resources provisioned by one fail.
+	// +optional
+	Provisioners map[string]bool `json:"provisioners,omitempty"`
+}
+
+// KlaudioConfigStatus defines the observed state of KlaudioConfig
+type KlaudioConfigStatus struct {
+	// DisabledProvisioners are the provisioners disabled by the feature gates in force
+	DisabledProvisioners []string `json:"disabledProvisioners,omitempty"`
+
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Disabled Provisioners",type="string",JSONPath=".status.disabledProvisioners"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// KlaudioConfig is the Schema for the klaudioconfigs API.
+// It configures the whole installation, so only the one named klaudio is read.
+type KlaudioConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KlaudioConfigSpec   `json:"spec,omitempty"`
+	Status KlaudioConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KlaudioConfigList contains a list of KlaudioConfig
+type KlaudioConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KlaudioConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KlaudioConfig{}, &KlaudioConfigList{})
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KlaudioFleetStatusName is the name of the KlaudioFleetStatus kept by the controller; one with any other name is
+// ignored
+const KlaudioFleetStatusName = "klaudio"
+
+// DefaultFleetStatusLimit is how many entries each list of the fleet status holds when the spec doesn't say
+const DefaultFleetStatusLimit = 10
+
+// KlaudioFleetStatusSpec defines how the fleet is summarized
+type KlaudioFleetStatusSpec struct {
+	// Limit is how many failing placements, drifted Resources and in-progress deployments are listed; all of them are
+	// counted anyway
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Limit *int32 `json:"limit,omitempty"`
+}
+
+// KlaudioFleetStatusStatus summarizes every ResourceGroup of the installation
+type KlaudioFleetStatusStatus struct {
+	// ResourceGroups, Deployments and Resources count the objects of the fleet by phase; objects without a phase yet
+	// are counted as Pending
+	ResourceGroups map[string]int32 `json:"resourceGroups,omitempty"`
+	Deployments    map[string]int32 `json:"deployments,omitempty"`
+	Resources      map[string]int32 `json:"resources,omitempty"`
+
+	// FailingPlacements are the placements with failed ResourceGroupDeployments, the ones with most failures first
+	FailingPlacements []KlaudioFleetFailingPlacement `json:"failingPlacements,omitempty"`
+
+	// DriftedResourcesCount is how many Resources diverged from their declared state; DriftedResources are the ones
+	// drifted for longer
+	DriftedResourcesCount int32                `json:"driftedResourcesCount,omitempty"`
+	DriftedResources      []KlaudioFleetObject `json:"driftedResources,omitempty"`
+
+	// InProgressDeploymentsCount is how many ResourceGroupDeployments are in progress; OldestInProgressDeployments are
+	// the ones in progress for longer
+	InProgressDeploymentsCount  int32                `json:"inProgressDeploymentsCount,omitempty"`
+	OldestInProgressDeployments []KlaudioFleetObject `json:"oldestInProgressDeployments,omitempty"`
+
+	// UpdatedAt is the last time the fleet was summarized
+	UpdatedAt *metav1.Time `json:"updatedAt,omitempty"`
+}
+
+// KlaudioFleetFailingPlacement is a placement with failed ResourceGroupDeployments
+type KlaudioFleetFailingPlacement struct {
+	Placement string `json:"placement"`
+	// Deployments are the failed ResourceGroupDeployments of the placement, as namespace/name
+	Deployments []string `json:"deployments"`
+}
+
+// KlaudioFleetObject is an object of the fleet in some state since some time, like a drifted Resource
+type KlaudioFleetObject struct {
+	Namespace string      `json:"namespace"`
+	Name      string      `json:"name"`
+	Placement string      `json:"placement,omitempty"`
+	Since     metav1.Time `json:"since"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Drifted",type="integer",JSONPath=".status.driftedResourcesCount"
+// +kubebuilder:printcolumn:name="In Progress",type="integer",JSONPath=".status.inProgressDeploymentsCount"
+// +kubebuilder:printcolumn:name="Updated",type="date",JSONPath=".status.updatedAt"
+
+// KlaudioFleetStatus is the Schema for the klaudiofleetstatuses API.
+// It's kept by the controller, so SREs watch a single object instead of every namespace; only the one named klaudio
+// is kept.
+type KlaudioFleetStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KlaudioFleetStatusSpec   `json:"spec,omitempty"`
+	Status KlaudioFleetStatusStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// KlaudioFleetStatusList contains a list of KlaudioFleetStatus
+type KlaudioFleetStatusList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KlaudioFleetStatus `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KlaudioFleetStatus{}, &KlaudioFleetStatusList{})
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PlacementReconcileRequestedAnnotation asks every ResourceGroupDeployment targeting the placement to be reconciled
+// again; the value is the time of the request, recorded by each deployment once handled
+const PlacementReconcileRequestedAnnotation = Group + "/reconcileRequestedAt"
+
+// PlacementSpec defines the desired state of Placement
+type PlacementSpec struct {
+	// Account is the cloud account resources are provisioned into
+	Account string `json:"account,omitempty"`
+
+	// Region is the default region of the resources provisioned to the placement
+	Region string `json:"region,omitempty"`
+
+	// Cluster is the Kubernetes cluster targeted by the placement, when it has one
+	Cluster *PlacementCluster `json:"cluster,omitempty"`
+
+	// CredentialsSecretRef points to the Secret holding the credentials used by provisioners to reach the account
+	CredentialsSecretRef *corev1.SecretReference `json:"credentialsSecretRef,omitempty"`
+
+	// FreezeWindows are periods during which every deployment targeting the placement is held
+	FreezeWindows []PlacementFreezeWindow `json:"freezeWindows,omitempty"`
+
+	// Suspend pauses the reconciliation of every deployment targeting the placement, until it's resumed
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+type PlacementCluster struct {
+	Name string `json:"name"`
+	// Server is the address of the cluster API server
+	Server string `json:"server,omitempty"`
+
+	// KubeconfigSecretRef points to a kubeconfig of the cluster; when present, the objects of the provisioners (like
+	// Terraform, Stack or Crossplane objects) of Resources deployed to the placement are created on this cluster,
+	// instead of the one running klaudio
+	KubeconfigSecretRef *PlacementKubeconfigSecretRef `json:"kubeconfigSecretRef,omitempty"`
+}
+
+type PlacementKubeconfigSecretRef struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// Key holding the kubeconfig; defaults to value, as the kubeconfig Secrets generated by Cluster API
+	Key string `json:"key,omitempty"`
+}
+
+type PlacementFreezeWindow struct {
+	Start  metav1.Time `json:"start"`
+	End    metav1.Time `json:"end"`
+	Reason string      `json:"reason,omitempty"`
+}
+
+// ActiveFreezeWindow returns the freeze window in effect at the given time, if any
+func (p *Placement) ActiveFreezeWindow(now time.Time) *PlacementFreezeWindow {
+	for i, window := range p.Spec.FreezeWindows {
+		if !now.Before(window.Start.Time) && now.Before(window.End.Time) {
+			return &p.Spec.FreezeWindows[i]
+		}
+	}
+	return nil
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Account",type="string",JSONPath=".spec.account"
+// +kubebuilder:printcolumn:name="Region",type="string",JSONPath=".spec.region"
+// +kubebuilder:printcolumn:name="Suspended",type="boolean",JSONPath=".spec.suspend"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// Placement is the Schema for the placements API
+type Placement struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PlacementSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PlacementList contains a list of Placement
+type PlacementList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Placement `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Placement{}, &PlacementList{})
+}
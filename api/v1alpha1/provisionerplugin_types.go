@@ -0,0 +1,82 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProvisionerPluginSpec defines the desired state of ProvisionerPlugin
+type ProvisionerPluginSpec struct {
+	// Endpoint is the gRPC address (host:port) where the plugin is listening
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint"`
+
+	// Timeout limits each call made to the plugin
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// TLS secures the connection to the plugin; without it, calls are made over plaintext
+	// +optional
+	TLS *ProvisionerPluginTLS `json:"tls,omitempty"`
+}
+
+// ProvisionerPluginTLS configures the TLS connection to a plugin
+type ProvisionerPluginTLS struct {
+	// SecretRef is the Secret with the CA that signed the plugin certificate (ca.crt) and, for mutual TLS,
+	// the client certificate presented to the plugin (tls.crt and tls.key)
+	SecretRef corev1.SecretReference `json:"secretRef"`
+
+	// ServerName is the name verified against the plugin certificate; the endpoint host by default
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+}
+
+// ProvisionerPluginStatus defines the observed state of ProvisionerPlugin
+type ProvisionerPluginStatus struct {
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Endpoint",type="string",JSONPath=".spec.endpoint"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ProvisionerPlugin is the Schema for the provisionerplugins API.
+// The plugin name is the provisioner name used by ResourceRefs.
+type ProvisionerPlugin struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ProvisionerPluginSpec   `json:"spec,omitempty"`
+	Status ProvisionerPluginStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ProvisionerPluginList contains a list of ProvisionerPlugin
+type ProvisionerPluginList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProvisionerPlugin `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ProvisionerPlugin{}, &ProvisionerPluginList{})
+}
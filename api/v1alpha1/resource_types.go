@@ -17,6 +17,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"encoding/json"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -29,12 +31,141 @@ type ResourceSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	Placement   string                `json:"placement"`
-	ResourceRef string                `json:"resourceRef"`
-	Properties  *runtime.RawExtension `json:"properties"`
+	// Placement is the name of the Placement the Resource is deployed to
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Placement string `json:"placement"`
+
+	ResourceRef    string                `json:"resourceRef"`
+	Properties     *runtime.RawExtension `json:"properties"`
+	DriftPolicy    DriftPolicy           `json:"driftPolicy,omitempty"`
+	DeletionPolicy DeletionPolicy        `json:"deletionPolicy,omitempty"`
+
+	// Protect refuses the deletion of the Resource until it's unset, and keeps the provisioner objects from destroying
+	// the infrastructure meanwhile, besides the DeletionPolicy: Terraform objects don't destroy their resources, Pulumi
+	// Stacks don't destroy on finalize, and Crossplane objects get deletionPolicy Orphan. OpenTofu modules may also
+	// receive the variable named by the preventDestroyVar property of the ResourceRef.
+	Protect bool `json:"protect,omitempty"`
+
+	// Suspend stops the provisioner from running; the provisioned infrastructure is kept as is
+	Suspend bool `json:"suspend,omitempty"`
+
+	// WriteOutputsTo is a Secret or a ConfigMap the outputs are written into, besides the output store, so workloads
+	// can read them without access to klaudio objects
+	WriteOutputsTo *ResourceOutputsTarget `json:"writeOutputsTo,omitempty"`
+
+	// ProvisionerObjectName is the name of the object created by the provisioner (a Terraform, a Stack, a Crossplane
+	// object...), instead of the name of the Resource; an existing object with this name is taken over. Changing it
+	// provisions a new object and leaves the previous one behind.
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`
+	ProvisionerObjectName string `json:"provisionerObjectName,omitempty"`
+
+	// Verification is a Job run once the Resource is provisioned; the Resource is only Done when it succeeds
+	Verification *ResourceVerification `json:"verification,omitempty"`
+
+	// Hooks are Jobs run by the teardown, before and after the provisioner destroys the infrastructure
+	Hooks *ResourceHooks `json:"hooks,omitempty"`
+
+	// SecretProperties is the Secret holding the properties read from secret refs; they're kept out of the spec, and
+	// only merged into the properties handed to the provisioner
+	SecretProperties *ResourceSecretProperties `json:"secretProperties,omitempty"`
+}
+
+// ResourceSecretProperties is a Secret in the namespace of the Resource, with one key to each property holding its
+// value as JSON
+type ResourceSecretProperties struct {
+	Name string `json:"name"`
+	// Checksum of the values, so the spec changes when they're rotated and the Resource is provisioned again
+	Checksum string `json:"checksum"`
+}
+
+// SecretPropertiesNameOf is the Secret holding the secret properties of a Resource
+func SecretPropertiesNameOf(resource string) string {
+	return resource + "-secret-properties"
+}
+
+// ResourceVerification is a Job verifying the provisioned infrastructure, like a smoke test connecting to a new
+// database. The outputs of the Resource are available to the Job as environment variables named OUTPUT_<NAME>, the
+// output name upper-cased with anything other than letters and digits replaced by _. The Job runs once to each
+// generation of the Resource.
+type ResourceVerification struct {
+	Image   string   `json:"image"`
+	Command []string `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+
+	// Env are environment variables of the Job, besides the outputs
+	Env map[string]string `json:"env,omitempty"`
+
+	// BackoffLimit is how many times the Job is retried before the verification fails; defaults to 0
+	// +kubebuilder:validation:Minimum=0
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// ActiveDeadlineSeconds limits how long the Job may run before the verification fails
+	// +kubebuilder:validation:Minimum=1
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+}
+
+// ResourceHooks are Jobs run when the Resource is destroyed, like taking a final snapshot of a database before it's
+// gone, or cleaning its DNS records up after. They only run when the infrastructure is destroyed, so a Resource with
+// the Orphan or Retain deletion policy skips them. As the verification Job, hooks read the outputs of the Resource as
+// environment variables named OUTPUT_<NAME>. Each hook runs once.
+type ResourceHooks struct {
+	// PreDestroy runs before the provisioner destroys the infrastructure
+	PreDestroy *ResourceHook `json:"preDestroy,omitempty"`
+	// PostDestroy runs once the infrastructure is destroyed, before the Resource goes away
+	PostDestroy *ResourceHook `json:"postDestroy,omitempty"`
+}
+
+// HookFailurePolicy controls what happens to the teardown when a hook fails
+// +kubebuilder:validation:Enum=Block;Warn
+type HookFailurePolicy string
+
+const (
+	// HookFailurePolicyBlock holds the teardown and fails the Resource; deleting the failed Job runs the hook again
+	HookFailurePolicyBlock HookFailurePolicy = "Block"
+	// HookFailurePolicyWarn logs the failure and carries on with the teardown
+	HookFailurePolicyWarn HookFailurePolicy = "Warn"
+)
+
+// ResourceHook is a Job run by the teardown of the Resource
+type ResourceHook struct {
+	Image   string   `json:"image"`
+	Command []string `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+
+	// Env are environment variables of the Job, besides the outputs
+	Env map[string]string `json:"env,omitempty"`
+
+	// BackoffLimit is how many times the Job is retried before the hook fails; defaults to 0
+	// +kubebuilder:validation:Minimum=0
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// ActiveDeadlineSeconds limits how long the Job may run before the hook fails
+	// +kubebuilder:validation:Minimum=1
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// FailurePolicy is what a failure of the hook does to the teardown; defaults to Block
+	FailurePolicy HookFailurePolicy `json:"failurePolicy,omitempty"`
 }
 
-type ResourceStatusDescription string
+// +kubebuilder:validation:Enum=Secret;ConfigMap
+type ResourceOutputsTargetKind string
+
+const (
+	ResourceOutputsTargetSecret    ResourceOutputsTargetKind = "Secret"
+	ResourceOutputsTargetConfigMap ResourceOutputsTargetKind = "ConfigMap"
+)
+
+// ResourceOutputsTarget holds one key per output; strings are written as they are, and any other value as JSON. The
+// object is created by klaudio, and deleted when the Resource is destroyed.
+type ResourceOutputsTarget struct {
+	Kind ResourceOutputsTargetKind `json:"kind"`
+	Name string                    `json:"name"`
+	// Namespace defaults to the namespace of the Resource; any other one must be allowed by the operator, with
+	// --write-outputs-namespaces
+	Namespace string `json:"namespace,omitempty"`
+}
 
 type ResourceStatusProvisioner struct {
 	Resource ResourceStatusProvisionerResource `json:"resource,omitempty"`
@@ -48,15 +179,113 @@ type ResourceStatusProvisionerResource struct {
 	Name    string `json:"name,omitempty"`
 }
 
+// ResourceStatusInventoryEntry describes a cloud resource owned by the provisioned Resource
+type ResourceStatusInventoryEntry struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	ID   string `json:"id,omitempty"`
+}
+
+// ResourceStatusConsumedSecret is a Secret read by the provisioner object, with the hash of its data
+type ResourceStatusConsumedSecret struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Hash      string `json:"hash"`
+}
+
 // ResourceStatus defines the observed state of Resource
 type ResourceStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
 	Provisioner ResourceStatusProvisioner `json:"provisioner,omitempty"`
-	Outputs     *runtime.RawExtension     `json:"outputs,omitempty"`
-	Phase       ResourceStatusDescription `json:"phase,omitempty"`
-	Conditions  []metav1.Condition        `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+	// Outputs published by the provisioner; each value keeps its JSON type (string, number, bool, object or array)
+	Outputs            *runtime.RawExtension          `json:"outputs,omitempty"`
+	Inventory          []ResourceStatusInventoryEntry `json:"inventory,omitempty"`
+	Phase              DeploymentPhase                `json:"phase,omitempty"`
+	ObservedGeneration int64                          `json:"observedGeneration,omitempty"`
+	// ProvisionedSpecHash is the hash of the spec fields read by the provisioner, from the last successful run; when
+	// a new generation changes none of them, the outputs are just republished
+	ProvisionedSpecHash string `json:"provisionedSpecHash,omitempty"`
+	// OutputsRefreshedAt is the last time the outputs were read from the provisioner
+	OutputsRefreshedAt *metav1.Time `json:"outputsRefreshedAt,omitempty"`
+	// RefreshRequestedAt is when the provisioner object was asked to run again by a requested refresh; it's cleared
+	// once the outputs of that run are read
+	RefreshRequestedAt *metav1.Time `json:"refreshRequestedAt,omitempty"`
+	// ConsumedSecrets are the Secrets read by the provisioner object in the last successful run; a rotation of any of
+	// them is handled by the SecretRotationPolicy of the ResourceRef
+	ConsumedSecrets []ResourceStatusConsumedSecret `json:"consumedSecrets,omitempty"`
+	// Retries counts the consecutive transient errors from the provisioner; it's reset when the provisioner succeeds
+	Retries    int32              `json:"retries,omitempty"`
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+}
+
+// ResourceOutputs are the outputs published by a Resource, keyed by name; values keep the type published by the
+// provisioner, so consumers don't have to parse numbers and booleans out of strings
+// +kubebuilder:object:generate=false
+type ResourceOutputs map[string]any
+
+// GetOutputs decodes the outputs from the status; a Resource without outputs has an empty map
+func (s *ResourceStatus) GetOutputs() (ResourceOutputs, error) {
+	outputs := make(ResourceOutputs)
+	if s.Outputs == nil || len(s.Outputs.Raw) == 0 {
+		return outputs, nil
+	}
+	if err := json.Unmarshal(s.Outputs.Raw, &outputs); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// SetOutputs encodes the outputs to the status
+func (s *ResourceStatus) SetOutputs(outputs ResourceOutputs) error {
+	raw, err := json.Marshal(outputs)
+	if err != nil {
+		return err
+	}
+	s.Outputs = &runtime.RawExtension{Raw: raw}
+	return nil
+}
+
+// String returns a string output; false when it doesn't exist or isn't a string
+func (o ResourceOutputs) String(name string) (string, bool) {
+	value, ok := o[name].(string)
+	return value, ok
+}
+
+// Bool returns a boolean output; false when it doesn't exist or isn't a boolean
+func (o ResourceOutputs) Bool(name string) (value bool, ok bool) {
+	value, ok = o[name].(bool)
+	return value, ok
+}
+
+// Float64 returns a numeric output; false when it doesn't exist or isn't a number
+func (o ResourceOutputs) Float64(name string) (float64, bool) {
+	switch value := o[name].(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Int64 returns a numeric output without a fractional part; false when it doesn't exist or isn't an integer
+func (o ResourceOutputs) Int64(name string) (int64, bool) {
+	if value, ok := o[name].(json.Number); ok {
+		i, err := value.Int64()
+		return i, err == nil
+	}
+	value, ok := o.Float64(name)
+	if !ok || value != float64(int64(value)) {
+		return 0, false
+	}
+	return int64(value), true
 }
 
 // +kubebuilder:object:root=true
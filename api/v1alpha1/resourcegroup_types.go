@@ -17,6 +17,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -32,51 +36,263 @@ type ResourceGroupSpec struct {
 	Parameters *runtime.RawExtension  `json:"parameters,omitempty"`
 	Refs       []ResourceGroupRef     `json:"refs,omitempty"`
 	Resources  []ResourceGroupElement `json:"resources,omitempty"`
+
+	// DeleteEmptyNamespace allows the generated namespace (and its runner RBAC) to be removed when
+	// there is nothing left to deploy in the group; foreign objects inside the namespace prevent the deletion.
+	// With the Placement namespace strategy, the namespace of each placement removed from the group is collected.
+	DeleteEmptyNamespace bool `json:"deleteEmptyNamespace,omitempty"`
+
+	// NamespaceStrategy chooses whether every placement is deployed into the same namespace or into a namespace of its
+	// own, isolating the runner workloads and RBAC of each account; it can't be changed after the group is created
+	NamespaceStrategy NamespaceStrategy `json:"namespaceStrategy,omitempty"`
+
+	// DriftPolicy applied to every resource of the group, unless the resource declares its own
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+
+	// BlastRadius limits how many deployed resources a single deployment run may change
+	BlastRadius *BlastRadiusPolicy `json:"blastRadius,omitempty"`
+
+	// Approval Manual holds every deployment run in the PendingApproval phase until an operator approves it
+	Approval ApprovalPolicy `json:"approval,omitempty"`
+
+	// PlacementApproval Manual holds the ResourceGroupDeployments that a change to the placements of the group would
+	// create or destroy, publishing them in status.placementChanges until an operator approves them through the
+	// approvePlacementChanges annotation
+	PlacementApproval ApprovalPolicy `json:"placementApproval,omitempty"`
+
+	// PrunePlacements allows the ResourceGroupDeployments to placements the group isn't deployed to anymore to be
+	// deleted, destroying their Resources. Without it, a deployment is only deleted when its resources keep their
+	// infrastructure (Orphan or Retain deletion policies), or when the change was approved with the Manual placement
+	// approval policy; otherwise it's kept, and still reported in status.deployments.
+	PrunePlacements bool `json:"prunePlacements,omitempty"`
+
+	// Suspend stops the reconciliation of the ResourceGroup, so its ResourceGroupDeployments aren't created or updated;
+	// deployments already running keep being reconciled
+	Suspend bool `json:"suspend,omitempty"`
+
+	// ReadOnlyAccess generates a ServiceAccount allowed to read the objects inside the group's namespace, and exports
+	// its credentials as a kubeconfig Secret, so external tools can observe the provisioning progress
+	ReadOnlyAccess bool `json:"readOnlyAccess,omitempty"`
+
+	// PlacementSelector narrows the Placements the group is deployed to, among the ones selected by its ResourceRefs
+	PlacementSelector *metav1.LabelSelector `json:"placementSelector,omitempty"`
+
+	// Requires are the klaudio capabilities this ResourceGroup relies on; a controller without any of them sets the
+	// Unsupported condition and leaves the ResourceGroup untouched
+	Requires []Capability `json:"requires,omitempty"`
+
+	// ExpressionLanguage is the language of the ${...} expressions of the resources; when it's empty, the default of
+	// the controller is used
+	ExpressionLanguage ExpressionLanguage `json:"expressionLanguage,omitempty"`
+
+	// TimeToReady is the expected time from the start of a deployment run to its resources being ready, like 30m;
+	// slower runs set the SlowProvisioning condition of their ResourceGroupDeployment and burn the SLO
+	TimeToReady *metav1.Duration `json:"timeToReady,omitempty"`
+
+	// SourceRef is a Flux source whose artifact holds more resources of the group, deployed together with the ones
+	// declared in resources
+	SourceRef *ResourceGroupSourceRef `json:"sourceRef,omitempty"`
 }
 
+// ResourceGroupSourceRef points to the artifact of a Flux source. The YAML files read from it may have many
+// documents, each one a list of resources or an object with a resources list; anchors and aliases are expanded before
+// the properties are read, and the other keys of an object are free to hold the anchors.
+type ResourceGroupSourceRef struct {
+	// +kubebuilder:validation:Enum=GitRepository;OCIRepository;Bucket
+	Kind string `json:"kind"`
+	// APIVersion of the source; defaults to source.toolkit.fluxcd.io/v1 to GitRepository, and to
+	// source.toolkit.fluxcd.io/v1beta2 to the others
+	APIVersion string `json:"apiVersion,omitempty"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	// Path of a YAML file inside the artifact, or of a directory whose .yaml and .yml files are read in lexical order;
+	// defaults to the root of the artifact
+	Path string `json:"path,omitempty"`
+}
+
+// BlastRadiusPolicy guards against template errors changing too many resources at once; a deployment run exceeding
+// the limits fails, unless its generation is approved through the approveBlastRadius annotation.
+type BlastRadiusPolicy struct {
+	// MaxResources is the maximum number of deployed resources changed by a run
+	// +kubebuilder:validation:Minimum=0
+	MaxResources *int32 `json:"maxResources,omitempty"`
+	// MaxPercentage is the maximum share (0-100) of the resources changed by a run
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	MaxPercentage *int32 `json:"maxPercentage,omitempty"`
+}
+
+// SecretSharedWithAnnotation lists the namespaces, comma-separated, whose deployments may read the Secret as a secret
+// ref; * shares it with every namespace
+const SecretSharedWithAnnotation = Group + "/sharedWith"
+
+// ResourceGroupRefKind is the kind of an object read by expressions. Secrets are handled apart; objects of any other
+// kind are read as they are, as refs.<name>.
 type ResourceGroupRefKind string
 
 const (
+	// ResourceGroupRefConfigMap refs are read by expressions as refs.<name>.data.<key>
 	ResourceGroupRefConfigMap = ResourceGroupRefKind("ConfigMap")
+	// ResourceGroupRefSecret refs are read by expressions as secrets.<name>.<key>, with the values already decoded
+	// from base64; they are never kept in the status. Secrets out of the namespace of the deployment must be shared
+	// with it by SecretSharedWithAnnotation.
+	ResourceGroupRefSecret = ResourceGroupRefKind("Secret")
+	// ResourceGroupRefExternal refs are read from a store outside the cluster, described by the external field of
+	// the ref; like Secret refs, they are read by expressions as secrets.<name>.<key> and never kept in the status
+	ResourceGroupRefExternal = ResourceGroupRefKind("External")
+)
+
+// +kubebuilder:validation:Enum=Vault;AWSParameterStore;AWSSecretsManager
+type ExternalRefProvider string
+
+const (
+	// ExternalRefVault reads a secret from HashiCorp Vault; both KV v1 and v2 engines are supported
+	ExternalRefVault = ExternalRefProvider("Vault")
+	// ExternalRefAWSParameterStore reads a parameter from AWS Systems Manager Parameter Store, as secrets.<name>.value
+	ExternalRefAWSParameterStore = ExternalRefProvider("AWSParameterStore")
+	// ExternalRefAWSSecretsManager reads a secret from AWS Secrets Manager; a JSON object is read key by key, and
+	// anything else as secrets.<name>.value
+	ExternalRefAWSSecretsManager = ExternalRefProvider("AWSSecretsManager")
 )
 
+// ExternalRef is a value kept in a store outside the cluster
+type ExternalRef struct {
+	Provider ExternalRefProvider `json:"provider"`
+
+	// Path of the secret in Vault (like secret/data/checkout), name of the parameter or id of the secret in AWS
+	Path string `json:"path"`
+
+	// Address of the Vault server; to AWS providers, it overrides the endpoint of the region. It must be allowed by
+	// the operator, with --external-ref-addresses.
+	Address string `json:"address,omitempty"`
+
+	// Region of the AWS providers
+	Region string `json:"region,omitempty"`
+
+	// CredentialsSecret is a Secret in the namespace of the deployment holding the credentials to the store: token to
+	// Vault; accessKeyId, secretAccessKey and, optionally, sessionToken to AWS
+	CredentialsSecret string `json:"credentialsSecret"`
+
+	// TTL of the values read from the store; they are read again after that. Defaults to 5m.
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+}
+
 type ResourceGroupRef struct {
+	// Name of the object; with a selector, it's only the name expressions read the list by
 	Name       string               `json:"name"`
 	ApiVersion string               `json:"apiVersion"`
 	Kind       ResourceGroupRefKind `json:"kind"`
 	Namespace  string               `json:"namespace,omitempty"`
+	// Selector reads every object of the kind matching these labels instead of a single one, as a list ordered by
+	// name: refs.<name>[0].data.key
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// External describes where an External ref is read from
+	External *ExternalRef `json:"external,omitempty"`
 }
 
 type ResourceGroupElement struct {
 	Name        string                `json:"name"`
 	ResourceRef string                `json:"resourceRef"`
 	Properties  *runtime.RawExtension `json:"properties"`
-}
+	DriftPolicy DriftPolicy           `json:"driftPolicy,omitempty"`
 
-type ResourceGroupDeploymentStatuses map[string]ResourceGroupDeploymentStatus
+	// ForEach is an expression evaluated to a list or a map, like ${parameters.zones}; one Resource is stamped out to
+	// each item, named <name>-<key>, and its properties can read the item as ${each.key} and ${each.value}. Items of a
+	// list must be strings or numbers, which are their keys too; a map is keyed by its own keys. Keys must be valid
+	// DNS labels. ForEach can read parameters and refs, but not other resources.
+	ForEach string `json:"forEach,omitempty"`
+
+	// DeletionPolicy applied to the generated Resource; defaults to Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// Protect is propagated to the generated Resource; see ResourceSpec.Protect
+	Protect bool `json:"protect,omitempty"`
+
+	// ExportedOutputs are the outputs visible to the expressions of other resources; when omitted, all outputs are
+	// exported. Outputs kept internal are still published in the Resource's status.
+	ExportedOutputs []string `json:"exportedOutputs,omitempty"`
 
-type ResourceGroupStatusPhaseDescription string
+	// Metadata is propagated to the generated Resource and to the objects created by its provisioner
+	Metadata *ResourceGroupElementMetadata `json:"metadata,omitempty"`
 
-// const (
-// 	ResourceGroupDeploymentInProgressPhase = ResourceGroupStatusPhaseDescription("DeploymentInProgress")
-// 	ResourceGroupDeploymentDonePhase       = ResourceGroupStatusPhaseDescription("DeploymentDone")
+	// WriteOutputsTo is propagated to the generated Resource; see ResourceSpec.WriteOutputsTo
+	WriteOutputsTo *ResourceOutputsTarget `json:"writeOutputsTo,omitempty"`
 
-// 	ResourceGroupConditionReady = "Ready"
+	// ProvisionerObjectName is propagated to the generated Resource; see ResourceSpec.ProvisionerObjectName. Resources
+	// stamped out by a forEach are suffixed with their keys, like <provisionerObjectName>-<key>.
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`
+	ProvisionerObjectName string `json:"provisionerObjectName,omitempty"`
+
+	// Verification is propagated to the generated Resource; see ResourceSpec.Verification
+	Verification *ResourceVerification `json:"verification,omitempty"`
+
+	// Hooks are propagated to the generated Resource; see ResourceSpec.Hooks
+	Hooks *ResourceHooks `json:"hooks,omitempty"`
+}
+
+// ResourceGroupElementMetadata are labels and annotations passed through to downstream objects;
+// keys under the klaudio domain are reserved and ignored.
+type ResourceGroupElementMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ResourceGroupDeploymentStatuses map[string]ResourceGroupDeploymentStatus
 
-// 	ResourceGroupConditionReasonReconciling             = "Reconciling"
-// 	ResourceGroupConditionReasonDeploymentInProgress    = "DeploymentInProgress"
-// 	ResourceGroupConditionReasonDeploymentDone          = "DeploymentDone"
-// 	ResourceGroupConditionReasonNamespaceCreationFailed = "NamespaceCreationFailed"
-// )
+// ResourceGroupUsage is the consumption of the management cluster by the ResourceGroup
+type ResourceGroupUsage struct {
+	// Resources is the number of Resources deployed by the group
+	Resources int `json:"resources"`
+	// ActiveRuns is the number of Resources being provisioned right now
+	ActiveRuns int `json:"activeRuns"`
+	// RunnerPods is the number of running pods in the group namespace
+	RunnerPods int `json:"runnerPods"`
+	// CPURequests is the sum of CPU requested by the runner pods
+	CPURequests resource.Quantity `json:"cpuRequests,omitempty"`
+	// MemoryRequests is the sum of memory requested by the runner pods
+	MemoryRequests resource.Quantity `json:"memoryRequests,omitempty"`
+}
 
 // ResourceGroupStatus defines the observed state of ResourceGroup
 type ResourceGroupStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	Deployments ResourceGroupDeploymentStatuses     `json:"deployments,omitempty"`
-	Phase       ResourceGroupStatusPhaseDescription `json:"phase,omitempty"`
-	Conditions  []metav1.Condition                  `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+	Deployments ResourceGroupDeploymentStatuses `json:"deployments,omitempty"`
+	Phase       DeploymentPhase                 `json:"phase,omitempty"`
+	Usage       *ResourceGroupUsage             `json:"usage,omitempty"`
+	Conditions  []metav1.Condition              `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// ReadOnlyKubeconfig is the name of the Secret, inside the group's namespace, holding the read-only kubeconfig
+	ReadOnlyKubeconfig string `json:"readOnlyKubeconfig,omitempty"`
+
+	// PlacementChanges are the placement changes waiting for approval, with the Manual placement approval policy
+	PlacementChanges *ResourceGroupPlacementChanges `json:"placementChanges,omitempty"`
+
+	// Source describes the resources loaded from the artifact of the sourceRef
+	Source *ResourceGroupSourceStatus `json:"source,omitempty"`
+}
+
+// ResourceGroupSourceStatus is the revision of the artifact the resources of the sourceRef were loaded from
+type ResourceGroupSourceStatus struct {
+	Revision string `json:"revision"`
+	// Resources are the names of the resources loaded from the artifact
+	Resources []string `json:"resources,omitempty"`
+	// ResourceRefs are the ResourceRefs used by the resources loaded from the artifact
+	ResourceRefs []string `json:"resourceRefs,omitempty"`
+}
+
+// ResourceGroupPlacementChanges are the ResourceGroupDeployments that would be created or destroyed because the
+// placements of the group changed
+type ResourceGroupPlacementChanges struct {
+	// ID identifies this set of changes; it's the value of the approvePlacementChanges annotation approving them
+	ID        string      `json:"id"`
+	PlannedAt metav1.Time `json:"plannedAt"`
+	// Create are the placements whose ResourceGroupDeployments would be created
+	Create []string `json:"create,omitempty"`
+	// Destroy are the placements whose ResourceGroupDeployments would be torn down, destroying their Resources
+	Destroy []string `json:"destroy,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -101,6 +317,39 @@ type ResourceGroupList struct {
 	Items           []ResourceGroup `json:"items"`
 }
 
+// NamespaceOf returns the namespace the group is deployed into to the placement
+func (r *ResourceGroup) NamespaceOf(placement string) string {
+	if r.Spec.NamespaceStrategy == NamespacePerPlacement {
+		return fmt.Sprintf("%s-%s", r.Name, placement)
+	}
+	return r.Name
+}
+
+// UsesResourceRef tells whether any resource of the group, declared in the spec or loaded from the sourceRef, is
+// provisioned by the ResourceRef
+func (r *ResourceGroup) UsesResourceRef(name string) bool {
+	return slices.Contains(r.ResourceRefNames(), name)
+}
+
+// ResourceRefNames returns the ResourceRefs of the group's resources, declared in the spec or loaded from the
+// sourceRef, in the order they're first used
+func (r *ResourceGroup) ResourceRefNames() []string {
+	names := make([]string, 0, len(r.Spec.Resources))
+	for _, element := range r.Spec.Resources {
+		if !slices.Contains(names, element.ResourceRef) {
+			names = append(names, element.ResourceRef)
+		}
+	}
+	if r.Spec.SourceRef != nil && r.Status.Source != nil {
+		for _, name := range r.Status.Source.ResourceRefs {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
 func init() {
 	SchemeBuilder.Register(&ResourceGroup{}, &ResourceGroupList{})
 }
@@ -29,24 +29,120 @@ type ResourceGroupDeploymentSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	Placement  string                 `json:"placement"`
+	// Placement is the name of the Placement the deployment targets
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Placement string `json:"placement"`
+
 	Refs       []ResourceGroupRef     `json:"refs,omitempty"`
 	Parameters *runtime.RawExtension  `json:"parameters,omitempty"`
 	Resources  []ResourceGroupElement `json:"resources,omitempty"`
+
+	DriftPolicy DriftPolicy        `json:"driftPolicy,omitempty"`
+	BlastRadius *BlastRadiusPolicy `json:"blastRadius,omitempty"`
+	Approval    ApprovalPolicy     `json:"approval,omitempty"`
+
+	// Suspend stops the reconciliation of the ResourceGroupDeployment, so its Resources aren't created or updated
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Mode Plan evaluates the resources and publishes what would change in status.plan, without applying anything
+	Mode DeploymentMode `json:"mode,omitempty"`
+
+	// ExpressionLanguage is the language of the ${...} expressions of the resources, copied from the ResourceGroup
+	ExpressionLanguage ExpressionLanguage `json:"expressionLanguage,omitempty"`
+
+	// TimeToReady is the time-to-ready SLO of the deployment runs, copied from the ResourceGroup
+	TimeToReady *metav1.Duration `json:"timeToReady,omitempty"`
+}
+
+// DeploymentMode controls whether a ResourceGroupDeployment applies its resources or only plans them
+// +kubebuilder:validation:Enum=Apply;Plan
+type DeploymentMode string
+
+const (
+	DeploymentModeApply DeploymentMode = "Apply"
+	DeploymentModePlan  DeploymentMode = "Plan"
+)
+
+// PlanAction is what applying a planned resource would do
+type PlanAction string
+
+const (
+	PlanActionCreate   PlanAction = "Create"
+	PlanActionUpdate   PlanAction = "Update"
+	PlanActionNoChange PlanAction = "NoChange"
+	// PlanActionDelete is used when a deployed Resource was removed from the ResourceGroup and will be pruned
+	PlanActionDelete PlanAction = "Delete"
+	// PlanActionUnknown is used when the properties of a deployed Resource depend on outputs only known after the apply
+	PlanActionUnknown PlanAction = "Unknown"
+)
+
+// ResourceGroupDeploymentPlan is the preview of a deployment in Plan mode
+type ResourceGroupDeploymentPlan struct {
+	ObservedGeneration int64                                    `json:"observedGeneration"`
+	PlannedAt          metav1.Time                              `json:"plannedAt"`
+	Resources          []ResourceGroupDeploymentPlannedResource `json:"resources,omitempty"`
+}
+
+type ResourceGroupDeploymentPlannedResource struct {
+	// Name of the Resource object
+	Name   string     `json:"name"`
+	Action PlanAction `json:"action"`
+	// Spec is the rendered Resource spec that would be applied
+	Spec *ResourceSpec `json:"spec,omitempty"`
+	// Diff lists the changes from the deployed Resource, one per line
+	Diff []string `json:"diff,omitempty"`
+	// Provisioner is the object the provisioner would apply, when the provisioner supports previews
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Provisioner *runtime.RawExtension `json:"provisioner,omitempty"`
+	Message     string                `json:"message,omitempty"`
 }
 
 type ResourceGroupDeploymentResourcesStatuses map[string]ResourceStatus
 
-type ResourceGroupDeploymentStatusPhase string
+// ResourceGroupDeploymentInputs is a snapshot of the parameters and refs resolved at the start of a deployment run;
+// every resource in the run is rendered from the same snapshot.
+type ResourceGroupDeploymentInputs struct {
+	ObservedGeneration int64                 `json:"observedGeneration"`
+	ResolvedAt         metav1.Time           `json:"resolvedAt"`
+	Parameters         *runtime.RawExtension `json:"parameters,omitempty"`
+	Refs               *runtime.RawExtension `json:"refs,omitempty"`
+}
 
 // ResourceGroupDeploymentStatus defines the observed state of ResourceGroupDeployment
 type ResourceGroupDeploymentStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	Inputs     *ResourceGroupDeploymentInputs           `json:"inputs,omitempty"`
 	Resources  ResourceGroupDeploymentResourcesStatuses `json:"resources,omitempty"`
-	Phase      ResourceGroupDeploymentStatusPhase       `json:"phase,omitempty"`
+	Phase      DeploymentPhase                          `json:"phase,omitempty"`
+	Plan       *ResourceGroupDeploymentPlan             `json:"plan,omitempty"`
 	Conditions []metav1.Condition                       `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// LastHandledReconcileAt is the last reconcile request from the placement handled by the deployment
+	LastHandledReconcileAt string `json:"lastHandledReconcileAt,omitempty"`
+
+	// Graph is the dependency graph between the resources, rendered to be visualized
+	Graph *ResourceGroupDeploymentGraph `json:"graph,omitempty"`
+
+	// TimeToReady is how long the last deployment run took, from the resolution of its inputs to its resources being
+	// ready
+	TimeToReady *metav1.Duration `json:"timeToReady,omitempty"`
+}
+
+type DeploymentGraphFormat string
+
+const (
+	DeploymentGraphMermaid DeploymentGraphFormat = "mermaid"
+	DeploymentGraphDOT     DeploymentGraphFormat = "dot"
+)
+
+// ResourceGroupDeploymentGraph is the dependency graph rendered in the format chosen through the graphFormat
+// annotation; each edge goes from a resource to the ones depending on it.
+type ResourceGroupDeploymentGraph struct {
+	Format   DeploymentGraphFormat `json:"format"`
+	Rendered string                `json:"rendered"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,108 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ResourceGroupTestSpec defines the desired state of ResourceGroupTest
+type ResourceGroupTestSpec struct {
+	// ResourceGroup whose expressions are tested
+	// +kubebuilder:validation:MinLength=1
+	ResourceGroup string `json:"resourceGroup"`
+
+	// +kubebuilder:validation:MinItems=1
+	Cases []ResourceGroupTestCase `json:"cases"`
+}
+
+// ResourceGroupTestCase renders the resources of the group from fixtures, instead of the cluster state, and compares
+// them with the expected properties
+type ResourceGroupTestCase struct {
+	Name string `json:"name"`
+
+	// Parameters are merged over the parameters declared by the ResourceGroup
+	Parameters *runtime.RawExtension `json:"parameters,omitempty"`
+
+	// Refs are the objects referenced by the group, keyed by the ref name
+	Refs *runtime.RawExtension `json:"refs,omitempty"`
+
+	// Outputs are the outputs published by each resource, keyed by the resource name
+	Outputs *runtime.RawExtension `json:"outputs,omitempty"`
+
+	// Placement is the placement the resources are rendered to; its name is the case name
+	Placement *PlacementSpec `json:"placement,omitempty"`
+
+	// Expected are the rendered properties, keyed by the resource name. Only the listed resources and properties are
+	// checked, so a case can focus on the expressions it's about.
+	Expected *runtime.RawExtension `json:"expected"`
+}
+
+type ResourceGroupTestPhase string
+
+const (
+	ResourceGroupTestPassedPhase ResourceGroupTestPhase = "Passed"
+	ResourceGroupTestFailedPhase ResourceGroupTestPhase = "Failed"
+)
+
+// ResourceGroupTestResult is the outcome of a test case
+type ResourceGroupTestResult struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	// Failures lists each mismatch between the rendered and the expected properties
+	Failures []string `json:"failures,omitempty"`
+}
+
+// ResourceGroupTestStatus defines the observed state of ResourceGroupTest
+type ResourceGroupTestStatus struct {
+	ObservedGeneration int64                     `json:"observedGeneration,omitempty"`
+	Phase              ResourceGroupTestPhase    `json:"phase,omitempty"`
+	Results            []ResourceGroupTestResult `json:"results,omitempty"`
+	Conditions         []metav1.Condition        `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="ResourceGroup",type="string",JSONPath=".spec.resourceGroup"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ResourceGroupTest is the Schema for the resourcegrouptests API.
+// Its cases run every time the test or the ResourceGroup changes, catching template regressions before they are
+// rolled out.
+type ResourceGroupTest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResourceGroupTestSpec   `json:"spec,omitempty"`
+	Status ResourceGroupTestStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResourceGroupTestList contains a list of ResourceGroupTest
+type ResourceGroupTestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResourceGroupTest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ResourceGroupTest{}, &ResourceGroupTestList{})
+}
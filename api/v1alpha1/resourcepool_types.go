@@ -0,0 +1,114 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// ResourcePoolLabel is the pool a standby Resource belongs to
+	ResourcePoolLabel = Group + "/pool"
+	// ResourcePoolClaimedByAnnotation is the Resource a standby Resource was claimed by
+	ResourcePoolClaimedByAnnotation = Group + "/pool.claimedBy"
+	// ResourcePoolClaimedAtAnnotation is when a standby Resource was claimed, in RFC 3339
+	ResourcePoolClaimedAtAnnotation = Group + "/pool.claimedAt"
+	// ResourcePoolAdoptedAnnotation marks a claimed standby Resource whose claimant was created and took the provisioner
+	// object over; the claim doesn't expire anymore
+	ResourcePoolAdoptedAnnotation = Group + "/pool.adopted"
+	// ResourcePoolClaimedFromAnnotation is the standby Resource a Resource took the provisioner object over from
+	ResourcePoolClaimedFromAnnotation = Group + "/pool.claimedFrom"
+	// ResourcePoolClaimedFromLabel is the pool a Resource claimed its standby Resource from, so the pool counts its
+	// claims
+	ResourcePoolClaimedFromLabel = Group + "/pool.claimedFrom"
+)
+
+// ResourcePoolSpec defines the desired state of ResourcePool
+type ResourcePoolSpec struct {
+	// ResourceRef of the standby Resources; deployments claim from the pool the resources with the same ResourceRef
+	ResourceRef string `json:"resourceRef"`
+
+	// Placement the standby Resources are provisioned to; deployments claim from the pool the resources deployed to
+	// the same placement
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Placement string `json:"placement"`
+
+	// Size is how many standby Resources are kept provisioned, waiting to be claimed
+	// +kubebuilder:validation:Minimum=0
+	Size int32 `json:"size"`
+
+	// Properties the standby Resources are provisioned with; once claimed, the provisioner object is updated with the
+	// properties of the deployment, so they should be the ones slow to change
+	Properties *runtime.RawExtension `json:"properties,omitempty"`
+}
+
+// ResourcePoolStatus defines the observed state of ResourcePool
+type ResourcePoolStatus struct {
+	// Available is how many standby Resources are provisioned, ready to be claimed
+	Available int32 `json:"available,omitempty"`
+	// Provisioning is how many standby Resources are still being provisioned
+	Provisioning int32 `json:"provisioning,omitempty"`
+	// Claimed is how many Resources of deployments run on a provisioner object taken over from a standby Resource of
+	// the pool
+	Claimed int32 `json:"claimed,omitempty"`
+
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="ResourceRef",type="string",JSONPath=".spec.resourceRef"
+// +kubebuilder:printcolumn:name="Placement",type="string",JSONPath=".spec.placement"
+// +kubebuilder:printcolumn:name="Size",type="integer",JSONPath=".spec.size"
+// +kubebuilder:printcolumn:name="Available",type="integer",JSONPath=".status.available"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ResourcePool is the Schema for the resourcepools API.
+// It keeps standby Resources provisioned ahead of time, so deployments in the same namespace creating a resource
+// with the same ResourceRef and placement take over the provisioner object of one of them, instead of waiting for
+// slow-to-create infrastructure, like Kafka topics or buckets.
+type ResourcePool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResourcePoolSpec   `json:"spec,omitempty"`
+	Status ResourcePoolStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResourcePoolList contains a list of ResourcePool
+type ResourcePoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResourcePool `json:"items"`
+}
+
+// ProvisionerObjectNameOf is the provisioner object name of a deployed Resource, when its element declares name; a
+// Resource claimed from a pool keeps the object of the standby Resource, unless the element names one itself
+func ProvisionerObjectNameOf(resource *Resource, name string) string {
+	if _, claimed := resource.Annotations[ResourcePoolClaimedFromAnnotation]; claimed && name == "" {
+		return resource.Spec.ProvisionerObjectName
+	}
+	return name
+}
+
+func init() {
+	SchemeBuilder.Register(&ResourcePool{}, &ResourcePoolList{})
+}
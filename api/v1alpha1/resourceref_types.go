@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -31,23 +32,95 @@ type ResourceRefSpec struct {
 
 	Provisioner ResourceRefProvisioner `json:"provisioner"`
 	Schema      ResourceRefSchema      `json:"schema"`
+
+	// Permissions required by the provisioner runner; when present, the runner's service account
+	// is bound to a Role generated with these rules instead of the shared tf-runner ClusterRole.
+	Permissions *ResourceRefPermissions `json:"permissions,omitempty"`
+
+	// OutputStore persists the outputs of Resources from this ResourceRef; when empty, the operator's default store
+	// is used.
+	OutputStore *ResourceRefOutputStore `json:"outputStore,omitempty"`
+
+	// SensitiveOutputs are kept in a Secret owned by each Resource, and redacted as *** in the output store; expressions
+	// still read their actual values
+	SensitiveOutputs []string `json:"sensitiveOutputs,omitempty"`
+
+	// PlacementSelector selects the Placements resources from this ResourceRef can be deployed to; when empty, every
+	// Placement is selected.
+	PlacementSelector *metav1.LabelSelector `json:"placementSelector,omitempty"`
+
+	// Requires are the klaudio capabilities this ResourceRef relies on; a controller without any of them refuses the
+	// ResourceRef, and the ResourceGroups using it
+	Requires []Capability `json:"requires,omitempty"`
+
+	// SecretRotationPolicy is what a rotation of the Secrets read by the provisioner objects, like the varsFrom of a
+	// Terraform object, does to the Resources; defaults to Reprovision. Secrets read by expressions change the
+	// properties of the Resources, which are always applied again.
+	SecretRotationPolicy SecretRotationPolicy `json:"secretRotationPolicy,omitempty"`
 }
 
 type ResourceRefProvisionerName string
 
 const (
-	ResourceRefPulumiProvisioner = "pulumi"
+	ResourceRefPulumiProvisioner     = "pulumi"
+	ResourceRefOpenTofuProvisioner   = "opentofu"
+	ResourceRefCrossplaneProvisioner = "crossplane"
+	ResourceRefHelmProvisioner       = "helm"
+	ResourceRefNoopProvisioner       = "noop"
+	ResourceRefHttpProvisioner       = "http"
 )
 
+// ResourceRefProvisionerChangeApprovalAnnotation, set to "true", accepts a change of the provisioner of a ResourceRef
+// still used by Resources; the objects created by the previous provisioner are left behind, to be cleaned up by hand
+const ResourceRefProvisionerChangeApprovalAnnotation = Group + "/approveProvisionerChange"
+
 type ResourceRefProvisioner struct {
 	Name       ResourceRefProvisionerName `json:"name"`
 	Properties *runtime.RawExtension      `json:"properties,omitempty"`
+
+	// Overrides are merged into the spec of the objects generated by the provisioner, setting fields that klaudio
+	// doesn't model yet
+	Overrides []ResourceRefProvisionerOverride `json:"overrides,omitempty"`
+}
+
+// ResourceRefProvisionerOverride is a merge patch to the spec of the generated objects of a kind (e.g. Terraform, Stack
+// or GitRepository): nested objects are merged, null removes a field and any other value, lists included, replaces
+// the generated one.
+type ResourceRefProvisionerOverride struct {
+	Kind string                `json:"kind"`
+	Spec *runtime.RawExtension `json:"spec"`
+}
+
+const (
+	ResourceRefStatusOutputStore    = "status"
+	ResourceRefSecretOutputStore    = "secret"
+	ResourceRefConfigMapOutputStore = "configmap"
+)
+
+// ResourceRefOutputStore selects where outputs are persisted: the Resource status, a Secret, a ConfigMap, or any
+// external store registered in the operator (like Vault or SSM)
+type ResourceRefOutputStore struct {
+	Name       string                `json:"name"`
+	Properties *runtime.RawExtension `json:"properties,omitempty"`
+}
+
+type ResourceRefPermissions struct {
+	ServiceAccountName string              `json:"serviceAccountName,omitempty"`
+	Rules              []rbacv1.PolicyRule `json:"rules"`
 }
 
 type ResourceRefSchema struct {
 	Type        string `json:"type"`
 	Description string `json:"description,omitempty"`
 
+	// Required are the properties that must be declared; only objects have required properties
+	Required []string `json:"required,omitempty"`
+
+	// Default is the value of the property when it isn't declared by the resource
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	Default *runtime.RawExtension `json:"default,omitempty"`
+
 	// +kubebuilder:pruning:PreserveUnknownFields
 	// +kubebuilder:validation:Schemaless
 	Properties map[string]ResourceRefSchema `json:"properties,omitempty"`
@@ -56,7 +129,8 @@ type ResourceRefSchema struct {
 type ResourceRefStatusDescription string
 
 const (
-	ResourceRefStatusReady ResourceRefStatusDescription = "Ready"
+	ResourceRefStatusReady       ResourceRefStatusDescription = "Ready"
+	ResourceRefStatusUnsupported ResourceRefStatusDescription = "Unsupported"
 )
 
 // ResourceRefStatus defines the observed state of ResourceRef
@@ -5,22 +5,277 @@ const (
 	ConditionTypeInProgress   string = "InProgress"
 	ConditionTypeFailed       string = "Failed"
 	ConditionTypeReady        string = "Ready"
+	ConditionTypeDrifted      string = "Drifted"
+	ConditionTypeFrozen       string = "Frozen"
+	ConditionTypePlanned      string = "Planned"
+	ConditionTypeApproved     string = "Approved"
+	ConditionTypeSuspended    string = "Suspended"
+	ConditionTypeStaleInputs  string = "StaleInputs"
+
+	// ConditionTypeUnsupported means the spec requires capabilities this controller doesn't have
+	ConditionTypeUnsupported string = "Unsupported"
+
+	// ConditionTypeOutputsRemoved warns that a provisioning run returned fewer outputs than the previous one
+	ConditionTypeOutputsRemoved string = "OutputsRemoved"
+
+	// ConditionTypeNeedsRotation means a Secret read by the provisioner object was rotated after the last successful run
+	ConditionTypeNeedsRotation string = "NeedsRotation"
+
+	// ConditionTypeSlowProvisioning means a deployment run took, or is taking, longer than the time-to-ready SLO
+	ConditionTypeSlowProvisioning string = "SlowProvisioning"
+
+	// stages of a ResourceGroupDeployment reconciliation
+	ConditionTypeInputsResolved string = "InputsResolved"
+	ConditionTypeGraphBuilt     string = "GraphBuilt"
+	ConditionTypeRendered       string = "Rendered"
+	ConditionTypeApplied        string = "Applied"
 
 	ConditionReasonReconciling = "Reconciling"
-	ConditionReasonFailed      = "Failed"
+	ConditionReasonRetrying    = "Retrying"
+
+	// Deprecated: Failed conditions use the failure classes below
+	ConditionReasonFailed = "Failed"
+
+	// the reason of every Failed condition is one of these failure classes, so automation can react to a class of
+	// failures without knowing each of them; the message tells the specific cause
+
+	// ConditionReasonInputError means the spec can't be deployed as it is: invalid properties, schema violations,
+	// dependency cycles or unsupported provisioners. Retrying doesn't help; the spec must change.
+	ConditionReasonInputError = "InputError"
+	// ConditionReasonDependencyNotReady means something the deployment relies on is missing or unreachable, like a
+	// ResourceGroup or the cluster of a placement. It's retried once the dependency changes.
+	ConditionReasonDependencyNotReady = "DependencyNotReady"
+	// ConditionReasonProvisionerError means the provisioner failed to create, update or destroy the infrastructure
+	ConditionReasonProvisionerError = "ProvisionerError"
+	// ConditionReasonPolicyViolation means a policy, like the blast radius of a deployment, blocked it
+	ConditionReasonPolicyViolation = "PolicyViolation"
+	// ConditionReasonTimeout means an operation didn't finish in time
+	ConditionReasonTimeout = "Timeout"
+	// ConditionReasonConflict means an object klaudio would write already exists, or was changed by someone else
+	ConditionReasonConflict = "Conflict"
+	// ConditionReasonVerificationFailed means the infrastructure was provisioned, but the verification Job of the
+	// Resource failed
+	ConditionReasonVerificationFailed = "VerificationFailed"
+	// ConditionReasonHookFailed means a hook Job of the teardown failed, holding it
+	ConditionReasonHookFailed = "HookFailed"
 
 	ConditionReasonDeploymentInProgress = "DeploymentInProgress"
 	ConditionReasonDeploymentDone       = "DeploymentDone"
 	ConditionReasonDeploymentFailed     = "DeploymentFailed"
+
+	ConditionReasonNamespaceDeleted  = "NamespaceDeleted"
+	ConditionReasonNamespaceNotEmpty = "NamespaceNotEmpty"
+
+	ConditionReasonDriftDetected   = "DriftDetected"
+	ConditionReasonDriftCorrecting = "DriftCorrecting"
+
+	ConditionReasonPlacementFrozen   = "PlacementFrozen"
+	ConditionReasonPlacementUnfrozen = "PlacementUnfrozen"
+
+	ConditionReasonPlanReady = "PlanReady"
+
+	ConditionReasonTimeToReadyExceeded = "TimeToReadyExceeded"
+	ConditionReasonTimeToReadyMet      = "TimeToReadyMet"
+
+	// Deprecated: use ConditionReasonPolicyViolation
+	ConditionReasonBlastRadiusExceeded = "BlastRadiusExceeded"
+
+	// Deprecated: use ConditionReasonInputError
+	ConditionReasonSchemaViolation = "SchemaViolation"
+
+	// ConditionReasonDependencyCycle is the reason of the Event about a cycle; its Failed condition is an InputError
+	ConditionReasonDependencyCycle = "DependencyCycle"
+
+	ConditionReasonPendingApproval = "PendingApproval"
+	ConditionReasonApproved        = "Approved"
+
+	ConditionReasonSuspended = "Suspended"
+	ConditionReasonResumed   = "Resumed"
+
+	ConditionReasonCapabilitiesMissing   = "CapabilitiesMissing"
+	ConditionReasonCapabilitiesSupported = "CapabilitiesSupported"
+
+	ConditionReasonPlacementSuspended = "PlacementSuspended"
+
+	ConditionReasonStageSucceeded = "StageSucceeded"
+	ConditionReasonStageFailed    = "StageFailed"
+
+	ConditionReasonResourcePruned = "ResourcePruned"
+
+	ConditionReasonOutputsExpired  = "OutputsExpired"
+	ConditionReasonInputsRefreshed = "InputsRefreshed"
+
+	ConditionReasonOutputsRemoved = "OutputsRemoved"
+	ConditionReasonOutputsHeld    = "OutputsHeld"
+
+	ConditionReasonSecretsRotated = "SecretsRotated"
+	ConditionReasonReprovisioning = "Reprovisioning"
+	ConditionReasonSecretsApplied = "SecretsApplied"
+
+	ConditionReasonDestroying  = "Destroying"
+	ConditionReasonVerifying   = "Verifying"
+	ConditionReasonRunningHook = "RunningHook"
+	// Deprecated: use ConditionReasonProvisionerError
+	ConditionReasonDestroyFailed = "DestroyFailed"
+
+	ConditionReasonPluginRegistered = "PluginRegistered"
+	ConditionReasonPluginRejected   = "PluginRejected"
+
+	ConditionReasonConfigApplied = "ConfigApplied"
+	ConditionReasonConfigIgnored = "ConfigIgnored"
+
+	ConditionReasonPoolFilled  = "PoolFilled"
+	ConditionReasonPoolFilling = "PoolFilling"
+
+	ConditionReasonTestsPassed = "TestsPassed"
+	// Deprecated: failed cases are reported as ConditionReasonInputError
+	ConditionReasonTestsFailed = "TestsFailed"
+
+	ConditionReasonResourceRefNotFound = "ResourceRefNotFound"
+	ConditionReasonSourceNotReady      = "SourceNotReady"
 )
 
+// ConditionTypes are the types of the conditions written by this version; older conditions of other types are dropped
+// from the status
+var ConditionTypes = []string{
+	ConditionTypeInitializing,
+	ConditionTypeInProgress,
+	ConditionTypeFailed,
+	ConditionTypeReady,
+	ConditionTypeDrifted,
+	ConditionTypeFrozen,
+	ConditionTypePlanned,
+	ConditionTypeApproved,
+	ConditionTypeSuspended,
+	ConditionTypeStaleInputs,
+	ConditionTypeUnsupported,
+	ConditionTypeOutputsRemoved,
+	ConditionTypeNeedsRotation,
+	ConditionTypeSlowProvisioning,
+	ConditionTypeInputsResolved,
+	ConditionTypeGraphBuilt,
+	ConditionTypeRendered,
+	ConditionTypeApplied,
+}
+
+// FailureReasons are the classes of failures reported by Failed conditions
+var FailureReasons = []string{
+	ConditionReasonInputError,
+	ConditionReasonDependencyNotReady,
+	ConditionReasonProvisionerError,
+	ConditionReasonPolicyViolation,
+	ConditionReasonTimeout,
+	ConditionReasonConflict,
+	ConditionReasonVerificationFailed,
+}
+
+// DriftPolicy controls what happens when a provisioned resource diverges from its declared state
+// +kubebuilder:validation:Enum=Ignore;Warn;Correct
+type DriftPolicy string
+
 const (
-	DeploymentInProgressPhase = "DeploymentInProgress"
-	DeploymentDonePhase       = "DeploymentDone"
-	DeploymentFailedPhase     = "DeploymentFailed"
+	// DriftPolicyIgnore disables drift detection
+	DriftPolicyIgnore DriftPolicy = "Ignore"
+	// DriftPolicyWarn reports the drift through conditions and metrics, without changing the resource
+	DriftPolicyWarn DriftPolicy = "Warn"
+	// DriftPolicyCorrect re-applies the declared state automatically
+	DriftPolicyCorrect DriftPolicy = "Correct"
 )
 
-func StatusPhaseToReason(phase string) string {
+// DeletionPolicy controls what happens to the provisioned infrastructure when a Resource is deleted
+// +kubebuilder:validation:Enum=Delete;Orphan;Retain
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete destroys the infrastructure before the Resource goes away
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan leaves the infrastructure and the provisioner objects behind, no longer owned by klaudio
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+	// DeletionPolicyRetain removes the provisioner objects but keeps the infrastructure and its state, so it can be adopted again
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+)
+
+// SecretRotationPolicy controls what happens when a Secret read by the provisioner object of a Resource is rotated
+// +kubebuilder:validation:Enum=Reprovision;Manual
+type SecretRotationPolicy string
+
+const (
+	// SecretRotationPolicyReprovision runs the provisioner again, so the infrastructure picks up the new values
+	SecretRotationPolicyReprovision SecretRotationPolicy = "Reprovision"
+	// SecretRotationPolicyManual only reports the rotation through the NeedsRotation condition; the provisioner runs
+	// again once a refresh is requested through the refreshRequestedAt annotation
+	SecretRotationPolicyManual SecretRotationPolicy = "Manual"
+)
+
+// DeploymentPhase is the phase shared by Resources, ResourceGroupDeployments and ResourceGroups
+// +kubebuilder:validation:Enum=DeploymentInProgress;DeploymentDone;DeploymentFailed;PendingApproval
+type DeploymentPhase string
+
+const (
+	DeploymentInProgressPhase DeploymentPhase = "DeploymentInProgress"
+	DeploymentDonePhase       DeploymentPhase = "DeploymentDone"
+	DeploymentFailedPhase     DeploymentPhase = "DeploymentFailed"
+	// DeploymentPendingApprovalPhase holds a rendered deployment run until an operator approves it
+	DeploymentPendingApprovalPhase DeploymentPhase = "PendingApproval"
+)
+
+// ApprovalPolicy controls whether deployment runs are applied right after rendered or wait for an operator
+// +kubebuilder:validation:Enum=Automatic;Manual
+type ApprovalPolicy string
+
+const (
+	ApprovalPolicyAutomatic ApprovalPolicy = "Automatic"
+	ApprovalPolicyManual    ApprovalPolicy = "Manual"
+)
+
+const (
+	// ApprovedAnnotation approves a run of a ResourceGroupDeployment with the Manual approval policy; the value is the
+	// approved generation, so any later change waits for a new approval
+	ApprovedAnnotation = Group + "/approved"
+	// ApprovedByAnnotation identifies who approved the run. It's written by the ResourceGroupDeployment webhook from
+	// the user of the request setting ApprovedAnnotation; values set by anyone else are discarded.
+	ApprovedByAnnotation = Group + "/approvedBy"
+)
+
+// NamespaceStrategy is how the deployments of a ResourceGroup are spread over namespaces
+// +kubebuilder:validation:Enum=Group;Placement
+type NamespaceStrategy string
+
+const (
+	// NamespacePerGroup deploys every placement into a single namespace, named after the group
+	NamespacePerGroup NamespaceStrategy = "Group"
+	// NamespacePerPlacement deploys each placement into its own namespace, named <group>-<placement>
+	NamespacePerPlacement NamespaceStrategy = "Placement"
+)
+
+// ExpressionLanguage is the language of the ${...} expressions of a ResourceGroup
+// +kubebuilder:validation:Enum=expr;cel
+type ExpressionLanguage string
+
+const (
+	// ExpressionLanguageExpr evaluates expressions with expr-lang
+	ExpressionLanguageExpr ExpressionLanguage = "expr"
+	// ExpressionLanguageCEL evaluates expressions with the Common Expression Language
+	ExpressionLanguageCEL ExpressionLanguage = "cel"
+)
+
+// NormalizeDeploymentPhase maps phase values written by older versions to the current DeploymentPhase taxonomy;
+// the second return value is false when the value is unknown.
+func NormalizeDeploymentPhase(phase string) (DeploymentPhase, bool) {
+	switch phase {
+	case string(DeploymentInProgressPhase), "DeploymentRunning", "Running", "InProgress":
+		return DeploymentInProgressPhase, true
+	case string(DeploymentDonePhase), "Done", "Success", "Ready":
+		return DeploymentDonePhase, true
+	case string(DeploymentFailedPhase), "Failed":
+		return DeploymentFailedPhase, true
+	case string(DeploymentPendingApprovalPhase):
+		return DeploymentPendingApprovalPhase, true
+	}
+	return DeploymentPhase(phase), false
+}
+
+func StatusPhaseToReason(phase DeploymentPhase) string {
 	switch phase {
 	case DeploymentInProgressPhase:
 		return ConditionReasonDeploymentInProgress
@@ -28,6 +283,8 @@ func StatusPhaseToReason(phase string) string {
 		return ConditionReasonDeploymentDone
 	case DeploymentFailedPhase:
 		return ConditionReasonDeploymentFailed
+	case DeploymentPendingApprovalPhase:
+		return ConditionReasonPendingApproval
 	}
-	return phase
+	return string(phase)
 }
@@ -21,12 +21,59 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Resource) DeepCopyInto(out *Resource) {
+func (in *BlastRadiusPolicy) DeepCopyInto(out *BlastRadiusPolicy) {
+	*out = *in
+	if in.MaxResources != nil {
+		in, out := &in.MaxResources, &out.MaxResources
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxPercentage != nil {
+		in, out := &in.MaxPercentage, &out.MaxPercentage
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlastRadiusPolicy.
+func (in *BlastRadiusPolicy) DeepCopy() *BlastRadiusPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(BlastRadiusPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalRef) DeepCopyInto(out *ExternalRef) {
+	*out = *in
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalRef.
+func (in *ExternalRef) DeepCopy() *ExternalRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KlaudioConfig) DeepCopyInto(out *KlaudioConfig) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -34,18 +81,18 @@ func (in *Resource) DeepCopyInto(out *Resource) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Resource.
-func (in *Resource) DeepCopy() *Resource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlaudioConfig.
+func (in *KlaudioConfig) DeepCopy() *KlaudioConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(Resource)
+	out := new(KlaudioConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Resource) DeepCopyObject() runtime.Object {
+func (in *KlaudioConfig) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -53,26 +100,31 @@ func (in *Resource) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceGroup) DeepCopyInto(out *ResourceGroup) {
+func (in *KlaudioConfigList) DeepCopyInto(out *KlaudioConfigList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KlaudioConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroup.
-func (in *ResourceGroup) DeepCopy() *ResourceGroup {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlaudioConfigList.
+func (in *KlaudioConfigList) DeepCopy() *KlaudioConfigList {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceGroup)
+	out := new(KlaudioConfigList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ResourceGroup) DeepCopyObject() runtime.Object {
+func (in *KlaudioConfigList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -80,7 +132,108 @@ func (in *ResourceGroup) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceGroupDeployment) DeepCopyInto(out *ResourceGroupDeployment) {
+func (in *KlaudioConfigSpec) DeepCopyInto(out *KlaudioConfigSpec) {
+	*out = *in
+	in.FeatureGates.DeepCopyInto(&out.FeatureGates)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlaudioConfigSpec.
+func (in *KlaudioConfigSpec) DeepCopy() *KlaudioConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KlaudioConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KlaudioConfigStatus) DeepCopyInto(out *KlaudioConfigStatus) {
+	*out = *in
+	if in.DisabledProvisioners != nil {
+		in, out := &in.DisabledProvisioners, &out.DisabledProvisioners
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlaudioConfigStatus.
+func (in *KlaudioConfigStatus) DeepCopy() *KlaudioConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KlaudioConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KlaudioFeatureGates) DeepCopyInto(out *KlaudioFeatureGates) {
+	*out = *in
+	if in.Provisioners != nil {
+		in, out := &in.Provisioners, &out.Provisioners
+		*out = make(map[string]bool, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlaudioFeatureGates.
+func (in *KlaudioFeatureGates) DeepCopy() *KlaudioFeatureGates {
+	if in == nil {
+		return nil
+	}
+	out := new(KlaudioFeatureGates)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KlaudioFleetFailingPlacement) DeepCopyInto(out *KlaudioFleetFailingPlacement) {
+	*out = *in
+	if in.Deployments != nil {
+		in, out := &in.Deployments, &out.Deployments
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlaudioFleetFailingPlacement.
+func (in *KlaudioFleetFailingPlacement) DeepCopy() *KlaudioFleetFailingPlacement {
+	if in == nil {
+		return nil
+	}
+	out := new(KlaudioFleetFailingPlacement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KlaudioFleetObject) DeepCopyInto(out *KlaudioFleetObject) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlaudioFleetObject.
+func (in *KlaudioFleetObject) DeepCopy() *KlaudioFleetObject {
+	if in == nil {
+		return nil
+	}
+	out := new(KlaudioFleetObject)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KlaudioFleetStatus) DeepCopyInto(out *KlaudioFleetStatus) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -88,18 +241,18 @@ func (in *ResourceGroupDeployment) DeepCopyInto(out *ResourceGroupDeployment) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeployment.
-func (in *ResourceGroupDeployment) DeepCopy() *ResourceGroupDeployment {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlaudioFleetStatus.
+func (in *KlaudioFleetStatus) DeepCopy() *KlaudioFleetStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceGroupDeployment)
+	out := new(KlaudioFleetStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ResourceGroupDeployment) DeepCopyObject() runtime.Object {
+func (in *KlaudioFleetStatus) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -107,31 +260,31 @@ func (in *ResourceGroupDeployment) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceGroupDeploymentList) DeepCopyInto(out *ResourceGroupDeploymentList) {
+func (in *KlaudioFleetStatusList) DeepCopyInto(out *KlaudioFleetStatusList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]ResourceGroupDeployment, len(*in))
+		*out = make([]KlaudioFleetStatus, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentList.
-func (in *ResourceGroupDeploymentList) DeepCopy() *ResourceGroupDeploymentList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlaudioFleetStatusList.
+func (in *KlaudioFleetStatusList) DeepCopy() *KlaudioFleetStatusList {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceGroupDeploymentList)
+	out := new(KlaudioFleetStatusList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ResourceGroupDeploymentList) DeepCopyObject() runtime.Object {
+func (in *KlaudioFleetStatusList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -139,154 +292,1272 @@ func (in *ResourceGroupDeploymentList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in ResourceGroupDeploymentResourcesStatuses) DeepCopyInto(out *ResourceGroupDeploymentResourcesStatuses) {
-	{
-		in := &in
-		*out = make(ResourceGroupDeploymentResourcesStatuses, len(*in))
-		for key, val := range *in {
-			(*out)[key] = *val.DeepCopy()
-		}
+func (in *KlaudioFleetStatusSpec) DeepCopyInto(out *KlaudioFleetStatusSpec) {
+	*out = *in
+	if in.Limit != nil {
+		in, out := &in.Limit, &out.Limit
+		*out = new(int32)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentResourcesStatuses.
-func (in ResourceGroupDeploymentResourcesStatuses) DeepCopy() ResourceGroupDeploymentResourcesStatuses {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlaudioFleetStatusSpec.
+func (in *KlaudioFleetStatusSpec) DeepCopy() *KlaudioFleetStatusSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceGroupDeploymentResourcesStatuses)
+	out := new(KlaudioFleetStatusSpec)
 	in.DeepCopyInto(out)
-	return *out
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceGroupDeploymentSpec) DeepCopyInto(out *ResourceGroupDeploymentSpec) {
+func (in *KlaudioFleetStatusStatus) DeepCopyInto(out *KlaudioFleetStatusStatus) {
 	*out = *in
-	if in.Refs != nil {
-		in, out := &in.Refs, &out.Refs
-		*out = make([]ResourceGroupRef, len(*in))
-		copy(*out, *in)
+	if in.ResourceGroups != nil {
+		in, out := &in.ResourceGroups, &out.ResourceGroups
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
-	if in.Parameters != nil {
-		in, out := &in.Parameters, &out.Parameters
-		*out = new(runtime.RawExtension)
-		(*in).DeepCopyInto(*out)
+	if in.Deployments != nil {
+		in, out := &in.Deployments, &out.Deployments
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
 	}
 	if in.Resources != nil {
 		in, out := &in.Resources, &out.Resources
-		*out = make([]ResourceGroupElement, len(*in))
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.FailingPlacements != nil {
+		in, out := &in.FailingPlacements, &out.FailingPlacements
+		*out = make([]KlaudioFleetFailingPlacement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DriftedResources != nil {
+		in, out := &in.DriftedResources, &out.DriftedResources
+		*out = make([]KlaudioFleetObject, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OldestInProgressDeployments != nil {
+		in, out := &in.OldestInProgressDeployments, &out.OldestInProgressDeployments
+		*out = make([]KlaudioFleetObject, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.UpdatedAt != nil {
+		in, out := &in.UpdatedAt, &out.UpdatedAt
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentSpec.
-func (in *ResourceGroupDeploymentSpec) DeepCopy() *ResourceGroupDeploymentSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KlaudioFleetStatusStatus.
+func (in *KlaudioFleetStatusStatus) DeepCopy() *KlaudioFleetStatusStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceGroupDeploymentSpec)
+	out := new(KlaudioFleetStatusStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceGroupDeploymentStatus) DeepCopyInto(out *ResourceGroupDeploymentStatus) {
+func (in *Placement) DeepCopyInto(out *Placement) {
 	*out = *in
-	if in.Resources != nil {
-		in, out := &in.Resources, &out.Resources
-		*out = make(ResourceGroupDeploymentResourcesStatuses, len(*in))
-		for key, val := range *in {
-			(*out)[key] = *val.DeepCopy()
-		}
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Placement.
+func (in *Placement) DeepCopy() *Placement {
+	if in == nil {
+		return nil
 	}
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
+	out := new(Placement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Placement) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlacementCluster) DeepCopyInto(out *PlacementCluster) {
+	*out = *in
+	if in.KubeconfigSecretRef != nil {
+		in, out := &in.KubeconfigSecretRef, &out.KubeconfigSecretRef
+		*out = new(PlacementKubeconfigSecretRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlacementCluster.
+func (in *PlacementCluster) DeepCopy() *PlacementCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(PlacementCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlacementFreezeWindow) DeepCopyInto(out *PlacementFreezeWindow) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlacementFreezeWindow.
+func (in *PlacementFreezeWindow) DeepCopy() *PlacementFreezeWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(PlacementFreezeWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlacementKubeconfigSecretRef) DeepCopyInto(out *PlacementKubeconfigSecretRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlacementKubeconfigSecretRef.
+func (in *PlacementKubeconfigSecretRef) DeepCopy() *PlacementKubeconfigSecretRef {
+	if in == nil {
+		return nil
+	}
+	out := new(PlacementKubeconfigSecretRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlacementList) DeepCopyInto(out *PlacementList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Placement, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentStatus.
-func (in *ResourceGroupDeploymentStatus) DeepCopy() *ResourceGroupDeploymentStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlacementList.
+func (in *PlacementList) DeepCopy() *PlacementList {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceGroupDeploymentStatus)
+	out := new(PlacementList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PlacementList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in ResourceGroupDeploymentStatuses) DeepCopyInto(out *ResourceGroupDeploymentStatuses) {
-	{
-		in := &in
-		*out = make(ResourceGroupDeploymentStatuses, len(*in))
+func (in *PlacementSpec) DeepCopyInto(out *PlacementSpec) {
+	*out = *in
+	if in.Cluster != nil {
+		in, out := &in.Cluster, &out.Cluster
+		*out = new(PlacementCluster)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CredentialsSecretRef != nil {
+		in, out := &in.CredentialsSecretRef, &out.CredentialsSecretRef
+		*out = new(corev1.SecretReference)
+		**out = **in
+	}
+	if in.FreezeWindows != nil {
+		in, out := &in.FreezeWindows, &out.FreezeWindows
+		*out = make([]PlacementFreezeWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlacementSpec.
+func (in *PlacementSpec) DeepCopy() *PlacementSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PlacementSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisionerPlugin) DeepCopyInto(out *ProvisionerPlugin) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisionerPlugin.
+func (in *ProvisionerPlugin) DeepCopy() *ProvisionerPlugin {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisionerPlugin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProvisionerPlugin) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisionerPluginList) DeepCopyInto(out *ProvisionerPluginList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ProvisionerPlugin, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisionerPluginList.
+func (in *ProvisionerPluginList) DeepCopy() *ProvisionerPluginList {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisionerPluginList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ProvisionerPluginList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisionerPluginSpec) DeepCopyInto(out *ProvisionerPluginSpec) {
+	*out = *in
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(ProvisionerPluginTLS)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisionerPluginSpec.
+func (in *ProvisionerPluginSpec) DeepCopy() *ProvisionerPluginSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisionerPluginSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisionerPluginStatus) DeepCopyInto(out *ProvisionerPluginStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisionerPluginStatus.
+func (in *ProvisionerPluginStatus) DeepCopy() *ProvisionerPluginStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisionerPluginStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisionerPluginTLS) DeepCopyInto(out *ProvisionerPluginTLS) {
+	*out = *in
+	out.SecretRef = in.SecretRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisionerPluginTLS.
+func (in *ProvisionerPluginTLS) DeepCopy() *ProvisionerPluginTLS {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisionerPluginTLS)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Resource) DeepCopyInto(out *Resource) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Resource.
+func (in *Resource) DeepCopy() *Resource {
+	if in == nil {
+		return nil
+	}
+	out := new(Resource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Resource) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroup) DeepCopyInto(out *ResourceGroup) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroup.
+func (in *ResourceGroup) DeepCopy() *ResourceGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResourceGroup) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupDeployment) DeepCopyInto(out *ResourceGroupDeployment) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeployment.
+func (in *ResourceGroupDeployment) DeepCopy() *ResourceGroupDeployment {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupDeployment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResourceGroupDeployment) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupDeploymentGraph) DeepCopyInto(out *ResourceGroupDeploymentGraph) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentGraph.
+func (in *ResourceGroupDeploymentGraph) DeepCopy() *ResourceGroupDeploymentGraph {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupDeploymentGraph)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupDeploymentInputs) DeepCopyInto(out *ResourceGroupDeploymentInputs) {
+	*out = *in
+	in.ResolvedAt.DeepCopyInto(&out.ResolvedAt)
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Refs != nil {
+		in, out := &in.Refs, &out.Refs
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentInputs.
+func (in *ResourceGroupDeploymentInputs) DeepCopy() *ResourceGroupDeploymentInputs {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupDeploymentInputs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupDeploymentList) DeepCopyInto(out *ResourceGroupDeploymentList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ResourceGroupDeployment, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentList.
+func (in *ResourceGroupDeploymentList) DeepCopy() *ResourceGroupDeploymentList {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupDeploymentList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResourceGroupDeploymentList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupDeploymentPlan) DeepCopyInto(out *ResourceGroupDeploymentPlan) {
+	*out = *in
+	in.PlannedAt.DeepCopyInto(&out.PlannedAt)
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]ResourceGroupDeploymentPlannedResource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentPlan.
+func (in *ResourceGroupDeploymentPlan) DeepCopy() *ResourceGroupDeploymentPlan {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupDeploymentPlan)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupDeploymentPlannedResource) DeepCopyInto(out *ResourceGroupDeploymentPlannedResource) {
+	*out = *in
+	if in.Spec != nil {
+		in, out := &in.Spec, &out.Spec
+		*out = new(ResourceSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Diff != nil {
+		in, out := &in.Diff, &out.Diff
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Provisioner != nil {
+		in, out := &in.Provisioner, &out.Provisioner
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentPlannedResource.
+func (in *ResourceGroupDeploymentPlannedResource) DeepCopy() *ResourceGroupDeploymentPlannedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupDeploymentPlannedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in ResourceGroupDeploymentResourcesStatuses) DeepCopyInto(out *ResourceGroupDeploymentResourcesStatuses) {
+	{
+		in := &in
+		*out = make(ResourceGroupDeploymentResourcesStatuses, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentResourcesStatuses.
+func (in ResourceGroupDeploymentResourcesStatuses) DeepCopy() ResourceGroupDeploymentResourcesStatuses {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupDeploymentResourcesStatuses)
+	in.DeepCopyInto(out)
+	return *out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupDeploymentSpec) DeepCopyInto(out *ResourceGroupDeploymentSpec) {
+	*out = *in
+	if in.Refs != nil {
+		in, out := &in.Refs, &out.Refs
+		*out = make([]ResourceGroupRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]ResourceGroupElement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BlastRadius != nil {
+		in, out := &in.BlastRadius, &out.BlastRadius
+		*out = new(BlastRadiusPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TimeToReady != nil {
+		in, out := &in.TimeToReady, &out.TimeToReady
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentSpec.
+func (in *ResourceGroupDeploymentSpec) DeepCopy() *ResourceGroupDeploymentSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupDeploymentSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupDeploymentStatus) DeepCopyInto(out *ResourceGroupDeploymentStatus) {
+	*out = *in
+	if in.Inputs != nil {
+		in, out := &in.Inputs, &out.Inputs
+		*out = new(ResourceGroupDeploymentInputs)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make(ResourceGroupDeploymentResourcesStatuses, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = new(ResourceGroupDeploymentPlan)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Graph != nil {
+		in, out := &in.Graph, &out.Graph
+		*out = new(ResourceGroupDeploymentGraph)
+		**out = **in
+	}
+	if in.TimeToReady != nil {
+		in, out := &in.TimeToReady, &out.TimeToReady
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentStatus.
+func (in *ResourceGroupDeploymentStatus) DeepCopy() *ResourceGroupDeploymentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupDeploymentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in ResourceGroupDeploymentStatuses) DeepCopyInto(out *ResourceGroupDeploymentStatuses) {
+	{
+		in := &in
+		*out = make(ResourceGroupDeploymentStatuses, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentStatuses.
+func (in ResourceGroupDeploymentStatuses) DeepCopy() ResourceGroupDeploymentStatuses {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupDeploymentStatuses)
+	in.DeepCopyInto(out)
+	return *out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupElement) DeepCopyInto(out *ResourceGroupElement) {
+	*out = *in
+	if in.Properties != nil {
+		in, out := &in.Properties, &out.Properties
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExportedOutputs != nil {
+		in, out := &in.ExportedOutputs, &out.ExportedOutputs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Metadata != nil {
+		in, out := &in.Metadata, &out.Metadata
+		*out = new(ResourceGroupElementMetadata)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WriteOutputsTo != nil {
+		in, out := &in.WriteOutputsTo, &out.WriteOutputsTo
+		*out = new(ResourceOutputsTarget)
+		**out = **in
+	}
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(ResourceVerification)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(ResourceHooks)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupElement.
+func (in *ResourceGroupElement) DeepCopy() *ResourceGroupElement {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupElement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupElementMetadata) DeepCopyInto(out *ResourceGroupElementMetadata) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupElementMetadata.
+func (in *ResourceGroupElementMetadata) DeepCopy() *ResourceGroupElementMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupElementMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupList) DeepCopyInto(out *ResourceGroupList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ResourceGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupList.
+func (in *ResourceGroupList) DeepCopy() *ResourceGroupList {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResourceGroupList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupPlacementChanges) DeepCopyInto(out *ResourceGroupPlacementChanges) {
+	*out = *in
+	in.PlannedAt.DeepCopyInto(&out.PlannedAt)
+	if in.Create != nil {
+		in, out := &in.Create, &out.Create
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Destroy != nil {
+		in, out := &in.Destroy, &out.Destroy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupPlacementChanges.
+func (in *ResourceGroupPlacementChanges) DeepCopy() *ResourceGroupPlacementChanges {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupPlacementChanges)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupRef) DeepCopyInto(out *ResourceGroupRef) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.External != nil {
+		in, out := &in.External, &out.External
+		*out = new(ExternalRef)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupRef.
+func (in *ResourceGroupRef) DeepCopy() *ResourceGroupRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupSourceRef) DeepCopyInto(out *ResourceGroupSourceRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupSourceRef.
+func (in *ResourceGroupSourceRef) DeepCopy() *ResourceGroupSourceRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupSourceRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupSourceStatus) DeepCopyInto(out *ResourceGroupSourceStatus) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResourceRefs != nil {
+		in, out := &in.ResourceRefs, &out.ResourceRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupSourceStatus.
+func (in *ResourceGroupSourceStatus) DeepCopy() *ResourceGroupSourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupSourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupSpec) DeepCopyInto(out *ResourceGroupSpec) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Refs != nil {
+		in, out := &in.Refs, &out.Refs
+		*out = make([]ResourceGroupRef, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]ResourceGroupElement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BlastRadius != nil {
+		in, out := &in.BlastRadius, &out.BlastRadius
+		*out = new(BlastRadiusPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PlacementSelector != nil {
+		in, out := &in.PlacementSelector, &out.PlacementSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Requires != nil {
+		in, out := &in.Requires, &out.Requires
+		*out = make([]Capability, len(*in))
+		copy(*out, *in)
+	}
+	if in.TimeToReady != nil {
+		in, out := &in.TimeToReady, &out.TimeToReady
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.SourceRef != nil {
+		in, out := &in.SourceRef, &out.SourceRef
+		*out = new(ResourceGroupSourceRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupSpec.
+func (in *ResourceGroupSpec) DeepCopy() *ResourceGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupStatus) DeepCopyInto(out *ResourceGroupStatus) {
+	*out = *in
+	if in.Deployments != nil {
+		in, out := &in.Deployments, &out.Deployments
+		*out = make(ResourceGroupDeploymentStatuses, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Usage != nil {
+		in, out := &in.Usage, &out.Usage
+		*out = new(ResourceGroupUsage)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PlacementChanges != nil {
+		in, out := &in.PlacementChanges, &out.PlacementChanges
+		*out = new(ResourceGroupPlacementChanges)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(ResourceGroupSourceStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupStatus.
+func (in *ResourceGroupStatus) DeepCopy() *ResourceGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupTest) DeepCopyInto(out *ResourceGroupTest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupTest.
+func (in *ResourceGroupTest) DeepCopy() *ResourceGroupTest {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupTest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResourceGroupTest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupTestCase) DeepCopyInto(out *ResourceGroupTestCase) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Refs != nil {
+		in, out := &in.Refs, &out.Refs
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Outputs != nil {
+		in, out := &in.Outputs, &out.Outputs
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Placement != nil {
+		in, out := &in.Placement, &out.Placement
+		*out = new(PlacementSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Expected != nil {
+		in, out := &in.Expected, &out.Expected
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupTestCase.
+func (in *ResourceGroupTestCase) DeepCopy() *ResourceGroupTestCase {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupTestCase)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupTestList) DeepCopyInto(out *ResourceGroupTestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ResourceGroupTest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupTestList.
+func (in *ResourceGroupTestList) DeepCopy() *ResourceGroupTestList {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupTestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResourceGroupTestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupTestResult) DeepCopyInto(out *ResourceGroupTestResult) {
+	*out = *in
+	if in.Failures != nil {
+		in, out := &in.Failures, &out.Failures
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupTestResult.
+func (in *ResourceGroupTestResult) DeepCopy() *ResourceGroupTestResult {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupTestResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupTestSpec) DeepCopyInto(out *ResourceGroupTestSpec) {
+	*out = *in
+	if in.Cases != nil {
+		in, out := &in.Cases, &out.Cases
+		*out = make([]ResourceGroupTestCase, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupTestSpec.
+func (in *ResourceGroupTestSpec) DeepCopy() *ResourceGroupTestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupTestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupTestStatus) DeepCopyInto(out *ResourceGroupTestStatus) {
+	*out = *in
+	if in.Results != nil {
+		in, out := &in.Results, &out.Results
+		*out = make([]ResourceGroupTestResult, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupTestStatus.
+func (in *ResourceGroupTestStatus) DeepCopy() *ResourceGroupTestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupTestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceGroupUsage) DeepCopyInto(out *ResourceGroupUsage) {
+	*out = *in
+	out.CPURequests = in.CPURequests.DeepCopy()
+	out.MemoryRequests = in.MemoryRequests.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupUsage.
+func (in *ResourceGroupUsage) DeepCopy() *ResourceGroupUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceGroupUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceHook) DeepCopyInto(out *ResourceHook) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]string, len(*in))
 		for key, val := range *in {
-			(*out)[key] = *val.DeepCopy()
+			(*out)[key] = val
 		}
 	}
+	if in.BackoffLimit != nil {
+		in, out := &in.BackoffLimit, &out.BackoffLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupDeploymentStatuses.
-func (in ResourceGroupDeploymentStatuses) DeepCopy() ResourceGroupDeploymentStatuses {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceHook.
+func (in *ResourceHook) DeepCopy() *ResourceHook {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceGroupDeploymentStatuses)
+	out := new(ResourceHook)
 	in.DeepCopyInto(out)
-	return *out
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceGroupElement) DeepCopyInto(out *ResourceGroupElement) {
+func (in *ResourceHooks) DeepCopyInto(out *ResourceHooks) {
 	*out = *in
-	if in.Properties != nil {
-		in, out := &in.Properties, &out.Properties
-		*out = new(runtime.RawExtension)
+	if in.PreDestroy != nil {
+		in, out := &in.PreDestroy, &out.PreDestroy
+		*out = new(ResourceHook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PostDestroy != nil {
+		in, out := &in.PostDestroy, &out.PostDestroy
+		*out = new(ResourceHook)
 		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupElement.
-func (in *ResourceGroupElement) DeepCopy() *ResourceGroupElement {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceHooks.
+func (in *ResourceHooks) DeepCopy() *ResourceHooks {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceGroupElement)
+	out := new(ResourceHooks)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceGroupList) DeepCopyInto(out *ResourceGroupList) {
+func (in *ResourceList) DeepCopyInto(out *ResourceList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]ResourceGroup, len(*in))
+		*out = make([]Resource, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupList.
-func (in *ResourceGroupList) DeepCopy() *ResourceGroupList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceList.
+func (in *ResourceList) DeepCopy() *ResourceList {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceGroupList)
+	out := new(ResourceList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ResourceGroupList) DeepCopyObject() runtime.Object {
+func (in *ResourceList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -294,113 +1565,121 @@ func (in *ResourceGroupList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceGroupRef) DeepCopyInto(out *ResourceGroupRef) {
+func (in *ResourceOutputsTarget) DeepCopyInto(out *ResourceOutputsTarget) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupRef.
-func (in *ResourceGroupRef) DeepCopy() *ResourceGroupRef {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceOutputsTarget.
+func (in *ResourceOutputsTarget) DeepCopy() *ResourceOutputsTarget {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceGroupRef)
+	out := new(ResourceOutputsTarget)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceGroupSpec) DeepCopyInto(out *ResourceGroupSpec) {
+func (in *ResourcePool) DeepCopyInto(out *ResourcePool) {
 	*out = *in
-	if in.Parameters != nil {
-		in, out := &in.Parameters, &out.Parameters
-		*out = new(runtime.RawExtension)
-		(*in).DeepCopyInto(*out)
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourcePool.
+func (in *ResourcePool) DeepCopy() *ResourcePool {
+	if in == nil {
+		return nil
 	}
-	if in.Refs != nil {
-		in, out := &in.Refs, &out.Refs
-		*out = make([]ResourceGroupRef, len(*in))
-		copy(*out, *in)
+	out := new(ResourcePool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResourcePool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
 	}
-	if in.Resources != nil {
-		in, out := &in.Resources, &out.Resources
-		*out = make([]ResourceGroupElement, len(*in))
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourcePoolList) DeepCopyInto(out *ResourcePoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ResourcePool, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupSpec.
-func (in *ResourceGroupSpec) DeepCopy() *ResourceGroupSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourcePoolList.
+func (in *ResourcePoolList) DeepCopy() *ResourcePoolList {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceGroupSpec)
+	out := new(ResourcePoolList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ResourcePoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceGroupStatus) DeepCopyInto(out *ResourceGroupStatus) {
+func (in *ResourcePoolSpec) DeepCopyInto(out *ResourcePoolSpec) {
 	*out = *in
-	if in.Deployments != nil {
-		in, out := &in.Deployments, &out.Deployments
-		*out = make(ResourceGroupDeploymentStatuses, len(*in))
-		for key, val := range *in {
-			(*out)[key] = *val.DeepCopy()
-		}
-	}
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Properties != nil {
+		in, out := &in.Properties, &out.Properties
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceGroupStatus.
-func (in *ResourceGroupStatus) DeepCopy() *ResourceGroupStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourcePoolSpec.
+func (in *ResourcePoolSpec) DeepCopy() *ResourcePoolSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceGroupStatus)
+	out := new(ResourcePoolSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ResourceList) DeepCopyInto(out *ResourceList) {
+func (in *ResourcePoolStatus) DeepCopyInto(out *ResourcePoolStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]Resource, len(*in))
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceList.
-func (in *ResourceList) DeepCopy() *ResourceList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourcePoolStatus.
+func (in *ResourcePoolStatus) DeepCopy() *ResourcePoolStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ResourceList)
+	out := new(ResourcePoolStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ResourceList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceRef) DeepCopyInto(out *ResourceRef) {
 	*out = *in
@@ -460,6 +1739,48 @@ func (in *ResourceRefList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceRefOutputStore) DeepCopyInto(out *ResourceRefOutputStore) {
+	*out = *in
+	if in.Properties != nil {
+		in, out := &in.Properties, &out.Properties
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRefOutputStore.
+func (in *ResourceRefOutputStore) DeepCopy() *ResourceRefOutputStore {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceRefOutputStore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceRefPermissions) DeepCopyInto(out *ResourceRefPermissions) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]rbacv1.PolicyRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRefPermissions.
+func (in *ResourceRefPermissions) DeepCopy() *ResourceRefPermissions {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceRefPermissions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceRefProvisioner) DeepCopyInto(out *ResourceRefProvisioner) {
 	*out = *in
@@ -468,6 +1789,13 @@ func (in *ResourceRefProvisioner) DeepCopyInto(out *ResourceRefProvisioner) {
 		*out = new(runtime.RawExtension)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Overrides != nil {
+		in, out := &in.Overrides, &out.Overrides
+		*out = make([]ResourceRefProvisionerOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRefProvisioner.
@@ -480,9 +1808,39 @@ func (in *ResourceRefProvisioner) DeepCopy() *ResourceRefProvisioner {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceRefProvisionerOverride) DeepCopyInto(out *ResourceRefProvisionerOverride) {
+	*out = *in
+	if in.Spec != nil {
+		in, out := &in.Spec, &out.Spec
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRefProvisionerOverride.
+func (in *ResourceRefProvisionerOverride) DeepCopy() *ResourceRefProvisionerOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceRefProvisionerOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceRefSchema) DeepCopyInto(out *ResourceRefSchema) {
 	*out = *in
+	if in.Required != nil {
+		in, out := &in.Required, &out.Required
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Default != nil {
+		in, out := &in.Default, &out.Default
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Properties != nil {
 		in, out := &in.Properties, &out.Properties
 		*out = make(map[string]ResourceRefSchema, len(*in))
@@ -507,6 +1865,31 @@ func (in *ResourceRefSpec) DeepCopyInto(out *ResourceRefSpec) {
 	*out = *in
 	in.Provisioner.DeepCopyInto(&out.Provisioner)
 	in.Schema.DeepCopyInto(&out.Schema)
+	if in.Permissions != nil {
+		in, out := &in.Permissions, &out.Permissions
+		*out = new(ResourceRefPermissions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OutputStore != nil {
+		in, out := &in.OutputStore, &out.OutputStore
+		*out = new(ResourceRefOutputStore)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SensitiveOutputs != nil {
+		in, out := &in.SensitiveOutputs, &out.SensitiveOutputs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PlacementSelector != nil {
+		in, out := &in.PlacementSelector, &out.PlacementSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Requires != nil {
+		in, out := &in.Requires, &out.Requires
+		*out = make([]Capability, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRefSpec.
@@ -539,6 +1922,21 @@ func (in *ResourceRefStatus) DeepCopy() *ResourceRefStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceSecretProperties) DeepCopyInto(out *ResourceSecretProperties) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceSecretProperties.
+func (in *ResourceSecretProperties) DeepCopy() *ResourceSecretProperties {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceSecretProperties)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceSpec) DeepCopyInto(out *ResourceSpec) {
 	*out = *in
@@ -547,6 +1945,26 @@ func (in *ResourceSpec) DeepCopyInto(out *ResourceSpec) {
 		*out = new(runtime.RawExtension)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.WriteOutputsTo != nil {
+		in, out := &in.WriteOutputsTo, &out.WriteOutputsTo
+		*out = new(ResourceOutputsTarget)
+		**out = **in
+	}
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(ResourceVerification)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hooks != nil {
+		in, out := &in.Hooks, &out.Hooks
+		*out = new(ResourceHooks)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretProperties != nil {
+		in, out := &in.SecretProperties, &out.SecretProperties
+		*out = new(ResourceSecretProperties)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceSpec.
@@ -568,6 +1986,26 @@ func (in *ResourceStatus) DeepCopyInto(out *ResourceStatus) {
 		*out = new(runtime.RawExtension)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Inventory != nil {
+		in, out := &in.Inventory, &out.Inventory
+		*out = make([]ResourceStatusInventoryEntry, len(*in))
+		copy(*out, *in)
+	}
+	if in.OutputsRefreshedAt != nil {
+		in, out := &in.OutputsRefreshedAt, &out.OutputsRefreshedAt
+		*out = new(v1.Time)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RefreshRequestedAt != nil {
+		in, out := &in.RefreshRequestedAt, &out.RefreshRequestedAt
+		*out = new(v1.Time)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConsumedSecrets != nil {
+		in, out := &in.ConsumedSecrets, &out.ConsumedSecrets
+		*out = make([]ResourceStatusConsumedSecret, len(*in))
+		copy(*out, *in)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]v1.Condition, len(*in))
@@ -587,6 +2025,36 @@ func (in *ResourceStatus) DeepCopy() *ResourceStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceStatusConsumedSecret) DeepCopyInto(out *ResourceStatusConsumedSecret) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceStatusConsumedSecret.
+func (in *ResourceStatusConsumedSecret) DeepCopy() *ResourceStatusConsumedSecret {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceStatusConsumedSecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceStatusInventoryEntry) DeepCopyInto(out *ResourceStatusInventoryEntry) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceStatusInventoryEntry.
+func (in *ResourceStatusInventoryEntry) DeepCopy() *ResourceStatusInventoryEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceStatusInventoryEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ResourceStatusProvisioner) DeepCopyInto(out *ResourceStatusProvisioner) {
 	*out = *in
@@ -617,3 +2085,45 @@ func (in *ResourceStatusProvisionerResource) DeepCopy() *ResourceStatusProvision
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceVerification) DeepCopyInto(out *ResourceVerification) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.BackoffLimit != nil {
+		in, out := &in.BackoffLimit, &out.BackoffLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceVerification.
+func (in *ResourceVerification) DeepCopy() *ResourceVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceVerification)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,636 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/bundle"
+	"github.com/nubank/klaudio/internal/changeset"
+	"github.com/nubank/klaudio/internal/expression"
+	"github.com/nubank/klaudio/internal/outputs"
+	"github.com/nubank/klaudio/internal/refs"
+	"github.com/nubank/klaudio/internal/rendertest"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(resourcesv1alpha1.AddToScheme(scheme))
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <command> [flags]\n\ncommands:\n  outputs\tsearch outputs from Resources across ResourceGroups and placements\n  test\t\trun ResourceGroupTests from local files, without deploying anything\n  placement\tpause, resume or reconcile every ResourceGroupDeployment to a placement\n  diff\t\tshow what deploying a ResourceGroup would change, without applying anything\n  lint\t\tcheck the paths read by the expressions of ResourceGroups from local files\n  bundle\texport a ResourceGroup with its ResourceRefs and ConfigMap refs, or import it into another cluster\n", os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "outputs":
+		if err := runOutputs(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "test":
+		if err := runTest(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "placement":
+		if err := runPlacement(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "diff":
+		if err := runDiff(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "lint":
+		if err := runLint(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "bundle":
+		if err := runBundle(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func runOutputs(args []string) error {
+	var selector string
+	var placement string
+	var names string
+	var format string
+	var outputStore string
+
+	flags := flag.NewFlagSet("outputs", flag.ExitOnError)
+	flags.StringVar(&selector, "selector", "", "Label selector to filter ResourceGroups (e.g. team=payments).")
+	flags.StringVar(&placement, "placement", "", "Only show outputs from Resources deployed to this placement.")
+	flags.StringVar(&names, "name", "", "Comma-separated list of output names to show; all outputs are shown by default.")
+	flags.StringVar(&format, "o", "table", "Output format: table or json.")
+	flags.StringVar(&outputStore, "output-store", outputs.StatusStoreName, "Default output store configured in the operator.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := outputs.SetDefaultStore(outputStore); err != nil {
+		return err
+	}
+
+	labelSelector, err := labels.Parse(selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector %s: %w", selector, err)
+	}
+
+	query := outputs.Query{
+		Selector:  labelSelector,
+		Placement: placement,
+	}
+	if names != "" {
+		query.Names = strings.Split(names, ",")
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	entries, err := outputs.NewIndex(c).Search(context.Background(), query)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+
+	case "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RESOURCEGROUP\tPLACEMENT\tRESOURCE\tOUTPUT\tVALUE")
+		for _, entry := range entries {
+			keys := make([]string, 0, len(entry.Outputs))
+			for key := range entry.Outputs {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\n", entry.ResourceGroup, entry.Placement, entry.Resource, key, entry.Outputs[key])
+			}
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+func runTest(args []string) error {
+	files := make([]string, 0)
+
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	flags.Func("f", "File with ResourceGroup and ResourceGroupTest manifests; may be repeated.", func(file string) error {
+		files = append(files, file)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return errors.New("at least one file is required (-f)")
+	}
+
+	resourceGroups := make(map[string]*resourcesv1alpha1.ResourceGroup)
+	resourceGroupTests := make([]*resourcesv1alpha1.ResourceGroupTest, 0)
+
+	for _, file := range files {
+		objects, err := readManifests(file)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", file, err)
+		}
+
+		for _, object := range objects {
+			switch o := object.(type) {
+			case *resourcesv1alpha1.ResourceGroup:
+				resourceGroups[o.Name] = o
+			case *resourcesv1alpha1.ResourceGroupTest:
+				resourceGroupTests = append(resourceGroupTests, o)
+			}
+		}
+	}
+
+	if len(resourceGroupTests) == 0 {
+		return errors.New("no ResourceGroupTest was found")
+	}
+
+	failed := 0
+	for _, resourceGroupTest := range resourceGroupTests {
+		resourceGroup, ok := resourceGroups[resourceGroupTest.Spec.ResourceGroup]
+		if !ok {
+			return fmt.Errorf("ResourceGroup %s, tested by %s, was not found", resourceGroupTest.Spec.ResourceGroup, resourceGroupTest.Name)
+		}
+
+		for _, result := range rendertest.Run(&resourceGroup.Spec, resourceGroupTest.Spec.Cases) {
+			if result.Passed {
+				fmt.Printf("PASS\t%s/%s\n", resourceGroupTest.Name, result.Name)
+				continue
+			}
+
+			failed++
+			fmt.Printf("FAIL\t%s/%s\n", resourceGroupTest.Name, result.Name)
+			for _, failure := range result.Failures {
+				fmt.Printf("\t%s\n", failure)
+			}
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d test cases failed", failed)
+	}
+
+	return nil
+}
+
+// runLint checks the expressions of ResourceGroups the same way the admission webhook does; ResourceRefs in the files
+// type the properties of the resources referencing them
+func runLint(args []string) error {
+	files := make([]string, 0)
+
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	flags.Func("f", "File with ResourceGroup and ResourceRef manifests; may be repeated.", func(file string) error {
+		files = append(files, file)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return errors.New("at least one file is required (-f)")
+	}
+
+	resourceGroups := make([]*resourcesv1alpha1.ResourceGroup, 0)
+	resourceRefs := make(map[string]*resourcesv1alpha1.ResourceRef)
+
+	for _, file := range files {
+		objects, err := readManifests(file)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", file, err)
+		}
+
+		for _, object := range objects {
+			switch o := object.(type) {
+			case *resourcesv1alpha1.ResourceGroup:
+				resourceGroups = append(resourceGroups, o)
+			case *resourcesv1alpha1.ResourceRef:
+				resourceRefs[o.Name] = o
+			}
+		}
+	}
+
+	if len(resourceGroups) == 0 {
+		return errors.New("no ResourceGroup was found")
+	}
+
+	failed := 0
+	for _, resourceGroup := range resourceGroups {
+		parameters := make(map[string]any)
+		if resourceGroup.Spec.Parameters != nil && len(resourceGroup.Spec.Parameters.Raw) != 0 {
+			if err := json.Unmarshal(resourceGroup.Spec.Parameters.Raw, &parameters); err != nil {
+				return fmt.Errorf("unable to read the parameters of ResourceGroup %s: %w", resourceGroup.Name, err)
+			}
+		}
+		args := resources.NewResourcePropertiesArgs(parameters, refs.NewReferences())
+
+		group := resources.NewResourceGroup().WithExpressionLanguage(expression.Language(resourceGroup.Spec.ExpressionLanguage))
+		for _, element := range resourceGroup.Spec.Resources {
+			// items only known to each deployment are linted as a whole
+			stamped, err := group.NewResources(element, args)
+			if err != nil {
+				resource, err := group.NewResource(element.Name, element.Properties)
+				if err != nil {
+					return fmt.Errorf("unable to read resource %s from ResourceGroup %s: %w", element.Name, resourceGroup.Name, err)
+				}
+				stamped = []*resources.Resource{resource}
+			}
+
+			for _, resource := range stamped {
+				resource.Ref = resourceRefs[element.ResourceRef]
+				resource.ExportedOutputs = element.ExportedOutputs
+			}
+		}
+
+		for _, err := range group.Lint() {
+			failed++
+			fmt.Printf("%s: %s\n", resourceGroup.Name, err)
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d expressions read paths that don't exist", failed)
+	}
+
+	return nil
+}
+
+// runBundle promotes a ResourceGroup between management clusters: export writes it, with everything it reads, to a
+// single file, and import applies that file to the current cluster
+func runBundle(args []string) error {
+	var file string
+
+	flags := flag.NewFlagSet("bundle", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s bundle export <resourcegroup> [-f file]\n       %s bundle import -f file\n", os.Args[0], os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&file, "f", "", "File the bundle is written to, or read from; export writes to stdout by default.")
+
+	if len(args) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	verb := args[0]
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	switch verb {
+	case "export":
+		if flags.NArg() != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		b, err := bundle.Export(ctx, c, flags.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		if file == "" {
+			return b.Write(os.Stdout)
+		}
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return b.Write(f)
+
+	case "import":
+		if file == "" || flags.NArg() != 0 {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		b, err := bundle.Read(f)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", file, err)
+		}
+
+		changes, err := b.Import(ctx, c)
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+		return err
+
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+// runPlacement changes a single Placement; the operator fans the change out to every ResourceGroupDeployment
+// targeting it
+func runPlacement(args []string) error {
+	flags := flag.NewFlagSet("placement", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s placement <pause|resume|reconcile> <name>\n", os.Args[0])
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	verb, name := flags.Arg(0), flags.Arg(1)
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	placement := &resourcesv1alpha1.Placement{}
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, placement); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(placement.DeepCopy())
+	switch verb {
+	case "pause":
+		placement.Spec.Suspend = true
+	case "resume":
+		placement.Spec.Suspend = false
+	case "reconcile":
+		if placement.Annotations == nil {
+			placement.Annotations = make(map[string]string)
+		}
+		placement.Annotations[resourcesv1alpha1.PlacementReconcileRequestedAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if err := c.Patch(ctx, placement, patch); err != nil {
+		return err
+	}
+
+	deployments := &resourcesv1alpha1.ResourceGroupDeploymentList{}
+	if err := c.List(ctx, deployments, client.MatchingLabels{resourcesv1alpha1.Group + "/placement": name}); err != nil {
+		return err
+	}
+
+	fmt.Printf("placement %s: %s requested to %d ResourceGroupDeployments\n", name, verb, len(deployments.Items))
+	return nil
+}
+
+// runDiff renders a ResourceGroup against the live state of each of its deployments, like a plan of the whole group;
+// the group is read from the cluster, unless a local file declaring it is given
+func runDiff(args []string) error {
+	var file string
+	var placement string
+	var format string
+
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s diff <resourcegroup> [flags]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&file, "f", "", "File with the ResourceGroup manifest to compare; the live ResourceGroup is used by default.")
+	flags.StringVar(&placement, "placement", "", "Only compare the deployment to this placement.")
+	flags.StringVar(&format, "o", "text", "Output format: text or json.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	name := flags.Arg(0)
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	resourceGroup := &resourcesv1alpha1.ResourceGroup{}
+	if file == "" {
+		if err := c.Get(ctx, client.ObjectKey{Name: name}, resourceGroup); err != nil {
+			return err
+		}
+	} else {
+		objects, err := readManifests(file)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", file, err)
+		}
+
+		resourceGroup = nil
+		for _, object := range objects {
+			if o, ok := object.(*resourcesv1alpha1.ResourceGroup); ok && o.Name == name {
+				resourceGroup = o
+			}
+		}
+		if resourceGroup == nil {
+			return fmt.Errorf("ResourceGroup %s was not found in %s", name, file)
+		}
+	}
+
+	matchingLabels := client.MatchingLabels{resourcesv1alpha1.Group + "/managedBy.name": name}
+	if placement != "" {
+		matchingLabels[resourcesv1alpha1.Group+"/placement"] = placement
+	}
+
+	// deployments live in the group namespace, or in a namespace to each placement
+	deployments := &resourcesv1alpha1.ResourceGroupDeploymentList{}
+	if err := c.List(ctx, deployments, matchingLabels); err != nil {
+		return err
+	}
+	if len(deployments.Items) == 0 {
+		return fmt.Errorf("there are no deployments of ResourceGroup %s", name)
+	}
+
+	sort.Slice(deployments.Items, func(i, j int) bool { return deployments.Items[i].Name < deployments.Items[j].Name })
+
+	diffs := make(map[string][]resourcesv1alpha1.ResourceGroupDeploymentPlannedResource)
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+
+		changes, err := changeset.OfDeployment(ctx, c, &resourceGroup.Spec, deployment)
+		if err != nil {
+			return fmt.Errorf("unable to compare deployment %s: %w", deployment.Name, err)
+		}
+		diffs[deployment.Spec.Placement] = changes
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diffs)
+
+	case "text":
+		symbols := map[resourcesv1alpha1.PlanAction]string{
+			resourcesv1alpha1.PlanActionCreate:  "+",
+			resourcesv1alpha1.PlanActionUpdate:  "~",
+			resourcesv1alpha1.PlanActionDelete:  "-",
+			resourcesv1alpha1.PlanActionUnknown: "?",
+		}
+
+		counts := make(map[resourcesv1alpha1.PlanAction]int)
+		for _, deployment := range deployments.Items {
+			fmt.Printf("placement %s:\n", deployment.Spec.Placement)
+
+			for _, change := range diffs[deployment.Spec.Placement] {
+				counts[change.Action]++
+				if change.Action == resourcesv1alpha1.PlanActionNoChange {
+					continue
+				}
+
+				fmt.Printf("  %s %s (%s)\n", symbols[change.Action], change.Name, change.Action)
+				for _, line := range change.Diff {
+					fmt.Printf("      %s\n", line)
+				}
+				if change.Message != "" {
+					fmt.Printf("      %s\n", change.Message)
+				}
+			}
+		}
+
+		fmt.Printf("\n%d to create, %d to update, %d to delete, %d unknown until applied\n",
+			counts[resourcesv1alpha1.PlanActionCreate], counts[resourcesv1alpha1.PlanActionUpdate], counts[resourcesv1alpha1.PlanActionDelete], counts[resourcesv1alpha1.PlanActionUnknown])
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// readManifests decodes the ResourceGroups, ResourceGroupTests and ResourceRefs from a YAML (multi-document) or JSON file; other
+// kinds are ignored
+func readManifests(file string) ([]runtime.Object, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	objects := make([]runtime.Object, 0)
+
+	decoder := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		raw := runtime.RawExtension{}
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, err
+		}
+
+		if len(raw.Raw) == 0 {
+			continue
+		}
+
+		typeMeta := struct {
+			Kind string `json:"kind"`
+		}{}
+		if err := json.Unmarshal(raw.Raw, &typeMeta); err != nil {
+			return nil, err
+		}
+
+		var object runtime.Object
+		switch typeMeta.Kind {
+		case "ResourceGroup":
+			object = &resourcesv1alpha1.ResourceGroup{}
+		case "ResourceGroupTest":
+			object = &resourcesv1alpha1.ResourceGroupTest{}
+		case "ResourceRef":
+			object = &resourcesv1alpha1.ResourceRef{}
+		default:
+			continue
+		}
+
+		if err := json.Unmarshal(raw.Raw, object); err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+}
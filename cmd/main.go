@@ -17,19 +17,28 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	"k8s.io/client-go/dynamic"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -37,7 +46,17 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/artifacts"
+	"github.com/nubank/klaudio/internal/benchmark"
+	"github.com/nubank/klaudio/internal/clusters"
 	"github.com/nubank/klaudio/internal/controller"
+	"github.com/nubank/klaudio/internal/eventstream"
+	"github.com/nubank/klaudio/internal/expression"
+	"github.com/nubank/klaudio/internal/ipam"
+	"github.com/nubank/klaudio/internal/outputs"
+	"github.com/nubank/klaudio/internal/refs"
+	"github.com/nubank/klaudio/internal/renderapi"
+	webhookresourcesv1alpha1 "github.com/nubank/klaudio/internal/webhook/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -59,6 +78,22 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var shardName string
+	var shardSelector string
+	var enableGroupControllers bool
+	var outputStore string
+	var outputsNamespaces string
+	var externalRefAddresses string
+	var cidrAllocationsNamespace string
+	var provisionerRetryBudget int
+	var outputsStalenessThreshold time.Duration
+	var eventStreamAddr string
+	var statusExporter string
+	var renderAddr string
+	var missingResourceRefPolicy string
+	var expressionLanguage string
+	var expressionLimits expression.Limits
+	var benchmarkSpec string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -69,6 +104,61 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&shardName, "shard-name", "",
+		"Name of the shard handled by this manager; each shard gets its own leader election.")
+	flag.StringVar(&shardSelector, "shard-selector", "",
+		"Label selector restricting the Resources and ResourceGroupDeployments handled by this manager, "+
+			"e.g. 'resources.klaudio.nubank.io/placement in (us-east-1,us-west-2)'. Empty means everything.")
+	flag.BoolVar(&enableGroupControllers, "enable-group-controllers", true,
+		"If set, ResourceGroup, ResourceRef and Namespace controllers run in this manager. "+
+			"When sharding, enable them in a single manager only; the other managers only cache their own shard.")
+	flag.IntVar(&provisionerRetryBudget, "provisioner-retry-budget", int(controller.DefaultProvisionerRetryBudget),
+		"How many consecutive transient errors from a provisioner are retried, with exponential backoff, "+
+			"before the Resource fails. Terminal errors are never retried.")
+	flag.StringVar(&outputStore, "output-store", outputs.StatusStoreName,
+		"Store used to persist the outputs of Resources whose ResourceRef doesn't declare one: status, secret, "+
+			"configmap, or any store registered with outputs.RegisterStore.")
+	flag.StringVar(&outputsNamespaces, "write-outputs-namespaces", "",
+		"Comma-separated namespaces, besides its own, a Resource may write its outputs into with "+
+			"spec.writeOutputsTo. By default, outputs are only written into the namespace of the Resource.")
+	flag.StringVar(&externalRefAddresses, "external-ref-addresses", "",
+		"Comma-separated addresses External refs may read from, like https://vault.example.com. The default "+
+			"endpoints of AWS are always allowed.")
+	flag.StringVar(&cidrAllocationsNamespace, "cidr-allocations-namespace", ipam.DefaultNamespace,
+		"Namespace of the ConfigMaps recording the subnets allocated by cidralloc to each ResourceGroup; "+
+			"usually the namespace of the operator.")
+	flag.DurationVar(&outputsStalenessThreshold, "outputs-staleness-threshold", 0,
+		"Maximum age of the outputs used to render dependent Resources; older outputs are refreshed before "+
+			"their dependents are rendered again. Zero disables the check.")
+	flag.StringVar(&eventStreamAddr, "event-stream-bind-address", "0",
+		"The address the event stream of ResourceGroups binds to, e.g. :8090; leave as 0 to disable it. "+
+			"The stream is served over TLS to bearer tokens allowed to get /resourcegroups/<name>/events as a "+
+			"non-resource URL, like the event-stream-reader ClusterRole.")
+	flag.StringVar(&statusExporter, "status-exporter", "",
+		"Sink the phase transitions of ResourceGroups, ResourceGroupDeployments and Resources are exported to: "+
+			"https://<webhook>, sqs://sqs.<region>.amazonaws.com/<account>/<queue> or kafka://<rest proxy>/<topic>. "+
+			"Leave empty to disable it.")
+	flag.StringVar(&renderAddr, "render-bind-address", "0",
+		"The address the render API of ResourceGroupDeployments binds to, e.g. :8091; leave as 0 to disable it. "+
+			"The API is served over TLS to bearer tokens allowed to post to "+
+			"/namespaces/<namespace>/resourcegroupdeployments/<name>/render as a non-resource URL, like the "+
+			"render-api-user ClusterRole.")
+	flag.StringVar(&missingResourceRefPolicy, "missing-resourceref-policy", string(webhookresourcesv1alpha1.MissingResourceRefReject),
+		"What the ResourceGroup webhook does with resources referencing ResourceRefs that don't exist yet: reject, "+
+			"or warn to accept ResourceGroups applied together with their ResourceRefs, in any order.")
+	flag.StringVar(&expressionLanguage, "expression-language", string(expression.LanguageExpr),
+		"Language of the expressions of ResourceGroups that don't declare spec.expressionLanguage: expr or cel.")
+	flag.Uint64Var(&expressionLimits.Cost, "expression-cost-limit", expression.DefaultLimits.Cost,
+		"Runtime cost a CEL expression may spend before its evaluation fails. Zero means unlimited.")
+	flag.IntVar(&expressionLimits.Depth, "expression-max-depth", expression.DefaultLimits.Depth,
+		"How deeply an expression may nest before it's refused. Zero means unlimited.")
+	flag.IntVar(&expressionLimits.Nodes, "expression-max-nodes", expression.DefaultLimits.Nodes,
+		"How many nodes the syntax tree of an Expr expression may have before it's refused. Zero means unlimited.")
+	flag.DurationVar(&expressionLimits.Timeout, "expression-timeout", expression.DefaultLimits.Timeout,
+		"How long the evaluation of an expression may take before it fails. Zero means unlimited.")
+	// internal, so it's left out of the usage; see runBenchmark
+	flag.StringVar(&benchmarkSpec, benchmarkFlag, "", "")
+	flag.Usage = usageWithout(benchmarkFlag)
 	opts := zap.Options{
 		Development: true,
 	}
@@ -77,6 +167,14 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if benchmarkSpec != "" {
+		if err := runBenchmark(ctrl.SetupSignalHandler(), benchmarkSpec); err != nil {
+			log.Error(err, "benchmark failed")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -93,12 +191,62 @@ func main() {
 		tlsOpts = append(tlsOpts, disableHTTP2)
 	}
 
+	var shardLabelSelector labels.Selector
+	if shardSelector != "" {
+		selector, err := labels.Parse(shardSelector)
+		if err != nil {
+			log.Error(err, "invalid shard selector", "selector", shardSelector)
+			os.Exit(1)
+		}
+		shardLabelSelector = selector
+	}
+
+	ipam.SetNamespace(cidrAllocationsNamespace)
+
+	if outputsNamespaces != "" {
+		outputs.SetExportNamespaces(strings.Split(outputsNamespaces, ","))
+	}
+
+	if externalRefAddresses != "" {
+		if err := refs.SetExternalAddresses(strings.Split(externalRefAddresses, ",")); err != nil {
+			log.Error(err, "invalid external ref addresses", "externalRefAddresses", externalRefAddresses)
+			os.Exit(1)
+		}
+	}
+
+	if err := outputs.SetDefaultStore(outputStore); err != nil {
+		log.Error(err, "invalid output store", "outputStore", outputStore)
+		os.Exit(1)
+	}
+
+	if err := expression.SetDefaultLanguage(expression.Language(expressionLanguage)); err != nil {
+		log.Error(err, "invalid expression language", "expressionLanguage", expressionLanguage)
+		os.Exit(1)
+	}
+	expression.SetLimits(expressionLimits)
+
+	leaderElectionID := "2674ee39.klaudio.nubank.io"
+	if shardName != "" {
+		leaderElectionID = fmt.Sprintf("%s.%s", shardName, leaderElectionID)
+	}
+
 	webhookServer := webhook.NewServer(webhook.Options{
 		TLSOpts: tlsOpts,
 	})
 
+	// the group controllers read the Resources and ResourceGroupDeployments of every shard
+	var cacheOptions cache.Options
+	if enableGroupControllers {
+		if shardLabelSelector != nil {
+			log.Info("group controllers are enabled, so the cache holds every shard; only the events of this one are handled")
+		}
+	} else {
+		cacheOptions.ByObject = controller.ShardCache(shardLabelSelector)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
+		Cache:  cacheOptions,
 		// Metrics endpoint is enabled in 'config/default/kustomization.yaml'. The Metrics options configure the server.
 		// More info:
 		// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.18.4/pkg/metrics/server
@@ -122,7 +270,7 @@ func main() {
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "2674ee39.klaudio.nubank.io",
+		LeaderElectionID:       leaderElectionID,
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -140,28 +288,74 @@ func main() {
 		os.Exit(1)
 	}
 
-	resourceRefReconciler := &controller.ResourceRefReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("resource-ref-controller"),
-	}
-	if err = resourceRefReconciler.SetupWithManager(mgr); err != nil {
-		log.Error(err, "unable to create controller", "controller", "ResourceRef")
+	resourceRefs, err := controller.NewResourceRefs(context.Background(), mgr)
+	if err != nil {
+		log.Error(err, "unable to set up the ResourceRefs cache")
 		os.Exit(1)
 	}
 
-	resourceGroupReconciler := &controller.ResourceGroupReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}
-	if err = resourceGroupReconciler.SetupWithManager(mgr); err != nil {
-		log.Error(err, "unable to create controller", "controller", "ResourceGroup")
-		os.Exit(1)
+	if enableGroupControllers {
+		resourceRefReconciler := &controller.ResourceRefReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("resource-ref-controller"),
+		}
+		if err = resourceRefReconciler.SetupWithManager(mgr); err != nil {
+			log.Error(err, "unable to create controller", "controller", "ResourceRef")
+			os.Exit(1)
+		}
+
+		resourceGroupReconciler := &controller.ResourceGroupReconciler{
+			Client:       mgr.GetClient(),
+			Scheme:       mgr.GetScheme(),
+			APIServerURL: mgr.GetConfig().Host,
+			ResourceRefs: resourceRefs,
+			Artifacts:    artifacts.NewLoader(mgr.GetClient()),
+			APIReader:    mgr.GetAPIReader(),
+		}
+		if err = resourceGroupReconciler.SetupWithManager(mgr); err != nil {
+			log.Error(err, "unable to create controller", "controller", "ResourceGroup")
+			os.Exit(1)
+		}
+
+		resourceGroupTestReconciler := &controller.ResourceGroupTestReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}
+		if err = resourceGroupTestReconciler.SetupWithManager(mgr); err != nil {
+			log.Error(err, "unable to create controller", "controller", "ResourceGroupTest")
+			os.Exit(1)
+		}
+
+		namespaceReconciler := &controller.NamespaceReconciler{
+			Client:       mgr.GetClient(),
+			Scheme:       mgr.GetScheme(),
+			ResourceRefs: resourceRefs,
+		}
+		if err = namespaceReconciler.SetupWithManager(mgr); err != nil {
+			log.Error(err, "unable to create controller", "controller", "Namespace")
+			os.Exit(1)
+		}
+
+		// the fleet is summarized once, by the manager running the group controllers
+		klaudioFleetStatusReconciler := &controller.KlaudioFleetStatusReconciler{
+			Client: mgr.GetClient(),
+			Scheme: mgr.GetScheme(),
+		}
+		if err = klaudioFleetStatusReconciler.SetupWithManager(mgr); err != nil {
+			log.Error(err, "unable to create controller", "controller", "KlaudioFleetStatus")
+			os.Exit(1)
+		}
 	}
 
 	resourceGroupDeploymentReconciler := &controller.ResourceGroupDeploymentReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		ShardSelector: shardLabelSelector,
+		Recorder:      mgr.GetEventRecorderFor("resource-group-deployment-controller"),
+
+		OutputsStalenessThreshold: outputsStalenessThreshold,
+		ResourceRefs:              resourceRefs,
 	}
 	if err = resourceGroupDeploymentReconciler.SetupWithManager(mgr); err != nil {
 		log.Error(err, "unable to create controller", "controller", "ResourceGroupDeployment")
@@ -170,26 +364,116 @@ func main() {
 
 	dynamiClient := dynamic.NewForConfigOrDie(mgr.GetConfig())
 
+	remoteClusters := clusters.NewFactory(mgr.GetClient(), mgr.GetScheme())
+	if err := mgr.Add(remoteClusters); err != nil {
+		log.Error(err, "unable to set up remote clusters")
+		os.Exit(1)
+	}
+
 	resourceReconciler := &controller.ResourceReconciler{
 		Client:        mgr.GetClient(),
 		DynamicClient: dynamiClient,
 		Scheme:        mgr.GetScheme(),
+		ShardSelector: shardLabelSelector,
+		RetryBudget:   int32(provisionerRetryBudget),
+		Clusters:      remoteClusters,
+		ResourceRefs:  resourceRefs,
 	}
 	if err = resourceReconciler.SetupWithManager(mgr); err != nil {
 		log.Error(err, "unable to create controller", "controller", "Resource")
 		os.Exit(1)
 	}
 
-	namespaceReconciler := &controller.NamespaceReconciler{
+	klaudioConfigReconciler := &controller.KlaudioConfigReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}
-	if err = namespaceReconciler.SetupWithManager(mgr); err != nil {
-		log.Error(err, "unable to create controller", "controller", "Namespace")
+	if err = klaudioConfigReconciler.SetupWithManager(mgr); err != nil {
+		log.Error(err, "unable to create controller", "controller", "KlaudioConfig")
 		os.Exit(1)
 	}
+	resourcePoolReconciler := &controller.ResourcePoolReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}
+	if err = resourcePoolReconciler.SetupWithManager(mgr); err != nil {
+		log.Error(err, "unable to create controller", "controller", "ResourcePool")
+		os.Exit(1)
+	}
+
+	provisionerPluginReconciler := &controller.ProvisionerPluginReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}
+	if err = provisionerPluginReconciler.SetupWithManager(mgr); err != nil {
+		log.Error(err, "unable to create controller", "controller", "ProvisionerPlugin")
+		os.Exit(1)
+	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		policy := webhookresourcesv1alpha1.MissingResourceRefPolicy(missingResourceRefPolicy)
+		if policy != webhookresourcesv1alpha1.MissingResourceRefReject && policy != webhookresourcesv1alpha1.MissingResourceRefWarn {
+			log.Error(fmt.Errorf("expected %s or %s", webhookresourcesv1alpha1.MissingResourceRefReject, webhookresourcesv1alpha1.MissingResourceRefWarn),
+				"invalid missing ResourceRef policy", "policy", missingResourceRefPolicy)
+			os.Exit(1)
+		}
+		if err = webhookresourcesv1alpha1.SetupResourceGroupWebhookWithManager(mgr, policy); err != nil {
+			log.Error(err, "unable to create webhook", "webhook", "ResourceGroup")
+			os.Exit(1)
+		}
+		if err = webhookresourcesv1alpha1.SetupResourceRefWebhookWithManager(mgr); err != nil {
+			log.Error(err, "unable to create webhook", "webhook", "ResourceRef")
+			os.Exit(1)
+		}
+		if err = webhookresourcesv1alpha1.SetupPlacementWebhookWithManager(mgr); err != nil {
+			log.Error(err, "unable to create webhook", "webhook", "Placement")
+			os.Exit(1)
+		}
+		if err = webhookresourcesv1alpha1.SetupResourceGroupDeploymentWebhookWithManager(mgr); err != nil {
+			log.Error(err, "unable to create webhook", "webhook", "ResourceGroupDeployment")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
+	if err := mgr.Add(&controller.SchemaMigration{Client: mgr.GetClient()}); err != nil {
+		log.Error(err, "unable to set up schema migration")
+		os.Exit(1)
+	}
+
+	// the APIs served by the manager authenticate and authorize their requests like the metrics endpoint
+	apiFilter, err := filters.WithAuthenticationAndAuthorization(mgr.GetConfig(), mgr.GetHTTPClient())
+	if err != nil {
+		log.Error(err, "unable to set up the authentication of the APIs")
+		os.Exit(1)
+	}
+
+	if eventStreamAddr != "0" {
+		if err := mgr.Add(&eventstream.Server{Addr: eventStreamAddr, Cache: mgr.GetCache(), Filter: apiFilter, TLSOpts: tlsOpts}); err != nil {
+			log.Error(err, "unable to set up the event stream")
+			os.Exit(1)
+		}
+	}
+
+	if statusExporter != "" {
+		sink, err := eventstream.NewSink(statusExporter)
+		if err != nil {
+			log.Error(err, "invalid status exporter", "statusExporter", statusExporter)
+			os.Exit(1)
+		}
+		if err := mgr.Add(&eventstream.Exporter{Cache: mgr.GetCache(), Sink: sink}); err != nil {
+			log.Error(err, "unable to set up the status exporter")
+			os.Exit(1)
+		}
+	}
+
+	if renderAddr != "0" {
+		if err := mgr.Add(&renderapi.Server{Addr: renderAddr, Client: mgr.GetClient(), Filter: apiFilter, TLSOpts: tlsOpts}); err != nil {
+			log.Error(err, "unable to set up the render API")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		log.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -205,3 +489,54 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// benchmarkFlag runs the manager in benchmark mode: instead of starting the controllers, it loads synthetic groups and
+// reports how fast their graphs are built, their resources are rendered and, with reconcile=true, deployed by the
+// controllers already running in the cluster. The value is a list of options, like
+// --benchmark=shape=layered,size=500,width=20,iterations=10,cpuprofile=/tmp/cpu.pprof
+const benchmarkFlag = "benchmark"
+
+// runBenchmark writes the report to the standard output as JSON, so runs can be compared over time
+func runBenchmark(ctx context.Context, spec string) error {
+	options, err := benchmark.ParseOptions(spec)
+	if err != nil {
+		return err
+	}
+
+	var c client.Client
+	if options.Reconcile {
+		c, err = client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+		if err != nil {
+			return fmt.Errorf("unable to create the client: %w", err)
+		}
+	}
+
+	log.Info("running benchmark", "shape", options.Shape, "size", options.Size, "iterations", options.Iterations, "reconcile", options.Reconcile)
+
+	report, err := benchmark.Run(ctx, c, options)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// usageWithout prints the usage of the command line flags, except the hidden ones
+func usageWithout(hidden ...string) func() {
+	return func() {
+		visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		visible.SetOutput(flag.CommandLine.Output())
+
+		flag.VisitAll(func(f *flag.Flag) {
+			if slices.Contains(hidden, f.Name) {
+				return
+			}
+			visible.Var(f.Value, f.Name, f.Usage)
+		})
+
+		fmt.Fprintf(visible.Output(), "Usage of %s:\n", os.Args[0])
+		visible.PrintDefaults()
+	}
+}
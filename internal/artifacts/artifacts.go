@@ -0,0 +1,262 @@
+// Package artifacts reads the resources of a ResourceGroup from the artifact of a Flux source
+package artifacts
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+const (
+	// MaxArtifactSize is the largest artifact downloaded, still compressed
+	MaxArtifactSize = 50 << 20
+	// MaxFileSize is the largest YAML file read from an artifact
+	MaxFileSize = 5 << 20
+	// MaxUnpackedSize bounds the bytes unpacked from an artifact, counting the files that aren't read too
+	MaxUnpackedSize = 100 << 20
+	// MaxEntries is the largest number of entries of an artifact
+	MaxEntries = 10000
+
+	// DefaultTimeout bounds the download of an artifact
+	DefaultTimeout = 30 * time.Second
+)
+
+// ErrNotReady is returned while the source has no artifact to read from
+var ErrNotReady = errors.New("the source has no artifact yet")
+
+// errUnpackedTooLarge keeps a small artifact from expanding into more than it's allowed to unpack
+var errUnpackedTooLarge = fmt.Errorf("the artifact unpacks to more than %d bytes", MaxUnpackedSize)
+
+// defaultAPIVersions of the kinds of source, when the sourceRef doesn't set one
+var defaultAPIVersions = map[string]string{
+	"GitRepository": "source.toolkit.fluxcd.io/v1",
+	"OCIRepository": "source.toolkit.fluxcd.io/v1beta2",
+	"Bucket":        "source.toolkit.fluxcd.io/v1beta2",
+}
+
+// Loaded are the resources read from a revision of an artifact
+type Loaded struct {
+	Revision  string
+	Resources []resourcesv1alpha1.ResourceGroupElement
+}
+
+// Loader downloads the artifacts of Flux sources, served by the source-controller, keeping the resources of the
+// last revision read to each source
+type Loader struct {
+	Client     client.Reader
+	HTTPClient *http.Client
+
+	mu     sync.Mutex
+	loaded map[types.NamespacedName]*Loaded
+}
+
+// NewLoader returns a Loader reading the sources with the client
+func NewLoader(c client.Reader) *Loader {
+	return &Loader{Client: c, HTTPClient: &http.Client{Timeout: DefaultTimeout}}
+}
+
+// Load returns the resources read from the current artifact of the source, downloading it only when its revision
+// changed since the last call
+func (l *Loader) Load(ctx context.Context, sourceRef *resourcesv1alpha1.ResourceGroupSourceRef) (*Loaded, error) {
+	gv, err := schema.ParseGroupVersion(sourceRef.APIVersion)
+	if sourceRef.APIVersion == "" {
+		gv, err = schema.ParseGroupVersion(defaultAPIVersions[sourceRef.Kind])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	source := &unstructured.Unstructured{}
+	source.SetGroupVersionKind(gv.WithKind(sourceRef.Kind))
+
+	key := types.NamespacedName{Namespace: sourceRef.Namespace, Name: sourceRef.Name}
+	if err := l.Client.Get(ctx, key, source); err != nil {
+		return nil, err
+	}
+
+	url, _, _ := unstructured.NestedString(source.Object, "status", "artifact", "url")
+	revision, _, _ := unstructured.NestedString(source.Object, "status", "artifact", "revision")
+	digest, _, _ := unstructured.NestedString(source.Object, "status", "artifact", "digest")
+	if url == "" {
+		return nil, fmt.Errorf("%w: %s %s", ErrNotReady, sourceRef.Kind, key)
+	}
+
+	// the path is part of what was read, so a new path reads the same revision again
+	revision = fmt.Sprintf("%s/%s", revision, strings.Trim(sourceRef.Path, "/"))
+
+	l.mu.Lock()
+	loaded, ok := l.loaded[key]
+	l.mu.Unlock()
+	if ok && loaded.Revision == revision {
+		return loaded, nil
+	}
+
+	artifact, err := l.download(ctx, url, digest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download the artifact of %s %s: %w", sourceRef.Kind, key, err)
+	}
+
+	resources, err := ReadResources(artifact, sourceRef.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the resources from the artifact of %s %s: %w", sourceRef.Kind, key, err)
+	}
+
+	loaded = &Loaded{Revision: revision, Resources: resources}
+
+	l.mu.Lock()
+	if l.loaded == nil {
+		l.loaded = make(map[types.NamespacedName]*Loaded)
+	}
+	l.loaded[key] = loaded
+	l.mu.Unlock()
+
+	return loaded, nil
+}
+
+// download reads the artifact, checking it against the digest published by the source, like sha256:<hex>
+func (l *Loader) download(ctx context.Context, url, digest string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := l.HTTPClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the source-controller responded %s", response.Status)
+	}
+
+	// one byte over the limit tells a large artifact apart from one of exactly the maximum size
+	artifact, err := io.ReadAll(io.LimitReader(response.Body, MaxArtifactSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(artifact) > MaxArtifactSize {
+		return nil, fmt.Errorf("the artifact is larger than %d bytes", MaxArtifactSize)
+	}
+
+	if algorithm, expected, ok := strings.Cut(digest, ":"); ok && algorithm == "sha256" {
+		sum := sha256.Sum256(artifact)
+		if actual := hex.EncodeToString(sum[:]); actual != expected {
+			return nil, fmt.Errorf("the digest of the artifact is sha256:%s, not %s", actual, digest)
+		}
+	}
+
+	return artifact, nil
+}
+
+// ReadResources reads the resources from the YAML files of a tar.gz artifact: the file at the path, or the .yaml and
+// .yml files inside the directory at the path, in lexical order
+func ReadResources(artifact []byte, dir string) ([]resourcesv1alpha1.ResourceGroupElement, error) {
+	dir = path.Clean("/" + dir)
+
+	compressed, err := gzip.NewReader(bytes.NewReader(artifact))
+	if err != nil {
+		return nil, err
+	}
+	defer compressed.Close()
+
+	files := make(map[string][]byte)
+
+	archive := tar.NewReader(&unpackedReader{reader: compressed, remaining: MaxUnpackedSize})
+	for entries := 1; ; entries++ {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entries > MaxEntries {
+			return nil, fmt.Errorf("the artifact has more than %d entries", MaxEntries)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean("/" + header.Name)
+		if name != dir && !strings.HasPrefix(name, strings.TrimSuffix(dir, "/")+"/") {
+			continue
+		}
+		if name != dir && path.Ext(name) != ".yaml" && path.Ext(name) != ".yml" {
+			continue
+		}
+		if header.Size > MaxFileSize {
+			return nil, fmt.Errorf("%s is larger than %d bytes", strings.TrimPrefix(name, "/"), MaxFileSize)
+		}
+
+		content, err := io.ReadAll(io.LimitReader(archive, MaxFileSize))
+		if err != nil {
+			return nil, err
+		}
+		files[name] = content
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("there are no YAML files at %s", dir)
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resources := make([]resourcesv1alpha1.ResourceGroupElement, 0)
+	for _, name := range names {
+		elements, err := Decode(files[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", strings.TrimPrefix(name, "/"), err)
+		}
+		resources = append(resources, elements...)
+	}
+
+	return resources, nil
+}
+
+// unpackedReader fails with errUnpackedTooLarge once more than remaining bytes are read, so entries skipped by the
+// tar reader are counted too
+type unpackedReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (u *unpackedReader) Read(p []byte) (int, error) {
+	if u.remaining <= 0 {
+		// one more byte tells an artifact of exactly the maximum size apart from a larger one
+		if n, err := u.reader.Read(make([]byte, 1)); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, errUnpackedTooLarge
+	}
+
+	if int64(len(p)) > u.remaining {
+		p = p[:u.remaining]
+	}
+	n, err := u.reader.Read(p)
+	u.remaining -= int64(n)
+	return n, err
+}
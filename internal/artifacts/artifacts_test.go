@@ -0,0 +1,228 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_Decode(t *testing.T) {
+
+	t.Run("We should expand anchors, aliases and merge keys before reading the resources", func(t *testing.T) {
+		content := `
+defaults: &defaults
+  region: us-east-1
+  tags: &tags
+    team: payments
+resources:
+- name: bucket
+  resourceRef: s3
+  properties:
+    <<: *defaults
+    name: checkout
+- name: queue
+  resourceRef: sqs
+  properties:
+    region: sa-east-1
+    tags: *tags
+`
+		resources, err := Decode([]byte(content))
+		assert.NoError(t, err)
+
+		if assert.Len(t, resources, 2) {
+			assert.Equal(t, "bucket", resources[0].Name)
+			assert.Equal(t, "s3", resources[0].ResourceRef)
+			assert.JSONEq(t, `{"region":"us-east-1","tags":{"team":"payments"},"name":"checkout"}`, string(resources[0].Properties.Raw))
+
+			assert.Equal(t, "queue", resources[1].Name)
+			assert.JSONEq(t, `{"region":"sa-east-1","tags":{"team":"payments"}}`, string(resources[1].Properties.Raw))
+		}
+	})
+
+	t.Run("We should read every document of a file", func(t *testing.T) {
+		content := `
+- name: bucket
+  resourceRef: s3
+  properties:
+    name: checkout
+---
+resources:
+- name: queue
+  resourceRef: sqs
+  properties:
+    name: checkout
+---
+`
+		resources, err := Decode([]byte(content))
+		assert.NoError(t, err)
+
+		if assert.Len(t, resources, 2) {
+			assert.Equal(t, "bucket", resources[0].Name)
+			assert.Equal(t, "queue", resources[1].Name)
+		}
+	})
+
+	t.Run("Unknown fields of the resources should be rejected", func(t *testing.T) {
+		_, err := Decode([]byte(`[{"name": "bucket", "resourceRef": "s3", "propertes": {}}]`))
+		assert.ErrorContains(t, err, "propertes")
+	})
+
+	t.Run("Resources without a resourceRef should be rejected", func(t *testing.T) {
+		_, err := Decode([]byte(`[{"name": "bucket"}]`))
+		assert.ErrorContains(t, err, "must have a name and a resourceRef")
+	})
+
+	t.Run("Documents of any other shape should be rejected", func(t *testing.T) {
+		_, err := Decode([]byte(`bucket`))
+		assert.ErrorContains(t, err, "document 0")
+	})
+}
+
+func Test_ReadResources(t *testing.T) {
+	artifact := newArtifact(t, map[string]string{
+		"README.md":                 "# resources",
+		"resources/b-queues.yaml":   "[{name: queue, resourceRef: sqs}]",
+		"resources/a-buckets.yml":   "[{name: bucket, resourceRef: s3}]",
+		"resources/nested/db.yaml":  "[{name: database, resourceRef: rds}]",
+		"others/ignored.yaml":       "[{name: ignored, resourceRef: s3}]",
+		"resources-elsewhere/x.yml": "[{name: elsewhere, resourceRef: s3}]",
+	})
+
+	t.Run("We should read the YAML files inside the directory, in lexical order", func(t *testing.T) {
+		resources, err := ReadResources(artifact, "resources")
+		assert.NoError(t, err)
+
+		names := make([]string, 0)
+		for _, resource := range resources {
+			names = append(names, resource.Name)
+		}
+		assert.Equal(t, []string{"bucket", "queue", "database"}, names)
+	})
+
+	t.Run("We should read a single file", func(t *testing.T) {
+		resources, err := ReadResources(artifact, "./others/ignored.yaml")
+		assert.NoError(t, err)
+
+		if assert.Len(t, resources, 1) {
+			assert.Equal(t, "ignored", resources[0].Name)
+		}
+	})
+
+	t.Run("A path without YAML files should be rejected", func(t *testing.T) {
+		_, err := ReadResources(artifact, "missing")
+		assert.ErrorContains(t, err, "there are no YAML files at /missing")
+	})
+
+	t.Run("An artifact with too many entries should be rejected", func(t *testing.T) {
+		files := make(map[string]string)
+		for i := 0; i <= MaxEntries; i++ {
+			files[fmt.Sprintf("resources/%05d.yaml", i)] = "[]"
+		}
+
+		_, err := ReadResources(newArtifact(t, files), "resources")
+		assert.ErrorContains(t, err, fmt.Sprintf("the artifact has more than %d entries", MaxEntries))
+	})
+
+	t.Run("An artifact unpacking to more than the maximum size should be rejected, even from files that aren't read", func(t *testing.T) {
+		large := newArtifact(t, map[string]string{
+			"resources.yaml": "[{name: bucket, resourceRef: s3}]",
+			"large.bin":      strings.Repeat("0", MaxUnpackedSize),
+		})
+
+		_, err := ReadResources(large, "resources.yaml")
+		assert.ErrorIs(t, err, errUnpackedTooLarge)
+	})
+}
+
+func Test_Loader(t *testing.T) {
+	artifact := newArtifact(t, map[string]string{"resources.yaml": "[{name: bucket, resourceRef: s3}]"})
+	sum := sha256.Sum256(artifact)
+
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		_, _ = w.Write(artifact)
+	}))
+	defer server.Close()
+
+	newSource := func(artifact map[string]any) *unstructured.Unstructured {
+		source := &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "source.toolkit.fluxcd.io/v1",
+			"kind":       "GitRepository",
+			"metadata":   map[string]any{"name": "resources", "namespace": "flux-system"},
+		}}
+		if artifact != nil {
+			source.Object["status"] = map[string]any{"artifact": artifact}
+		}
+		return source
+	}
+
+	sourceRef := &resourcesv1alpha1.ResourceGroupSourceRef{Kind: "GitRepository", Name: "resources", Namespace: "flux-system"}
+
+	t.Run("We should read the resources from the artifact of the source, once by revision", func(t *testing.T) {
+		loader := NewLoader(fake.NewClientBuilder().WithObjects(newSource(map[string]any{
+			"url":      server.URL + "/gitrepository/flux-system/resources/sha.tar.gz",
+			"revision": "main@sha1:abc",
+			"digest":   "sha256:" + hex.EncodeToString(sum[:]),
+		})).Build())
+
+		loaded, err := loader.Load(context.TODO(), sourceRef)
+		assert.NoError(t, err)
+		assert.Equal(t, "main@sha1:abc/", loaded.Revision)
+		if assert.Len(t, loaded.Resources, 1) {
+			assert.Equal(t, "bucket", loaded.Resources[0].Name)
+		}
+
+		_, err = loader.Load(context.TODO(), sourceRef)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, downloads)
+	})
+
+	t.Run("An artifact not matching its digest should be rejected", func(t *testing.T) {
+		loader := NewLoader(fake.NewClientBuilder().WithObjects(newSource(map[string]any{
+			"url":      server.URL + "/gitrepository/flux-system/resources/sha.tar.gz",
+			"revision": "main@sha1:def",
+			"digest":   "sha256:0000",
+		})).Build())
+
+		_, err := loader.Load(context.TODO(), sourceRef)
+		assert.ErrorContains(t, err, "the digest of the artifact")
+	})
+
+	t.Run("A source without an artifact isn't ready", func(t *testing.T) {
+		loader := NewLoader(fake.NewClientBuilder().WithObjects(newSource(nil)).Build())
+
+		_, err := loader.Load(context.TODO(), sourceRef)
+		assert.ErrorIs(t, err, ErrNotReady)
+	})
+}
+
+func newArtifact(t *testing.T, files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	compressed := gzip.NewWriter(buffer)
+	archive := tar.NewWriter(compressed)
+
+	for name, content := range files {
+		assert.NoError(t, archive.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := archive.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, archive.Close())
+	assert.NoError(t, compressed.Close())
+	return buffer.Bytes()
+}
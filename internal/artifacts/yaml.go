@@ -0,0 +1,76 @@
+package artifacts
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// Decode reads the resources from every document of a YAML file. A document is a list of resources, or an object
+// with a resources list; its other keys are ignored, so they can hold the anchors shared by the resources. Anchors,
+// aliases and merge keys are expanded before the resources are read, so their properties are plain JSON.
+func Decode(content []byte) ([]resourcesv1alpha1.ResourceGroupElement, error) {
+	resources := make([]resourcesv1alpha1.ResourceGroupElement, 0)
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for i := 0; ; i++ {
+		var document any
+		if err := decoder.Decode(&document); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		elements, err := elementsOf(document)
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		resources = append(resources, elements...)
+	}
+
+	return resources, nil
+}
+
+func elementsOf(document any) ([]resourcesv1alpha1.ResourceGroupElement, error) {
+	switch d := document.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+	case map[string]any:
+		document = d["resources"]
+		if _, ok := document.([]any); !ok {
+			return nil, fmt.Errorf("the resources of the document must be a list")
+		}
+	default:
+		return nil, fmt.Errorf("a document must be a list of resources, or an object with a resources list")
+	}
+
+	// keys of any other type than strings are rejected here
+	raw, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	elements := make([]resourcesv1alpha1.ResourceGroupElement, 0)
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&elements); err != nil {
+		return nil, err
+	}
+
+	for i, element := range elements {
+		if element.Name == "" || element.ResourceRef == "" {
+			return nil, fmt.Errorf("resource %d must have a name and a resourceRef", i)
+		}
+	}
+
+	return elements, nil
+}
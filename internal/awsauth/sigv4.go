@@ -0,0 +1,35 @@
+// Package awsauth signs requests to AWS APIs with the signer of the AWS SDK, so the few calls klaudio makes don't need
+// the clients of each service
+package awsauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+var signer = v4.NewSigner()
+
+// Credentials sign the requests; SessionToken is only set to temporary credentials
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// SignRequest adds the Signature Version 4 headers to the request, along with the session token of temporary
+// credentials
+func SignRequest(ctx context.Context, request *http.Request, payload []byte, credentials Credentials, region string, service string, now time.Time) error {
+	payloadHash := sha256.Sum256(payload)
+
+	return signer.SignHTTP(ctx, aws.Credentials{
+		AccessKeyID:     credentials.AccessKeyID,
+		SecretAccessKey: credentials.SecretAccessKey,
+		SessionToken:    credentials.SessionToken,
+	}, request, hex.EncodeToString(payloadHash[:]), service, region, now)
+}
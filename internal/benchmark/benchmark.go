@@ -0,0 +1,245 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	api "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/refs"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// Shape is how the resources of a synthetic group depend on each other
+type Shape string
+
+const (
+	// ShapeChain makes every resource depend on the previous one: as deep as the group is large
+	ShapeChain Shape = "chain"
+	// ShapeFanOut makes every resource depend on the first one: two levels, as wide as the group is large
+	ShapeFanOut Shape = "fanout"
+	// ShapeLayered splits the resources in levels of the given width, each resource depending on two resources of the
+	// level before
+	ShapeLayered Shape = "layered"
+	// ShapeIndependent doesn't declare any dependency
+	ShapeIndependent Shape = "independent"
+)
+
+const (
+	defaultSize       = 100
+	defaultWidth      = 10
+	defaultIterations = 10
+
+	// DefaultResourceRef is the ResourceRef the synthetic resources are deployed with; the noop provisioner is
+	// expected, so nothing real is provisioned
+	DefaultResourceRef = "klaudio-benchmark"
+)
+
+// Options are the synthetic groups loaded by the benchmark, and what is measured
+type Options struct {
+	Shape      Shape
+	Size       int
+	Width      int
+	Iterations int
+
+	// Reconcile deploys the synthetic group to the cluster, through the running controllers, measuring how long it
+	// takes to be ready; ResourceRef is what its resources are deployed with
+	Reconcile   bool
+	ResourceRef string
+	Timeout     time.Duration
+
+	// CPUProfile and MemProfile are files the profiles of the graph build and render are written to, if set
+	CPUProfile string
+	MemProfile string
+}
+
+// ParseOptions reads the options from a comma-separated list of key=value, like shape=layered,size=500,width=20
+func ParseOptions(spec string) (*Options, error) {
+	options := &Options{
+		Shape:       ShapeLayered,
+		Size:        defaultSize,
+		Width:       defaultWidth,
+		Iterations:  defaultIterations,
+		ResourceRef: DefaultResourceRef,
+		Timeout:     10 * time.Minute,
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid benchmark option %s; expected key=value", entry)
+		}
+
+		var err error
+		switch strings.TrimSpace(key) {
+		case "shape":
+			options.Shape = Shape(value)
+		case "size":
+			options.Size, err = strconv.Atoi(value)
+		case "width":
+			options.Width, err = strconv.Atoi(value)
+		case "iterations":
+			options.Iterations, err = strconv.Atoi(value)
+		case "reconcile":
+			options.Reconcile, err = strconv.ParseBool(value)
+		case "resourceRef":
+			options.ResourceRef = value
+		case "timeout":
+			options.Timeout, err = time.ParseDuration(value)
+		case "cpuprofile":
+			options.CPUProfile = value
+		case "memprofile":
+			options.MemProfile = value
+		default:
+			return nil, fmt.Errorf("unknown benchmark option %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid benchmark option %s: %w", key, err)
+		}
+	}
+
+	if options.Size < 1 || options.Width < 1 || options.Iterations < 1 {
+		return nil, fmt.Errorf("benchmark size, width and iterations must be positive")
+	}
+
+	return options, nil
+}
+
+// SyntheticGroup is a group of the given shape and size; every resource reads the outputs of its dependencies, like
+// the noop provisioner echoes them
+func SyntheticGroup(options *Options) (*api.ResourceGroupSpec, error) {
+	group := &api.ResourceGroupSpec{
+		Resources: make([]api.ResourceGroupElement, 0, options.Size),
+	}
+
+	for i := 0; i < options.Size; i++ {
+		dependencies, err := dependenciesOf(options, i)
+		if err != nil {
+			return nil, err
+		}
+
+		inputs := make([]any, 0, len(dependencies))
+		for _, dependency := range dependencies {
+			inputs = append(inputs, fmt.Sprintf("${resources.%s.status.outputs.id}", resourceName(dependency)))
+		}
+
+		properties, err := json.Marshal(map[string]any{
+			"id":     fmt.Sprintf("%s-id", resourceName(i)),
+			"inputs": inputs,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		group.Resources = append(group.Resources, api.ResourceGroupElement{
+			Name:        resourceName(i),
+			ResourceRef: options.ResourceRef,
+			Properties:  &runtime.RawExtension{Raw: properties},
+		})
+	}
+
+	return group, nil
+}
+
+func dependenciesOf(options *Options, i int) ([]int, error) {
+	switch options.Shape {
+	case ShapeChain:
+		if i == 0 {
+			return nil, nil
+		}
+		return []int{i - 1}, nil
+
+	case ShapeFanOut:
+		if i == 0 {
+			return nil, nil
+		}
+		return []int{0}, nil
+
+	case ShapeLayered:
+		level, position := i/options.Width, i%options.Width
+		if level == 0 {
+			return nil, nil
+		}
+		previous := (level - 1) * options.Width
+		// the same resource twice when the levels are a single resource wide
+		return slices.Compact([]int{previous + position, previous + (position+1)%options.Width}), nil
+
+	case ShapeIndependent:
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown benchmark shape %s; expected %s, %s, %s or %s", options.Shape, ShapeChain, ShapeFanOut, ShapeLayered, ShapeIndependent)
+}
+
+// resourceName is padded, so the names sort in the order the resources were declared
+func resourceName(i int) string {
+	return fmt.Sprintf("r%05d", i)
+}
+
+// build reads the resources of the group and sorts them, like the first stages of a deployment
+func build(group *api.ResourceGroupSpec) (*resources.ResourceGroup, []string, error) {
+	args := resources.NewResourcePropertiesArgs(make(map[string]any), refs.NewReferences())
+
+	resourceGroup := resources.NewResourceGroup()
+	for _, element := range group.Resources {
+		if _, err := resourceGroup.NewResources(element, args); err != nil {
+			return nil, nil, fmt.Errorf("unable to read resource %s: %w", element.Name, err)
+		}
+	}
+
+	dag, err := resourceGroup.Graph()
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to generate a graph from the group resources: %w", err)
+	}
+
+	return resourceGroup, dag, nil
+}
+
+// render evaluates the resources in the order of a deployment; their properties are echoed as outputs, like the noop
+// provisioner does
+func render(resourceGroup *resources.ResourceGroup, dag []string) error {
+	args := resources.NewResourcePropertiesArgs(make(map[string]any), refs.NewReferences())
+
+	for _, name := range dag {
+		resource, err := resourceGroup.Get(name)
+		if err != nil {
+			return err
+		}
+
+		expandedProperties, err := resource.Evaluate(args)
+		if err != nil {
+			return fmt.Errorf("unable to render resource %s: %w", resource.Name, err)
+		}
+
+		rawProperties, err := json.Marshal(expandedProperties)
+		if err != nil {
+			return err
+		}
+
+		outputs := make(map[string]any)
+		if err := json.Unmarshal(rawProperties, &outputs); err != nil {
+			return err
+		}
+
+		deployed := &api.Resource{Spec: api.ResourceSpec{Properties: &runtime.RawExtension{Raw: rawProperties}}}
+		if err := deployed.Status.SetOutputs(outputs); err != nil {
+			return err
+		}
+
+		args, err = args.WithResource(resource, deployed)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
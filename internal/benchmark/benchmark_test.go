@@ -0,0 +1,79 @@
+package benchmark
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseOptions(t *testing.T) {
+	t.Run("We should be able to read the options, keeping the defaults of the missing ones", func(t *testing.T) {
+		options, err := ParseOptions("shape=chain,size=50,reconcile=true,timeout=1m")
+
+		assert.NoError(t, err)
+		assert.Equal(t, ShapeChain, options.Shape)
+		assert.Equal(t, 50, options.Size)
+		assert.Equal(t, defaultIterations, options.Iterations)
+		assert.True(t, options.Reconcile)
+		assert.Equal(t, time.Minute, options.Timeout)
+		assert.Equal(t, DefaultResourceRef, options.ResourceRef)
+	})
+
+	t.Run("We should refuse unknown or invalid options", func(t *testing.T) {
+		_, err := ParseOptions("depth=10")
+		assert.ErrorContains(t, err, "unknown benchmark option depth")
+
+		_, err = ParseOptions("size=many")
+		assert.ErrorContains(t, err, "invalid benchmark option size")
+
+		_, err = ParseOptions("iterations=0")
+		assert.Error(t, err)
+	})
+}
+
+func Test_SyntheticGroup(t *testing.T) {
+	levelsOf := func(t *testing.T, options *Options) [][]string {
+		group, err := SyntheticGroup(options)
+		assert.NoError(t, err)
+		assert.Len(t, group.Resources, options.Size)
+
+		resourceGroup, dag, err := build(group)
+		assert.NoError(t, err)
+		assert.Len(t, dag, options.Size)
+
+		levels, err := resourceGroup.DeploymentLevels()
+		assert.NoError(t, err)
+		return levels
+	}
+
+	t.Run("We should be able to generate groups of each shape", func(t *testing.T) {
+		assert.Len(t, levelsOf(t, &Options{Shape: ShapeChain, Size: 5, ResourceRef: DefaultResourceRef}), 5)
+		assert.Len(t, levelsOf(t, &Options{Shape: ShapeFanOut, Size: 5, ResourceRef: DefaultResourceRef}), 2)
+		assert.Len(t, levelsOf(t, &Options{Shape: ShapeIndependent, Size: 5, ResourceRef: DefaultResourceRef}), 1)
+
+		layered := levelsOf(t, &Options{Shape: ShapeLayered, Size: 12, Width: 4, ResourceRef: DefaultResourceRef})
+		assert.Len(t, layered, 3)
+		assert.Len(t, layered[0], 4)
+	})
+
+	t.Run("We should refuse unknown shapes", func(t *testing.T) {
+		_, err := SyntheticGroup(&Options{Shape: "ring", Size: 2})
+		assert.ErrorContains(t, err, "unknown benchmark shape ring")
+	})
+}
+
+func Test_Run(t *testing.T) {
+	t.Run("We should be able to measure the graph build and render of a synthetic group", func(t *testing.T) {
+		report, err := Run(context.Background(), nil, &Options{Shape: ShapeLayered, Size: 20, Width: 5, Iterations: 3, ResourceRef: DefaultResourceRef})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 4, report.Levels)
+		assert.Equal(t, 5, report.MaxWidth)
+		assert.Equal(t, 3, report.GraphBuild.Iterations)
+		assert.Equal(t, 3, report.Render.Iterations)
+		assert.LessOrEqual(t, report.Render.Min, report.Render.Max)
+		assert.Nil(t, report.Reconcile)
+	})
+}
@@ -0,0 +1,194 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// Report is what the benchmark measured; each stage is timed on every iteration
+type Report struct {
+	Shape    Shape `json:"shape"`
+	Size     int   `json:"size"`
+	Levels   int   `json:"levels"`
+	MaxWidth int   `json:"maxWidth"`
+
+	GraphBuild Stat  `json:"graphBuild"`
+	Render     Stat  `json:"render"`
+	Reconcile  *Stat `json:"reconcile,omitempty"`
+}
+
+// Stat sums up the timings of a stage; throughput is how many resources are handled per second
+type Stat struct {
+	Iterations int           `json:"iterations"`
+	Min        time.Duration `json:"min"`
+	Mean       time.Duration `json:"mean"`
+	Max        time.Duration `json:"max"`
+	Throughput float64       `json:"resourcesPerSecond"`
+}
+
+func statOf(timings []time.Duration, size int) Stat {
+	stat := Stat{Iterations: len(timings)}
+	if len(timings) == 0 {
+		return stat
+	}
+
+	var total time.Duration
+	stat.Min = timings[0]
+	for _, timing := range timings {
+		total += timing
+		stat.Min = min(stat.Min, timing)
+		stat.Max = max(stat.Max, timing)
+	}
+	stat.Mean = total / time.Duration(len(timings))
+	if total > 0 {
+		stat.Throughput = float64(size*len(timings)) / total.Seconds()
+	}
+
+	return stat
+}
+
+// Run loads a synthetic group and measures how fast its graph is built and its resources are rendered; when asked,
+// the group is deployed to the cluster too. The client is only used to reconcile.
+func Run(ctx context.Context, c client.Client, options *Options) (*Report, error) {
+	group, err := SyntheticGroup(options)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Shape: options.Shape, Size: options.Size}
+
+	resourceGroup, _, err := build(group)
+	if err != nil {
+		return nil, err
+	}
+	levels, err := resourceGroup.DeploymentLevels()
+	if err != nil {
+		return nil, err
+	}
+	report.Levels = len(levels)
+	for _, level := range levels {
+		report.MaxWidth = max(report.MaxWidth, len(level))
+	}
+
+	if options.CPUProfile != "" {
+		profile, err := os.Create(options.CPUProfile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create the CPU profile: %w", err)
+		}
+		defer profile.Close()
+
+		if err := pprof.StartCPUProfile(profile); err != nil {
+			return nil, fmt.Errorf("unable to start the CPU profile: %w", err)
+		}
+	}
+
+	buildTimings := make([]time.Duration, 0, options.Iterations)
+	renderTimings := make([]time.Duration, 0, options.Iterations)
+	for i := 0; i < options.Iterations; i++ {
+		start := time.Now()
+		resourceGroup, dag, err := build(group)
+		if err != nil {
+			pprof.StopCPUProfile()
+			return nil, err
+		}
+		buildTimings = append(buildTimings, time.Since(start))
+
+		start = time.Now()
+		if err := render(resourceGroup, dag); err != nil {
+			pprof.StopCPUProfile()
+			return nil, err
+		}
+		renderTimings = append(renderTimings, time.Since(start))
+	}
+
+	if options.CPUProfile != "" {
+		pprof.StopCPUProfile()
+	}
+
+	report.GraphBuild = statOf(buildTimings, options.Size)
+	report.Render = statOf(renderTimings, options.Size)
+
+	if options.MemProfile != "" {
+		if err := writeMemProfile(options.MemProfile); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Reconcile {
+		reconcileTimings := make([]time.Duration, 0, options.Iterations)
+		for i := 0; i < options.Iterations; i++ {
+			elapsed, err := reconcile(ctx, c, options, group, i)
+			if err != nil {
+				return nil, err
+			}
+			reconcileTimings = append(reconcileTimings, elapsed)
+		}
+		stat := statOf(reconcileTimings, options.Size)
+		report.Reconcile = &stat
+	}
+
+	return report, nil
+}
+
+func writeMemProfile(path string) error {
+	profile, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create the memory profile: %w", err)
+	}
+	defer profile.Close()
+
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(profile); err != nil {
+		return fmt.Errorf("unable to write the memory profile: %w", err)
+	}
+	return nil
+}
+
+// reconcile deploys the synthetic group through the running controllers, measuring how long it takes to be ready;
+// the group is deleted afterwards, whatever happened
+func reconcile(ctx context.Context, c client.Client, options *Options, group *api.ResourceGroupSpec, iteration int) (time.Duration, error) {
+	resourceGroup := &api.ResourceGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   fmt.Sprintf("klaudio-benchmark-%d-%d", time.Now().Unix(), iteration),
+			Labels: map[string]string{api.Group + "/benchmark": "true"},
+		},
+		Spec: *group.DeepCopy(),
+	}
+
+	start := time.Now()
+	if err := c.Create(ctx, resourceGroup); err != nil {
+		return 0, fmt.Errorf("unable to create the benchmark ResourceGroup: %w", err)
+	}
+	defer func() {
+		_ = client.IgnoreNotFound(c.Delete(context.Background(), resourceGroup))
+	}()
+
+	var phase api.DeploymentPhase
+	err := wait.PollUntilContextTimeout(ctx, time.Second, options.Timeout, false, func(ctx context.Context) (bool, error) {
+		current := &api.ResourceGroup{}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(resourceGroup), current); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		phase = current.Status.Phase
+		return phase == api.DeploymentDonePhase || phase == api.DeploymentFailedPhase, nil
+	})
+	elapsed := time.Since(start)
+	if err != nil {
+		return 0, fmt.Errorf("benchmark ResourceGroup %s wasn't ready after %s (phase %s): %w", resourceGroup.Name, options.Timeout, phase, err)
+	}
+	if phase == api.DeploymentFailedPhase {
+		return 0, fmt.Errorf("benchmark ResourceGroup %s failed; is ResourceRef %s a noop one?", resourceGroup.Name, options.ResourceRef)
+	}
+
+	return elapsed, nil
+}
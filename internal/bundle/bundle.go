@@ -0,0 +1,276 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	sigsyaml "sigs.k8s.io/yaml"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// Bundle is a ResourceGroup together with what it needs from the management cluster: the ResourceRefs of its
+// resources and the ConfigMaps its expressions read as refs. Secret and External refs are never bundled; they must
+// already exist in the cluster the bundle is imported to.
+type Bundle struct {
+	ResourceGroup *resourcesv1alpha1.ResourceGroup
+	ResourceRefs  []resourcesv1alpha1.ResourceRef
+	ConfigMaps    []corev1.ConfigMap
+}
+
+// Export reads a ResourceGroup and what it needs from the cluster; objects are stripped from their status and from
+// the metadata only meaningful to the cluster they were read from
+func Export(ctx context.Context, c client.Reader, name string) (*Bundle, error) {
+	resourceGroup := &resourcesv1alpha1.ResourceGroup{}
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, resourceGroup); err != nil {
+		return nil, fmt.Errorf("unable to fetch ResourceGroup %s: %w", name, err)
+	}
+
+	bundle := &Bundle{
+		ResourceGroup: &resourcesv1alpha1.ResourceGroup{
+			ObjectMeta: portable(resourceGroup.ObjectMeta),
+			Spec:       resourceGroup.Spec,
+		},
+		ResourceRefs: make([]resourcesv1alpha1.ResourceRef, 0),
+		ConfigMaps:   make([]corev1.ConfigMap, 0),
+	}
+
+	resourceRefNames := sets.New[string]()
+	for _, element := range resourceGroup.Spec.Resources {
+		resourceRefNames.Insert(element.ResourceRef)
+	}
+	for _, resourceRefName := range sets.List(resourceRefNames) {
+		resourceRef := &resourcesv1alpha1.ResourceRef{}
+		if err := c.Get(ctx, client.ObjectKey{Name: resourceRefName}, resourceRef); err != nil {
+			return nil, fmt.Errorf("unable to fetch ResourceRef %s: %w", resourceRefName, err)
+		}
+		bundle.ResourceRefs = append(bundle.ResourceRefs, resourcesv1alpha1.ResourceRef{
+			ObjectMeta: portable(resourceRef.ObjectMeta),
+			Spec:       resourceRef.Spec,
+		})
+	}
+
+	configMaps := make(map[types.NamespacedName]corev1.ConfigMap)
+	for _, ref := range resourceGroup.Spec.Refs {
+		if ref.Kind != resourcesv1alpha1.ResourceGroupRefConfigMap {
+			continue
+		}
+
+		if ref.Selector == nil {
+			configMap := corev1.ConfigMap{}
+			if err := c.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, &configMap); err != nil {
+				return nil, fmt.Errorf("unable to fetch ConfigMap %s, read by ref %s: %w", ref.Name, ref.Name, err)
+			}
+			configMaps[client.ObjectKeyFromObject(&configMap)] = configMap
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(ref.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector of ref %s: %w", ref.Name, err)
+		}
+		options := []client.ListOption{client.MatchingLabelsSelector{Selector: selector}}
+		if ref.Namespace != "" {
+			options = append(options, client.InNamespace(ref.Namespace))
+		}
+		list := &corev1.ConfigMapList{}
+		if err := c.List(ctx, list, options...); err != nil {
+			return nil, fmt.Errorf("unable to list the ConfigMaps read by ref %s: %w", ref.Name, err)
+		}
+		for _, configMap := range list.Items {
+			configMaps[client.ObjectKeyFromObject(&configMap)] = configMap
+		}
+	}
+	for _, key := range slices.SortedFunc(maps.Keys(configMaps), compareKeys) {
+		configMap := configMaps[key]
+		bundle.ConfigMaps = append(bundle.ConfigMaps, corev1.ConfigMap{
+			ObjectMeta: portable(configMap.ObjectMeta),
+			Immutable:  configMap.Immutable,
+			Data:       configMap.Data,
+			BinaryData: configMap.BinaryData,
+		})
+	}
+
+	return bundle, nil
+}
+
+// Objects are the objects of the bundle in the order they're applied: what the ResourceGroup reads comes first, so
+// the admission webhook finds its ResourceRefs
+func (b *Bundle) Objects() []client.Object {
+	objects := make([]client.Object, 0, len(b.ConfigMaps)+len(b.ResourceRefs)+1)
+	for i := range b.ConfigMaps {
+		configMap := &b.ConfigMaps[i]
+		configMap.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+		objects = append(objects, configMap)
+	}
+	for i := range b.ResourceRefs {
+		resourceRef := &b.ResourceRefs[i]
+		resourceRef.SetGroupVersionKind(resourcesv1alpha1.GroupVersion.WithKind("ResourceRef"))
+		objects = append(objects, resourceRef)
+	}
+	if b.ResourceGroup != nil {
+		b.ResourceGroup.SetGroupVersionKind(resourcesv1alpha1.GroupVersion.WithKind("ResourceGroup"))
+		objects = append(objects, b.ResourceGroup)
+	}
+	return objects
+}
+
+// Write encodes the bundle as a multi-document YAML, which can be applied with kubectl as well
+func (b *Bundle) Write(w io.Writer) error {
+	for i, object := range b.Objects() {
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+
+		document, err := sigsyaml.Marshal(object)
+		if err != nil {
+			return fmt.Errorf("unable to encode %s %s: %w", object.GetObjectKind().GroupVersionKind().Kind, object.GetName(), err)
+		}
+		if _, err := w.Write(document); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read decodes a bundle written by Write; a bundle holds a single ResourceGroup
+func Read(r io.Reader) (*Bundle, error) {
+	bundle := &Bundle{
+		ResourceRefs: make([]resourcesv1alpha1.ResourceRef, 0),
+		ConfigMaps:   make([]corev1.ConfigMap, 0),
+	}
+
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		raw := runtime.RawExtension{}
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		raw.Raw = bytes.TrimSpace(raw.Raw)
+		if len(raw.Raw) == 0 || string(raw.Raw) == "null" {
+			continue
+		}
+
+		typeMeta := metav1.TypeMeta{}
+		if err := json.Unmarshal(raw.Raw, &typeMeta); err != nil {
+			return nil, err
+		}
+
+		switch typeMeta.Kind {
+		case "ResourceGroup":
+			if bundle.ResourceGroup != nil {
+				return nil, errors.New("a bundle holds a single ResourceGroup")
+			}
+			bundle.ResourceGroup = &resourcesv1alpha1.ResourceGroup{}
+			if err := json.Unmarshal(raw.Raw, bundle.ResourceGroup); err != nil {
+				return nil, err
+			}
+		case "ResourceRef":
+			resourceRef := resourcesv1alpha1.ResourceRef{}
+			if err := json.Unmarshal(raw.Raw, &resourceRef); err != nil {
+				return nil, err
+			}
+			bundle.ResourceRefs = append(bundle.ResourceRefs, resourceRef)
+		case "ConfigMap":
+			configMap := corev1.ConfigMap{}
+			if err := json.Unmarshal(raw.Raw, &configMap); err != nil {
+				return nil, err
+			}
+			bundle.ConfigMaps = append(bundle.ConfigMaps, configMap)
+		default:
+			return nil, fmt.Errorf("unexpected %s in the bundle", typeMeta.Kind)
+		}
+	}
+
+	if bundle.ResourceGroup == nil {
+		return nil, errors.New("the bundle has no ResourceGroup")
+	}
+	return bundle, nil
+}
+
+// Import creates the objects of the bundle, or replaces the specs of the ones already in the cluster, returning what
+// was done to each of them. Namespaces of the ConfigMaps must already exist.
+func (b *Bundle) Import(ctx context.Context, c client.Client) ([]string, error) {
+	changes := make([]string, 0)
+	for _, object := range b.Objects() {
+		kind := object.GetObjectKind().GroupVersionKind().Kind
+
+		existing := object.DeepCopyObject().(client.Object)
+		if err := c.Get(ctx, client.ObjectKeyFromObject(object), existing); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return changes, fmt.Errorf("unable to fetch %s %s: %w", kind, object.GetName(), err)
+			}
+			if err := c.Create(ctx, object); err != nil {
+				return changes, fmt.Errorf("unable to create %s %s: %w", kind, object.GetName(), err)
+			}
+			changes = append(changes, fmt.Sprintf("%s %s created", kind, nameOf(object)))
+			continue
+		}
+
+		// what controllers keep in the metadata survives the import
+		object.SetResourceVersion(existing.GetResourceVersion())
+		object.SetFinalizers(existing.GetFinalizers())
+		object.SetOwnerReferences(existing.GetOwnerReferences())
+		object.SetLabels(merged(existing.GetLabels(), object.GetLabels()))
+		object.SetAnnotations(merged(existing.GetAnnotations(), object.GetAnnotations()))
+		if err := c.Update(ctx, object); err != nil {
+			return changes, fmt.Errorf("unable to update %s %s: %w", kind, object.GetName(), err)
+		}
+		changes = append(changes, fmt.Sprintf("%s %s updated", kind, nameOf(object)))
+	}
+	return changes, nil
+}
+
+// portable keeps the metadata meaningful to any cluster: the name, the namespace, labels and annotations
+func portable(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	annotations := maps.Clone(meta.Annotations)
+	delete(annotations, corev1.LastAppliedConfigAnnotation)
+
+	return metav1.ObjectMeta{
+		Name:        meta.Name,
+		Namespace:   meta.Namespace,
+		Labels:      meta.Labels,
+		Annotations: annotations,
+	}
+}
+
+func merged(existing, imported map[string]string) map[string]string {
+	if len(existing) == 0 {
+		return imported
+	}
+	all := maps.Clone(existing)
+	maps.Copy(all, imported)
+	return all
+}
+
+func nameOf(object client.Object) string {
+	if object.GetNamespace() == "" {
+		return object.GetName()
+	}
+	return fmt.Sprintf("%s/%s", object.GetNamespace(), object.GetName())
+}
+
+func compareKeys(a, b types.NamespacedName) int {
+	return strings.Compare(a.String(), b.String())
+}
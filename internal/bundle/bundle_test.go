@@ -0,0 +1,144 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_Bundle(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	ctx := context.TODO()
+
+	resourceGroup := &resourcesv1alpha1.ResourceGroup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "checkout",
+			Labels:      map[string]string{"team": "payments"},
+			Annotations: map[string]string{corev1.LastAppliedConfigAnnotation: "{}"},
+			Finalizers:  []string{"resources.klaudio.nubank.io/finalizer"},
+		},
+		Spec: resourcesv1alpha1.ResourceGroupSpec{
+			Refs: []resourcesv1alpha1.ResourceGroupRef{
+				{Name: "owner", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefConfigMap, Namespace: "checkout"},
+				{Name: "flags", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefConfigMap, Namespace: "checkout",
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"klaudio/feature-flags": "true"}}},
+				{Name: "password", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefSecret, Namespace: "checkout"},
+			},
+			Resources: []resourcesv1alpha1.ResourceGroupElement{
+				{Name: "database", ResourceRef: "rds", Properties: &runtime.RawExtension{Raw: []byte(`{"name":"orders"}`)}},
+				{Name: "replica", ResourceRef: "rds", Properties: &runtime.RawExtension{Raw: []byte(`{"name":"orders-replica"}`)}},
+				{Name: "queue", ResourceRef: "sqs", Properties: &runtime.RawExtension{Raw: []byte(`{}`)}},
+			},
+		},
+		Status: resourcesv1alpha1.ResourceGroupStatus{Phase: resourcesv1alpha1.DeploymentDonePhase},
+	}
+
+	newResourceRef := func(name string) *resourcesv1alpha1.ResourceRef {
+		return &resourcesv1alpha1.ResourceRef{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: resourcesv1alpha1.ResourceRefSpec{
+				Provisioner: resourcesv1alpha1.ResourceRefProvisioner{Name: resourcesv1alpha1.ResourceRefNoopProvisioner},
+				Schema:      resourcesv1alpha1.ResourceRefSchema{Type: "object"},
+			},
+		}
+	}
+
+	source := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(
+			resourceGroup,
+			newResourceRef("rds"),
+			newResourceRef("sqs"),
+			newResourceRef("unused"),
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "checkout"}, Data: map[string]string{"team": "payments"}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "flags-a", Namespace: "checkout", Labels: map[string]string{"klaudio/feature-flags": "true"}}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "checkout"}},
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "password", Namespace: "checkout"}},
+		).
+		Build()
+
+	t.Run("We should export a ResourceGroup with its ResourceRefs and ConfigMap refs", func(t *testing.T) {
+		b, err := Export(ctx, source, "checkout")
+		assert.NoError(t, err)
+
+		assert.Equal(t, "checkout", b.ResourceGroup.Name)
+		assert.Equal(t, map[string]string{"team": "payments"}, b.ResourceGroup.Labels)
+		assert.Empty(t, b.ResourceGroup.Annotations)
+		assert.Empty(t, b.ResourceGroup.Finalizers)
+		assert.Empty(t, b.ResourceGroup.ResourceVersion)
+		assert.Empty(t, b.ResourceGroup.Status)
+
+		if assert.Len(t, b.ResourceRefs, 2) {
+			assert.Equal(t, "rds", b.ResourceRefs[0].Name)
+			assert.Equal(t, "sqs", b.ResourceRefs[1].Name)
+		}
+
+		if assert.Len(t, b.ConfigMaps, 2) {
+			assert.Equal(t, "flags-a", b.ConfigMaps[0].Name)
+			assert.Equal(t, "owner", b.ConfigMaps[1].Name)
+			assert.Equal(t, map[string]string{"team": "payments"}, b.ConfigMaps[1].Data)
+		}
+	})
+
+	t.Run("We should read the bundle back from what was written", func(t *testing.T) {
+		b, err := Export(ctx, source, "checkout")
+		assert.NoError(t, err)
+
+		buffer := &bytes.Buffer{}
+		assert.NoError(t, b.Write(buffer))
+		assert.Contains(t, buffer.String(), "kind: ResourceGroup")
+
+		read, err := Read(buffer)
+		assert.NoError(t, err)
+
+		assert.Equal(t, b.ResourceGroup.Spec, read.ResourceGroup.Spec)
+		assert.Len(t, read.ResourceRefs, 2)
+		assert.Len(t, read.ConfigMaps, 2)
+	})
+
+	t.Run("We should import the bundle into another cluster", func(t *testing.T) {
+		target := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(&resourcesv1alpha1.ResourceRef{
+				ObjectMeta: metav1.ObjectMeta{Name: "rds", Finalizers: []string{"resources.klaudio.nubank.io/finalizer"}},
+			}).
+			Build()
+
+		b, err := Export(ctx, source, "checkout")
+		assert.NoError(t, err)
+
+		changes, err := b.Import(ctx, target)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"ConfigMap checkout/flags-a created",
+			"ConfigMap checkout/owner created",
+			"ResourceRef rds updated",
+			"ResourceRef sqs created",
+			"ResourceGroup checkout created",
+		}, changes)
+
+		rds := &resourcesv1alpha1.ResourceRef{}
+		assert.NoError(t, target.Get(ctx, client.ObjectKey{Name: "rds"}, rds))
+		assert.Equal(t, resourcesv1alpha1.ResourceRefProvisionerName(resourcesv1alpha1.ResourceRefNoopProvisioner), rds.Spec.Provisioner.Name)
+		assert.Equal(t, []string{"resources.klaudio.nubank.io/finalizer"}, rds.Finalizers)
+
+		imported := &resourcesv1alpha1.ResourceGroup{}
+		assert.NoError(t, target.Get(ctx, client.ObjectKey{Name: "checkout"}, imported))
+		assert.Equal(t, resourceGroup.Spec, imported.Spec)
+	})
+
+	t.Run("We should reject a bundle without a ResourceGroup", func(t *testing.T) {
+		_, err := Read(bytes.NewBufferString("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: owner\n"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,226 @@
+package changeset
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/expression"
+	"github.com/nubank/klaudio/internal/generated"
+	"github.com/nubank/klaudio/internal/ipam"
+	"github.com/nubank/klaudio/internal/outputs"
+	"github.com/nubank/klaudio/internal/refs"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// OfDeployment renders the resources of a ResourceGroup to one of its deployments, comparing them with the Resources
+// live in the cluster; nothing is created or changed. Parameters declared by the group override the ones from the
+// deployment, and refs are resolved again, as a new deployment run would do.
+func OfDeployment(ctx context.Context, c client.Client, group *resourcesv1alpha1.ResourceGroupSpec, deployment *resourcesv1alpha1.ResourceGroupDeployment) ([]resourcesv1alpha1.ResourceGroupDeploymentPlannedResource, error) {
+	parameters, err := objectOf(deployment.Spec.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid deployment parameters: %w", err)
+	}
+	groupParameters, err := objectOf(group.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid group parameters: %w", err)
+	}
+	maps.Copy(parameters, groupParameters)
+
+	references := refs.NewReferences()
+	for _, ref := range deployment.Spec.Refs {
+		if refs.IsSecret(ref) {
+			continue
+		}
+		if _, err := references.NewReference(ctx, c, ref); err != nil {
+			return nil, fmt.Errorf("unable to resolve ref %s: %w", ref.Name, err)
+		}
+	}
+
+	secrets, err := refs.Secrets(ctx, c, deployment.Namespace, deployment.Spec.Refs)
+	if err != nil {
+		return nil, err
+	}
+
+	driftPolicies := make(map[string]resourcesv1alpha1.DriftPolicy)
+	deletionPolicies := make(map[string]resourcesv1alpha1.DeletionPolicy)
+	protected := make(map[string]bool)
+	writeOutputsTo := make(map[string]*resourcesv1alpha1.ResourceOutputsTarget)
+	objectNames := make(map[string]string)
+	verifications := make(map[string]*resourcesv1alpha1.ResourceVerification)
+	hooks := make(map[string]*resourcesv1alpha1.ResourceHooks)
+
+	forEachArgs := resources.NewResourcePropertiesArgs(parameters, references)
+
+	resourceGroup := resources.NewResourceGroup().WithExpressionLanguage(expression.Language(group.ExpressionLanguage))
+	err = resourceGroup.NewElements(group.Resources, forEachArgs, func(resource *resources.Resource, element resourcesv1alpha1.ResourceGroupElement) error {
+		resourceRef := &resourcesv1alpha1.ResourceRef{}
+		if err := c.Get(ctx, types.NamespacedName{Name: element.ResourceRef}, resourceRef); err != nil {
+			return fmt.Errorf("unable to fetch ResourceRef %s: %w", element.ResourceRef, err)
+		}
+		resource.Ref = resourceRef
+
+		driftPolicies[resource.Name] = group.DriftPolicy
+		if element.DriftPolicy != "" {
+			driftPolicies[resource.Name] = element.DriftPolicy
+		}
+		deletionPolicies[resource.Name] = element.DeletionPolicy
+		protected[resource.Name] = element.Protect
+		writeOutputsTo[resource.Name] = element.WriteOutputsTo
+		objectNames[resource.Name] = resource.ProvisionerObjectNameOf(element.ProvisionerObjectName)
+		verifications[resource.Name] = element.Verification
+		hooks[resource.Name] = element.Hooks
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dag, err := resourceGroup.Graph()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate a graph from the group resources: %w", err)
+	}
+
+	changes := make([]resourcesv1alpha1.ResourceGroupDeploymentPlannedResource, 0, len(dag))
+	desired := make([]string, 0, len(dag))
+
+	// resources are rendered in the same order of a deployment; outputs come from the deployed ones
+	// subnets not allocated yet are only previewed
+	allocator := ipam.NewAllocator(ctx, c, cmp.Or(deployment.Labels[resourcesv1alpha1.Group+"/managedBy.name"], deployment.Name), deployment.Spec.Placement, true).
+		WithLegacyNamespace(deployment.Namespace)
+	generator := generated.NewGenerator(ctx, c, deployment.Namespace, deployment.Spec.Placement, true)
+	placement := &resourcesv1alpha1.Placement{}
+	if err := c.Get(ctx, types.NamespacedName{Name: deployment.Spec.Placement}, placement); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		placement = &resourcesv1alpha1.Placement{ObjectMeta: metav1.ObjectMeta{Name: deployment.Spec.Placement}}
+	}
+	args := resources.NewResourcePropertiesArgs(parameters, references).
+		WithCIDRAllocator(allocator).
+		WithValueGenerator(generator).
+		WithPlacement(placement).
+		WithSecrets(secrets)
+	err = resourceGroup.Render(dag, args, func(step *resources.RenderStep) (*resourcesv1alpha1.Resource, error) {
+		resource := step.Resource
+
+		resourceNameToDeploy := fmt.Sprintf("%s.%s", deployment.Name, resource.NameAsKebabCase())
+		desired = append(desired, resourceNameToDeploy)
+
+		plannedResource := resourcesv1alpha1.ResourceGroupDeploymentPlannedResource{Name: resourceNameToDeploy}
+
+		deployed := &resourcesv1alpha1.Resource{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: deployment.Namespace, Name: resourceNameToDeploy}, deployed); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			deployed = nil
+		}
+
+		// only outputs that aren't produced yet are known after the apply; any other error is a broken expression
+		var unknown *expression.UnknownError
+		if errors.As(step.Err, &unknown) {
+			plannedResource.Action = resourcesv1alpha1.PlanActionUnknown
+			if deployed == nil {
+				plannedResource.Action = resourcesv1alpha1.PlanActionCreate
+			}
+			plannedResource.Message = fmt.Sprintf("Properties are only known after the apply: %s", unknown.Error())
+			changes = append(changes, plannedResource)
+			return nil, nil
+		}
+		if step.Err != nil {
+			return nil, fmt.Errorf("unable to evaluate properties from resource %s: %w", resource.Name, step.Err)
+		}
+
+		spec := resourcesv1alpha1.ResourceSpec{
+			Placement:             deployment.Spec.Placement,
+			ResourceRef:           resource.Ref.Name,
+			Properties:            &runtime.RawExtension{Raw: step.RawProperties},
+			DriftPolicy:           driftPolicies[resource.Name],
+			DeletionPolicy:        deletionPolicies[resource.Name],
+			Protect:               protected[resource.Name],
+			WriteOutputsTo:        writeOutputsTo[resource.Name],
+			ProvisionerObjectName: objectNames[resource.Name],
+			Verification:          verifications[resource.Name],
+			Hooks:                 hooks[resource.Name],
+		}
+		// values read from Secret refs are kept out of the spec, so they're never shown in the plan
+		secret := resource.SecretProperties()
+		if _, err := SeparateSecretProperties(&spec, resourcesv1alpha1.SecretPropertiesNameOf(plannedResource.Name), secret); err != nil {
+			return nil, err
+		}
+		plannedResource.Spec = spec.DeepCopy()
+
+		if deployed == nil {
+			plannedResource.Action = resourcesv1alpha1.PlanActionCreate
+			changes = append(changes, plannedResource)
+			return nil, nil
+		}
+
+		spec.ProvisionerObjectName = resourcesv1alpha1.ProvisionerObjectNameOf(deployed, spec.ProvisionerObjectName)
+
+		diff, err := SpecDiff(&deployed.Spec, &spec, secret...)
+		if err != nil {
+			return nil, err
+		}
+
+		plannedResource.Action = resourcesv1alpha1.PlanActionNoChange
+		if len(diff) != 0 {
+			plannedResource.Action = resourcesv1alpha1.PlanActionUpdate
+			plannedResource.Diff = diff
+		}
+
+		changes = append(changes, plannedResource)
+
+		// outputs from the deployed Resource are available to the next ones
+		return outputs.Resolve(ctx, c, deployed)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Resources removed from the resource group would be pruned
+	deployedResources := &resourcesv1alpha1.ResourceList{}
+	if err := c.List(ctx, deployedResources, client.InNamespace(deployment.Namespace), client.MatchingLabels{resourcesv1alpha1.Group + "/managedBy.name": deployment.Name}); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(deployedResources.Items, func(a, b resourcesv1alpha1.Resource) int { return strings.Compare(a.Name, b.Name) })
+
+	for i := range deployedResources.Items {
+		resource := &deployedResources.Items[i]
+		if !metav1.IsControlledBy(resource, deployment) || slices.Contains(desired, resource.Name) {
+			continue
+		}
+		changes = append(changes, resourcesv1alpha1.ResourceGroupDeploymentPlannedResource{
+			Name:    resource.Name,
+			Action:  resourcesv1alpha1.PlanActionDelete,
+			Message: fmt.Sprintf("Removed from the resource group; deletion policy: %s", resource.Spec.DeletionPolicy),
+		})
+	}
+
+	return changes, nil
+}
+
+func objectOf(raw *runtime.RawExtension) (map[string]any, error) {
+	object := make(map[string]any)
+	if raw == nil || len(raw.Raw) == 0 {
+		return object, nil
+	}
+	if err := json.Unmarshal(raw.Raw, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
@@ -0,0 +1,185 @@
+package changeset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_OfDeployment(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	newResourceRef := func(name string) *resourcesv1alpha1.ResourceRef {
+		return &resourcesv1alpha1.ResourceRef{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       resourcesv1alpha1.ResourceRefSpec{Schema: resourcesv1alpha1.ResourceRefSchema{Type: "object"}},
+		}
+	}
+
+	deployment := &resourcesv1alpha1.ResourceGroupDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "checkout.prod", Namespace: "checkout", UID: "deployment-uid"},
+		Spec: resourcesv1alpha1.ResourceGroupDeploymentSpec{
+			Placement:  "prod",
+			Parameters: &runtime.RawExtension{Raw: []byte(`{"size":10}`)},
+		},
+	}
+
+	newDeployedResource := func(name string, resourceRef string, properties string) *resourcesv1alpha1.Resource {
+		return &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: deployment.Namespace,
+				Labels:    map[string]string{resourcesv1alpha1.Group + "/managedBy.name": deployment.Name},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: resourcesv1alpha1.GroupVersion.String(),
+					Kind:       "ResourceGroupDeployment",
+					Name:       deployment.Name,
+					UID:        deployment.UID,
+					Controller: ptr.To(true),
+				}},
+			},
+			Spec: resourcesv1alpha1.ResourceSpec{
+				Placement:   deployment.Spec.Placement,
+				ResourceRef: resourceRef,
+				Properties:  &runtime.RawExtension{Raw: []byte(properties)},
+			},
+		}
+	}
+
+	database := newDeployedResource("checkout.prod.database", "database", `{"size":10}`)
+	assert.NoError(t, database.Status.SetOutputs(map[string]any{"endpoint": "checkout-prod.rds"}))
+
+	removed := newDeployedResource("checkout.prod.queue", "queue", `{}`)
+
+	group := &resourcesv1alpha1.ResourceGroupSpec{
+		Parameters: &runtime.RawExtension{Raw: []byte(`{"size":20}`)},
+		Resources: []resourcesv1alpha1.ResourceGroupElement{
+			{Name: "database", ResourceRef: "database", Properties: &runtime.RawExtension{Raw: []byte(`{"size":"${parameters.size}"}`)}},
+			{Name: "app", ResourceRef: "app", Properties: &runtime.RawExtension{Raw: []byte(`{"database":"${resources.database.status.outputs.endpoint}"}`)}},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(newResourceRef("database"), newResourceRef("app"), database, removed).
+		Build()
+
+	t.Run("We should list what deploying the group would change", func(t *testing.T) {
+		changes, err := OfDeployment(context.TODO(), c, group, deployment)
+
+		assert.NoError(t, err)
+		if assert.Len(t, changes, 3) {
+			assert.Equal(t, "checkout.prod.database", changes[0].Name)
+			assert.Equal(t, resourcesv1alpha1.PlanActionUpdate, changes[0].Action)
+			assert.Equal(t, []string{"~ properties.size: 10 -> 20"}, changes[0].Diff)
+
+			// outputs of the deployed database are available to render the app
+			assert.Equal(t, "checkout.prod.app", changes[1].Name)
+			assert.Equal(t, resourcesv1alpha1.PlanActionCreate, changes[1].Action)
+			assert.JSONEq(t, `{"database":"checkout-prod.rds"}`, string(changes[1].Spec.Properties.Raw))
+
+			assert.Equal(t, "checkout.prod.queue", changes[2].Name)
+			assert.Equal(t, resourcesv1alpha1.PlanActionDelete, changes[2].Action)
+		}
+	})
+
+	t.Run("We should report no changes when the group matches the deployed Resources", func(t *testing.T) {
+		unchanged := &resourcesv1alpha1.ResourceGroupSpec{
+			Resources: []resourcesv1alpha1.ResourceGroupElement{
+				{Name: "database", ResourceRef: "database", Properties: &runtime.RawExtension{Raw: []byte(`{"size":"${parameters.size}"}`)}},
+				{Name: "queue", ResourceRef: "queue", Properties: &runtime.RawExtension{Raw: []byte(`{}`)}},
+			},
+		}
+
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(newResourceRef("database"), newResourceRef("queue"), database, removed).
+			Build()
+
+		changes, err := OfDeployment(context.TODO(), c, unchanged, deployment)
+
+		assert.NoError(t, err)
+		for _, change := range changes {
+			assert.Equal(t, resourcesv1alpha1.PlanActionNoChange, change.Action, change.Name)
+		}
+	})
+
+	t.Run("Only properties reading outputs not produced yet should be known after the apply", func(t *testing.T) {
+		waiting := &resourcesv1alpha1.ResourceGroupSpec{
+			Resources: []resourcesv1alpha1.ResourceGroupElement{
+				{Name: "queue", ResourceRef: "queue", Properties: &runtime.RawExtension{Raw: []byte(`{}`)}},
+				{Name: "app", ResourceRef: "app", Properties: &runtime.RawExtension{Raw: []byte(`{"queue":"${resources.queue.status.outputs.url}"}`)}},
+			},
+		}
+
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(newResourceRef("queue"), newResourceRef("app")).
+			Build()
+
+		changes, err := OfDeployment(context.TODO(), c, waiting, deployment)
+
+		assert.NoError(t, err)
+		if assert.Len(t, changes, 2) {
+			assert.Equal(t, "checkout.prod.app", changes[1].Name)
+			assert.Equal(t, resourcesv1alpha1.PlanActionCreate, changes[1].Action)
+			assert.Contains(t, changes[1].Message, "only known after the apply")
+		}
+	})
+
+	t.Run("A broken expression should fail the plan instead of being known after the apply", func(t *testing.T) {
+		broken := &resourcesv1alpha1.ResourceGroupSpec{
+			Resources: []resourcesv1alpha1.ResourceGroupElement{
+				{Name: "database", ResourceRef: "database", Properties: &runtime.RawExtension{Raw: []byte(`{"size":"${parameters.size +}"}`)}},
+			},
+		}
+
+		_, err := OfDeployment(context.TODO(), c, broken, deployment)
+
+		assert.ErrorContains(t, err, "unable to evaluate properties from resource database")
+	})
+
+	t.Run("We should never show the values read from secret refs", func(t *testing.T) {
+		withSecrets := deployment.DeepCopy()
+		withSecrets.Spec.Refs = []resourcesv1alpha1.ResourceGroupRef{
+			{Name: "database-credentials", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefSecret, Namespace: "checkout"},
+		}
+
+		credentials := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "database-credentials", Namespace: "checkout"},
+			Data:       map[string][]byte{"password": []byte("new-s3cr3t")},
+		}
+
+		deployed := newDeployedResource("checkout.prod.database", "database", `{"size":10,"password":"old-s3cr3t"}`)
+
+		secretGroup := &resourcesv1alpha1.ResourceGroupSpec{
+			Resources: []resourcesv1alpha1.ResourceGroupElement{
+				{Name: "database", ResourceRef: "database", Properties: &runtime.RawExtension{Raw: []byte(`{"size":"${parameters.size}","password":"${secrets[\"database-credentials\"].password}"}`)}},
+			},
+		}
+
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(newResourceRef("database"), deployed, credentials).
+			Build()
+
+		changes, err := OfDeployment(context.TODO(), c, secretGroup, withSecrets)
+
+		assert.NoError(t, err)
+		if assert.Len(t, changes, 1) {
+			assert.Equal(t, resourcesv1alpha1.PlanActionUpdate, changes[0].Action)
+			assert.Equal(t, []string{"~ secretProperties: (secret value)", "~ properties.password: (secret value)"}, changes[0].Diff)
+			assert.JSONEq(t, `{"size":10}`, string(changes[0].Spec.Properties.Raw))
+			if assert.NotNil(t, changes[0].Spec.SecretProperties) {
+				assert.Equal(t, "checkout.prod.database-secret-properties", changes[0].Spec.SecretProperties.Name)
+				assert.NotContains(t, changes[0].Spec.SecretProperties.Checksum, "new-s3cr3t")
+			}
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package changeset
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// SpecDiff lists the changes from the deployed Resource spec to the planned one, one per line. The values of secret
+// properties are never shown; only the fact they changed.
+func SpecDiff(deployed *resourcesv1alpha1.ResourceSpec, planned *resourcesv1alpha1.ResourceSpec, secret ...string) ([]string, error) {
+	diff := make([]string, 0)
+
+	compare := func(name string, from string, to string) {
+		if from != to {
+			diff = append(diff, fmt.Sprintf("~ %s: %q -> %q", name, from, to))
+		}
+	}
+
+	compare("resourceRef", deployed.ResourceRef, planned.ResourceRef)
+	compare("driftPolicy", string(deployed.DriftPolicy), string(planned.DriftPolicy))
+	compare("deletionPolicy", string(deployed.DeletionPolicy), string(planned.DeletionPolicy))
+	compare("protect", strconv.FormatBool(deployed.Protect), strconv.FormatBool(planned.Protect))
+	compare("provisionerObjectName", deployed.ProvisionerObjectName, planned.ProvisionerObjectName)
+
+	if !equality.Semantic.DeepEqual(deployed.SecretProperties, planned.SecretProperties) {
+		diff = append(diff, "~ secretProperties: (secret value)")
+	}
+
+	propertiesOf := func(properties *runtime.RawExtension) (map[string]json.RawMessage, error) {
+		all := make(map[string]json.RawMessage)
+		if properties == nil || len(properties.Raw) == 0 {
+			return all, nil
+		}
+		if err := json.Unmarshal(properties.Raw, &all); err != nil {
+			return nil, err
+		}
+		return all, nil
+	}
+
+	from, err := propertiesOf(deployed.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := propertiesOf(planned.Properties)
+	if err != nil {
+		return nil, err
+	}
+
+	names := slices.Sorted(maps.Keys(from))
+	for name := range maps.Keys(to) {
+		if _, ok := from[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		fromValue, inFrom := from[name]
+		toValue, inTo := to[name]
+
+		if slices.Contains(secret, name) {
+			if inFrom != inTo || !equality.Semantic.DeepEqual(normalizedJson(fromValue), normalizedJson(toValue)) {
+				diff = append(diff, fmt.Sprintf("~ properties.%s: (secret value)", name))
+			}
+			continue
+		}
+
+		switch {
+		case !inFrom:
+			diff = append(diff, fmt.Sprintf("+ properties.%s: %s", name, toValue))
+		case !inTo:
+			diff = append(diff, fmt.Sprintf("- properties.%s: %s", name, fromValue))
+		case !equality.Semantic.DeepEqual(normalizedJson(fromValue), normalizedJson(toValue)):
+			diff = append(diff, fmt.Sprintf("~ properties.%s: %s -> %s", name, fromValue, toValue))
+		}
+	}
+
+	return diff, nil
+}
+
+// normalizedJson decodes a JSON value, so the same content with a different formatting is considered equal
+func normalizedJson(raw json.RawMessage) any {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw)
+	}
+	return value
+}
+
+// SeparateSecretProperties moves the secret properties out of the spec, so their values are never stored in the
+// Resource: they're returned as the data of the Secret named by spec.secretProperties, one JSON value by property.
+// A spec without secret properties is left as it is.
+func SeparateSecretProperties(spec *resourcesv1alpha1.ResourceSpec, name string, secret []string) (map[string][]byte, error) {
+	if len(secret) == 0 || spec.Properties == nil || len(spec.Properties.Raw) == 0 {
+		return nil, nil
+	}
+
+	properties := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(spec.Properties.Raw, &properties); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]byte)
+	for _, property := range secret {
+		if value, ok := properties[property]; ok {
+			values[property] = value
+			delete(properties, property)
+		}
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(properties)
+	if err != nil {
+		return nil, err
+	}
+	spec.Properties = &runtime.RawExtension{Raw: raw}
+
+	// the name salts the checksum, so equal values don't have equal checksums across Resources
+	checksum := sha256.New()
+	checksum.Write([]byte(name))
+	for _, property := range slices.Sorted(maps.Keys(values)) {
+		checksum.Write([]byte(property))
+		checksum.Write(values[property])
+	}
+	spec.SecretProperties = &resourcesv1alpha1.ResourceSecretProperties{Name: name, Checksum: hex.EncodeToString(checksum.Sum(nil))}
+
+	return values, nil
+}
@@ -0,0 +1,90 @@
+package changeset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_SpecDiff(t *testing.T) {
+	deployed := &resourcesv1alpha1.ResourceSpec{
+		ResourceRef: "database",
+		Properties:  &runtime.RawExtension{Raw: []byte(`{"name":"db","size":10,"engine":"postgres"}`)},
+	}
+
+	t.Run("We should report no changes for the same spec", func(t *testing.T) {
+		planned := deployed.DeepCopy()
+		planned.Properties = &runtime.RawExtension{Raw: []byte(`{"engine": "postgres", "name": "db", "size": 10}`)}
+
+		diff, err := SpecDiff(deployed, planned)
+		assert.NoError(t, err)
+		assert.Empty(t, diff)
+	})
+
+	t.Run("We should list added, removed and changed properties", func(t *testing.T) {
+		planned := deployed.DeepCopy()
+		planned.DeletionPolicy = resourcesv1alpha1.DeletionPolicyRetain
+		planned.Properties = &runtime.RawExtension{Raw: []byte(`{"name":"db","size":20,"version":"16"}`)}
+
+		diff, err := SpecDiff(deployed, planned)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			`~ deletionPolicy: "" -> "Retain"`,
+			`- properties.engine: "postgres"`,
+			`~ properties.size: 10 -> 20`,
+			`+ properties.version: "16"`,
+		}, diff)
+	})
+}
+
+func Test_SeparateSecretProperties(t *testing.T) {
+	newSpec := func(password string) *resourcesv1alpha1.ResourceSpec {
+		return &resourcesv1alpha1.ResourceSpec{
+			ResourceRef: "database",
+			Properties:  &runtime.RawExtension{Raw: []byte(`{"name":"db","password":"` + password + `"}`)},
+		}
+	}
+
+	t.Run("We should move the secret properties out of the spec", func(t *testing.T) {
+		spec := newSpec("s3cr3t")
+
+		values, err := SeparateSecretProperties(spec, "db-secret-properties", []string{"password"})
+		assert.NoError(t, err)
+
+		assert.Equal(t, map[string][]byte{"password": []byte(`"s3cr3t"`)}, values)
+		assert.JSONEq(t, `{"name":"db"}`, string(spec.Properties.Raw))
+		if assert.NotNil(t, spec.SecretProperties) {
+			assert.Equal(t, "db-secret-properties", spec.SecretProperties.Name)
+			assert.NotEmpty(t, spec.SecretProperties.Checksum)
+		}
+	})
+
+	t.Run("We should change the checksum when the values change", func(t *testing.T) {
+		spec, rotated := newSpec("s3cr3t"), newSpec("n3w-s3cr3t")
+
+		_, err := SeparateSecretProperties(spec, "db-secret-properties", []string{"password"})
+		assert.NoError(t, err)
+		_, err = SeparateSecretProperties(rotated, "db-secret-properties", []string{"password"})
+		assert.NoError(t, err)
+
+		assert.NotEqual(t, spec.SecretProperties.Checksum, rotated.SecretProperties.Checksum)
+
+		diff, err := SpecDiff(spec, rotated, "password")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"~ secretProperties: (secret value)"}, diff)
+	})
+
+	t.Run("We should leave a spec without secret properties as it is", func(t *testing.T) {
+		spec := newSpec("s3cr3t")
+
+		values, err := SeparateSecretProperties(spec, "db-secret-properties", nil)
+		assert.NoError(t, err)
+
+		assert.Nil(t, values)
+		assert.Nil(t, spec.SecretProperties)
+		assert.JSONEq(t, `{"name":"db","password":"s3cr3t"}`, string(spec.Properties.Raw))
+	})
+}
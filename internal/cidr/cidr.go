@@ -0,0 +1,177 @@
+// Package cidr does IP address arithmetic over CIDR prefixes, with the same semantics of the Terraform functions of
+// the same name, so network plans can be carried over between both.
+package cidr
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+)
+
+// Host returns the address of the given host number inside the prefix; negative numbers count from the end.
+func Host(prefix string, hostnum int) (string, error) {
+	p, err := parse(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	hostBits := p.Addr().BitLen() - p.Bits()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(hostBits))
+
+	n := big.NewInt(int64(hostnum))
+	if hostnum < 0 {
+		n.Add(n, size)
+	}
+	if n.Sign() < 0 || n.Cmp(size) >= 0 {
+		return "", fmt.Errorf("prefix %s has no host %d", prefix, hostnum)
+	}
+
+	return addrOf(new(big.Int).Add(intOf(p.Addr()), n), p.Addr().Is4()).String(), nil
+}
+
+// Netmask returns the netmask of an IPv4 prefix, in dotted-decimal notation.
+func Netmask(prefix string) (string, error) {
+	p, err := parse(prefix)
+	if err != nil {
+		return "", err
+	}
+	if !p.Addr().Is4() {
+		return "", fmt.Errorf("only IPv4 prefixes have a netmask: %s", prefix)
+	}
+
+	mask := new(big.Int).Lsh(big.NewInt(1), 32)
+	mask.Sub(mask, new(big.Int).Lsh(big.NewInt(1), uint(32-p.Bits())))
+
+	return addrOf(mask, true).String(), nil
+}
+
+// Subnet returns the subnet with the given number, extending the prefix length by newbits.
+func Subnet(prefix string, newbits int, netnum int) (string, error) {
+	p, err := parse(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	bits := p.Bits() + newbits
+	if newbits < 0 || bits > p.Addr().BitLen() {
+		return "", fmt.Errorf("unable to extend prefix %s by %d bits", prefix, newbits)
+	}
+	if netnum < 0 || big.NewInt(int64(netnum)).Cmp(new(big.Int).Lsh(big.NewInt(1), uint(newbits))) >= 0 {
+		return "", fmt.Errorf("prefix %s extended by %d bits has no subnet %d", prefix, newbits, netnum)
+	}
+
+	offset := new(big.Int).Lsh(big.NewInt(int64(netnum)), uint(p.Addr().BitLen()-bits))
+	return netip.PrefixFrom(addrOf(offset.Add(offset, intOf(p.Addr())), p.Addr().Is4()), bits).String(), nil
+}
+
+// Subnets carves consecutive subnets from the prefix, one to each newbits; each subnet starts at the first address
+// after the previous one that is aligned to its own length.
+func Subnets(prefix string, newbits ...int) ([]string, error) {
+	p, err := parse(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	subnets := make([]string, 0, len(newbits))
+
+	next := intOf(p.Addr())
+	for _, n := range newbits {
+		subnet, err := fit(p, p.Bits()+n, next)
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, subnet.String())
+
+		next = new(big.Int).Add(intOf(lastOf(subnet)), big.NewInt(1))
+	}
+
+	return subnets, nil
+}
+
+// NextFree returns the first subnet inside the pool, extending its prefix length by newbits, that doesn't overlap any
+// of the taken ones.
+func NextFree(pool string, newbits int, taken []string) (string, error) {
+	p, err := parse(pool)
+	if err != nil {
+		return "", err
+	}
+
+	takenPrefixes := make([]netip.Prefix, 0, len(taken))
+	for _, t := range taken {
+		takenPrefix, err := parse(t)
+		if err != nil {
+			return "", err
+		}
+		takenPrefixes = append(takenPrefixes, takenPrefix)
+	}
+
+	bits := p.Bits() + newbits
+	if newbits < 0 || bits > p.Addr().BitLen() {
+		return "", fmt.Errorf("unable to extend prefix %s by %d bits", pool, newbits)
+	}
+
+	next := intOf(p.Addr())
+	for {
+		candidate, err := fit(p, bits, next)
+		if err != nil {
+			return "", fmt.Errorf("there is no free /%d subnet left in %s", bits, pool)
+		}
+
+		overlapping := false
+		for _, t := range takenPrefixes {
+			if candidate.Overlaps(t) {
+				overlapping = true
+				next = new(big.Int).Add(intOf(lastOf(t)), big.NewInt(1))
+				break
+			}
+		}
+		if !overlapping {
+			return candidate.String(), nil
+		}
+	}
+}
+
+// fit returns the first prefix of the given length, inside p, starting at or after the address start
+func fit(p netip.Prefix, bits int, start *big.Int) (netip.Prefix, error) {
+	if bits < p.Bits() || bits > p.Addr().BitLen() {
+		return netip.Prefix{}, fmt.Errorf("a /%d subnet doesn't fit in %s", bits, p)
+	}
+
+	size := new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-bits))
+
+	// round up to the next boundary of the subnet length
+	aligned := new(big.Int).Add(start, new(big.Int).Sub(size, big.NewInt(1)))
+	aligned.Div(aligned, size)
+	aligned.Mul(aligned, size)
+
+	last := new(big.Int).Add(aligned, new(big.Int).Sub(size, big.NewInt(1)))
+	if last.Cmp(intOf(lastOf(p))) > 0 {
+		return netip.Prefix{}, fmt.Errorf("there is no room left for a /%d subnet in %s", bits, p)
+	}
+
+	return netip.PrefixFrom(addrOf(aligned, p.Addr().Is4()), bits), nil
+}
+
+func parse(prefix string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR prefix %s: %w", prefix, err)
+	}
+	return p.Masked(), nil
+}
+
+func lastOf(p netip.Prefix) netip.Addr {
+	size := new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-p.Bits()))
+	return addrOf(new(big.Int).Add(intOf(p.Masked().Addr()), size.Sub(size, big.NewInt(1))), p.Addr().Is4())
+}
+
+func intOf(addr netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(addr.AsSlice())
+}
+
+func addrOf(n *big.Int, is4 bool) netip.Addr {
+	if is4 {
+		return netip.AddrFrom4([4]byte(n.FillBytes(make([]byte, 4))))
+	}
+	return netip.AddrFrom16([16]byte(n.FillBytes(make([]byte, 16))))
+}
@@ -0,0 +1,108 @@
+package cidr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Host(t *testing.T) {
+
+	t.Run("We should be able to find a host inside a prefix", func(t *testing.T) {
+		host, err := Host("10.12.112.0/20", 16)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "10.12.112.16", host)
+	})
+
+	t.Run("We should be able to count hosts from the end of a prefix", func(t *testing.T) {
+		host, err := Host("10.12.112.0/20", -2)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "10.12.127.254", host)
+	})
+
+	t.Run("We should not find a host outside the prefix", func(t *testing.T) {
+		_, err := Host("10.0.0.0/30", 4)
+
+		assert.Error(t, err)
+	})
+}
+
+func Test_Netmask(t *testing.T) {
+
+	t.Run("We should be able to find the netmask of an IPv4 prefix", func(t *testing.T) {
+		netmask, err := Netmask("172.16.0.0/12")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "255.240.0.0", netmask)
+	})
+
+	t.Run("We should not find a netmask to an IPv6 prefix", func(t *testing.T) {
+		_, err := Netmask("fd00::/8")
+
+		assert.Error(t, err)
+	})
+}
+
+func Test_Subnet(t *testing.T) {
+
+	t.Run("We should be able to carve a subnet from an IPv4 prefix", func(t *testing.T) {
+		subnet, err := Subnet("172.16.0.0/12", 4, 2)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "172.18.0.0/16", subnet)
+	})
+
+	t.Run("We should be able to carve a subnet from an IPv6 prefix", func(t *testing.T) {
+		subnet, err := Subnet("fd00:fd12:3456:7890::/56", 16, 162)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "fd00:fd12:3456:7800:a200::/72", subnet)
+	})
+
+	t.Run("We should not carve a subnet that doesn't exist", func(t *testing.T) {
+		_, err := Subnet("10.0.0.0/16", 2, 4)
+
+		assert.Error(t, err)
+	})
+}
+
+func Test_Subnets(t *testing.T) {
+
+	t.Run("We should be able to carve consecutive subnets, aligned to their lengths", func(t *testing.T) {
+		subnets, err := Subnets("10.1.0.0/16", 4, 4, 8, 4)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"10.1.0.0/20", "10.1.16.0/20", "10.1.32.0/24", "10.1.48.0/20"}, subnets)
+	})
+
+	t.Run("We should not carve more subnets than the prefix holds", func(t *testing.T) {
+		_, err := Subnets("10.0.0.0/24", 1, 1, 1)
+
+		assert.Error(t, err)
+	})
+}
+
+func Test_NextFree(t *testing.T) {
+
+	t.Run("We should find the first subnet that isn't taken", func(t *testing.T) {
+		subnet, err := NextFree("10.0.0.0/16", 8, []string{"10.0.0.0/24", "10.0.2.0/23"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.1.0/24", subnet)
+
+		t.Run("...skipping over larger taken subnets", func(t *testing.T) {
+			subnet, err := NextFree("10.0.0.0/16", 8, []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/23"})
+
+			assert.NoError(t, err)
+			assert.Equal(t, "10.0.4.0/24", subnet)
+		})
+	})
+
+	t.Run("We should fail when the pool is exhausted", func(t *testing.T) {
+		_, err := NextFree("10.0.0.0/24", 1, []string{"10.0.0.0/25", "10.0.0.128/25"})
+
+		assert.Error(t, err)
+	})
+}
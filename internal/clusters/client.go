@@ -0,0 +1,92 @@
+package clusters
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// routedClient reads and writes klaudio objects (like ResourceRefs and Resources) in the local cluster, and any other
+// object in the remote one
+type routedClient struct {
+	client.Client
+	local client.Client
+}
+
+// NewRoutedClient returns a client to provisioners of Resources deployed to a remote cluster. Owner references to
+// klaudio objects are dropped from the objects written to the remote cluster, since its garbage collector would never
+// find their owners; those objects are deleted by the provisioners instead.
+func NewRoutedClient(local, remote client.Client) client.Client {
+	return &routedClient{Client: remote, local: local}
+}
+
+func (c *routedClient) targetOf(obj runtime.Object) client.Client {
+	gvk, err := apiutil.GVKForObject(obj, c.local.Scheme())
+	if err == nil && gvk.Group == resourcesv1alpha1.Group {
+		return c.local
+	}
+	return c.Client
+}
+
+func (c *routedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return c.targetOf(obj).Get(ctx, key, obj, opts...)
+}
+
+func (c *routedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.targetOf(list).List(ctx, list, opts...)
+}
+
+func (c *routedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	target := c.targetOf(obj)
+	if target != c.local {
+		dropLocalOwners(obj)
+	}
+	return target.Create(ctx, obj, opts...)
+}
+
+func (c *routedClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	target := c.targetOf(obj)
+	if target != c.local {
+		dropLocalOwners(obj)
+	}
+	return target.Update(ctx, obj, opts...)
+}
+
+func (c *routedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	target := c.targetOf(obj)
+	if target != c.local {
+		dropLocalOwners(obj)
+	}
+	return target.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *routedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return c.targetOf(obj).Delete(ctx, obj, opts...)
+}
+
+func (c *routedClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	return c.targetOf(obj).DeleteAllOf(ctx, obj, opts...)
+}
+
+func dropLocalOwners(obj client.Object) {
+	owners := obj.GetOwnerReferences()
+	if len(owners) == 0 {
+		return
+	}
+
+	remaining := make([]metav1.OwnerReference, 0, len(owners))
+	for _, owner := range owners {
+		gv, err := schema.ParseGroupVersion(owner.APIVersion)
+		if err == nil && gv.Group == resourcesv1alpha1.Group {
+			continue
+		}
+		remaining = append(remaining, owner)
+	}
+	obj.SetOwnerReferences(remaining)
+}
@@ -0,0 +1,60 @@
+package clusters
+
+import (
+	"context"
+	"testing"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_RoutedClient(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+
+	resource := &resourcesv1alpha1.Resource{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev.database", Namespace: "checkout"},
+	}
+
+	local := fake.NewClientBuilder().WithScheme(scheme).WithObjects(resource).Build()
+	remote := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	routed := NewRoutedClient(local, remote)
+
+	ctx := context.TODO()
+
+	t.Run("We should be able to read klaudio objects from the local cluster", func(t *testing.T) {
+		found := &resourcesv1alpha1.Resource{}
+		assert.NoError(t, routed.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: "dev.database"}, found))
+	})
+
+	t.Run("We should be able to create any other object on the remote cluster, without owner references to klaudio objects", func(t *testing.T) {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "dev.database",
+				Namespace: "checkout",
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: resourcesv1alpha1.GroupVersion.String(), Kind: "Resource", Name: "dev.database", UID: "uid-1"},
+					{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "uid-2"},
+				},
+			},
+		}
+		assert.NoError(t, routed.Create(ctx, configMap))
+
+		created := &corev1.ConfigMap{}
+		assert.NoError(t, remote.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: "dev.database"}, created))
+		assert.Len(t, created.OwnerReferences, 1)
+		assert.Equal(t, "owner", created.OwnerReferences[0].Name)
+
+		err := local.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: "dev.database"}, &corev1.ConfigMap{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+}
@@ -0,0 +1,368 @@
+// Package clusters connects to the remote clusters targeted by placements, so provisioners can create their objects
+// there instead of in the cluster running klaudio.
+package clusters
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/provisioning"
+)
+
+const (
+	// DefaultKubeconfigKey is the key of the kubeconfig inside its Secret, when the placement doesn't choose one
+	DefaultKubeconfigKey = "value"
+
+	// RequestTimeout bounds each request to a remote cluster, except for the watches of its cache
+	RequestTimeout = 30 * time.Second
+)
+
+// Remote is the connection to the cluster of a placement
+type Remote struct {
+	Cluster cluster.Cluster
+	// Client creates the provisioner objects on the remote cluster, and reads klaudio objects from the local one
+	Client        client.Client
+	DynamicClient *dynamic.DynamicClient
+	// WatchedKinds are the provisioner objects of the remote cluster whose changes trigger a reconciliation
+	WatchedKinds map[schema.GroupKind]bool
+
+	// version of the kubeconfig Secret the connection was made with
+	version string
+	stop    context.CancelFunc
+	done    <-chan struct{}
+}
+
+// Watch is a source of the changes to objects of the kind in the remote cluster, mapped to requests. It lives as long
+// as the connection: once it's closed, like when the kubeconfig is rotated, the handler is removed, and the hooks of
+// the new connection watch it instead.
+func (r *Remote) Watch(obj client.Object, mapFunc handler.MapFunc) source.Source {
+	return source.Func(func(ctx context.Context, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) error {
+		informer, err := r.Cluster.GetCache().GetInformer(ctx, obj)
+		if err != nil {
+			return err
+		}
+
+		enqueue := func(obj any) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if clientObj, ok := obj.(client.Object); ok {
+				for _, request := range mapFunc(ctx, clientObj) {
+					queue.Add(request)
+				}
+			}
+		}
+
+		registration, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+			AddFunc:    enqueue,
+			UpdateFunc: func(_, newObj any) { enqueue(newObj) },
+			DeleteFunc: enqueue,
+		})
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			select {
+			case <-r.done:
+			case <-ctx.Done():
+			}
+			_ = informer.RemoveEventHandler(registration)
+		}()
+
+		return nil
+	})
+}
+
+// EnsureNamespace creates the namespace on the remote cluster, when it doesn't exist there yet
+func (r *Remote) EnsureNamespace(ctx context.Context, name string) error {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{resourcesv1alpha1.Group + "/managedBy.group": resourcesv1alpha1.Group},
+		},
+	}
+	if err := r.Cluster.GetClient().Create(ctx, namespace); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to create namespace %s on the remote cluster: %w", name, err)
+	}
+	return nil
+}
+
+// ConnectHook is called with every new connection, before it's used
+type ConnectHook func(placement string, remote *Remote) error
+
+// Factory keeps a connection to the remote cluster of each placement, made again when its kubeconfig changes. It's a
+// manager.Runnable: connections are bound to the manager, and closed when it stops.
+type Factory struct {
+	client client.Client
+	scheme *runtime.Scheme
+
+	mu         sync.Mutex
+	ctx        context.Context
+	remotes    map[string]*Remote
+	connecting map[string]*connection
+	hooks      []ConnectHook
+}
+
+// connection is a connection being made to the cluster of a placement; callers asking for the same version of the
+// kubeconfig meanwhile wait for it, instead of connecting again
+type connection struct {
+	version string
+	done    chan struct{}
+	remote  *Remote
+	err     error
+}
+
+func NewFactory(c client.Client, scheme *runtime.Scheme) *Factory {
+	return &Factory{client: c, scheme: scheme, remotes: make(map[string]*Remote), connecting: make(map[string]*connection)}
+}
+
+// OnConnect registers a hook called with every new connection
+func (f *Factory) OnConnect(hook ConnectHook) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.hooks = append(f.hooks, hook)
+}
+
+// Start implements manager.Runnable
+func (f *Factory) Start(ctx context.Context) error {
+	f.mu.Lock()
+	f.ctx = ctx
+	f.mu.Unlock()
+
+	<-ctx.Done()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for placement, remote := range f.remotes {
+		remote.stop()
+		delete(f.remotes, placement)
+	}
+	clear(f.connecting)
+	return nil
+}
+
+// RemoteOf returns the connection to the remote cluster of the placement; nil means the Resources deployed to the
+// placement are provisioned in the local cluster
+func (f *Factory) RemoteOf(ctx context.Context, placement *resourcesv1alpha1.Placement) (*Remote, error) {
+	if placement.Spec.Cluster == nil || placement.Spec.Cluster.KubeconfigSecretRef == nil {
+		f.disconnect(placement.Name)
+		return nil, nil
+	}
+	secretRef := placement.Spec.Cluster.KubeconfigSecretRef
+
+	secret := &corev1.Secret{}
+	if err := f.client.Get(ctx, types.NamespacedName{Namespace: secretRef.Namespace, Name: secretRef.Name}, secret); err != nil {
+		return nil, fmt.Errorf("unable to read the kubeconfig of placement %s: %w", placement.Name, err)
+	}
+
+	f.mu.Lock()
+
+	if f.ctx == nil {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("unable to connect to the cluster of placement %s before the manager is started", placement.Name)
+	}
+
+	if remote, ok := f.remotes[placement.Name]; ok && remote.version == secret.ResourceVersion {
+		f.mu.Unlock()
+		return remote, nil
+	}
+
+	// connecting takes as long as syncing the cache of the remote cluster, so it's done without holding the lock;
+	// other placements are served meanwhile
+	if pending, ok := f.connecting[placement.Name]; ok && pending.version == secret.ResourceVersion {
+		f.mu.Unlock()
+
+		select {
+		case <-pending.done:
+			return pending.remote, pending.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	pending := &connection{version: secret.ResourceVersion, done: make(chan struct{})}
+	f.connecting[placement.Name] = pending
+	factoryCtx := f.ctx
+
+	f.mu.Unlock()
+
+	remote, err := f.connectWith(factoryCtx, placement, secret)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.connecting[placement.Name] != pending {
+		// disconnected, or connecting with a newer kubeconfig, meanwhile
+		if err == nil {
+			remote.stop()
+		}
+		remote, err = nil, fmt.Errorf("the connection to the cluster of placement %s was superseded", placement.Name)
+	} else {
+		delete(f.connecting, placement.Name)
+
+		if err == nil {
+			// the kubeconfig was rotated: the old connection is closed, along with the watches of its hooks
+			if previous, ok := f.remotes[placement.Name]; ok {
+				previous.stop()
+			}
+			f.remotes[placement.Name] = remote
+		}
+	}
+
+	pending.remote, pending.err = remote, err
+	close(pending.done)
+
+	return remote, err
+}
+
+// connectWith connects to the cluster of the placement with the kubeconfig of the Secret
+func (f *Factory) connectWith(ctx context.Context, placement *resourcesv1alpha1.Placement, secret *corev1.Secret) (*Remote, error) {
+	key := placement.Spec.Cluster.KubeconfigSecretRef.Key
+	if key == "" {
+		key = DefaultKubeconfigKey
+	}
+	kubeconfig, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("there is no kubeconfig in key %s of secret %s/%s, from placement %s", key, secret.Namespace, secret.Name, placement.Name)
+	}
+
+	remote, err := f.connect(ctx, placement.Name, kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to the cluster of placement %s: %w", placement.Name, err)
+	}
+	remote.version = secret.ResourceVersion
+
+	return remote, nil
+}
+
+func (f *Factory) connect(ctx context.Context, placement string, kubeconfig []byte) (*Remote, error) {
+	config, err := restConfigOf(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// requests time out, but the watches of the cache are long-lived, so it keeps the config without a timeout
+	withTimeout := rest.CopyConfig(config)
+	withTimeout.Timeout = RequestTimeout
+	httpClient, err := rest.HTTPClientFor(withTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteCluster, err := cluster.New(config, func(o *cluster.Options) {
+		o.Client.HTTPClient = httpClient
+		o.MapperProvider = func(c *rest.Config, _ *http.Client) (meta.RESTMapper, error) {
+			return apiutil.NewDynamicRESTMapper(c, httpClient)
+		}
+		o.Scheme = f.scheme
+		// only the watched provisioner objects are worth an informer, and only the ones created by provisioners; anything
+		// else is read straight from the API server
+		o.Client.Cache = &client.CacheOptions{DisableFor: []client.Object{&corev1.Secret{}, &corev1.ConfigMap{}, &corev1.Namespace{}}}
+		o.Cache.DefaultLabelSelector = provisioning.ControlledObjectsSelector
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := dynamic.NewForConfigAndClient(withTimeout, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	go func() {
+		if err := remoteCluster.Start(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "connection to remote cluster was lost", "placement", placement)
+		}
+	}()
+
+	if !remoteCluster.GetCache().WaitForCacheSync(ctx) {
+		stop()
+		return nil, fmt.Errorf("unable to sync the cache of the remote cluster")
+	}
+
+	remote := &Remote{
+		Cluster:       remoteCluster,
+		Client:        NewRoutedClient(f.client, remoteCluster.GetClient()),
+		DynamicClient: dynamicClient,
+		WatchedKinds:  make(map[schema.GroupKind]bool),
+		stop:          stop,
+		done:          ctx.Done(),
+	}
+
+	for _, hook := range f.hooks {
+		if err := hook(placement, remote); err != nil {
+			stop()
+			return nil, err
+		}
+	}
+
+	return remote, nil
+}
+
+// disconnect closes the connection to a placement that isn't remote anymore
+func (f *Factory) disconnect(placement string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if remote, ok := f.remotes[placement]; ok {
+		remote.stop()
+		delete(f.remotes, placement)
+	}
+	delete(f.connecting, placement)
+}
+
+// restConfigOf reads a kubeconfig from a Secret. The kubeconfig is written by whoever can write Secrets in the namespace,
+// but it's used by the controller, so it can't run commands (exec, auth providers) nor read files from the controller
+// filesystem; credentials must be inline.
+func restConfigOf(kubeconfig []byte) (*rest.Config, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, authInfo := range config.AuthInfos {
+		switch {
+		case authInfo.Exec != nil:
+			return nil, fmt.Errorf("user %s of the kubeconfig runs a command (exec), which isn't allowed", name)
+		case authInfo.AuthProvider != nil:
+			return nil, fmt.Errorf("user %s of the kubeconfig uses an auth provider, which isn't allowed", name)
+		case authInfo.TokenFile != "" || authInfo.ClientCertificate != "" || authInfo.ClientKey != "":
+			return nil, fmt.Errorf("user %s of the kubeconfig reads credentials from files, which isn't allowed", name)
+		}
+	}
+	for name, kubeCluster := range config.Clusters {
+		if kubeCluster.CertificateAuthority != "" {
+			return nil, fmt.Errorf("cluster %s of the kubeconfig reads its certificate authority from a file, which isn't allowed", name)
+		}
+	}
+
+	return clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}).ClientConfig()
+}
@@ -0,0 +1,48 @@
+package clusters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RestConfigOf(t *testing.T) {
+	kubeconfigWith := func(user string) []byte {
+		return []byte(`
+apiVersion: v1
+kind: Config
+clusters:
+- name: remote
+  cluster:
+    server: https://remote.example.com
+contexts:
+- name: remote
+  context:
+    cluster: remote
+    user: remote
+current-context: remote
+users:
+- name: remote
+  user:
+` + user)
+	}
+
+	t.Run("We should be able to read a kubeconfig with inline credentials", func(t *testing.T) {
+		config, err := restConfigOf(kubeconfigWith("    token: my-token\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, "https://remote.example.com", config.Host)
+		assert.Equal(t, "my-token", config.BearerToken)
+	})
+
+	t.Run("We should not accept a kubeconfig that runs commands or reads files", func(t *testing.T) {
+		for _, user := range []string{
+			"    exec:\n      apiVersion: client.authentication.k8s.io/v1\n      command: /bin/sh\n",
+			"    auth-provider:\n      name: oidc\n",
+			"    tokenFile: /var/run/secrets/kubernetes.io/serviceaccount/token\n",
+			"    client-certificate: /etc/tls/tls.crt\n    client-key: /etc/tls/tls.key\n",
+		} {
+			_, err := restConfigOf(kubeconfigWith(user))
+			assert.ErrorContains(t, err, "isn't allowed", user)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// approve holds a rendered deployment run in the PendingApproval phase, publishing its change set in status.plan,
+// until the generation is approved; it returns true when the run can be applied
+func (r *ResourceGroupDeploymentReconciler) approve(ctx context.Context, deployment *resourcesv1alpha1.ResourceGroupDeployment, resourceGroup *resources.ResourceGroup, dag []string, args *resources.ResourcePropertiesArgs, specOf resourceSpecRenderer) (bool, error) {
+	condition := meta.FindStatusCondition(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeApproved)
+	if condition != nil && condition.ObservedGeneration != deployment.Generation {
+		condition = nil
+	}
+
+	if deployment.Annotations[resourcesv1alpha1.ApprovedAnnotation] == strconv.FormatInt(deployment.Generation, 10) {
+		if condition != nil && condition.Status == metav1.ConditionTrue {
+			return true, nil
+		}
+
+		approvedBy := deployment.Annotations[resourcesv1alpha1.ApprovedByAnnotation]
+		if approvedBy == "" {
+			approvedBy = "unknown"
+		}
+
+		message := fmt.Sprintf("Generation %d of ResourceGroupDeployment %s was approved by %s", deployment.Generation, deployment.Name, approvedBy)
+		r.Recorder.Event(deployment, corev1.EventTypeNormal, resourcesv1alpha1.ConditionReasonApproved, message)
+
+		deployment.Status.Phase = resourcesv1alpha1.DeploymentInProgressPhase
+		_, err := r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+			Type:               resourcesv1alpha1.ConditionTypeApproved,
+			Status:             metav1.ConditionTrue,
+			Reason:             resourcesv1alpha1.ConditionReasonApproved,
+			ObservedGeneration: deployment.Generation,
+			Message:            message,
+		})
+		return err == nil, err
+	}
+
+	if condition != nil && condition.Status == metav1.ConditionFalse && deployment.Status.Phase == resourcesv1alpha1.DeploymentPendingApprovalPhase {
+		// still waiting
+		return false, nil
+	}
+
+	changes, err := r.changeSet(ctx, deployment, resourceGroup, dag, args, specOf, true)
+	if err != nil {
+		return false, err
+	}
+
+	message := fmt.Sprintf("Generation %d of ResourceGroupDeployment %s is waiting for approval; review status.plan and set the annotation %s=%d", deployment.Generation, deployment.Name, resourcesv1alpha1.ApprovedAnnotation, deployment.Generation)
+	r.Recorder.Event(deployment, corev1.EventTypeNormal, resourcesv1alpha1.ConditionReasonPendingApproval, message)
+
+	deployment.Status.Plan = &resourcesv1alpha1.ResourceGroupDeploymentPlan{
+		ObservedGeneration: deployment.Generation,
+		PlannedAt:          metav1.Now(),
+		Resources:          changes,
+	}
+	deployment.Status.Phase = resourcesv1alpha1.DeploymentPendingApprovalPhase
+	_, err = r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+		Type:               resourcesv1alpha1.ConditionTypeApproved,
+		Status:             metav1.ConditionFalse,
+		Reason:             resourcesv1alpha1.ConditionReasonPendingApproval,
+		ObservedGeneration: deployment.Generation,
+		Message:            message,
+	})
+
+	return false, err
+}
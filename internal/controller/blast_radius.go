@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// BlastRadiusApprovalAnnotation lets an operator approve a ResourceGroupDeployment run exceeding its blast radius;
+// the value is the approved generation, so any later change is guarded again
+const BlastRadiusApprovalAnnotation = resourcesv1alpha1.Group + "/approveBlastRadius"
+
+// exceededBlastRadius describes how a change set exceeds the policy; empty when it doesn't. Only deployed resources
+// count: an update, an Unknown change or a deletion may break them, while new resources don't touch anything yet.
+func exceededBlastRadius(policy *resourcesv1alpha1.BlastRadiusPolicy, changes []resourcesv1alpha1.ResourceGroupDeploymentPlannedResource) string {
+	if policy == nil || len(changes) == 0 {
+		return ""
+	}
+
+	changed := make([]string, 0)
+	for _, change := range changes {
+		if change.Action == resourcesv1alpha1.PlanActionUpdate || change.Action == resourcesv1alpha1.PlanActionUnknown || change.Action == resourcesv1alpha1.PlanActionDelete {
+			changed = append(changed, change.Name)
+		}
+	}
+
+	if policy.MaxResources != nil && len(changed) > int(*policy.MaxResources) {
+		return fmt.Sprintf("%d resources would be changed (%s), above the limit of %d", len(changed), strings.Join(changed, ", "), *policy.MaxResources)
+	}
+
+	if policy.MaxPercentage != nil && len(changed)*100 > int(*policy.MaxPercentage)*len(changes) {
+		return fmt.Sprintf("%d%% of the resources would be changed (%s), above the limit of %d%%", len(changed)*100/len(changes), strings.Join(changed, ", "), *policy.MaxPercentage)
+	}
+
+	return ""
+}
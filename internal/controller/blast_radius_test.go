@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/utils/ptr"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_BlastRadius(t *testing.T) {
+	changes := []resourcesv1alpha1.ResourceGroupDeploymentPlannedResource{
+		{Name: "sample.vpc", Action: resourcesv1alpha1.PlanActionNoChange},
+		{Name: "sample.subnet", Action: resourcesv1alpha1.PlanActionUpdate},
+		{Name: "sample.database", Action: resourcesv1alpha1.PlanActionUnknown},
+		{Name: "sample.cache", Action: resourcesv1alpha1.PlanActionCreate},
+	}
+
+	t.Run("We should accept any change set without a policy", func(t *testing.T) {
+		assert.Empty(t, exceededBlastRadius(nil, changes))
+	})
+
+	t.Run("We should limit the number of changed resources", func(t *testing.T) {
+		assert.Empty(t, exceededBlastRadius(&resourcesv1alpha1.BlastRadiusPolicy{MaxResources: ptr.To(int32(2))}, changes))
+		assert.Contains(t, exceededBlastRadius(&resourcesv1alpha1.BlastRadiusPolicy{MaxResources: ptr.To(int32(1))}, changes),
+			"2 resources would be changed (sample.subnet, sample.database)")
+	})
+
+	t.Run("We should limit the share of changed resources", func(t *testing.T) {
+		assert.Empty(t, exceededBlastRadius(&resourcesv1alpha1.BlastRadiusPolicy{MaxPercentage: ptr.To(int32(50))}, changes))
+		assert.Contains(t, exceededBlastRadius(&resourcesv1alpha1.BlastRadiusPolicy{MaxPercentage: ptr.To(int32(25))}, changes),
+			"50% of the resources would be changed")
+	})
+
+	t.Run("We should count pruned resources as changed", func(t *testing.T) {
+		withDeletion := append(changes, resourcesv1alpha1.ResourceGroupDeploymentPlannedResource{Name: "sample.queue", Action: resourcesv1alpha1.PlanActionDelete})
+
+		assert.Contains(t, exceededBlastRadius(&resourcesv1alpha1.BlastRadiusPolicy{MaxResources: ptr.To(int32(2))}, withDeletion),
+			"3 resources would be changed (sample.subnet, sample.database, sample.queue)")
+	})
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// missingCapabilitiesOf returns the capabilities required by the ResourceGroup, or by the ResourceRefs of its
+// resources, that this controller doesn't have
+func missingCapabilitiesOf(ctx context.Context, refs *ResourceRefs, c client.Client, resourceGroup *resourcesv1alpha1.ResourceGroup, resources []resourcesv1alpha1.ResourceGroupElement) ([]resourcesv1alpha1.Capability, error) {
+	required := append([]resourcesv1alpha1.Capability{}, resourceGroup.Spec.Requires...)
+
+	for _, element := range resources {
+		resourceRef, err := resourceRefOf(ctx, refs, c, element.ResourceRef)
+		if err != nil {
+			// a missing ResourceRef is reported later, by the deployment
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		required = append(required, resourceRef.Spec.Requires...)
+	}
+
+	return dedupCapabilities(resourcesv1alpha1.UnsupportedCapabilities(required)), nil
+}
+
+func dedupCapabilities(capabilities []resourcesv1alpha1.Capability) []resourcesv1alpha1.Capability {
+	seen := make(map[resourcesv1alpha1.Capability]bool)
+	deduped := make([]resourcesv1alpha1.Capability, 0, len(capabilities))
+	for _, capability := range capabilities {
+		if !seen[capability] {
+			seen[capability] = true
+			deduped = append(deduped, capability)
+		}
+	}
+	return deduped
+}
+
+// unsupportedCondition is kept while an object requires capabilities this controller doesn't have; nothing else is
+// done with the object until a newer controller takes it
+func unsupportedCondition(kind string, name string, missing []resourcesv1alpha1.Capability) *metav1.Condition {
+	names := make([]string, 0, len(missing))
+	for _, capability := range missing {
+		names = append(names, string(capability))
+	}
+	return &metav1.Condition{
+		Type:    resourcesv1alpha1.ConditionTypeUnsupported,
+		Status:  metav1.ConditionTrue,
+		Reason:  resourcesv1alpha1.ConditionReasonCapabilitiesMissing,
+		Message: fmt.Sprintf("%s %s requires capabilities unsupported by this controller: %s", kind, name, strings.Join(names, ", ")),
+	}
+}
+
+func supportedCondition(kind string, name string) *metav1.Condition {
+	return &metav1.Condition{
+		Type:    resourcesv1alpha1.ConditionTypeUnsupported,
+		Status:  metav1.ConditionFalse,
+		Reason:  resourcesv1alpha1.ConditionReasonCapabilitiesSupported,
+		Message: fmt.Sprintf("Every capability required by %s %s is supported", kind, name),
+	}
+}
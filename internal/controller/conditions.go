@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// setStatusCondition sets a condition like meta.SetStatusCondition, compacting the conditions on the way: the ones of
+// types no longer written (from older versions) are dropped, and only the newest condition of each type is kept, so
+// the list never grows past resourcesv1alpha1.ConditionTypes. It returns true when the conditions changed.
+func setStatusCondition(conditions *[]metav1.Condition, condition metav1.Condition) bool {
+	compacted := compactConditions(*conditions)
+	changed := len(compacted) != len(*conditions)
+
+	if meta.SetStatusCondition(&compacted, condition) {
+		changed = true
+	}
+
+	*conditions = compacted
+	return changed
+}
+
+// compactConditions drops the conditions of unknown types, and the older duplicates of each type
+func compactConditions(conditions []metav1.Condition) []metav1.Condition {
+	compacted := make([]metav1.Condition, 0, len(conditions))
+
+	for _, condition := range conditions {
+		if !slices.Contains(resourcesv1alpha1.ConditionTypes, condition.Type) {
+			continue
+		}
+
+		i := slices.IndexFunc(compacted, func(c metav1.Condition) bool { return c.Type == condition.Type })
+		if i == -1 {
+			compacted = append(compacted, condition)
+			continue
+		}
+		if condition.LastTransitionTime.After(compacted[i].LastTransitionTime.Time) {
+			compacted[i] = condition
+		}
+	}
+
+	return compacted
+}
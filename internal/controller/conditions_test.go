@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("Status conditions", func() {
+	Context("When a condition is set on a long-lived status", func() {
+		It("should drop obsolete conditions and keep only the newest one of each type", func() {
+			older := metav1.NewTime(time.Now().Add(-time.Hour))
+			newer := metav1.NewTime(time.Now().Add(-time.Minute))
+
+			conditions := []metav1.Condition{
+				{Type: "Deploying", Status: metav1.ConditionTrue, Reason: "Reconciling", LastTransitionTime: older},
+				{Type: resourcesv1alpha1.ConditionTypeDrifted, Status: metav1.ConditionTrue, Reason: resourcesv1alpha1.ConditionReasonDriftDetected, LastTransitionTime: older},
+				{Type: resourcesv1alpha1.ConditionTypeDrifted, Status: metav1.ConditionFalse, Reason: resourcesv1alpha1.ConditionReasonDriftCorrecting, LastTransitionTime: newer},
+			}
+
+			changed := setStatusCondition(&conditions, metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeReady,
+				Status:  metav1.ConditionTrue,
+				Reason:  resourcesv1alpha1.ConditionReasonDeploymentDone,
+				Message: "done",
+			})
+			Expect(changed).To(BeTrue())
+
+			Expect(conditions).To(HaveLen(2))
+			Expect(meta.FindStatusCondition(conditions, "Deploying")).To(BeNil())
+			Expect(meta.FindStatusCondition(conditions, resourcesv1alpha1.ConditionTypeDrifted).Reason).To(Equal(resourcesv1alpha1.ConditionReasonDriftCorrecting))
+			Expect(meta.IsStatusConditionTrue(conditions, resourcesv1alpha1.ConditionTypeReady)).To(BeTrue())
+
+			By("setting the same condition again")
+			changed = setStatusCondition(&conditions, metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeReady,
+				Status:  metav1.ConditionTrue,
+				Reason:  resourcesv1alpha1.ConditionReasonDeploymentDone,
+				Message: "done",
+			})
+			Expect(changed).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// failureReasonOf classifies an error into one of the failure reasons; errors without a clear class get the
+// fallback, the class of the operation that failed
+func failureReasonOf(err error, fallback string) string {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		return resourcesv1alpha1.ConditionReasonTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return resourcesv1alpha1.ConditionReasonTimeout
+	case apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		return resourcesv1alpha1.ConditionReasonConflict
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return resourcesv1alpha1.ConditionReasonInputError
+	}
+
+	return fallback
+}
+
+// failureReasonOfResources is the reason a deployment failed: the one of its first failed Resource, which has
+// already been classified
+func failureReasonOfResources(statuses resourcesv1alpha1.ResourceGroupDeploymentResourcesStatuses) string {
+	for _, name := range slices.Sorted(maps.Keys(statuses)) {
+		status := statuses[name]
+		if status.Phase != resourcesv1alpha1.DeploymentFailedPhase {
+			continue
+		}
+		if condition := meta.FindStatusCondition(status.Conditions, resourcesv1alpha1.ConditionTypeFailed); condition != nil && slices.Contains(resourcesv1alpha1.FailureReasons, condition.Reason) {
+			return condition.Reason
+		}
+	}
+
+	return resourcesv1alpha1.ConditionReasonProvisionerError
+}
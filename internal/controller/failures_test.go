@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("Failure reasons", func() {
+	Context("When an operation fails", func() {
+		It("should classify the error, or use the class of the operation", func() {
+			namespaces := schema.GroupResource{Resource: "namespaces"}
+
+			Expect(failureReasonOf(fmt.Errorf("provisioner: %w", context.DeadlineExceeded), resourcesv1alpha1.ConditionReasonProvisionerError)).
+				To(Equal(resourcesv1alpha1.ConditionReasonTimeout))
+			Expect(failureReasonOf(apierrors.NewAlreadyExists(namespaces, "checkout"), resourcesv1alpha1.ConditionReasonDependencyNotReady)).
+				To(Equal(resourcesv1alpha1.ConditionReasonConflict))
+			Expect(failureReasonOf(apierrors.NewBadRequest("invalid"), resourcesv1alpha1.ConditionReasonProvisionerError)).
+				To(Equal(resourcesv1alpha1.ConditionReasonInputError))
+			Expect(failureReasonOf(errors.New("terraform apply failed"), resourcesv1alpha1.ConditionReasonProvisionerError)).
+				To(Equal(resourcesv1alpha1.ConditionReasonProvisionerError))
+		})
+	})
+
+	Context("When a Resource of a deployment fails", func() {
+		It("should report the failure class of the Resource", func() {
+			statuses := resourcesv1alpha1.ResourceGroupDeploymentResourcesStatuses{
+				"checkout.prod.bucket": {Phase: resourcesv1alpha1.DeploymentDonePhase},
+				"checkout.prod.database": {
+					Phase: resourcesv1alpha1.DeploymentFailedPhase,
+					Conditions: []metav1.Condition{{
+						Type:   resourcesv1alpha1.ConditionTypeFailed,
+						Status: metav1.ConditionFalse,
+						Reason: resourcesv1alpha1.ConditionReasonTimeout,
+					}},
+				},
+			}
+			Expect(failureReasonOfResources(statuses)).To(Equal(resourcesv1alpha1.ConditionReasonTimeout))
+
+			statuses["checkout.prod.database"].Conditions[0].Reason = resourcesv1alpha1.ConditionReasonDeploymentFailed
+			Expect(failureReasonOfResources(statuses)).To(Equal(resourcesv1alpha1.ConditionReasonProvisionerError))
+		})
+	})
+})
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// GraphFormatAnnotation chooses how the dependency graph is rendered in the deployment status: mermaid (the default)
+// or dot
+const GraphFormatAnnotation = resourcesv1alpha1.Group + "/graphFormat"
+
+// renderGraph renders the dependency graph of the deployment; unknown formats fall back to Mermaid.
+func renderGraph(deployment *resourcesv1alpha1.ResourceGroupDeployment, resourceGroup *resources.ResourceGroup) (*resourcesv1alpha1.ResourceGroupDeploymentGraph, error) {
+	if resourcesv1alpha1.DeploymentGraphFormat(deployment.Annotations[GraphFormatAnnotation]) == resourcesv1alpha1.DeploymentGraphDOT {
+		rendered, err := resourceGroup.DOT()
+		if err != nil {
+			return nil, err
+		}
+		return &resourcesv1alpha1.ResourceGroupDeploymentGraph{Format: resourcesv1alpha1.DeploymentGraphDOT, Rendered: rendered}, nil
+	}
+
+	rendered, err := resourceGroup.Mermaid()
+	if err != nil {
+		return nil, err
+	}
+	return &resourcesv1alpha1.ResourceGroupDeploymentGraph{Format: resourcesv1alpha1.DeploymentGraphMermaid, Rendered: rendered}, nil
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+var _ = Describe("Dependency graph", func() {
+	Context("When rendering the graph of a deployment", func() {
+		newResourceGroup := func() *resources.ResourceGroup {
+			resourceGroup := resources.NewResourceGroup()
+			_, err := resourceGroup.NewResource("database", &runtime.RawExtension{Raw: []byte(`{"name":"sample"}`)})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = resourceGroup.NewResource("app", &runtime.RawExtension{Raw: []byte(`{"database":"${resources.database.status.outputs.name}"}`)})
+			Expect(err).NotTo(HaveOccurred())
+			return resourceGroup
+		}
+
+		It("should render a Mermaid flowchart by default", func() {
+			graph, err := renderGraph(&resourcesv1alpha1.ResourceGroupDeployment{}, newResourceGroup())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(graph.Format).To(Equal(resourcesv1alpha1.DeploymentGraphMermaid))
+			Expect(graph.Rendered).To(ContainSubstring("resources_database --> resources_app"))
+		})
+
+		It("should render the DOT language when requested by the annotation", func() {
+			deployment := &resourcesv1alpha1.ResourceGroupDeployment{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{GraphFormatAnnotation: "dot"}},
+			}
+
+			graph, err := renderGraph(deployment, newResourceGroup())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(graph.Format).To(Equal(resourcesv1alpha1.DeploymentGraphDOT))
+			Expect(graph.Rendered).To(ContainSubstring(`"resources.database" -> "resources.app";`))
+		})
+	})
+})
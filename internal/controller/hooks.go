@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/outputs"
+)
+
+const (
+	preDestroyHook  = "pre-destroy"
+	postDestroyHook = "post-destroy"
+)
+
+// destroyHooksOf returns the hooks run by the teardown of the Resource; they're skipped when the infrastructure is
+// left behind, by its deletion policy or its protection
+func destroyHooksOf(resource *resourcesv1alpha1.Resource) *resourcesv1alpha1.ResourceHooks {
+	policy := resource.Spec.DeletionPolicy
+	if resource.Spec.Protect || (policy != "" && policy != resourcesv1alpha1.DeletionPolicyDelete) {
+		return nil
+	}
+	return resource.Spec.Hooks
+}
+
+// hookJobName is the name of the Job running a hook of the Resource
+func hookJobName(resource *resourcesv1alpha1.Resource, hook string) string {
+	return fmt.Sprintf("%s-%s", resource.Name, hook)
+}
+
+// runHook runs a hook of the teardown, once. It returns no condition when the teardown can carry on: the Job
+// succeeded, or failed with the Warn policy; otherwise, the condition to report: in progress while the Job runs,
+// failed when it failed with the Block policy. Outputs are read from the output store, since the provisioner may be
+// destroying the infrastructure they came from.
+func (r *ResourceReconciler) runHook(ctx context.Context, resource *resourcesv1alpha1.Resource, store outputs.Store, hook string, spec *resourcesv1alpha1.ResourceHook) (*metav1.Condition, error) {
+	log := log.FromContext(ctx).WithValues("resource", resource.Name, "hook", hook)
+
+	name := hookJobName(resource, hook)
+
+	job := &batchv1.Job{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: resource.Namespace, Name: name}, job); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
+		resourceOutputs, err := store.Load(ctx, resource)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the outputs to the %s hook: %w", hook, err)
+		}
+
+		secret, err := r.outputsSecret(ctx, resource, fmt.Sprintf("%s-hooks", resource.Name), resourceOutputs)
+		if err != nil {
+			return nil, fmt.Errorf("unable to write the outputs to the %s hook: %w", hook, err)
+		}
+
+		job = newHookJob(resource, name, hook, spec, secret.Name)
+		if err := ctrl.SetControllerReference(resource, job, r.Scheme); err != nil {
+			return nil, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return nil, fmt.Errorf("unable to create the %s hook Job %s: %w", hook, name, err)
+		}
+
+		log.Info(fmt.Sprintf("Running %s hook of Resource %s through Job %s...", hook, resource.Name, name))
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return nil, nil
+
+		case batchv1.JobFailed:
+			if spec.FailurePolicy == resourcesv1alpha1.HookFailurePolicyWarn {
+				log.Info(fmt.Sprintf("%s hook Job %s failed: %s; carrying on with the teardown", hook, name, condition.Message))
+				return nil, nil
+			}
+			return &metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeFailed,
+				Status:  metav1.ConditionFalse,
+				Reason:  resourcesv1alpha1.ConditionReasonHookFailed,
+				Message: fmt.Sprintf("The %s hook Job %s of Resource %s failed: %s; delete the Job to run it again", hook, name, resource.Name, condition.Message),
+			}, nil
+		}
+	}
+
+	return &metav1.Condition{
+		Type:    resourcesv1alpha1.ConditionTypeInProgress,
+		Status:  metav1.ConditionTrue,
+		Reason:  resourcesv1alpha1.ConditionReasonRunningHook,
+		Message: fmt.Sprintf("Resource %s is being destroyed; waiting for the %s hook Job %s...", resource.Name, hook, name),
+	}, nil
+}
+
+// holdTeardown reports the hook holding the teardown; the Resource is reconciled again when the Job changes
+func (r *ResourceReconciler) holdTeardown(ctx context.Context, resource *resourcesv1alpha1.Resource, condition *metav1.Condition) (ctrl.Result, error) {
+	phase := resourcesv1alpha1.DeploymentInProgressPhase
+	if condition.Type == resourcesv1alpha1.ConditionTypeFailed {
+		phase = resourcesv1alpha1.DeploymentFailedPhase
+	}
+
+	changed := resource.Status.Phase != phase
+	resource.Status.Phase = phase
+	if setStatusCondition(&resource.Status.Conditions, *condition) || changed {
+		if err := r.Status().Update(ctx, resource); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+func newHookJob(resource *resourcesv1alpha1.Resource, name string, hook string, spec *resourcesv1alpha1.ResourceHook, secretName string) *batchv1.Job {
+	env := make([]corev1.EnvVar, 0, len(spec.Env))
+	for _, name := range slices.Sorted(maps.Keys(spec.Env)) {
+		env = append(env, corev1.EnvVar{Name: name, Value: spec.Env[name]})
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: resource.Namespace,
+			Name:      name,
+			Labels: map[string]string{
+				resourcesv1alpha1.Group + "/managedBy.name": resource.Name,
+				resourcesv1alpha1.Group + "/placement":      resource.Spec.Placement,
+				resourcesv1alpha1.Group + "/hook":           hook,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          ptr.To(ptr.Deref(spec.BackoffLimit, 0)),
+			ActiveDeadlineSeconds: spec.ActiveDeadlineSeconds,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    hook,
+							Image:   spec.Image,
+							Command: spec.Command,
+							Args:    spec.Args,
+							Env:     env,
+							EnvFrom: []corev1.EnvFromSource{
+								{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: secretName}}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("Hooks", func() {
+	Context("When a Resource has destroy hooks", func() {
+		resource := &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "database", Namespace: "checkout"},
+			Spec: resourcesv1alpha1.ResourceSpec{
+				Placement: "prod",
+				Hooks: &resourcesv1alpha1.ResourceHooks{
+					PreDestroy: &resourcesv1alpha1.ResourceHook{
+						Image:   "amazon/aws-cli:2.17.0",
+						Command: []string{"aws", "rds", "create-db-snapshot"},
+						Env:     map[string]string{"AWS_REGION": "us-east-1"},
+					},
+					PostDestroy: &resourcesv1alpha1.ResourceHook{
+						Image:         "registry.example.com/dns-cleanup:1.0",
+						FailurePolicy: resourcesv1alpha1.HookFailurePolicyWarn,
+					},
+				},
+			},
+		}
+
+		It("should only run them when the infrastructure is destroyed", func() {
+			Expect(destroyHooksOf(resource)).To(Equal(resource.Spec.Hooks))
+
+			deleted := resource.DeepCopy()
+			deleted.Spec.DeletionPolicy = resourcesv1alpha1.DeletionPolicyDelete
+			Expect(destroyHooksOf(deleted)).To(Equal(resource.Spec.Hooks))
+
+			orphaned := resource.DeepCopy()
+			orphaned.Spec.DeletionPolicy = resourcesv1alpha1.DeletionPolicyOrphan
+			Expect(destroyHooksOf(orphaned)).To(BeNil())
+
+			retained := resource.DeepCopy()
+			retained.Spec.DeletionPolicy = resourcesv1alpha1.DeletionPolicyRetain
+			Expect(destroyHooksOf(retained)).To(BeNil())
+		})
+
+		It("should run each hook once, without retries by default", func() {
+			name := hookJobName(resource, preDestroyHook)
+			Expect(name).To(Equal("database-pre-destroy"))
+
+			job := newHookJob(resource, name, preDestroyHook, resource.Spec.Hooks.PreDestroy, "database-hooks")
+			Expect(*job.Spec.BackoffLimit).To(Equal(int32(0)))
+			Expect(job.Labels).To(HaveKeyWithValue(resourcesv1alpha1.Group+"/hook", preDestroyHook))
+
+			Expect(job.Spec.Template.Spec.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+			Expect(job.Spec.Template.Spec.Containers).To(HaveLen(1))
+
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(container.Name).To(Equal(preDestroyHook))
+			Expect(container.Image).To(Equal("amazon/aws-cli:2.17.0"))
+			Expect(container.Env).To(Equal([]corev1.EnvVar{{Name: "AWS_REGION", Value: "us-east-1"}}))
+			Expect(container.EnvFrom[0].SecretRef.Name).To(Equal("database-hooks"))
+		})
+	})
+})
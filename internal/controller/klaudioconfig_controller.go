@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/provisioning"
+)
+
+// KlaudioConfigReconciler applies the feature gates of the KlaudioConfig of the installation
+type KlaudioConfigReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=klaudioconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=klaudioconfigs/status,verbs=get;update;patch
+
+// Reconcile applies the feature gates of the KlaudioConfig named klaudio; without it, every provisioner is enabled.
+func (r *KlaudioConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("klaudioConfig", req.Name)
+
+	klaudioConfig := &resourcesv1alpha1.KlaudioConfig{}
+	if err := r.Get(ctx, req.NamespacedName, klaudioConfig); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		if req.Name == resourcesv1alpha1.KlaudioConfigName {
+			log.Info(fmt.Sprintf("KlaudioConfig %s was removed; enabling every provisioner", req.Name))
+			provisioning.SetDisabledProvisioners()
+		}
+		return ctrl.Result{}, nil
+	}
+
+	condition := metav1.Condition{
+		Type:               resourcesv1alpha1.ConditionTypeReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             resourcesv1alpha1.ConditionReasonConfigApplied,
+		ObservedGeneration: klaudioConfig.Generation,
+	}
+
+	var disabled []string
+	switch {
+	case klaudioConfig.Name != resourcesv1alpha1.KlaudioConfigName:
+		log.Info(fmt.Sprintf("KlaudioConfig %s is ignored; only %s is read", klaudioConfig.Name, resourcesv1alpha1.KlaudioConfigName))
+
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = resourcesv1alpha1.ConditionReasonConfigIgnored
+		condition.Message = fmt.Sprintf("Only the KlaudioConfig named %s configures the installation", resourcesv1alpha1.KlaudioConfigName)
+
+	case !klaudioConfig.DeletionTimestamp.IsZero():
+		provisioning.SetDisabledProvisioners()
+		return ctrl.Result{}, nil
+
+	default:
+		disabled = disabledProvisionersOf(klaudioConfig)
+		provisioning.SetDisabledProvisioners(disabled...)
+
+		condition.Message = fmt.Sprintf("Disabled provisioners: %v", disabled)
+		log.Info(fmt.Sprintf("KlaudioConfig %s applied; disabled provisioners: %v", klaudioConfig.Name, disabled))
+	}
+
+	changed := setStatusCondition(&klaudioConfig.Status.Conditions, condition)
+	if !slices.Equal(klaudioConfig.Status.DisabledProvisioners, disabled) {
+		klaudioConfig.Status.DisabledProvisioners = disabled
+		changed = true
+	}
+
+	if changed {
+		if err := r.Status().Update(ctx, klaudioConfig); err != nil {
+			log.Error(err, "unable to update KlaudioConfig's status")
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// disabledProvisionersOf returns the names of the provisioners disabled by the feature gates, sorted
+func disabledProvisionersOf(klaudioConfig *resourcesv1alpha1.KlaudioConfig) []string {
+	disabled := make([]string, 0)
+	for name, enabled := range klaudioConfig.Spec.FeatureGates.Provisioners {
+		if !enabled {
+			disabled = append(disabled, name)
+		}
+	}
+	slices.Sort(disabled)
+	return disabled
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KlaudioConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&resourcesv1alpha1.KlaudioConfig{}).
+		Complete(r)
+}
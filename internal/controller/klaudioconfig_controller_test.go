@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/provisioning"
+)
+
+var _ = Describe("KlaudioConfig Controller", func() {
+	Context("When the KlaudioConfig disables provisioners", func() {
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{Name: resourcesv1alpha1.KlaudioConfigName}
+
+		BeforeEach(func() {
+			klaudioConfig := &resourcesv1alpha1.KlaudioConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: resourcesv1alpha1.KlaudioConfigName},
+				Spec: resourcesv1alpha1.KlaudioConfigSpec{
+					FeatureGates: resourcesv1alpha1.KlaudioFeatureGates{
+						Provisioners: map[string]bool{"pulumi": false, "helm": false, "opentofu": true},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, klaudioConfig)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			klaudioConfig := &resourcesv1alpha1.KlaudioConfig{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, klaudioConfig)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, klaudioConfig)).To(Succeed())
+
+			provisioning.SetDisabledProvisioners()
+		})
+
+		It("should refuse the disabled provisioners", func() {
+			controllerReconciler := &KlaudioConfigReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(provisioning.IsProvisionerDisabled("pulumi")).To(BeTrue())
+			Expect(provisioning.IsProvisionerDisabled("helm")).To(BeTrue())
+			Expect(provisioning.IsProvisionerDisabled("opentofu")).To(BeFalse())
+
+			klaudioConfig := &resourcesv1alpha1.KlaudioConfig{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, klaudioConfig)).To(Succeed())
+			Expect(klaudioConfig.Status.DisabledProvisioners).To(Equal([]string{"helm", "pulumi"}))
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: "unknown"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provisioning.IsProvisionerDisabled("pulumi")).To(BeTrue())
+		})
+	})
+})
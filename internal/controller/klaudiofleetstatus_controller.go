@@ -0,0 +1,230 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// fleetPendingPhase counts the objects that don't have a phase yet
+const fleetPendingPhase = "Pending"
+
+// KlaudioFleetStatusReconciler keeps the KlaudioFleetStatus of the installation, summarizing every ResourceGroup
+type KlaudioFleetStatusReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=klaudiofleetstatuses,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=klaudiofleetstatuses/status,verbs=get;update;patch
+
+// Reconcile summarizes the ResourceGroups, their deployments and Resources into the KlaudioFleetStatus named klaudio,
+// creating it when it doesn't exist.
+func (r *KlaudioFleetStatusReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("klaudioFleetStatus", req.Name)
+
+	if req.Name != resourcesv1alpha1.KlaudioFleetStatusName {
+		log.Info(fmt.Sprintf("KlaudioFleetStatus %s is ignored; only %s is kept", req.Name, resourcesv1alpha1.KlaudioFleetStatusName))
+		return ctrl.Result{}, nil
+	}
+
+	fleetStatus := &resourcesv1alpha1.KlaudioFleetStatus{}
+	if err := r.Get(ctx, req.NamespacedName, fleetStatus); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		fleetStatus = &resourcesv1alpha1.KlaudioFleetStatus{ObjectMeta: metav1.ObjectMeta{Name: resourcesv1alpha1.KlaudioFleetStatusName}}
+		if err := r.Create(ctx, fleetStatus); err != nil {
+			return ctrl.Result{}, client.IgnoreAlreadyExists(err)
+		}
+	}
+
+	if !fleetStatus.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	resourceGroups := &resourcesv1alpha1.ResourceGroupList{}
+	if err := r.List(ctx, resourceGroups); err != nil {
+		return ctrl.Result{}, err
+	}
+	deployments := &resourcesv1alpha1.ResourceGroupDeploymentList{}
+	if err := r.List(ctx, deployments); err != nil {
+		return ctrl.Result{}, err
+	}
+	resources := &resourcesv1alpha1.ResourceList{}
+	if err := r.List(ctx, resources); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	limit := resourcesv1alpha1.DefaultFleetStatusLimit
+	if fleetStatus.Spec.Limit != nil {
+		limit = int(*fleetStatus.Spec.Limit)
+	}
+
+	status := summarizeFleet(resourceGroups.Items, deployments.Items, resources.Items, limit)
+
+	// the summary is only written when something changed, so watching it isn't noisy
+	previous := fleetStatus.Status.DeepCopy()
+	previous.UpdatedAt = nil
+	if equality.Semantic.DeepEqual(previous, &status) {
+		return ctrl.Result{}, nil
+	}
+
+	now := metav1.Now()
+	status.UpdatedAt = &now
+	fleetStatus.Status = status
+	if err := r.Status().Update(ctx, fleetStatus); err != nil {
+		log.Error(err, "unable to update KlaudioFleetStatus's status")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// summarizeFleet counts the objects of the fleet by phase and lists the ones needing attention, up to the limit
+func summarizeFleet(resourceGroups []resourcesv1alpha1.ResourceGroup, deployments []resourcesv1alpha1.ResourceGroupDeployment, resources []resourcesv1alpha1.Resource, limit int) resourcesv1alpha1.KlaudioFleetStatusStatus {
+	status := resourcesv1alpha1.KlaudioFleetStatusStatus{
+		ResourceGroups: make(map[string]int32),
+		Deployments:    make(map[string]int32),
+		Resources:      make(map[string]int32),
+	}
+
+	for _, resourceGroup := range resourceGroups {
+		status.ResourceGroups[fleetPhaseOf(resourceGroup.Status.Phase)]++
+	}
+
+	failed := make(map[string][]string)
+	inProgress := make([]resourcesv1alpha1.KlaudioFleetObject, 0)
+	for _, deployment := range deployments {
+		phase := fleetPhaseOf(deployment.Status.Phase)
+		status.Deployments[phase]++
+
+		switch resourcesv1alpha1.DeploymentPhase(phase) {
+		case resourcesv1alpha1.DeploymentFailedPhase:
+			failed[deployment.Spec.Placement] = append(failed[deployment.Spec.Placement], client.ObjectKeyFromObject(&deployment).String())
+
+		case resourcesv1alpha1.DeploymentInProgressPhase:
+			since := deployment.CreationTimestamp
+			if condition := meta.FindStatusCondition(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeInProgress); condition != nil && condition.Status == metav1.ConditionTrue {
+				since = condition.LastTransitionTime
+			}
+			inProgress = append(inProgress, resourcesv1alpha1.KlaudioFleetObject{
+				Namespace: deployment.Namespace,
+				Name:      deployment.Name,
+				Placement: deployment.Spec.Placement,
+				Since:     since,
+			})
+		}
+	}
+
+	drifted := make([]resourcesv1alpha1.KlaudioFleetObject, 0)
+	for _, resource := range resources {
+		status.Resources[fleetPhaseOf(resource.Status.Phase)]++
+
+		if condition := meta.FindStatusCondition(resource.Status.Conditions, resourcesv1alpha1.ConditionTypeDrifted); condition != nil && condition.Status == metav1.ConditionTrue {
+			drifted = append(drifted, resourcesv1alpha1.KlaudioFleetObject{
+				Namespace: resource.Namespace,
+				Name:      resource.Name,
+				Placement: resource.Spec.Placement,
+				Since:     condition.LastTransitionTime,
+			})
+		}
+	}
+
+	for _, placement := range slices.Sorted(maps.Keys(failed)) {
+		slices.Sort(failed[placement])
+		status.FailingPlacements = append(status.FailingPlacements, resourcesv1alpha1.KlaudioFleetFailingPlacement{
+			Placement:   placement,
+			Deployments: failed[placement],
+		})
+	}
+	// placements with more failures first; sorted by name before, so ties keep the order of names
+	slices.SortStableFunc(status.FailingPlacements, func(a, b resourcesv1alpha1.KlaudioFleetFailingPlacement) int {
+		return cmp.Compare(len(b.Deployments), len(a.Deployments))
+	})
+	status.FailingPlacements = firstOf(status.FailingPlacements, limit)
+
+	status.DriftedResourcesCount = int32(len(drifted))
+	status.DriftedResources = firstOf(oldestFirst(drifted), limit)
+
+	status.InProgressDeploymentsCount = int32(len(inProgress))
+	status.OldestInProgressDeployments = firstOf(oldestFirst(inProgress), limit)
+
+	return status
+}
+
+// fleetPhaseOf is the phase an object is counted in; phases written by older versions are counted in the current ones
+func fleetPhaseOf(phase resourcesv1alpha1.DeploymentPhase) string {
+	if phase == "" {
+		return fleetPendingPhase
+	}
+	normalized, _ := resourcesv1alpha1.NormalizeDeploymentPhase(string(phase))
+	return string(normalized)
+}
+
+func oldestFirst(objects []resourcesv1alpha1.KlaudioFleetObject) []resourcesv1alpha1.KlaudioFleetObject {
+	slices.SortFunc(objects, func(a, b resourcesv1alpha1.KlaudioFleetObject) int {
+		return cmp.Or(
+			a.Since.Compare(b.Since.Time),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+	return objects
+}
+
+// firstOf keeps up to the limit of items; nil when there are none, so empty lists are left out of the status
+func firstOf[T any](items []T, limit int) []T {
+	if len(items) == 0 {
+		return nil
+	}
+	return items[:min(len(items), limit)]
+}
+
+// SetupWithManager sets up the controller with the Manager; any change to a ResourceGroup, a deployment or a Resource
+// summarizes the fleet again.
+func (r *KlaudioFleetStatusReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	toFleetStatus := handler.EnqueueRequestsFromMapFunc(func(_ context.Context, _ client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: resourcesv1alpha1.KlaudioFleetStatusName}}}
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&resourcesv1alpha1.KlaudioFleetStatus{}).
+		Watches(&resourcesv1alpha1.ResourceGroup{}, toFleetStatus).
+		Watches(&resourcesv1alpha1.ResourceGroupDeployment{}, toFleetStatus).
+		Watches(&resourcesv1alpha1.Resource{}, toFleetStatus).
+		Complete(r)
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("KlaudioFleetStatus Controller", func() {
+	Context("When the fleet is summarized", func() {
+		since := func(minutes int) metav1.Time {
+			return metav1.NewTime(time.Date(2024, 1, 1, 0, minutes, 0, 0, time.UTC))
+		}
+
+		deployment := func(name string, placement string, phase resourcesv1alpha1.DeploymentPhase, inProgressSince metav1.Time) resourcesv1alpha1.ResourceGroupDeployment {
+			d := resourcesv1alpha1.ResourceGroupDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec:       resourcesv1alpha1.ResourceGroupDeploymentSpec{Placement: placement},
+				Status:     resourcesv1alpha1.ResourceGroupDeploymentStatus{Phase: phase},
+			}
+			if phase == resourcesv1alpha1.DeploymentInProgressPhase {
+				d.Status.Conditions = []metav1.Condition{{Type: resourcesv1alpha1.ConditionTypeInProgress, Status: metav1.ConditionTrue, LastTransitionTime: inProgressSince}}
+			}
+			return d
+		}
+
+		resource := func(name string, driftedSince *metav1.Time) resourcesv1alpha1.Resource {
+			r := resourcesv1alpha1.Resource{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec:       resourcesv1alpha1.ResourceSpec{Placement: "dev"},
+				Status:     resourcesv1alpha1.ResourceStatus{Phase: resourcesv1alpha1.DeploymentDonePhase},
+			}
+			if driftedSince != nil {
+				r.Status.Conditions = []metav1.Condition{{Type: resourcesv1alpha1.ConditionTypeDrifted, Status: metav1.ConditionTrue, LastTransitionTime: *driftedSince}}
+			}
+			return r
+		}
+
+		resourceGroups := []resourcesv1alpha1.ResourceGroup{
+			{ObjectMeta: metav1.ObjectMeta{Name: "rg-1", Namespace: "default"}, Status: resourcesv1alpha1.ResourceGroupStatus{Phase: resourcesv1alpha1.DeploymentDonePhase}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "rg-2", Namespace: "default"}},
+		}
+
+		deployments := []resourcesv1alpha1.ResourceGroupDeployment{
+			deployment("rg-1-dev", "dev", resourcesv1alpha1.DeploymentDonePhase, metav1.Time{}),
+			deployment("rg-1-prod", "prod", resourcesv1alpha1.DeploymentFailedPhase, metav1.Time{}),
+			deployment("rg-2-prod", "prod", resourcesv1alpha1.DeploymentFailedPhase, metav1.Time{}),
+			deployment("rg-2-staging", "staging", resourcesv1alpha1.DeploymentFailedPhase, metav1.Time{}),
+			deployment("rg-2-dev", "dev", resourcesv1alpha1.DeploymentInProgressPhase, since(10)),
+			deployment("rg-3-dev", "dev", resourcesv1alpha1.DeploymentInProgressPhase, since(5)),
+		}
+
+		drifted, driftedBefore := since(20), since(15)
+		resources := []resourcesv1alpha1.Resource{
+			resource("bucket", &drifted),
+			resource("queue", &driftedBefore),
+			resource("topic", nil),
+		}
+
+		It("should count the objects by phase", func() {
+			status := summarizeFleet(resourceGroups, deployments, resources, resourcesv1alpha1.DefaultFleetStatusLimit)
+
+			Expect(status.ResourceGroups).To(Equal(map[string]int32{string(resourcesv1alpha1.DeploymentDonePhase): 1, fleetPendingPhase: 1}))
+			Expect(status.Deployments).To(Equal(map[string]int32{
+				string(resourcesv1alpha1.DeploymentDonePhase):       1,
+				string(resourcesv1alpha1.DeploymentFailedPhase):     3,
+				string(resourcesv1alpha1.DeploymentInProgressPhase): 2,
+			}))
+			Expect(status.Resources).To(Equal(map[string]int32{string(resourcesv1alpha1.DeploymentDonePhase): 3}))
+		})
+
+		It("should list the placements with most failures first", func() {
+			status := summarizeFleet(resourceGroups, deployments, resources, resourcesv1alpha1.DefaultFleetStatusLimit)
+
+			Expect(status.FailingPlacements).To(Equal([]resourcesv1alpha1.KlaudioFleetFailingPlacement{
+				{Placement: "prod", Deployments: []string{"default/rg-1-prod", "default/rg-2-prod"}},
+				{Placement: "staging", Deployments: []string{"default/rg-2-staging"}},
+			}))
+		})
+
+		It("should list the oldest drifted Resources and in-progress deployments first", func() {
+			status := summarizeFleet(resourceGroups, deployments, resources, resourcesv1alpha1.DefaultFleetStatusLimit)
+
+			Expect(status.DriftedResourcesCount).To(Equal(int32(2)))
+			Expect(status.DriftedResources).To(HaveLen(2))
+			Expect(status.DriftedResources[0].Name).To(Equal("queue"))
+			Expect(status.DriftedResources[1].Name).To(Equal("bucket"))
+
+			Expect(status.InProgressDeploymentsCount).To(Equal(int32(2)))
+			Expect(status.OldestInProgressDeployments).To(HaveLen(2))
+			Expect(status.OldestInProgressDeployments[0].Name).To(Equal("rg-3-dev"))
+			Expect(status.OldestInProgressDeployments[0].Since).To(Equal(since(5)))
+		})
+
+		It("should list up to the limit, counting all of them", func() {
+			status := summarizeFleet(resourceGroups, deployments, resources, 1)
+
+			Expect(status.FailingPlacements).To(HaveLen(1))
+			Expect(status.FailingPlacements[0].Placement).To(Equal("prod"))
+
+			Expect(status.DriftedResourcesCount).To(Equal(int32(2)))
+			Expect(status.DriftedResources).To(HaveLen(1))
+
+			Expect(status.InProgressDeploymentsCount).To(Equal(int32(2)))
+			Expect(status.OldestInProgressDeployments).To(HaveLen(1))
+		})
+
+		It("should leave empty lists out", func() {
+			status := summarizeFleet(nil, nil, nil, resourcesv1alpha1.DefaultFleetStatusLimit)
+
+			Expect(status.FailingPlacements).To(BeNil())
+			Expect(status.DriftedResources).To(BeNil())
+			Expect(status.OldestInProgressDeployments).To(BeNil())
+		})
+	})
+})
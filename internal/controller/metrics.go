@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	resourceDrifted = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "klaudio_resource_drifted",
+			Help: "Whether a provisioned Resource diverges from its declared state (1) or not (0)",
+		},
+		[]string{"namespace", "name", "placement", "policy"},
+	)
+
+	resourceGroupResources = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "klaudio_resourcegroup_resources",
+			Help: "Number of Resources deployed by a ResourceGroup",
+		},
+		[]string{"resource_group"},
+	)
+
+	resourceGroupActiveRuns = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "klaudio_resourcegroup_active_runs",
+			Help: "Number of Resources from a ResourceGroup being provisioned",
+		},
+		[]string{"resource_group"},
+	)
+
+	resourceGroupRunnerPods = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "klaudio_resourcegroup_runner_pods",
+			Help: "Number of running pods in the ResourceGroup namespace",
+		},
+		[]string{"resource_group"},
+	)
+
+	resourceGroupCPURequests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "klaudio_resourcegroup_cpu_requests_cores",
+			Help: "CPU requested by the running pods in the ResourceGroup namespace",
+		},
+		[]string{"resource_group"},
+	)
+
+	resourceGroupMemoryRequests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "klaudio_resourcegroup_memory_requests_bytes",
+			Help: "Memory requested by the running pods in the ResourceGroup namespace",
+		},
+		[]string{"resource_group"},
+	)
+
+	deploymentTimeToReady = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "klaudio_resourcegroupdeployment_time_to_ready_seconds",
+			Help:    "Time from the start of a deployment run to its Resources being ready",
+			Buckets: prometheus.ExponentialBuckets(30, 2, 10),
+		},
+		[]string{"resource_group", "placement"},
+	)
+
+	deploymentTimeToReadyRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "klaudio_resourcegroupdeployment_time_to_ready_runs_total",
+			Help: "Number of finished deployment runs with a time-to-ready SLO, by whether they met it (met) or not (breached)",
+		},
+		[]string{"resource_group", "placement", "result"},
+	)
+
+	deploymentTimeToReadyBurn = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "klaudio_resourcegroupdeployment_time_to_ready_slo_burn_ratio",
+			Help: "Time-to-ready of the last deployment run divided by its SLO; above 1 the SLO was breached",
+		},
+		[]string{"resource_group", "placement"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		resourceDrifted,
+		resourceGroupResources,
+		resourceGroupActiveRuns,
+		resourceGroupRunnerPods,
+		resourceGroupCPURequests,
+		resourceGroupMemoryRequests,
+		deploymentTimeToReady,
+		deploymentTimeToReadyRuns,
+		deploymentTimeToReadyBurn,
+	)
+}
+
+// forgetResourceMetrics drops the series of a deleted Resource; the drift gauge is matched by namespace and name,
+// so the series of any policy the Resource ever had are dropped too
+func forgetResourceMetrics(namespace, name string) {
+	resourceDrifted.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "name": name})
+}
+
+// forgetResourceGroupMetrics drops the usage series of a deleted ResourceGroup
+func forgetResourceGroupMetrics(resourceGroup string) {
+	resourceGroupResources.DeleteLabelValues(resourceGroup)
+	resourceGroupActiveRuns.DeleteLabelValues(resourceGroup)
+	resourceGroupRunnerPods.DeleteLabelValues(resourceGroup)
+	resourceGroupCPURequests.DeleteLabelValues(resourceGroup)
+	resourceGroupMemoryRequests.DeleteLabelValues(resourceGroup)
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ForgetMetrics(t *testing.T) {
+
+	t.Run("We should drop the drift series of a deleted Resource, whatever its policy", func(t *testing.T) {
+		resourceDrifted.WithLabelValues("checkout", "orders-bucket", "prod", "Correct").Set(0)
+		resourceDrifted.WithLabelValues("checkout", "orders-bucket", "prod", "Warn").Set(1)
+		resourceDrifted.WithLabelValues("checkout", "orders-queue", "prod", "Warn").Set(1)
+
+		forgetResourceMetrics("checkout", "orders-bucket")
+
+		assert.Equal(t, 1, testutil.CollectAndCount(resourceDrifted))
+
+		forgetResourceMetrics("checkout", "orders-queue")
+	})
+
+	t.Run("We should drop the usage series of a deleted ResourceGroup", func(t *testing.T) {
+		resourceGroupResources.WithLabelValues("checkout").Set(2)
+		resourceGroupActiveRuns.WithLabelValues("checkout").Set(1)
+		resourceGroupRunnerPods.WithLabelValues("checkout").Set(1)
+		resourceGroupCPURequests.WithLabelValues("checkout").Set(0.5)
+		resourceGroupMemoryRequests.WithLabelValues("checkout").Set(1024)
+
+		forgetResourceGroupMetrics("checkout")
+
+		assert.Equal(t, 0, testutil.CollectAndCount(resourceGroupResources))
+		assert.Equal(t, 0, testutil.CollectAndCount(resourceGroupActiveRuns))
+		assert.Equal(t, 0, testutil.CollectAndCount(resourceGroupRunnerPods))
+		assert.Equal(t, 0, testutil.CollectAndCount(resourceGroupCPURequests))
+		assert.Equal(t, 0, testutil.CollectAndCount(resourceGroupMemoryRequests))
+	})
+}
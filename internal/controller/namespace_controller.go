@@ -19,6 +19,8 @@ package controller
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 
 	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
@@ -28,7 +30,10 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -38,6 +43,8 @@ import (
 type NamespaceReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// ResourceRefs serves ResourceRefs from the shared cache; without it, they're read with the client
+	ResourceRefs *ResourceRefs
 }
 
 const (
@@ -45,11 +52,50 @@ const (
 	OpenTofuServiceAccountName = "tf-runner"
 
 	OpenTofuRoleBindingName = "opentofu-runner"
+
+	PulumiClusterRoleName    = "pulumi-runner-role"
+	PulumiServiceAccountName = "pulumi"
+
+	PulumiRoleBindingName = "pulumi-runner"
+
+	RunnerRoleNameSuffix = "runner"
 )
 
+// sharedRunner is the service account running a provisioner inside the namespace, and its binding to the ClusterRole
+// shared by every namespace
+type sharedRunner struct {
+	ServiceAccountName string
+	ClusterRoleName    string
+	RoleBindingName    string
+}
+
+// sharedRunners are the provisioners whose runners live in the ResourceGroup's namespace; the other provisioners
+// don't require any RBAC inside it
+var sharedRunners = map[resourcesv1alpha1.ResourceRefProvisionerName]sharedRunner{
+	resourcesv1alpha1.ResourceRefOpenTofuProvisioner: {
+		ServiceAccountName: OpenTofuServiceAccountName,
+		ClusterRoleName:    OpenTofuClusterRoleName,
+		RoleBindingName:    OpenTofuRoleBindingName,
+	},
+	resourcesv1alpha1.ResourceRefPulumiProvisioner: {
+		ServiceAccountName: PulumiServiceAccountName,
+		ClusterRoleName:    PulumiClusterRoleName,
+		RoleBindingName:    PulumiRoleBindingName,
+	},
+}
+
 // +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=namespaces/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core,resources=namespaces/finalizers,verbs=update
+// +kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=bind;escalate
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind,resourceNames=tf-runner-role;pulumi-runner-role
+
+// The runner Roles carry the rules declared by ResourceRefs, which the controller itself doesn't hold, so creating
+// and binding them requires escalate and bind. Their names are generated from the ResourceRefs, so they can't be
+// narrowed with resourceNames; ResourceRefs are cluster-scoped, and creating them must be restricted to the platform
+// admins. The shared ClusterRoles are fixed, so only those can be bound.
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -63,39 +109,228 @@ const (
 func (r *NamespaceReconciler) Reconcile(ctx context.Context, namespace *corev1.Namespace) (ctrl.Result, error) {
 	namespacedLog := log.FromContext(ctx).WithValues("namespace", namespace.Name)
 
-	openTofuRunnerRoleBinding := &rbacv1.RoleBinding{}
-	if err := r.Get(ctx, types.NamespacedName{Name: OpenTofuRoleBindingName, Namespace: namespace.Name}, openTofuRunnerRoleBinding); err != nil {
-		if !apierrors.IsNotFound(err) {
-			namespacedLog.Error(err, "unable to fetch OpenTofu Runner's role binding")
+	resourceRefs, err := r.resourceRefsOf(ctx, namespace)
+	if err != nil {
+		namespacedLog.Error(err, "unable to fetch ResourceRefs used in the namespace")
+		return ctrl.Result{}, err
+	}
+
+	// runners without declared permissions still rely on the shared ClusterRole of their provisioner
+	required := make(map[resourcesv1alpha1.ResourceRefProvisionerName]bool)
+	runnerRoles := make(map[string]bool)
+
+	for _, resourceRef := range resourceRefs {
+		if resourceRef.Spec.Permissions == nil {
+			required[resourceRef.Spec.Provisioner.Name] = true
+			continue
+		}
+
+		if err := r.reconcileRunnerRole(ctx, namespace, resourceRef); err != nil {
+			namespacedLog.Error(err, fmt.Sprintf("unable to generate runner's role to ResourceRef %s in namespace %s", resourceRef.Name, namespace.Name))
 			return ctrl.Result{}, err
 		}
+		runnerRoles[runnerRoleNameOf(resourceRef)] = true
+	}
+
+	if err := r.removeStaleRunnerRoles(ctx, namespace, runnerRoles); err != nil {
+		namespacedLog.Error(err, fmt.Sprintf("unable to remove stale runner's roles from namespace %s", namespace.Name))
+		return ctrl.Result{}, err
+	}
 
-		namespacedLog.Info(fmt.Sprintf("there is no role binding to run OpenTofu in the namespace %s; trying to generate...", namespace.Name))
+	// only the provisioners used by the group get a runner in the namespace. Without any ResourceRef, the ones used by
+	// the group aren't known yet, like while its source isn't loaded, so the bindings already there are kept.
+	for _, provisionerName := range slices.Sorted(maps.Keys(sharedRunners)) {
+		runner := sharedRunners[provisionerName]
+
+		if !required[provisionerName] {
+			if len(resourceRefs) == 0 {
+				continue
+			}
+
+			if err := r.removeSharedRunner(ctx, namespace, runner); err != nil {
+				namespacedLog.Error(err, fmt.Sprintf("unable to remove the %s runner from namespace %s", provisionerName, namespace.Name))
+				return ctrl.Result{}, err
+			}
+			continue
+		}
 
-		openTofuRunnerRoleBinding.Name = OpenTofuRoleBindingName
-		openTofuRunnerRoleBinding.Namespace = namespace.Name
-		openTofuRunnerRoleBinding.RoleRef = rbacv1.RoleRef{
+		if err := r.reconcileSharedRunner(ctx, namespace, runner); err != nil {
+			namespacedLog.Error(err, fmt.Sprintf("unable to generate the %s runner in namespace %s", provisionerName, namespace.Name))
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// reconcileSharedRunner generates the runner's service account, bound to the shared ClusterRole of its provisioner
+func (r *NamespaceReconciler) reconcileSharedRunner(ctx context.Context, namespace *corev1.Namespace, runner sharedRunner) error {
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: v1.ObjectMeta{Name: runner.ServiceAccountName, Namespace: namespace.Name}}
+	if err := r.Create(ctx, serviceAccount); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	roleBinding := &rbacv1.RoleBinding{ObjectMeta: v1.ObjectMeta{Name: runner.RoleBindingName, Namespace: namespace.Name}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, roleBinding, func() error {
+		roleBinding.RoleRef = rbacv1.RoleRef{
 			APIGroup: "rbac.authorization.k8s.io",
 			Kind:     "ClusterRole",
-			Name:     OpenTofuClusterRoleName,
+			Name:     runner.ClusterRoleName,
 		}
-		openTofuRunnerRoleBinding.Subjects = []rbacv1.Subject{
+		roleBinding.Subjects = []rbacv1.Subject{
 			{
 				Kind:      "ServiceAccount",
-				Name:      OpenTofuServiceAccountName,
+				Name:      runner.ServiceAccountName,
 				Namespace: namespace.Name,
 			},
 		}
+		return nil
+	}); err != nil {
+		return err
+	}
 
-		if err := r.Create(ctx, openTofuRunnerRoleBinding); err != nil {
-			namespacedLog.Error(err, fmt.Sprintf("unable to create the required OpenTofu role binding in namespace %s", namespace.Name))
-			return ctrl.Result{}, err
+	return nil
+}
+
+// removeSharedRunner deletes the binding to the shared ClusterRole of a provisioner that is no longer used; the service
+// account is kept, since Roles generated from ResourceRef permissions may still be bound to it.
+func (r *NamespaceReconciler) removeSharedRunner(ctx context.Context, namespace *corev1.Namespace, runner sharedRunner) error {
+	roleBinding := &rbacv1.RoleBinding{ObjectMeta: v1.ObjectMeta{Name: runner.RoleBindingName, Namespace: namespace.Name}}
+	if err := r.Delete(ctx, roleBinding); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// resourceRefsOf returns the ResourceRefs used by the ResourceGroup that owns the namespace; in a namespace generated to
+// a single placement, only the ones deployed to that placement
+func (r *NamespaceReconciler) resourceRefsOf(ctx context.Context, namespace *corev1.Namespace) ([]*resourcesv1alpha1.ResourceRef, error) {
+	resourceGroupName, ok := namespace.Labels[resourcesv1alpha1.Group+"/managedBy.name"]
+	if !ok || namespace.Labels[resourcesv1alpha1.Group+"/managedBy.kind"] != "ResourceGroup" {
+		return nil, nil
+	}
+
+	resourceGroup := &resourcesv1alpha1.ResourceGroup{}
+	if err := r.Get(ctx, types.NamespacedName{Name: resourceGroupName}, resourceGroup); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	resourceRefs := make([]*resourcesv1alpha1.ResourceRef, 0)
+
+	for _, name := range resourceGroup.ResourceRefNames() {
+		resourceRef, err := resourceRefOf(ctx, r.ResourceRefs, r.Client, name)
+		if apierrors.IsNotFound(err) {
+			// a missing ResourceRef has nothing to run; its runner's role is removed as stale
+			continue
+		}
+		if err != nil {
+			return nil, err
 		}
 
-		namespacedLog.Info(fmt.Sprintf("a RoleBinding to run OpenTofu runnners in namespace %s was created", namespace.Name))
+		// a namespace of a single placement only runs the provisioners deployed to it; a ResourceRef selecting no
+		// placement at all keeps its runner, as the deployments to it are kept too
+		if placement, ok := namespace.Labels[resourcesv1alpha1.Group+"/placement"]; ok && len(resourceRef.Status.Placements) != 0 && !slices.Contains(resourceRef.Status.Placements, placement) {
+			continue
+		}
+		resourceRefs = append(resourceRefs, resourceRef)
 	}
 
-	return ctrl.Result{}, nil
+	return resourceRefs, nil
+}
+
+// runnerRoleNameOf is the name of the Role, and of its RoleBinding, generated from the ResourceRef's permissions
+func runnerRoleNameOf(resourceRef *resourcesv1alpha1.ResourceRef) string {
+	return fmt.Sprintf("%s-%s", resourceRef.Name, RunnerRoleNameSuffix)
+}
+
+// reconcileRunnerRole generates a Role with the ResourceRef's declared permissions, bound to the runner's service
+// account. Both are owned by the ResourceRef, so they're collected when it's deleted.
+func (r *NamespaceReconciler) reconcileRunnerRole(ctx context.Context, namespace *corev1.Namespace, resourceRef *resourcesv1alpha1.ResourceRef) error {
+	name := runnerRoleNameOf(resourceRef)
+
+	// typed objects read from the cache have no kind set, so the labels are written out
+	labels := map[string]string{
+		resourcesv1alpha1.Group + "/managedBy.group":   resourcesv1alpha1.GroupVersion.Group,
+		resourcesv1alpha1.Group + "/managedBy.version": resourcesv1alpha1.GroupVersion.Version,
+		resourcesv1alpha1.Group + "/managedBy.kind":    "ResourceRef",
+		resourcesv1alpha1.Group + "/managedBy.name":    resourceRef.Name,
+	}
+
+	role := &rbacv1.Role{ObjectMeta: v1.ObjectMeta{Name: name, Namespace: namespace.Name}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, role, func() error {
+		role.Labels = labels
+		role.Rules = resourceRef.Spec.Permissions.Rules
+		return controllerutil.SetControllerReference(resourceRef, role, r.Scheme)
+	}); err != nil {
+		return err
+	}
+
+	serviceAccountName := resourceRef.Spec.Permissions.ServiceAccountName
+	if serviceAccountName == "" {
+		serviceAccountName = OpenTofuServiceAccountName
+		if runner, ok := sharedRunners[resourceRef.Spec.Provisioner.Name]; ok {
+			serviceAccountName = runner.ServiceAccountName
+		}
+	}
+
+	roleBinding := &rbacv1.RoleBinding{ObjectMeta: v1.ObjectMeta{Name: name, Namespace: namespace.Name}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, roleBinding, func() error {
+		roleBinding.Labels = labels
+		roleBinding.RoleRef = rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     role.Name,
+		}
+		roleBinding.Subjects = []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      serviceAccountName,
+				Namespace: namespace.Name,
+			},
+		}
+		return controllerutil.SetControllerReference(resourceRef, roleBinding, r.Scheme)
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// removeStaleRunnerRoles deletes the runner's roles, and their bindings, of ResourceRefs no longer used in the
+// namespace or whose permissions were removed; only the ones generated from ResourceRefs are touched
+func (r *NamespaceReconciler) removeStaleRunnerRoles(ctx context.Context, namespace *corev1.Namespace, keep map[string]bool) error {
+	generated := client.MatchingLabels{
+		resourcesv1alpha1.Group + "/managedBy.group": resourcesv1alpha1.Group,
+		resourcesv1alpha1.Group + "/managedBy.kind":  "ResourceRef",
+	}
+
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, roleBindings, client.InNamespace(namespace.Name), generated); err != nil {
+		return err
+	}
+	for i := range roleBindings.Items {
+		if keep[roleBindings.Items[i].Name] {
+			continue
+		}
+		if err := r.Delete(ctx, &roleBindings.Items[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	roles := &rbacv1.RoleList{}
+	if err := r.List(ctx, roles, client.InNamespace(namespace.Name), generated); err != nil {
+		return err
+	}
+	for i := range roles.Items {
+		if keep[roles.Items[i].Name] {
+			continue
+		}
+		if err := r.Delete(ctx, &roles.Items[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -109,7 +344,52 @@ func (r *NamespaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 	return ctrl.NewControllerManagedBy(mgr).
-		WithEventFilter(expectedLabel).
-		For(&corev1.Namespace{}).
+		For(&corev1.Namespace{}, builder.WithPredicates(expectedLabel)).
+		Watches(&resourcesv1alpha1.ResourceGroup{}, handler.EnqueueRequestsFromMapFunc(r.namespaceOfResourceGroup)).
+		Watches(&resourcesv1alpha1.ResourceRef{}, handler.EnqueueRequestsFromMapFunc(r.namespacesUsingResourceRef)).
 		Complete(reconcile.AsReconciler[*corev1.Namespace](mgr.GetClient(), r))
 }
+
+// namespaceOfResourceGroup maps a ResourceGroup to its dedicated namespace, or to the namespaces of each placement
+func (r *NamespaceReconciler) namespaceOfResourceGroup(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.namespacesOf(ctx, obj.GetName())
+}
+
+// namespacesOf lists the namespaces generated to a ResourceGroup; the namespace named after the group is always
+// included, since it may not have been created yet
+func (r *NamespaceReconciler) namespacesOf(ctx context.Context, resourceGroupName string) []reconcile.Request {
+	requests := []reconcile.Request{{NamespacedName: types.NamespacedName{Name: resourceGroupName}}}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaces, client.MatchingLabels{
+		resourcesv1alpha1.Group + "/managedBy.kind": "ResourceGroup",
+		resourcesv1alpha1.Group + "/managedBy.name": resourceGroupName,
+	}); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list namespaces", "resourceGroup", resourceGroupName)
+		return requests
+	}
+
+	for _, namespace := range namespaces.Items {
+		if namespace.Name != resourceGroupName {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: namespace.Name}})
+		}
+	}
+	return requests
+}
+
+// namespacesUsingResourceRef maps a ResourceRef to the namespaces of every ResourceGroup using it
+func (r *NamespaceReconciler) namespacesUsingResourceRef(ctx context.Context, obj client.Object) []reconcile.Request {
+	resourceGroups := &resourcesv1alpha1.ResourceGroupList{}
+	if err := r.List(ctx, resourceGroups); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ResourceGroups", "resourceRef", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for _, resourceGroup := range resourceGroups.Items {
+		if resourceGroup.UsesResourceRef(obj.GetName()) {
+			requests = append(requests, r.namespacesOf(ctx, resourceGroup.Name)...)
+		}
+	}
+	return requests
+}
@@ -17,7 +17,19 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
 )
 
 var _ = Describe("Namespace Controller", func() {
@@ -30,3 +42,141 @@ var _ = Describe("Namespace Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("Namespace runners", func() {
+	Context("When a ResourceGroup uses only some provisioners", func() {
+		ctx := context.Background()
+
+		It("should bind only the runners of the used provisioners", func() {
+			resourceRef := &resourcesv1alpha1.ResourceRef{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-runners-pulumi-ref"},
+				Spec: resourcesv1alpha1.ResourceRefSpec{
+					Provisioner: resourcesv1alpha1.ResourceRefProvisioner{Name: resourcesv1alpha1.ResourceRefPulumiProvisioner},
+					Schema:      resourcesv1alpha1.ResourceRefSchema{Type: "object"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resourceRef)).To(Succeed())
+
+			resourceGroup := &resourcesv1alpha1.ResourceGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-runners-group"},
+				Spec: resourcesv1alpha1.ResourceGroupSpec{
+					Resources: []resourcesv1alpha1.ResourceGroupElement{{Name: "stack", ResourceRef: resourceRef.Name, Properties: &runtime.RawExtension{Raw: []byte(`{}`)}}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resourceGroup)).To(Succeed())
+
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: resourceGroup.Name,
+					Labels: map[string]string{
+						resourcesv1alpha1.Group + "/managedBy.group": resourcesv1alpha1.Group,
+						resourcesv1alpha1.Group + "/managedBy.kind":  "ResourceGroup",
+						resourcesv1alpha1.Group + "/managedBy.name":  resourceGroup.Name,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			controllerReconciler := &NamespaceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			reconciler := reconcile.AsReconciler[*corev1.Namespace](k8sClient, controllerReconciler)
+
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: namespace.Name}})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: PulumiServiceAccountName, Namespace: namespace.Name}, &corev1.ServiceAccount{})).To(Succeed())
+
+			pulumiRoleBinding := &rbacv1.RoleBinding{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: PulumiRoleBindingName, Namespace: namespace.Name}, pulumiRoleBinding)).To(Succeed())
+			Expect(pulumiRoleBinding.RoleRef.Name).To(Equal(PulumiClusterRoleName))
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: OpenTofuRoleBindingName, Namespace: namespace.Name}, &rbacv1.RoleBinding{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("switching the ResourceRef to OpenTofu")
+			resourceRef.Spec.Provisioner.Name = resourcesv1alpha1.ResourceRefOpenTofuProvisioner
+			Expect(k8sClient.Update(ctx, resourceRef)).To(Succeed())
+
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: namespace.Name}})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: OpenTofuRoleBindingName, Namespace: namespace.Name}, &rbacv1.RoleBinding{})).To(Succeed())
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: PulumiRoleBindingName, Namespace: namespace.Name}, &rbacv1.RoleBinding{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("keeping the runner while no ResourceRef of the group is known")
+			Expect(k8sClient.Delete(ctx, resourceRef)).To(Succeed())
+
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: namespace.Name}})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: OpenTofuRoleBindingName, Namespace: namespace.Name}, &rbacv1.RoleBinding{})).To(Succeed())
+
+			Expect(k8sClient.Delete(ctx, resourceGroup)).To(Succeed())
+		})
+	})
+
+	Context("When a ResourceRef with permissions stops being used", func() {
+		ctx := context.Background()
+
+		It("should remove its runner's role", func() {
+			resourceRef := &resourcesv1alpha1.ResourceRef{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-stale-runner-ref"},
+				Spec: resourcesv1alpha1.ResourceRefSpec{
+					Provisioner: resourcesv1alpha1.ResourceRefProvisioner{Name: resourcesv1alpha1.ResourceRefOpenTofuProvisioner},
+					Schema:      resourcesv1alpha1.ResourceRefSchema{Type: "object"},
+					Permissions: &resourcesv1alpha1.ResourceRefPermissions{
+						Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resourceRef)).To(Succeed())
+
+			resourceGroup := &resourcesv1alpha1.ResourceGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-stale-runner-group"},
+				Spec: resourcesv1alpha1.ResourceGroupSpec{
+					Resources: []resourcesv1alpha1.ResourceGroupElement{{Name: "bucket", ResourceRef: resourceRef.Name, Properties: &runtime.RawExtension{Raw: []byte(`{}`)}}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resourceGroup)).To(Succeed())
+
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: resourceGroup.Name,
+					Labels: map[string]string{
+						resourcesv1alpha1.Group + "/managedBy.group": resourcesv1alpha1.Group,
+						resourcesv1alpha1.Group + "/managedBy.kind":  "ResourceGroup",
+						resourcesv1alpha1.Group + "/managedBy.name":  resourceGroup.Name,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			controllerReconciler := &NamespaceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			reconciler := reconcile.AsReconciler[*corev1.Namespace](k8sClient, controllerReconciler)
+
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: namespace.Name}})
+			Expect(err).NotTo(HaveOccurred())
+
+			role := &rbacv1.Role{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: runnerRoleNameOf(resourceRef), Namespace: namespace.Name}, role)).To(Succeed())
+			Expect(role.OwnerReferences).To(HaveLen(1))
+			Expect(role.OwnerReferences[0].Name).To(Equal(resourceRef.Name))
+
+			By("removing the ResourceRef's permissions")
+			resourceRef.Spec.Permissions = nil
+			Expect(k8sClient.Update(ctx, resourceRef)).To(Succeed())
+
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: namespace.Name}})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: runnerRoleNameOf(resourceRef), Namespace: namespace.Name}, &rbacv1.Role{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: runnerRoleNameOf(resourceRef), Namespace: namespace.Name}, &rbacv1.RoleBinding{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, resourceGroup)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resourceRef)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/provisioning"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// RefreshRequestedAnnotation asks the Resource controller to run the provisioner again, refreshing the outputs; the
+// value is the time of the request
+const RefreshRequestedAnnotation = resourcesv1alpha1.Group + "/refreshRequestedAt"
+
+// staleSources lists the dependencies of a resource whose outputs are older than the threshold. Outputs without a
+// refresh time were never tracked, so they aren't considered stale.
+func staleSources(resource *resources.Resource, sources map[string]*resourcesv1alpha1.Resource, threshold time.Duration, now time.Time) []*resourcesv1alpha1.Resource {
+	stale := make([]*resourcesv1alpha1.Resource, 0)
+	if threshold <= 0 {
+		return stale
+	}
+
+	for _, dependency := range resource.Dependencies() {
+		source, ok := sources[strings.TrimPrefix(dependency, "resources.")]
+		if !ok || source.Status.OutputsRefreshedAt == nil {
+			continue
+		}
+		if now.Sub(source.Status.OutputsRefreshedAt.Time) > threshold {
+			stale = append(stale, source)
+		}
+	}
+
+	return stale
+}
+
+// requestRefresh annotates the source Resource, unless a refresh was already requested after its outputs were read or
+// its provisioner object is still running for one
+func (r *ResourceGroupDeploymentReconciler) requestRefresh(ctx context.Context, source *resourcesv1alpha1.Resource) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, types.NamespacedName{Namespace: source.Namespace, Name: source.Name}, source); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+
+		if source.Status.RefreshRequestedAt != nil {
+			return nil
+		}
+
+		if requestedAt, err := time.Parse(time.RFC3339, source.Annotations[RefreshRequestedAnnotation]); err == nil &&
+			source.Status.OutputsRefreshedAt != nil && requestedAt.After(source.Status.OutputsRefreshedAt.Time) {
+			return nil
+		}
+
+		if source.Annotations == nil {
+			source.Annotations = make(map[string]string)
+		}
+		source.Annotations[RefreshRequestedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+
+		return r.Update(ctx, source)
+	})
+}
+
+// forceRefresh acts on a refresh requested with RefreshRequestedAnnotation: the provisioner object is asked to run
+// again, and the request moves from the annotation to the status until the outputs of that run are read
+func (r *ResourceReconciler) forceRefresh(ctx context.Context, resource *resourcesv1alpha1.Resource) error {
+	if _, ok := resource.Annotations[RefreshRequestedAnnotation]; !ok {
+		return nil
+	}
+
+	log.FromContext(ctx).Info(fmt.Sprintf("A refresh of Resource %s was requested; running its provisioner again...", resource.Name))
+
+	requestedAt := metav1.Now().Rfc3339Copy()
+	for _, annotation := range provisioning.ReconcileRequestAnnotations {
+		resource.Annotations[annotation] = requestedAt.UTC().Format(time.RFC3339)
+	}
+	delete(resource.Annotations, RefreshRequestedAnnotation)
+	if err := r.Update(ctx, resource); err != nil {
+		return err
+	}
+
+	resource.Status.RefreshRequestedAt = &requestedAt
+	return r.Status().Update(ctx, resource)
+}
+
+// refreshPending tells whether the provisioner object didn't run yet since a refresh was forced, so its status still
+// comes from the run before. Provisioners not reporting the requests they handle are taken as refreshed.
+func refreshPending(resource *resourcesv1alpha1.Resource, status *provisioning.ProvisionedResourceStatus) bool {
+	if resource.Status.RefreshRequestedAt == nil || status.HandledReconcileRequest == "" {
+		return false
+	}
+	handledAt, err := time.Parse(time.RFC3339, status.HandledReconcileRequest)
+	return err == nil && handledAt.Before(resource.Status.RefreshRequestedAt.Time)
+}
+
+// outputsChanged compares the outputs read from the provisioner to the ones stored by the last run
+func outputsChanged(previous resourcesv1alpha1.ResourceOutputs, current map[string]any) bool {
+	if len(previous) == 0 && len(current) == 0 {
+		return false
+	}
+	return !reflect.DeepEqual(map[string]any(previous), current)
+}
+
+// staleInputsCondition sets the StaleInputs condition of a dependent Resource, if it changed
+func (r *ResourceGroupDeploymentReconciler) staleInputsCondition(ctx context.Context, dependent *resourcesv1alpha1.Resource, stale []*resourcesv1alpha1.Resource) error {
+	condition := metav1.Condition{
+		Type:    resourcesv1alpha1.ConditionTypeStaleInputs,
+		Status:  metav1.ConditionFalse,
+		Reason:  resourcesv1alpha1.ConditionReasonInputsRefreshed,
+		Message: fmt.Sprintf("Resource %s was rendered from fresh outputs", dependent.Name),
+	}
+
+	if len(stale) != 0 {
+		names := make([]string, 0, len(stale))
+		for _, source := range stale {
+			names = append(names, fmt.Sprintf("%s (refreshed at %s)", source.Name, source.Status.OutputsRefreshedAt.UTC().Format(time.RFC3339)))
+		}
+
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = resourcesv1alpha1.ConditionReasonOutputsExpired
+		condition.Message = fmt.Sprintf("Resource %s is waiting for outputs older than %s to be refreshed: %s", dependent.Name, r.OutputsStalenessThreshold, strings.Join(names, ", "))
+	}
+
+	current := meta.FindStatusCondition(dependent.Status.Conditions, condition.Type)
+	if current == nil && len(stale) == 0 {
+		// the inputs were never stale; there is nothing to report
+		return nil
+	}
+	if current != nil && current.Status == condition.Status && current.Message == condition.Message {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, types.NamespacedName{Namespace: dependent.Namespace, Name: dependent.Name}, dependent); err != nil {
+			return err
+		}
+		setStatusCondition(&dependent.Status.Conditions, condition)
+		return r.Status().Update(ctx, dependent)
+	})
+}
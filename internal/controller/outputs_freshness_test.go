@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/provisioning"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+var _ = Describe("Outputs freshness", func() {
+	Context("When checking the sources of a resource", func() {
+		now := time.Now()
+
+		newSource := func(name string, refreshedAt *metav1.Time) *resourcesv1alpha1.Resource {
+			return &resourcesv1alpha1.Resource{
+				ObjectMeta: metav1.ObjectMeta{Name: "sample." + name},
+				Status:     resourcesv1alpha1.ResourceStatus{OutputsRefreshedAt: refreshedAt},
+			}
+		}
+
+		resourceGroup := resources.NewResourceGroup()
+		app, err := resourceGroup.NewResource("app", &runtime.RawExtension{Raw: []byte(`{"database":"${resources.database.status.outputs.endpoint}","cache":"${resources.cache.status.outputs.endpoint}","queue":"${resources.queue.status.outputs.url}"}`)})
+		Expect(err).NotTo(HaveOccurred())
+
+		sources := map[string]*resourcesv1alpha1.Resource{
+			"database": newSource("database", &metav1.Time{Time: now.Add(-2 * time.Hour)}),
+			"cache":    newSource("cache", &metav1.Time{Time: now.Add(-time.Minute)}),
+			"queue":    newSource("queue", nil),
+		}
+
+		It("should list the sources refreshed before the threshold", func() {
+			stale := staleSources(app, sources, time.Hour, now)
+			Expect(stale).To(HaveLen(1))
+			Expect(stale[0].Name).To(Equal("sample.database"))
+		})
+
+		It("should ignore outputs when the threshold is disabled", func() {
+			Expect(staleSources(app, sources, 0, now)).To(BeEmpty())
+		})
+	})
+})
+
+func Test_RefreshPending(t *testing.T) {
+	requestedAt := metav1.NewTime(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+
+	refreshing := &resourcesv1alpha1.Resource{Status: resourcesv1alpha1.ResourceStatus{RefreshRequestedAt: &requestedAt}}
+
+	t.Run("We should wait while the provisioner object reports an older request", func(t *testing.T) {
+		assert.True(t, refreshPending(refreshing, &provisioning.ProvisionedResourceStatus{HandledReconcileRequest: "2024-05-01T09:00:00Z"}))
+	})
+
+	t.Run("We should take the run as refreshed once the request is handled", func(t *testing.T) {
+		assert.False(t, refreshPending(refreshing, &provisioning.ProvisionedResourceStatus{HandledReconcileRequest: "2024-05-01T10:00:00Z"}))
+		assert.False(t, refreshPending(refreshing, &provisioning.ProvisionedResourceStatus{HandledReconcileRequest: "2024-05-01T10:05:00.123Z"}))
+	})
+
+	t.Run("Provisioners not reporting the handled requests are taken as refreshed", func(t *testing.T) {
+		assert.False(t, refreshPending(refreshing, &provisioning.ProvisionedResourceStatus{}))
+	})
+
+	t.Run("Nothing is pending without a requested refresh", func(t *testing.T) {
+		assert.False(t, refreshPending(&resourcesv1alpha1.Resource{}, &provisioning.ProvisionedResourceStatus{HandledReconcileRequest: "2024-05-01T09:00:00Z"}))
+	})
+}
+
+func Test_OutputsChanged(t *testing.T) {
+
+	t.Run("We should compare the outputs by value", func(t *testing.T) {
+		assert.False(t, outputsChanged(resourcesv1alpha1.ResourceOutputs{"endpoint": "db.local", "port": float64(5432)}, map[string]any{"endpoint": "db.local", "port": float64(5432)}))
+		assert.True(t, outputsChanged(resourcesv1alpha1.ResourceOutputs{"endpoint": "db.local"}, map[string]any{"endpoint": "db.remote"}))
+	})
+
+	t.Run("The first outputs read are a change", func(t *testing.T) {
+		assert.True(t, outputsChanged(nil, map[string]any{"endpoint": "db.local"}))
+		assert.False(t, outputsChanged(nil, map[string]any{}))
+	})
+}
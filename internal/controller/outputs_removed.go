@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/gobuffalo/flect"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/expression"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// removedOutputs lists the outputs from the previous run that the provisioner doesn't return anymore
+func removedOutputs(previous resourcesv1alpha1.ResourceOutputs, current resourcesv1alpha1.ResourceOutputs) []string {
+	removed := make([]string, 0)
+	for _, name := range slices.Sorted(maps.Keys(previous)) {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	return removed
+}
+
+// OutputsRemovalAcknowledgedAnnotation lists, comma separated, the outputs of a Resource allowed to disappear while
+// other resources still consume them; until every one of them is listed, the outputs of the run aren't published
+const OutputsRemovalAcknowledgedAnnotation = resourcesv1alpha1.Group + "/acknowledgeRemovedOutputs"
+
+// outputConsumers lists the resources from the same ResourceGroupDeployment whose properties read any of the removed
+// outputs, with the outputs read by each one, followed by the consumed outputs
+func (r *ResourceReconciler) outputConsumers(ctx context.Context, resource *resourcesv1alpha1.Resource, removed []string) ([]string, []string, error) {
+	deploymentName := resource.Labels[resourcesv1alpha1.Group+"/managedBy.name"]
+	if deploymentName == "" {
+		return nil, nil, nil
+	}
+
+	deployment := &resourcesv1alpha1.ResourceGroupDeployment{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: resource.Namespace, Name: deploymentName}, deployment); err != nil {
+		return nil, nil, client.IgnoreNotFound(err)
+	}
+
+	// resources are deployed as <deployment>.<element name as kebab case>
+	source := ""
+	for _, element := range deployment.Spec.Resources {
+		if fmt.Sprintf("%s.%s", deployment.Name, flect.Dasherize(element.Name)) == resource.Name {
+			source = element.Name
+			break
+		}
+	}
+	if source == "" {
+		return nil, nil, nil
+	}
+
+	return outputConsumersOf(expression.Language(deployment.Spec.ExpressionLanguage), deployment.Spec.Resources, source, removed)
+}
+
+// outputConsumersOf reads the paths read by the expressions of each element, other than the source; reading all the
+// outputs of the source, or the source itself, consumes every removed output
+func outputConsumersOf(language expression.Language, elements []resourcesv1alpha1.ResourceGroupElement, source string, removed []string) ([]string, []string, error) {
+	consumers := make([]string, 0)
+	consumed := make([]string, 0)
+
+	resourceGroup := resources.NewResourceGroup().WithExpressionLanguage(language)
+	for _, element := range elements {
+		if element.Name == source || element.Properties == nil {
+			continue
+		}
+
+		consumer, err := resourceGroup.NewResource(element.Name, element.Properties)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read resource %s: %w", element.Name, err)
+		}
+		references := consumer.References()
+
+		read := make([]string, 0)
+		for _, name := range removed {
+			output := fmt.Sprintf("resources.%s.status.outputs.%s", source, name)
+			if slices.ContainsFunc(references, func(reference string) bool {
+				return reference == output || strings.HasPrefix(reference, output+".") || strings.HasPrefix(output, reference+".")
+			}) {
+				read = append(read, name)
+			}
+		}
+		if len(read) == 0 {
+			continue
+		}
+
+		consumers = append(consumers, fmt.Sprintf("%s (%s)", element.Name, strings.Join(read, ", ")))
+		for _, name := range read {
+			if !slices.Contains(consumed, name) {
+				consumed = append(consumed, name)
+			}
+		}
+	}
+	slices.Sort(consumed)
+
+	return consumers, consumed, nil
+}
+
+// unacknowledgedOutputs are the consumed outputs not listed by OutputsRemovalAcknowledgedAnnotation
+func unacknowledgedOutputs(resource *resourcesv1alpha1.Resource, consumed []string) []string {
+	acknowledged := strings.Split(resource.Annotations[OutputsRemovalAcknowledgedAnnotation], ",")
+	for i := range acknowledged {
+		acknowledged[i] = strings.TrimSpace(acknowledged[i])
+	}
+
+	return slices.DeleteFunc(slices.Clone(consumed), func(name string) bool {
+		return slices.Contains(acknowledged, name)
+	})
+}
+
+// removedOutputsToCondition warns about outputs removed by the provisioner, before they are published to the
+// dependent resources. The warning is kept until the Resource changes. When other resources consume any of them,
+// true is returned until the removal is acknowledged, so the outputs they read are kept published.
+func (r *ResourceReconciler) removedOutputsToCondition(ctx context.Context, resource *resourcesv1alpha1.Resource, previous resourcesv1alpha1.ResourceOutputs, current resourcesv1alpha1.ResourceOutputs) (bool, error) {
+	removed := removedOutputs(previous, current)
+	if len(removed) == 0 {
+		if condition := meta.FindStatusCondition(resource.Status.Conditions, resourcesv1alpha1.ConditionTypeOutputsRemoved); condition != nil && condition.ObservedGeneration != resource.Generation {
+			meta.RemoveStatusCondition(&resource.Status.Conditions, resourcesv1alpha1.ConditionTypeOutputsRemoved)
+		}
+		return false, nil
+	}
+
+	consumers, consumed, err := r.outputConsumers(ctx, resource, removed)
+	if err != nil {
+		return false, err
+	}
+
+	reason := resourcesv1alpha1.ConditionReasonOutputsRemoved
+	message := fmt.Sprintf("Outputs %s aren't returned by the provisioner anymore", strings.Join(removed, ", "))
+	if len(consumers) != 0 {
+		message = fmt.Sprintf("%s; they are consumed by %s", message, strings.Join(consumers, ", "))
+	}
+
+	unacknowledged := unacknowledgedOutputs(resource, consumed)
+	if len(unacknowledged) != 0 {
+		reason = resourcesv1alpha1.ConditionReasonOutputsHeld
+		message = fmt.Sprintf("%s. The outputs are kept until annotation %s lists %s", message, OutputsRemovalAcknowledgedAnnotation, strings.Join(unacknowledged, ", "))
+	}
+
+	setStatusCondition(&resource.Status.Conditions, metav1.Condition{
+		Type:               resourcesv1alpha1.ConditionTypeOutputsRemoved,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: resource.Generation,
+	})
+
+	return len(unacknowledged) != 0, nil
+}
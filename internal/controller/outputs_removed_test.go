@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/expression"
+)
+
+var _ = Describe("Removed outputs", func() {
+	Context("When a provisioning run returns fewer outputs", func() {
+		ctx := context.Background()
+
+		deployment := &resourcesv1alpha1.ResourceGroupDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "outputs-removed.prod", Namespace: "default"},
+			Spec: resourcesv1alpha1.ResourceGroupDeploymentSpec{
+				Placement: "prod",
+				Resources: []resourcesv1alpha1.ResourceGroupElement{
+					{Name: "database", ResourceRef: "rds", Properties: &runtime.RawExtension{Raw: []byte(`{"name":"checkout"}`)}},
+					{Name: "app", ResourceRef: "service", Properties: &runtime.RawExtension{Raw: []byte(`{"host":"${resources.database.status.outputs.endpoint}","db":"${resources.database.status.outputs.name}"}`)}},
+					{Name: "worker", ResourceRef: "service", Properties: &runtime.RawExtension{Raw: []byte(`{"host":"${resources.database.status.outputs.endpointV2}"}`)}},
+				},
+			},
+		}
+
+		newResource := func(generation int64) *resourcesv1alpha1.Resource {
+			return &resourcesv1alpha1.Resource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "outputs-removed.prod.database",
+					Namespace:  "default",
+					Generation: generation,
+					Labels:     map[string]string{resourcesv1alpha1.Group + "/managedBy.name": deployment.Name},
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, deployment.DeepCopy())).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, deployment.DeepCopy())).To(Succeed())
+		})
+
+		It("should list the removed outputs and the resources consuming them", func() {
+			reconciler := &ResourceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			resource := newResource(1)
+
+			previous := resourcesv1alpha1.ResourceOutputs{"endpoint": "checkout.rds", "name": "checkout", "port": "5432"}
+			current := resourcesv1alpha1.ResourceOutputs{"endpointV2": "checkout.rds"}
+
+			held, err := reconciler.removedOutputsToCondition(ctx, resource, previous, current)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(held).To(BeTrue())
+
+			condition := meta.FindStatusCondition(resource.Status.Conditions, resourcesv1alpha1.ConditionTypeOutputsRemoved)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal(resourcesv1alpha1.ConditionReasonOutputsHeld))
+			Expect(condition.Message).To(Equal("Outputs endpoint, name, port aren't returned by the provisioner anymore; they are consumed by app (endpoint, name). " +
+				"The outputs are kept until annotation resources.klaudio.nubank.io/acknowledgeRemovedOutputs lists endpoint, name"))
+
+			By("publishing the outputs once the removal is acknowledged")
+			resource.Annotations = map[string]string{OutputsRemovalAcknowledgedAnnotation: "endpoint, name"}
+
+			held, err = reconciler.removedOutputsToCondition(ctx, resource, previous, current)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(held).To(BeFalse())
+			Expect(meta.FindStatusCondition(resource.Status.Conditions, resourcesv1alpha1.ConditionTypeOutputsRemoved).Reason).To(Equal(resourcesv1alpha1.ConditionReasonOutputsRemoved))
+
+			By("keeping the warning until the Resource changes")
+			_, err = reconciler.removedOutputsToCondition(ctx, resource, current, current)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, resourcesv1alpha1.ConditionTypeOutputsRemoved)).To(BeTrue())
+
+			resource.Generation = 2
+			_, err = reconciler.removedOutputsToCondition(ctx, resource, current, current)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(meta.FindStatusCondition(resource.Status.Conditions, resourcesv1alpha1.ConditionTypeOutputsRemoved)).To(BeNil())
+		})
+	})
+})
+
+func Test_OutputConsumersOf(t *testing.T) {
+	elements := []resourcesv1alpha1.ResourceGroupElement{
+		{Name: "database", ResourceRef: "rds", Properties: &runtime.RawExtension{Raw: []byte(`{"name":"checkout"}`)}},
+		{Name: "app", ResourceRef: "service", Properties: &runtime.RawExtension{Raw: []byte(`{"host":"${resources[\"database\"].status.outputs[\"endpoint\"]}"}`)}},
+		{Name: "worker", ResourceRef: "service", Properties: &runtime.RawExtension{Raw: []byte(`{"env":{"DB":"${resources.database.status.outputs}"}}`)}},
+		{Name: "cache", ResourceRef: "elasticache", Properties: &runtime.RawExtension{Raw: []byte(`{"tag":"${resources.database.status.outputs.endpointV2}"}`)}},
+	}
+
+	t.Run("We should find the consumers reading the removed outputs in any syntax", func(t *testing.T) {
+		consumers, consumed, err := outputConsumersOf(expression.LanguageExpr, elements, "database", []string{"endpoint", "port"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"app (endpoint)", "worker (endpoint, port)"}, consumers)
+		assert.Equal(t, []string{"endpoint", "port"}, consumed)
+	})
+
+	t.Run("We should only hold the outputs not acknowledged", func(t *testing.T) {
+		resource := &resourcesv1alpha1.Resource{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{OutputsRemovalAcknowledgedAnnotation: "port"},
+		}}
+		assert.Equal(t, []string{"endpoint"}, unacknowledgedOutputs(resource, []string{"endpoint", "port"}))
+	})
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/outputs"
+)
+
+// provisioningHashOf hashes the spec fields read by provisioners; writeOutputsTo only changes who consumes the
+// outputs, suspend is handled before any provisioner runs, and hooks are only run by the teardown
+func provisioningHashOf(spec resourcesv1alpha1.ResourceSpec) (string, error) {
+	spec.WriteOutputsTo = nil
+	spec.Suspend = false
+	spec.Hooks = nil
+
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// republishOutputs writes the current outputs of a deployed Resource to a new consumer, without provisioning it
+// again, when nothing read by the provisioner changed since the last successful run. It returns false when the
+// Resource must be provisioned.
+func (r *ResourceReconciler) republishOutputs(ctx context.Context, resource *resourcesv1alpha1.Resource, resourceRef *resourcesv1alpha1.ResourceRef) (bool, error) {
+	if resource.Status.Phase != resourcesv1alpha1.DeploymentDonePhase || resource.Status.ObservedGeneration == resource.Generation {
+		return false, nil
+	}
+
+	hash, err := provisioningHashOf(resource.Spec)
+	if err != nil {
+		return false, err
+	}
+	if resource.Status.ProvisionedSpecHash == "" || resource.Status.ProvisionedSpecHash != hash {
+		return false, nil
+	}
+
+	log.FromContext(ctx).Info(fmt.Sprintf("Only consumers of the outputs of Resource %s changed; republishing them...", resource.Name))
+
+	current, err := outputs.Reveal(ctx, r.Client, resource)
+	if err != nil {
+		return false, err
+	}
+	if err := outputs.Export(ctx, r.Client, resource, current, resourceRef.Spec.SensitiveOutputs); err != nil {
+		return false, err
+	}
+
+	resource.Status.ObservedGeneration = resource.Generation
+	if err := r.Status().Update(ctx, resource); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
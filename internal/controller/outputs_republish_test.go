@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("Republished outputs", func() {
+	Context("When a deployed Resource gets a new consumer of its outputs", func() {
+		ctx := context.Background()
+
+		It("should write the current outputs to it, without provisioning the Resource again", func() {
+			resource := &resourcesv1alpha1.Resource{
+				ObjectMeta: metav1.ObjectMeta{Name: "outputs-republish.prod.database", Namespace: "default"},
+				Spec: resourcesv1alpha1.ResourceSpec{
+					Placement:   "prod",
+					ResourceRef: "rds",
+					Properties:  &runtime.RawExtension{Raw: []byte(`{"name":"checkout"}`)},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			}()
+
+			hash, err := provisioningHashOf(resource.Spec)
+			Expect(err).NotTo(HaveOccurred())
+
+			resource.Status.Phase = resourcesv1alpha1.DeploymentDonePhase
+			resource.Status.ObservedGeneration = resource.Generation
+			resource.Status.ProvisionedSpecHash = hash
+			Expect(resource.Status.SetOutputs(resourcesv1alpha1.ResourceOutputs{"endpoint": "checkout.rds"})).To(Succeed())
+			Expect(k8sClient.Status().Update(ctx, resource)).To(Succeed())
+
+			resource.Spec.WriteOutputsTo = &resourcesv1alpha1.ResourceOutputsTarget{Kind: resourcesv1alpha1.ResourceOutputsTargetConfigMap, Name: "checkout-database"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			reconciler := &ResourceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			resourceRef := &resourcesv1alpha1.ResourceRef{ObjectMeta: metav1.ObjectMeta{Name: "rds"}}
+
+			republished, err := reconciler.republishOutputs(ctx, resource, resourceRef)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(republished).To(BeTrue())
+			Expect(resource.Status.ObservedGeneration).To(Equal(resource.Generation))
+
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: "checkout-database"}, configMap)).To(Succeed())
+			Expect(configMap.Data).To(HaveKeyWithValue("endpoint", "checkout.rds"))
+
+			resource.Spec.Properties = &runtime.RawExtension{Raw: []byte(`{"name":"billing"}`)}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			republished, err = reconciler.republishOutputs(ctx, resource, resourceRef)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(republished).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// PlacementChangesApprovalAnnotation approves the placement changes of a ResourceGroup with the Manual placement
+// approval policy; the value is the id published in status.placementChanges, so any other change waits again
+const PlacementChangesApprovalAnnotation = resourcesv1alpha1.Group + "/approvePlacementChanges"
+
+// placementChangesOf compares the known placements of a group to the placements it's deployed to; the id is a digest
+// of both lists, so the same changes always get the same id
+func placementChangesOf(known sets.String, deployed sets.String) (create []string, destroy []string, id string) {
+	create = known.Difference(deployed).List()
+	destroy = deployed.Difference(known).List()
+	if len(create) == 0 && len(destroy) == 0 {
+		return nil, nil, ""
+	}
+
+	digest := sha256.Sum256([]byte(fmt.Sprintf("create:%s;destroy:%s", strings.Join(create, ","), strings.Join(destroy, ","))))
+	return create, destroy, hex.EncodeToString(digest[:])[:12]
+}
+
+// deployedPlacements are the placements the ResourceGroup has ResourceGroupDeployments to
+func (r *ResourceGroupReconciler) deployedPlacements(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup) (sets.String, error) {
+	deployments := &resourcesv1alpha1.ResourceGroupDeploymentList{}
+	if err := r.List(ctx, deployments, client.MatchingLabels{resourcesv1alpha1.Group + "/managedBy.name": resourceGroup.Name}); err != nil {
+		return nil, err
+	}
+
+	deployed := sets.NewString()
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+
+		placement := deployment.Labels[resourcesv1alpha1.Group+"/placement"]
+		if placement == "" || !metav1.IsControlledBy(deployment, resourceGroup) || !deployment.DeletionTimestamp.IsZero() {
+			continue
+		}
+		deployed.Insert(placement)
+	}
+	return deployed, nil
+}
+
+// approvePlacementChanges returns the placements the group may be deployed to. With the Manual placement approval
+// policy, ResourceGroupDeployments are only created or destroyed once the changes are approved; until then, the
+// changes are published in status.placementChanges and the group keeps its current placements.
+func (r *ResourceGroupReconciler) approvePlacementChanges(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup, knowPlacements sets.String) (sets.String, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroup", resourceGroup.Name)
+
+	if resourceGroup.Spec.PlacementApproval != resourcesv1alpha1.ApprovalPolicyManual {
+		return knowPlacements, nil
+	}
+
+	deployed, err := r.deployedPlacements(ctx, resourceGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	create, destroy, id := placementChangesOf(knowPlacements, deployed)
+	if id == "" {
+		if resourceGroup.Status.PlacementChanges != nil {
+			resourceGroup.Status.PlacementChanges = nil
+			if err := r.Status().Update(ctx, resourceGroup); err != nil {
+				return nil, err
+			}
+		}
+		return knowPlacements, nil
+	}
+
+	condition := meta.FindStatusCondition(resourceGroup.Status.Conditions, resourcesv1alpha1.ConditionTypeApproved)
+
+	if resourceGroup.Annotations[PlacementChangesApprovalAnnotation] == id {
+		message := fmt.Sprintf("Placement changes %s of ResourceGroup %s were approved", id, resourceGroup.Name)
+		if condition == nil || condition.Status != metav1.ConditionTrue || condition.Message != message {
+			log.Info(message)
+			if _, err := r.newResourceGroupCondition(ctx, resourceGroup, &metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeApproved,
+				Status:  metav1.ConditionTrue,
+				Reason:  resourcesv1alpha1.ConditionReasonApproved,
+				Message: message,
+			}); err != nil {
+				return nil, err
+			}
+		}
+		return knowPlacements, nil
+	}
+
+	if resourceGroup.Status.PlacementChanges == nil || resourceGroup.Status.PlacementChanges.ID != id {
+		message := fmt.Sprintf("Placement changes of ResourceGroup %s are waiting for approval (create: %v, destroy: %v); review status.placementChanges and set the annotation %s=%s",
+			resourceGroup.Name, create, destroy, PlacementChangesApprovalAnnotation, id)
+		log.Info(message)
+
+		resourceGroup.Status.PlacementChanges = &resourcesv1alpha1.ResourceGroupPlacementChanges{
+			ID:        id,
+			PlannedAt: metav1.Now(),
+			Create:    create,
+			Destroy:   destroy,
+		}
+		if _, err := r.newResourceGroupCondition(ctx, resourceGroup, &metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeApproved,
+			Status:  metav1.ConditionFalse,
+			Reason:  resourcesv1alpha1.ConditionReasonPendingApproval,
+			Message: message,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	// nothing is created or destroyed; the deployments already there keep being reconciled
+	return deployed, nil
+}
@@ -0,0 +1,31 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var _ = Describe("Placement changes", func() {
+	Context("When the placements of a ResourceGroup change", func() {
+		It("should tell the deployments to be created and destroyed", func() {
+			create, destroy, id := placementChangesOf(sets.NewString("prod", "staging", "sandbox"), sets.NewString("prod", "dev"))
+			Expect(create).To(Equal([]string{"sandbox", "staging"}))
+			Expect(destroy).To(Equal([]string{"dev"}))
+			Expect(id).NotTo(BeEmpty())
+
+			_, _, sameId := placementChangesOf(sets.NewString("sandbox", "prod", "staging"), sets.NewString("dev", "prod"))
+			Expect(sameId).To(Equal(id))
+
+			_, _, otherId := placementChangesOf(sets.NewString("prod", "staging"), sets.NewString("prod", "dev"))
+			Expect(otherId).NotTo(Equal(id))
+		})
+
+		It("should have no changes when the group is deployed to every placement", func() {
+			create, destroy, id := placementChangesOf(sets.NewString("prod"), sets.NewString("prod"))
+			Expect(create).To(BeEmpty())
+			Expect(destroy).To(BeEmpty())
+			Expect(id).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// handleReconcileRequest records that the deployment was reconciled after the last request made to its placement;
+// any change to the placement already enqueues every deployment targeting it
+func (r *ResourceGroupDeploymentReconciler) handleReconcileRequest(ctx context.Context, deployment *resourcesv1alpha1.ResourceGroupDeployment, placement *resourcesv1alpha1.Placement) error {
+	requestedAt := placement.Annotations[resourcesv1alpha1.PlacementReconcileRequestedAnnotation]
+	if requestedAt == "" || requestedAt == deployment.Status.LastHandledReconcileAt {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, types.NamespacedName{Namespace: deployment.Namespace, Name: deployment.Name}, deployment); err != nil {
+			return err
+		}
+		deployment.Status.LastHandledReconcileAt = requestedAt
+		return r.Status().Update(ctx, deployment)
+	})
+}
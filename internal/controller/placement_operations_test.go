@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("Placement operations", func() {
+	Context("When operating every deployment to a placement", func() {
+		ctx := context.Background()
+
+		placementName := "placement-operations"
+		typeNamespacedName := types.NamespacedName{Name: "sample.placement-operations", Namespace: "default"}
+
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, &resourcesv1alpha1.Placement{
+				ObjectMeta: metav1.ObjectMeta{Name: placementName},
+				Spec:       resourcesv1alpha1.PlacementSpec{Suspend: true},
+			})).To(Succeed())
+
+			Expect(k8sClient.Create(ctx, &resourcesv1alpha1.ResourceGroupDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      typeNamespacedName.Name,
+					Namespace: typeNamespacedName.Namespace,
+					Labels:    map[string]string{resourcesv1alpha1.Group + "/placement": placementName},
+				},
+				Spec: resourcesv1alpha1.ResourceGroupDeploymentSpec{Placement: placementName},
+			})).To(Succeed())
+		})
+
+		AfterEach(func() {
+			deployment := &resourcesv1alpha1.ResourceGroupDeployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			deployment.Finalizers = nil
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, deployment)).To(Succeed())
+
+			Expect(k8sClient.Delete(ctx, &resourcesv1alpha1.Placement{ObjectMeta: metav1.ObjectMeta{Name: placementName}})).To(Succeed())
+		})
+
+		It("should hold deployments while the placement is suspended, and record reconcile requests", func() {
+			reconciler := reconcile.AsReconciler[*resourcesv1alpha1.ResourceGroupDeployment](k8sClient, &ResourceGroupDeploymentReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			})
+
+			By("pausing the placement")
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			deployment := &resourcesv1alpha1.ResourceGroupDeployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			suspended := meta.FindStatusCondition(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeSuspended)
+			Expect(suspended).NotTo(BeNil())
+			Expect(suspended.Status).To(Equal(metav1.ConditionTrue))
+			Expect(suspended.Reason).To(Equal(resourcesv1alpha1.ConditionReasonPlacementSuspended))
+
+			By("resuming the placement with a reconcile request")
+			placement := &resourcesv1alpha1.Placement{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: placementName}, placement)).To(Succeed())
+			placement.Spec.Suspend = false
+			placement.Annotations = map[string]string{resourcesv1alpha1.PlacementReconcileRequestedAnnotation: "2024-10-01T12:00:00Z"}
+			Expect(k8sClient.Update(ctx, placement)).To(Succeed())
+
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			Expect(meta.IsStatusConditionFalse(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeSuspended)).To(BeTrue())
+			Expect(deployment.Status.LastHandledReconcileAt).To(Equal("2024-10-01T12:00:00Z"))
+		})
+	})
+})
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"encoding/json"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// ProvenanceAnnotation records, as a JSON object, where each top-level property of a Resource came from: the
+// parameters, refs and outputs of other resources read to render it, or "literal" and "default"
+const ProvenanceAnnotation = resourcesv1alpha1.Group + "/provenance"
+
+// applyProvenance annotates the Resource with the provenance of the properties rendered from source
+func applyProvenance(resource *resourcesv1alpha1.Resource, source *resources.Resource, expanded resources.ExpandedResourceProperties) error {
+	provenance, err := json.Marshal(source.Provenance(expanded))
+	if err != nil {
+		return err
+	}
+
+	if resource.Annotations == nil {
+		resource.Annotations = make(map[string]string)
+	}
+	resource.Annotations[ProvenanceAnnotation] = string(provenance)
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+var _ = Describe("Property provenance", func() {
+	Context("When annotating a rendered Resource", func() {
+		It("should record where each top-level property came from", func() {
+			resourceGroup := resources.NewResourceGroup()
+			source, err := resourceGroup.NewResource("app", &runtime.RawExtension{Raw: []byte(`{
+				"name": "checkout-${parameters.env}",
+				"database": {"endpoint": "${resources.database.status.outputs.endpoint}", "team": "${refs.owner.data.team}"},
+				"replicas": 2
+			}`)})
+			Expect(err).NotTo(HaveOccurred())
+
+			expanded := resources.ExpandedResourceProperties{
+				"name":     "checkout-prod",
+				"database": map[string]any{"endpoint": "checkout-prod.rds", "team": "payments"},
+				"replicas": 2,
+				"port":     8080,
+			}
+
+			resource := &resourcesv1alpha1.Resource{}
+			Expect(applyProvenance(resource, source, expanded)).To(Succeed())
+
+			Expect(resource.Annotations[ProvenanceAnnotation]).To(MatchJSON(`{
+				"name": ["parameters.env"],
+				"database": ["refs.owner.data.team", "resources.database.status.outputs.endpoint"],
+				"replicas": ["literal"],
+				"port": ["default"]
+			}`))
+		})
+	})
+})
@@ -0,0 +1,153 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/provisioning"
+)
+
+// ProvisionerPluginReconciler keeps the provisioner plugin registry in sync with ProvisionerPlugin objects
+type ProvisionerPluginReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=provisionerplugins,verbs=get;list;watch
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=provisionerplugins/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+
+// Reconcile registers the plugin described by a ProvisionerPlugin; SetupWithManager unregisters it when the object is gone.
+func (r *ProvisionerPluginReconciler) Reconcile(ctx context.Context, provisionerPlugin *resourcesv1alpha1.ProvisionerPlugin) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("provisionerPlugin", provisionerPlugin.Name)
+
+	if !provisionerPlugin.DeletionTimestamp.IsZero() {
+		provisioning.UnregisterPlugin(provisionerPlugin.Name)
+		return ctrl.Result{}, nil
+	}
+
+	var timeout time.Duration
+	if provisionerPlugin.Spec.Timeout != nil {
+		timeout = provisionerPlugin.Spec.Timeout.Duration
+	}
+
+	condition := metav1.Condition{
+		Type:               resourcesv1alpha1.ConditionTypeReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             resourcesv1alpha1.ConditionReasonPluginRegistered,
+		Message:            fmt.Sprintf("Provisioner %s is served by %s", provisionerPlugin.Name, provisionerPlugin.Spec.Endpoint),
+		ObservedGeneration: provisionerPlugin.Generation,
+	}
+
+	transport, err := r.transportOf(ctx, provisionerPlugin)
+	if err == nil {
+		err = provisioning.RegisterPlugin(provisionerPlugin.Name, provisionerPlugin.Spec.Endpoint, timeout, transport)
+	}
+	if err != nil {
+		log.Error(err, "unable to register provisioner plugin")
+
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = resourcesv1alpha1.ConditionReasonPluginRejected
+		condition.Message = err.Error()
+	} else {
+		log.Info(fmt.Sprintf("ProvisionerPlugin %s registered at %s", provisionerPlugin.Name, provisionerPlugin.Spec.Endpoint))
+	}
+
+	if setStatusCondition(&provisionerPlugin.Status.Conditions, condition) {
+		if err := r.Status().Update(ctx, provisionerPlugin); err != nil {
+			log.Error(err, "unable to update ProvisionerPlugin's status")
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// transportOf builds the TLS configuration of a plugin from its Secret; plugins without it are called over plaintext
+func (r *ProvisionerPluginReconciler) transportOf(ctx context.Context, provisionerPlugin *resourcesv1alpha1.ProvisionerPlugin) (*provisioning.PluginTransport, error) {
+	spec := provisionerPlugin.Spec.TLS
+	if spec == nil {
+		return nil, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: spec.SecretRef.Namespace, Name: spec.SecretRef.Name}, secret); err != nil {
+		return nil, fmt.Errorf("unable to read the TLS Secret %s/%s: %w", spec.SecretRef.Namespace, spec.SecretRef.Name, err)
+	}
+
+	config, err := provisioning.NewPluginTLS(secret.Data["ca.crt"], secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey], spec.ServerName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS Secret %s/%s: %w", spec.SecretRef.Namespace, spec.SecretRef.Name, err)
+	}
+
+	return &provisioning.PluginTransport{
+		TLS:      config,
+		Revision: fmt.Sprintf("%s/%s/%s", secret.UID, secret.ResourceVersion, spec.ServerName),
+	}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ProvisionerPluginReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	objectReconciler := reconcile.AsReconciler[*resourcesv1alpha1.ProvisionerPlugin](mgr.GetClient(), r)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&resourcesv1alpha1.ProvisionerPlugin{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.pluginsUsingSecret)).
+		Complete(reconcile.Func(func(ctx context.Context, req reconcile.Request) (ctrl.Result, error) {
+			// AsReconciler skips objects that are gone, so their plugins are unregistered here
+			if err := r.Get(ctx, req.NamespacedName, &resourcesv1alpha1.ProvisionerPlugin{}); apierrors.IsNotFound(err) {
+				log.FromContext(ctx).Info(fmt.Sprintf("ProvisionerPlugin %s was removed; unregistering it", req.Name))
+				provisioning.UnregisterPlugin(req.Name)
+				return ctrl.Result{}, nil
+			}
+			return objectReconciler.Reconcile(ctx, req)
+		}))
+}
+
+// pluginsUsingSecret enqueues the ProvisionerPlugins whose TLS Secret changed, so rotated certificates are picked up
+func (r *ProvisionerPluginReconciler) pluginsUsingSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	provisionerPlugins := &resourcesv1alpha1.ProvisionerPluginList{}
+	if err := r.List(ctx, provisionerPlugins); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ProvisionerPlugins", "secret", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, provisionerPlugin := range provisionerPlugins.Items {
+		pluginTLS := provisionerPlugin.Spec.TLS
+		if pluginTLS == nil || pluginTLS.SecretRef.Namespace != obj.GetNamespace() || pluginTLS.SecretRef.Name != obj.GetName() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: provisionerPlugin.Name}})
+	}
+	return requests
+}
@@ -0,0 +1,231 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+const (
+	ReadOnlyServiceAccountName = "klaudio-viewer"
+	ReadOnlyKubeconfigName     = ReadOnlyServiceAccountName + "-kubeconfig"
+	ReadOnlyKubeconfigKey      = "kubeconfig"
+
+	// ReadOnlyTokenExpiration is how long the token of the read-only kubeconfig is requested for; it's renewed once
+	// two thirds of it are gone
+	ReadOnlyTokenExpiration = 24 * time.Hour
+
+	// readOnlyTokenRenewAtAnnotation keeps, in the kubeconfig Secret, when its token must be renewed
+	readOnlyTokenRenewAtAnnotation = resourcesv1alpha1.Group + "/renewTokenAt"
+	// readOnlyLegacyTokenSecretName is the long-lived token Secret of previous versions, removed once a token is
+	// requested instead
+	readOnlyLegacyTokenSecretName = ReadOnlyServiceAccountName + "-token"
+	// rootCAConfigMapName is published by Kubernetes to every namespace, with the CA of the API server
+	rootCAConfigMapName = "kube-root-ca.crt"
+)
+
+// readOnlyRules allow reading the deployments and resources of the group, and the objects generated by provisioners;
+// secrets are left out, since they may hold provisioning credentials.
+var readOnlyRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{resourcesv1alpha1.Group},
+		Resources: []string{"resourcegroupdeployments", "resourcegroupdeployments/status", "resources", "resources/status"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"configmaps", "pods", "events"},
+		Verbs:     []string{"get", "list", "watch"},
+	},
+}
+
+// +kubebuilder:rbac:groups=core,resources=serviceaccounts/token,verbs=create
+
+// reconcileReadOnlyAccess keeps the read-only ServiceAccount of the group's namespace, and returns the name of the
+// kubeconfig Secret to it, with how long until its token must be renewed. The kubeconfig holds a token requested to
+// the ServiceAccount; the name is empty while Kubernetes doesn't publish the CA of the API server to the namespace.
+func (r *ResourceGroupReconciler) reconcileReadOnlyAccess(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup, namespace *corev1.Namespace) (string, time.Duration, error) {
+	if !resourceGroup.Spec.ReadOnlyAccess {
+		return "", 0, r.revokeReadOnlyAccess(ctx, resourceGroup, namespace)
+	}
+
+	labels := readOnlyLabelsOf(resourceGroup)
+
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: ReadOnlyServiceAccountName, Namespace: namespace.Name}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, serviceAccount, func() error {
+		serviceAccount.Labels = labels
+		return nil
+	}); err != nil {
+		return "", 0, fmt.Errorf("unable to reconcile ServiceAccount %s: %w", serviceAccount.Name, err)
+	}
+
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: ReadOnlyServiceAccountName, Namespace: namespace.Name}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, role, func() error {
+		role.Labels = labels
+		role.Rules = readOnlyRules
+		return nil
+	}); err != nil {
+		return "", 0, fmt.Errorf("unable to reconcile Role %s: %w", role.Name, err)
+	}
+
+	roleBinding := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: ReadOnlyServiceAccountName, Namespace: namespace.Name}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, roleBinding, func() error {
+		roleBinding.Labels = labels
+		roleBinding.RoleRef = rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     role.Name,
+		}
+		roleBinding.Subjects = []rbacv1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      serviceAccount.Name,
+				Namespace: namespace.Name,
+			},
+		}
+		return nil
+	}); err != nil {
+		return "", 0, fmt.Errorf("unable to reconcile RoleBinding %s: %w", roleBinding.Name, err)
+	}
+
+	if err := r.deleteReadOnlyObject(ctx, resourceGroup, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: readOnlyLegacyTokenSecretName, Namespace: namespace.Name}}); err != nil {
+		return "", 0, err
+	}
+
+	kubeconfigSecret := &corev1.Secret{}
+	if err := r.namespacedReader().Get(ctx, types.NamespacedName{Name: ReadOnlyKubeconfigName, Namespace: namespace.Name}, kubeconfigSecret); err == nil {
+		if !readOnlyManagedBy(kubeconfigSecret, resourceGroup) {
+			return "", 0, fmt.Errorf("there is a Secret %s in namespace %s not managed by ResourceGroup %s", ReadOnlyKubeconfigName, namespace.Name, resourceGroup.Name)
+		}
+		if renewAt, err := time.Parse(time.RFC3339, kubeconfigSecret.Annotations[readOnlyTokenRenewAtAnnotation]); err == nil && time.Now().Before(renewAt) {
+			return kubeconfigSecret.Name, time.Until(renewAt), nil
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return "", 0, err
+	}
+
+	rootCA := &corev1.ConfigMap{}
+	if err := r.namespacedReader().Get(ctx, types.NamespacedName{Name: rootCAConfigMapName, Namespace: namespace.Name}, rootCA); err != nil {
+		return "", 0, client.IgnoreNotFound(err)
+	}
+
+	expirationSeconds := int64(ReadOnlyTokenExpiration.Seconds())
+	tokenRequest := &authenticationv1.TokenRequest{Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &expirationSeconds}}
+	if err := r.SubResource("token").Create(ctx, serviceAccount, tokenRequest); err != nil {
+		return "", 0, fmt.Errorf("unable to request a token to ServiceAccount %s: %w", serviceAccount.Name, err)
+	}
+
+	// the API server may issue the token for less than requested
+	issuedAt := time.Now()
+	renewAt := issuedAt.Add(tokenRequest.Status.ExpirationTimestamp.Sub(issuedAt) * 2 / 3)
+
+	kubeconfig, err := clientcmd.Write(readOnlyKubeconfigOf(r.APIServerURL, namespace.Name, []byte(rootCA.Data["ca.crt"]), []byte(tokenRequest.Status.Token)))
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to generate read-only kubeconfig: %w", err)
+	}
+
+	kubeconfigSecret = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: ReadOnlyKubeconfigName, Namespace: namespace.Name}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, kubeconfigSecret, func() error {
+		kubeconfigSecret.Labels = labels
+		if kubeconfigSecret.Annotations == nil {
+			kubeconfigSecret.Annotations = make(map[string]string)
+		}
+		kubeconfigSecret.Annotations[readOnlyTokenRenewAtAnnotation] = renewAt.UTC().Format(time.RFC3339)
+		kubeconfigSecret.Data = map[string][]byte{ReadOnlyKubeconfigKey: kubeconfig}
+		return nil
+	}); err != nil {
+		return "", 0, fmt.Errorf("unable to reconcile Secret %s: %w", kubeconfigSecret.Name, err)
+	}
+
+	return kubeconfigSecret.Name, time.Until(renewAt), nil
+}
+
+// revokeReadOnlyAccess removes the read-only ServiceAccount and everything generated to it. The ServiceAccount tells
+// whether the access was ever given to the group, so nothing else is looked up without it; objects that are already
+// gone, or weren't generated to the group, are left alone.
+func (r *ResourceGroupReconciler) revokeReadOnlyAccess(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup, namespace *corev1.Namespace) error {
+	serviceAccount := &corev1.ServiceAccount{}
+	if err := r.namespacedReader().Get(ctx, types.NamespacedName{Name: ReadOnlyServiceAccountName, Namespace: namespace.Name}, serviceAccount); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !readOnlyManagedBy(serviceAccount, resourceGroup) {
+		return nil
+	}
+
+	objs := []client.Object{
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: ReadOnlyKubeconfigName, Namespace: namespace.Name}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: readOnlyLegacyTokenSecretName, Namespace: namespace.Name}},
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: ReadOnlyServiceAccountName, Namespace: namespace.Name}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: ReadOnlyServiceAccountName, Namespace: namespace.Name}},
+		serviceAccount,
+	}
+	for _, obj := range objs {
+		if err := r.deleteReadOnlyObject(ctx, resourceGroup, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteReadOnlyObject deletes an object generated to the read-only access of the group, if it still exists and is
+// labeled as managed by the group
+func (r *ResourceGroupReconciler) deleteReadOnlyObject(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup, obj client.Object) error {
+	if err := r.namespacedReader().Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !readOnlyManagedBy(obj, resourceGroup) {
+		return nil
+	}
+
+	uid := obj.GetUID()
+	if err := r.Delete(ctx, obj, client.Preconditions{UID: &uid}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("unable to delete %s: %w", obj.GetName(), err)
+	}
+	return nil
+}
+
+func readOnlyLabelsOf(resourceGroup *resourcesv1alpha1.ResourceGroup) map[string]string {
+	return map[string]string{
+		resourcesv1alpha1.Group + "/managedBy.group":   resourceGroup.GroupVersionKind().Group,
+		resourcesv1alpha1.Group + "/managedBy.version": resourceGroup.GroupVersionKind().Version,
+		resourcesv1alpha1.Group + "/managedBy.kind":    resourceGroup.GroupVersionKind().Kind,
+		resourcesv1alpha1.Group + "/managedBy.name":    resourceGroup.Name,
+	}
+}
+
+// readOnlyManagedBy tells whether the object was generated to the read-only access of the group
+func readOnlyManagedBy(obj client.Object, resourceGroup *resourcesv1alpha1.ResourceGroup) bool {
+	name, ok := obj.GetLabels()[resourcesv1alpha1.Group+"/managedBy.name"]
+	return ok && name == resourceGroup.Name
+}
+
+func readOnlyKubeconfigOf(server, namespace string, caData, token []byte) clientcmdapi.Config {
+	config := clientcmdapi.NewConfig()
+	config.Clusters[namespace] = &clientcmdapi.Cluster{
+		Server:                   server,
+		CertificateAuthorityData: caData,
+	}
+	config.AuthInfos[ReadOnlyServiceAccountName] = &clientcmdapi.AuthInfo{
+		Token: string(token),
+	}
+	config.Contexts[namespace] = &clientcmdapi.Context{
+		Cluster:   namespace,
+		AuthInfo:  ReadOnlyServiceAccountName,
+		Namespace: namespace,
+	}
+	config.CurrentContext = namespace
+	return *config
+}
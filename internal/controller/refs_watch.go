@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// deploymentRefsIndex indexes ResourceGroupDeployments by the ConfigMaps and Secrets they read as refs
+const deploymentRefsIndex = ".spec.refs"
+
+// refIndexKey identifies a ref in deploymentRefsIndex; refs with a selector are indexed without a name, since they
+// can match any object of the kind in their namespace
+func refIndexKey(kind resourcesv1alpha1.ResourceGroupRefKind, namespace string, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// watchedRef tells whether changes to the objects read by the ref are watched; only ConfigMaps and Secrets are
+func watchedRef(ref resourcesv1alpha1.ResourceGroupRef) bool {
+	return ref.Kind == resourcesv1alpha1.ResourceGroupRefConfigMap || ref.Kind == resourcesv1alpha1.ResourceGroupRefSecret
+}
+
+// refIndexKeysOf returns the keys of every watched ref of a ResourceGroupDeployment
+func refIndexKeysOf(obj client.Object) []string {
+	deployment, ok := obj.(*resourcesv1alpha1.ResourceGroupDeployment)
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0, len(deployment.Spec.Refs))
+	for _, ref := range deployment.Spec.Refs {
+		if !watchedRef(ref) {
+			continue
+		}
+		if ref.Selector != nil {
+			keys = append(keys, refIndexKey(ref.Kind, ref.Namespace, ""))
+			continue
+		}
+		keys = append(keys, refIndexKey(ref.Kind, ref.Namespace, ref.Name))
+	}
+	return keys
+}
+
+// readsRef tells whether the ResourceGroupDeployment reads the object as one of its refs of the kind
+func readsRef(deployment *resourcesv1alpha1.ResourceGroupDeployment, kind resourcesv1alpha1.ResourceGroupRefKind, obj client.Object) bool {
+	for _, ref := range deployment.Spec.Refs {
+		if ref.Kind != kind {
+			continue
+		}
+		if ref.Namespace != "" && ref.Namespace != obj.GetNamespace() {
+			continue
+		}
+		if ref.Selector == nil {
+			if ref.Name == obj.GetName() {
+				return true
+			}
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(ref.Selector)
+		if err != nil {
+			continue
+		}
+		if selector.Matches(labels.Set(obj.GetLabels())) {
+			return true
+		}
+	}
+	return false
+}
+
+// deploymentsToRef maps a ConfigMap or a Secret to every ResourceGroupDeployment reading it as a ref, so a change to
+// the object starts a new deployment run with the new values
+func (r *ResourceGroupDeploymentReconciler) deploymentsToRef(kind resourcesv1alpha1.ResourceGroupRefKind) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		keys := []string{
+			refIndexKey(kind, obj.GetNamespace(), obj.GetName()),
+			// refs with a selector, in the namespace of the object or in any namespace
+			refIndexKey(kind, obj.GetNamespace(), ""),
+			refIndexKey(kind, "", ""),
+		}
+
+		requests := make([]reconcile.Request, 0)
+		seen := make(map[client.ObjectKey]bool)
+		for _, key := range keys {
+			deployments := &resourcesv1alpha1.ResourceGroupDeploymentList{}
+			if err := r.List(ctx, deployments, client.MatchingFields{deploymentRefsIndex: key}); err != nil {
+				log.FromContext(ctx).Error(err, "unable to list ResourceGroupDeployments", "ref", key)
+				return nil
+			}
+
+			for _, deployment := range deployments.Items {
+				deploymentKey := client.ObjectKeyFromObject(&deployment)
+				if seen[deploymentKey] || !inShard(r.ShardSelector, &deployment) || !readsRef(&deployment, kind, obj) {
+					continue
+				}
+				seen[deploymentKey] = true
+				requests = append(requests, reconcile.Request{NamespacedName: deploymentKey})
+			}
+		}
+		return requests
+	}
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("Watched refs", func() {
+	Context("When a ResourceGroupDeployment reads ConfigMaps and Secrets as refs", func() {
+		deployment := &resourcesv1alpha1.ResourceGroupDeployment{
+			Spec: resourcesv1alpha1.ResourceGroupDeploymentSpec{
+				Refs: []resourcesv1alpha1.ResourceGroupRef{
+					{Name: "network", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefConfigMap, Namespace: "infra"},
+					{Name: "credentials", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefSecret, Namespace: "infra"},
+					{
+						Name: "zones", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefConfigMap,
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"kind": "zone"}},
+					},
+					{Name: "cluster", ApiVersion: "example.io/v1", Kind: "Cluster", Namespace: "infra"},
+				},
+			},
+		}
+
+		It("should index the ConfigMaps and Secrets, but no other kind", func() {
+			Expect(refIndexKeysOf(deployment)).To(Equal([]string{
+				"ConfigMap/infra/network",
+				"Secret/infra/credentials",
+				"ConfigMap//",
+			}))
+		})
+
+		It("should tell the objects read by the deployment", func() {
+			network := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "network", Namespace: "infra"}}
+			Expect(readsRef(deployment, resourcesv1alpha1.ResourceGroupRefConfigMap, network)).To(BeTrue())
+			Expect(readsRef(deployment, resourcesv1alpha1.ResourceGroupRefSecret, network)).To(BeFalse())
+
+			zone := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "us-east-1a", Namespace: "default", Labels: map[string]string{"kind": "zone"}}}
+			Expect(readsRef(deployment, resourcesv1alpha1.ResourceGroupRefConfigMap, zone)).To(BeTrue())
+
+			other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "network", Namespace: "default"}}
+			Expect(readsRef(deployment, resourcesv1alpha1.ResourceGroupRefConfigMap, other)).To(BeFalse())
+		})
+	})
+})
@@ -18,34 +18,89 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/clusters"
+	"github.com/nubank/klaudio/internal/outputs"
 	"github.com/nubank/klaudio/internal/provisioning"
 )
 
+// ResourceDestroyFinalizer holds the Resource until the provisioned infrastructure is torn down
+const ResourceDestroyFinalizer = resourcesv1alpha1.Group + "/destroy"
+
 // ResourceReconciler reconciles a Resource object
 type ResourceReconciler struct {
 	client.Client
 	*dynamic.DynamicClient
 	Scheme *runtime.Scheme
+	// ShardSelector restricts the Resources handled by this manager
+	ShardSelector labels.Selector
+	// RetryBudget is how many consecutive transient provisioner errors are retried before the Resource fails;
+	// zero means DefaultProvisionerRetryBudget
+	RetryBudget int32
+	// Clusters connects to the remote clusters of placements; provisioner objects of Resources deployed to them are
+	// created there. Without it, every Resource is provisioned in the local cluster
+	Clusters *clusters.Factory
+	// ResourceRefs serves ResourceRefs from the shared cache; without it, they're read with the client
+	ResourceRefs *ResourceRefs
+
+	// watchedKinds are the provisioner objects whose changes trigger a reconciliation; any other is polled
+	watchedKinds map[schema.GroupKind]bool
+}
+
+const (
+	DefaultProvisionerRetryBudget int32 = 5
+
+	retryBaseDelay = time.Duration(5) * time.Second
+	retryMaxDelay  = time.Duration(5) * time.Minute
+)
+
+// retryBackoff doubles the delay after each attempt, up to retryMaxDelay
+func retryBackoff(attempt int32) time.Duration {
+	delay := retryBaseDelay
+	for i := int32(1); i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxDelay)
 }
 
 // +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resources,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resources/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resources/finalizers,verbs=update
+// +kubebuilder:rbac:groups=core,resources=secrets;configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=placements,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infra.contrib.fluxcd.io,resources=terraforms,verbs=get;list;watch
+// +kubebuilder:rbac:groups=pulumi.com,resources=stacks,verbs=get;list;watch
+// +kubebuilder:rbac:groups=helm.toolkit.fluxcd.io,resources=helmreleases,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -59,6 +114,20 @@ type ResourceReconciler struct {
 func (r *ResourceReconciler) Reconcile(ctx context.Context, resource *resourcesv1alpha1.Resource) (ctrl.Result, error) {
 	logWithResource := log.FromContext(ctx).WithValues("resource", resource.Name)
 
+	deleting := !resource.DeletionTimestamp.IsZero()
+
+	if !deleting && !controllerutil.ContainsFinalizer(resource, ResourceDestroyFinalizer) {
+		controllerutil.AddFinalizer(resource, ResourceDestroyFinalizer)
+		if err := r.Update(ctx, resource); err != nil {
+			logWithResource.Error(err, "unable to add finalizer to Resource")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if deleting && !controllerutil.ContainsFinalizer(resource, ResourceDestroyFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
 	if len(resource.Status.Conditions) == 0 {
 		resource.Status.Phase = resourcesv1alpha1.DeploymentInProgressPhase
 		resourceWithCondition, err := r.newResourceCondition(ctx, resource, &metav1.Condition{
@@ -74,12 +143,76 @@ func (r *ResourceReconciler) Reconcile(ctx context.Context, resource *resourcesv
 		resource = resourceWithCondition
 	}
 
-	resourceRef := &resourcesv1alpha1.ResourceRef{}
-	if err := r.Get(ctx, types.NamespacedName{Name: resource.Spec.ResourceRef}, resourceRef); err != nil {
+	// a protected Resource isn't deleted until the protection is removed; the provisioner objects keep the
+	// infrastructure meanwhile
+	if deleting && resource.Spec.Protect {
+		logWithResource.Info("Resource is protected; holding its deletion...")
+		_, err := r.newResourceCondition(ctx, resource, &metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeFailed,
+			Status:  metav1.ConditionFalse,
+			Reason:  resourcesv1alpha1.ConditionReasonPolicyViolation,
+			Message: fmt.Sprintf("Resource %s is protected; set spec.protect to false to delete it", resource.Name),
+		})
+		if err != nil {
+			logWithResource.Error(err, "Failed to update Resource's status")
+		}
+		return ctrl.Result{}, err
+	}
+
+	// a suspended Resource is still destroyed when deleted
+	suspended := meta.IsStatusConditionTrue(resource.Status.Conditions, resourcesv1alpha1.ConditionTypeSuspended)
+	if resource.Spec.Suspend && !deleting {
+		logWithResource.Info("Resource is suspended; skipping reconciliation...")
+		if !suspended {
+			if _, err := r.newResourceCondition(ctx, resource, suspendedCondition("Resource", resource.Name)); err != nil {
+				logWithResource.Error(err, "Failed to update Resource's status")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if suspended && !resource.Spec.Suspend {
+		resourceResumed, err := r.newResourceCondition(ctx, resource, resumedCondition("Resource", resource.Name))
+		if err != nil {
+			logWithResource.Error(err, "Failed to update Resource's status")
+			return ctrl.Result{}, err
+		}
+		resource = resourceResumed
+	}
+
+	resourceRef, err := resourceRefOf(ctx, r.ResourceRefs, r.Client, resource.Spec.ResourceRef)
+	if err != nil {
 		logWithResource.Error(err, "unable to fetch ResourceRef", "resourceRef", resource.Name)
+		if deleting {
+			if !apierrors.IsNotFound(err) {
+				return ctrl.Result{}, err
+			}
+			// without the ResourceRef there is no way to know what must be destroyed; releasing the Resource would
+			// leave its infrastructure behind, so it waits for the ResourceRef to come back
+			_, err := r.newResourceCondition(ctx, resource, &metav1.Condition{
+				Type:   resourcesv1alpha1.ConditionTypeFailed,
+				Status: metav1.ConditionFalse,
+				Reason: resourcesv1alpha1.ConditionReasonDependencyNotReady,
+				Message: fmt.Sprintf("Unable to destroy Resource %s: ResourceRef %s doesn't exist; restore it, or remove the finalizer %s to release the Resource without destroying anything",
+					resource.Name, resource.Spec.ResourceRef, ResourceDestroyFinalizer),
+			})
+			return ctrl.Result{RequeueAfter: time.Minute}, err
+		}
 		return ctrl.Result{Requeue: false}, nil
 	}
 
+	if !deleting {
+		republished, err := r.republishOutputs(ctx, resource, resourceRef)
+		if err != nil {
+			logWithResource.Error(err, "failed to republish Resource outputs")
+			return ctrl.Result{}, err
+		}
+		if republished {
+			return ctrl.Result{}, nil
+		}
+	}
+
 	resourceRefProvisioner := resourceRef.Spec.Provisioner
 	provisionerName := resourceRefProvisioner.Name
 
@@ -89,56 +222,199 @@ func (r *ResourceReconciler) Reconcile(ctx context.Context, resource *resourcesv
 	if err != nil {
 		logWithProvisioner.Error(err, fmt.Sprintf("unsupported ResourceRef provisioner: %s", resourceRefProvisioner))
 
+		// plugins are registered by their own controller, which may not have caught up yet, like right after a restart
+		if errors.Is(err, provisioning.ErrUnsupportedProvisioner) {
+			declared, pluginErr := r.pluginDeclared(ctx, string(provisionerName))
+			if pluginErr != nil {
+				return ctrl.Result{}, pluginErr
+			}
+			if declared {
+				logWithProvisioner.Info(fmt.Sprintf("provisioner plugin %s is not registered yet; waiting for it...", provisionerName))
+				return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}, nil
+			}
+		}
+
+		// a disabled provisioner may be enabled again; releasing the Resource would leave its infrastructure behind
+		if deleting && errors.Is(err, provisioning.ErrProvisionerDisabled) {
+			_, err := r.newResourceCondition(ctx, resource, &metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeFailed,
+				Status:  metav1.ConditionFalse,
+				Reason:  resourcesv1alpha1.ConditionReasonInputError,
+				Message: fmt.Sprintf("Unable to destroy Resource %s: provisioner %s is disabled in this installation", resource.Name, provisionerName),
+			})
+			return ctrl.Result{RequeueAfter: time.Minute}, err
+		}
+
+		if deleting {
+			return ctrl.Result{}, r.releaseResource(ctx, resource)
+		}
+
 		_, err := r.newResourceCondition(ctx, resource, &metav1.Condition{
 			Type:    resourcesv1alpha1.ConditionTypeFailed,
 			Status:  metav1.ConditionFalse,
-			Reason:  resourcesv1alpha1.ConditionReasonFailed,
-			Message: fmt.Sprintf("Unsupported ResourceRef provisioner: %s", provisionerName),
+			Reason:  resourcesv1alpha1.ConditionReasonInputError,
+			Message: fmt.Sprintf("Unsupported ResourceRef provisioner %s: %s", provisionerName, err.Error()),
 		})
 
 		return ctrl.Result{Requeue: false}, err
 	}
 
-	provisioner, err := provisionerFactory(r.Client, r.DynamicClient, r.Scheme, logWithProvisioner, &resourceRef.Spec.Provisioner)
+	remote, err := r.remoteOf(ctx, resource, deleting)
+	if err != nil {
+		logWithProvisioner.Error(err, fmt.Sprintf("unable to connect to the cluster of placement %s", resource.Spec.Placement))
+
+		resource.Status.Phase = resourcesv1alpha1.DeploymentFailedPhase
+		_, conditionErr := r.newResourceCondition(ctx, resource, &metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeFailed,
+			Status:  metav1.ConditionFalse,
+			Reason:  failureReasonOf(err, resourcesv1alpha1.ConditionReasonDependencyNotReady),
+			Message: fmt.Sprintf("Unable to connect to the cluster of placement %s: %s", resource.Spec.Placement, err.Error()),
+		})
+		if conditionErr != nil {
+			return ctrl.Result{}, conditionErr
+		}
+		return ctrl.Result{RequeueAfter: time.Duration(30) * time.Second}, nil
+	}
+
+	provisionerClient, provisionerDynamicClient := r.Client, r.DynamicClient
+	if remote != nil {
+		logWithProvisioner = logWithProvisioner.WithValues("cluster", resource.Spec.Placement)
+		provisionerClient, provisionerDynamicClient = remote.Client, remote.DynamicClient
+	}
+
+	provisioner, err := provisionerFactory(provisionerClient, provisionerDynamicClient, r.Scheme, logWithProvisioner, &resourceRef.Spec.Provisioner)
 	if err != nil {
 		logWithProvisioner.Error(err, fmt.Sprintf("unsupported ResourceRef provisioner: %s; unable to create a Provisioner instance", provisionerName))
 
+		if deleting {
+			return ctrl.Result{}, r.releaseResource(ctx, resource)
+		}
+
 		_, err := r.newResourceCondition(ctx, resource, &metav1.Condition{
 			Type:    resourcesv1alpha1.ConditionTypeFailed,
 			Status:  metav1.ConditionFalse,
-			Reason:  resourcesv1alpha1.ConditionReasonFailed,
-			Message: fmt.Sprintf("Unsupported ResourceRef provisioner: %s", provisionerName),
+			Reason:  resourcesv1alpha1.ConditionReasonInputError,
+			Message: fmt.Sprintf("Unsupported ResourceRef provisioner %s: %s", provisionerName, err.Error()),
 		})
 
 		return ctrl.Result{Requeue: false}, err
 	}
 
+	if deleting {
+		return r.destroy(ctx, resource, resourceRef, provisioner, remote, logWithProvisioner)
+	}
+
+	consumedSecrets, err := consumedSecretsOf(ctx, provisionerClient, resource, provisioner)
+	if err != nil {
+		logWithProvisioner.Error(err, "unable to read the Secrets consumed by the provisioner")
+		return ctrl.Result{}, err
+	}
+	if rotated := rotatedSecretsOf(resource, consumedSecrets); len(rotated) != 0 {
+		hold, err := r.rotateSecrets(ctx, resource, resourceRef, rotated)
+		if err != nil || hold {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err := r.forceRefresh(ctx, resource); err != nil {
+		logWithProvisioner.Error(err, "unable to request a refresh of the provisioner object")
+		return ctrl.Result{}, err
+	}
+
 	logWithProvisioner.Info(fmt.Sprintf("Running provisioner: %s", provisionerName))
 
-	status, err := provisioner.Run(ctx, resource)
+	// the provisioner reads the secret properties too, but the Resource is never updated with them
+	provisioned, err := withSecretProperties(ctx, r.Client, resource)
+	if err != nil {
+		logWithProvisioner.Error(err, "unable to read the secret properties")
+		return ctrl.Result{}, err
+	}
+
+	status, err := provisioner.Run(ctx, provisioned)
+	// provisioners may have patched the metadata of the copy
+	resource.ObjectMeta = provisioned.ObjectMeta
 
 	if err != nil {
 		logWithProvisioner.Error(err, fmt.Sprintf("failed to run %s provisioner", provisionerName))
 
+		budget := r.RetryBudget
+		if budget <= 0 {
+			budget = DefaultProvisionerRetryBudget
+		}
+
+		if provisioning.IsTransient(err) && resource.Status.Retries < budget {
+			resource.Status.Retries++
+			delay := retryBackoff(resource.Status.Retries)
+
+			logWithProvisioner.Info(fmt.Sprintf("transient error from %s provisioner; retrying in %s (attempt %d of %d)", provisionerName, delay, resource.Status.Retries, budget))
+
+			resource.Status.Phase = resourcesv1alpha1.DeploymentInProgressPhase
+			_, conditionErr := r.newResourceCondition(ctx, resource, &metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeInProgress,
+				Status:  metav1.ConditionTrue,
+				Reason:  resourcesv1alpha1.ConditionReasonRetrying,
+				Message: fmt.Sprintf("Transient error from provisioner %s (attempt %d of %d): %s", provisionerName, resource.Status.Retries, budget, err.Error()),
+			})
+			if conditionErr != nil {
+				return ctrl.Result{}, conditionErr
+			}
+
+			return ctrl.Result{RequeueAfter: delay}, nil
+		}
+
+		message := fmt.Sprintf("Failed to run provisioner %s: %s", provisionerName, err.Error())
+		if provisioning.IsTransient(err) {
+			message = fmt.Sprintf("Failed to run provisioner %s after %d retries: %s", provisionerName, resource.Status.Retries, err.Error())
+		}
+
+		resource.Status.Phase = resourcesv1alpha1.DeploymentFailedPhase
+		resource.Status.Retries = 0
 		_, err := r.newResourceCondition(ctx, resource, &metav1.Condition{
 			Type:    resourcesv1alpha1.ConditionTypeFailed,
 			Status:  metav1.ConditionFalse,
-			Reason:  resourcesv1alpha1.ConditionReasonFailed,
-			Message: fmt.Sprintf("Failed to run provisioner: %s", provisionerName),
+			Reason:  failureReasonOf(err, resourcesv1alpha1.ConditionReasonProvisionerError),
+			Message: message,
 		})
 
 		return ctrl.Result{Requeue: false}, err
 	}
 
+	resource.Status.Retries = 0
+
 	logWithResource.Info(fmt.Sprintf("Current state from %s provisioning is %s", provisionerName, status.State))
 
-	if status.IsRunning() {
-		return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}, nil
+	if status.IsRunning() || refreshPending(resource, status) {
+		return r.waitFor(status, remote), nil
 	}
 
 	phase, condition := statusToCondition(status, resource)
 
-	resource.Status.Phase = resourcesv1alpha1.ResourceStatusDescription(phase)
+	// a provisioned Resource is only done once its verification Job succeeds
+	if phase == resourcesv1alpha1.DeploymentDonePhase && resource.Spec.Verification != nil {
+		verificationPhase, verificationCondition, err := r.verify(ctx, resource, status.Outputs)
+		if err != nil {
+			logWithResource.Error(err, "failed to verify the provisioned resource")
+			return ctrl.Result{}, err
+		}
+		if verificationCondition != nil {
+			phase, condition = verificationPhase, verificationCondition
+		}
+	}
+
+	resource.Status.Phase = phase
+	if phase == resourcesv1alpha1.DeploymentDonePhase {
+		resource.Status.ObservedGeneration = resource.Generation
+
+		hash, err := provisioningHashOf(resource.Spec)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		resource.Status.ProvisionedSpecHash = hash
+
+		rotationApplied(resource, consumedSecrets)
+	}
+
+	driftToCondition(status, resource)
 
 	if status.Resource != nil {
 		resource.Status.Provisioner = resourcesv1alpha1.ResourceStatusProvisioner{
@@ -151,13 +427,63 @@ func (r *ResourceReconciler) Reconcile(ctx context.Context, resource *resourcesv
 			},
 		}
 	}
+	if status.Inventory != nil {
+		inventory := make([]resourcesv1alpha1.ResourceStatusInventoryEntry, 0, len(status.Inventory))
+		for _, entry := range status.Inventory {
+			inventory = append(inventory, resourcesv1alpha1.ResourceStatusInventoryEntry{
+				Type: entry.Type,
+				Name: entry.Name,
+				ID:   entry.ID,
+			})
+		}
+		resource.Status.Inventory = inventory
+	}
 	if status.Outputs != nil {
-		outputAsJson, err := json.Marshal(status.Outputs)
+		store, err := outputs.SelectStoreOf(r.Client, resourceRef)
 		if err != nil {
-			logWithResource.Error(err, "failed to unmarshall provisioned resource outputs")
+			logWithResource.Error(err, "unsupported output store")
 			return ctrl.Result{Requeue: false}, err
 		}
-		resource.Status.Outputs = &runtime.RawExtension{Raw: outputAsJson}
+
+		previous, err := store.Load(ctx, resource)
+		if err != nil {
+			logWithResource.Error(err, "failed to load previous resource outputs")
+			return ctrl.Result{}, err
+		}
+
+		// a successful run returning fewer outputs breaks the resources consuming them; warn before publishing, and
+		// keep the outputs they read until the removal is acknowledged
+		held := false
+		if phase == resourcesv1alpha1.DeploymentDonePhase {
+			held, err = r.removedOutputsToCondition(ctx, resource, previous, status.Outputs)
+			if err != nil {
+				logWithResource.Error(err, "failed to check removed resource outputs")
+				return ctrl.Result{}, err
+			}
+		}
+
+		if held {
+			logWithResource.Info("outputs consumed by other resources were removed; they aren't published until the removal is acknowledged")
+		} else {
+			if err := store.Save(ctx, resource, status.Outputs); err != nil {
+				logWithResource.Error(err, "failed to save provisioned resource outputs")
+				return ctrl.Result{}, err
+			}
+			if err := outputs.Export(ctx, r.Client, resource, status.Outputs, resourceRef.Spec.SensitiveOutputs); err != nil {
+				logWithResource.Error(err, "failed to write provisioned resource outputs")
+				return ctrl.Result{}, err
+			}
+
+			// the outputs are only as fresh as the last run producing them; reading the same ones again refreshes nothing
+			refreshed := phase == resourcesv1alpha1.DeploymentDonePhase && resource.Status.RefreshRequestedAt != nil
+			if refreshed || outputsChanged(previous, status.Outputs) {
+				refreshedAt := metav1.Now()
+				resource.Status.OutputsRefreshedAt = &refreshedAt
+			}
+		}
+	}
+	if phase == resourcesv1alpha1.DeploymentDonePhase {
+		resource.Status.RefreshRequestedAt = nil
 	}
 
 	_, err = r.newResourceCondition(ctx, resource, condition)
@@ -169,7 +495,177 @@ func (r *ResourceReconciler) Reconcile(ctx context.Context, resource *resourcesv
 	return ctrl.Result{}, nil
 }
 
-func statusToCondition(status *provisioning.ProvisionedResourceStatus, resource *resourcesv1alpha1.Resource) (string, *metav1.Condition) {
+// destroy tears down the provisioned infrastructure, releasing the Resource only when the provisioner is done
+func (r *ResourceReconciler) destroy(ctx context.Context, resource *resourcesv1alpha1.Resource, resourceRef *resourcesv1alpha1.ResourceRef, provisioner provisioning.Provisioner, remote *clusters.Remote, log logr.Logger) (ctrl.Result, error) {
+	store, err := outputs.SelectStoreOf(r.Client, resourceRef)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	hooks := destroyHooksOf(resource)
+	if hooks != nil && hooks.PreDestroy != nil {
+		condition, err := r.runHook(ctx, resource, store, preDestroyHook, hooks.PreDestroy)
+		if err != nil {
+			log.Error(err, "failed to run the pre-destroy hook")
+			return ctrl.Result{}, err
+		}
+		if condition != nil {
+			return r.holdTeardown(ctx, resource, condition)
+		}
+	}
+
+	log.Info(fmt.Sprintf("Resource %s is being deleted; destroying provisioned infrastructure...", resource.Name))
+
+	// a Secret gone with the secret properties doesn't hold the teardown back
+	provisioned, err := withSecretProperties(ctx, r.Client, resource)
+	if err != nil {
+		log.Error(err, "destroying provisioned infrastructure without the secret properties")
+		provisioned = resource
+	}
+
+	status, err := provisioner.Destroy(ctx, provisioned)
+	resource.ObjectMeta = provisioned.ObjectMeta
+	if err != nil {
+		log.Error(err, "failed to destroy provisioned infrastructure")
+
+		resource.Status.Phase = resourcesv1alpha1.DeploymentFailedPhase
+		_, conditionErr := r.newResourceCondition(ctx, resource, &metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeFailed,
+			Status:  metav1.ConditionFalse,
+			Reason:  failureReasonOf(err, resourcesv1alpha1.ConditionReasonProvisionerError),
+			Message: fmt.Sprintf("Failed to destroy Resource %s: %s", resource.Name, err.Error()),
+		})
+		if conditionErr != nil {
+			return ctrl.Result{}, conditionErr
+		}
+		return ctrl.Result{RequeueAfter: time.Duration(30) * time.Second}, nil
+	}
+
+	switch status.State {
+	case provisioning.ProvisionedResourceSuccessState:
+		log.Info(fmt.Sprintf("Resource %s was destroyed", resource.Name))
+
+		if hooks != nil && hooks.PostDestroy != nil {
+			condition, err := r.runHook(ctx, resource, store, postDestroyHook, hooks.PostDestroy)
+			if err != nil {
+				log.Error(err, "failed to run the post-destroy hook")
+				return ctrl.Result{}, err
+			}
+			if condition != nil {
+				return r.holdTeardown(ctx, resource, condition)
+			}
+		}
+
+		// the output store forgets the outputs of the destroyed Resource
+		if err := store.Delete(ctx, resource); err != nil {
+			log.Error(err, "failed to delete Resource outputs")
+			return ctrl.Result{}, err
+		}
+		if err := outputs.Unexport(ctx, r.Client, resource); err != nil {
+			log.Error(err, "failed to delete the objects Resource outputs were written to")
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, r.releaseResource(ctx, resource)
+
+	case provisioning.ProvisionedResourceFailedState:
+		resource.Status.Phase = resourcesv1alpha1.DeploymentFailedPhase
+		_, err := r.newResourceCondition(ctx, resource, &metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeFailed,
+			Status:  metav1.ConditionFalse,
+			Reason:  resourcesv1alpha1.ConditionReasonProvisionerError,
+			Message: fmt.Sprintf("Destruction of Resource %s failed", resource.Name),
+		})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: time.Duration(30) * time.Second}, nil
+	}
+
+	if condition := meta.FindStatusCondition(resource.Status.Conditions, resourcesv1alpha1.ConditionTypeInProgress); condition == nil || condition.Reason != resourcesv1alpha1.ConditionReasonDestroying {
+		resource.Status.Phase = resourcesv1alpha1.DeploymentInProgressPhase
+		_, err := r.newResourceCondition(ctx, resource, &metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeInProgress,
+			Status:  metav1.ConditionTrue,
+			Reason:  resourcesv1alpha1.ConditionReasonDestroying,
+			Message: fmt.Sprintf("Resource %s is being destroyed...", resource.Name),
+		})
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	return r.waitFor(status, remote), nil
+}
+
+// remoteOf returns the connection to the cluster of the Resource's placement; nil means the local cluster
+func (r *ResourceReconciler) remoteOf(ctx context.Context, resource *resourcesv1alpha1.Resource, deleting bool) (*clusters.Remote, error) {
+	if r.Clusters == nil || resource.Spec.Placement == "" {
+		return nil, nil
+	}
+
+	placement := &resourcesv1alpha1.Placement{}
+	if err := r.Get(ctx, types.NamespacedName{Name: resource.Spec.Placement}, placement); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	remote, err := r.Clusters.RemoteOf(ctx, placement)
+	if err != nil || remote == nil {
+		return nil, err
+	}
+
+	if !deleting {
+		if err := remote.EnsureNamespace(ctx, resource.Namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	return remote, nil
+}
+
+// waitFor waits for changes on the provisioner object when it's watched, and polls it otherwise
+func (r *ResourceReconciler) waitFor(status *provisioning.ProvisionedResourceStatus, remote *clusters.Remote) ctrl.Result {
+	if status.Resource != nil {
+		watchedKinds := r.watchedKinds
+		if remote != nil {
+			watchedKinds = remote.WatchedKinds
+		}
+		if watchedKinds[status.Resource.GroupKind()] {
+			return ctrl.Result{}
+		}
+	}
+	return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}
+}
+
+// pluginDeclared tells whether there is a ProvisionerPlugin with the name of a provisioner
+func (r *ResourceReconciler) pluginDeclared(ctx context.Context, name string) (bool, error) {
+	if err := r.Get(ctx, types.NamespacedName{Name: name}, &resourcesv1alpha1.ProvisionerPlugin{}); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return true, nil
+}
+
+// releaseResource removes the destroy finalizer, letting the Resource go
+func (r *ResourceReconciler) releaseResource(ctx context.Context, resource *resourcesv1alpha1.Resource) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, types.NamespacedName{Namespace: resource.Namespace, Name: resource.Name}, resource); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if !controllerutil.RemoveFinalizer(resource, ResourceDestroyFinalizer) {
+			return nil
+		}
+		if err := r.Update(ctx, resource); err != nil {
+			return err
+		}
+		forgetResourceMetrics(resource.Namespace, resource.Name)
+		return nil
+	})
+}
+
+func statusToCondition(status *provisioning.ProvisionedResourceStatus, resource *resourcesv1alpha1.Resource) (resourcesv1alpha1.DeploymentPhase, *metav1.Condition) {
 	switch status.State {
 	case provisioning.ProvisionedResourceSuccessState:
 		return resourcesv1alpha1.DeploymentDonePhase, &metav1.Condition{
@@ -182,7 +678,7 @@ func statusToCondition(status *provisioning.ProvisionedResourceStatus, resource
 		return resourcesv1alpha1.DeploymentFailedPhase, &metav1.Condition{
 			Type:    resourcesv1alpha1.ConditionTypeFailed,
 			Status:  metav1.ConditionFalse,
-			Reason:  resourcesv1alpha1.ConditionReasonDeploymentFailed,
+			Reason:  resourcesv1alpha1.ConditionReasonProvisionerError,
 			Message: fmt.Sprintf("Deployment from Resource %s failed", resource.Name),
 		}
 	default:
@@ -195,8 +691,48 @@ func statusToCondition(status *provisioning.ProvisionedResourceStatus, resource
 	}
 }
 
+// driftToCondition applies the Resource's drift policy to the drift reported by the provisioner
+func driftToCondition(status *provisioning.ProvisionedResourceStatus, resource *resourcesv1alpha1.Resource) {
+	policy := resource.Spec.DriftPolicy
+	if policy == "" {
+		policy = resourcesv1alpha1.DriftPolicyCorrect
+	}
+
+	metricLabels := prometheus.Labels{
+		"namespace": resource.Namespace,
+		"name":      resource.Name,
+		"placement": resource.Spec.Placement,
+		"policy":    string(policy),
+	}
+
+	if !status.Drifted || policy == resourcesv1alpha1.DriftPolicyIgnore {
+		resourceDrifted.With(metricLabels).Set(0)
+		meta.RemoveStatusCondition(&resource.Status.Conditions, resourcesv1alpha1.ConditionTypeDrifted)
+		return
+	}
+
+	resourceDrifted.With(metricLabels).Set(1)
+
+	if policy == resourcesv1alpha1.DriftPolicyWarn {
+		setStatusCondition(&resource.Status.Conditions, metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeDrifted,
+			Status:  metav1.ConditionTrue,
+			Reason:  resourcesv1alpha1.ConditionReasonDriftDetected,
+			Message: fmt.Sprintf("Resource %s diverges from its declared state; drift policy is %s, so it won't be corrected", resource.Name, policy),
+		})
+		return
+	}
+
+	setStatusCondition(&resource.Status.Conditions, metav1.Condition{
+		Type:    resourcesv1alpha1.ConditionTypeDrifted,
+		Status:  metav1.ConditionTrue,
+		Reason:  resourcesv1alpha1.ConditionReasonDriftCorrecting,
+		Message: fmt.Sprintf("Resource %s diverges from its declared state; drift policy is %s, so it is being re-applied", resource.Name, policy),
+	})
+}
+
 func (r *ResourceReconciler) newResourceCondition(ctx context.Context, resource *resourcesv1alpha1.Resource, newCondition *metav1.Condition) (*resourcesv1alpha1.Resource, error) {
-	meta.SetStatusCondition(&resource.Status.Conditions, *newCondition)
+	setStatusCondition(&resource.Status.Conditions, *newCondition)
 	if err := r.Status().Update(ctx, resource); err != nil {
 		return nil, err
 	}
@@ -208,7 +744,87 @@ func (r *ResourceReconciler) newResourceCondition(ctx context.Context, resource
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ResourceReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&resourcesv1alpha1.Resource{}).
-		Complete(reconcile.AsReconciler(mgr.GetClient(), r))
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &resourcesv1alpha1.Resource{}, consumedSecretsIndex, consumedSecretKeysOf); err != nil {
+		return err
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&resourcesv1alpha1.Resource{}, builder.WithPredicates(shardPredicate(r.ShardSelector))).
+		Owns(&batchv1.Job{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.resourcesConsumingSecret))
+
+	// provisioner objects are watched through a cache of their own, restricted to the ones created by provisioners;
+	// the client doesn't cache unstructured objects, so they're still read from the API server
+	provisionerObjects, err := cache.New(mgr.GetConfig(), cache.Options{
+		HTTPClient:           mgr.GetHTTPClient(),
+		Scheme:               mgr.GetScheme(),
+		Mapper:               mgr.GetRESTMapper(),
+		DefaultLabelSelector: provisioning.ControlledObjectsSelector,
+	})
+	if err != nil {
+		return err
+	}
+	if err := mgr.Add(provisionerObjects); err != nil {
+		return err
+	}
+
+	// provisioner objects are only watched when their CRDs are installed
+	r.watchedKinds = make(map[schema.GroupKind]bool)
+	for _, gvk := range provisioning.ControlledKinds {
+		if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			mgr.GetLogger().Info(fmt.Sprintf("%s is not available; Resources provisioned with it will be polled", gvk.Kind))
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		b = b.WatchesRawSource(source.Kind(provisionerObjects, client.Object(obj),
+			handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &resourcesv1alpha1.Resource{}, handler.OnlyControllerOwner())))
+
+		r.watchedKinds[gvk.GroupKind()] = true
+	}
+
+	c, err := b.Build(reconcile.AsReconciler(mgr.GetClient(), r))
+	if err != nil {
+		return err
+	}
+
+	if r.Clusters != nil {
+		r.Clusters.OnConnect(func(placement string, remote *clusters.Remote) error {
+			return r.watchRemote(mgr, c, placement, remote)
+		})
+	}
+
+	return nil
+}
+
+// watchRemote watches the provisioner objects of a remote cluster, while connected to it; they have no owner
+// references there, so they are mapped back to their Resources by their labels
+func (r *ResourceReconciler) watchRemote(mgr ctrl.Manager, c controller.Controller, placement string, remote *clusters.Remote) error {
+	for _, gvk := range provisioning.ControlledKinds {
+		if _, err := remote.Cluster.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			mgr.GetLogger().Info(fmt.Sprintf("%s is not available on the cluster of placement %s; Resources provisioned with it will be polled", gvk.Kind, placement))
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+
+		if err := c.Watch(remote.Watch(obj, resourceOfProvisionerObject)); err != nil {
+			return err
+		}
+
+		remote.WatchedKinds[gvk.GroupKind()] = true
+	}
+	return nil
+}
+
+func resourceOfProvisionerObject(ctx context.Context, obj client.Object) []reconcile.Request {
+	objLabels := obj.GetLabels()
+	if objLabels[resourcesv1alpha1.Group+"/managedBy.kind"] != "Resource" || objLabels[resourcesv1alpha1.Group+"/managedBy.name"] == "" {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: objLabels[resourcesv1alpha1.Group+"/managedBy.name"]}},
+	}
 }
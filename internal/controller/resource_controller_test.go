@@ -18,9 +18,12 @@ package controller
 
 import (
 	"context"
+	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/assert"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -84,3 +87,13 @@ var _ = Describe("Resource Controller", func() {
 		})
 	})
 })
+
+func Test_RetryBackoff(t *testing.T) {
+
+	t.Run("We should back off exponentially up to a maximum delay", func(t *testing.T) {
+		assert.Equal(t, 5*time.Second, retryBackoff(1))
+		assert.Equal(t, 10*time.Second, retryBackoff(2))
+		assert.Equal(t, 40*time.Second, retryBackoff(4))
+		assert.Equal(t, 5*time.Minute, retryBackoff(20))
+	})
+}
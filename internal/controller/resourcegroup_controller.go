@@ -19,9 +19,12 @@ package controller
 import (
 	"context"
 	"fmt"
+	"maps"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -31,21 +34,46 @@ import (
 	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/artifacts"
+	"github.com/nubank/klaudio/internal/generated"
+	"github.com/nubank/klaudio/internal/ipam"
 )
 
 // ResourceGroupReconciler reconciles a ResourceGroup object
 type ResourceGroupReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// APIServerURL is the address written to the read-only kubeconfig of the groups
+	APIServerURL string
+	// ResourceRefs serves ResourceRefs from the shared cache; without it, they're read with the client
+	ResourceRefs *ResourceRefs
+	// Artifacts loads the resources of the groups with a sourceRef; without it, artifacts are downloaded on every
+	// reconciliation
+	Artifacts *artifacts.Loader
+	// APIReader lists the Pods, Secrets, ConfigMaps and ServiceAccounts of a single namespace straight from the API
+	// server, so the ones of the whole cluster are never cached; without it, they're read with the client
+	APIReader client.Reader
+}
+
+// namespacedReader reads the core objects inside the namespaces of the group
+func (r *ResourceGroupReconciler) namespacedReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
 }
 
 // +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcegroups,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcegroups/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcegroups/finalizers,verbs=update
+// +kubebuilder:rbac:groups=core,resources=configmaps;secrets;serviceaccounts;pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets;serviceaccounts,verbs=create;update;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -73,77 +101,183 @@ func (r *ResourceGroupReconciler) Reconcile(ctx context.Context, resourceGroup *
 		resourceGroup = resourceGroupWithCondition
 	}
 
-	log.Info(fmt.Sprintf("current status phase is %s", resourceGroup.Status.Phase))
-
-	// step 1: generate a dedicated namespace to resource group
-	namespace := &corev1.Namespace{}
-	if err := r.Get(ctx, types.NamespacedName{Name: resourceGroup.Name}, namespace); err != nil {
-		if !apierrors.IsNotFound(err) {
-			log.Error(err, "unable to fetch ResourceGroup's namespace")
-			return ctrl.Result{}, err
-		}
+	// resources loaded from the artifact of the sourceRef are deployed together with the declared ones
+	resources, loaded, err := r.resourcesOf(ctx, resourceGroup)
+	if err != nil {
+		log.Error(err, "unable to load the resources of ResourceGroup")
+		return ctrl.Result{}, err
+	}
+	if !loaded {
+		return ctrl.Result{RequeueAfter: sourcePollInterval}, nil
+	}
 
-		log.Info(fmt.Sprintf("there is no namespace to ResourceGroup %s; trying to generate...", resourceGroup.Name))
+	missing, err := missingCapabilitiesOf(ctx, r.ResourceRefs, r.Client, resourceGroup, resources)
+	if err != nil {
+		log.Error(err, "unable to check the capabilities required by ResourceGroup")
+		return ctrl.Result{}, err
+	}
 
-		namespace.Name = resourceGroup.Name
-		namespace.Labels = map[string]string{
-			resourcesv1alpha1.Group + "/managedBy.group":   resourceGroup.GroupVersionKind().Group,
-			resourcesv1alpha1.Group + "/managedBy.version": resourceGroup.GroupVersionKind().Version,
-			resourcesv1alpha1.Group + "/managedBy.kind":    resourceGroup.GroupVersionKind().Kind,
-			resourcesv1alpha1.Group + "/managedBy.name":    resourceGroup.Name,
+	unsupported := meta.FindStatusCondition(resourceGroup.Status.Conditions, resourcesv1alpha1.ConditionTypeUnsupported)
+	if len(missing) > 0 {
+		log.Info(fmt.Sprintf("ResourceGroup requires unsupported capabilities %v; skipping reconciliation...", missing))
+		condition := unsupportedCondition("ResourceGroup", resourceGroup.Name, missing)
+		if unsupported == nil || unsupported.Status != metav1.ConditionTrue || unsupported.Message != condition.Message {
+			if _, err := r.newResourceGroupCondition(ctx, resourceGroup, condition); err != nil {
+				log.Error(err, "Failed to update ResourceGroup status")
+				return ctrl.Result{}, err
+			}
 		}
-		if err := ctrl.SetControllerReference(resourceGroup, namespace, r.Scheme); err != nil {
-			log.Error(err, "unable to set namespace's ownerReference", "namespace", namespace.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if unsupported != nil && unsupported.Status == metav1.ConditionTrue {
+		resourceGroupSupported, err := r.newResourceGroupCondition(ctx, resourceGroup, supportedCondition("ResourceGroup", resourceGroup.Name))
+		if err != nil {
+			log.Error(err, "Failed to update ResourceGroup status")
 			return ctrl.Result{}, err
 		}
+		resourceGroup = resourceGroupSupported
+	}
 
-		if err := r.Create(ctx, namespace); err != nil {
-			log.Error(err, fmt.Sprintf("unable to create namespace %s", namespace.Name), "namespace", namespace.Name)
-
-			_, err = r.newResourceGroupCondition(ctx, resourceGroup, &metav1.Condition{
-				Type:    resourcesv1alpha1.ConditionTypeFailed,
-				Status:  metav1.ConditionFalse,
-				Reason:  resourcesv1alpha1.ConditionReasonFailed,
-				Message: fmt.Sprintf("Unable to create a namespace to ResourceGroup %s", resourceGroup.Name),
-			})
-			if err != nil {
-				log.Error(err, "failed to update ResourceGroup's status")
+	suspended := meta.IsStatusConditionTrue(resourceGroup.Status.Conditions, resourcesv1alpha1.ConditionTypeSuspended)
+	if resourceGroup.Spec.Suspend {
+		log.Info("ResourceGroup is suspended; skipping reconciliation...")
+		if !suspended {
+			if _, err := r.newResourceGroupCondition(ctx, resourceGroup, suspendedCondition("ResourceGroup", resourceGroup.Name)); err != nil {
+				log.Error(err, "Failed to update ResourceGroup status")
 				return ctrl.Result{}, err
 			}
-
-			return ctrl.Result{Requeue: false}, err
 		}
+		return ctrl.Result{}, nil
+	}
 
-		log.Info(fmt.Sprintf("a namespace was created to ResourceGroup %s", resourceGroup.Name))
+	if suspended {
+		resourceGroupResumed, err := r.newResourceGroupCondition(ctx, resourceGroup, resumedCondition("ResourceGroup", resourceGroup.Name))
+		if err != nil {
+			log.Error(err, "Failed to update ResourceGroup status")
+			return ctrl.Result{}, err
+		}
+		resourceGroup = resourceGroupResumed
 	}
 
-	namespacedLog := log.WithValues("resourceGroupNamespace", namespace.Name)
+	log.Info(fmt.Sprintf("current status phase is %s", resourceGroup.Status.Phase))
 
 	knowPlacements := sets.NewString()
+	resourceRefsReady := true
 
 	// step 1: traverse all resources and collect deployment placements
-	for _, resource := range resourceGroup.Spec.Resources {
+	for _, resource := range resources {
 		// every resource must reference a ResourceRef object
-		resourceRef := &resourcesv1alpha1.ResourceRef{}
-		if err := r.Get(ctx, types.NamespacedName{Name: resource.ResourceRef}, resourceRef); err != nil {
-			namespacedLog.Error(err, "unable to fetch ResourceRef", "resourceRef", resource.ResourceRef)
-			return ctrl.Result{Requeue: false}, nil
+		resourceRef, err := resourceRefOf(ctx, r.ResourceRefs, r.Client, resource.ResourceRef)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				log.Error(err, "unable to fetch ResourceRef", "resourceRef", resource.ResourceRef)
+				return ctrl.Result{}, err
+			}
+
+			// applied together with the group, maybe; its creation triggers a new reconciliation
+			log.Info(fmt.Sprintf("ResourceRef %s doesn't exist yet; waiting for it...", resource.ResourceRef))
+
+			if _, err := r.newResourceGroupCondition(ctx, resourceGroup, &metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeInitializing,
+				Status:  metav1.ConditionTrue,
+				Reason:  resourcesv1alpha1.ConditionReasonResourceRefNotFound,
+				Message: fmt.Sprintf("Waiting for ResourceRef %s, used by resource %s; the ResourceGroup is deployed once it's created", resource.ResourceRef, resource.Name),
+			}); err != nil {
+				log.Error(err, "unable to update ResourceGroups's status")
+				return ctrl.Result{}, err
+			}
+
+			return ctrl.Result{}, nil
 		}
 
 		knowPlacements = knowPlacements.Insert(resourceRef.Status.Placements...)
+		resourceRefsReady = resourceRefsReady && resourceRef.Status.Status == resourcesv1alpha1.ResourceRefStatusReady
+	}
+
+	if resourceGroup.Spec.PlacementSelector != nil {
+		selected, err := selectPlacements(ctx, r.Client, resourceGroup.Spec.PlacementSelector)
+		if err != nil {
+			log.Error(err, "unable to select Placements")
+			return ctrl.Result{}, err
+		}
+		knowPlacements = knowPlacements.Intersection(sets.NewString(selected...))
+	}
+
+	// with the Manual placement approval policy, deployments aren't created or destroyed until the changes are approved
+	knowPlacements, err = r.approvePlacementChanges(ctx, resourceGroup, knowPlacements)
+	if err != nil {
+		log.Error(err, "unable to check the placement changes of ResourceGroup")
+		return ctrl.Result{}, err
+	}
+
+	// deployments to placements that aren't known anymore are torn down
+	keptDeployments, err := r.pruneDeployments(ctx, resourceGroup, knowPlacements, resourceRefsReady)
+	if err != nil {
+		log.Error(err, "unable to prune ResourceGroupDeployments")
+		return ctrl.Result{}, err
+	}
+
+	perPlacement := resourceGroup.Spec.NamespaceStrategy == resourcesv1alpha1.NamespacePerPlacement
+
+	// namespaces of placements that aren't known anymore can be collected once their deployments are gone
+	if perPlacement && resourceGroup.Spec.DeleteEmptyNamespace {
+		if err := r.collectPlacementNamespaces(ctx, resourceGroup, knowPlacements); err != nil {
+			log.Error(err, "unable to collect the namespaces of removed placements")
+			return ctrl.Result{}, err
+		}
 	}
 
-	knowDeployments := make(resourcesv1alpha1.ResourceGroupDeploymentStatuses)
+	// there is nothing to deploy anymore; the generated namespace can be collected
+	if !perPlacement && resourceGroup.Spec.DeleteEmptyNamespace && knowPlacements.Len() == 0 {
+		return r.collectEmptyNamespace(ctx, resourceGroup)
+	}
+
+	namespacedLog := log
+	namespaces := make([]string, 0)
+
+	// step 2: generate a dedicated namespace to resource group; with the Placement strategy, each placement gets its own
+	var readOnlyKubeconfig string
+	var readOnlyTokenRenewal time.Duration
+	if !perPlacement {
+		namespace, err := r.generateNamespace(ctx, resourceGroup, "")
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		namespaces = append(namespaces, namespace.Name)
+
+		namespacedLog = log.WithValues("resourceGroupNamespace", namespace.Name)
 
-	// step 2: generate one ResourceGroupDeployment to each placement
+		readOnlyKubeconfig, readOnlyTokenRenewal, err = r.reconcileReadOnlyAccess(ctx, resourceGroup, namespace)
+		if err != nil {
+			namespacedLog.Error(err, "unable to reconcile the read-only access to ResourceGroup's namespace")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// deployments that couldn't be pruned are still reported
+	knowDeployments := maps.Clone(keptDeployments)
+
+	// step 3: generate one ResourceGroupDeployment to each placement
 	for _, placement := range knowPlacements.List() {
 		resourceGroupDeployment := &resourcesv1alpha1.ResourceGroupDeployment{}
 
 		deploymentLog := namespacedLog.WithValues("deployment", placement, "placement", placement)
 
+		if perPlacement {
+			namespace, err := r.generateNamespace(ctx, resourceGroup, placement)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			namespaces = append(namespaces, namespace.Name)
+
+			deploymentLog = deploymentLog.WithValues("resourceGroupNamespace", namespace.Name)
+		}
+
+		namespace := resourceGroup.NamespaceOf(placement)
 		deploymentName := fmt.Sprintf("%s.%s", resourceGroup.Name, placement)
 
-		if err := r.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace.Name}, resourceGroupDeployment); err != nil {
+		if err := r.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace}, resourceGroupDeployment); err != nil {
 			if !apierrors.IsNotFound(err) {
 				deploymentLog.Error(err, "unable to fetch ResourceGroupDeployment")
 				return ctrl.Result{}, err
@@ -158,9 +292,14 @@ func (r *ResourceGroupReconciler) Reconcile(ctx context.Context, resourceGroup *
 				resourcesv1alpha1.Group + "/managedBy.name":    resourceGroup.Name,
 				resourcesv1alpha1.Group + "/placement":         placement,
 			}
-			resourceGroupDeployment.Namespace = namespace.Name
+			resourceGroupDeployment.Namespace = namespace
 			resourceGroupDeployment.Spec.Placement = placement
-			resourceGroupDeployment.Spec.Resources = resourceGroup.Spec.Resources
+			resourceGroupDeployment.Spec.Resources = resources
+			resourceGroupDeployment.Spec.DriftPolicy = resourceGroup.Spec.DriftPolicy
+			resourceGroupDeployment.Spec.BlastRadius = resourceGroup.Spec.BlastRadius
+			resourceGroupDeployment.Spec.Approval = resourceGroup.Spec.Approval
+			resourceGroupDeployment.Spec.ExpressionLanguage = resourceGroup.Spec.ExpressionLanguage
+			resourceGroupDeployment.Spec.TimeToReady = resourceGroup.Spec.TimeToReady
 
 			if err := ctrl.SetControllerReference(resourceGroup, resourceGroupDeployment, r.Scheme); err != nil {
 				deploymentLog.Error(err, "unable to set ResourceGroupDeployment's ownerReference")
@@ -178,11 +317,16 @@ func (r *ResourceGroupReconciler) Reconcile(ctx context.Context, resourceGroup *
 
 		} else {
 			err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				if err = r.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace.Name}, resourceGroupDeployment); err != nil {
+				if err = r.Get(ctx, types.NamespacedName{Name: deploymentName, Namespace: namespace}, resourceGroupDeployment); err != nil {
 					return err
 				}
 				resourceGroupDeployment.Spec.Placement = placement
-				resourceGroupDeployment.Spec.Resources = resourceGroup.Spec.Resources
+				resourceGroupDeployment.Spec.Resources = resources
+				resourceGroupDeployment.Spec.DriftPolicy = resourceGroup.Spec.DriftPolicy
+				resourceGroupDeployment.Spec.BlastRadius = resourceGroup.Spec.BlastRadius
+				resourceGroupDeployment.Spec.Approval = resourceGroup.Spec.Approval
+				resourceGroupDeployment.Spec.ExpressionLanguage = resourceGroup.Spec.ExpressionLanguage
+				resourceGroupDeployment.Spec.TimeToReady = resourceGroup.Spec.TimeToReady
 				return r.Update(ctx, resourceGroupDeployment)
 			})
 			if err != nil {
@@ -204,18 +348,36 @@ func (r *ResourceGroupReconciler) Reconcile(ctx context.Context, resourceGroup *
 			currentGroupPhase = resourcesv1alpha1.DeploymentInProgressPhase
 			break
 		}
+		if knowDeployment.Phase == resourcesv1alpha1.DeploymentPendingApprovalPhase {
+			// keep looking: a failed or running deployment is more relevant than a pending one
+			currentGroupPhase = resourcesv1alpha1.DeploymentPendingApprovalPhase
+		}
 	}
 
 	log.Info(fmt.Sprintf("next status phase will be %s", currentGroupPhase))
 
-	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+	usage, err := r.usageOf(ctx, namespaces...)
+	if err != nil {
+		namespacedLog.Error(err, "unable to compute ResourceGroup's usage")
+		return ctrl.Result{}, err
+	}
+
+	resourceGroupResources.WithLabelValues(resourceGroup.Name).Set(float64(usage.Resources))
+	resourceGroupActiveRuns.WithLabelValues(resourceGroup.Name).Set(float64(usage.ActiveRuns))
+	resourceGroupRunnerPods.WithLabelValues(resourceGroup.Name).Set(float64(usage.RunnerPods))
+	resourceGroupCPURequests.WithLabelValues(resourceGroup.Name).Set(usage.CPURequests.AsApproximateFloat64())
+	resourceGroupMemoryRequests.WithLabelValues(resourceGroup.Name).Set(usage.MemoryRequests.AsApproximateFloat64())
+
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		// refresh ResourceGroup
 		if err := r.Get(ctx, types.NamespacedName{Name: resourceGroup.Name}, resourceGroup); err != nil {
 			log.Error(err, "unable to refresh ResourceGroup")
 			return err
 		}
 		resourceGroup.Status.Deployments = knowDeployments
-		resourceGroup.Status.Phase = resourcesv1alpha1.ResourceGroupStatusPhaseDescription(currentGroupPhase)
+		resourceGroup.Status.Usage = usage
+		resourceGroup.Status.ReadOnlyKubeconfig = readOnlyKubeconfig
+		resourceGroup.Status.Phase = currentGroupPhase
 
 		reason := resourcesv1alpha1.StatusPhaseToReason(currentGroupPhase)
 
@@ -233,16 +395,396 @@ func (r *ResourceGroupReconciler) Reconcile(ctx context.Context, resourceGroup *
 		return ctrl.Result{}, err
 	}
 
-	if currentGroupPhase == resourcesv1alpha1.DeploymentDonePhase {
-		return ctrl.Result{}, nil
+	// the CA of the API server is published to the namespace asynchronously by Kubernetes
+	readOnlyAccessPending := !perPlacement && resourceGroup.Spec.ReadOnlyAccess && readOnlyKubeconfig == ""
+
+	if currentGroupPhase == resourcesv1alpha1.DeploymentDonePhase && !readOnlyAccessPending {
+		// the token of the read-only kubeconfig is renewed before it expires
+		requeueAfter := readOnlyTokenRenewal
+		// new revisions of the artifact aren't watched, but polled
+		if resourceGroup.Spec.SourceRef != nil && (requeueAfter == 0 || sourcePollInterval < requeueAfter) {
+			requeueAfter = sourcePollInterval
+		}
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
 	}
 
 	// reschedule the reconciliation until the deployment is done
 	return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}, nil
 }
 
+// generateNamespace returns the namespace the ResourceGroup is deployed into to the placement, creating it when it
+// doesn't exist yet; the placement is ignored by the Group namespace strategy
+func (r *ResourceGroupReconciler) generateNamespace(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup, placement string) (*corev1.Namespace, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroup", resourceGroup.Name)
+
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: resourceGroup.NamespaceOf(placement)}, namespace); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "unable to fetch ResourceGroup's namespace")
+			return nil, err
+		}
+
+		log.Info(fmt.Sprintf("there is no namespace %s to ResourceGroup %s; trying to generate...", resourceGroup.NamespaceOf(placement), resourceGroup.Name))
+
+		namespace.Name = resourceGroup.NamespaceOf(placement)
+		namespace.Labels = map[string]string{
+			resourcesv1alpha1.Group + "/managedBy.group":   resourceGroup.GroupVersionKind().Group,
+			resourcesv1alpha1.Group + "/managedBy.version": resourceGroup.GroupVersionKind().Version,
+			resourcesv1alpha1.Group + "/managedBy.kind":    resourceGroup.GroupVersionKind().Kind,
+			resourcesv1alpha1.Group + "/managedBy.name":    resourceGroup.Name,
+		}
+		if resourceGroup.Spec.NamespaceStrategy == resourcesv1alpha1.NamespacePerPlacement {
+			namespace.Labels[resourcesv1alpha1.Group+"/placement"] = placement
+		}
+		if err := ctrl.SetControllerReference(resourceGroup, namespace, r.Scheme); err != nil {
+			log.Error(err, "unable to set namespace's ownerReference", "namespace", namespace.Name)
+			return nil, err
+		}
+
+		if err := r.Create(ctx, namespace); err != nil {
+			log.Error(err, fmt.Sprintf("unable to create namespace %s", namespace.Name), "namespace", namespace.Name)
+
+			if _, conditionErr := r.newResourceGroupCondition(ctx, resourceGroup, &metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeFailed,
+				Status:  metav1.ConditionFalse,
+				Reason:  failureReasonOf(err, resourcesv1alpha1.ConditionReasonDependencyNotReady),
+				Message: fmt.Sprintf("Unable to create namespace %s to ResourceGroup %s: %s", namespace.Name, resourceGroup.Name, err.Error()),
+			}); conditionErr != nil {
+				log.Error(conditionErr, "failed to update ResourceGroup's status")
+				return nil, conditionErr
+			}
+
+			return nil, err
+		}
+
+		log.Info(fmt.Sprintf("namespace %s was created to ResourceGroup %s", namespace.Name, resourceGroup.Name))
+	}
+
+	return namespace, nil
+}
+
+// pruneDeployments deletes the ResourceGroupDeployments to placements the group isn't deployed to anymore; the
+// deployment teardown destroys its Resources before the deployment goes away. Nothing is pruned while the placements
+// can't be trusted: when the group is deployed to none, which is what a misconfigured selector, ResourceRefs without
+// placements or an upgrade before the Placements are created look like, or when any ResourceRef isn't Ready. The deployments kept are returned,
+// so they're still reported in status.deployments.
+func (r *ResourceGroupReconciler) pruneDeployments(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup, knowPlacements sets.String, resourceRefsReady bool) (resourcesv1alpha1.ResourceGroupDeploymentStatuses, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroup", resourceGroup.Name)
+
+	deployments := &resourcesv1alpha1.ResourceGroupDeploymentList{}
+	// with the Placement namespace strategy, deployments are spread over many namespaces
+	if err := r.List(ctx, deployments, client.MatchingLabels{resourcesv1alpha1.Group + "/managedBy.name": resourceGroup.Name}); err != nil {
+		return nil, err
+	}
+
+	kept := make(resourcesv1alpha1.ResourceGroupDeploymentStatuses)
+	pruned := make([]string, 0)
+
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+
+		placement := deployment.Labels[resourcesv1alpha1.Group+"/placement"]
+		if placement == "" || knowPlacements.Has(placement) || !metav1.IsControlledBy(deployment, resourceGroup) || !deployment.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		switch {
+		case knowPlacements.Len() == 0:
+			log.Info(fmt.Sprintf("ResourceGroup isn't deployed to any placement; keeping ResourceGroupDeployment %s...", deployment.Name))
+		case !resourceRefsReady:
+			log.Info(fmt.Sprintf("placement %s was removed, but not every ResourceRef is ready; keeping ResourceGroupDeployment %s...", placement, deployment.Name))
+		case !prunable(resourceGroup, deployment):
+			log.Info(fmt.Sprintf("placement %s was removed, but pruning ResourceGroupDeployment %s would destroy its resources; set prunePlacements to allow it", placement, deployment.Name))
+		default:
+			log.Info(fmt.Sprintf("placement %s was removed; deleting ResourceGroupDeployment %s...", placement, deployment.Name))
+
+			if err := r.Delete(ctx, deployment); client.IgnoreNotFound(err) != nil {
+				return nil, err
+			}
+			pruned = append(pruned, deployment.Name)
+			continue
+		}
+
+		kept[deployment.Name] = deployment.Status
+	}
+
+	if len(pruned) == 0 {
+		return kept, nil
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, types.NamespacedName{Name: resourceGroup.Name}, resourceGroup); err != nil {
+			return err
+		}
+		if resourceGroup.Status.Deployments == nil {
+			return nil
+		}
+		for _, name := range pruned {
+			delete(resourceGroup.Status.Deployments, name)
+		}
+		return r.Status().Update(ctx, resourceGroup)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return kept, nil
+}
+
+// prunable tells whether the ResourceGroupDeployment may be deleted: the group opted in, the change was approved with
+// the Manual placement approval policy, or every resource of the deployment keeps its infrastructure
+func prunable(resourceGroup *resourcesv1alpha1.ResourceGroup, deployment *resourcesv1alpha1.ResourceGroupDeployment) bool {
+	if resourceGroup.Spec.PrunePlacements || resourceGroup.Spec.PlacementApproval == resourcesv1alpha1.ApprovalPolicyManual {
+		return true
+	}
+	for _, element := range deployment.Spec.Resources {
+		if element.DeletionPolicy != resourcesv1alpha1.DeletionPolicyOrphan && element.DeletionPolicy != resourcesv1alpha1.DeletionPolicyRetain {
+			return false
+		}
+	}
+	return true
+}
+
+// usageOf computes the consumption of the ResourceGroup's namespaces: Resources, provisioner runs and runner pods
+func (r *ResourceGroupReconciler) usageOf(ctx context.Context, namespaces ...string) (*resourcesv1alpha1.ResourceGroupUsage, error) {
+	usage := &resourcesv1alpha1.ResourceGroupUsage{}
+
+	for _, namespace := range namespaces {
+		resources := &resourcesv1alpha1.ResourceList{}
+		if err := r.List(ctx, resources, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+
+		usage.Resources += len(resources.Items)
+		for _, resource := range resources.Items {
+			if resource.Status.Phase == resourcesv1alpha1.DeploymentInProgressPhase {
+				usage.ActiveRuns++
+			}
+		}
+
+		pods := &corev1.PodList{}
+		if err := r.namespacedReader().List(ctx, pods, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+
+		for _, pod := range pods.Items {
+			if pod.Status.Phase != corev1.PodRunning && pod.Status.Phase != corev1.PodPending {
+				continue
+			}
+
+			usage.RunnerPods++
+			for _, container := range pod.Spec.Containers {
+				if cpu, ok := container.Resources.Requests[corev1.ResourceCPU]; ok {
+					usage.CPURequests.Add(cpu)
+				}
+				if memory, ok := container.Resources.Requests[corev1.ResourceMemory]; ok {
+					usage.MemoryRequests.Add(memory)
+				}
+			}
+		}
+	}
+
+	return usage, nil
+}
+
+// collectEmptyNamespace removes the namespace generated to the ResourceGroup, as long as there are no
+// deployments, resources or foreign objects left inside it
+func (r *ResourceGroupReconciler) collectEmptyNamespace(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroup", resourceGroup.Name, "resourceGroupNamespace", resourceGroup.Name)
+
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: resourceGroup.Name}, namespace); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "unable to fetch ResourceGroup's namespace")
+		return ctrl.Result{}, err
+	}
+
+	if !namespace.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	if !metav1.IsControlledBy(namespace, resourceGroup) {
+		log.Info(fmt.Sprintf("namespace %s is not controlled by ResourceGroup %s; skipping deletion", namespace.Name, resourceGroup.Name))
+		return ctrl.Result{}, nil
+	}
+
+	leftovers, err := r.leftoversIn(ctx, namespace.Name)
+	if err != nil {
+		log.Error(err, fmt.Sprintf("unable to inspect objects inside namespace %s", namespace.Name))
+		return ctrl.Result{}, err
+	}
+
+	if len(leftovers) != 0 {
+		log.Info(fmt.Sprintf("namespace %s can't be deleted; there are objects left inside it: %s", namespace.Name, leftovers))
+
+		_, err := r.newResourceGroupCondition(ctx, resourceGroup, &metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeReady,
+			Status:  metav1.ConditionTrue,
+			Reason:  resourcesv1alpha1.ConditionReasonNamespaceNotEmpty,
+			Message: fmt.Sprintf("Namespace %s was kept because there are objects left inside it: %s", namespace.Name, strings.Join(leftovers, ", ")),
+		})
+		if err != nil {
+			log.Error(err, "unable to update ResourceGroups's status")
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.Delete(ctx, namespace); err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, fmt.Sprintf("unable to delete namespace %s", namespace.Name))
+		return ctrl.Result{}, err
+	}
+
+	log.Info(fmt.Sprintf("namespace %s was empty and has been deleted", namespace.Name))
+
+	resourceGroup.Status.Deployments = nil
+	_, err = r.newResourceGroupCondition(ctx, resourceGroup, &metav1.Condition{
+		Type:    resourcesv1alpha1.ConditionTypeReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  resourcesv1alpha1.ConditionReasonNamespaceDeleted,
+		Message: fmt.Sprintf("There is nothing to deploy from ResourceGroup %s; namespace %s was deleted", resourceGroup.Name, namespace.Name),
+	})
+	if err != nil {
+		log.Error(err, "unable to update ResourceGroups's status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// collectPlacementNamespaces removes the namespaces generated to placements that aren't known anymore, as long as
+// there are no deployments, resources or foreign objects left inside them; the ones still in use are kept until a
+// next reconciliation
+func (r *ResourceGroupReconciler) collectPlacementNamespaces(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup, knowPlacements sets.String) error {
+	log := log.FromContext(ctx).WithValues("resourceGroup", resourceGroup.Name)
+
+	namespaces := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaces, client.MatchingLabels{
+		resourcesv1alpha1.Group + "/managedBy.kind": "ResourceGroup",
+		resourcesv1alpha1.Group + "/managedBy.name": resourceGroup.Name,
+	}); err != nil {
+		return err
+	}
+
+	for i := range namespaces.Items {
+		namespace := &namespaces.Items[i]
+
+		placement, ok := namespace.Labels[resourcesv1alpha1.Group+"/placement"]
+		if !ok || knowPlacements.Has(placement) || !metav1.IsControlledBy(namespace, resourceGroup) || !namespace.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		leftovers, err := r.leftoversIn(ctx, namespace.Name)
+		if err != nil {
+			return fmt.Errorf("unable to inspect objects inside namespace %s: %w", namespace.Name, err)
+		}
+		if len(leftovers) != 0 {
+			log.Info(fmt.Sprintf("namespace %s of placement %s can't be deleted yet; there are objects left inside it: %s", namespace.Name, placement, leftovers))
+			continue
+		}
+
+		if err := r.Delete(ctx, namespace); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		log.Info(fmt.Sprintf("namespace %s of placement %s was empty and has been deleted", namespace.Name, placement))
+	}
+
+	return nil
+}
+
+// leftoversIn lists the objects inside the namespace that would be lost with it; the bootstrap objects
+// generated by Kubernetes itself, the runner's RBAC, the read-only access and the CIDR allocations, which mean nothing
+// once nothing is deployed, are not considered.
+func (r *ResourceGroupReconciler) leftoversIn(ctx context.Context, namespace string) ([]string, error) {
+	leftovers := make([]string, 0)
+
+	isBootstrap := func(obj client.Object) bool {
+		return obj.GetLabels()[resourcesv1alpha1.Group+"/managedBy.group"] == resourcesv1alpha1.Group
+	}
+
+	deployments := &resourcesv1alpha1.ResourceGroupDeploymentList{}
+	if err := r.List(ctx, deployments, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments.Items {
+		leftovers = append(leftovers, fmt.Sprintf("ResourceGroupDeployment/%s", deployment.Name))
+	}
+
+	resources := &resourcesv1alpha1.ResourceList{}
+	if err := r.List(ctx, resources, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for _, resource := range resources.Items {
+		leftovers = append(leftovers, fmt.Sprintf("Resource/%s", resource.Name))
+	}
+
+	configMaps := &corev1.ConfigMapList{}
+	if err := r.namespacedReader().List(ctx, configMaps, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for _, configMap := range configMaps.Items {
+		if configMap.Name != "kube-root-ca.crt" && configMap.Name != ipam.AllocationsConfigMapName && configMap.Name != generated.ValuesConfigMapName {
+			leftovers = append(leftovers, fmt.Sprintf("ConfigMap/%s", configMap.Name))
+		}
+	}
+
+	secrets := &corev1.SecretList{}
+	if err := r.namespacedReader().List(ctx, secrets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for _, secret := range secrets.Items {
+		if secret.Type != corev1.SecretTypeServiceAccountToken && secret.Name != ReadOnlyKubeconfigName {
+			leftovers = append(leftovers, fmt.Sprintf("Secret/%s", secret.Name))
+		}
+	}
+
+	serviceAccounts := &corev1.ServiceAccountList{}
+	if err := r.namespacedReader().List(ctx, serviceAccounts, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for _, serviceAccount := range serviceAccounts.Items {
+		if serviceAccount.Name != "default" && serviceAccount.Name != OpenTofuServiceAccountName && serviceAccount.Name != PulumiServiceAccountName && serviceAccount.Name != ReadOnlyServiceAccountName {
+			leftovers = append(leftovers, fmt.Sprintf("ServiceAccount/%s", serviceAccount.Name))
+		}
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.namespacedReader().List(ctx, pods, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		leftovers = append(leftovers, fmt.Sprintf("Pod/%s", pod.Name))
+	}
+
+	roles := &rbacv1.RoleList{}
+	if err := r.List(ctx, roles, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for _, role := range roles.Items {
+		if !isBootstrap(&role) {
+			leftovers = append(leftovers, fmt.Sprintf("Role/%s", role.Name))
+		}
+	}
+
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, roleBindings, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for _, roleBinding := range roleBindings.Items {
+		if !isBootstrap(&roleBinding) && roleBinding.Name != OpenTofuRoleBindingName && roleBinding.Name != PulumiRoleBindingName {
+			leftovers = append(leftovers, fmt.Sprintf("RoleBinding/%s", roleBinding.Name))
+		}
+	}
+
+	return leftovers, nil
+}
+
 func (r *ResourceGroupReconciler) newResourceGroupCondition(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup, newCondition *metav1.Condition) (*resourcesv1alpha1.ResourceGroup, error) {
-	meta.SetStatusCondition(&resourceGroup.Status.Conditions, *newCondition)
+	setStatusCondition(&resourceGroup.Status.Conditions, *newCondition)
 	if err := r.Status().Update(ctx, resourceGroup); err != nil {
 		return nil, err
 	}
@@ -254,8 +796,55 @@ func (r *ResourceGroupReconciler) newResourceGroupCondition(ctx context.Context,
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ResourceGroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	objectReconciler := reconcile.AsReconciler[*resourcesv1alpha1.ResourceGroup](mgr.GetClient(), r)
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&resourcesv1alpha1.ResourceGroup{}).
 		Owns(&resourcesv1alpha1.ResourceGroupDeployment{}).
-		Complete(reconcile.AsReconciler[*resourcesv1alpha1.ResourceGroup](mgr.GetClient(), r))
+		Watches(&resourcesv1alpha1.ResourceRef{}, handler.EnqueueRequestsFromMapFunc(r.resourceGroupsUsingResourceRef)).
+		Watches(&resourcesv1alpha1.Placement{}, handler.EnqueueRequestsFromMapFunc(r.resourceGroupsSelectingPlacements)).
+		Complete(reconcile.Func(func(ctx context.Context, req reconcile.Request) (ctrl.Result, error) {
+			// AsReconciler skips objects that are gone, so their usage metrics are dropped here
+			if err := r.Get(ctx, req.NamespacedName, &resourcesv1alpha1.ResourceGroup{}); apierrors.IsNotFound(err) {
+				forgetResourceGroupMetrics(req.Name)
+				return ctrl.Result{}, nil
+			}
+			return objectReconciler.Reconcile(ctx, req)
+		}))
+}
+
+// resourceGroupsUsingResourceRef enqueues the ResourceGroups with resources referencing the ResourceRef, so the ones
+// waiting for it are deployed once it's created
+func (r *ResourceGroupReconciler) resourceGroupsUsingResourceRef(ctx context.Context, obj client.Object) []reconcile.Request {
+	resourceGroups := &resourcesv1alpha1.ResourceGroupList{}
+	if err := r.List(ctx, resourceGroups); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ResourceGroups", "resourceRef", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for _, resourceGroup := range resourceGroups.Items {
+		if resourceGroup.UsesResourceRef(obj.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: resourceGroup.Name}})
+		}
+	}
+	return requests
+}
+
+// resourceGroupsSelectingPlacements enqueues the ResourceGroups with their own placement selector when a Placement
+// changes; the others follow the status of their ResourceRefs
+func (r *ResourceGroupReconciler) resourceGroupsSelectingPlacements(ctx context.Context, obj client.Object) []reconcile.Request {
+	resourceGroups := &resourcesv1alpha1.ResourceGroupList{}
+	if err := r.List(ctx, resourceGroups); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ResourceGroups", "placement", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for _, resourceGroup := range resourceGroups.Items {
+		if resourceGroup.Spec.PlacementSelector != nil {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: resourceGroup.Name}})
+		}
+	}
+	return requests
 }
@@ -18,11 +18,20 @@ package controller
 
 import (
 	"context"
+	"fmt"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -84,3 +93,294 @@ var _ = Describe("ResourceGroup Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("ResourceGroup waiting for ResourceRefs", func() {
+	Context("When a resource references a ResourceRef that doesn't exist yet", func() {
+		ctx := context.Background()
+
+		It("should wait for the ResourceRef, and be enqueued once it's created", func() {
+			resourceGroup := &resourcesv1alpha1.ResourceGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-waiting-group"},
+				Spec: resourcesv1alpha1.ResourceGroupSpec{
+					Resources: []resourcesv1alpha1.ResourceGroupElement{
+						{Name: "cache", ResourceRef: "test-missing-ref", Properties: &runtime.RawExtension{Raw: []byte(`{}`)}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resourceGroup)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, resourceGroup)).To(Succeed())
+			}()
+
+			controllerReconciler := &ResourceGroupReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			reconciler := reconcile.AsReconciler[*resourcesv1alpha1.ResourceGroup](k8sClient, controllerReconciler)
+
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: resourceGroup.Name}})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceGroup.Name}, resourceGroup)).To(Succeed())
+			condition := meta.FindStatusCondition(resourceGroup.Status.Conditions, resourcesv1alpha1.ConditionTypeInitializing)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Reason).To(Equal(resourcesv1alpha1.ConditionReasonResourceRefNotFound))
+
+			requests := controllerReconciler.resourceGroupsUsingResourceRef(ctx, &resourcesv1alpha1.ResourceRef{ObjectMeta: metav1.ObjectMeta{Name: "test-missing-ref"}})
+			Expect(requests).To(ContainElement(reconcile.Request{NamespacedName: types.NamespacedName{Name: resourceGroup.Name}}))
+		})
+	})
+})
+
+var _ = Describe("ResourceGroup required capabilities", func() {
+	Context("When a ResourceGroup requires a capability this controller doesn't have", func() {
+		ctx := context.Background()
+
+		It("should set the Unsupported condition, and do nothing else", func() {
+			resourceGroup := &resourcesv1alpha1.ResourceGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-unsupported-group"},
+				Spec: resourcesv1alpha1.ResourceGroupSpec{
+					Requires: []resourcesv1alpha1.Capability{resourcesv1alpha1.CapabilityForEach, "FromTheFuture"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resourceGroup)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, resourceGroup)).To(Succeed())
+			}()
+
+			controllerReconciler := &ResourceGroupReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			reconciler := reconcile.AsReconciler[*resourcesv1alpha1.ResourceGroup](k8sClient, controllerReconciler)
+
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: resourceGroup.Name}})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceGroup.Name}, resourceGroup)).To(Succeed())
+			condition := meta.FindStatusCondition(resourceGroup.Status.Conditions, resourcesv1alpha1.ConditionTypeUnsupported)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Message).To(ContainSubstring("FromTheFuture"))
+			Expect(condition.Message).NotTo(ContainSubstring(string(resourcesv1alpha1.CapabilityForEach)))
+
+			namespace := &corev1.Namespace{}
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: resourceGroup.Name}, namespace)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("ResourceGroup deployments pruning", func() {
+	Context("When a placement is removed", func() {
+		ctx := context.Background()
+
+		resources := []resourcesv1alpha1.ResourceGroupElement{{Name: "bucket", ResourceRef: "s3"}}
+
+		newDeploymentOf := func(resourceGroup *resourcesv1alpha1.ResourceGroup, placement string) *resourcesv1alpha1.ResourceGroupDeployment {
+			deployment := &resourcesv1alpha1.ResourceGroupDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("%s.%s", resourceGroup.Name, placement),
+					Namespace: resourceGroup.Name,
+					Labels: map[string]string{
+						resourcesv1alpha1.Group + "/managedBy.name": resourceGroup.Name,
+						resourcesv1alpha1.Group + "/placement":      placement,
+					},
+				},
+				Spec: resourcesv1alpha1.ResourceGroupDeploymentSpec{Placement: placement, Resources: resources},
+			}
+			Expect(ctrl.SetControllerReference(resourceGroup, deployment, k8sClient.Scheme())).To(Succeed())
+			Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+			return deployment
+		}
+
+		It("should delete only the deployments to unknown placements", func() {
+			resourceGroup := &resourcesv1alpha1.ResourceGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-pruned-group"},
+				Spec:       resourcesv1alpha1.ResourceGroupSpec{Resources: resources, PrunePlacements: true},
+			}
+			Expect(k8sClient.Create(ctx, resourceGroup)).To(Succeed())
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: resourceGroup.Name}})).To(Succeed())
+
+			kept := newDeploymentOf(resourceGroup, "prod")
+			removed := newDeploymentOf(resourceGroup, "staging")
+
+			resourceGroup.Status.Deployments = resourcesv1alpha1.ResourceGroupDeploymentStatuses{kept.Name: {}, removed.Name: {}}
+			Expect(k8sClient.Status().Update(ctx, resourceGroup)).To(Succeed())
+
+			controllerReconciler := &ResourceGroupReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			keptDeployments, err := controllerReconciler.pruneDeployments(ctx, resourceGroup, sets.NewString("prod"), true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keptDeployments).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(kept), &resourcesv1alpha1.ResourceGroupDeployment{})).To(Succeed())
+
+			err = k8sClient.Get(ctx, client.ObjectKeyFromObject(removed), &resourcesv1alpha1.ResourceGroupDeployment{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("removing the pruned deployment from the status")
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(resourceGroup), resourceGroup)).To(Succeed())
+			Expect(resourceGroup.Status.Deployments).To(HaveKey(kept.Name))
+			Expect(resourceGroup.Status.Deployments).NotTo(HaveKey(removed.Name))
+
+			Expect(k8sClient.Delete(ctx, kept)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resourceGroup)).To(Succeed())
+		})
+
+		It("should keep the deployments when pruning them can't be trusted, or wasn't allowed", func() {
+			resourceGroup := &resourcesv1alpha1.ResourceGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-unpruned-group"},
+				Spec:       resourcesv1alpha1.ResourceGroupSpec{Resources: resources},
+			}
+			Expect(k8sClient.Create(ctx, resourceGroup)).To(Succeed())
+			Expect(k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: resourceGroup.Name}})).To(Succeed())
+
+			deployment := newDeploymentOf(resourceGroup, "staging")
+
+			controllerReconciler := &ResourceGroupReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+
+			By("destroying the resources without the group opting in")
+			keptDeployments, err := controllerReconciler.pruneDeployments(ctx, resourceGroup, sets.NewString("prod"), true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keptDeployments).To(HaveKey(deployment.Name))
+
+			resourceGroup.Spec.PrunePlacements = true
+
+			By("selecting no placement at all")
+			keptDeployments, err = controllerReconciler.pruneDeployments(ctx, resourceGroup, sets.NewString(), true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keptDeployments).To(HaveKey(deployment.Name))
+
+			By("having a ResourceRef that isn't ready")
+			keptDeployments, err = controllerReconciler.pruneDeployments(ctx, resourceGroup, sets.NewString("prod"), false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keptDeployments).To(HaveKey(deployment.Name))
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deployment), &resourcesv1alpha1.ResourceGroupDeployment{})).To(Succeed())
+
+			Expect(k8sClient.Delete(ctx, deployment)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resourceGroup)).To(Succeed())
+		})
+	})
+})
+
+var _ = Describe("ResourceGroup namespace strategy", func() {
+	Context("When every placement gets its own namespace", func() {
+		ctx := context.Background()
+
+		It("should generate a namespace to each placement, labeled with it", func() {
+			resourceGroup := &resourcesv1alpha1.ResourceGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-isolated-group"},
+				Spec:       resourcesv1alpha1.ResourceGroupSpec{NamespaceStrategy: resourcesv1alpha1.NamespacePerPlacement},
+			}
+			Expect(k8sClient.Create(ctx, resourceGroup)).To(Succeed())
+
+			controllerReconciler := &ResourceGroupReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+
+			for _, placement := range []string{"prod", "staging"} {
+				namespace, err := controllerReconciler.generateNamespace(ctx, resourceGroup, placement)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(namespace.Name).To(Equal(fmt.Sprintf("test-isolated-group-%s", placement)))
+
+				generated := &corev1.Namespace{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: namespace.Name}, generated)).To(Succeed())
+				Expect(generated.Labels).To(HaveKeyWithValue(resourcesv1alpha1.Group+"/placement", placement))
+				Expect(metav1.IsControlledBy(generated, resourceGroup)).To(BeTrue())
+			}
+
+			By("collecting the namespace of a removed placement")
+			Expect(controllerReconciler.collectPlacementNamespaces(ctx, resourceGroup, sets.NewString("prod"))).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "test-isolated-group-prod"}, &corev1.Namespace{})).To(Succeed())
+
+			// envtest has no namespace controller to finish the deletion
+			removed := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "test-isolated-group-staging"}, removed)).To(Succeed())
+			Expect(removed.DeletionTimestamp.IsZero()).To(BeFalse())
+
+			Expect(k8sClient.Delete(ctx, resourceGroup)).To(Succeed())
+		})
+	})
+})
+
+var _ = Describe("ResourceGroup read-only access", func() {
+	Context("When the read-only access is enabled", func() {
+		ctx := context.Background()
+
+		It("should export a kubeconfig to a read-only ServiceAccount, and revoke it when disabled", func() {
+			resourceGroup := &resourcesv1alpha1.ResourceGroup{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-read-only-group"},
+				Spec:       resourcesv1alpha1.ResourceGroupSpec{ReadOnlyAccess: true},
+			}
+			Expect(k8sClient.Create(ctx, resourceGroup)).To(Succeed())
+
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: resourceGroup.Name}}
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			controllerReconciler := &ResourceGroupReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), APIServerURL: "https://kubernetes.default.svc"}
+
+			By("waiting for the CA of the API server")
+			kubeconfigName, _, err := controllerReconciler.reconcileReadOnlyAccess(ctx, resourceGroup, namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kubeconfigName).To(BeEmpty())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: ReadOnlyServiceAccountName, Namespace: namespace.Name}, &corev1.ServiceAccount{})).To(Succeed())
+
+			role := &rbacv1.Role{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: ReadOnlyServiceAccountName, Namespace: namespace.Name}, role)).To(Succeed())
+			for _, rule := range role.Rules {
+				Expect(rule.Verbs).To(ConsistOf("get", "list", "watch"))
+				Expect(rule.Resources).NotTo(ContainElement("secrets"))
+			}
+
+			By("publishing the CA, as Kubernetes would")
+			Expect(k8sClient.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "kube-root-ca.crt", Namespace: namespace.Name},
+				Data:       map[string]string{"ca.crt": "sample-ca"},
+			})).To(Succeed())
+
+			kubeconfigName, renewal, err := controllerReconciler.reconcileReadOnlyAccess(ctx, resourceGroup, namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kubeconfigName).To(Equal(ReadOnlyKubeconfigName))
+			Expect(renewal).To(BeNumerically(">", 0))
+			Expect(renewal).To(BeNumerically("<=", ReadOnlyTokenExpiration))
+
+			kubeconfigSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: kubeconfigName, Namespace: namespace.Name}, kubeconfigSecret)).To(Succeed())
+
+			kubeconfig, err := clientcmd.Load(kubeconfigSecret.Data[ReadOnlyKubeconfigKey])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kubeconfig.Clusters[namespace.Name].Server).To(Equal("https://kubernetes.default.svc"))
+			Expect(string(kubeconfig.Clusters[namespace.Name].CertificateAuthorityData)).To(Equal("sample-ca"))
+			Expect(kubeconfig.Contexts[namespace.Name].Namespace).To(Equal(namespace.Name))
+			Expect(kubeconfig.AuthInfos[ReadOnlyServiceAccountName].Token).NotTo(BeEmpty())
+
+			By("keeping the token until it must be renewed")
+			_, _, err = controllerReconciler.reconcileReadOnlyAccess(ctx, resourceGroup, namespace)
+			Expect(err).NotTo(HaveOccurred())
+
+			renewed := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: kubeconfigName, Namespace: namespace.Name}, renewed)).To(Succeed())
+			Expect(renewed.Data).To(Equal(kubeconfigSecret.Data))
+
+			leftovers, err := controllerReconciler.leftoversIn(ctx, namespace.Name)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leftovers).To(BeEmpty())
+
+			By("disabling the read-only access")
+			resourceGroup.Spec.ReadOnlyAccess = false
+			kubeconfigName, _, err = controllerReconciler.reconcileReadOnlyAccess(ctx, resourceGroup, namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kubeconfigName).To(BeEmpty())
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: ReadOnlyServiceAccountName, Namespace: namespace.Name}, &corev1.ServiceAccount{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: ReadOnlyKubeconfigName, Namespace: namespace.Name}, &corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			Expect(k8sClient.Delete(ctx, resourceGroup)).To(Succeed())
+		})
+	})
+})
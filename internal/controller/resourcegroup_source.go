@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/artifacts"
+)
+
+// sourcePollInterval is how often a ResourceGroup with a sourceRef looks for a new revision of its artifact
+const sourcePollInterval = time.Minute
+
+// +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=gitrepositories;ocirepositories;buckets,verbs=get
+
+// resourcesOf returns the resources of the group, followed by the ones loaded from the artifact of its sourceRef.
+// While the artifact can't be read, the group's conditions tell why and false is returned, so nothing is deployed
+// from a partial list of resources.
+func (r *ResourceGroupReconciler) resourcesOf(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup) ([]resourcesv1alpha1.ResourceGroupElement, bool, error) {
+	sourceRef := resourceGroup.Spec.SourceRef
+	if sourceRef == nil {
+		return resourceGroup.Spec.Resources, true, nil
+	}
+
+	log := log.FromContext(ctx).WithValues("resourceGroup", resourceGroup.Name, "source", fmt.Sprintf("%s/%s/%s", sourceRef.Kind, sourceRef.Namespace, sourceRef.Name))
+
+	loader := r.Artifacts
+	if loader == nil {
+		loader = artifacts.NewLoader(r.Client)
+	}
+
+	loaded, err := loader.Load(ctx, sourceRef)
+	if err != nil {
+		condition := &metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeFailed,
+			Status:  metav1.ConditionFalse,
+			Reason:  resourcesv1alpha1.ConditionReasonInputError,
+			Message: fmt.Sprintf("Unable to load the resources of ResourceGroup %s: %s", resourceGroup.Name, err.Error()),
+		}
+		if errors.Is(err, artifacts.ErrNotReady) || apierrors.IsNotFound(err) {
+			condition = &metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeInitializing,
+				Status:  metav1.ConditionTrue,
+				Reason:  resourcesv1alpha1.ConditionReasonSourceNotReady,
+				Message: fmt.Sprintf("Waiting for the artifact of %s %s/%s; the ResourceGroup is deployed once it's available", sourceRef.Kind, sourceRef.Namespace, sourceRef.Name),
+			}
+		}
+
+		log.Info(fmt.Sprintf("unable to load the resources from the source: %s", err.Error()))
+
+		if _, err := r.newResourceGroupCondition(ctx, resourceGroup, condition); err != nil {
+			log.Error(err, "unable to update ResourceGroups's status")
+			return nil, false, err
+		}
+		return nil, false, nil
+	}
+
+	resources, err := withSourcedResources(resourceGroup.Spec.Resources, loaded.Resources)
+	if err != nil {
+		if _, err := r.newResourceGroupCondition(ctx, resourceGroup, &metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeFailed,
+			Status:  metav1.ConditionFalse,
+			Reason:  resourcesv1alpha1.ConditionReasonInputError,
+			Message: fmt.Sprintf("Unable to load the resources of ResourceGroup %s from revision %s: %s", resourceGroup.Name, loaded.Revision, err.Error()),
+		}); err != nil {
+			log.Error(err, "unable to update ResourceGroups's status")
+			return nil, false, err
+		}
+		return nil, false, nil
+	}
+
+	// the ResourceRefs of the loaded resources are published, so the runners of the group's namespaces are allowed
+	// to provision them too
+	source := &resourcesv1alpha1.ResourceGroupSourceStatus{Revision: loaded.Revision}
+	for _, element := range loaded.Resources {
+		source.Resources = append(source.Resources, element.Name)
+		if !slices.Contains(source.ResourceRefs, element.ResourceRef) {
+			source.ResourceRefs = append(source.ResourceRefs, element.ResourceRef)
+		}
+	}
+
+	if !equality.Semantic.DeepEqual(resourceGroup.Status.Source, source) {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			if err := r.Get(ctx, types.NamespacedName{Name: resourceGroup.Name}, resourceGroup); err != nil {
+				return err
+			}
+			resourceGroup.Status.Source = source
+			return r.Status().Update(ctx, resourceGroup)
+		})
+		if err != nil {
+			log.Error(err, "unable to update ResourceGroups's status")
+			return nil, false, err
+		}
+
+		log.Info(fmt.Sprintf("%d resources were loaded from revision %s", len(loaded.Resources), loaded.Revision))
+	}
+
+	return resources, true, nil
+}
+
+// withSourcedResources appends the resources loaded from an artifact to the ones declared in the group; names must
+// still be unique
+func withSourcedResources(declared, sourced []resourcesv1alpha1.ResourceGroupElement) ([]resourcesv1alpha1.ResourceGroupElement, error) {
+	resources := slices.Clone(declared)
+
+	names := make(map[string]bool)
+	for _, element := range declared {
+		names[element.Name] = true
+	}
+	for _, element := range sourced {
+		if names[element.Name] {
+			return nil, fmt.Errorf("resource %s is declared more than once", element.Name)
+		}
+		names[element.Name] = true
+		resources = append(resources, element)
+	}
+
+	return resources, nil
+}
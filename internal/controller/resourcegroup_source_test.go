@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_WithSourcedResources(t *testing.T) {
+	declared := []resourcesv1alpha1.ResourceGroupElement{{Name: "bucket", ResourceRef: "s3"}}
+
+	t.Run("We should append the resources loaded from the artifact to the declared ones", func(t *testing.T) {
+		resources, err := withSourcedResources(declared, []resourcesv1alpha1.ResourceGroupElement{{Name: "queue", ResourceRef: "sqs"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []resourcesv1alpha1.ResourceGroupElement{{Name: "bucket", ResourceRef: "s3"}, {Name: "queue", ResourceRef: "sqs"}}, resources)
+		assert.Len(t, declared, 1)
+	})
+
+	t.Run("A loaded resource named like a declared one should be rejected", func(t *testing.T) {
+		_, err := withSourcedResources(declared, []resourcesv1alpha1.ResourceGroupElement{{Name: "bucket", ResourceRef: "gcs"}})
+		assert.ErrorContains(t, err, "resource bucket is declared more than once")
+	})
+}
@@ -17,36 +17,62 @@ limitations under the License.
 package controller
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/expression"
+	"github.com/nubank/klaudio/internal/ipam"
 	"github.com/nubank/klaudio/internal/refs"
 	"github.com/nubank/klaudio/internal/resources"
 )
 
+// ResourceGroupDeploymentTeardownFinalizer holds the ResourceGroupDeployment until its Resources are destroyed,
+// in the reverse order of their dependencies
+const ResourceGroupDeploymentTeardownFinalizer = resourcesv1alpha1.Group + "/teardown"
+
 // ResourceGroupDeploymentReconciler reconciles a ResourceGroupDeployment object
 type ResourceGroupDeploymentReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// ShardSelector restricts the ResourceGroupDeployments handled by this manager
+	ShardSelector labels.Selector
+	Recorder      record.EventRecorder
+	// OutputsStalenessThreshold is the maximum age of the outputs used to render dependent Resources; zero disables it
+	OutputsStalenessThreshold time.Duration
+	// ResourceRefs serves ResourceRefs from the shared cache; without it, they're read with the client
+	ResourceRefs *ResourceRefs
 }
 
 // +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcegroupdeployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcegroupdeployments/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcegroupdeployments/finalizers,verbs=update
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=placements,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=core,resources=configmaps;secrets,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -60,6 +86,21 @@ type ResourceGroupDeploymentReconciler struct {
 func (r *ResourceGroupDeploymentReconciler) Reconcile(ctx context.Context, deployment *resourcesv1alpha1.ResourceGroupDeployment) (ctrl.Result, error) {
 	log := log.FromContext(ctx).WithValues("resourceGroupDeployment", deployment.Name)
 
+	if !deployment.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(deployment, ResourceGroupDeploymentTeardownFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		return r.teardown(ctx, deployment)
+	}
+
+	if !controllerutil.ContainsFinalizer(deployment, ResourceGroupDeploymentTeardownFinalizer) {
+		controllerutil.AddFinalizer(deployment, ResourceGroupDeploymentTeardownFinalizer)
+		if err := r.Update(ctx, deployment); err != nil {
+			log.Error(err, "unable to add finalizer to ResourceGroupDeployment")
+			return ctrl.Result{}, err
+		}
+	}
+
 	if len(deployment.Status.Conditions) == 0 {
 		deployment.Status.Phase = resourcesv1alpha1.DeploymentInProgressPhase
 		deploymentWithCondition, err := r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
@@ -75,222 +116,325 @@ func (r *ResourceGroupDeploymentReconciler) Reconcile(ctx context.Context, deplo
 		deployment = deploymentWithCondition
 	}
 
-	parameters := make(map[string]any)
-	if deployment.Spec.Parameters != nil {
-		if err := json.Unmarshal(deployment.Spec.Parameters.Raw, &parameters); err != nil {
-			log.Error(err, "failed to deserialize deployment parameters")
-			return ctrl.Result{Requeue: false}, err
+	// placements can be suspended or frozen by SREs, holding every deployment targeting them at once
+	placement := &resourcesv1alpha1.Placement{}
+	if err := r.Get(ctx, types.NamespacedName{Name: deployment.Spec.Placement}, placement); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "unable to fetch Placement", "placement", deployment.Spec.Placement)
+			return ctrl.Result{}, err
 		}
+		placement = nil
 	}
 
-	references := refs.NewReferences()
+	suspended := meta.IsStatusConditionTrue(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeSuspended)
+	if deployment.Spec.Suspend {
+		log.Info("deployment is suspended; skipping reconciliation...")
+		if !suspended {
+			if _, err := r.newResourceGroupDeploymentCondition(ctx, deployment, suspendedCondition("ResourceGroupDeployment", deployment.Name)); err != nil {
+				log.Error(err, "Failed to update ResourceGroupDeployment's status")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
 
-	// step 1: resolve references
-	for _, ref := range deployment.Spec.Refs {
-		referenceObject, err := references.NewReference(ctx, r.Client, ref)
+	if placement != nil && placement.Spec.Suspend {
+		log.Info(fmt.Sprintf("placement %s is suspended; skipping reconciliation...", placement.Name))
+		if !suspended {
+			if _, err := r.newResourceGroupDeploymentCondition(ctx, deployment, placementSuspendedCondition(placement.Name)); err != nil {
+				log.Error(err, "Failed to update ResourceGroupDeployment's status")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if suspended {
+		deploymentResumed, err := r.newResourceGroupDeploymentCondition(ctx, deployment, resumedCondition("ResourceGroupDeployment", deployment.Name))
 		if err != nil {
-			log.Error(err, "unable to fetch Ref", "ref", ref.Name)
+			log.Error(err, "Failed to update ResourceGroupDeployment's status")
 			return ctrl.Result{}, err
 		}
-
-		log.Info(fmt.Sprintf("resolved reference: %+v", referenceObject))
+		deployment = deploymentResumed
 	}
 
-	resourceGroup := resources.NewResourceGroup()
+	// placements can be frozen by SREs; while a freeze window is active, the deployment is held
+	if placement != nil {
+		if window := placement.ActiveFreezeWindow(time.Now()); window != nil {
+			log.Info(fmt.Sprintf("placement %s is frozen until %s; holding deployment...", placement.Name, window.End))
 
-	// step 2: traverse all resources to determine relationship between them
-	for _, candidate := range deployment.Spec.Resources {
-		logWithResource := log.WithValues("resource", candidate.Name)
+			_, err := r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeFrozen,
+				Status:  metav1.ConditionTrue,
+				Reason:  resourcesv1alpha1.ConditionReasonPlacementFrozen,
+				Message: fmt.Sprintf("Placement %s is frozen until %s: %s", placement.Name, window.End.UTC().Format(time.RFC3339), window.Reason),
+			})
+			if err != nil {
+				log.Error(err, "Failed to update ResourceGroupDeployment's status")
+				return ctrl.Result{}, err
+			}
 
-		// every resource must reference a ResourceRef object
-		resourceRef := &resourcesv1alpha1.ResourceRef{}
-		if err := r.Get(ctx, types.NamespacedName{Name: candidate.ResourceRef}, resourceRef); err != nil {
-			logWithResource.Error(err, "unable to fetch ResourceRef", "resourceRef", candidate.Name)
-			return ctrl.Result{}, err
+			return ctrl.Result{RequeueAfter: time.Until(window.End.Time)}, nil
 		}
+	}
 
-		resource, err := resourceGroup.NewResource(candidate.Name, candidate.Properties)
+	if meta.IsStatusConditionTrue(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeFrozen) {
+		deploymentUnfrozen, err := r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeFrozen,
+			Status:  metav1.ConditionFalse,
+			Reason:  resourcesv1alpha1.ConditionReasonPlacementUnfrozen,
+			Message: fmt.Sprintf("Placement %s is not frozen anymore", deployment.Spec.Placement),
+		})
 		if err != nil {
-			logWithResource.Error(err, fmt.Sprintf("unable to unmarshal resource %s", candidate.Name), "resourceRef", candidate.Name)
+			log.Error(err, "Failed to update ResourceGroupDeployment's status")
 			return ctrl.Result{}, err
 		}
-
-		resource.Ref = resourceRef
+		deployment = deploymentUnfrozen
 	}
 
-	// step 3: generate a dag
-	dag, err := resourceGroup.Graph()
+	result, err := r.runPipeline(ctx, &deploymentRun{deployment: deployment, placement: placement}, r.pipeline())
 	if err != nil {
-		log.Error(err, "unable to generate a graph from deployment resources")
-		return ctrl.Result{}, err
+		return result, err
+	}
+
+	if placement != nil {
+		if err := r.handleReconcileRequest(ctx, deployment, placement); err != nil {
+			log.Error(err, "Failed to update ResourceGroupDeployment's status")
+			return ctrl.Result{}, err
+		}
 	}
 
-	log.Info(fmt.Sprintf("Generated dag: %s", dag))
+	return result, nil
+}
 
-	args := resources.NewResourcePropertiesArgs(parameters, references)
+// teardown deletes the Resources from the deployment walking the dag backwards: a level is only deleted when the
+// Resources depending on it are fully deprovisioned (e.g. the database is destroyed before the VPC it lives in).
+func (r *ResourceGroupDeploymentReconciler) teardown(ctx context.Context, deployment *resourcesv1alpha1.ResourceGroupDeployment) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroupDeployment", deployment.Name)
 
-	knowResources := make(resourcesv1alpha1.ResourceGroupDeploymentResourcesStatuses)
+	// forEach is expanded from the last frozen inputs; Resources stamped out to items that can't be found anymore are
+	// torn down as the ones removed from the spec
+	forEachArgs, err := forEachArgsOf(deployment.Status.Inputs)
+	if err != nil {
+		log.Error(err, "unable to read the inputs from ResourceGroupDeployment")
+		return ctrl.Result{}, err
+	}
 
-	// step 4: in order, expand and generate each resource
-	for _, resourceName := range dag {
-		resource, err := resourceGroup.Get(resourceName)
-		if err != nil {
+	resourceGroup := resources.NewResourceGroup().WithExpressionLanguage(expression.Language(deployment.Spec.ExpressionLanguage))
+	for _, candidate := range deployment.Spec.Resources {
+		if _, err := resourceGroup.NewResources(candidate, forEachArgs); err != nil {
+			if candidate.ForEach != "" {
+				log.Info(fmt.Sprintf("unable to stamp out resource %s: %s", candidate.Name, err.Error()))
+				continue
+			}
+			log.Error(err, fmt.Sprintf("unable to unmarshal resource %s", candidate.Name))
 			return ctrl.Result{}, err
 		}
-		logWithResource := log.WithValues("resource", resource.Name)
+	}
 
-		log.Info(fmt.Sprintf("Processing %s...", resource.Name))
+	levels, err := resourceGroup.TeardownLevels()
+	if err != nil {
+		log.Error(err, "unable to generate a graph from deployment resources")
+		return ctrl.Result{}, err
+	}
 
-		// first, expand properties
-		expandedProperties, err := resource.Evaluate(args)
-		if err != nil {
-			log.Error(err, "unable to evaluate properties")
-			return ctrl.Result{}, err
-		}
+	deployed := &resourcesv1alpha1.ResourceList{}
+	if err := r.List(ctx, deployed, client.InNamespace(deployment.Namespace), client.MatchingLabels{resourcesv1alpha1.Group + "/managedBy.name": deployment.Name}); err != nil {
+		log.Error(err, "unable to list Resources from ResourceGroupDeployment")
+		return ctrl.Result{}, err
+	}
 
-		rawProperties, err := json.Marshal(expandedProperties)
-		if err != nil {
-			log.Error(err, "unable to serialize resource properties")
-			return ctrl.Result{}, err
+	remaining := make(map[string]*resourcesv1alpha1.Resource)
+	for i := range deployed.Items {
+		if metav1.IsControlledBy(&deployed.Items[i], deployment) {
+			remaining[deployed.Items[i].Name] = &deployed.Items[i]
 		}
+	}
 
-		resourceNameToDeploy := fmt.Sprintf("%s.%s", deployment.Name, resource.NameAsKebabCase())
-
-		resourceToDeploy := &resourcesv1alpha1.Resource{}
-		if err := r.Get(ctx, types.NamespacedName{Namespace: deployment.Namespace, Name: resourceNameToDeploy}, resourceToDeploy); err != nil {
-			if !apierrors.IsNotFound(err) {
-				log.Error(err, "unable to fetch Resource object")
+	teardownLevels := make([][]string, 0, len(levels)+1)
+	for _, level := range levels {
+		names := make([]string, 0, len(level))
+		for _, resourceName := range level {
+			resource, err := resourceGroup.Get(resourceName)
+			if err != nil {
 				return ctrl.Result{}, err
 			}
+			names = append(names, fmt.Sprintf("%s.%s", deployment.Name, resource.NameAsKebabCase()))
+		}
+		teardownLevels = append(teardownLevels, names)
+	}
 
-			// there is no Resource yet; just create it
-			log.Info(fmt.Sprintf("Creating Resource %s...", resourceNameToDeploy))
-
-			resourceToDeploy.Name = resourceNameToDeploy
-			resourceToDeploy.Namespace = deployment.Namespace
-			resourceToDeploy.Labels = map[string]string{
-				resourcesv1alpha1.Group + "/managedBy.group":   deployment.GroupVersionKind().Group,
-				resourcesv1alpha1.Group + "/managedBy.version": deployment.GroupVersionKind().Version,
-				resourcesv1alpha1.Group + "/managedBy.kind":    deployment.GroupVersionKind().Kind,
-				resourcesv1alpha1.Group + "/managedBy.name":    deployment.Name,
-				resourcesv1alpha1.Group + "/placement":         deployment.Spec.Placement,
-			}
-			resourceToDeploy.Spec = resourcesv1alpha1.ResourceSpec{
-				Placement:   deployment.Spec.Placement,
-				ResourceRef: resource.Ref.Name,
-				Properties:  &runtime.RawExtension{Raw: rawProperties},
-			}
-			if err := ctrl.SetControllerReference(deployment, resourceToDeploy, r.Scheme); err != nil {
-				log.Error(err, "unable to set Resource's ownerReference")
-				return ctrl.Result{}, err
+	// Resources removed from the spec aren't in the dag anymore, so nothing known depends on them
+	orphans := make([]string, 0)
+	for name := range remaining {
+		if !slices.ContainsFunc(teardownLevels, func(level []string) bool { return slices.Contains(level, name) }) {
+			orphans = append(orphans, name)
+		}
+	}
+	slices.Sort(orphans)
+	teardownLevels = append([][]string{orphans}, teardownLevels...)
+
+	for i, level := range teardownLevels {
+		waiting := make([]string, 0)
+		for _, name := range level {
+			resource, ok := remaining[name]
+			if !ok {
+				continue
 			}
+			waiting = append(waiting, name)
 
-			if err := r.Create(ctx, resourceToDeploy); err != nil {
-				logWithResource.Error(err, fmt.Sprintf("unable to schedule Resource %s to be deployed", resourceNameToDeploy))
-
-				_, err = r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
-					Type:    resourcesv1alpha1.ConditionTypeFailed,
-					Status:  metav1.ConditionFalse,
-					Reason:  resourcesv1alpha1.ConditionReasonFailed,
-					Message: fmt.Sprintf("Unable to schedule Resource %s to be deployed", resourceNameToDeploy),
-				})
-
-				return ctrl.Result{}, err
+			if resource.DeletionTimestamp.IsZero() {
+				log.Info(fmt.Sprintf("Deleting Resource %s...", name))
+				if err := r.Delete(ctx, resource); client.IgnoreNotFound(err) != nil {
+					log.Error(err, fmt.Sprintf("unable to delete Resource %s", name))
+					return ctrl.Result{}, err
+				}
 			}
+		}
+
+		if len(waiting) == 0 {
+			continue
+		}
 
-			_, err = r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+		message := fmt.Sprintf("Tearing down ResourceGroupDeployment %s (level %d of %d); waiting for Resources %s", deployment.Name, i+1, len(teardownLevels), strings.Join(waiting, ", "))
+		if condition := meta.FindStatusCondition(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeInProgress); condition == nil || condition.Message != message {
+			deployment.Status.Phase = resourcesv1alpha1.DeploymentInProgressPhase
+			_, err := r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
 				Type:    resourcesv1alpha1.ConditionTypeInProgress,
 				Status:  metav1.ConditionTrue,
-				Reason:  resourcesv1alpha1.ConditionReasonDeploymentInProgress,
-				Message: fmt.Sprintf("Resource %s, from ResourceGroupDeployment %s, was successfully scheduled to be deployed", resourceNameToDeploy, deployment.Name),
+				Reason:  resourcesv1alpha1.ConditionReasonDestroying,
+				Message: message,
 			})
 			if err != nil {
-				log.Error(err, "failed to update ResourceGroupDeployment's status")
+				log.Error(err, "Failed to update ResourceGroupDeployment's status")
 				return ctrl.Result{}, err
 			}
+		}
 
-			logWithResource.Info(fmt.Sprintf("Resource %s scheduled to be deployed; deploy is in progress through reconciliation process", resourceNameToDeploy))
+		// the deletion of the Resources triggers the next reconciliation
+		return ctrl.Result{}, nil
+	}
 
-			// just reschedule the reconcilation
-			return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}, nil
-		} else {
-			err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				if err = r.Get(ctx, types.NamespacedName{Name: resourceNameToDeploy, Namespace: deployment.Namespace}, resourceToDeploy); err != nil {
-					return err
-				}
-				resourceToDeploy.Spec.Properties = &runtime.RawExtension{Raw: rawProperties}
-				return r.Update(ctx, resourceToDeploy)
-			})
-			if err != nil {
-				logWithResource.Error(err, fmt.Sprintf("unable to update spec properties from Resource %s", resourceNameToDeploy))
+	log.Info("All Resources were destroyed; releasing ResourceGroupDeployment...")
 
-				_, err = r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
-					Type:    resourcesv1alpha1.ConditionTypeFailed,
-					Status:  metav1.ConditionFalse,
-					Reason:  resourcesv1alpha1.ConditionReasonFailed,
-					Message: fmt.Sprintf("unable to update spec properties from Resource %s", resourceNameToDeploy),
-				})
+	// nothing uses the subnets of the placement anymore
+	if err := ipam.Release(ctx, r.Client, resourceGroupOf(deployment), deployment.Spec.Placement); err != nil {
+		log.Error(err, "unable to release the subnets allocated to ResourceGroupDeployment")
+		return ctrl.Result{}, err
+	}
 
-				return ctrl.Result{}, err
-			}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := r.Get(ctx, types.NamespacedName{Namespace: deployment.Namespace, Name: deployment.Name}, deployment); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if !controllerutil.RemoveFinalizer(deployment, ResourceGroupDeploymentTeardownFinalizer) {
+			return nil
 		}
+		return r.Update(ctx, deployment)
+	})
+
+	return ctrl.Result{}, err
+}
 
-		// check the current deployment to resource
-		if resourceToDeploy.Status.Phase == resourcesv1alpha1.DeploymentInProgressPhase {
-			return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}, nil
+// resourceGroupOf is the name of the ResourceGroup the deployment was generated from; a deployment created by hand
+// stands for its own group
+func resourceGroupOf(deployment *resourcesv1alpha1.ResourceGroupDeployment) string {
+	return cmp.Or(deployment.Labels[resourcesv1alpha1.Group+"/managedBy.name"], deployment.Name)
+}
+
+// forEachArgsOf is the scope of the forEach expressions from frozen inputs
+func forEachArgsOf(inputs *resourcesv1alpha1.ResourceGroupDeploymentInputs) (*resources.ResourcePropertiesArgs, error) {
+	parameters := make(map[string]any)
+	references := refs.NewReferences()
+
+	if inputs == nil {
+		return resources.NewResourcePropertiesArgs(parameters, references), nil
+	}
+
+	if inputs.Parameters != nil {
+		if err := json.Unmarshal(inputs.Parameters.Raw, &parameters); err != nil {
+			return nil, fmt.Errorf("failed to deserialize deployment parameters: %w", err)
 		}
+	}
 
-		// collect the resource to be used as argument and move to the next one
-		args, err = args.WithResource(resource.Name, resourceToDeploy)
+	if inputs.Refs != nil {
+		frozenReferences, err := refs.NewReferencesFromSnapshot(inputs.Refs.Raw)
 		if err != nil {
-			log.Error(err, "failed to update ResourcePropertiesArgs map")
-			return ctrl.Result{}, err
+			return nil, fmt.Errorf("failed to deserialize deployment refs: %w", err)
 		}
-
-		knowResources[resourceToDeploy.Name] = resourceToDeploy.Status
+		references = frozenReferences
 	}
 
-	log.Info("Updating deployment status...")
+	return resources.NewResourcePropertiesArgs(parameters, references), nil
+}
 
-	currentConditionType := resourcesv1alpha1.ConditionTypeReady
-	currentDeploymentPhase := resourcesv1alpha1.DeploymentDonePhase
-	for _, knowResource := range knowResources {
-		if knowResource.Phase == resourcesv1alpha1.DeploymentFailedPhase {
-			currentConditionType = resourcesv1alpha1.ConditionTypeFailed
-			currentDeploymentPhase = resourcesv1alpha1.DeploymentFailedPhase
-			break
+func (r *ResourceGroupDeploymentReconciler) resolveInputs(ctx context.Context, deployment *resourcesv1alpha1.ResourceGroupDeployment) (*resourcesv1alpha1.ResourceGroupDeploymentInputs, error) {
+	references := refs.NewReferences()
+
+	for _, ref := range deployment.Spec.Refs {
+		// secrets are read again by every run, never frozen with the inputs
+		if refs.IsSecret(ref) {
+			continue
 		}
-		if knowResource.Phase == resourcesv1alpha1.DeploymentInProgressPhase {
-			currentConditionType = resourcesv1alpha1.ConditionTypeInProgress
-			currentDeploymentPhase = resourcesv1alpha1.DeploymentInProgressPhase
-			break
+
+		referenceObject, err := references.NewReference(ctx, r.Client, ref)
+		if err != nil {
+			return nil, err
 		}
+
+		log.FromContext(ctx).Info(fmt.Sprintf("resolved reference: %+v", referenceObject))
 	}
 
-	deployment.Status.Resources = knowResources
-	deployment.Status.Phase = resourcesv1alpha1.ResourceGroupDeploymentStatusPhase(currentDeploymentPhase)
-	_, err = r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
-		Type:    currentConditionType,
-		Status:  metav1.ConditionTrue,
-		Reason:  resourcesv1alpha1.StatusPhaseToReason(currentDeploymentPhase),
-		Message: fmt.Sprintf("Resources from ResourceGroupDeployment %s were successfully scheduled to be deployed", deployment.Name),
-	})
+	snapshot, err := references.Snapshot()
 	if err != nil {
-		log.Error(err, "Failed to update ResourceGroupDeployment's status")
-		return ctrl.Result{}, err
+		return nil, err
 	}
 
-	if currentDeploymentPhase == resourcesv1alpha1.DeploymentDonePhase {
-		log.Info("Deployment finished.")
-		return ctrl.Result{}, nil
+	inputs := &resourcesv1alpha1.ResourceGroupDeploymentInputs{
+		ObservedGeneration: deployment.Generation,
+		ResolvedAt:         metav1.Now(),
+		Refs:               &runtime.RawExtension{Raw: snapshot},
 	}
+	if deployment.Spec.Parameters != nil {
+		inputs.Parameters = deployment.Spec.Parameters.DeepCopy()
+	}
+
+	return inputs, nil
+}
 
-	// reschedule the reconciliation until the deployment is done
-	return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}, nil
+func sameInputs(current *resourcesv1alpha1.ResourceGroupDeploymentInputs, candidate *resourcesv1alpha1.ResourceGroupDeploymentInputs) bool {
+	if current == nil || current.ObservedGeneration != candidate.ObservedGeneration {
+		return false
+	}
+	return equality.Semantic.DeepEqual(current.Parameters, candidate.Parameters) && equality.Semantic.DeepEqual(current.Refs, candidate.Refs)
+}
+
+// applyElementMetadata merges the labels and annotations declared to a group element into the Resource;
+// keys under the klaudio domain are skipped, so the managedBy labels can't be overridden.
+func applyElementMetadata(resource *resourcesv1alpha1.Resource, metadata *resourcesv1alpha1.ResourceGroupElementMetadata) {
+	if metadata == nil {
+		return
+	}
+
+	merge := func(target map[string]string, source map[string]string) map[string]string {
+		for k, v := range source {
+			if strings.HasPrefix(k, resourcesv1alpha1.Group+"/") {
+				continue
+			}
+			if target == nil {
+				target = make(map[string]string)
+			}
+			target[k] = v
+		}
+		return target
+	}
+
+	resource.Labels = merge(resource.Labels, metadata.Labels)
+	resource.Annotations = merge(resource.Annotations, metadata.Annotations)
 }
 
 func (r *ResourceGroupDeploymentReconciler) newResourceGroupDeploymentCondition(ctx context.Context, resourceGroupDeployment *resourcesv1alpha1.ResourceGroupDeployment, newCondition *metav1.Condition) (*resourcesv1alpha1.ResourceGroupDeployment, error) {
-	meta.SetStatusCondition(&resourceGroupDeployment.Status.Conditions, *newCondition)
+	setStatusCondition(&resourceGroupDeployment.Status.Conditions, *newCondition)
 	if err := r.Status().Update(ctx, resourceGroupDeployment); err != nil {
 		return nil, err
 	}
@@ -302,7 +446,33 @@ func (r *ResourceGroupDeploymentReconciler) newResourceGroupDeploymentCondition(
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ResourceGroupDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &resourcesv1alpha1.ResourceGroupDeployment{}, deploymentRefsIndex, refIndexKeysOf); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&resourcesv1alpha1.ResourceGroupDeployment{}).
+		For(&resourcesv1alpha1.ResourceGroupDeployment{}, builder.WithPredicates(shardPredicate(r.ShardSelector))).
+		Owns(&resourcesv1alpha1.Resource{}).
+		Watches(&resourcesv1alpha1.Placement{}, handler.EnqueueRequestsFromMapFunc(r.deploymentsToPlacement)).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.deploymentsToRef(resourcesv1alpha1.ResourceGroupRefConfigMap))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.deploymentsToRef(resourcesv1alpha1.ResourceGroupRefSecret))).
 		Complete(reconcile.AsReconciler(mgr.GetClient(), r))
 }
+
+// deploymentsToPlacement maps a Placement to every ResourceGroupDeployment targeting it
+func (r *ResourceGroupDeploymentReconciler) deploymentsToPlacement(ctx context.Context, obj client.Object) []reconcile.Request {
+	deployments := &resourcesv1alpha1.ResourceGroupDeploymentList{}
+	if err := r.List(ctx, deployments, client.MatchingLabels{resourcesv1alpha1.Group + "/placement": obj.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ResourceGroupDeployments", "placement", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(deployments.Items))
+	for _, deployment := range deployments.Items {
+		if !inShard(r.ShardSelector, &deployment) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&deployment)})
+	}
+	return requests
+}
@@ -0,0 +1,709 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/changeset"
+	"github.com/nubank/klaudio/internal/expression"
+	"github.com/nubank/klaudio/internal/generated"
+	"github.com/nubank/klaudio/internal/ipam"
+	"github.com/nubank/klaudio/internal/outputs"
+	"github.com/nubank/klaudio/internal/refs"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// deploymentRun is the state shared by the stages of a deployment reconciliation; each stage fills what the next
+// ones need
+type deploymentRun struct {
+	deployment *resourcesv1alpha1.ResourceGroupDeployment
+	// placement is the Placement targeted by the deployment; nil when it doesn't exist
+	placement *resourcesv1alpha1.Placement
+
+	// filled by the inputs stage
+	parameters map[string]any
+	references *refs.References
+
+	// filled by the graph stage
+	resourceGroup    *resources.ResourceGroup
+	dag              []string
+	levels           [][]string
+	args             *resources.ResourcePropertiesArgs
+	specOf           resourceSpecRenderer
+	driftPolicies    map[string]resourcesv1alpha1.DriftPolicy
+	deletionPolicies map[string]resourcesv1alpha1.DeletionPolicy
+	protected        map[string]bool
+	elementMetadata  map[string]*resourcesv1alpha1.ResourceGroupElementMetadata
+	writeOutputsTo   map[string]*resourcesv1alpha1.ResourceOutputsTarget
+	objectNames      map[string]string
+	verifications    map[string]*resourcesv1alpha1.ResourceVerification
+	hooks            map[string]*resourcesv1alpha1.ResourceHooks
+
+	// filled by the apply stage
+	deployed resourcesv1alpha1.ResourceGroupDeploymentResourcesStatuses
+}
+
+// deploymentStage is a step of the deployment pipeline. A stage returning a result stops the pipeline, and the result
+// is returned from Reconcile; a stage returning nothing lets the next one run.
+type deploymentStage struct {
+	name string
+	// condition is kept by the pipeline: True once the stage is done at the current generation, False when it fails
+	condition string
+	run       func(ctx context.Context, run *deploymentRun) (*ctrl.Result, error)
+}
+
+// pipeline lists the stages of a deployment, in order
+func (r *ResourceGroupDeploymentReconciler) pipeline() []deploymentStage {
+	return []deploymentStage{
+		{name: "inputs", condition: resourcesv1alpha1.ConditionTypeInputsResolved, run: r.resolveInputsStage},
+		{name: "graph", condition: resourcesv1alpha1.ConditionTypeGraphBuilt, run: r.buildGraphStage},
+		{name: "render", condition: resourcesv1alpha1.ConditionTypeRendered, run: r.renderStage},
+		{name: "prune", run: r.pruneStage},
+		{name: "apply", condition: resourcesv1alpha1.ConditionTypeApplied, run: r.applyStage},
+		{name: "status", run: r.aggregateStatusStage},
+	}
+}
+
+// runPipeline runs the stages one after another, until one of them stops the deployment or fails
+func (r *ResourceGroupDeploymentReconciler) runPipeline(ctx context.Context, run *deploymentRun, stages []deploymentStage) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroupDeployment", run.deployment.Name)
+
+	for _, stage := range stages {
+		result, err := stage.run(ctx, run)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("stage %s failed", stage.name))
+
+			if stage.condition != "" {
+				if _, conditionErr := r.newResourceGroupDeploymentCondition(ctx, run.deployment, &metav1.Condition{
+					Type:               stage.condition,
+					Status:             metav1.ConditionFalse,
+					Reason:             resourcesv1alpha1.ConditionReasonStageFailed,
+					ObservedGeneration: run.deployment.Generation,
+					Message:            fmt.Sprintf("Stage %s failed: %s", stage.name, err.Error()),
+				}); conditionErr != nil {
+					log.Error(conditionErr, "Failed to update ResourceGroupDeployment's status")
+				}
+			}
+
+			return ctrl.Result{}, err
+		}
+
+		if result != nil {
+			return *result, nil
+		}
+
+		if stage.condition == "" {
+			continue
+		}
+
+		if condition := meta.FindStatusCondition(run.deployment.Status.Conditions, stage.condition); condition != nil && condition.Status == metav1.ConditionTrue && condition.ObservedGeneration == run.deployment.Generation {
+			continue
+		}
+
+		if _, err := r.newResourceGroupDeploymentCondition(ctx, run.deployment, &metav1.Condition{
+			Type:               stage.condition,
+			Status:             metav1.ConditionTrue,
+			Reason:             resourcesv1alpha1.ConditionReasonStageSucceeded,
+			ObservedGeneration: run.deployment.Generation,
+			Message:            fmt.Sprintf("Stage %s succeeded at generation %d", stage.name, run.deployment.Generation),
+		}); err != nil {
+			log.Error(err, "Failed to update ResourceGroupDeployment's status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// resolveInputsStage resolves parameters and references; inputs are frozen while the deployment run is in progress,
+// so all resources are rendered from the same values even if a ref changes in the middle of the rollout
+func (r *ResourceGroupDeploymentReconciler) resolveInputsStage(ctx context.Context, run *deploymentRun) (*ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroupDeployment", run.deployment.Name)
+	deployment := run.deployment
+
+	inputs := deployment.Status.Inputs
+	if inputs == nil || inputs.ObservedGeneration != deployment.Generation || deployment.Status.Phase != resourcesv1alpha1.DeploymentInProgressPhase {
+		newInputs, err := r.resolveInputs(ctx, deployment)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve deployment inputs: %w", err)
+		}
+
+		if !sameInputs(inputs, newInputs) {
+			// a new snapshot starts a new deployment run
+			deployment.Status.Inputs = newInputs
+			deployment.Status.Phase = resourcesv1alpha1.DeploymentInProgressPhase
+			if _, err := r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeInProgress,
+				Status:  metav1.ConditionTrue,
+				Reason:  resourcesv1alpha1.ConditionReasonReconciling,
+				Message: fmt.Sprintf("Inputs from ResourceGroupDeployment %s were resolved at generation %d", deployment.Name, deployment.Generation),
+			}); err != nil {
+				return nil, err
+			}
+
+			log.Info(fmt.Sprintf("deployment inputs were frozen at generation %d", deployment.Status.Inputs.ObservedGeneration))
+		}
+
+		inputs = deployment.Status.Inputs
+	}
+
+	run.parameters = make(map[string]any)
+	if inputs.Parameters != nil {
+		if err := json.Unmarshal(inputs.Parameters.Raw, &run.parameters); err != nil {
+			return nil, fmt.Errorf("failed to deserialize deployment parameters: %w", err)
+		}
+	}
+
+	run.references = refs.NewReferences()
+	if inputs.Refs != nil {
+		frozenReferences, err := refs.NewReferencesFromSnapshot(inputs.Refs.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deserialize deployment refs: %w", err)
+		}
+		run.references = frozenReferences
+	}
+
+	return nil, nil
+}
+
+// buildGraphStage traverses all resources to determine the relationship between them, generating a dag
+func (r *ResourceGroupDeploymentReconciler) buildGraphStage(ctx context.Context, run *deploymentRun) (*ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroupDeployment", run.deployment.Name)
+	deployment := run.deployment
+
+	run.resourceGroup = resources.NewResourceGroup().WithExpressionLanguage(expression.Language(deployment.Spec.ExpressionLanguage))
+
+	// a resource-level drift policy overrides the one declared to the whole group
+	run.driftPolicies = make(map[string]resourcesv1alpha1.DriftPolicy)
+
+	// what happens to the infrastructure of each resource when it's deleted
+	run.deletionPolicies = make(map[string]resourcesv1alpha1.DeletionPolicy)
+	run.protected = make(map[string]bool)
+
+	// labels and annotations passed through to the generated Resources
+	run.elementMetadata = make(map[string]*resourcesv1alpha1.ResourceGroupElementMetadata)
+
+	// where the outputs of each resource are written to, besides the output store
+	run.writeOutputsTo = make(map[string]*resourcesv1alpha1.ResourceOutputsTarget)
+
+	// names of the objects created by the provisioners, when they aren't named after the Resources
+	run.objectNames = make(map[string]string)
+
+	// Jobs verifying each resource once it's provisioned
+	run.verifications = make(map[string]*resourcesv1alpha1.ResourceVerification)
+
+	// Jobs run by the teardown of each resource
+	run.hooks = make(map[string]*resourcesv1alpha1.ResourceHooks)
+
+	// forEach is evaluated before anything is deployed, so it only reads parameters and refs
+	forEachArgs := resources.NewResourcePropertiesArgs(run.parameters, run.references)
+
+	err := run.resourceGroup.NewElements(deployment.Spec.Resources, forEachArgs, func(resource *resources.Resource, candidate resourcesv1alpha1.ResourceGroupElement) error {
+		// every resource must reference a ResourceRef object
+		resourceRef, err := resourceRefOf(ctx, r.ResourceRefs, r.Client, candidate.ResourceRef)
+		if err != nil {
+			return fmt.Errorf("unable to fetch ResourceRef %s: %w", candidate.ResourceRef, err)
+		}
+		resource.Ref = resourceRef
+
+		run.driftPolicies[resource.Name] = deployment.Spec.DriftPolicy
+		if candidate.DriftPolicy != "" {
+			run.driftPolicies[resource.Name] = candidate.DriftPolicy
+		}
+
+		run.elementMetadata[resource.Name] = candidate.Metadata
+		run.deletionPolicies[resource.Name] = candidate.DeletionPolicy
+		run.protected[resource.Name] = candidate.Protect
+		run.writeOutputsTo[resource.Name] = candidate.WriteOutputsTo
+		run.objectNames[resource.Name] = resource.ProvisionerObjectNameOf(candidate.ProvisionerObjectName)
+		run.verifications[resource.Name] = candidate.Verification
+		run.hooks[resource.Name] = candidate.Hooks
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dag, err := run.resourceGroup.Graph()
+	if err != nil {
+		var cycle *resources.CycleError
+		if !errors.As(err, &cycle) {
+			return nil, fmt.Errorf("unable to generate a graph from deployment resources: %w", err)
+		}
+
+		log.Info(fmt.Sprintf("Resources from deployment %s have a dependency cycle: %s", deployment.Name, strings.Join(cycle.Path, " -> ")))
+
+		if r.Recorder != nil {
+			r.Recorder.Event(deployment, corev1.EventTypeWarning, resourcesv1alpha1.ConditionReasonDependencyCycle, cycle.Error())
+		}
+
+		deployment.Status.Phase = resourcesv1alpha1.DeploymentFailedPhase
+		if _, err := r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeFailed,
+			Status:  metav1.ConditionFalse,
+			Reason:  resourcesv1alpha1.ConditionReasonInputError,
+			Message: fmt.Sprintf("Unable to deploy resources: %s. Remove one of these references to break the cycle.", cycle.Error()),
+		}); err != nil {
+			return nil, err
+		}
+
+		// retrying doesn't help; a change to the ResourceGroup triggers a new reconciliation
+		return &ctrl.Result{}, nil
+	}
+	run.dag = dag
+
+	log.Info(fmt.Sprintf("Generated dag: %s", dag))
+
+	// published with the next status update
+	renderedGraph, err := renderGraph(deployment, run.resourceGroup)
+	if err != nil {
+		return nil, fmt.Errorf("unable to render the graph from deployment resources: %w", err)
+	}
+	deployment.Status.Graph = renderedGraph
+
+	levels, err := run.resourceGroup.DeploymentLevels()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate a graph from deployment resources: %w", err)
+	}
+	run.levels = levels
+
+	// subnets allocated by cidralloc are recorded to the whole group; a plan only previews them
+	allocator := ipam.NewAllocator(ctx, r.Client, resourceGroupOf(deployment), deployment.Spec.Placement, deployment.Spec.Mode == resourcesv1alpha1.DeploymentModePlan).
+		WithLegacyNamespace(deployment.Namespace)
+	// and so are the values generated by now and randomsuffix
+	generator := generated.NewGenerator(ctx, r.Client, deployment.Namespace, deployment.Spec.Placement, deployment.Spec.Mode == resourcesv1alpha1.DeploymentModePlan)
+	placement := run.placement
+	if placement == nil {
+		placement = &resourcesv1alpha1.Placement{ObjectMeta: metav1.ObjectMeta{Name: deployment.Spec.Placement}}
+	}
+
+	// unlike the other refs, secrets are read again on every run, so they never end up in the frozen inputs
+	secrets, err := refs.Secrets(ctx, r.Client, deployment.Namespace, deployment.Spec.Refs)
+	if err != nil {
+		return nil, err
+	}
+
+	run.args = resources.NewResourcePropertiesArgs(run.parameters, run.references).
+		WithCIDRAllocator(allocator).
+		WithValueGenerator(generator).
+		WithPlacement(placement).
+		WithSecrets(secrets)
+
+	run.specOf = func(resource *resources.Resource, rawProperties []byte) resourcesv1alpha1.ResourceSpec {
+		return resourcesv1alpha1.ResourceSpec{
+			Placement:             deployment.Spec.Placement,
+			ResourceRef:           resource.Ref.Name,
+			Properties:            &runtime.RawExtension{Raw: rawProperties},
+			DriftPolicy:           run.driftPolicies[resource.Name],
+			DeletionPolicy:        run.deletionPolicies[resource.Name],
+			Protect:               run.protected[resource.Name],
+			WriteOutputsTo:        run.writeOutputsTo[resource.Name],
+			ProvisionerObjectName: run.objectNames[resource.Name],
+			Verification:          run.verifications[resource.Name],
+			Hooks:                 run.hooks[resource.Name],
+		}
+	}
+
+	return nil, nil
+}
+
+// renderStage renders the change set of the deployment: in Plan mode, it's just published; otherwise, it must fit
+// the blast radius and, with manual approval, be approved before anything is applied
+func (r *ResourceGroupDeploymentReconciler) renderStage(ctx context.Context, run *deploymentRun) (*ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroupDeployment", run.deployment.Name)
+	deployment := run.deployment
+
+	if deployment.Spec.Mode == resourcesv1alpha1.DeploymentModePlan {
+		result, err := r.plan(ctx, deployment, run.resourceGroup, run.dag, run.args, run.specOf)
+		return &result, err
+	}
+
+	// a plan is only kept while the deployment is in Plan mode
+	deployment.Status.Plan = nil
+	meta.RemoveStatusCondition(&deployment.Status.Conditions, resourcesv1alpha1.ConditionTypePlanned)
+
+	// the blast radius is checked before anything is applied; a generation approved by an operator skips the guard
+	if deployment.Spec.BlastRadius != nil && deployment.Annotations[BlastRadiusApprovalAnnotation] != strconv.FormatInt(deployment.Generation, 10) {
+		changes, err := r.changeSet(ctx, deployment, run.resourceGroup, run.dag, run.args, run.specOf, false)
+		if err != nil {
+			return nil, fmt.Errorf("unable to compute the deployment change set: %w", err)
+		}
+
+		if exceeded := exceededBlastRadius(deployment.Spec.BlastRadius, changes); exceeded != "" {
+			log.Info(fmt.Sprintf("deployment blocked by its blast radius: %s", exceeded))
+
+			deployment.Status.Phase = resourcesv1alpha1.DeploymentFailedPhase
+			if _, err := r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeFailed,
+				Status:  metav1.ConditionFalse,
+				Reason:  resourcesv1alpha1.ConditionReasonPolicyViolation,
+				Message: fmt.Sprintf("Deployment blocked: %s. Set the annotation %s=%d to approve it", exceeded, BlastRadiusApprovalAnnotation, deployment.Generation),
+			}); err != nil {
+				return nil, err
+			}
+
+			// a new generation, or the approval annotation, triggers a new reconciliation
+			return &ctrl.Result{}, nil
+		}
+	}
+
+	if deployment.Spec.Approval == resourcesv1alpha1.ApprovalPolicyManual {
+		approved, err := r.approve(ctx, deployment, run.resourceGroup, run.dag, run.args, run.specOf)
+		if err != nil {
+			return nil, fmt.Errorf("unable to update ResourceGroupDeployment's approval: %w", err)
+		}
+		if !approved {
+			log.Info(fmt.Sprintf("deployment is waiting for approval of generation %d", deployment.Generation))
+			return &ctrl.Result{}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// applyStage expands and generates each resource, level by level; resources in the same level don't depend on each
+// other, so all of them are scheduled at once and provisioned concurrently
+func (r *ResourceGroupDeploymentReconciler) applyStage(ctx context.Context, run *deploymentRun) (*ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroupDeployment", run.deployment.Name)
+	deployment := run.deployment
+
+	run.deployed = make(resourcesv1alpha1.ResourceGroupDeploymentResourcesStatuses)
+
+	// deployed Resources, by resource name, whose outputs are used by the next levels
+	sources := make(map[string]*resourcesv1alpha1.Resource)
+	now := time.Now()
+
+	for i, level := range run.levels {
+		scheduled := make([]string, 0)
+		inProgress := false
+
+		deployedResources := make(map[string]*resourcesv1alpha1.Resource)
+
+		for _, resourceName := range level {
+			resource, err := run.resourceGroup.Get(resourceName)
+			if err != nil {
+				return nil, err
+			}
+			logWithResource := log.WithValues("resource", resource.Name)
+
+			log.Info(fmt.Sprintf("Processing %s...", resource.Name))
+
+			resourceNameToDeploy := fmt.Sprintf("%s.%s", deployment.Name, resource.NameAsKebabCase())
+
+			// outputs older than the threshold are refreshed before the resource is rendered from them again
+			if stale := staleSources(resource, sources, r.OutputsStalenessThreshold, now); len(stale) != 0 {
+				for _, source := range stale {
+					logWithResource.Info(fmt.Sprintf("outputs from Resource %s are stale; requesting a refresh...", source.Name))
+					if err := r.requestRefresh(ctx, source); err != nil {
+						return nil, fmt.Errorf("unable to request a refresh of Resource %s: %w", source.Name, err)
+					}
+				}
+
+				dependent := &resourcesv1alpha1.Resource{}
+				if err := r.Get(ctx, types.NamespacedName{Namespace: deployment.Namespace, Name: resourceNameToDeploy}, dependent); err != nil {
+					if !apierrors.IsNotFound(err) {
+						return nil, fmt.Errorf("unable to fetch Resource %s: %w", resourceNameToDeploy, err)
+					}
+				} else if err := r.staleInputsCondition(ctx, dependent, stale); err != nil {
+					return nil, fmt.Errorf("unable to update the status of Resource %s: %w", resourceNameToDeploy, err)
+				}
+
+				// the refreshed outputs trigger the next reconciliation
+				inProgress = true
+				continue
+			}
+
+			// first, expand properties; every dependency was deployed in a previous level
+			expandedProperties, err := resource.Evaluate(run.args)
+
+			// outputs a dependency hasn't produced yet hold the resource back, instead of failing the deployment
+			var unknown *expression.UnknownError
+			if errors.As(err, &unknown) {
+				logWithResource.Info(fmt.Sprintf("resource %s is %s", resource.Name, unknown.Error()))
+
+				if _, err := r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+					Type:    resourcesv1alpha1.ConditionTypeInProgress,
+					Status:  metav1.ConditionTrue,
+					Reason:  resourcesv1alpha1.ConditionReasonDependencyNotReady,
+					Message: fmt.Sprintf("Resource %s is %s", resource.Name, unknown.Error()),
+				}); err != nil {
+					return nil, err
+				}
+
+				// changes on the Resources it's waiting on trigger the next reconciliation
+				inProgress = true
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unable to evaluate properties from resource %s: %w", resource.Name, err)
+			}
+
+			rawProperties, err := json.Marshal(expandedProperties)
+			if err != nil {
+				return nil, fmt.Errorf("unable to serialize properties from resource %s: %w", resource.Name, err)
+			}
+
+			// the expanded properties must match the schema declared by the ResourceRef
+			if resource.Ref != nil {
+				if violations := resources.ValidateSchema(&resource.Ref.Spec.Schema, rawProperties); len(violations) != 0 {
+					logWithResource.Info(fmt.Sprintf("properties from resource %s don't match the schema of ResourceRef %s", resource.Name, resource.Ref.Name))
+
+					deployment.Status.Phase = resourcesv1alpha1.DeploymentFailedPhase
+					if _, err := r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+						Type:    resourcesv1alpha1.ConditionTypeFailed,
+						Status:  metav1.ConditionFalse,
+						Reason:  resourcesv1alpha1.ConditionReasonInputError,
+						Message: fmt.Sprintf("Properties from resource %s don't match the schema of ResourceRef %s: %s", resource.Name, resource.Ref.Name, violations.ToAggregate().Error()),
+					}); err != nil {
+						return nil, err
+					}
+
+					// a new generation, or a change to the ResourceGroup, triggers a new reconciliation
+					return &ctrl.Result{}, nil
+				}
+			}
+
+			// values read from Secret refs are kept in a Secret of their own, out of the spec of the Resource
+			spec := run.specOf(resource, rawProperties)
+			secretProperties, err := changeset.SeparateSecretProperties(&spec, resourcesv1alpha1.SecretPropertiesNameOf(resourceNameToDeploy), resource.SecretProperties())
+			if err != nil {
+				return nil, fmt.Errorf("unable to read the secret properties from resource %s: %w", resource.Name, err)
+			}
+
+			resourceToDeploy := &resourcesv1alpha1.Resource{}
+			if err := r.Get(ctx, types.NamespacedName{Namespace: deployment.Namespace, Name: resourceNameToDeploy}, resourceToDeploy); err != nil {
+				if !apierrors.IsNotFound(err) {
+					return nil, fmt.Errorf("unable to fetch Resource %s: %w", resourceNameToDeploy, err)
+				}
+
+				// there is no Resource yet; just create it
+				log.Info(fmt.Sprintf("Creating Resource %s...", resourceNameToDeploy))
+
+				resourceToDeploy.Name = resourceNameToDeploy
+				resourceToDeploy.Namespace = deployment.Namespace
+				resourceToDeploy.Labels = map[string]string{
+					resourcesv1alpha1.Group + "/managedBy.group":   deployment.GroupVersionKind().Group,
+					resourcesv1alpha1.Group + "/managedBy.version": deployment.GroupVersionKind().Version,
+					resourcesv1alpha1.Group + "/managedBy.kind":    deployment.GroupVersionKind().Kind,
+					resourcesv1alpha1.Group + "/managedBy.name":    deployment.Name,
+					resourcesv1alpha1.Group + "/placement":         deployment.Spec.Placement,
+				}
+				applyElementMetadata(resourceToDeploy, run.elementMetadata[resource.Name])
+				if err := applyProvenance(resourceToDeploy, resource, expandedProperties); err != nil {
+					return nil, fmt.Errorf("unable to record the provenance of properties from Resource %s: %w", resourceNameToDeploy, err)
+				}
+				resourceToDeploy.Spec = spec
+				var standby *resourcesv1alpha1.Resource
+				if resourceToDeploy.Spec.ProvisionerObjectName == "" {
+					// a standby Resource from a pool takes the wait out of creating slow infrastructure
+					standby, err = claimFromPool(ctx, r.Client, resourceToDeploy)
+					if err != nil {
+						return nil, fmt.Errorf("unable to claim a standby Resource to %s: %w", resourceNameToDeploy, err)
+					}
+					if standby != nil {
+						logWithResource.Info(fmt.Sprintf("Resource %s claimed the standby Resource %s from pool %s", resourceNameToDeploy, standby.Name, standby.Labels[resourcesv1alpha1.ResourcePoolLabel]))
+
+						resourceToDeploy.Spec.ProvisionerObjectName = standbyObjectNameOf(standby)
+						if resourceToDeploy.Annotations == nil {
+							resourceToDeploy.Annotations = make(map[string]string)
+						}
+						resourceToDeploy.Annotations[resourcesv1alpha1.ResourcePoolClaimedFromAnnotation] = standby.Name
+						resourceToDeploy.Labels[resourcesv1alpha1.ResourcePoolClaimedFromLabel] = standby.Labels[resourcesv1alpha1.ResourcePoolLabel]
+					}
+				}
+				if err := ctrl.SetControllerReference(deployment, resourceToDeploy, r.Scheme); err != nil {
+					return nil, fmt.Errorf("unable to set ownerReference from Resource %s: %w", resourceNameToDeploy, err)
+				}
+
+				if err := r.Create(ctx, resourceToDeploy); err != nil {
+					return nil, fmt.Errorf("unable to schedule Resource %s to be deployed: %w", resourceNameToDeploy, err)
+				}
+				if standby != nil {
+					if err := adoptStandby(ctx, r.Client, standby.Name, resourceToDeploy); err != nil {
+						return nil, fmt.Errorf("unable to take the provisioner object of standby Resource %s over to %s: %w", standby.Name, resourceNameToDeploy, err)
+					}
+				}
+				if err := writeSecretProperties(ctx, r.Client, r.Scheme, resourceToDeploy, secretProperties); err != nil {
+					return nil, fmt.Errorf("unable to write the secret properties of Resource %s: %w", resourceNameToDeploy, err)
+				}
+
+				logWithResource.Info(fmt.Sprintf("Resource %s scheduled to be deployed; deploy is in progress through reconciliation process", resourceNameToDeploy))
+
+				scheduled = append(scheduled, resourceNameToDeploy)
+				continue
+			}
+
+			// written before the spec changes, so the provisioner never runs the new spec with the previous values
+			readSecretProperties := resourceToDeploy.Spec.SecretProperties != nil
+			if len(secretProperties) != 0 {
+				if err := writeSecretProperties(ctx, r.Client, r.Scheme, resourceToDeploy, secretProperties); err != nil {
+					return nil, fmt.Errorf("unable to write the secret properties of Resource %s: %w", resourceNameToDeploy, err)
+				}
+			}
+
+			err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+				if err = r.Get(ctx, types.NamespacedName{Name: resourceNameToDeploy, Namespace: deployment.Namespace}, resourceToDeploy); err != nil {
+					return err
+				}
+				resourceToDeploy.Spec.Properties = spec.Properties
+				resourceToDeploy.Spec.SecretProperties = spec.SecretProperties
+				resourceToDeploy.Spec.DriftPolicy = run.driftPolicies[resource.Name]
+				resourceToDeploy.Spec.DeletionPolicy = run.deletionPolicies[resource.Name]
+				resourceToDeploy.Spec.Protect = run.protected[resource.Name]
+				resourceToDeploy.Spec.WriteOutputsTo = run.writeOutputsTo[resource.Name]
+				resourceToDeploy.Spec.ProvisionerObjectName = resourcesv1alpha1.ProvisionerObjectNameOf(resourceToDeploy, run.objectNames[resource.Name])
+				resourceToDeploy.Spec.Verification = run.verifications[resource.Name]
+				resourceToDeploy.Spec.Hooks = run.hooks[resource.Name]
+				applyElementMetadata(resourceToDeploy, run.elementMetadata[resource.Name])
+				if err := applyProvenance(resourceToDeploy, resource, expandedProperties); err != nil {
+					return err
+				}
+				return r.Update(ctx, resourceToDeploy)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("unable to update spec properties from Resource %s: %w", resourceNameToDeploy, err)
+			}
+
+			// and deleted once the spec no longer reads them
+			if readSecretProperties && len(secretProperties) == 0 {
+				if err := writeSecretProperties(ctx, r.Client, r.Scheme, resourceToDeploy, nil); err != nil {
+					return nil, fmt.Errorf("unable to delete the secret properties of Resource %s: %w", resourceNameToDeploy, err)
+				}
+			}
+
+			if err := r.staleInputsCondition(ctx, resourceToDeploy, nil); err != nil {
+				return nil, fmt.Errorf("unable to update the status of Resource %s: %w", resourceNameToDeploy, err)
+			}
+
+			// check the current deployment to resource
+			if resourceToDeploy.Status.Phase == resourcesv1alpha1.DeploymentInProgressPhase {
+				inProgress = true
+				continue
+			}
+
+			deployedResources[resourceName] = resourceToDeploy
+		}
+
+		if len(scheduled) != 0 {
+			if _, err := r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeInProgress,
+				Status:  metav1.ConditionTrue,
+				Reason:  resourcesv1alpha1.ConditionReasonDeploymentInProgress,
+				Message: fmt.Sprintf("Resources %s, from ResourceGroupDeployment %s, were successfully scheduled to be deployed", strings.Join(scheduled, ", "), deployment.Name),
+			}); err != nil {
+				return nil, err
+			}
+		}
+
+		// the next level can only be evaluated with the outputs of this one
+		if len(scheduled) != 0 || inProgress {
+			log.Info(fmt.Sprintf("Level %d of %d is in progress: %s", i+1, len(run.levels), level))
+
+			// changes on the Resources trigger the next reconciliation
+			return &ctrl.Result{}, nil
+		}
+
+		// collect the resources to be used as arguments and move to the next level
+		for _, resourceName := range level {
+			resource, err := run.resourceGroup.Get(resourceName)
+			if err != nil {
+				return nil, err
+			}
+			resourceToDeploy := deployedResources[resourceName]
+
+			resolvedResource, err := outputs.Resolve(ctx, r.Client, resourceToDeploy)
+			if err != nil {
+				return nil, fmt.Errorf("unable to load outputs from Resource %s: %w", resourceToDeploy.Name, err)
+			}
+
+			run.args, err = run.args.WithResource(resource, resolvedResource)
+			if err != nil {
+				return nil, fmt.Errorf("unable to update arguments with Resource %s: %w", resourceToDeploy.Name, err)
+			}
+
+			run.deployed[resourceToDeploy.Name] = resourceToDeploy.Status
+			sources[resource.Name] = resourceToDeploy
+		}
+	}
+
+	return nil, nil
+}
+
+// aggregateStatusStage sums up the status of the deployed Resources into the deployment status
+func (r *ResourceGroupDeploymentReconciler) aggregateStatusStage(ctx context.Context, run *deploymentRun) (*ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroupDeployment", run.deployment.Name)
+	deployment := run.deployment
+
+	log.Info("Updating deployment status...")
+
+	currentConditionType := resourcesv1alpha1.ConditionTypeReady
+	currentDeploymentPhase := resourcesv1alpha1.DeploymentDonePhase
+	for _, knowResource := range run.deployed {
+		if knowResource.Phase == resourcesv1alpha1.DeploymentFailedPhase {
+			currentConditionType = resourcesv1alpha1.ConditionTypeFailed
+			currentDeploymentPhase = resourcesv1alpha1.DeploymentFailedPhase
+			break
+		}
+		if knowResource.Phase == resourcesv1alpha1.DeploymentInProgressPhase {
+			currentConditionType = resourcesv1alpha1.ConditionTypeInProgress
+			currentDeploymentPhase = resourcesv1alpha1.DeploymentInProgressPhase
+			break
+		}
+	}
+
+	reason := resourcesv1alpha1.StatusPhaseToReason(currentDeploymentPhase)
+	if currentDeploymentPhase == resourcesv1alpha1.DeploymentFailedPhase {
+		reason = failureReasonOfResources(run.deployed)
+	}
+
+	previousPhase := deployment.Status.Phase
+
+	deployment.Status.Resources = run.deployed
+	deployment.Status.Phase = currentDeploymentPhase
+
+	// the SlowProvisioning condition is persisted with the new phase
+	checkTimeToReadyAfter := trackTimeToReady(deployment, previousPhase, time.Now())
+	finished := previousPhase != resourcesv1alpha1.DeploymentDonePhase && currentDeploymentPhase == resourcesv1alpha1.DeploymentDonePhase
+
+	if _, err := r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+		Type:    currentConditionType,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: fmt.Sprintf("Resources from ResourceGroupDeployment %s were successfully scheduled to be deployed", deployment.Name),
+	}); err != nil {
+		return nil, err
+	}
+
+	if finished {
+		observeTimeToReady(deployment)
+	}
+
+	if currentDeploymentPhase == resourcesv1alpha1.DeploymentDonePhase {
+		log.Info("Deployment finished.")
+
+		// outputs are checked again once they may be stale
+		if r.OutputsStalenessThreshold > 0 {
+			return &ctrl.Result{RequeueAfter: r.OutputsStalenessThreshold}, nil
+		}
+	}
+
+	// changes on the Resources trigger the next reconciliation, until the deployment is done; a run in progress is
+	// checked again once it would breach its time-to-ready SLO
+	return &ctrl.Result{RequeueAfter: checkTimeToReadyAfter}, nil
+}
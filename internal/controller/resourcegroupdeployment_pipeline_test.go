@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("ResourceGroupDeployment pipeline", func() {
+	Context("When running the deployment stages", func() {
+		const deploymentName = "test-pipeline"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{Name: deploymentName, Namespace: "default"}
+
+		var deployment *resourcesv1alpha1.ResourceGroupDeployment
+		var reconciler *ResourceGroupDeploymentReconciler
+
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, &resourcesv1alpha1.ResourceGroupDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: deploymentName, Namespace: "default"},
+			})).To(Succeed())
+
+			deployment = &resourcesv1alpha1.ResourceGroupDeployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+
+			reconciler = &ResourceGroupDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, deployment)).To(Succeed())
+		})
+
+		It("should run the stages in order and mark each of them as done", func() {
+			executed := make([]string, 0)
+			stage := func(name string) func(context.Context, *deploymentRun) (*ctrl.Result, error) {
+				return func(context.Context, *deploymentRun) (*ctrl.Result, error) {
+					executed = append(executed, name)
+					return nil, nil
+				}
+			}
+
+			_, err := reconciler.runPipeline(ctx, &deploymentRun{deployment: deployment}, []deploymentStage{
+				{name: "inputs", condition: resourcesv1alpha1.ConditionTypeInputsResolved, run: stage("inputs")},
+				{name: "graph", condition: resourcesv1alpha1.ConditionTypeGraphBuilt, run: stage("graph")},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(executed).To(Equal([]string{"inputs", "graph"}))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeInputsResolved)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeGraphBuilt)).To(BeTrue())
+		})
+
+		It("should stop when a stage returns a result", func() {
+			executed := make([]string, 0)
+
+			result, err := reconciler.runPipeline(ctx, &deploymentRun{deployment: deployment}, []deploymentStage{
+				{name: "render", condition: resourcesv1alpha1.ConditionTypeRendered, run: func(context.Context, *deploymentRun) (*ctrl.Result, error) {
+					executed = append(executed, "render")
+					return &ctrl.Result{RequeueAfter: 10}, nil
+				}},
+				{name: "apply", condition: resourcesv1alpha1.ConditionTypeApplied, run: func(context.Context, *deploymentRun) (*ctrl.Result, error) {
+					executed = append(executed, "apply")
+					return nil, nil
+				}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(ctrl.Result{RequeueAfter: 10}))
+			Expect(executed).To(Equal([]string{"render"}))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			Expect(meta.FindStatusCondition(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeRendered)).To(BeNil())
+		})
+
+		It("should report the stage that failed", func() {
+			_, err := reconciler.runPipeline(ctx, &deploymentRun{deployment: deployment}, []deploymentStage{
+				{name: "graph", condition: resourcesv1alpha1.ConditionTypeGraphBuilt, run: func(context.Context, *deploymentRun) (*ctrl.Result, error) {
+					return nil, errors.New("cycle detected")
+				}},
+			})
+			Expect(err).To(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			condition := meta.FindStatusCondition(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeGraphBuilt)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(resourcesv1alpha1.ConditionReasonStageFailed))
+			Expect(condition.Message).To(ContainSubstring("cycle detected"))
+		})
+	})
+})
@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/changeset"
+	"github.com/nubank/klaudio/internal/expression"
+	"github.com/nubank/klaudio/internal/outputs"
+	"github.com/nubank/klaudio/internal/provisioning"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// resourceSpecRenderer renders the spec of the Resource generated to a group element
+type resourceSpecRenderer func(resource *resources.Resource, rawProperties []byte) resourcesv1alpha1.ResourceSpec
+
+// plan publishes the change set of the deployment in status.plan; nothing is created or changed
+func (r *ResourceGroupDeploymentReconciler) plan(ctx context.Context, deployment *resourcesv1alpha1.ResourceGroupDeployment, resourceGroup *resources.ResourceGroup, dag []string, args *resources.ResourcePropertiesArgs, specOf resourceSpecRenderer) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroupDeployment", deployment.Name)
+
+	log.Info("Deployment is in Plan mode; planning resources...")
+
+	changes, err := r.changeSet(ctx, deployment, resourceGroup, dag, args, specOf, true)
+	if err != nil {
+		log.Error(err, "unable to compute the deployment change set")
+		return ctrl.Result{}, err
+	}
+
+	plan := &resourcesv1alpha1.ResourceGroupDeploymentPlan{
+		ObservedGeneration: deployment.Generation,
+		PlannedAt:          metav1.Now(),
+		Resources:          changes,
+	}
+
+	// an unchanged plan keeps its timestamp, so the status isn't rewritten on every reconciliation
+	if current := deployment.Status.Plan; current != nil && current.ObservedGeneration == plan.ObservedGeneration && equality.Semantic.DeepEqual(current.Resources, plan.Resources) {
+		plan.PlannedAt = current.PlannedAt
+	}
+
+	actions := make(map[resourcesv1alpha1.PlanAction]int)
+	for _, plannedResource := range plan.Resources {
+		actions[plannedResource.Action]++
+	}
+
+	deployment.Status.Plan = plan
+	deployment.Status.Phase = resourcesv1alpha1.DeploymentDonePhase
+	_, err = r.newResourceGroupDeploymentCondition(ctx, deployment, &metav1.Condition{
+		Type:   resourcesv1alpha1.ConditionTypePlanned,
+		Status: metav1.ConditionTrue,
+		Reason: resourcesv1alpha1.ConditionReasonPlanReady,
+		Message: fmt.Sprintf("Plan from ResourceGroupDeployment %s: %d to create, %d to update, %d unknown",
+			deployment.Name,
+			actions[resourcesv1alpha1.PlanActionCreate],
+			actions[resourcesv1alpha1.PlanActionUpdate],
+			actions[resourcesv1alpha1.PlanActionUnknown]),
+	})
+	if err != nil {
+		log.Error(err, "Failed to update ResourceGroupDeployment's status")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Plan finished.")
+
+	return ctrl.Result{}, nil
+}
+
+// changeSet renders every Resource the deployment would apply and compares it with the deployed one. Properties
+// depending on outputs of Resources that aren't deployed yet can't be evaluated: a new Resource is still planned to
+// be created, but a deployed one is planned as Unknown.
+func (r *ResourceGroupDeploymentReconciler) changeSet(ctx context.Context, deployment *resourcesv1alpha1.ResourceGroupDeployment, resourceGroup *resources.ResourceGroup, dag []string, args *resources.ResourcePropertiesArgs, specOf resourceSpecRenderer, withPreview bool) ([]resourcesv1alpha1.ResourceGroupDeploymentPlannedResource, error) {
+	changes := make([]resourcesv1alpha1.ResourceGroupDeploymentPlannedResource, 0, len(dag))
+
+	err := resourceGroup.Render(dag, args, func(step *resources.RenderStep) (*resourcesv1alpha1.Resource, error) {
+		resource := step.Resource
+
+		resourceNameToDeploy := fmt.Sprintf("%s.%s", deployment.Name, resource.NameAsKebabCase())
+
+		plannedResource := resourcesv1alpha1.ResourceGroupDeploymentPlannedResource{Name: resourceNameToDeploy}
+
+		deployed := &resourcesv1alpha1.Resource{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: deployment.Namespace, Name: resourceNameToDeploy}, deployed); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			deployed = nil
+		}
+
+		// only outputs that aren't produced yet are known after the apply; any other error is a broken expression
+		var unknown *expression.UnknownError
+		if errors.As(step.Err, &unknown) {
+			plannedResource.Action = resourcesv1alpha1.PlanActionUnknown
+			if deployed == nil {
+				plannedResource.Action = resourcesv1alpha1.PlanActionCreate
+			}
+			plannedResource.Message = fmt.Sprintf("Properties are only known after the apply: %s", unknown.Error())
+			changes = append(changes, plannedResource)
+			return nil, nil
+		}
+		if step.Err != nil {
+			return nil, fmt.Errorf("unable to evaluate properties from resource %s: %w", resource.Name, step.Err)
+		}
+
+		spec := specOf(resource, step.RawProperties)
+		if deployed != nil {
+			spec.ProvisionerObjectName = resourcesv1alpha1.ProvisionerObjectNameOf(deployed, spec.ProvisionerObjectName)
+		}
+
+		// values read from Secret refs are kept out of the spec, so they're never shown in the plan
+		secret := resource.SecretProperties()
+		if _, err := changeset.SeparateSecretProperties(&spec, resourcesv1alpha1.SecretPropertiesNameOf(resourceNameToDeploy), secret); err != nil {
+			return nil, err
+		}
+		plannedResource.Spec = spec.DeepCopy()
+
+		// outputs from the deployed Resource are available to the next ones
+		var resolved *resourcesv1alpha1.Resource
+		if deployed == nil {
+			plannedResource.Action = resourcesv1alpha1.PlanActionCreate
+		} else {
+			diff, err := changeset.SpecDiff(&deployed.Spec, &spec, secret...)
+			if err != nil {
+				return nil, err
+			}
+
+			plannedResource.Action = resourcesv1alpha1.PlanActionNoChange
+			if len(diff) != 0 {
+				plannedResource.Action = resourcesv1alpha1.PlanActionUpdate
+				plannedResource.Diff = diff
+			}
+
+			resolved, err = outputs.Resolve(ctx, r.Client, deployed)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if withPreview && plannedResource.Action != resourcesv1alpha1.PlanActionNoChange {
+			preview, err := r.preview(ctx, resource.Ref, deployment, resourceNameToDeploy, plannedResource.Spec, deployed)
+			if err != nil {
+				plannedResource.Message = fmt.Sprintf("Unable to preview the provisioner object: %s", err.Error())
+			}
+			plannedResource.Provisioner = preview
+		}
+
+		changes = append(changes, plannedResource)
+
+		return resolved, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Resources removed from the resource group are pruned
+	stale, err := r.staleResources(ctx, deployment, resourceGroup, dag)
+	if err != nil {
+		return nil, err
+	}
+	for _, resource := range stale {
+		changes = append(changes, resourcesv1alpha1.ResourceGroupDeploymentPlannedResource{
+			Name:    resource.Name,
+			Action:  resourcesv1alpha1.PlanActionDelete,
+			Message: fmt.Sprintf("Removed from the resource group; deletion policy: %s", resource.Spec.DeletionPolicy),
+		})
+	}
+
+	return changes, nil
+}
+
+// preview renders the object the provisioner would apply to a planned Resource; nil when the provisioner doesn't
+// support previews
+func (r *ResourceGroupDeploymentReconciler) preview(ctx context.Context, resourceRef *resourcesv1alpha1.ResourceRef, deployment *resourcesv1alpha1.ResourceGroupDeployment, name string, spec *resourcesv1alpha1.ResourceSpec, deployed *resourcesv1alpha1.Resource) (*runtime.RawExtension, error) {
+	provisionerFactory, err := provisioning.SelectByName(string(resourceRef.Spec.Provisioner.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	provisioner, err := provisionerFactory(r.Client, nil, r.Scheme, log.FromContext(ctx), &resourceRef.Spec.Provisioner)
+	if err != nil {
+		return nil, err
+	}
+
+	previewer, ok := provisioner.(provisioning.Previewer)
+	if !ok {
+		return nil, nil
+	}
+
+	candidate := &resourcesv1alpha1.Resource{
+		ObjectMeta: metav1.ObjectMeta{Namespace: deployment.Namespace, Name: name},
+	}
+	if deployed != nil {
+		candidate = deployed.DeepCopy()
+	}
+	candidate.Spec = *spec
+
+	obj, err := previewer.Preview(ctx, candidate)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return &runtime.RawExtension{Raw: raw}, nil
+}
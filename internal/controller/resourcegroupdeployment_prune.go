@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// staleResources lists the Resources owned by the deployment that aren't in the resource group anymore, sorted by name
+func (r *ResourceGroupDeploymentReconciler) staleResources(ctx context.Context, deployment *resourcesv1alpha1.ResourceGroupDeployment, resourceGroup *resources.ResourceGroup, dag []string) ([]*resourcesv1alpha1.Resource, error) {
+	desired := make([]string, 0, len(dag))
+	for _, resourceName := range dag {
+		resource, err := resourceGroup.Get(resourceName)
+		if err != nil {
+			return nil, err
+		}
+		desired = append(desired, fmt.Sprintf("%s.%s", deployment.Name, resource.NameAsKebabCase()))
+	}
+
+	deployed := &resourcesv1alpha1.ResourceList{}
+	if err := r.List(ctx, deployed, client.InNamespace(deployment.Namespace), client.MatchingLabels{resourcesv1alpha1.Group + "/managedBy.name": deployment.Name}); err != nil {
+		return nil, err
+	}
+
+	stale := make([]*resourcesv1alpha1.Resource, 0)
+	for i := range deployed.Items {
+		resource := &deployed.Items[i]
+		if metav1.IsControlledBy(resource, deployment) && !slices.Contains(desired, resource.Name) {
+			stale = append(stale, resource)
+		}
+	}
+
+	slices.SortFunc(stale, func(a, b *resourcesv1alpha1.Resource) int { return strings.Compare(a.Name, b.Name) })
+
+	return stale, nil
+}
+
+// pruneStage deletes the Resources removed from the resource group. Nothing left in the group can depend on them, so
+// they are deleted at once, without waiting for the rollout; the Resource controller honors their deletion policy.
+func (r *ResourceGroupDeploymentReconciler) pruneStage(ctx context.Context, run *deploymentRun) (*ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroupDeployment", run.deployment.Name)
+
+	stale, err := r.staleResources(ctx, run.deployment, run.resourceGroup, run.dag)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list stale Resources: %w", err)
+	}
+
+	for _, resource := range stale {
+		if !resource.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		log.Info(fmt.Sprintf("Resource %s was removed from the resource group; pruning it (deletion policy: %s)...", resource.Name, resource.Spec.DeletionPolicy))
+
+		if err := r.Delete(ctx, resource); client.IgnoreNotFound(err) != nil {
+			return nil, fmt.Errorf("unable to prune Resource %s: %w", resource.Name, err)
+		}
+
+		if r.Recorder != nil {
+			r.Recorder.Event(run.deployment, corev1.EventTypeNormal, resourcesv1alpha1.ConditionReasonResourcePruned, fmt.Sprintf("Resource %s was removed from the resource group and pruned", resource.Name))
+		}
+	}
+
+	return nil, nil
+}
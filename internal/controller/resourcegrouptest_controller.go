@@ -0,0 +1,151 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/rendertest"
+)
+
+// ResourceGroupTestReconciler runs the cases of ResourceGroupTests against their ResourceGroups
+type ResourceGroupTestReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcegrouptests,verbs=get;list;watch
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcegrouptests/status,verbs=get;update;patch
+
+// Reconcile renders the ResourceGroup with the fixtures of each test case, reporting which cases passed. Nothing is
+// deployed; cases run again every time the test or the ResourceGroup changes.
+func (r *ResourceGroupTestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("resourceGroupTest", req.Name)
+
+	resourceGroupTest := &resourcesv1alpha1.ResourceGroupTest{}
+	if err := r.Get(ctx, req.NamespacedName, resourceGroupTest); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	status := resourcesv1alpha1.ResourceGroupTestStatus{
+		ObservedGeneration: resourceGroupTest.Generation,
+		Conditions:         resourceGroupTest.Status.Conditions,
+	}
+
+	condition := metav1.Condition{
+		ObservedGeneration: resourceGroupTest.Generation,
+	}
+
+	resourceGroup := &resourcesv1alpha1.ResourceGroup{}
+	if err := r.Get(ctx, types.NamespacedName{Name: resourceGroupTest.Spec.ResourceGroup}, resourceGroup); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "unable to fetch ResourceGroup", "resourceGroup", resourceGroupTest.Spec.ResourceGroup)
+			return ctrl.Result{}, err
+		}
+
+		status.Phase = resourcesv1alpha1.ResourceGroupTestFailedPhase
+		condition.Type = resourcesv1alpha1.ConditionTypeFailed
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = resourcesv1alpha1.ConditionReasonDependencyNotReady
+		condition.Message = fmt.Sprintf("ResourceGroup %s not found", resourceGroupTest.Spec.ResourceGroup)
+	} else {
+		status.Results = rendertest.Run(&resourceGroup.Spec, resourceGroupTest.Spec.Cases)
+
+		failed := 0
+		for _, result := range status.Results {
+			if !result.Passed {
+				failed++
+			}
+		}
+
+		if failed == 0 {
+			status.Phase = resourcesv1alpha1.ResourceGroupTestPassedPhase
+			condition.Type = resourcesv1alpha1.ConditionTypeReady
+			condition.Status = metav1.ConditionTrue
+			condition.Reason = resourcesv1alpha1.ConditionReasonTestsPassed
+			condition.Message = fmt.Sprintf("All %d cases passed against ResourceGroup %s", len(status.Results), resourceGroup.Name)
+		} else {
+			status.Phase = resourcesv1alpha1.ResourceGroupTestFailedPhase
+			condition.Type = resourcesv1alpha1.ConditionTypeFailed
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = resourcesv1alpha1.ConditionReasonInputError
+			condition.Message = fmt.Sprintf("%d of %d cases failed against ResourceGroup %s", failed, len(status.Results), resourceGroup.Name)
+		}
+	}
+
+	// only one outcome is kept at a time
+	status.Conditions = append([]metav1.Condition{}, status.Conditions...)
+	for _, conditionType := range []string{resourcesv1alpha1.ConditionTypeReady, resourcesv1alpha1.ConditionTypeFailed} {
+		if conditionType != condition.Type {
+			meta.RemoveStatusCondition(&status.Conditions, conditionType)
+		}
+	}
+	setStatusCondition(&status.Conditions, condition)
+
+	if equality.Semantic.DeepEqual(status, resourceGroupTest.Status) {
+		return ctrl.Result{}, nil
+	}
+
+	log.Info(fmt.Sprintf("ResourceGroupTest %s: %s", resourceGroupTest.Name, condition.Message))
+
+	resourceGroupTest.Status = status
+	if err := r.Status().Update(ctx, resourceGroupTest); err != nil {
+		log.Error(err, "unable to update ResourceGroupTest's status")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// testsOfResourceGroup runs the tests of a ResourceGroup again when it changes
+func (r *ResourceGroupTestReconciler) testsOfResourceGroup(ctx context.Context, obj client.Object) []reconcile.Request {
+	all := &resourcesv1alpha1.ResourceGroupTestList{}
+	if err := r.List(ctx, all); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ResourceGroupTests")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for _, resourceGroupTest := range all.Items {
+		if resourceGroupTest.Spec.ResourceGroup == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: resourceGroupTest.Name}})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ResourceGroupTestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&resourcesv1alpha1.ResourceGroupTest{}).
+		Watches(&resourcesv1alpha1.ResourceGroup{}, handler.EnqueueRequestsFromMapFunc(r.testsOfResourceGroup)).
+		Complete(r)
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("ResourceGroupTest Controller", func() {
+	Context("When running test cases", func() {
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{Name: "test-resource-group-test"}
+
+		resourceGroup := &resourcesv1alpha1.ResourceGroup{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-tested-resource-group"},
+			Spec: resourcesv1alpha1.ResourceGroupSpec{
+				Resources: []resourcesv1alpha1.ResourceGroupElement{
+					{Name: "database", ResourceRef: "rds", Properties: &runtime.RawExtension{Raw: []byte(`{"name":"checkout"}`)}},
+					{Name: "app", ResourceRef: "service", Properties: &runtime.RawExtension{Raw: []byte(`{"database":"${resources.database.status.outputs.endpoint}"}`)}},
+				},
+			},
+		}
+
+		newResourceGroupTest := func(expected string) *resourcesv1alpha1.ResourceGroupTest {
+			return &resourcesv1alpha1.ResourceGroupTest{
+				ObjectMeta: metav1.ObjectMeta{Name: typeNamespacedName.Name},
+				Spec: resourcesv1alpha1.ResourceGroupTestSpec{
+					ResourceGroup: resourceGroup.Name,
+					Cases: []resourcesv1alpha1.ResourceGroupTestCase{
+						{
+							Name:     "endpoint",
+							Outputs:  &runtime.RawExtension{Raw: []byte(`{"database":{"endpoint":"checkout.rds"}}`)},
+							Expected: &runtime.RawExtension{Raw: []byte(expected)},
+						},
+					},
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, resourceGroup.DeepCopy())).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, &resourcesv1alpha1.ResourceGroupTest{ObjectMeta: metav1.ObjectMeta{Name: typeNamespacedName.Name}})).To(Succeed())
+			Expect(k8sClient.Delete(ctx, &resourcesv1alpha1.ResourceGroup{ObjectMeta: metav1.ObjectMeta{Name: resourceGroup.Name}})).To(Succeed())
+		})
+
+		reconcileTest := func() *resourcesv1alpha1.ResourceGroupTest {
+			controllerReconciler := &ResourceGroupTestReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+
+			_, err := controllerReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			resourceGroupTest := &resourcesv1alpha1.ResourceGroupTest{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resourceGroupTest)).To(Succeed())
+			return resourceGroupTest
+		}
+
+		It("should report the passed cases", func() {
+			Expect(k8sClient.Create(ctx, newResourceGroupTest(`{"app":{"database":"checkout.rds"}}`))).To(Succeed())
+
+			resourceGroupTest := reconcileTest()
+			Expect(resourceGroupTest.Status.Phase).To(Equal(resourcesv1alpha1.ResourceGroupTestPassedPhase))
+			Expect(resourceGroupTest.Status.Results).To(HaveLen(1))
+			Expect(resourceGroupTest.Status.Results[0].Passed).To(BeTrue())
+		})
+
+		It("should report the failed cases", func() {
+			Expect(k8sClient.Create(ctx, newResourceGroupTest(`{"app":{"database":"checkout-prod.rds"}}`))).To(Succeed())
+
+			resourceGroupTest := reconcileTest()
+			Expect(resourceGroupTest.Status.Phase).To(Equal(resourcesv1alpha1.ResourceGroupTestFailedPhase))
+			Expect(resourceGroupTest.Status.Results[0].Failures).To(ConsistOf(`app.database: expected "checkout-prod.rds", got "checkout.rds"`))
+		})
+	})
+})
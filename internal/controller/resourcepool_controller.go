@@ -0,0 +1,427 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+const (
+	// poolClaimTimeout is how long a claimed standby Resource waits for the Resource claiming it to be created, before
+	// it's returned to the pool
+	poolClaimTimeout = 10 * time.Minute
+
+	// poolClaimRequeue is how often a pool with pending claims is reconciled again
+	poolClaimRequeue = 10 * time.Second
+)
+
+// ResourcePoolReconciler keeps the standby Resources of ResourcePools provisioned, and releases the ones claimed by
+// deployments
+type ResourcePoolReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcepools,verbs=get;list;watch
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcepools/status,verbs=get;update;patch
+
+// Reconcile tops the pool up to its size. A claimed standby Resource is deleted once the Resource claiming it exists;
+// it was orphaned when claimed, and the provisioner object is handed over to its new owner before.
+func (r *ResourcePoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("resourcePool", req.NamespacedName)
+
+	pool := &resourcesv1alpha1.ResourcePool{}
+	if err := r.Get(ctx, req.NamespacedName, pool); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// standby Resources are owned by the pool, so they're collected along with it
+	if !pool.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	standbys := &resourcesv1alpha1.ResourceList{}
+	if err := r.List(ctx, standbys, client.InNamespace(pool.Namespace), client.MatchingLabels{resourcesv1alpha1.ResourcePoolLabel: pool.Name}); err != nil {
+		log.Error(err, "unable to list standby Resources")
+		return ctrl.Result{}, err
+	}
+
+	result := ctrl.Result{}
+	unclaimed := make([]*resourcesv1alpha1.Resource, 0)
+
+	for i := range standbys.Items {
+		standby := &standbys.Items[i]
+		if !standby.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		claimant, claimed := standby.Annotations[resourcesv1alpha1.ResourcePoolClaimedByAnnotation]
+		if !claimed {
+			unclaimed = append(unclaimed, standby)
+			continue
+		}
+
+		pending, err := r.releaseClaim(ctx, standby, claimant)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("unable to release standby Resource %s claimed by %s", standby.Name, claimant))
+			return ctrl.Result{}, err
+		}
+		if pending {
+			result.RequeueAfter = poolClaimRequeue
+		}
+
+		// the claim expired; the standby Resource is back in the pool
+		if _, claimed := standby.Annotations[resourcesv1alpha1.ResourcePoolClaimedByAnnotation]; !claimed {
+			unclaimed = append(unclaimed, standby)
+		}
+	}
+
+	// the oldest are kept, since they're the likeliest to be provisioned
+	slices.SortFunc(unclaimed, func(a, b *resourcesv1alpha1.Resource) int {
+		if c := a.CreationTimestamp.Compare(b.CreationTimestamp.Time); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	size := int(pool.Spec.Size)
+	for len(unclaimed) > size {
+		excess := unclaimed[len(unclaimed)-1]
+		unclaimed = unclaimed[:len(unclaimed)-1]
+
+		log.Info(fmt.Sprintf("pool is above its size; deleting standby Resource %s", excess.Name))
+		if err := r.Delete(ctx, excess); client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	for range size - len(unclaimed) {
+		standby, err := r.newStandby(ctx, pool)
+		if err != nil {
+			log.Error(err, "unable to create standby Resource")
+			return ctrl.Result{}, err
+		}
+		log.Info(fmt.Sprintf("standby Resource %s created", standby.Name))
+		unclaimed = append(unclaimed, standby)
+	}
+
+	available := int32(0)
+	for _, standby := range unclaimed {
+		if standby.Status.Phase == resourcesv1alpha1.DeploymentDonePhase {
+			available++
+		}
+	}
+
+	claimants := &resourcesv1alpha1.ResourceList{}
+	if err := r.List(ctx, claimants, client.InNamespace(pool.Namespace), client.MatchingLabels{resourcesv1alpha1.ResourcePoolClaimedFromLabel: pool.Name}); err != nil {
+		log.Error(err, "unable to list the Resources claimed from the pool")
+		return ctrl.Result{}, err
+	}
+	claimed := int32(0)
+	for _, claimant := range claimants.Items {
+		if claimant.DeletionTimestamp.IsZero() {
+			claimed++
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               resourcesv1alpha1.ConditionTypeReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             resourcesv1alpha1.ConditionReasonPoolFilled,
+		Message:            fmt.Sprintf("%d of %d standby Resources are available", available, size),
+		ObservedGeneration: pool.Generation,
+	}
+	if int(available) < size {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = resourcesv1alpha1.ConditionReasonPoolFilling
+	}
+
+	changed := setStatusCondition(&pool.Status.Conditions, condition)
+	if pool.Status.Available != available || pool.Status.Provisioning != int32(len(unclaimed))-available || pool.Status.Claimed != claimed {
+		pool.Status.Available = available
+		pool.Status.Provisioning = int32(len(unclaimed)) - available
+		pool.Status.Claimed = claimed
+		changed = true
+	}
+
+	if changed {
+		if err := r.Status().Update(ctx, pool); err != nil {
+			log.Error(err, "unable to update ResourcePool's status")
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
+	return result, nil
+}
+
+// releaseClaim deletes a claimed standby Resource once the Resource claiming it exists, handing the provisioner object
+// over to it; a claim that isn't followed by the Resource in time expires, returning the standby Resource to the pool.
+// The claim is returned with an update of the standby Resource as it was listed, so it fails when the claimant adopted
+// it meanwhile. It returns true while the claim is pending.
+func (r *ResourcePoolReconciler) releaseClaim(ctx context.Context, standby *resourcesv1alpha1.Resource, claimant string) (bool, error) {
+	resource := &resourcesv1alpha1.Resource{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: standby.Namespace, Name: claimant}, resource)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, err
+	}
+
+	// a Resource with the same name that didn't take the provisioner object over is not the claimant
+	if err == nil && resource.Annotations[resourcesv1alpha1.ResourcePoolClaimedFromAnnotation] == standby.Name {
+		if err := transferProvisionerObject(ctx, r.Client, standby, resource); err != nil {
+			return false, err
+		}
+		return false, client.IgnoreNotFound(r.Delete(ctx, standby))
+	}
+
+	// the claimant took the provisioner object over and was deleted since; the object went with it
+	if _, adopted := standby.Annotations[resourcesv1alpha1.ResourcePoolAdoptedAnnotation]; adopted {
+		return false, client.IgnoreNotFound(r.Delete(ctx, standby))
+	}
+
+	claimedAt, err := time.Parse(time.RFC3339, standby.Annotations[resourcesv1alpha1.ResourcePoolClaimedAtAnnotation])
+	if err == nil && time.Since(claimedAt) < poolClaimTimeout {
+		return true, nil
+	}
+
+	returned := standby.DeepCopy()
+	delete(returned.Annotations, resourcesv1alpha1.ResourcePoolClaimedByAnnotation)
+	delete(returned.Annotations, resourcesv1alpha1.ResourcePoolClaimedAtAnnotation)
+	returned.Spec.Suspend = false
+	returned.Spec.DeletionPolicy = ""
+
+	if err := r.Update(ctx, returned); err != nil {
+		// the claimant adopted it meanwhile; it's released in the next reconciliation
+		if apierrors.IsConflict(err) {
+			return true, nil
+		}
+		return false, client.IgnoreNotFound(err)
+	}
+	*standby = *returned
+	return false, nil
+}
+
+func (r *ResourcePoolReconciler) newStandby(ctx context.Context, pool *resourcesv1alpha1.ResourcePool) (*resourcesv1alpha1.Resource, error) {
+	properties := pool.Spec.Properties
+	if properties == nil {
+		properties = &runtime.RawExtension{Raw: []byte("{}")}
+	}
+
+	standby := &resourcesv1alpha1.Resource{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: pool.Name + "-",
+			Namespace:    pool.Namespace,
+			Labels: map[string]string{
+				resourcesv1alpha1.ResourcePoolLabel:    pool.Name,
+				resourcesv1alpha1.Group + "/placement": pool.Spec.Placement,
+			},
+		},
+		Spec: resourcesv1alpha1.ResourceSpec{
+			Placement:   pool.Spec.Placement,
+			ResourceRef: pool.Spec.ResourceRef,
+			Properties:  properties.DeepCopy(),
+		},
+	}
+	if err := ctrl.SetControllerReference(pool, standby, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	if err := r.Create(ctx, standby); err != nil {
+		return nil, err
+	}
+	return standby, nil
+}
+
+// claimFromPool claims a provisioned standby Resource to the Resource about to be created, when a pool in its namespace
+// keeps Resources of the same ResourceRef and placement. The standby Resource is suspended, so it stops changing the
+// provisioner object, and orphans it when deleted. A claim left behind by a previous attempt to create the same
+// Resource is taken again, renewing its time, so it doesn't expire while the Resource is created. It returns nil when
+// there is nothing to claim; once the Resource is created, adoptStandby must confirm the claim.
+func claimFromPool(ctx context.Context, c client.Client, resource *resourcesv1alpha1.Resource) (*resourcesv1alpha1.Resource, error) {
+	standbys := &resourcesv1alpha1.ResourceList{}
+	if err := c.List(ctx, standbys, client.InNamespace(resource.Namespace), client.HasLabels{resourcesv1alpha1.ResourcePoolLabel}); err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*resourcesv1alpha1.Resource, 0)
+	for i := range standbys.Items {
+		standby := &standbys.Items[i]
+		if !standby.DeletionTimestamp.IsZero() || standby.Spec.ResourceRef != resource.Spec.ResourceRef || standby.Spec.Placement != resource.Spec.Placement {
+			continue
+		}
+
+		claimant, claimed := standby.Annotations[resourcesv1alpha1.ResourcePoolClaimedByAnnotation]
+		if claimed && claimant == resource.Name {
+			candidates = []*resourcesv1alpha1.Resource{standby}
+			break
+		}
+		if !claimed && standby.Status.Phase == resourcesv1alpha1.DeploymentDonePhase {
+			candidates = append(candidates, standby)
+		}
+	}
+
+	slices.SortFunc(candidates, func(a, b *resourcesv1alpha1.Resource) int {
+		return a.CreationTimestamp.Compare(b.CreationTimestamp.Time)
+	})
+
+	for _, standby := range candidates {
+		if standby.Annotations == nil {
+			standby.Annotations = make(map[string]string)
+		}
+		standby.Annotations[resourcesv1alpha1.ResourcePoolClaimedByAnnotation] = resource.Name
+		standby.Annotations[resourcesv1alpha1.ResourcePoolClaimedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		standby.Spec.Suspend = true
+		standby.Spec.DeletionPolicy = resourcesv1alpha1.DeletionPolicyOrphan
+
+		// another deployment claimed it first, or the pool returned an expired claim; try the next one
+		if err := c.Update(ctx, standby); err != nil {
+			if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		return standby, nil
+	}
+
+	return nil, nil
+}
+
+// adoptStandby confirms the claim of the standby Resource once the Resource claiming it was created, and hands the
+// provisioner object over to it. The claim is confirmed with an update of the standby Resource, so the pool can't
+// return it meanwhile; a claim the pool returned before is taken again, unless another Resource claimed it since.
+func adoptStandby(ctx context.Context, c client.Client, standbyName string, claimant *resourcesv1alpha1.Resource) error {
+	standby := &resourcesv1alpha1.Resource{}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.Get(ctx, types.NamespacedName{Namespace: claimant.Namespace, Name: standbyName}, standby); err != nil {
+			return err
+		}
+
+		if by, claimed := standby.Annotations[resourcesv1alpha1.ResourcePoolClaimedByAnnotation]; claimed && by != claimant.Name {
+			return fmt.Errorf("standby Resource %s was claimed by Resource %s meanwhile", standbyName, by)
+		}
+		if _, adopted := standby.Annotations[resourcesv1alpha1.ResourcePoolAdoptedAnnotation]; adopted {
+			return nil
+		}
+
+		if standby.Annotations == nil {
+			standby.Annotations = make(map[string]string)
+		}
+		standby.Annotations[resourcesv1alpha1.ResourcePoolClaimedByAnnotation] = claimant.Name
+		standby.Annotations[resourcesv1alpha1.ResourcePoolAdoptedAnnotation] = "true"
+		standby.Spec.Suspend = true
+		standby.Spec.DeletionPolicy = resourcesv1alpha1.DeletionPolicyOrphan
+
+		return c.Update(ctx, standby)
+	})
+	if err != nil {
+		return err
+	}
+
+	return transferProvisionerObject(ctx, c, standby, claimant)
+}
+
+// transferProvisionerObject makes the claimant the controller of the provisioner object of the standby Resource, and
+// points the labels naming the standby Resource to it, so the changes of the object are watched by the claimant and
+// the object is collected along with it
+func transferProvisionerObject(ctx context.Context, c client.Client, standby *resourcesv1alpha1.Resource, claimant *resourcesv1alpha1.Resource) error {
+	provisioned := standby.Status.Provisioner.Resource
+	if provisioned.Kind == "" {
+		return nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(schema.GroupVersionKind{Group: provisioned.Group, Version: provisioned.Version, Kind: provisioned.Kind})
+
+	return client.IgnoreNotFound(retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.Get(ctx, types.NamespacedName{Namespace: standby.Namespace, Name: provisioned.Name}, obj); err != nil {
+			return err
+		}
+
+		changed := false
+
+		owners := make([]metav1.OwnerReference, 0)
+		owned := false
+		for _, owner := range obj.GetOwnerReferences() {
+			if owner.UID == standby.UID {
+				changed = true
+				continue
+			}
+			owned = owned || owner.UID == claimant.UID
+			owners = append(owners, owner)
+		}
+		if !owned {
+			owners = append(owners, metav1.OwnerReference{
+				APIVersion:         resourcesv1alpha1.GroupVersion.String(),
+				Kind:               "Resource",
+				Name:               claimant.Name,
+				UID:                claimant.UID,
+				BlockOwnerDeletion: ptr.To(true),
+				Controller:         ptr.To(true),
+			})
+			changed = true
+		}
+
+		labels := obj.GetLabels()
+		for _, key := range []string{"name", resourcesv1alpha1.Group + "/managedBy.name"} {
+			if labels[key] == standby.Name {
+				labels[key] = claimant.Name
+				changed = true
+			}
+		}
+
+		if !changed {
+			return nil
+		}
+		obj.SetOwnerReferences(owners)
+		obj.SetLabels(labels)
+		return c.Update(ctx, obj)
+	}))
+}
+
+// standbyObjectNameOf is the name of the provisioner object of a standby Resource
+func standbyObjectNameOf(standby *resourcesv1alpha1.Resource) string {
+	if standby.Spec.ProvisionerObjectName != "" {
+		return standby.Spec.ProvisionerObjectName
+	}
+	return standby.Name
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ResourcePoolReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&resourcesv1alpha1.ResourcePool{}).
+		Owns(&resourcesv1alpha1.Resource{}).
+		Complete(r)
+}
@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("ResourcePool Controller", func() {
+	Context("When a ResourcePool keeps standby Resources", func() {
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{Name: "kafka-topics", Namespace: "default"}
+
+		BeforeEach(func() {
+			pool := &resourcesv1alpha1.ResourcePool{
+				ObjectMeta: metav1.ObjectMeta{Name: typeNamespacedName.Name, Namespace: typeNamespacedName.Namespace},
+				Spec: resourcesv1alpha1.ResourcePoolSpec{
+					ResourceRef: "kafka-topic",
+					Placement:   "sandbox",
+					Size:        2,
+				},
+			}
+			Expect(k8sClient.Create(ctx, pool)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			pool := &resourcesv1alpha1.ResourcePool{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, pool)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, pool)).To(Succeed())
+
+			Expect(k8sClient.DeleteAllOf(ctx, &resourcesv1alpha1.Resource{}, client.InNamespace(typeNamespacedName.Namespace), client.HasLabels{resourcesv1alpha1.ResourcePoolLabel})).To(Succeed())
+		})
+
+		It("should provision standby Resources up to the size, to be claimed by new Resources", func() {
+			controllerReconciler := &ResourcePoolReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			standbys := &resourcesv1alpha1.ResourceList{}
+			Expect(k8sClient.List(ctx, standbys, client.InNamespace(typeNamespacedName.Namespace), client.MatchingLabels{resourcesv1alpha1.ResourcePoolLabel: typeNamespacedName.Name})).To(Succeed())
+			Expect(standbys.Items).To(HaveLen(2))
+			Expect(standbys.Items[0].Spec.ResourceRef).To(Equal("kafka-topic"))
+			Expect(standbys.Items[0].Spec.Placement).To(Equal("sandbox"))
+
+			resource := &resourcesv1alpha1.Resource{
+				ObjectMeta: metav1.ObjectMeta{Name: "orders-topic", Namespace: typeNamespacedName.Namespace},
+				Spec:       resourcesv1alpha1.ResourceSpec{ResourceRef: "kafka-topic", Placement: "sandbox"},
+			}
+
+			By("There is nothing to claim while standby Resources are provisioned")
+			standby, err := claimFromPool(ctx, k8sClient, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(standby).To(BeNil())
+
+			provisioned := &standbys.Items[0]
+			provisioned.Status.Phase = resourcesv1alpha1.DeploymentDonePhase
+			Expect(k8sClient.Status().Update(ctx, provisioned)).To(Succeed())
+
+			By("A provisioned standby Resource is claimed, suspended and orphans its provisioner object")
+			standby, err = claimFromPool(ctx, k8sClient, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(standby).NotTo(BeNil())
+			Expect(standby.Name).To(Equal(provisioned.Name))
+			Expect(standby.Annotations).To(HaveKeyWithValue(resourcesv1alpha1.ResourcePoolClaimedByAnnotation, "orders-topic"))
+			Expect(standby.Spec.Suspend).To(BeTrue())
+			Expect(standby.Spec.DeletionPolicy).To(Equal(resourcesv1alpha1.DeletionPolicyOrphan))
+			Expect(standbyObjectNameOf(standby)).To(Equal(provisioned.Name))
+
+			By("The same Resource takes its claim again")
+			again, err := claimFromPool(ctx, k8sClient, resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(again.Name).To(Equal(standby.Name))
+
+			By("The pool is topped up once the claim is pending")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.List(ctx, standbys, client.InNamespace(typeNamespacedName.Namespace), client.MatchingLabels{resourcesv1alpha1.ResourcePoolLabel: typeNamespacedName.Name})).To(Succeed())
+			Expect(standbys.Items).To(HaveLen(3))
+
+			By("The claimed standby Resource is adopted and released once the Resource claiming it exists")
+			resource.Labels = map[string]string{resourcesv1alpha1.ResourcePoolClaimedFromLabel: typeNamespacedName.Name}
+			resource.Annotations = map[string]string{resourcesv1alpha1.ResourcePoolClaimedFromAnnotation: standby.Name}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, resource))).To(Succeed())
+			})
+
+			Expect(adoptStandby(ctx, k8sClient, standby.Name, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.List(ctx, standbys, client.InNamespace(typeNamespacedName.Namespace), client.MatchingLabels{resourcesv1alpha1.ResourcePoolLabel: typeNamespacedName.Name})).To(Succeed())
+			Expect(standbys.Items).To(HaveLen(2))
+
+			pool := &resourcesv1alpha1.ResourcePool{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, pool)).To(Succeed())
+			Expect(pool.Status.Claimed).To(Equal(int32(1)))
+		})
+	})
+})
+
+func Test_AdoptStandby(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+
+	terraformGvk := schema.GroupVersionKind{Group: "infra.contrib.fluxcd.io", Version: "v1alpha2", Kind: "Terraform"}
+
+	newStandby := func(annotations map[string]string) *resourcesv1alpha1.Resource {
+		return &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "checkout",
+				Name:        "kafka-topics-x7k2p",
+				UID:         "standby-uid",
+				Labels:      map[string]string{resourcesv1alpha1.ResourcePoolLabel: "kafka-topics"},
+				Annotations: annotations,
+			},
+			Spec: resourcesv1alpha1.ResourceSpec{ResourceRef: "kafka-topic", Placement: "sandbox", Suspend: true},
+			Status: resourcesv1alpha1.ResourceStatus{
+				Provisioner: resourcesv1alpha1.ResourceStatusProvisioner{
+					Resource: resourcesv1alpha1.ResourceStatusProvisionerResource{
+						Group:   terraformGvk.Group,
+						Version: terraformGvk.Version,
+						Kind:    terraformGvk.Kind,
+						Name:    "kafka-topics-x7k2p",
+					},
+				},
+			},
+		}
+	}
+
+	newTerraform := func() *unstructured.Unstructured {
+		terraform := &unstructured.Unstructured{}
+		terraform.SetGroupVersionKind(terraformGvk)
+		terraform.SetNamespace("checkout")
+		terraform.SetName("kafka-topics-x7k2p")
+		terraform.SetLabels(map[string]string{
+			"name": "kafka-topics-x7k2p",
+			resourcesv1alpha1.Group + "/managedBy.kind": "Resource",
+			resourcesv1alpha1.Group + "/managedBy.name": "kafka-topics-x7k2p",
+		})
+		terraform.SetOwnerReferences([]metav1.OwnerReference{
+			{APIVersion: resourcesv1alpha1.GroupVersion.String(), Kind: "Resource", Name: "kafka-topics-x7k2p", UID: "standby-uid", Controller: ptr.To(true)},
+		})
+		return terraform
+	}
+
+	claimant := &resourcesv1alpha1.Resource{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "checkout", Name: "orders-topic", UID: "claimant-uid"},
+	}
+
+	t.Run("We should hand the provisioner object over to the claimant", func(t *testing.T) {
+		standby := newStandby(map[string]string{resourcesv1alpha1.ResourcePoolClaimedByAnnotation: "orders-topic"})
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(standby, newTerraform()).Build()
+
+		assert.NoError(t, adoptStandby(context.Background(), c, standby.Name, claimant))
+
+		adopted := &resourcesv1alpha1.Resource{}
+		assert.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(standby), adopted))
+		assert.Contains(t, adopted.Annotations, resourcesv1alpha1.ResourcePoolAdoptedAnnotation)
+
+		terraform := &unstructured.Unstructured{}
+		terraform.SetGroupVersionKind(terraformGvk)
+		assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "checkout", Name: "kafka-topics-x7k2p"}, terraform))
+
+		owner := metav1.GetControllerOf(terraform)
+		if assert.NotNil(t, owner) {
+			assert.Equal(t, "orders-topic", owner.Name)
+			assert.Equal(t, claimant.UID, owner.UID)
+		}
+		assert.Len(t, terraform.GetOwnerReferences(), 1)
+		assert.Equal(t, "orders-topic", terraform.GetLabels()["name"])
+		assert.Equal(t, "orders-topic", terraform.GetLabels()[resourcesv1alpha1.Group+"/managedBy.name"])
+	})
+
+	t.Run("We should take a claim returned to the pool again", func(t *testing.T) {
+		standby := newStandby(nil)
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(standby, newTerraform()).Build()
+
+		assert.NoError(t, adoptStandby(context.Background(), c, standby.Name, claimant))
+
+		adopted := &resourcesv1alpha1.Resource{}
+		assert.NoError(t, c.Get(context.Background(), client.ObjectKeyFromObject(standby), adopted))
+		assert.Equal(t, "orders-topic", adopted.Annotations[resourcesv1alpha1.ResourcePoolClaimedByAnnotation])
+		assert.Equal(t, resourcesv1alpha1.DeletionPolicyOrphan, adopted.Spec.DeletionPolicy)
+	})
+
+	t.Run("We should refuse to adopt a standby Resource claimed by another Resource", func(t *testing.T) {
+		standby := newStandby(map[string]string{resourcesv1alpha1.ResourcePoolClaimedByAnnotation: "payments-topic"})
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(standby, newTerraform()).Build()
+
+		assert.ErrorContains(t, adoptStandby(context.Background(), c, standby.Name, claimant), "claimed by Resource payments-topic")
+
+		terraform := &unstructured.Unstructured{}
+		terraform.SetGroupVersionKind(terraformGvk)
+		assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "checkout", Name: "kafka-topics-x7k2p"}, terraform))
+		assert.Equal(t, "kafka-topics-x7k2p", metav1.GetControllerOf(terraform).Name)
+	})
+}
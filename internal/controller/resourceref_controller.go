@@ -19,11 +19,16 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -40,6 +45,7 @@ type ResourceRefReconciler struct {
 // +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcerefs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcerefs/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=resourcerefs/finalizers,verbs=update
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=placements,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -53,8 +59,25 @@ type ResourceRefReconciler struct {
 func (r *ResourceRefReconciler) Reconcile(ctx context.Context, resourceRef *resourcesv1alpha1.ResourceRef) (ctrl.Result, error) {
 	log := log.FromContext(ctx).WithValues("resourceRef", resourceRef.Name)
 
+	if missing := resourcesv1alpha1.UnsupportedCapabilities(resourceRef.Spec.Requires); len(missing) > 0 {
+		log.Info(fmt.Sprintf("ResourceRef requires unsupported capabilities %v", missing))
+
+		resourceRef.Status.Status = resourcesv1alpha1.ResourceRefStatusUnsupported
+		if err := r.Status().Update(ctx, resourceRef); err != nil {
+			log.Error(err, "unable to update ResourceRef's status")
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	placements, err := selectPlacements(ctx, r.Client, resourceRef.Spec.PlacementSelector)
+	if err != nil {
+		log.Error(err, "unable to select Placements")
+		return ctrl.Result{}, err
+	}
+
 	resourceRef.Status.Status = resourcesv1alpha1.ResourceRefStatusReady
-	resourceRef.Status.Placements = []string{"account-1"}
+	resourceRef.Status.Placements = placements
 	if err := r.Status().Update(ctx, resourceRef); err != nil {
 		log.Error(err, "unable to update ResourceRef's status")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
@@ -62,7 +85,9 @@ func (r *ResourceRefReconciler) Reconcile(ctx context.Context, resourceRef *reso
 
 	log.Info(fmt.Sprintf("ResourceRef %s was updated", resourceRef.Name))
 
-	r.Recorder.Eventf(resourceRef, "Normal", "Reconcile", "ResourceRef %s is reconciled.", resourceRef.Name)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(resourceRef, "Normal", "Reconcile", "ResourceRef %s is reconciled.", resourceRef.Name)
+	}
 
 	return ctrl.Result{}, nil
 }
@@ -71,5 +96,46 @@ func (r *ResourceRefReconciler) Reconcile(ctx context.Context, resourceRef *reso
 func (r *ResourceRefReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&resourcesv1alpha1.ResourceRef{}).
+		Watches(&resourcesv1alpha1.Placement{}, handler.EnqueueRequestsFromMapFunc(r.resourceRefsToPlacement)).
 		Complete(reconcile.AsReconciler(mgr.GetClient(), r))
 }
+
+// resourceRefsToPlacement enqueues every ResourceRef when a Placement changes, since any of them may select it
+func (r *ResourceRefReconciler) resourceRefsToPlacement(ctx context.Context, obj client.Object) []reconcile.Request {
+	resourceRefs := &resourcesv1alpha1.ResourceRefList{}
+	if err := r.List(ctx, resourceRefs); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ResourceRefs", "placement", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(resourceRefs.Items))
+	for _, resourceRef := range resourceRefs.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: resourceRef.Name}})
+	}
+	return requests
+}
+
+// selectPlacements returns the names of the Placements matching the selector, sorted; an empty selector matches all
+func selectPlacements(ctx context.Context, c client.Client, selector *metav1.LabelSelector) ([]string, error) {
+	matching := labels.Everything()
+	if selector != nil {
+		s, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid placement selector: %w", err)
+		}
+		matching = s
+	}
+
+	placements := &resourcesv1alpha1.PlacementList{}
+	if err := c.List(ctx, placements, client.MatchingLabelsSelector{Selector: matching}); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(placements.Items))
+	for _, placement := range placements.Items {
+		names = append(names, placement.Name)
+	}
+	slices.Sort(names)
+
+	return names, nil
+}
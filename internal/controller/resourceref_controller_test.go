@@ -83,4 +83,50 @@ var _ = Describe("ResourceRef Controller", func() {
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
 	})
+
+	Context("When the ResourceRef selects placements", func() {
+		ctx := context.Background()
+
+		placements := []*resourcesv1alpha1.Placement{
+			{ObjectMeta: metav1.ObjectMeta{Name: "prod-us", Labels: map[string]string{"env": "prod"}}, Spec: resourcesv1alpha1.PlacementSpec{Account: "prod", Region: "us-east-1"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "prod-br", Labels: map[string]string{"env": "prod"}}, Spec: resourcesv1alpha1.PlacementSpec{Account: "prod", Region: "sa-east-1"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "staging-us", Labels: map[string]string{"env": "staging"}}, Spec: resourcesv1alpha1.PlacementSpec{Account: "staging", Region: "us-east-1"}},
+		}
+
+		BeforeEach(func() {
+			for _, placement := range placements {
+				Expect(k8sClient.Create(ctx, placement.DeepCopy())).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			for _, placement := range placements {
+				Expect(k8sClient.Delete(ctx, placement.DeepCopy())).To(Succeed())
+			}
+		})
+
+		It("should publish the matching placements", func() {
+			resourceRef := &resourcesv1alpha1.ResourceRef{
+				ObjectMeta: metav1.ObjectMeta{Name: "placement-selector-ref"},
+				Spec: resourcesv1alpha1.ResourceRefSpec{
+					PlacementSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resourceRef)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, resourceRef)).To(Succeed())
+			}()
+
+			reconciler := reconcile.AsReconciler[*resourcesv1alpha1.ResourceRef](k8sClient, &ResourceRefReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			})
+
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: resourceRef.Name}})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceRef.Name}, resourceRef)).To(Succeed())
+			Expect(resourceRef.Status.Placements).To(Equal([]string{"prod-br", "prod-us"}))
+		})
+	})
 })
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// resourceRefProvisionerIndex indexes ResourceRefs by the name of their provisioner
+const resourceRefProvisionerIndex = ".spec.provisioner.name"
+
+// ResourceRefs reads ResourceRefs from the informer cache of the manager, shared by every controller. Every resource
+// reads its ResourceRef on each reconciliation, so the objects are kept between reads and only dropped when the
+// informer sees them change or go away; they're shared, so callers must not modify them.
+type ResourceRefs struct {
+	reader client.Reader
+
+	mu      sync.RWMutex
+	byName  map[string]*resourcesv1alpha1.ResourceRef
+	version uint64
+}
+
+// NewResourceRefs indexes ResourceRefs by provisioner and keeps the accessor in sync with the informer of the manager
+func NewResourceRefs(ctx context.Context, mgr ctrl.Manager) (*ResourceRefs, error) {
+	refs := newResourceRefs(mgr.GetCache())
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &resourcesv1alpha1.ResourceRef{}, resourceRefProvisionerIndex, provisionerNameOf); err != nil {
+		return nil, err
+	}
+
+	informer, err := mgr.GetCache().GetInformer(ctx, &resourcesv1alpha1.ResourceRef{})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(_, obj any) { refs.invalidate(obj) },
+		DeleteFunc: func(obj any) { refs.invalidate(obj) },
+	}); err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
+
+func newResourceRefs(reader client.Reader) *ResourceRefs {
+	return &ResourceRefs{reader: reader, byName: make(map[string]*resourcesv1alpha1.ResourceRef)}
+}
+
+func provisionerNameOf(obj client.Object) []string {
+	resourceRef, ok := obj.(*resourcesv1alpha1.ResourceRef)
+	if !ok {
+		return nil
+	}
+	return []string{string(resourceRef.Spec.Provisioner.Name)}
+}
+
+// Get returns the ResourceRef with the name; it must not be modified
+func (refs *ResourceRefs) Get(ctx context.Context, name string) (*resourcesv1alpha1.ResourceRef, error) {
+	refs.mu.RLock()
+	resourceRef, ok := refs.byName[name]
+	version := refs.version
+	refs.mu.RUnlock()
+
+	if ok {
+		return resourceRef, nil
+	}
+
+	resourceRef = &resourcesv1alpha1.ResourceRef{}
+	if err := refs.reader.Get(ctx, types.NamespacedName{Name: name}, resourceRef); err != nil {
+		return nil, err
+	}
+
+	refs.mu.Lock()
+	defer refs.mu.Unlock()
+
+	// a change seen while reading may be newer than what was read; the next read gets it
+	if refs.version == version {
+		refs.byName[name] = resourceRef
+	}
+	return resourceRef, nil
+}
+
+// ByProvisioner lists the ResourceRefs using the provisioner
+func (refs *ResourceRefs) ByProvisioner(ctx context.Context, provisioner resourcesv1alpha1.ResourceRefProvisionerName) ([]resourcesv1alpha1.ResourceRef, error) {
+	resourceRefs := &resourcesv1alpha1.ResourceRefList{}
+	if err := refs.reader.List(ctx, resourceRefs, client.MatchingFields{resourceRefProvisionerIndex: string(provisioner)}); err != nil {
+		return nil, err
+	}
+	return resourceRefs.Items, nil
+}
+
+func (refs *ResourceRefs) invalidate(obj any) {
+	name, err := toolscache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+
+	refs.mu.Lock()
+	defer refs.mu.Unlock()
+
+	delete(refs.byName, name)
+	refs.version++
+}
+
+// resourceRefOf reads a ResourceRef through the shared accessor or, without one, straight from the client
+func resourceRefOf(ctx context.Context, refs *ResourceRefs, c client.Reader, name string) (*resourcesv1alpha1.ResourceRef, error) {
+	if refs != nil {
+		return refs.Get(ctx, name)
+	}
+
+	resourceRef := &resourcesv1alpha1.ResourceRef{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, resourceRef); err != nil {
+		return nil, err
+	}
+	return resourceRef, nil
+}
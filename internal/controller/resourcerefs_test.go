@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("ResourceRefs", func() {
+	Context("When ResourceRefs are read through the shared accessor", func() {
+		ctx := context.Background()
+
+		resourceRef := &resourcesv1alpha1.ResourceRef{
+			ObjectMeta: metav1.ObjectMeta{Name: "cached-ref"},
+			Spec: resourcesv1alpha1.ResourceRefSpec{
+				Provisioner: resourcesv1alpha1.ResourceRefProvisioner{Name: resourcesv1alpha1.ResourceRefNoopProvisioner},
+			},
+		}
+
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, resourceRef.DeepCopy())).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, resourceRef.DeepCopy())).To(Succeed())
+		})
+
+		It("should keep them until they change", func() {
+			refs := newResourceRefs(k8sClient)
+
+			cached, err := refs.Get(ctx, "cached-ref")
+			Expect(err).NotTo(HaveOccurred())
+
+			again, err := refs.Get(ctx, "cached-ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(again).To(BeIdenticalTo(cached))
+
+			refs.invalidate(cached)
+
+			read, err := refs.Get(ctx, "cached-ref")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read).NotTo(BeIdenticalTo(cached))
+			Expect(read.Name).To(Equal("cached-ref"))
+		})
+
+		It("should fail when the ResourceRef doesn't exist", func() {
+			refs := newResourceRefs(k8sClient)
+
+			_, err := refs.Get(ctx, "unknown-ref")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
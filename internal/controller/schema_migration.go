@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// SchemaVersionAnnotation is the last schema migration applied to an object
+const SchemaVersionAnnotation = resourcesv1alpha1.Group + "/schemaVersion"
+
+// schemaMigration rewrites stored objects whose shape changed between operator versions. Migrations work over
+// unstructured content, so they can read fields that the current API types don't know anymore; the API server still
+// prunes the fields that the installed CRD doesn't declare, so a renamed field must be kept in the schema (deprecated)
+// until the release after the one that migrates it.
+type schemaMigration struct {
+	version     int
+	description string
+	kinds       []string
+	migrate     func(obj *unstructured.Unstructured) error
+}
+
+// schemaMigrations are applied in order; once released, a migration must never change (add a new one instead).
+var schemaMigrations = []schemaMigration{
+	{
+		version:     1,
+		description: "normalize status phases to the DeploymentPhase taxonomy",
+		kinds:       []string{"Resource", "ResourceGroupDeployment", "ResourceGroup"},
+		migrate:     normalizeStatusPhase,
+	},
+}
+
+// SchemaMigration brings Resources, ResourceGroupDeployments and ResourceGroups written by older versions of the
+// operator to the current schema, so they keep round-tripping through the API types.
+// It runs once, when the manager starts.
+type SchemaMigration struct {
+	client.Client
+}
+
+func (m *SchemaMigration) NeedLeaderElection() bool {
+	return true
+}
+
+func (m *SchemaMigration) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName("schema-migration")
+
+	latest := schemaMigrations[len(schemaMigrations)-1].version
+
+	if err := m.migratePlacements(ctx); err != nil {
+		log.Error(err, "unable to migrate placements to Placements")
+		return err
+	}
+
+	for _, kind := range []string{"Resource", "ResourceGroupDeployment", "ResourceGroup"} {
+		objs := &unstructured.UnstructuredList{}
+		objs.SetGroupVersionKind(resourcesv1alpha1.GroupVersion.WithKind(kind + "List"))
+		if err := m.List(ctx, objs); err != nil {
+			return err
+		}
+
+		for i := range objs.Items {
+			obj := &objs.Items[i]
+			if err := m.migrate(ctx, obj, kind); err != nil {
+				log.Error(err, fmt.Sprintf("unable to migrate %s %s", kind, client.ObjectKeyFromObject(obj)))
+				return err
+			}
+		}
+	}
+
+	log.Info(fmt.Sprintf("objects were migrated to schema version %d", latest))
+
+	return nil
+}
+
+func (m *SchemaMigration) migrate(ctx context.Context, obj *unstructured.Unstructured, kind string) error {
+	gvk := resourcesv1alpha1.GroupVersion.WithKind(kind)
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		obj.SetGroupVersionKind(gvk)
+		if err := m.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+
+		current, _ := strconv.Atoi(obj.GetAnnotations()[SchemaVersionAnnotation])
+
+		original := obj.DeepCopy()
+
+		version := current
+		for _, migration := range schemaMigrations {
+			if migration.version <= current || !appliesTo(migration, kind) {
+				version = max(version, migration.version)
+				continue
+			}
+
+			log.FromContext(ctx).Info(fmt.Sprintf("applying schema migration %d (%s) to %s %s", migration.version, migration.description, kind, client.ObjectKeyFromObject(obj)))
+
+			if err := migration.migrate(obj); err != nil {
+				return fmt.Errorf("schema migration %d failed: %w", migration.version, err)
+			}
+			version = migration.version
+		}
+
+		if version == current {
+			return nil
+		}
+
+		// status is a subresource; it must be written apart from the rest of the object. The answer to the status
+		// update is the object as stored, with the spec not migrated yet, so it's read into a copy
+		if status, ok := obj.Object["status"]; ok && !equality.Semantic.DeepEqual(status, original.Object["status"]) {
+			withStatus := obj.DeepCopy()
+			if err := m.Status().Update(ctx, withStatus); err != nil {
+				return err
+			}
+			obj.SetResourceVersion(withStatus.GetResourceVersion())
+		}
+
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[SchemaVersionAnnotation] = strconv.Itoa(version)
+		obj.SetAnnotations(annotations)
+
+		return m.Update(ctx, obj)
+	})
+}
+
+// +kubebuilder:rbac:groups=resources.klaudio.nubank.io,resources=placements,verbs=create
+
+// migratePlacements creates a Placement to each placement deployed to by older versions of the operator, which didn't
+// select Placements by label; while there are none, ResourceRefs select nothing, and their deployments are held. It's
+// a no-op once any Placement exists.
+func (m *SchemaMigration) migratePlacements(ctx context.Context) error {
+	placements := &resourcesv1alpha1.PlacementList{}
+	if err := m.List(ctx, placements); err != nil {
+		return err
+	}
+	if len(placements.Items) != 0 {
+		return nil
+	}
+
+	deployments := &resourcesv1alpha1.ResourceGroupDeploymentList{}
+	if err := m.List(ctx, deployments); err != nil {
+		return err
+	}
+
+	deployed := sets.New[string]()
+	for _, deployment := range deployments.Items {
+		if placement := deployment.Labels[resourcesv1alpha1.Group+"/placement"]; placement != "" {
+			deployed.Insert(placement)
+		}
+	}
+
+	for _, name := range sets.List(deployed) {
+		placement := &resourcesv1alpha1.Placement{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if err := m.Create(ctx, placement); client.IgnoreAlreadyExists(err) != nil {
+			return err
+		}
+		log.FromContext(ctx).Info(fmt.Sprintf("Placement %s was created from the deployments to it", name))
+	}
+
+	return nil
+}
+
+func appliesTo(migration schemaMigration, kind string) bool {
+	for _, candidate := range migration.kinds {
+		if candidate == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeStatusPhase rewrites phases written by older versions of the operator; unknown values are cleared,
+// so the controllers compute them again in the next reconciliation.
+func normalizeStatusPhase(obj *unstructured.Unstructured) error {
+	phase, exists, err := unstructured.NestedString(obj.Object, "status", "phase")
+	if err != nil || !exists || phase == "" {
+		return err
+	}
+
+	normalized, known := resourcesv1alpha1.NormalizeDeploymentPhase(phase)
+	if !known {
+		unstructured.RemoveNestedField(obj.Object, "status", "phase")
+		return nil
+	}
+
+	return unstructured.SetNestedField(obj.Object, string(normalized), "status", "phase")
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_SchemaMigrations(t *testing.T) {
+
+	t.Run("We should rewrite legacy phases", func(t *testing.T) {
+		obj := &unstructured.Unstructured{Object: map[string]any{
+			"status": map[string]any{"phase": "Running"},
+		}}
+
+		assert.NoError(t, normalizeStatusPhase(obj))
+
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		assert.Equal(t, "DeploymentInProgress", phase)
+	})
+
+	t.Run("We should clear unknown phases", func(t *testing.T) {
+		obj := &unstructured.Unstructured{Object: map[string]any{
+			"status": map[string]any{"phase": "Whatever"},
+		}}
+
+		assert.NoError(t, normalizeStatusPhase(obj))
+
+		_, exists, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		assert.False(t, exists)
+	})
+
+	t.Run("We should keep the migrated spec when the status is migrated too", func(t *testing.T) {
+		scheme := runtime.NewScheme()
+		assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+
+		resource := &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "checkout", Name: "orders-bucket"},
+			Spec:       resourcesv1alpha1.ResourceSpec{ResourceRef: "bucket", Placement: "prod"},
+			Status:     resourcesv1alpha1.ResourceStatus{Phase: "Running"},
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(resource).WithStatusSubresource(resource).Build()
+
+		migrations := schemaMigrations
+		defer func() { schemaMigrations = migrations }()
+		schemaMigrations = append(schemaMigrations, schemaMigration{
+			version:     len(migrations) + 1,
+			description: "suspend everything",
+			kinds:       []string{"Resource"},
+			migrate: func(obj *unstructured.Unstructured) error {
+				return unstructured.SetNestedField(obj.Object, true, "spec", "suspend")
+			},
+		})
+
+		migration := &SchemaMigration{Client: c}
+		assert.NoError(t, migration.Start(context.TODO()))
+
+		migrated := &resourcesv1alpha1.Resource{}
+		assert.NoError(t, c.Get(context.TODO(), client.ObjectKeyFromObject(resource), migrated))
+
+		assert.True(t, migrated.Spec.Suspend)
+		assert.Equal(t, resourcesv1alpha1.DeploymentInProgressPhase, migrated.Status.Phase)
+		assert.Equal(t, "2", migrated.Annotations[SchemaVersionAnnotation])
+	})
+
+	t.Run("We should create a Placement to each placement deployed to, while there are none", func(t *testing.T) {
+		scheme := runtime.NewScheme()
+		assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+
+		deploymentTo := func(name, placement string) *resourcesv1alpha1.ResourceGroupDeployment {
+			return &resourcesv1alpha1.ResourceGroupDeployment{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "checkout",
+				Name:      name,
+				Labels:    map[string]string{resourcesv1alpha1.Group + "/placement": placement},
+			}}
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(deploymentTo("checkout.account-1", "account-1"), deploymentTo("orders.account-1", "account-1")).
+			Build()
+
+		migration := &SchemaMigration{Client: c}
+		assert.NoError(t, migration.migratePlacements(context.TODO()))
+
+		placements := &resourcesv1alpha1.PlacementList{}
+		assert.NoError(t, c.List(context.TODO(), placements))
+		if assert.Len(t, placements.Items, 1) {
+			assert.Equal(t, "account-1", placements.Items[0].Name)
+		}
+
+		t.Run("...and leave them alone once any exists", func(t *testing.T) {
+			assert.NoError(t, c.Create(context.TODO(), deploymentTo("checkout.account-2", "account-2")))
+			assert.NoError(t, migration.migratePlacements(context.TODO()))
+
+			assert.NoError(t, c.List(context.TODO(), placements))
+			assert.Len(t, placements.Items, 1)
+		})
+	})
+}
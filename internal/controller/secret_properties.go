@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// writeSecretProperties keeps the secret properties of the Resource in the Secret named by its spec, owned by the
+// Resource; without them, a Secret left by a previous spec is deleted
+func writeSecretProperties(ctx context.Context, c client.Client, scheme *runtime.Scheme, resource *resourcesv1alpha1.Resource, values map[string][]byte) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: resource.Namespace,
+			Name:      resourcesv1alpha1.SecretPropertiesNameOf(resource.Name),
+		},
+	}
+
+	if len(values) == 0 {
+		return client.IgnoreNotFound(c.Delete(ctx, secret))
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, c, secret, func() error {
+		secret.Labels = map[string]string{
+			resourcesv1alpha1.Group + "/managedBy.kind": "Resource",
+			resourcesv1alpha1.Group + "/managedBy.name": resource.Name,
+		}
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = values
+		return controllerutil.SetControllerReference(resource, secret, scheme)
+	})
+	return err
+}
+
+// withSecretProperties returns a copy of the Resource whose properties include the ones kept in its Secret, to be
+// handed to the provisioner; the Resource itself is never updated with them
+func withSecretProperties(ctx context.Context, c client.Client, resource *resourcesv1alpha1.Resource) (*resourcesv1alpha1.Resource, error) {
+	if resource.Spec.SecretProperties == nil {
+		return resource, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: resource.Namespace, Name: resource.Spec.SecretProperties.Name}, secret); err != nil {
+		return nil, fmt.Errorf("unable to read the secret properties of Resource %s: %w", resource.Name, err)
+	}
+
+	properties := make(map[string]json.RawMessage)
+	if resource.Spec.Properties != nil && len(resource.Spec.Properties.Raw) != 0 {
+		if err := json.Unmarshal(resource.Spec.Properties.Raw, &properties); err != nil {
+			return nil, err
+		}
+	}
+	for name, value := range secret.Data {
+		properties[name] = value
+	}
+
+	raw, err := json.Marshal(properties)
+	if err != nil {
+		return nil, err
+	}
+
+	withSecrets := resource.DeepCopy()
+	withSecrets.Spec.Properties = &runtime.RawExtension{Raw: raw}
+	return withSecrets, nil
+}
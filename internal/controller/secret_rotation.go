@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/provisioning"
+)
+
+// consumedSecretsIndex indexes Resources by the Secrets read by their provisioner objects
+const consumedSecretsIndex = ".status.consumedSecrets"
+
+func consumedSecretKeysOf(obj client.Object) []string {
+	resource, ok := obj.(*resourcesv1alpha1.Resource)
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0, len(resource.Status.ConsumedSecrets))
+	for _, secret := range resource.Status.ConsumedSecrets {
+		keys = append(keys, types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}.String())
+	}
+	return keys
+}
+
+// resourcesConsumingSecret maps a Secret to the Resources whose provisioner objects read it, so a rotation is handled
+// right away. Secrets of remote placements aren't watched; their rotations are found by the next reconciliation.
+func (r *ResourceReconciler) resourcesConsumingSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	resources := &resourcesv1alpha1.ResourceList{}
+	if err := r.List(ctx, resources, client.MatchingFields{consumedSecretsIndex: client.ObjectKeyFromObject(obj).String()}); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list Resources", "secret", client.ObjectKeyFromObject(obj))
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(resources.Items))
+	for _, resource := range resources.Items {
+		if !inShard(r.ShardSelector, &resource) {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&resource)})
+	}
+	return requests
+}
+
+// consumedSecretsOf hashes the Secrets read by the provisioner object of the Resource; nil when the provisioner reads
+// none. A missing Secret has an empty hash, so its creation is a rotation too.
+func consumedSecretsOf(ctx context.Context, c client.Client, resource *resourcesv1alpha1.Resource, provisioner provisioning.Provisioner) ([]resourcesv1alpha1.ResourceStatusConsumedSecret, error) {
+	consumer, ok := provisioner.(provisioning.SecretConsumer)
+	if !ok {
+		return nil, nil
+	}
+
+	names, err := consumer.ConsumedSecrets(resource)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	consumed := make([]resourcesv1alpha1.ResourceStatusConsumedSecret, 0, len(names))
+	for _, name := range names {
+		hash := ""
+
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, name, secret); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("unable to read Secret %s: %w", name, err)
+			}
+		} else {
+			// maps are encoded with sorted keys, so the same data always has the same hash
+			encoded, err := json.Marshal(secret.Data)
+			if err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256(encoded)
+			hash = hex.EncodeToString(sum[:])
+		}
+
+		consumed = append(consumed, resourcesv1alpha1.ResourceStatusConsumedSecret{Namespace: name.Namespace, Name: name.Name, Hash: hash})
+	}
+	return consumed, nil
+}
+
+// rotatedSecretsOf returns the Secrets whose data changed since the last successful run; Secrets the provisioner
+// didn't read back then are new inputs, not rotations
+func rotatedSecretsOf(resource *resourcesv1alpha1.Resource, consumed []resourcesv1alpha1.ResourceStatusConsumedSecret) []string {
+	previous := make(map[string]string, len(resource.Status.ConsumedSecrets))
+	for _, secret := range resource.Status.ConsumedSecrets {
+		previous[types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}.String()] = secret.Hash
+	}
+
+	rotated := make([]string, 0)
+	for _, secret := range consumed {
+		name := types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}.String()
+		if hash, ok := previous[name]; ok && hash != secret.Hash {
+			rotated = append(rotated, name)
+		}
+	}
+	return rotated
+}
+
+// rotateSecrets applies the rotation policy of the ResourceRef to rotated Secrets. With Reprovision, or once a refresh
+// is requested with the Manual policy, the provisioner object is asked to run again; otherwise, the rotation is only
+// reported, and true is returned so the provisioner doesn't run. A new generation of the Resource is always applied.
+func (r *ResourceReconciler) rotateSecrets(ctx context.Context, resource *resourcesv1alpha1.Resource, resourceRef *resourcesv1alpha1.ResourceRef, rotated []string) (bool, error) {
+	log := log.FromContext(ctx).WithValues("resource", resource.Name)
+
+	current := meta.FindStatusCondition(resource.Status.Conditions, resourcesv1alpha1.ConditionTypeNeedsRotation)
+
+	manual := resourceRef.Spec.SecretRotationPolicy == resourcesv1alpha1.SecretRotationPolicyManual
+	if manual && resource.Status.ObservedGeneration == resource.Generation && !refreshRequestedSince(resource, current) {
+		condition := metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeNeedsRotation,
+			Status:  metav1.ConditionTrue,
+			Reason:  resourcesv1alpha1.ConditionReasonSecretsRotated,
+			Message: fmt.Sprintf("Secrets read by the provisioner of Resource %s were rotated: %s; request a refresh to provision it again", resource.Name, strings.Join(rotated, ", ")),
+		}
+		if setStatusCondition(&resource.Status.Conditions, condition) {
+			if err := r.Status().Update(ctx, resource); err != nil {
+				return true, err
+			}
+		}
+		return true, nil
+	}
+
+	// the provisioner object was already asked to run again
+	if current != nil && current.Status == metav1.ConditionTrue && current.Reason == resourcesv1alpha1.ConditionReasonReprovisioning {
+		return false, nil
+	}
+
+	log.Info(fmt.Sprintf("Secrets read by the provisioner were rotated: %s; provisioning Resource %s again...", strings.Join(rotated, ", "), resource.Name))
+
+	if resource.Annotations == nil {
+		resource.Annotations = make(map[string]string)
+	}
+	requestedAt := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, annotation := range provisioning.ReconcileRequestAnnotations {
+		resource.Annotations[annotation] = requestedAt
+	}
+	if err := r.Update(ctx, resource); err != nil {
+		return false, err
+	}
+
+	_, err := r.newResourceCondition(ctx, resource, &metav1.Condition{
+		Type:    resourcesv1alpha1.ConditionTypeNeedsRotation,
+		Status:  metav1.ConditionTrue,
+		Reason:  resourcesv1alpha1.ConditionReasonReprovisioning,
+		Message: fmt.Sprintf("Secrets read by the provisioner of Resource %s were rotated: %s; provisioning it again", resource.Name, strings.Join(rotated, ", ")),
+	})
+	return false, err
+}
+
+// rotationApplied records the Secrets read by a successful run, clearing a pending rotation
+func rotationApplied(resource *resourcesv1alpha1.Resource, consumed []resourcesv1alpha1.ResourceStatusConsumedSecret) {
+	resource.Status.ConsumedSecrets = consumed
+
+	if meta.IsStatusConditionTrue(resource.Status.Conditions, resourcesv1alpha1.ConditionTypeNeedsRotation) {
+		setStatusCondition(&resource.Status.Conditions, metav1.Condition{
+			Type:    resourcesv1alpha1.ConditionTypeNeedsRotation,
+			Status:  metav1.ConditionFalse,
+			Reason:  resourcesv1alpha1.ConditionReasonSecretsApplied,
+			Message: fmt.Sprintf("Resource %s was provisioned with the rotated Secrets", resource.Name),
+		})
+	}
+}
+
+// refreshRequestedSince tells whether a refresh of the Resource was requested after the rotation was reported
+func refreshRequestedSince(resource *resourcesv1alpha1.Resource, condition *metav1.Condition) bool {
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		return false
+	}
+	requestedAt, err := time.Parse(time.RFC3339, resource.Annotations[RefreshRequestedAnnotation])
+	return err == nil && requestedAt.After(condition.LastTransitionTime.Time)
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/provisioning"
+)
+
+type secretConsumerProvisioner struct {
+	provisioning.NoopProvisioner
+	secrets []types.NamespacedName
+}
+
+func (p *secretConsumerProvisioner) ConsumedSecrets(_ *resourcesv1alpha1.Resource) ([]types.NamespacedName, error) {
+	return p.secrets, nil
+}
+
+var _ = Describe("Secret rotation", func() {
+	Context("When the Secrets read by a provisioner change", func() {
+		ctx := context.Background()
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "secret-rotation-credentials", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte("s3cr3t")},
+		}
+
+		provisioner := &secretConsumerProvisioner{secrets: []types.NamespacedName{
+			{Namespace: "default", Name: "secret-rotation-credentials"},
+			{Namespace: "default", Name: "secret-rotation-missing"},
+		}}
+
+		BeforeEach(func() {
+			Expect(k8sClient.Create(ctx, secret.DeepCopy())).To(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, secret.DeepCopy())).To(Succeed())
+		})
+
+		It("should hash the Secrets read by the provisioner", func() {
+			resource := &resourcesv1alpha1.Resource{ObjectMeta: metav1.ObjectMeta{Name: "secret-rotation", Namespace: "default"}}
+
+			consumed, err := consumedSecretsOf(ctx, k8sClient, resource, provisioner)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(consumed).To(HaveLen(2))
+			Expect(consumed[0].Name).To(Equal("secret-rotation-credentials"))
+			Expect(consumed[0].Hash).NotTo(BeEmpty())
+			Expect(consumed[1].Name).To(Equal("secret-rotation-missing"))
+			Expect(consumed[1].Hash).To(BeEmpty())
+		})
+
+		It("should report only the Secrets whose data changed since the last run", func() {
+			resource := &resourcesv1alpha1.Resource{ObjectMeta: metav1.ObjectMeta{Name: "secret-rotation", Namespace: "default"}}
+
+			consumed, err := consumedSecretsOf(ctx, k8sClient, resource, provisioner)
+			Expect(err).NotTo(HaveOccurred())
+
+			resource.Status.ConsumedSecrets = consumed[:1]
+			Expect(rotatedSecretsOf(resource, consumed)).To(BeEmpty())
+
+			rotated := secret.DeepCopy()
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: secret.Name}, rotated)).To(Succeed())
+			rotated.Data["password"] = []byte("n3w-s3cr3t")
+			Expect(k8sClient.Update(ctx, rotated)).To(Succeed())
+
+			current, err := consumedSecretsOf(ctx, k8sClient, resource, provisioner)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rotatedSecretsOf(resource, current)).To(Equal([]string{"default/secret-rotation-credentials"}))
+		})
+
+		It("should ignore provisioners reading no Secrets", func() {
+			resource := &resourcesv1alpha1.Resource{ObjectMeta: metav1.ObjectMeta{Name: "secret-rotation", Namespace: "default"}}
+
+			consumed, err := consumedSecretsOf(ctx, k8sClient, resource, &provisioning.NoopProvisioner{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(consumed).To(BeNil())
+		})
+	})
+})
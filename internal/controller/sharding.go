@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// inShard tells whether the object belongs to the shard handled by this manager; a nil selector means there
+// is no sharding at all. Resources and ResourceGroupDeployments carry the placement label, so selecting
+// by placement splits the load deterministically across managers.
+func inShard(selector labels.Selector, obj client.Object) bool {
+	return selector == nil || selector.Matches(labels.Set(obj.GetLabels()))
+}
+
+func shardPredicate(selector labels.Selector) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return inShard(selector, obj)
+	})
+}
+
+// ShardCache restricts the cache of the manager to the Resources and ResourceGroupDeployments of its shard, so
+// each manager only watches and keeps in memory its own share of them. The predicates are still needed when the
+// cache can't be restricted, like in the manager running the group controllers, which read all of them.
+func ShardCache(selector labels.Selector) map[client.Object]cache.ByObject {
+	if selector == nil {
+		return nil
+	}
+	return map[client.Object]cache.ByObject{
+		&resourcesv1alpha1.Resource{}:                {Label: selector},
+		&resourcesv1alpha1.ResourceGroupDeployment{}: {Label: selector},
+	}
+}
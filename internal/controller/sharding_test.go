@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_Sharding(t *testing.T) {
+	selector, err := labels.Parse(resourcesv1alpha1.Group + "/placement in (us-east-1)")
+	assert.NoError(t, err)
+
+	t.Run("We should restrict the cache of Resources and ResourceGroupDeployments to the shard", func(t *testing.T) {
+		byObject := ShardCache(selector)
+		assert.Len(t, byObject, 2)
+		for obj, options := range byObject {
+			assert.Equal(t, selector, options.Label, "%T", obj)
+		}
+	})
+
+	t.Run("Without a selector, we should cache everything", func(t *testing.T) {
+		assert.Nil(t, ShardCache(nil))
+	})
+
+	t.Run("We should tell the objects of the shard apart", func(t *testing.T) {
+		inside := &resourcesv1alpha1.Resource{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{resourcesv1alpha1.Group + "/placement": "us-east-1"}}}
+		outside := &resourcesv1alpha1.Resource{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{resourcesv1alpha1.Group + "/placement": "us-west-2"}}}
+
+		assert.True(t, inShard(selector, inside))
+		assert.False(t, inShard(selector, outside))
+		assert.True(t, inShard(nil, outside))
+	})
+}
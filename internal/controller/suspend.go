@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// suspendedCondition is kept while spec.suspend holds the reconciliation of an object; deletions aren't held
+func suspendedCondition(kind string, name string) *metav1.Condition {
+	return &metav1.Condition{
+		Type:    resourcesv1alpha1.ConditionTypeSuspended,
+		Status:  metav1.ConditionTrue,
+		Reason:  resourcesv1alpha1.ConditionReasonSuspended,
+		Message: fmt.Sprintf("Reconciliation from %s %s is suspended", kind, name),
+	}
+}
+
+func resumedCondition(kind string, name string) *metav1.Condition {
+	return &metav1.Condition{
+		Type:    resourcesv1alpha1.ConditionTypeSuspended,
+		Status:  metav1.ConditionFalse,
+		Reason:  resourcesv1alpha1.ConditionReasonResumed,
+		Message: fmt.Sprintf("Reconciliation from %s %s was resumed", kind, name),
+	}
+}
+
+// placementSuspendedCondition is kept while the placement targeted by a deployment is suspended
+func placementSuspendedCondition(placement string) *metav1.Condition {
+	return &metav1.Condition{
+		Type:    resourcesv1alpha1.ConditionTypeSuspended,
+		Status:  metav1.ConditionTrue,
+		Reason:  resourcesv1alpha1.ConditionReasonPlacementSuspended,
+		Message: fmt.Sprintf("Reconciliation from every ResourceGroupDeployment to placement %s is suspended", placement),
+	}
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+const (
+	timeToReadyMet      = "met"
+	timeToReadyBreached = "breached"
+)
+
+// trackTimeToReady measures the deployment run against its time-to-ready SLO, before the new phase is persisted; a run
+// starts when its inputs are resolved. A finished run records how long it took; a run in progress past the SLO is
+// flagged as slow. It returns when a run in progress must be checked again, zero if it doesn't.
+func trackTimeToReady(deployment *resourcesv1alpha1.ResourceGroupDeployment, previousPhase resourcesv1alpha1.DeploymentPhase, now time.Time) time.Duration {
+	if deployment.Status.Inputs == nil {
+		return 0
+	}
+
+	slo := deployment.Spec.TimeToReady
+	if slo == nil || slo.Duration <= 0 {
+		// the SLO was removed; there is nothing to report anymore
+		meta.RemoveStatusCondition(&deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeSlowProvisioning)
+		slo = nil
+	}
+
+	elapsed := now.Sub(deployment.Status.Inputs.ResolvedAt.Time).Round(time.Second)
+
+	switch deployment.Status.Phase {
+	case resourcesv1alpha1.DeploymentDonePhase:
+		if previousPhase == resourcesv1alpha1.DeploymentDonePhase {
+			return 0
+		}
+
+		deployment.Status.TimeToReady = &metav1.Duration{Duration: elapsed}
+
+		if slo == nil {
+			return 0
+		}
+
+		if elapsed > slo.Duration {
+			slowProvisioning(deployment, metav1.ConditionTrue, resourcesv1alpha1.ConditionReasonTimeToReadyExceeded,
+				fmt.Sprintf("Deployment run took %s, longer than the time-to-ready SLO of %s", elapsed, slo.Duration))
+		} else {
+			slowProvisioning(deployment, metav1.ConditionFalse, resourcesv1alpha1.ConditionReasonTimeToReadyMet,
+				fmt.Sprintf("Deployment run took %s, within the time-to-ready SLO of %s", elapsed, slo.Duration))
+		}
+
+		return 0
+
+	case resourcesv1alpha1.DeploymentInProgressPhase:
+		if slo == nil {
+			return 0
+		}
+
+		if elapsed > slo.Duration {
+			slowProvisioning(deployment, metav1.ConditionTrue, resourcesv1alpha1.ConditionReasonTimeToReadyExceeded,
+				fmt.Sprintf("Deployment run is in progress for %s, longer than the time-to-ready SLO of %s", elapsed, slo.Duration))
+			return 0
+		}
+
+		// a flag from a previous run doesn't apply to this one
+		if meta.IsStatusConditionTrue(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeSlowProvisioning) {
+			slowProvisioning(deployment, metav1.ConditionFalse, resourcesv1alpha1.ConditionReasonTimeToReadyMet,
+				fmt.Sprintf("Deployment run is in progress for %s, within the time-to-ready SLO of %s", elapsed, slo.Duration))
+		}
+
+		return slo.Duration - elapsed + time.Second
+	}
+
+	return 0
+}
+
+func slowProvisioning(deployment *resourcesv1alpha1.ResourceGroupDeployment, status metav1.ConditionStatus, reason string, message string) {
+	setStatusCondition(&deployment.Status.Conditions, metav1.Condition{
+		Type:               resourcesv1alpha1.ConditionTypeSlowProvisioning,
+		Status:             status,
+		Reason:             reason,
+		ObservedGeneration: deployment.Generation,
+		Message:            message,
+	})
+}
+
+// observeTimeToReady exposes the time-to-ready of a finished deployment run, and how much of its SLO it burned
+func observeTimeToReady(deployment *resourcesv1alpha1.ResourceGroupDeployment) {
+	if deployment.Status.TimeToReady == nil {
+		return
+	}
+
+	resourceGroup := deployment.Labels[resourcesv1alpha1.Group+"/managedBy.name"]
+	elapsed := deployment.Status.TimeToReady.Duration
+
+	deploymentTimeToReady.WithLabelValues(resourceGroup, deployment.Spec.Placement).Observe(elapsed.Seconds())
+
+	slo := deployment.Spec.TimeToReady
+	if slo == nil || slo.Duration <= 0 {
+		deploymentTimeToReadyBurn.DeleteLabelValues(resourceGroup, deployment.Spec.Placement)
+		return
+	}
+
+	result := timeToReadyMet
+	if elapsed > slo.Duration {
+		result = timeToReadyBreached
+	}
+	deploymentTimeToReadyRuns.WithLabelValues(resourceGroup, deployment.Spec.Placement, result).Inc()
+	deploymentTimeToReadyBurn.WithLabelValues(resourceGroup, deployment.Spec.Placement).Set(elapsed.Seconds() / slo.Duration.Seconds())
+}
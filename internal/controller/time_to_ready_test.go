@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("Time-to-ready", func() {
+	Context("When a deployment run is measured against its SLO", func() {
+		resolvedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		newDeployment := func(phase resourcesv1alpha1.DeploymentPhase, slo *metav1.Duration) *resourcesv1alpha1.ResourceGroupDeployment {
+			return &resourcesv1alpha1.ResourceGroupDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "sample.dev", Namespace: "default"},
+				Spec:       resourcesv1alpha1.ResourceGroupDeploymentSpec{Placement: "dev", TimeToReady: slo},
+				Status: resourcesv1alpha1.ResourceGroupDeploymentStatus{
+					Phase:  phase,
+					Inputs: &resourcesv1alpha1.ResourceGroupDeploymentInputs{ResolvedAt: metav1.NewTime(resolvedAt)},
+				},
+			}
+		}
+
+		slo := &metav1.Duration{Duration: 10 * time.Minute}
+
+		It("should record how long a finished run took", func() {
+			deployment := newDeployment(resourcesv1alpha1.DeploymentDonePhase, nil)
+
+			Expect(trackTimeToReady(deployment, resourcesv1alpha1.DeploymentInProgressPhase, resolvedAt.Add(5*time.Minute))).To(BeZero())
+			Expect(deployment.Status.TimeToReady).To(Equal(&metav1.Duration{Duration: 5 * time.Minute}))
+			Expect(meta.FindStatusCondition(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeSlowProvisioning)).To(BeNil())
+		})
+
+		It("should flag a finished run slower than the SLO", func() {
+			deployment := newDeployment(resourcesv1alpha1.DeploymentDonePhase, slo)
+
+			trackTimeToReady(deployment, resourcesv1alpha1.DeploymentInProgressPhase, resolvedAt.Add(15*time.Minute))
+
+			condition := meta.FindStatusCondition(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeSlowProvisioning)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Reason).To(Equal(resourcesv1alpha1.ConditionReasonTimeToReadyExceeded))
+		})
+
+		It("should not measure a run again once it's done", func() {
+			deployment := newDeployment(resourcesv1alpha1.DeploymentDonePhase, slo)
+
+			trackTimeToReady(deployment, resourcesv1alpha1.DeploymentDonePhase, resolvedAt.Add(time.Hour))
+
+			Expect(deployment.Status.TimeToReady).To(BeNil())
+			Expect(deployment.Status.Conditions).To(BeEmpty())
+		})
+
+		It("should check a run in progress again once it would breach the SLO", func() {
+			deployment := newDeployment(resourcesv1alpha1.DeploymentInProgressPhase, slo)
+			slowProvisioning(deployment, metav1.ConditionTrue, resourcesv1alpha1.ConditionReasonTimeToReadyExceeded, "previous run")
+
+			Expect(trackTimeToReady(deployment, resourcesv1alpha1.DeploymentInProgressPhase, resolvedAt.Add(4*time.Minute))).To(Equal(6*time.Minute + time.Second))
+			Expect(meta.IsStatusConditionFalse(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeSlowProvisioning)).To(BeTrue())
+			Expect(deployment.Status.TimeToReady).To(BeNil())
+		})
+
+		It("should flag a run in progress past the SLO", func() {
+			deployment := newDeployment(resourcesv1alpha1.DeploymentInProgressPhase, slo)
+
+			Expect(trackTimeToReady(deployment, resourcesv1alpha1.DeploymentInProgressPhase, resolvedAt.Add(11*time.Minute))).To(BeZero())
+			Expect(meta.IsStatusConditionTrue(deployment.Status.Conditions, resourcesv1alpha1.ConditionTypeSlowProvisioning)).To(BeTrue())
+		})
+	})
+})
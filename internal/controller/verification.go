@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var outputEnvNameRe = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// outputEnvName is the environment variable an output is available as to the verification Job
+func outputEnvName(output string) string {
+	return "OUTPUT_" + strings.ToUpper(outputEnvNameRe.ReplaceAllString(output, "_"))
+}
+
+// verificationJobName is the name of the Job verifying a generation of the Resource
+func verificationJobName(resource *resourcesv1alpha1.Resource) string {
+	return fmt.Sprintf("%s-verify-%d", resource.Name, resource.Generation)
+}
+
+// verify runs the verification Job of a provisioned Resource, once to each generation. It returns no condition when
+// the Job succeeded, so the Resource can be Done; otherwise, the phase and the condition to report: in progress while
+// the Job runs, failed when it failed. Outputs are handed to the Job through a Secret, since some may be sensitive.
+func (r *ResourceReconciler) verify(ctx context.Context, resource *resourcesv1alpha1.Resource, outputs map[string]any) (resourcesv1alpha1.DeploymentPhase, *metav1.Condition, error) {
+	log := log.FromContext(ctx).WithValues("resource", resource.Name)
+
+	name := verificationJobName(resource)
+
+	job := &batchv1.Job{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: resource.Namespace, Name: name}, job); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return "", nil, err
+		}
+
+		secret, err := r.outputsSecret(ctx, resource, fmt.Sprintf("%s-verification", resource.Name), outputs)
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to write the outputs to the verification Job: %w", err)
+		}
+
+		job = newVerificationJob(resource, name, secret.Name)
+		if err := ctrl.SetControllerReference(resource, job, r.Scheme); err != nil {
+			return "", nil, err
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return "", nil, fmt.Errorf("unable to create the verification Job %s: %w", name, err)
+		}
+
+		log.Info(fmt.Sprintf("Resource %s was provisioned; verifying it through Job %s...", resource.Name, name))
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			log.Info(fmt.Sprintf("verification Job %s succeeded", name))
+			return "", nil, nil
+
+		case batchv1.JobFailed:
+			return resourcesv1alpha1.DeploymentFailedPhase, &metav1.Condition{
+				Type:    resourcesv1alpha1.ConditionTypeFailed,
+				Status:  metav1.ConditionFalse,
+				Reason:  resourcesv1alpha1.ConditionReasonVerificationFailed,
+				Message: fmt.Sprintf("Verification Job %s of Resource %s failed: %s", name, resource.Name, condition.Message),
+			}, nil
+		}
+	}
+
+	return resourcesv1alpha1.DeploymentInProgressPhase, &metav1.Condition{
+		Type:    resourcesv1alpha1.ConditionTypeInProgress,
+		Status:  metav1.ConditionTrue,
+		Reason:  resourcesv1alpha1.ConditionReasonVerifying,
+		Message: fmt.Sprintf("Resource %s was provisioned; waiting for verification Job %s...", resource.Name, name),
+	}, nil
+}
+
+// outputsSecret writes the outputs of the Resource as the environment of its Jobs, like the verification one
+func (r *ResourceReconciler) outputsSecret(ctx context.Context, resource *resourcesv1alpha1.Resource, name string, outputs map[string]any) (*corev1.Secret, error) {
+	data := make(map[string][]byte)
+	for name, value := range outputs {
+		if s, ok := value.(string); ok {
+			data[outputEnvName(name)] = []byte(s)
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		data[outputEnvName(name)] = encoded
+	}
+
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: resource.Namespace, Name: name}
+	if err := r.Get(ctx, key, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Data:       data,
+		}
+		if err := ctrl.SetControllerReference(resource, secret, r.Scheme); err != nil {
+			return nil, err
+		}
+		return secret, r.Create(ctx, secret)
+	}
+
+	secret.Data = data
+	return secret, r.Update(ctx, secret)
+}
+
+func newVerificationJob(resource *resourcesv1alpha1.Resource, name string, secretName string) *batchv1.Job {
+	verification := resource.Spec.Verification
+
+	env := make([]corev1.EnvVar, 0, len(verification.Env))
+	for _, name := range slices.Sorted(maps.Keys(verification.Env)) {
+		env = append(env, corev1.EnvVar{Name: name, Value: verification.Env[name]})
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: resource.Namespace,
+			Name:      name,
+			Labels: map[string]string{
+				resourcesv1alpha1.Group + "/managedBy.name": resource.Name,
+				resourcesv1alpha1.Group + "/placement":      resource.Spec.Placement,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          ptr.To(ptr.Deref(verification.BackoffLimit, 0)),
+			ActiveDeadlineSeconds: verification.ActiveDeadlineSeconds,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    "verification",
+							Image:   verification.Image,
+							Command: verification.Command,
+							Args:    verification.Args,
+							Env:     env,
+							EnvFrom: []corev1.EnvFromSource{
+								{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: secretName}}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
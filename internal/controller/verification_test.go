@@ -0,0 +1,48 @@
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+var _ = Describe("Verification", func() {
+	Context("When a Resource has a verification Job", func() {
+		resource := &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "database", Namespace: "checkout", Generation: 3},
+			Spec: resourcesv1alpha1.ResourceSpec{
+				Placement: "prod",
+				Verification: &resourcesv1alpha1.ResourceVerification{
+					Image:   "postgres:16",
+					Command: []string{"pg_isready"},
+					Env:     map[string]string{"PGCONNECT_TIMEOUT": "5"},
+				},
+			},
+		}
+
+		It("should expose the outputs as environment variables", func() {
+			Expect(outputEnvName("host")).To(Equal("OUTPUT_HOST"))
+			Expect(outputEnvName("connection-string")).To(Equal("OUTPUT_CONNECTION_STRING"))
+			Expect(outputEnvName("replica.endpoint")).To(Equal("OUTPUT_REPLICA_ENDPOINT"))
+		})
+
+		It("should run the Job once to each generation, without retries by default", func() {
+			name := verificationJobName(resource)
+			Expect(name).To(Equal("database-verify-3"))
+
+			job := newVerificationJob(resource, name, "database-verification")
+			Expect(*job.Spec.BackoffLimit).To(Equal(int32(0)))
+
+			Expect(job.Spec.Template.Spec.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+			Expect(job.Spec.Template.Spec.Containers).To(HaveLen(1))
+
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("postgres:16"))
+			Expect(container.Env).To(Equal([]corev1.EnvVar{{Name: "PGCONNECT_TIMEOUT", Value: "5"}}))
+			Expect(container.EnvFrom[0].SecretRef.Name).To(Equal("database-verification"))
+		})
+	})
+})
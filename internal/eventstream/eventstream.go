@@ -0,0 +1,165 @@
+package eventstream
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+const (
+	MessageTypeEvent           = "Event"
+	MessageTypePhaseTransition = "PhaseTransition"
+)
+
+// Message is an entry of the stream of a ResourceGroup: a Kubernetes Event about one of its objects, or a phase
+// transition of the group, its deployments or its Resources
+type Message struct {
+	Type      string      `json:"type"`
+	Time      metav1.Time `json:"time"`
+	Kind      string      `json:"kind"`
+	Namespace string      `json:"namespace,omitempty"`
+	Name      string      `json:"name"`
+
+	// filled to Events
+	EventType string `json:"eventType,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Message   string `json:"message,omitempty"`
+
+	// filled to phase transitions
+	PreviousPhase resourcesv1alpha1.DeploymentPhase `json:"previousPhase,omitempty"`
+	Phase         resourcesv1alpha1.DeploymentPhase `json:"phase,omitempty"`
+}
+
+// subscriberBuffer is how many messages a slow subscriber may fall behind before new messages are dropped to it
+const subscriberBuffer = 64
+
+// Broker fans the messages of each ResourceGroup out to its subscribers
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan Message]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[string]map[chan Message]struct{})}
+}
+
+// Subscribe returns the messages published to a ResourceGroup from now on; the returned function must be called to
+// stop receiving them.
+func (b *Broker) Subscribe(resourceGroup string) (<-chan Message, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	messages := make(chan Message, subscriberBuffer)
+	if b.subscribers[resourceGroup] == nil {
+		b.subscribers[resourceGroup] = make(map[chan Message]struct{})
+	}
+	b.subscribers[resourceGroup][messages] = struct{}{}
+
+	return messages, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers[resourceGroup], messages)
+		if len(b.subscribers[resourceGroup]) == 0 {
+			delete(b.subscribers, resourceGroup)
+		}
+	}
+}
+
+// Publish sends a message to every subscriber of the ResourceGroup; it never blocks, so a subscriber that doesn't keep
+// up loses messages instead of holding the others.
+func (b *Broker) Publish(resourceGroup string, message Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for subscriber := range b.subscribers[resourceGroup] {
+		select {
+		case subscriber <- message:
+		default:
+		}
+	}
+}
+
+func (b *Broker) subscribed(resourceGroup string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers[resourceGroup])
+}
+
+// resourceGroupOfEvent finds the ResourceGroup an Event is about: the group itself, which is cluster-scoped, or any
+// object inside the namespace generated to it.
+func resourceGroupOfEvent(event *corev1.Event) string {
+	if event.InvolvedObject.Kind == "ResourceGroup" && event.InvolvedObject.Namespace == "" {
+		return event.InvolvedObject.Name
+	}
+	return event.Namespace
+}
+
+func eventMessage(event *corev1.Event) Message {
+	eventTime := event.LastTimestamp
+	if eventTime.IsZero() {
+		eventTime = metav1.NewTime(event.EventTime.Time)
+	}
+
+	return Message{
+		Type:      MessageTypeEvent,
+		Time:      eventTime,
+		Kind:      event.InvolvedObject.Kind,
+		Namespace: event.InvolvedObject.Namespace,
+		Name:      event.InvolvedObject.Name,
+		EventType: event.Type,
+		Reason:    event.Reason,
+		Message:   event.Message,
+	}
+}
+
+// phaseTransition compares the phases of two versions of an object, returning the ResourceGroup it belongs to and
+// the message to publish; ok is false when the phase didn't change.
+func phaseTransition(oldObj client.Object, newObj client.Object) (resourceGroup string, message Message, ok bool) {
+	previousPhase, _ := phaseOf(oldObj)
+	phase, known := phaseOf(newObj)
+	if !known || previousPhase == phase {
+		return "", Message{}, false
+	}
+
+	var kind string
+	switch newObj.(type) {
+	case *resourcesv1alpha1.ResourceGroup:
+		kind = "ResourceGroup"
+		resourceGroup = newObj.GetName()
+	case *resourcesv1alpha1.ResourceGroupDeployment:
+		kind = "ResourceGroupDeployment"
+		resourceGroup = newObj.GetNamespace()
+	case *resourcesv1alpha1.Resource:
+		kind = "Resource"
+		resourceGroup = newObj.GetNamespace()
+	}
+
+	return resourceGroup, Message{
+		Type:          MessageTypePhaseTransition,
+		Time:          metav1.Now(),
+		Kind:          kind,
+		Namespace:     newObj.GetNamespace(),
+		Name:          newObj.GetName(),
+		PreviousPhase: previousPhase,
+		Phase:         phase,
+	}, true
+}
+
+func phaseOf(obj client.Object) (resourcesv1alpha1.DeploymentPhase, bool) {
+	switch o := obj.(type) {
+	case *resourcesv1alpha1.ResourceGroup:
+		return o.Status.Phase, true
+	case *resourcesv1alpha1.ResourceGroupDeployment:
+		return o.Status.Phase, true
+	case *resourcesv1alpha1.Resource:
+		return o.Status.Phase, true
+	default:
+		return "", false
+	}
+}
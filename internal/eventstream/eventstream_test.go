@@ -0,0 +1,142 @@
+package eventstream
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_Broker(t *testing.T) {
+	t.Run("We should deliver messages only to the subscribers of the ResourceGroup", func(t *testing.T) {
+		broker := NewBroker()
+
+		checkout, unsubscribeCheckout := broker.Subscribe("checkout")
+		defer unsubscribeCheckout()
+
+		payments, unsubscribePayments := broker.Subscribe("payments")
+		defer unsubscribePayments()
+
+		broker.Publish("checkout", Message{Type: MessageTypeEvent, Name: "checkout.prod"})
+
+		assert.Equal(t, "checkout.prod", (<-checkout).Name)
+		assert.Empty(t, payments)
+	})
+
+	t.Run("We should not block when a subscriber doesn't keep up", func(t *testing.T) {
+		broker := NewBroker()
+
+		messages, unsubscribe := broker.Subscribe("checkout")
+		defer unsubscribe()
+
+		for range subscriberBuffer + 10 {
+			broker.Publish("checkout", Message{Type: MessageTypeEvent})
+		}
+
+		assert.Len(t, messages, subscriberBuffer)
+	})
+
+	t.Run("We should forget subscribers that are gone", func(t *testing.T) {
+		broker := NewBroker()
+
+		_, unsubscribe := broker.Subscribe("checkout")
+		assert.Equal(t, 1, broker.subscribed("checkout"))
+
+		unsubscribe()
+		assert.Equal(t, 0, broker.subscribed("checkout"))
+	})
+}
+
+func Test_Messages(t *testing.T) {
+	t.Run("We should find the ResourceGroup of an Event", func(t *testing.T) {
+		aboutGroup := &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: "ResourceGroup", Name: "checkout"},
+		}
+		assert.Equal(t, "checkout", resourceGroupOfEvent(aboutGroup))
+
+		aboutDeployment := &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: "checkout"},
+			InvolvedObject: corev1.ObjectReference{Kind: "ResourceGroupDeployment", Namespace: "checkout", Name: "checkout.prod"},
+		}
+		assert.Equal(t, "checkout", resourceGroupOfEvent(aboutDeployment))
+	})
+
+	t.Run("We should report phase transitions", func(t *testing.T) {
+		before := &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "checkout", Name: "checkout.prod.database"},
+			Status:     resourcesv1alpha1.ResourceStatus{Phase: resourcesv1alpha1.DeploymentInProgressPhase},
+		}
+
+		after := before.DeepCopy()
+		after.Status.Phase = resourcesv1alpha1.DeploymentDonePhase
+
+		resourceGroup, message, ok := phaseTransition(before, after)
+
+		assert.True(t, ok)
+		assert.Equal(t, "checkout", resourceGroup)
+		assert.Equal(t, MessageTypePhaseTransition, message.Type)
+		assert.Equal(t, "Resource", message.Kind)
+		assert.Equal(t, resourcesv1alpha1.DeploymentInProgressPhase, message.PreviousPhase)
+		assert.Equal(t, resourcesv1alpha1.DeploymentDonePhase, message.Phase)
+	})
+
+	t.Run("We should ignore updates that keep the phase", func(t *testing.T) {
+		before := &resourcesv1alpha1.ResourceGroup{
+			ObjectMeta: metav1.ObjectMeta{Name: "checkout"},
+			Status:     resourcesv1alpha1.ResourceGroupStatus{Phase: resourcesv1alpha1.DeploymentDonePhase},
+		}
+
+		_, _, ok := phaseTransition(before, before.DeepCopy())
+
+		assert.False(t, ok)
+	})
+}
+
+func Test_Server(t *testing.T) {
+	t.Run("We should stream the messages of a ResourceGroup as server-sent events", func(t *testing.T) {
+		s := &Server{}
+
+		server := httptest.NewServer(s.Handler())
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/resourcegroups/checkout/events", nil)
+		assert.NoError(t, err)
+
+		response, err := http.DefaultClient.Do(request)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer response.Body.Close()
+
+		assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+		// the response headers are only flushed after the subscription
+		assert.Equal(t, 1, s.broker.subscribed("checkout"))
+
+		s.broker.Publish("checkout", Message{Type: MessageTypeEvent, Kind: "Resource", Name: "checkout.prod.database", Reason: "Provisioned"})
+
+		reader := bufio.NewReader(response.Body)
+
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "event: Event\n", line)
+
+		line, err = reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(line, "data: "))
+		assert.Contains(t, line, `"reason":"Provisioned"`)
+	})
+}
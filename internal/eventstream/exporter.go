@@ -0,0 +1,101 @@
+package eventstream
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// exporterBuffer is how many phase transitions may wait to be exported before new ones are dropped
+	exporterBuffer = 1024
+
+	// DefaultExportRetries is how many times a phase transition is sent again when the sink fails
+	DefaultExportRetries = 5
+)
+
+// ExportedMessage is a phase transition sent to a sink, with the ResourceGroup it belongs to
+type ExportedMessage struct {
+	ResourceGroup string `json:"resourceGroup"`
+	Message
+}
+
+// Sink is an external system of record of phase transitions
+type Sink interface {
+	Send(ctx context.Context, message ExportedMessage) error
+}
+
+// Exporter pushes the phase transitions of ResourceGroups, ResourceGroupDeployments and Resources to a sink, for
+// organizations that track infrastructure changes outside the cluster. Only the leader exports, so each transition is
+// sent once; a transition that keeps failing after the retries is dropped and logged, since the cluster remains the
+// source of truth.
+type Exporter struct {
+	Cache cache.Cache
+	Sink  Sink
+	// Retries is how many times a failed send is retried; zero means DefaultExportRetries
+	Retries int
+
+	queue chan ExportedMessage
+}
+
+func (e *Exporter) NeedLeaderElection() bool {
+	return true
+}
+
+func (e *Exporter) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName("status-exporter")
+
+	e.queue = make(chan ExportedMessage, exporterBuffer)
+
+	if err := watchPhaseTransitions(ctx, e.Cache, func(resourceGroup string, message Message) {
+		select {
+		case e.queue <- ExportedMessage{ResourceGroup: resourceGroup, Message: message}:
+		default:
+			log.Info(fmt.Sprintf("export queue is full; dropping phase transition of %s %s/%s to %s",
+				message.Kind, message.Namespace, message.Name, message.Phase))
+		}
+	}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case message := <-e.queue:
+			if err := e.send(ctx, message); err != nil {
+				log.Error(err, "unable to export phase transition", "kind", message.Kind, "namespace", message.Namespace,
+					"name", message.Name, "phase", message.Phase)
+			}
+		}
+	}
+}
+
+// send retries the message with an exponential backoff, from one second
+func (e *Exporter) send(ctx context.Context, message ExportedMessage) error {
+	retries := e.Retries
+	if retries <= 0 {
+		retries = DefaultExportRetries
+	}
+
+	delay := time.Second
+
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if err = e.Sink.Send(ctx, message); err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+			delay *= 2
+		}
+	}
+	return fmt.Errorf("failed after %d retries: %w", retries, err)
+}
@@ -0,0 +1,152 @@
+package eventstream
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/httpserver"
+)
+
+// +kubebuilder:rbac:groups=core,resources=events,verbs=get;list;watch
+
+// keepAliveInterval is how often a comment is written to idle streams, so proxies don't close them
+const keepAliveInterval = 30 * time.Second
+
+// Server streams the Events and phase transitions of a ResourceGroup as server-sent events, at
+// GET /resourcegroups/{name}/events. Messages are taken from the manager's informers, so every replica serves the
+// stream, leader or not; only what happens after the subscription is streamed. The stream is served over TLS, to
+// clients allowed to get the /resourcegroups/{name}/events non-resource URL by the Filter.
+type Server struct {
+	Addr    string
+	Cache   cache.Cache
+	Filter  metricsserver.Filter
+	TLSOpts []func(*tls.Config)
+
+	init   sync.Once
+	broker *Broker
+}
+
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	s.init.Do(func() { s.broker = NewBroker() })
+
+	if err := s.watch(ctx); err != nil {
+		return err
+	}
+
+	server := &httpserver.Server{Name: "event-stream", Addr: s.Addr, Handler: s.Handler(), Filter: s.Filter, TLSOpts: s.TLSOpts}
+	return server.Start(ctx)
+}
+
+// watch publishes Events and phase transitions from the informers to the broker
+func (s *Server) watch(ctx context.Context) error {
+	events, err := s.Cache.GetInformer(ctx, &corev1.Event{})
+	if err != nil {
+		return err
+	}
+
+	publishEvent := func(obj any) {
+		if event, ok := obj.(*corev1.Event); ok {
+			s.broker.Publish(resourceGroupOfEvent(event), eventMessage(event))
+		}
+	}
+	if _, err := events.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    publishEvent,
+		UpdateFunc: func(_, newObj any) { publishEvent(newObj) },
+	}); err != nil {
+		return err
+	}
+
+	return watchPhaseTransitions(ctx, s.Cache, s.broker.Publish)
+}
+
+// watchPhaseTransitions calls publish with every phase transition of ResourceGroups, ResourceGroupDeployments and
+// Resources seen by the informers
+func watchPhaseTransitions(ctx context.Context, c cache.Cache, publish func(resourceGroup string, message Message)) error {
+	for _, obj := range []client.Object{&resourcesv1alpha1.ResourceGroup{}, &resourcesv1alpha1.ResourceGroupDeployment{}, &resourcesv1alpha1.Resource{}} {
+		informer, err := c.GetInformer(ctx, obj)
+		if err != nil {
+			return err
+		}
+
+		if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj any) {
+				oldClientObj, oldOk := oldObj.(client.Object)
+				newClientObj, newOk := newObj.(client.Object)
+				if !oldOk || !newOk {
+					return
+				}
+				if resourceGroup, message, ok := phaseTransition(oldClientObj, newClientObj); ok {
+					publish(resourceGroup, message)
+				}
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handler serves the stream of each ResourceGroup
+func (s *Server) Handler() http.Handler {
+	s.init.Do(func() { s.broker = NewBroker() })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /resourcegroups/{name}/events", s.serveEvents)
+	return mux
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	messages, unsubscribe := s.broker.Subscribe(r.PathValue("name"))
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+
+		case message := <-messages:
+			data, err := json.Marshal(message)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", message.Type, data)
+			flusher.Flush()
+		}
+	}
+}
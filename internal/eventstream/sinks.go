@@ -0,0 +1,182 @@
+package eventstream
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nubank/klaudio/internal/awsauth"
+)
+
+// NewSink selects the sink of an address:
+//   - http(s)://host/path posts each message as JSON to a webhook
+//   - sqs://sqs.<region>.amazonaws.com/<account>/<queue> sends each message to an SQS queue, with the credentials of
+//     the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables
+//   - kafka://host:port/<topic> (or kafka+https://) produces each message to a topic through a Kafka REST proxy, keyed
+//     by ResourceGroup
+func NewSink(address string) (Sink, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sink address %s: %w", address, err)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	switch u.Scheme {
+	case "http", "https":
+		return &WebhookSink{URL: address, Client: client}, nil
+
+	case "sqs":
+		queue := strings.Trim(u.Path, "/")
+		if u.Host == "" || strings.Count(queue, "/") != 1 {
+			return nil, fmt.Errorf("expected sqs://<host>/<account>/<queue>, got %s", address)
+		}
+
+		region := u.Query().Get("region")
+		if region == "" {
+			// sqs.<region>.amazonaws.com
+			if parts := strings.Split(u.Host, "."); len(parts) > 2 {
+				region = parts[1]
+			}
+		}
+		if region == "" {
+			return nil, fmt.Errorf("unable to tell the region of %s; set it with ?region=", address)
+		}
+
+		return &SQSSink{
+			Endpoint:        "https://" + u.Host,
+			QueueURL:        fmt.Sprintf("https://%s/%s", u.Host, queue),
+			Region:          region,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			Client:          client,
+		}, nil
+
+	case "kafka", "kafka+https":
+		topic := strings.Trim(u.Path, "/")
+		if u.Host == "" || topic == "" {
+			return nil, fmt.Errorf("expected kafka://<host>/<topic>, got %s", address)
+		}
+
+		scheme := "http"
+		if u.Scheme == "kafka+https" {
+			scheme = "https"
+		}
+		return &KafkaSink{URL: fmt.Sprintf("%s://%s", scheme, u.Host), Topic: topic, Client: client}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported sink %s; expected http(s), sqs or kafka", u.Scheme)
+	}
+}
+
+// WebhookSink posts each message as JSON; any response other than 2xx is a failure
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *WebhookSink) Send(ctx context.Context, message ExportedMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.Client, s.URL, "application/json", body)
+}
+
+// SQSSink sends each message to an SQS queue; on FIFO queues, messages of the same ResourceGroup keep their order
+type SQSSink struct {
+	Endpoint        string
+	QueueURL        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Client          *http.Client
+}
+
+func (s *SQSSink) Send(ctx context.Context, message ExportedMessage) error {
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return fmt.Errorf("credentials to AWS are required to send messages to SQS")
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	input := map[string]any{"QueueUrl": s.QueueURL, "MessageBody": string(body)}
+	if strings.HasSuffix(s.QueueURL, ".fifo") {
+		digest := sha256.Sum256(body)
+		input["MessageGroupId"] = message.ResourceGroup
+		input["MessageDeduplicationId"] = hex.EncodeToString(digest[:])
+	}
+
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	request.Header.Set("X-Amz-Target", "AmazonSQS.SendMessage")
+
+	credentials := awsauth.Credentials{AccessKeyID: s.AccessKeyID, SecretAccessKey: s.SecretAccessKey, SessionToken: s.SessionToken}
+	if err := awsauth.SignRequest(ctx, request, payload, credentials, s.Region, "sqs", time.Now().UTC()); err != nil {
+		return fmt.Errorf("unable to sign the request to SQS: %w", err)
+	}
+
+	return do(s.Client, request)
+}
+
+// KafkaSink produces each message to a topic through the v2 API of a Kafka REST proxy
+type KafkaSink struct {
+	URL    string
+	Topic  string
+	Client *http.Client
+}
+
+func (s *KafkaSink) Send(ctx context.Context, message ExportedMessage) error {
+	body, err := json.Marshal(map[string]any{
+		"records": []map[string]any{{"key": message.ResourceGroup, "value": message}},
+	})
+	if err != nil {
+		return err
+	}
+	return post(ctx, s.Client, fmt.Sprintf("%s/topics/%s", s.URL, url.PathEscape(s.Topic)), "application/vnd.kafka.json.v2+json", body)
+}
+
+func post(ctx context.Context, c *http.Client, target string, contentType string, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	return do(c, request)
+}
+
+func do(c *http.Client, request *http.Request) error {
+	response, err := c.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("%s responded %s: %s", request.URL.Host, response.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package eventstream
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_Sinks(t *testing.T) {
+	ctx := context.TODO()
+
+	message := ExportedMessage{
+		ResourceGroup: "checkout",
+		Message: Message{
+			Type:          MessageTypePhaseTransition,
+			Kind:          "Resource",
+			Namespace:     "checkout",
+			Name:          "database",
+			PreviousPhase: resourcesv1alpha1.DeploymentInProgressPhase,
+			Phase:         resourcesv1alpha1.DeploymentDonePhase,
+		},
+	}
+
+	t.Run("We should select the sink by the scheme of the address", func(t *testing.T) {
+		sink, err := NewSink("https://records.example.com/changes")
+		assert.NoError(t, err)
+		assert.IsType(t, &WebhookSink{}, sink)
+
+		sink, err = NewSink("sqs://sqs.us-east-1.amazonaws.com/123456789012/changes.fifo")
+		assert.NoError(t, err)
+		if assert.IsType(t, &SQSSink{}, sink) {
+			assert.Equal(t, "us-east-1", sink.(*SQSSink).Region)
+			assert.Equal(t, "https://sqs.us-east-1.amazonaws.com/123456789012/changes.fifo", sink.(*SQSSink).QueueURL)
+		}
+
+		sink, err = NewSink("kafka://rest-proxy:8082/infrastructure-changes")
+		assert.NoError(t, err)
+		if assert.IsType(t, &KafkaSink{}, sink) {
+			assert.Equal(t, "http://rest-proxy:8082", sink.(*KafkaSink).URL)
+			assert.Equal(t, "infrastructure-changes", sink.(*KafkaSink).Topic)
+		}
+
+		_, err = NewSink("amqp://rabbit/changes")
+		assert.Error(t, err)
+	})
+
+	t.Run("We should post the message to a webhook", func(t *testing.T) {
+		var received map[string]any
+		webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer webhook.Close()
+
+		sink := &WebhookSink{URL: webhook.URL, Client: webhook.Client()}
+		assert.NoError(t, sink.Send(ctx, message))
+
+		assert.Equal(t, "checkout", received["resourceGroup"])
+		assert.Equal(t, "database", received["name"])
+		assert.Equal(t, string(resourcesv1alpha1.DeploymentDonePhase), received["phase"])
+	})
+
+	t.Run("We should fail when the webhook doesn't accept the message", func(t *testing.T) {
+		webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}))
+		defer webhook.Close()
+
+		sink := &WebhookSink{URL: webhook.URL, Client: webhook.Client()}
+		assert.Error(t, sink.Send(ctx, message))
+	})
+
+	t.Run("We should send the message to SQS with a signed request, grouped by ResourceGroup on FIFO queues", func(t *testing.T) {
+		sqs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "AmazonSQS.SendMessage", r.Header.Get("X-Amz-Target"))
+			assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+
+			request := make(map[string]any)
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Equal(t, "checkout", request["MessageGroupId"])
+			assert.NotEmpty(t, request["MessageDeduplicationId"])
+			assert.Contains(t, request["MessageBody"], `"resourceGroup":"checkout"`)
+
+			w.Write([]byte(`{"MessageId":"1"}`))
+		}))
+		defer sqs.Close()
+
+		sink := &SQSSink{
+			Endpoint:        sqs.URL,
+			QueueURL:        "https://sqs.us-east-1.amazonaws.com/123456789012/changes.fifo",
+			Region:          "us-east-1",
+			AccessKeyID:     "AKID",
+			SecretAccessKey: "s3cr3t",
+			Client:          sqs.Client(),
+		}
+		assert.NoError(t, sink.Send(ctx, message))
+	})
+
+	t.Run("We should produce the message to a Kafka topic keyed by ResourceGroup", func(t *testing.T) {
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/topics/infrastructure-changes", r.URL.Path)
+			assert.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+
+			request := struct {
+				Records []struct {
+					Key   string         `json:"key"`
+					Value map[string]any `json:"value"`
+				} `json:"records"`
+			}{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Len(t, request.Records, 1)
+			assert.Equal(t, "checkout", request.Records[0].Key)
+			assert.Equal(t, "database", request.Records[0].Value["name"])
+
+			w.Write([]byte(`{"offsets":[{"partition":0,"offset":1}]}`))
+		}))
+		defer proxy.Close()
+
+		sink := &KafkaSink{URL: proxy.URL, Topic: "infrastructure-changes", Client: proxy.Client()}
+		assert.NoError(t, sink.Send(ctx, message))
+	})
+}
@@ -1,20 +1,47 @@
 package cel
 
 import (
+	"context"
 	"fmt"
 	"maps"
 	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
 	"github.com/google/cel-go/ext"
-)
+	"github.com/google/cel-go/interpreter"
 
-var (
-	celExpressionRe        = regexp.MustCompile(`\$\{([^}]+)\}`)
-	resourcesExpressionRe  = regexp.MustCompile(`(resources\.[^.]+)\.`)
-	referencesExpressionRe = regexp.MustCompile(`(refs\.[^.]+)\.`)
+	"github.com/nubank/klaudio/internal/expression/names"
 )
 
+var celExpressionRe = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// Limits bound each evaluation: Cost is the runtime cost CEL accounts to the operations evaluated, Depth how deeply an
+// expression may nest, and Timeout how long an evaluation may take, checked while comprehensions, like map() or all(),
+// iterate. Zero is unlimited.
+type Limits struct {
+	Cost    uint64
+	Depth   int
+	Timeout time.Duration
+}
+
+// Option configures a CelExpression
+type Option func(*CelExpression)
+
+// WithLimits bounds the evaluations of the expression
+func WithLimits(limits Limits) Option {
+	return func(e *CelExpression) {
+		e.limits = limits
+	}
+}
+
 func SearchExpressions(expression string) []string {
 	matches := celExpressionRe.FindAllStringSubmatch(expression, -1)
 
@@ -26,70 +53,318 @@ func SearchExpressions(expression string) []string {
 	return celExpressions
 }
 
-type CelExpression string
+type CelExpression struct {
+	source string
+	limits Limits
+}
 
-func NewCelExpression(source string) (CelExpression, error) {
+func NewCelExpression(source string, options ...Option) (CelExpression, error) {
 	matches := celExpressionRe.FindStringSubmatch(source)
 
 	if len(matches) == 0 {
-		return CelExpression(""), fmt.Errorf("invalid cel expression: %s", source)
+		return CelExpression{}, fmt.Errorf("invalid cel expression: %s", source)
 	}
 
-	expression := matches[1]
+	return NewCelExpressionOf(matches[1], options...), nil
+}
 
-	return CelExpression(expression), nil
+// NewCelExpressionOf is the expression written inside ${...}
+func NewCelExpressionOf(expression string, options ...Option) CelExpression {
+	e := CelExpression{source: expression}
+	for _, option := range options {
+		option(&e)
+	}
+	return e
 }
 
 func (e CelExpression) Source() string {
-	return string(e)
+	return e.source
 }
 
+// Dependencies are the resources and refs the expression reads, as resources.<name> and refs.<name>; they are read
+// from the parsed expression, so every field selection counts, in dot or index syntax. Kebab-case names in dot syntax
+// are read as names, not subtractions. Names only known at evaluation
+// time, like resources[name], can't be told in advance.
 func (e CelExpression) Dependencies() []string {
 	dependencies := make([]string, 0)
 
-	matches := resourcesExpressionRe.FindStringSubmatch(e.Source())
-	if len(matches) != 0 {
-		dependencies = append(dependencies, matches[1])
+	environment, err := e.newEnv()
+	if err != nil {
+		return dependencies
 	}
 
-	matches = referencesExpressionRe.FindStringSubmatch(e.Source())
-	if len(matches) != 0 {
-		dependencies = append(dependencies, matches[1])
+	parsed, issues := environment.Parse(names.Bracketed(e.Source()))
+	if issues != nil && issues.Err() != nil {
+		// the expression fails to compile when it's evaluated; that's where the error is reported
+		return dependencies
 	}
 
+	ast.PreOrderVisit(parsed.NativeRep().Expr(), ast.NewExprVisitor(func(expr ast.Expr) {
+		if dependency, ok := dependencyOf(expr); ok && !slices.Contains(dependencies, dependency) {
+			dependencies = append(dependencies, dependency)
+		}
+	}))
+
 	return dependencies
 }
 
-func (e CelExpression) Evaluate(variables map[string]any) (any, error) {
+// dependencyOf tells whether the expression selects a named resource or ref: resources.name or resources["name"]
+func dependencyOf(expr ast.Expr) (string, bool) {
+	var operand ast.Expr
+	var name string
+
+	switch expr.Kind() {
+	case ast.SelectKind:
+		operand, name = expr.AsSelect().Operand(), expr.AsSelect().FieldName()
+
+	case ast.CallKind:
+		call := expr.AsCall()
+		if call.FunctionName() != operators.Index || len(call.Args()) != 2 || call.Args()[1].Kind() != ast.LiteralKind {
+			return "", false
+		}
+		key, ok := call.Args()[1].AsLiteral().(types.String)
+		if !ok {
+			return "", false
+		}
+		operand, name = call.Args()[0], string(key)
+
+	default:
+		return "", false
+	}
+
+	if operand.Kind() != ast.IdentKind || (operand.AsIdent() != "resources" && operand.AsIdent() != "refs") {
+		return "", false
+	}
+	return fmt.Sprintf("%s.%s", operand.AsIdent(), name), true
+}
+
+// References are the paths into resources and refs the expression reads, like resources.database.status.outputs.host,
+// in dot or index syntax; every prefix of a path is a reference too. Fields only tested with has() aren't read.
+func (e CelExpression) References() []string {
+	return slices.DeleteFunc(e.Variables(), func(path string) bool {
+		return !strings.HasPrefix(path, "resources.") && !strings.HasPrefix(path, "refs.")
+	})
+}
+
+// Variables are the paths into any variable the expression reads, like parameters.size or each.value, the same way
+// as References
+func (e CelExpression) Variables() []string {
+	variables := make([]string, 0)
+
+	environment, err := e.newEnv()
+	if err != nil {
+		return variables
+	}
+
+	parsed, issues := environment.Parse(names.Bracketed(e.Source()))
+	if issues != nil && issues.Err() != nil {
+		return variables
+	}
+
+	ast.PreOrderVisit(parsed.NativeRep().Expr(), ast.NewExprVisitor(func(expr ast.Expr) {
+		if expr.Kind() != ast.SelectKind && expr.Kind() != ast.CallKind {
+			return
+		}
+		path, ok := pathOf(expr)
+		if !ok || len(path) < 2 {
+			return
+		}
+		if variable := strings.Join(path, "."); !slices.Contains(variables, variable) {
+			variables = append(variables, variable)
+		}
+	}))
+
+	return variables
+}
+
+// pathOf is the path of a chain of field selections with constant names, like resources["database"].status
+func pathOf(expr ast.Expr) ([]string, bool) {
+	switch expr.Kind() {
+	case ast.IdentKind:
+		return []string{expr.AsIdent()}, true
+
+	case ast.SelectKind:
+		if expr.AsSelect().IsTestOnly() {
+			return nil, false
+		}
+		path, ok := pathOf(expr.AsSelect().Operand())
+		if !ok {
+			return nil, false
+		}
+		return append(path, expr.AsSelect().FieldName()), true
+
+	case ast.CallKind:
+		call := expr.AsCall()
+		if call.FunctionName() != operators.Index || len(call.Args()) != 2 || call.Args()[1].Kind() != ast.LiteralKind {
+			return nil, false
+		}
+		key, ok := call.Args()[1].AsLiteral().(types.String)
+		if !ok {
+			return nil, false
+		}
+		path, ok := pathOf(call.Args()[0])
+		if !ok {
+			return nil, false
+		}
+		return append(path, string(key)), true
+
+	default:
+		return nil, false
+	}
+}
+
+func (e CelExpression) Evaluate(args ...map[string]any) (any, error) {
+	variables := make(map[string]any)
+	for _, arg := range args {
+		maps.Copy(variables, arg)
+	}
+
+	_, program, err := e.program(slices.Collect(maps.Keys(variables)))
+	if err != nil {
+		return "", err
+	}
+
+	value, err := e.eval(program, variables)
+	if err != nil {
+		return "", err
+	}
+
+	return nativeOf(value), nil
+}
+
+// EvaluatePartial evaluates the expression where some paths aren't known yet, like resources.database.status.outputs.host;
+// the second result is false when the value depends on any of them. Paths that don't change the result, like the
+// other side of a short-circuited ||, are ignored.
+func (e CelExpression) EvaluatePartial(unknowns []string, args ...map[string]any) (any, bool, error) {
+	variables := make(map[string]any)
+	for _, arg := range args {
+		maps.Copy(variables, arg)
+	}
+
+	names := slices.Collect(maps.Keys(variables))
+	patterns := make([]*interpreter.AttributePattern, 0, len(unknowns))
+	for _, unknown := range unknowns {
+		path := strings.Split(unknown, ".")
+		pattern := cel.AttributePattern(path[0])
+		for _, name := range path[1:] {
+			pattern = pattern.QualString(name)
+		}
+		patterns = append(patterns, pattern)
+
+		// the root of an unknown path may be missing altogether, like resources before any resource is deployed
+		if !slices.Contains(names, path[0]) {
+			names = append(names, path[0])
+		}
+	}
+
+	_, program, err := e.program(names, cel.EvalOptions(cel.OptPartialEval))
+	if err != nil {
+		return "", false, err
+	}
+
+	activation, err := cel.PartialVars(variables, patterns...)
+	if err != nil {
+		return "", false, err
+	}
+
+	value, err := e.eval(program, activation)
+	if err != nil {
+		return "", false, err
+	}
+
+	if types.IsUnknown(value) {
+		return nil, false, nil
+	}
+	return nativeOf(value), true, nil
+}
+
+// eval runs the program within the timeout
+func (e CelExpression) eval(program cel.Program, input any) (ref.Val, error) {
+	ctx := context.Background()
+	if e.limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.limits.Timeout)
+		defer cancel()
+	}
+
+	value, _, err := program.ContextEval(ctx, input)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed evaluating expression %s: took longer than %s", e.Source(), e.limits.Timeout)
+		}
+		return nil, fmt.Errorf("failed evaluating expression %s: %w", e.Source(), err)
+	}
+	return value, nil
+}
+
+// newEnv creates an environment whose parser refuses expressions nested deeper than the depth limit
+func (e CelExpression) newEnv(options ...cel.EnvOption) (*cel.Env, error) {
+	if e.limits.Depth > 0 {
+		options = append(options, cel.ParserRecursionLimit(e.limits.Depth))
+	}
+	return cel.NewEnv(options...)
+}
+
+// program compiles the expression to an environment declaring each variable as dynamic
+func (e CelExpression) program(variables []string, options ...cel.ProgramOption) (*cel.Env, cel.Program, error) {
 	celEnvironmentOpts := make([]cel.EnvOption, 0)
 	celEnvironmentOpts = append(celEnvironmentOpts,
 		ext.Lists(),
 		ext.Strings(),
 	)
-	for k := range maps.Keys(variables) {
-		celEnvironmentOpts = append(celEnvironmentOpts, cel.Variable(k, cel.AnyType))
+	for _, k := range variables {
+		celEnvironmentOpts = append(celEnvironmentOpts, cel.Variable(k, cel.DynType))
 	}
-	environment, err := cel.NewEnv(celEnvironmentOpts...)
+	environment, err := e.newEnv(celEnvironmentOpts...)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
 	source := e.Source()
 
-	checkedAst, issues := environment.Compile(source)
+	checkedAst, issues := environment.Compile(names.Bracketed(source))
 	if issues != nil && issues.Err() != nil {
-		return "", fmt.Errorf("failed compiling expression %s: %w", source, issues.Err())
+		return nil, nil, fmt.Errorf("failed compiling expression %s: %w", source, issues.Err())
 	}
 
-	program, err := environment.Program(checkedAst)
-	if err != nil {
-		return "", fmt.Errorf("failed programming expression %s: %w", source, err)
+	if e.limits.Cost > 0 {
+		options = append(options, cel.CostLimit(e.limits.Cost))
+	}
+	if e.limits.Timeout > 0 {
+		options = append(options, cel.InterruptCheckFrequency(100))
 	}
 
-	value, _, err := program.Eval(variables)
+	program, err := environment.Program(checkedAst, options...)
 	if err != nil {
-		return "", fmt.Errorf("failed evaluating expression %s: %w", source, err)
+		return nil, nil, fmt.Errorf("failed programming expression %s: %w", source, err)
 	}
 
-	return value.Value(), nil
+	return environment, program, nil
+}
+
+// nativeOf converts a CEL value to Go: maps become map[string]any and lists []any, all the way down, so whole objects
+// can be written to properties
+func nativeOf(value ref.Val) any {
+	switch v := value.(type) {
+	case traits.Mapper:
+		m := make(map[string]any)
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			key := it.Next()
+			m[fmt.Sprintf("%v", key.Value())] = nativeOf(v.Get(key))
+		}
+		return m
+
+	case traits.Lister:
+		l := make([]any, 0)
+		for it := v.Iterator(); it.HasNext() == types.True; {
+			l = append(l, nativeOf(it.Next()))
+		}
+		return l
+
+	case types.Null:
+		return nil
+
+	default:
+		return v.Value()
+	}
 }
@@ -0,0 +1,118 @@
+package cel
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CelExpression(t *testing.T) {
+
+	t.Run("We should be able to eval an expression to a whole object", func(t *testing.T) {
+		expression, err := NewCelExpression("${refs.config.data}")
+
+		assert.NoError(t, err)
+
+		variables := map[string]any{
+			"refs": map[string]any{
+				"config": map[string]any{
+					"data": map[string]any{"region": "us-east-1", "zones": []any{"a", "b"}},
+				},
+			},
+		}
+
+		r, err := expression.Evaluate(variables)
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]any{"region": "us-east-1", "zones": []any{"a", "b"}}, r)
+	})
+}
+
+func Test_CelExpressionDependencies(t *testing.T) {
+
+	t.Run("We should be able to read every dependency of an expression", func(t *testing.T) {
+		expression, err := NewCelExpression(`${resources.database.host + ":" + string(resources["database-port"].port) + refs.settings.path + resources.database.name}`)
+
+		assert.NoError(t, err)
+
+		dependencies := expression.Dependencies()
+
+		assert.Equal(t, []string{"resources.database", "resources.database-port", "refs.settings"}, dependencies)
+	})
+
+	t.Run("We should read kebab-case names in dot syntax as names, not subtractions", func(t *testing.T) {
+		expression, err := NewCelExpression(`${resources.resource-one.value + secrets.database-credentials.password}`)
+
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{"resources.resource-one"}, expression.Dependencies())
+
+		r, err := expression.Evaluate(map[string]any{
+			"resources": map[string]any{"resource-one": map[string]any{"value": "one-"}},
+			"secrets":   map[string]any{"database-credentials": map[string]any{"password": "s3cr3t"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "one-s3cr3t", r)
+	})
+
+	t.Run("Names only known at evaluation time aren't dependencies", func(t *testing.T) {
+		expression, err := NewCelExpression(`${resources[name].host}`)
+
+		assert.NoError(t, err)
+
+		assert.Empty(t, expression.Dependencies())
+	})
+
+	t.Run("Constant expressions doesn't have dependencies", func(t *testing.T) {
+		expression, err := NewCelExpression(`${"hello"}`)
+
+		assert.NoError(t, err)
+
+		assert.Empty(t, expression.Dependencies())
+	})
+}
+
+func Test_CelExpressionLimits(t *testing.T) {
+
+	items := make([]any, 3000)
+	for i := range items {
+		items[i] = i
+	}
+	variables := map[string]any{"items": items}
+
+	t.Run("We should fail evaluations above the cost limit", func(t *testing.T) {
+		expression, err := NewCelExpression("${items.all(x, x >= 0)}", WithLimits(Limits{Cost: 100}))
+		assert.NoError(t, err)
+
+		_, err = expression.Evaluate(variables)
+		assert.ErrorContains(t, err, "cost limit exceeded")
+	})
+
+	t.Run("We should refuse expressions nested deeper than the limit", func(t *testing.T) {
+		limits := WithLimits(Limits{Depth: 32})
+
+		expression, err := NewCelExpression("${(1 + 1) * 2}", limits)
+		assert.NoError(t, err)
+
+		r, err := expression.Evaluate()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), r)
+
+		expression, err = NewCelExpression(fmt.Sprintf("${%s1%s}", strings.Repeat("(", 100), strings.Repeat(")", 100)), limits)
+		assert.NoError(t, err)
+
+		_, err = expression.Evaluate()
+		assert.ErrorContains(t, err, "failed compiling expression")
+	})
+
+	t.Run("We should interrupt evaluations taking longer than the timeout", func(t *testing.T) {
+		expression, err := NewCelExpression("${items.all(x, items.all(y, x + y >= 0))}", WithLimits(Limits{Timeout: 10 * time.Millisecond}))
+		assert.NoError(t, err)
+
+		_, err = expression.Evaluate(variables)
+		assert.ErrorContains(t, err, "took longer than 10ms")
+	})
+}
@@ -4,18 +4,46 @@ import (
 	"fmt"
 	"maps"
 	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+	"github.com/expr-lang/expr/vm"
+
+	"github.com/nubank/klaudio/internal/expression/names"
 )
 
-var (
-	exprExpressionRe       = regexp.MustCompile(`\$\{([^}]+)\}`)
-	resourcesExpressionRe  = regexp.MustCompile(`(resources\.[^.]+)\.`)
-	referencesExpressionRe = regexp.MustCompile(`(refs\.[^.]+)\.`)
+var exprExpressionRe = regexp.MustCompile(`\$\{([^}]+)\}`)
 
-	resourcesEscapedExpressionRe  = regexp.MustCompile(`(resources)\["([^"]+)"\]`)
-	referencesEscapedExpressionRe = regexp.MustCompile(`(refs)\["([^"]+)"\]`)
-)
+// Limits bound each evaluation: Depth is how deeply parentheses, brackets and braces may nest, Nodes how many nodes the
+// syntax tree may have, and Timeout how long an evaluation may take. Expr can't interrupt a program, so one that times
+// out is abandoned to run to its end in the background, still bounded by the memory budget of the Expr VM; at most
+// MaxInFlight evaluations with a timeout run at once, counting the abandoned ones. Zero is unlimited.
+type Limits struct {
+	Depth   int
+	Nodes   int
+	Timeout time.Duration
+}
+
+// MaxInFlight is how many evaluations with a timeout may run at once
+const MaxInFlight = 64
+
+// inFlight holds a slot to each evaluation with a timeout until its program ends, even after the timeout; otherwise
+// every reconcile of a slow expression would leave one more program running
+var inFlight = make(chan struct{}, MaxInFlight)
+
+// Option configures an ExprExpression
+type Option func(*ExprExpression)
+
+// WithLimits bounds the evaluations of the expression
+func WithLimits(limits Limits) Option {
+	return func(e *ExprExpression) {
+		e.limits = limits
+	}
+}
 
 func SearchExpressions(expression string) []string {
 	matches := exprExpressionRe.FindAllStringSubmatch(expression, -1)
@@ -28,50 +56,139 @@ func SearchExpressions(expression string) []string {
 	return expressions
 }
 
-type ExprExpression string
+type ExprExpression struct {
+	source string
+	limits Limits
+}
 
-func NewExprExpression(source string) (ExprExpression, error) {
+func NewExprExpression(source string, options ...Option) (ExprExpression, error) {
 	matches := exprExpressionRe.FindStringSubmatch(source)
 
 	if len(matches) == 0 {
-		return ExprExpression(""), fmt.Errorf("invalid Expr expression: %s", source)
+		return ExprExpression{}, fmt.Errorf("invalid Expr expression: %s", source)
 	}
 
-	expression := matches[1]
+	return NewExprExpressionOf(matches[1], options...), nil
+}
 
-	return ExprExpression(expression), nil
+// NewExprExpressionOf is the expression written inside ${...}
+func NewExprExpressionOf(expression string, options ...Option) ExprExpression {
+	e := ExprExpression{source: expression}
+	for _, option := range options {
+		option(&e)
+	}
+	return e
 }
 
 func (e ExprExpression) Source() string {
-	return string(e)
+	return e.source
 }
 
+// Dependencies are the resources and refs the expression reads, as resources.<name> and refs.<name>; they are read
+// from the syntax tree, so every member access counts, in dot or bracket syntax. Kebab-case names in dot syntax are
+// read as names, not subtractions. Names only known at evaluation time,
+// like resources[name], can't be told in advance.
 func (e ExprExpression) Dependencies() []string {
-	dependencies := make([]string, 0)
+	tree, err := parser.Parse(names.Bracketed(e.Source()))
+	if err != nil {
+		// the expression fails to compile when it's evaluated; that's where the error is reported
+		return make([]string, 0)
+	}
+
+	visitor := &dependenciesVisitor{dependencies: make([]string, 0)}
+	ast.Walk(&tree.Node, visitor)
 
-	matches := resourcesExpressionRe.FindStringSubmatch(e.Source())
-	if len(matches) != 0 {
-		dependencies = append(dependencies, matches[1])
+	return visitor.dependencies
+}
+
+type dependenciesVisitor struct {
+	dependencies []string
+}
+
+func (v *dependenciesVisitor) Visit(node *ast.Node) {
+	member, ok := (*node).(*ast.MemberNode)
+	if !ok {
+		return
 	}
 
-	matches = referencesExpressionRe.FindStringSubmatch(e.Source())
-	if len(matches) != 0 {
-		dependencies = append(dependencies, matches[1])
+	identifier, ok := member.Node.(*ast.IdentifierNode)
+	if !ok || (identifier.Value != "resources" && identifier.Value != "refs") {
+		return
 	}
 
-	if len(dependencies) == 0 {
-		matches = resourcesEscapedExpressionRe.FindStringSubmatch(e.Source())
-		if len(matches) > 2 {
-			dependencies = append(dependencies, fmt.Sprintf("%s.%s", matches[1], matches[2]))
-		}
+	property, ok := member.Property.(*ast.StringNode)
+	if !ok {
+		return
+	}
 
-		matches = referencesEscapedExpressionRe.FindStringSubmatch(e.Source())
-		if len(matches) > 2 {
-			dependencies = append(dependencies, fmt.Sprintf("%s.%s", matches[1], matches[2]))
-		}
+	dependency := fmt.Sprintf("%s.%s", identifier.Value, property.Value)
+	if !slices.Contains(v.dependencies, dependency) {
+		v.dependencies = append(v.dependencies, dependency)
+	}
+}
+
+// References are the paths into resources and refs the expression reads, like resources.database.status.outputs.host,
+// in dot or bracket syntax; every prefix of a path is a reference too
+func (e ExprExpression) References() []string {
+	return slices.DeleteFunc(e.Variables(), func(path string) bool {
+		return !strings.HasPrefix(path, "resources.") && !strings.HasPrefix(path, "refs.")
+	})
+}
+
+// Variables are the paths into any variable the expression reads, like parameters.size or each.value, the same way
+// as References
+func (e ExprExpression) Variables() []string {
+	tree, err := parser.Parse(names.Bracketed(e.Source()))
+	if err != nil {
+		return make([]string, 0)
+	}
+
+	visitor := &variablesVisitor{variables: make([]string, 0)}
+	ast.Walk(&tree.Node, visitor)
+
+	return visitor.variables
+}
+
+type variablesVisitor struct {
+	variables []string
+}
+
+func (v *variablesVisitor) Visit(node *ast.Node) {
+	if _, ok := (*node).(*ast.MemberNode); !ok {
+		return
+	}
+
+	path, ok := pathOf(*node)
+	if !ok || len(path) < 2 {
+		return
+	}
+
+	variable := strings.Join(path, ".")
+	if !slices.Contains(v.variables, variable) {
+		v.variables = append(v.variables, variable)
 	}
+}
+
+// pathOf is the path of a chain of member accesses with constant names, like resources["database"].status
+func pathOf(node ast.Node) ([]string, bool) {
+	switch n := node.(type) {
+	case *ast.IdentifierNode:
+		return []string{n.Value}, true
+
+	case *ast.MemberNode:
+		property, ok := n.Property.(*ast.StringNode)
+		if !ok {
+			return nil, false
+		}
+		path, ok := pathOf(n.Node)
+		if !ok {
+			return nil, false
+		}
+		return append(path, property.Value), true
 
-	return dependencies
+	default:
+		return nil, false
+	}
 }
 
 func (e ExprExpression) Evaluate(args ...map[string]any) (any, error) {
@@ -82,15 +199,115 @@ func (e ExprExpression) Evaluate(args ...map[string]any) (any, error) {
 
 	source := e.Source()
 
-	program, err := expr.Compile(source, expr.Env(allArgs))
+	if depth := nestingOf(source); e.limits.Depth > 0 && depth > e.limits.Depth {
+		return "", fmt.Errorf("failed compiling expression %s: nested %d levels deep, above the limit of %d", source, depth, e.limits.Depth)
+	}
+
+	if e.limits.Nodes > 0 {
+		if nodes := nodesOf(source); nodes > e.limits.Nodes {
+			return "", fmt.Errorf("failed compiling expression %s: %d nodes, above the limit of %d", source, nodes, e.limits.Nodes)
+		}
+	}
+
+	program, err := expr.Compile(names.Bracketed(source), append([]expr.Option{expr.Env(allArgs)}, functions...)...)
 	if err != nil {
 		return "", fmt.Errorf("failed compiling expression %s: %w", source, err)
 	}
 
-	value, err := expr.Run(program, allArgs)
+	value, err := run(program, allArgs, e.limits.Timeout)
 	if err != nil {
 		return "", fmt.Errorf("failed evaluating expression %s: %w", source, err)
 	}
 
 	return value, nil
 }
+
+// run runs the program within the timeout; waiting for a slot of inFlight counts to the timeout
+func run(program *vm.Program, env map[string]any, timeout time.Duration) (any, error) {
+	if timeout <= 0 {
+		return expr.Run(program, env)
+	}
+
+	type result struct {
+		value any
+		err   error
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case inFlight <- struct{}{}:
+	case <-timer.C:
+		return nil, fmt.Errorf("took longer than %s waiting for the %d evaluations in flight", timeout, MaxInFlight)
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		defer func() { <-inFlight }()
+		value, err := expr.Run(program, env)
+		done <- result{value: value, err: err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-timer.C:
+		return nil, fmt.Errorf("took longer than %s", timeout)
+	}
+}
+
+// nodesOf is how many nodes the syntax tree of the source has; a source that doesn't parse has none, and fails to
+// compile instead
+func nodesOf(source string) int {
+	tree, err := parser.Parse(names.Bracketed(source))
+	if err != nil {
+		return 0
+	}
+
+	visitor := &nodesVisitor{}
+	ast.Walk(&tree.Node, visitor)
+
+	return visitor.nodes
+}
+
+type nodesVisitor struct {
+	nodes int
+}
+
+func (v *nodesVisitor) Visit(*ast.Node) {
+	v.nodes++
+}
+
+// nestingOf is how deeply parentheses, brackets and braces nest in the source, outside of string literals; the
+// parser recurses once to each level
+func nestingOf(source string) int {
+	depth, deepest := 0, 0
+	var quote rune
+	escaped := false
+
+	for _, c := range source {
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		case '(', '[', '{':
+			depth++
+			deepest = max(deepest, depth)
+		case ')', ']', '}':
+			depth--
+		}
+	}
+	return deepest
+}
@@ -1,7 +1,9 @@
 package expr
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -111,3 +113,129 @@ func Test_ExprExpression(t *testing.T) {
 
 	})
 }
+
+func Test_ExprExpressionDependencies(t *testing.T) {
+
+	t.Run("We should read kebab-case names in dot syntax as names, not subtractions", func(t *testing.T) {
+		expression, err := NewExprExpression("${resources.resource-one.value + refs.app-config.data.owner}")
+
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{"resources.resource-one", "refs.app-config"}, expression.Dependencies())
+
+		r, err := expression.Evaluate(map[string]any{
+			"resources": map[string]any{"resource-one": map[string]any{"value": "one-"}},
+			"refs":      map[string]any{"app-config": map[string]any{"data": map[string]any{"owner": "checkout"}}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "one-checkout", r)
+	})
+}
+
+func Test_ExprExpressionCIDRFunctions(t *testing.T) {
+
+	variables := map[string]any{
+		"parameters": map[string]any{
+			"vpc":  "10.0.0.0/16",
+			"zone": float64(2),
+		},
+	}
+
+	t.Run("We should be able to carve a subnet from a parameter", func(t *testing.T) {
+		expression, err := NewExprExpression(`${cidrsubnet(parameters.vpc, 8, parameters.zone)}`)
+		assert.NoError(t, err)
+
+		r, err := expression.Evaluate(variables)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.2.0/24", r)
+	})
+
+	t.Run("We should be able to carve many subnets at once", func(t *testing.T) {
+		expression, err := NewExprExpression(`${cidrsubnets(parameters.vpc, 4, 4, 8)}`)
+		assert.NoError(t, err)
+
+		r, err := expression.Evaluate(variables)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.0/20", "10.0.16.0/20", "10.0.32.0/24"}, r)
+	})
+
+	t.Run("We should be able to find a host and a netmask", func(t *testing.T) {
+		expression, err := NewExprExpression(`${cidrhost(cidrsubnet(parameters.vpc, 8, 1), 10) + "/" + cidrnetmask("10.0.1.0/24")}`)
+		assert.NoError(t, err)
+
+		r, err := expression.Evaluate(variables)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.1.10/255.255.255.0", r)
+	})
+}
+
+func Test_ExprExpressionLimits(t *testing.T) {
+
+	t.Run("We should refuse expressions nested deeper than the limit", func(t *testing.T) {
+		limits := WithLimits(Limits{Depth: 3})
+
+		assert.Equal(t, 3, nestingOf(`(a[(0)] + (b))["(((("]`))
+
+		expression, err := NewExprExpression(`${((1 + 1))}`, limits)
+		assert.NoError(t, err)
+
+		r, err := expression.Evaluate()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, r)
+
+		expression, err = NewExprExpression(`${((((1 + 1))))}`, limits)
+		assert.NoError(t, err)
+
+		_, err = expression.Evaluate()
+		assert.ErrorContains(t, err, "above the limit of 3")
+	})
+
+	t.Run("We should refuse expressions with more nodes than the limit", func(t *testing.T) {
+		limits := WithLimits(Limits{Nodes: 5})
+
+		expression, err := NewExprExpression(`${1 + 2 + 3}`, limits)
+		assert.NoError(t, err)
+
+		r, err := expression.Evaluate()
+		assert.NoError(t, err)
+		assert.Equal(t, 6, r)
+
+		expression, err = NewExprExpression(`${1 + 2 + 3 + 4 + 5}`, limits)
+		assert.NoError(t, err)
+
+		_, err = expression.Evaluate()
+		assert.ErrorContains(t, err, "above the limit of 5")
+	})
+
+	t.Run("We should not start more evaluations than the ones allowed in flight", func(t *testing.T) {
+		for range MaxInFlight {
+			inFlight <- struct{}{}
+		}
+		defer func() {
+			for range MaxInFlight {
+				<-inFlight
+			}
+		}()
+
+		expression, err := NewExprExpression(`${1 + 1}`, WithLimits(Limits{Timeout: 10 * time.Millisecond}))
+		assert.NoError(t, err)
+
+		_, err = expression.Evaluate()
+		assert.ErrorContains(t, err, "evaluations in flight")
+	})
+
+	t.Run("We should give up on evaluations taking longer than the timeout", func(t *testing.T) {
+		items := make([]int, 3000)
+
+		expression, err := NewExprExpression(`${all(items, all(items, # >= 0))}`, WithLimits(Limits{Timeout: 10 * time.Millisecond}))
+		assert.NoError(t, err)
+
+		_, err = expression.Evaluate(map[string]any{"items": items})
+		if assert.Error(t, err) {
+			assert.True(t, strings.HasSuffix(err.Error(), "took longer than 10ms"))
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/expr-lang/expr"
+
+	"github.com/nubank/klaudio/internal/cidr"
+)
+
+// functions are the helpers available to every expression, besides the Expr builtins
+var functions = []expr.Option{
+	// the builtin changes on every render, which would change properties on every deployment; a stable now() is
+	// declared by the scope of deployments instead
+	expr.DisableBuiltin("now"),
+
+	expr.Function("cidrhost", func(params ...any) (any, error) {
+		hostnum, err := toInt(params[1])
+		if err != nil {
+			return nil, err
+		}
+		return cidr.Host(params[0].(string), hostnum)
+	}, new(func(string, any) string)),
+
+	expr.Function("cidrnetmask", func(params ...any) (any, error) {
+		return cidr.Netmask(params[0].(string))
+	}, new(func(string) string)),
+
+	expr.Function("cidrsubnet", func(params ...any) (any, error) {
+		newbits, err := toInt(params[1])
+		if err != nil {
+			return nil, err
+		}
+		netnum, err := toInt(params[2])
+		if err != nil {
+			return nil, err
+		}
+		return cidr.Subnet(params[0].(string), newbits, netnum)
+	}, new(func(string, any, any) string)),
+
+	expr.Function("cidrsubnets", func(params ...any) (any, error) {
+		newbits := make([]int, 0, len(params)-1)
+		for _, p := range params[1:] {
+			n, err := toInt(p)
+			if err != nil {
+				return nil, err
+			}
+			newbits = append(newbits, n)
+		}
+		return cidr.Subnets(params[0].(string), newbits...)
+	}, new(func(string, ...any) []string)),
+}
+
+// toInt accepts any number, since parameters decoded from JSON are always float64
+func toInt(value any) (int, error) {
+	switch n := value.(type) {
+	case int:
+		return n, nil
+	case int32:
+		return int(n), nil
+	case int64:
+		return int(n), nil
+	case float32:
+		return toInt(float64(n))
+	case float64:
+		if n != math.Trunc(n) {
+			return 0, fmt.Errorf("%v is not an integer", n)
+		}
+		return int(n), nil
+	default:
+		return 0, fmt.Errorf("%v is not a number", value)
+	}
+}
@@ -1,9 +1,13 @@
 package expression
 
 import (
+	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/nubank/klaudio/internal/expression/cel"
 	"github.com/nubank/klaudio/internal/expression/expr"
 )
 
@@ -18,24 +22,118 @@ type Expression interface {
 	Dependencies() []string
 }
 
+// Language is the language ${...} expressions are written in
+type Language string
+
+const (
+	LanguageExpr Language = "expr"
+	LanguageCEL  Language = "cel"
+)
+
+var defaultLanguage = LanguageExpr
+
+// SetDefaultLanguage chooses the language of expressions parsed without one, like the ones of a ResourceGroup that
+// doesn't declare its own
+func SetDefaultLanguage(language Language) error {
+	switch language {
+	case LanguageExpr, LanguageCEL:
+		defaultLanguage = language
+		return nil
+	default:
+		return fmt.Errorf("unsupported expression language %s; expected expr or cel", language)
+	}
+}
+
+// DefaultLanguage is the language of expressions parsed without one
+func DefaultLanguage() Language {
+	return defaultLanguage
+}
+
+// Limits bound the evaluation of each expression, so a pathological ResourceGroup can't stall a controller worker;
+// zero values are unlimited
+type Limits struct {
+	// Cost is the runtime cost a CEL expression may spend, as CEL accounts it to the operations evaluated; Expr
+	// expressions are bound by the memory budget of the Expr VM instead
+	Cost uint64
+	// Depth is how deeply an expression may nest
+	Depth int
+	// Nodes is how many nodes the syntax tree of an Expr expression may have; CEL expressions are bound by Cost
+	Nodes int
+	// Timeout is how long an evaluation may take
+	Timeout time.Duration
+}
+
+// DefaultLimits are generous to any expression written by hand
+var DefaultLimits = Limits{Cost: 1_000_000, Depth: 32, Nodes: 10_000, Timeout: time.Second}
+
+// limits bound the expressions parsed from now on; unlimited until SetLimits is called
+var limits Limits
+
+// SetLimits bounds the evaluation of the expressions parsed from now on, in every language
+func SetLimits(l Limits) {
+	limits = l
+}
+
+// Parse reads an expression in the default language
 func Parse(expression any) (Expression, error) {
+	return ParseWith("", expression)
+}
+
+// ParseWith reads an expression in a language; an empty language is the default one
+func ParseWith(language Language, expression any) (Expression, error) {
+	if language == "" {
+		language = defaultLanguage
+	}
+
 	expressionAsString, ok := expression.(string)
 	if !ok {
-		return SimpleExpression(fmt.Sprintf("%s", expression)), nil
+		return ConstantExpression{value: expression}, nil
 	}
 
-	expressions := expr.SearchExpressions(expressionAsString)
+	var expressions []string
+	switch language {
+	case LanguageExpr:
+		expressions = expr.SearchExpressions(expressionAsString)
+	case LanguageCEL:
+		expressions = cel.SearchExpressions(expressionAsString)
+	default:
+		return nil, fmt.Errorf("unsupported expression language %s; expected expr or cel", language)
+	}
 
 	if len(expressions) == 0 {
 		return SimpleExpression(expressionAsString), nil
 	}
 
 	if len(expressions) == 1 && strings.HasPrefix(expressionAsString, StartToken) {
-		return expr.NewExprExpression(expressionAsString)
+		return newSingleExpression(language, expressionAsString)
+	}
+
+	return newCompositeExpression(language, expressionAsString, expressions)
+}
+
+// IsSingle tells whether the expression is a single ${...}, which keeps the type of its result
+func IsSingle(e Expression) bool {
+	switch e.(type) {
+	case expr.ExprExpression, cel.CelExpression:
+		return true
+	default:
+		return false
 	}
+}
 
-	return newCompositeExpression(expressionAsString, expressions)
+func newSingleExpression(language Language, source string) (Expression, error) {
+	if language == LanguageCEL {
+		return cel.NewCelExpression(source, celLimits())
+	}
+	return expr.NewExprExpression(source, exprLimits())
+}
 
+func celLimits() cel.Option {
+	return cel.WithLimits(cel.Limits{Cost: limits.Cost, Depth: limits.Depth, Timeout: limits.Timeout})
+}
+
+func exprLimits() expr.Option {
+	return expr.WithLimits(expr.Limits{Depth: limits.Depth, Nodes: limits.Nodes, Timeout: limits.Timeout})
 }
 
 func noDependencies() []string {
@@ -56,15 +154,39 @@ func (e SimpleExpression) Dependencies() []string {
 	return noDependencies()
 }
 
+// ConstantExpression is a value other than a string, like a number or a boolean; it's evaluated as is
+type ConstantExpression struct {
+	value any
+}
+
+func (e ConstantExpression) Source() string {
+	return fmt.Sprintf("%v", e.value)
+}
+
+func (e ConstantExpression) Evaluate(args ...map[string]any) (any, error) {
+	return e.value, nil
+}
+
+func (e ConstantExpression) Dependencies() []string {
+	return noDependencies()
+}
+
+// CompositeExpression interpolates expressions into a string. A single expression, with nothing around it, keeps the
+// type of its result; here every result becomes text: strings as they are, nil as nothing, and anything else, like
+// numbers, booleans, lists and maps, as JSON.
 type CompositeExpression struct {
 	source      string
 	expressions []Expression
 }
 
-func newCompositeExpression(expression string, expressions []string) (CompositeExpression, error) {
+func newCompositeExpression(language Language, expression string, expressions []string) (CompositeExpression, error) {
 	checkedExpressions := make([]Expression, 0)
 	for _, e := range expressions {
-		checkedExpressions = append(checkedExpressions, expr.ExprExpression(e))
+		if language == LanguageCEL {
+			checkedExpressions = append(checkedExpressions, cel.NewCelExpressionOf(e, celLimits()))
+		} else {
+			checkedExpressions = append(checkedExpressions, expr.NewExprExpressionOf(e, exprLimits()))
+		}
 	}
 
 	return CompositeExpression{source: expression, expressions: checkedExpressions}, nil
@@ -75,22 +197,158 @@ func (e CompositeExpression) Source() string {
 }
 
 func (e CompositeExpression) Evaluate(args ...map[string]any) (any, error) {
+	return e.evaluate(func(expression Expression) (any, error) {
+		return expression.Evaluate(args...)
+	})
+}
+
+func (e CompositeExpression) evaluate(evaluate func(Expression) (any, error)) (any, error) {
 	s := e.source
 	for _, expression := range e.expressions {
-		r, err := expression.Evaluate(args...)
+		r, err := evaluate(expression)
 		if err != nil {
 			return "", err
 		}
+		text, err := stringOf(r)
+		if err != nil {
+			return "", fmt.Errorf("failed interpolating expression %s: %w", expression.Source(), err)
+		}
 		fragment := StartToken + expression.Source() + EndToken
-		s = strings.Replace(s, fragment, fmt.Sprintf("%s", r), -1)
+		s = strings.Replace(s, fragment, text, -1)
 	}
 	return s, nil
 }
 
 func (e CompositeExpression) Dependencies() []string {
-	dependencies := make([]string, len(e.expressions))
+	dependencies := make([]string, 0, len(e.expressions))
 	for _, expression := range e.expressions {
-		dependencies = append(dependencies, expression.Dependencies()...)
+		for _, dependency := range expression.Dependencies() {
+			if !slices.Contains(dependencies, dependency) {
+				dependencies = append(dependencies, dependency)
+			}
+		}
 	}
 	return dependencies
 }
+
+func (e CompositeExpression) References() []string {
+	references := make([]string, 0)
+	for _, expression := range e.expressions {
+		for _, reference := range ReferencesOf(expression) {
+			if !slices.Contains(references, reference) {
+				references = append(references, reference)
+			}
+		}
+	}
+	return references
+}
+
+func (e CompositeExpression) Variables() []string {
+	variables := make([]string, 0)
+	for _, expression := range e.expressions {
+		for _, variable := range VariablesOf(expression) {
+			if !slices.Contains(variables, variable) {
+				variables = append(variables, variable)
+			}
+		}
+	}
+	return variables
+}
+
+// stringOf is the text of an expression result interpolated into a string
+func stringOf(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
+
+// UnknownError means the result of an expression depends on values that aren't known yet, like outputs a resource
+// hasn't produced
+type UnknownError struct {
+	Unknowns []string
+}
+
+func (e *UnknownError) Error() string {
+	return fmt.Sprintf("waiting on %s", strings.Join(e.Unknowns, ", "))
+}
+
+// ReferencesOf are the paths into resources and refs an expression reads, like resources.database.status.outputs.host;
+// only the longest ones are kept, so a path isn't listed along with its prefixes
+func ReferencesOf(e Expression) []string {
+	referencer, ok := e.(interface{ References() []string })
+	if !ok {
+		return make([]string, 0)
+	}
+	return longestPathsOf(referencer.References())
+}
+
+// VariablesOf are the paths into any variable an expression reads, like parameters.size or
+// resources.database.status.outputs.host; as in ReferencesOf, only the longest ones are kept
+func VariablesOf(e Expression) []string {
+	reader, ok := e.(interface{ Variables() []string })
+	if !ok {
+		return make([]string, 0)
+	}
+	return longestPathsOf(reader.Variables())
+}
+
+func longestPathsOf(all []string) []string {
+	paths := make([]string, 0, len(all))
+	for _, path := range all {
+		isPrefix := slices.ContainsFunc(all, func(other string) bool {
+			return strings.HasPrefix(other, path+".")
+		})
+		if !isPrefix && !slices.Contains(paths, path) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// EvaluateWithUnknowns evaluates an expression where some of the paths it reads aren't known yet, failing with an
+// UnknownError when the result depends on them. CEL expressions are partially evaluated, so unknowns that don't change
+// the result are ignored; other expressions depend on them when they fail or result in nothing.
+func EvaluateWithUnknowns(e Expression, unknowns []string, args ...map[string]any) (any, error) {
+	references := ReferencesOf(e)
+	read := slices.DeleteFunc(slices.Clone(unknowns), func(unknown string) bool {
+		return !slices.Contains(references, unknown)
+	})
+	if len(read) == 0 {
+		return e.Evaluate(args...)
+	}
+
+	switch e := e.(type) {
+	case CompositeExpression:
+		return e.evaluate(func(expression Expression) (any, error) {
+			return EvaluateWithUnknowns(expression, read, args...)
+		})
+
+	case cel.CelExpression:
+		value, known, err := e.EvaluatePartial(read, args...)
+		if err != nil {
+			return nil, err
+		}
+		if !known {
+			return nil, &UnknownError{Unknowns: read}
+		}
+		return value, nil
+
+	default:
+		value, err := e.Evaluate(args...)
+		if err != nil || value == nil {
+			return nil, &UnknownError{Unknowns: read}
+		}
+		return value, nil
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/nubank/klaudio/internal/expression/cel"
 )
 
 func Test_Expression(t *testing.T) {
@@ -159,6 +161,76 @@ func Test_Expression(t *testing.T) {
 	})
 }
 
+func Test_ExpressionResults(t *testing.T) {
+
+	variables := map[string]any{
+		"refs": map[string]any{
+			"config": map[string]any{
+				"data": map[string]any{"region": "us-east-1", "replicas": 3},
+				"tags": []any{"checkout", "prod"},
+			},
+		},
+	}
+
+	t.Run("A single expression should keep the type of its result", func(t *testing.T) {
+
+		t.Run("...for maps", func(t *testing.T) {
+			expression, err := Parse("${refs.config.data}")
+
+			assert.NoError(t, err)
+
+			r, err := expression.Evaluate(variables)
+
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]any{"region": "us-east-1", "replicas": 3}, r)
+		})
+
+		t.Run("...for lists", func(t *testing.T) {
+			expression, err := Parse("${refs.config.tags}")
+
+			assert.NoError(t, err)
+
+			r, err := expression.Evaluate(variables)
+
+			assert.NoError(t, err)
+			assert.Equal(t, []any{"checkout", "prod"}, r)
+		})
+
+		t.Run("...and for numbers", func(t *testing.T) {
+			expression, err := Parse("${refs.config.data.replicas * 2}")
+
+			assert.NoError(t, err)
+
+			r, err := expression.Evaluate(variables)
+
+			assert.NoError(t, err)
+			assert.Equal(t, 6, r)
+		})
+	})
+
+	t.Run("Values other than strings should be kept as they are", func(t *testing.T) {
+		expression, err := Parse(true)
+
+		assert.NoError(t, err)
+
+		r, err := expression.Evaluate()
+
+		assert.NoError(t, err)
+		assert.Equal(t, true, r)
+	})
+
+	t.Run("Composite expressions should write numbers, booleans, lists and maps as JSON", func(t *testing.T) {
+		expression, err := Parse("replicas=${refs.config.data.replicas} enabled=${true} tags=${refs.config.tags} data=${refs.config.data} none=${nil}")
+
+		assert.NoError(t, err)
+
+		r, err := expression.Evaluate(variables)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `replicas=3 enabled=true tags=["checkout","prod"] data={"region":"us-east-1","replicas":3} none=`, r)
+	})
+}
+
 func Test_ExpressionDependencies(t *testing.T) {
 	t.Run("We should be able to read dependencies from an expression", func(t *testing.T) {
 
@@ -213,7 +285,157 @@ func Test_ExpressionDependencies(t *testing.T) {
 
 				assert.Equal(t, []string{"refs.sample"}, dependencies)
 			})
+
+			t.Run("...even when the name isn't a valid identifier", func(t *testing.T) {
+				expression, err := Parse(`${resources["my-db"].outputs.host}`)
+
+				assert.NoError(t, err)
+
+				dependencies := expression.Dependencies()
+
+				assert.Equal(t, []string{"resources.my-db"}, dependencies)
+			})
+		})
+
+		t.Run("Every dependency of an expression should be detected.", func(t *testing.T) {
+			expression, err := Parse(`${resources.database.host + ":" + string(resources["database-port"].port) + refs.settings.path + resources.database.name}`)
+
+			assert.NoError(t, err)
+
+			dependencies := expression.Dependencies()
+
+			assert.Equal(t, []string{"resources.database", "resources.database-port", "refs.settings"}, dependencies)
+		})
+
+		t.Run("Dependencies of composite expressions should be detected.", func(t *testing.T) {
+			expression, err := Parse(`postgres://${resources.database.host}:${resources.database.port}/${refs.settings.name}`)
+
+			assert.NoError(t, err)
+
+			dependencies := expression.Dependencies()
+
+			assert.Equal(t, []string{"resources.database", "refs.settings"}, dependencies)
+		})
+
+		t.Run("Names only known at evaluation time aren't dependencies.", func(t *testing.T) {
+			expression, err := Parse(`${resources[name].host}`)
+
+			assert.NoError(t, err)
+
+			dependencies := expression.Dependencies()
+
+			assert.Empty(t, dependencies)
+		})
+	})
+
+}
+
+func Test_ExpressionLanguage(t *testing.T) {
+
+	t.Run("We should parse expressions in the chosen language", func(t *testing.T) {
+		expression, err := ParseWith(LanguageCEL, `${parameters.size * 2}`)
+
+		assert.NoError(t, err)
+		assert.IsType(t, cel.CelExpression{}, expression)
+
+		r, err := expression.Evaluate(map[string]any{"parameters": map[string]any{"size": 10}})
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(20), r)
+	})
+
+	t.Run("We should interpolate CEL expressions into a string", func(t *testing.T) {
+		expression, err := ParseWith(LanguageCEL, `postgres://${resources.database.host}:${string(resources.database.port)}`)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"resources.database"}, expression.Dependencies())
+
+		r, err := expression.Evaluate(map[string]any{
+			"resources": map[string]any{"database": map[string]any{"host": "db.local", "port": 5432}},
 		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "postgres://db.local:5432", r)
+	})
+
+	t.Run("We should parse expressions without a language in the default one", func(t *testing.T) {
+		defer SetDefaultLanguage(LanguageExpr)
+
+		assert.NoError(t, SetDefaultLanguage(LanguageCEL))
+
+		expression, err := Parse(`${parameters.size}`)
+
+		assert.NoError(t, err)
+		assert.IsType(t, cel.CelExpression{}, expression)
 	})
 
+	t.Run("We should reject unsupported languages", func(t *testing.T) {
+		assert.Error(t, SetDefaultLanguage("jsonnet"))
+
+		_, err := ParseWith("jsonnet", `${parameters.size}`)
+
+		assert.Error(t, err)
+	})
+}
+
+func Test_ExpressionLimits(t *testing.T) {
+	defer SetLimits(Limits{})
+
+	SetLimits(Limits{Depth: 2})
+
+	t.Run("We should bound the expressions parsed in each language", func(t *testing.T) {
+		for _, language := range []Language{LanguageExpr, LanguageCEL} {
+			expression, err := ParseWith(language, `${((((1 + 1))))}`)
+			assert.NoError(t, err)
+
+			_, err = expression.Evaluate()
+			assert.ErrorContains(t, err, "failed compiling expression", "language %s", language)
+		}
+	})
+
+	t.Run("We should bound each expression interpolated into a string", func(t *testing.T) {
+		expression, err := ParseWith(LanguageCEL, `size: ${((((1 + 1))))}`)
+		assert.NoError(t, err)
+
+		_, err = expression.Evaluate()
+		assert.ErrorContains(t, err, "failed compiling expression")
+	})
+}
+
+func Test_ExpressionUnknowns(t *testing.T) {
+
+	args := map[string]any{
+		"resources": map[string]any{
+			"database": map[string]any{"status": map[string]any{"outputs": map[string]any{"host": "db.internal"}}},
+		},
+	}
+
+	t.Run("We should read the longest paths into resources and refs", func(t *testing.T) {
+		expression, err := Parse(`${resources.database.status.outputs.host}:${resources["database"].status.outputs.port} ${refs.settings}`)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"resources.database.status.outputs.host", "resources.database.status.outputs.port", "refs.settings"}, ReferencesOf(expression))
+	})
+
+	t.Run("We should fail with the unknowns an interpolation depends on", func(t *testing.T) {
+		expression, err := Parse(`${resources.database.status.outputs.host}:${resources.database.status.outputs.port}`)
+		assert.NoError(t, err)
+
+		_, err = EvaluateWithUnknowns(expression, []string{"resources.database.status.outputs.port"}, args)
+
+		var unknown *UnknownError
+		if assert.ErrorAs(t, err, &unknown) {
+			assert.Equal(t, []string{"resources.database.status.outputs.port"}, unknown.Unknowns)
+		}
+	})
+
+	t.Run("We should ignore the unknowns a CEL expression doesn't depend on", func(t *testing.T) {
+		expression, err := ParseWith(LanguageCEL, `${has(resources.database.status.outputs.host) ? resources.database.status.outputs.host : resources.database.status.outputs.port}`)
+		assert.NoError(t, err)
+
+		r, err := EvaluateWithUnknowns(expression, []string{"resources.database.status.outputs.port"}, args)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "db.internal", r)
+	})
 }
@@ -0,0 +1,63 @@
+// Package names rewrites the names of resources, refs and secrets written in dot syntax, like
+// resources.my-database.status, to index syntax, like resources["my-database"].status, so the dashes of kebab-case
+// names aren't read as subtractions by the parsers of expressions.
+package names
+
+import (
+	"regexp"
+	"strings"
+)
+
+// dottedNameRe matches a kebab-case name selected from the variables keyed by the names of objects
+var dottedNameRe = regexp.MustCompile(`^(resources|refs|secrets)\.([A-Za-z_][A-Za-z0-9_]*(?:-[A-Za-z0-9_]+)+)`)
+
+// Bracketed rewrites every kebab-case name selected in dot syntax from resources, refs or secrets to index syntax;
+// string literals are kept as they are. Names without dashes are left alone.
+func Bracketed(source string) string {
+	if !strings.Contains(source, "-") {
+		return source
+	}
+
+	var bracketed strings.Builder
+	var quote byte
+	escaped := false
+
+	for i := 0; i < len(source); i++ {
+		c := source[i]
+
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == quote:
+				quote = 0
+			}
+			bracketed.WriteByte(c)
+			continue
+		}
+
+		switch c {
+		case '"', '\'', '`':
+			quote = c
+		default:
+			// only variables are rewritten, never fields of other objects, like a.resources.name
+			if i == 0 || !isPartOfName(source[i-1]) {
+				if match := dottedNameRe.FindStringSubmatch(source[i:]); match != nil {
+					bracketed.WriteString(match[1] + `["` + match[2] + `"]`)
+					i += len(match[0]) - 1
+					continue
+				}
+			}
+		}
+
+		bracketed.WriteByte(c)
+	}
+
+	return bracketed.String()
+}
+
+func isPartOfName(c byte) bool {
+	return c == '.' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,30 @@
+package names
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Bracketed(t *testing.T) {
+
+	t.Run("We should rewrite kebab-case names of resources, refs and secrets to index syntax", func(t *testing.T) {
+		assert.Equal(t, `resources["resource-one"].status.outputs.id`, Bracketed("resources.resource-one.status.outputs.id"))
+		assert.Equal(t, `refs["app-config"].data.owner`, Bracketed("refs.app-config.data.owner"))
+		assert.Equal(t, `secrets["database-credentials"].password`, Bracketed("secrets.database-credentials.password"))
+	})
+
+	t.Run("We should rewrite every name of an expression", func(t *testing.T) {
+		assert.Equal(t,
+			`resources["db-a"].status.outputs.host + ":" + string(resources["db-b"].status.outputs.port)`,
+			Bracketed(`resources.db-a.status.outputs.host + ":" + string(resources.db-b.status.outputs.port)`))
+	})
+
+	t.Run("We should keep names without dashes, subtractions and string literals as they are", func(t *testing.T) {
+		assert.Equal(t, "resources.database.status.outputs.port", Bracketed("resources.database.status.outputs.port"))
+		assert.Equal(t, "parameters.max-parameters.min", Bracketed("parameters.max-parameters.min"))
+		assert.Equal(t, "resources.database.size - 1", Bracketed("resources.database.size - 1"))
+		assert.Equal(t, `"resources.resource-one" + resources["resource-two"].name`, Bracketed(`"resources.resource-one" + resources["resource-two"].name`))
+		assert.Equal(t, "each.value.resources.resource-one", Bracketed("each.value.resources.resource-one"))
+	})
+}
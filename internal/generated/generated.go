@@ -0,0 +1,256 @@
+// Package generated records the values generated by expressions to each placement of a ResourceGroup, like timestamps
+// and random suffixes, so they are generated once and a render produces the same properties as the previous ones.
+// Removing a value from the ConfigMap generates it again in the next deployment.
+package generated
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ValuesConfigMapName is the ConfigMap, in the namespace of the ResourceGroup, holding the generated values of all
+	// its placements
+	ValuesConfigMapName = "klaudio-generated-values"
+
+	valuesKey = "values"
+
+	// suffixAlphabet keeps random suffixes valid in DNS names, like the names of buckets and Kubernetes objects
+	suffixAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// Values are the values generated by each function, by owner (the placement, optionally followed by a name; random
+// suffixes are also owned by their length)
+type Values map[string]map[string]string
+
+// generate returns the value of the owner, generating one when there is none yet or the recorded one isn't valid
+// anymore, like a suffix of another length
+func (v Values) generate(function string, owner string, valid func(string) bool, newValue func() (string, error)) (value string, generated bool, err error) {
+	if value, ok := v[function][owner]; ok && valid(value) {
+		return value, false, nil
+	}
+
+	value, err = newValue()
+	if err != nil {
+		return "", false, err
+	}
+
+	if v[function] == nil {
+		v[function] = make(map[string]string)
+	}
+	v[function][owner] = value
+
+	return value, true, nil
+}
+
+// Generator generates values to one placement. Values are persisted to a ConfigMap, unless the Generator has no client
+// or is a dry run: then new values are only kept in memory, to preview what they would be.
+type Generator struct {
+	ctx       context.Context
+	client    client.Client
+	namespace string
+	placement string
+	dryRun    bool
+
+	now    func() time.Time
+	random func(owner string, length int) (string, error)
+
+	mu     sync.Mutex
+	values Values
+}
+
+func NewGenerator(ctx context.Context, c client.Client, namespace string, placement string, dryRun bool) *Generator {
+	return &Generator{ctx: ctx, client: c, namespace: namespace, placement: placement, dryRun: dryRun, now: time.Now, random: randomSuffix}
+}
+
+// NewMemoryGenerator returns a Generator that starts empty and never persists its values. They are reproducible, so
+// renders can be compared with expected properties: timestamps are the Unix epoch, and random suffixes are derived
+// from their owner.
+func NewMemoryGenerator(placement string) *Generator {
+	return &Generator{
+		ctx:       context.Background(),
+		placement: placement,
+		dryRun:    true,
+		now:       func() time.Time { return time.Unix(0, 0) },
+		random:    derivedSuffix,
+	}
+}
+
+// Now returns the time of the first call to the placement, in RFC 3339; the same time is returned every time. A name
+// allows more than one timestamp to the placement.
+func (g *Generator) Now(name ...string) (string, error) {
+	owner, err := g.ownerOf(name)
+	if err != nil {
+		return "", err
+	}
+
+	return g.generate("now", owner,
+		func(value string) bool {
+			_, err := time.Parse(time.RFC3339, value)
+			return err == nil
+		},
+		func() (string, error) {
+			return g.now().UTC().Format(time.RFC3339), nil
+		})
+}
+
+// RandomSuffix returns lowercase letters and digits of the length to the placement; the same suffix is returned every
+// time. A name allows more than one suffix to the placement, and so does another length: each length has its own
+// suffix, so asking for two lengths doesn't replace one suffix by the other on every render.
+func (g *Generator) RandomSuffix(length int, name ...string) (string, error) {
+	if length <= 0 || length > 64 {
+		return "", fmt.Errorf("the length of a random suffix must be between 1 and 64, got %d", length)
+	}
+
+	owner, err := g.ownerOf(name)
+	if err != nil {
+		return "", err
+	}
+
+	return g.generate("randomsuffix", fmt.Sprintf("%s/%d", owner, length),
+		func(value string) bool {
+			return len(value) == length
+		},
+		func() (string, error) {
+			return g.random(owner, length)
+		})
+}
+
+func (g *Generator) ownerOf(name []string) (string, error) {
+	if len(name) > 1 {
+		return "", fmt.Errorf("a generated value has only one name, got %v", name)
+	}
+	if len(name) == 1 && name[0] != "" {
+		return fmt.Sprintf("%s.%s", g.placement, name[0]), nil
+	}
+	return g.placement, nil
+}
+
+func (g *Generator) generate(function string, owner string, valid func(string) bool, newValue func() (string, error)) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.client == nil || g.dryRun {
+		if g.values == nil {
+			values, err := g.load()
+			if err != nil {
+				return "", err
+			}
+			g.values = values
+		}
+		value, _, err := g.values.generate(function, owner, valid, newValue)
+		return value, err
+	}
+
+	var value string
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap := &corev1.ConfigMap{}
+		err := g.client.Get(g.ctx, client.ObjectKey{Namespace: g.namespace, Name: ValuesConfigMapName}, configMap)
+		exists := err == nil
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+
+		values, err := valuesOf(configMap)
+		if err != nil {
+			return err
+		}
+
+		v, generated, err := values.generate(function, owner, valid, newValue)
+		if err != nil {
+			return err
+		}
+		value = v
+
+		if !generated {
+			return nil
+		}
+
+		data, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: g.namespace, Name: ValuesConfigMapName},
+				Data:       map[string]string{valuesKey: string(data)},
+			}
+			return g.client.Create(g.ctx, configMap)
+		}
+
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		configMap.Data[valuesKey] = string(data)
+		return g.client.Update(g.ctx, configMap)
+	})
+
+	return value, err
+}
+
+func (g *Generator) load() (Values, error) {
+	if g.client == nil {
+		return make(Values), nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := g.client.Get(g.ctx, client.ObjectKey{Namespace: g.namespace, Name: ValuesConfigMapName}, configMap); err != nil {
+		if errors.IsNotFound(err) {
+			return make(Values), nil
+		}
+		return nil, err
+	}
+
+	return valuesOf(configMap)
+}
+
+func valuesOf(configMap *corev1.ConfigMap) (Values, error) {
+	values := make(Values)
+
+	data, ok := configMap.Data[valuesKey]
+	if !ok || data == "" {
+		return values, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &values); err != nil {
+		return nil, fmt.Errorf("unable to read the generated values of %s/%s: %w", configMap.Namespace, configMap.Name, err)
+	}
+
+	return values, nil
+}
+
+func randomSuffix(_ string, length int) (string, error) {
+	var suffix strings.Builder
+	for range length {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(suffixAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		suffix.WriteByte(suffixAlphabet[n.Int64()])
+	}
+	return suffix.String(), nil
+}
+
+func derivedSuffix(owner string, length int) (string, error) {
+	sum := sha256.Sum256([]byte(owner))
+
+	var suffix strings.Builder
+	for i := range length {
+		suffix.WriteByte(suffixAlphabet[int(sum[i%len(sum)])%len(suffixAlphabet)])
+	}
+	return suffix.String(), nil
+}
@@ -0,0 +1,97 @@
+package generated
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_Generator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	t.Run("We should generate a random suffix to each placement", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		prod, err := NewGenerator(context.TODO(), c, "checkout", "prod", false).RandomSuffix(8)
+		assert.NoError(t, err)
+		assert.Regexp(t, `^[a-z0-9]{8}$`, prod)
+
+		staging, err := NewGenerator(context.TODO(), c, "checkout", "staging", false).RandomSuffix(8)
+		assert.NoError(t, err)
+		assert.Regexp(t, `^[a-z0-9]{8}$`, staging)
+
+		t.Run("...and keep them in the next deployments", func(t *testing.T) {
+			again, err := NewGenerator(context.TODO(), c, "checkout", "prod", false).RandomSuffix(8)
+			assert.NoError(t, err)
+			assert.Equal(t, prod, again)
+
+			configMap := &corev1.ConfigMap{}
+			assert.NoError(t, c.Get(context.TODO(), client.ObjectKey{Namespace: "checkout", Name: ValuesConfigMapName}, configMap))
+			assert.JSONEq(t, `{"randomsuffix":{"prod/8":"`+prod+`","staging/8":"`+staging+`"}}`, configMap.Data[valuesKey])
+		})
+
+		t.Run("...with one suffix to each length", func(t *testing.T) {
+			longer, err := NewGenerator(context.TODO(), c, "checkout", "prod", false).RandomSuffix(12)
+			assert.NoError(t, err)
+			assert.Len(t, longer, 12)
+
+			generator := NewGenerator(context.TODO(), c, "checkout", "prod", false)
+			for range 2 {
+				shorter, err := generator.RandomSuffix(8)
+				assert.NoError(t, err)
+				assert.Equal(t, prod, shorter)
+
+				again, err := generator.RandomSuffix(12)
+				assert.NoError(t, err)
+				assert.Equal(t, longer, again)
+			}
+		})
+	})
+
+	t.Run("We should keep the time of the first render", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		generator := NewGenerator(context.TODO(), c, "checkout", "prod", false)
+		generator.now = func() time.Time { return time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC) }
+
+		createdAt, err := generator.Now()
+		assert.NoError(t, err)
+		assert.Equal(t, "2026-03-01T12:00:00Z", createdAt)
+
+		again, err := NewGenerator(context.TODO(), c, "checkout", "prod", false).Now()
+		assert.NoError(t, err)
+		assert.Equal(t, createdAt, again)
+
+		rotatedAt, err := NewGenerator(context.TODO(), c, "checkout", "prod", false).Now("rotation")
+		assert.NoError(t, err)
+		assert.NotEqual(t, createdAt, rotatedAt)
+	})
+
+	t.Run("We should not record values in a dry run", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		generator := NewGenerator(context.TODO(), c, "checkout", "prod", true)
+
+		suffix, err := generator.RandomSuffix(6)
+		assert.NoError(t, err)
+
+		again, err := generator.RandomSuffix(6)
+		assert.NoError(t, err)
+		assert.Equal(t, suffix, again)
+
+		err = c.Get(context.TODO(), client.ObjectKey{Namespace: "checkout", Name: ValuesConfigMapName}, &corev1.ConfigMap{})
+		assert.Error(t, err)
+	})
+
+	t.Run("We should reject suffixes without a sane length", func(t *testing.T) {
+		_, err := NewMemoryGenerator("prod").RandomSuffix(0)
+		assert.Error(t, err)
+	})
+}
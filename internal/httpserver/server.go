@@ -0,0 +1,89 @@
+// Package httpserver serves the HTTP APIs of the manager, next to its controllers
+package httpserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	certutil "k8s.io/client-go/util/cert"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// Server serves a handler over TLS until the manager stops, on every replica, leader or not. Like the metrics
+// endpoint, it serves a self-signed certificate, and each request goes through the Filter first:
+// filters.WithAuthenticationAndAuthorization reviews the bearer token of the request, then checks whether its user
+// may access the path as a non-resource URL, with the method as the verb.
+type Server struct {
+	// Name of the server in the logs, like event-stream
+	Name    string
+	Addr    string
+	Handler http.Handler
+	Filter  metricsserver.Filter
+	TLSOpts []func(*tls.Config)
+}
+
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName(s.Name)
+
+	handler := s.Handler
+	if s.Filter != nil {
+		filtered, err := s.Filter(log, handler)
+		if err != nil {
+			return fmt.Errorf("unable to protect the %s server: %w", s.Name, err)
+		}
+		handler = filtered
+	}
+
+	cert, key, err := certutil.GenerateSelfSignedCertKeyWithFixtures("localhost", []net.IP{{127, 0, 0, 1}}, nil, "")
+	if err != nil {
+		return fmt.Errorf("unable to generate a certificate to the %s server: %w", s.Name, err)
+	}
+	keyPair, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		return fmt.Errorf("unable to generate a certificate to the %s server: %w", s.Name, err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		NextProtos:   []string{"h2"},
+	}
+	for _, opt := range s.TLSOpts {
+		opt(tlsConfig)
+	}
+
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Error(err, "unable to shut the server down")
+		}
+	}()
+
+	log.Info(fmt.Sprintf("serving at %s", s.Addr))
+
+	// the certificate is already in the TLS config
+	if err := server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
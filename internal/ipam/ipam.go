@@ -0,0 +1,295 @@
+// Package ipam records the subnets carved from address pools to each placement of a ResourceGroup, so they are
+// allocated once and kept stable across deployments, without an external IPAM.
+package ipam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/cidr"
+)
+
+const (
+	// AllocationsConfigMapName is the ConfigMap where older versions recorded the allocations of a ResourceGroup, in
+	// the namespace of its deployments; they're still read, so the subnets allocated there are kept
+	AllocationsConfigMapName = "klaudio-cidr-allocations"
+
+	// DefaultNamespace holds the allocations of every ResourceGroup, unless SetNamespace chooses another one
+	DefaultNamespace = "klaudio-system"
+
+	allocationsKey = "allocations"
+)
+
+// namespace holds a ConfigMap to each ResourceGroup, with the allocations of all its placements; one namespace keeps
+// the placements of a group from allocating the same subnets, whatever its namespace strategy
+var namespace = DefaultNamespace
+
+// SetNamespace selects the namespace holding the allocations; it must be set before any is made
+func SetNamespace(ns string) {
+	namespace = ns
+}
+
+// ConfigMapNameOf is the ConfigMap holding the allocations of the ResourceGroup
+func ConfigMapNameOf(group string) string {
+	return fmt.Sprintf("%s-%s", AllocationsConfigMapName, group)
+}
+
+// Allocations are the subnets allocated to each placement, by pool and name; the subnet allocated without a name is
+// keyed by an empty one. Each placement is a key of the ConfigMap, so it's released without touching the others.
+type Allocations map[string]map[string]map[string]string
+
+// allocate returns the subnet of the placement in the pool, carving the first free one when there is none yet; a
+// subnet recorded by an older version is adopted as it is
+func (a Allocations) allocate(placement string, pool string, newbits int, name string, legacy legacyAllocations) (subnet string, allocated bool, err error) {
+	if subnet, ok := a[placement][pool][name]; ok {
+		return subnet, false, nil
+	}
+
+	subnet, ok := legacy.subnetOf(placement, pool, name)
+	if !ok {
+		taken := make([]string, 0)
+		for _, pools := range a {
+			taken = append(taken, slices.Collect(maps.Values(pools[pool]))...)
+		}
+		// the order of the map must not decide the result
+		slices.Sort(taken)
+
+		subnet, err = cidr.NextFree(pool, newbits, taken)
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	if a[placement] == nil {
+		a[placement] = make(map[string]map[string]string)
+	}
+	if a[placement][pool] == nil {
+		a[placement][pool] = make(map[string]string)
+	}
+	a[placement][pool][name] = subnet
+
+	return subnet, true, nil
+}
+
+// legacyAllocations are the subnets allocated from each pool by older versions, by owner: the placement, optionally
+// followed by a name
+type legacyAllocations map[string]map[string]string
+
+func (l legacyAllocations) subnetOf(placement string, pool string, name string) (string, bool) {
+	owner := placement
+	if name != "" {
+		owner = fmt.Sprintf("%s.%s", placement, name)
+	}
+	subnet, ok := l[pool][owner]
+	return subnet, ok
+}
+
+// Allocator allocates subnets to one placement of a ResourceGroup. Allocations are persisted to a ConfigMap, unless
+// the Allocator has no client or is a dry run: then new allocations are only kept in memory, to preview what they
+// would be.
+type Allocator struct {
+	ctx       context.Context
+	client    client.Client
+	group     string
+	placement string
+	dryRun    bool
+
+	// legacyNamespace is where an older version may have recorded the allocations of the group
+	legacyNamespace string
+
+	mu          sync.Mutex
+	allocations Allocations
+	legacy      legacyAllocations
+}
+
+func NewAllocator(ctx context.Context, c client.Client, group string, placement string, dryRun bool) *Allocator {
+	return &Allocator{ctx: ctx, client: c, group: group, placement: placement, dryRun: dryRun}
+}
+
+// NewMemoryAllocator returns an Allocator that starts empty and never persists its allocations
+func NewMemoryAllocator(placement string) *Allocator {
+	return &Allocator{ctx: context.Background(), placement: placement, dryRun: true}
+}
+
+// WithLegacyNamespace adopts the subnets allocated by older versions, which recorded them in the namespace of the
+// deployment
+func (a *Allocator) WithLegacyNamespace(namespace string) *Allocator {
+	a.legacyNamespace = namespace
+	return a
+}
+
+// Allocate returns the subnet of the placement in the pool, extending the pool prefix by newbits; the same subnet is
+// returned every time. A name allows more than one subnet from the same pool to the placement.
+func (a *Allocator) Allocate(pool string, newbits int, name ...string) (string, error) {
+	if len(name) > 1 {
+		return "", fmt.Errorf("a subnet has only one name, got %v", name)
+	}
+	subnetName := ""
+	if len(name) == 1 {
+		subnetName = name[0]
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	legacy, err := a.loadLegacy()
+	if err != nil {
+		return "", err
+	}
+
+	if a.client == nil || a.dryRun {
+		if a.allocations == nil {
+			configMap, err := a.get()
+			if err != nil {
+				return "", err
+			}
+			allocations, err := allocationsOf(configMap)
+			if err != nil {
+				return "", err
+			}
+			a.allocations = allocations
+		}
+
+		subnet, _, err := a.allocations.allocate(a.placement, pool, newbits, subnetName, legacy)
+		return subnet, err
+	}
+
+	var subnet string
+
+	// another deployment of the group may create or update the ConfigMap meanwhile; then its allocations are read again
+	err = retry.OnError(retry.DefaultRetry, isRaced, func() error {
+		configMap, err := a.get()
+		if err != nil {
+			return err
+		}
+
+		allocations, err := allocationsOf(configMap)
+		if err != nil {
+			return err
+		}
+
+		s, allocated, err := allocations.allocate(a.placement, pool, newbits, subnetName, legacy)
+		if err != nil {
+			return err
+		}
+		subnet = s
+
+		if !allocated {
+			return nil
+		}
+
+		data, err := json.Marshal(allocations[a.placement])
+		if err != nil {
+			return err
+		}
+
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+		configMap.Data[a.placement] = string(data)
+
+		if configMap.ResourceVersion == "" {
+			return a.client.Create(a.ctx, configMap)
+		}
+		return a.client.Update(a.ctx, configMap)
+	})
+
+	return subnet, err
+}
+
+// get reads the ConfigMap of the group; a new one is returned when it doesn't exist yet
+func (a *Allocator) get() (*corev1.ConfigMap, error) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      ConfigMapNameOf(a.group),
+			Labels:    map[string]string{resourcesv1alpha1.Group + "/managedBy.name": a.group},
+		},
+	}
+	if a.client == nil {
+		return configMap, nil
+	}
+
+	if err := a.client.Get(a.ctx, client.ObjectKeyFromObject(configMap), configMap); err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	return configMap, nil
+}
+
+func (a *Allocator) loadLegacy() (legacyAllocations, error) {
+	if a.legacy != nil {
+		return a.legacy, nil
+	}
+
+	a.legacy = make(legacyAllocations)
+	if a.client == nil || a.legacyNamespace == "" {
+		return a.legacy, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := a.client.Get(a.ctx, client.ObjectKey{Namespace: a.legacyNamespace, Name: AllocationsConfigMapName}, configMap); err != nil {
+		if errors.IsNotFound(err) {
+			return a.legacy, nil
+		}
+		a.legacy = nil
+		return nil, err
+	}
+
+	if data := configMap.Data[allocationsKey]; data != "" {
+		if err := json.Unmarshal([]byte(data), &a.legacy); err != nil {
+			a.legacy = nil
+			return nil, fmt.Errorf("unable to read the CIDR allocations of %s/%s: %w", configMap.Namespace, configMap.Name, err)
+		}
+	}
+	return a.legacy, nil
+}
+
+// Release removes the subnets allocated to the placement of the group, so they can be allocated again; the ConfigMap
+// is deleted along with the last placement
+func Release(ctx context.Context, c client.Client, group string, placement string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap := &corev1.ConfigMap{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ConfigMapNameOf(group)}, configMap); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+
+		if _, ok := configMap.Data[placement]; !ok {
+			return nil
+		}
+		delete(configMap.Data, placement)
+
+		if len(configMap.Data) == 0 {
+			return client.IgnoreNotFound(c.Delete(ctx, configMap, client.Preconditions{ResourceVersion: &configMap.ResourceVersion}))
+		}
+		return c.Update(ctx, configMap)
+	})
+}
+
+func allocationsOf(configMap *corev1.ConfigMap) (Allocations, error) {
+	allocations := make(Allocations)
+
+	for placement, data := range configMap.Data {
+		pools := make(map[string]map[string]string)
+		if err := json.Unmarshal([]byte(data), &pools); err != nil {
+			return nil, fmt.Errorf("unable to read the CIDR allocations of %s/%s: %w", configMap.Namespace, configMap.Name, err)
+		}
+		allocations[placement] = pools
+	}
+
+	return allocations, nil
+}
+
+func isRaced(err error) bool {
+	return errors.IsConflict(err) || errors.IsAlreadyExists(err)
+}
@@ -0,0 +1,140 @@
+package ipam
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func Test_Allocator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	t.Run("We should allocate a different subnet to each placement", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		prod, err := NewAllocator(context.TODO(), c, "checkout", "prod", false).Allocate("10.0.0.0/16", 8)
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.0.0/24", prod)
+
+		staging, err := NewAllocator(context.TODO(), c, "checkout", "staging", false).Allocate("10.0.0.0/16", 8)
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.1.0/24", staging)
+
+		t.Run("...and keep them in the next deployments", func(t *testing.T) {
+			again, err := NewAllocator(context.TODO(), c, "checkout", "prod", false).Allocate("10.0.0.0/16", 8)
+			assert.NoError(t, err)
+			assert.Equal(t, prod, again)
+
+			configMap := &corev1.ConfigMap{}
+			assert.NoError(t, c.Get(context.TODO(), client.ObjectKey{Namespace: DefaultNamespace, Name: ConfigMapNameOf("checkout")}, configMap))
+			assert.JSONEq(t, `{"10.0.0.0/16":{"":"10.0.0.0/24"}}`, configMap.Data["prod"])
+			assert.JSONEq(t, `{"10.0.0.0/16":{"":"10.0.1.0/24"}}`, configMap.Data["staging"])
+		})
+	})
+
+	t.Run("We should allocate more than one subnet from the same pool to a placement using names", func(t *testing.T) {
+		allocator := NewAllocator(context.TODO(), fake.NewClientBuilder().WithScheme(scheme).Build(), "checkout", "prod", false)
+
+		private, err := allocator.Allocate("10.0.0.0/16", 4, "private")
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.0.0/20", private)
+
+		public, err := allocator.Allocate("10.0.0.0/16", 8, "public")
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.16.0/24", public)
+	})
+
+	t.Run("We should not record allocations in a dry run", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		subnet, err := NewAllocator(context.TODO(), c, "checkout", "prod", true).Allocate("10.0.0.0/16", 8)
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.0.0/24", subnet)
+
+		err = c.Get(context.TODO(), client.ObjectKey{Namespace: DefaultNamespace, Name: ConfigMapNameOf("checkout")}, &corev1.ConfigMap{})
+		assert.Error(t, err)
+	})
+
+	t.Run("We should adopt the subnets recorded by older versions in the namespace of the deployment", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "checkout-prod", Name: AllocationsConfigMapName},
+			Data:       map[string]string{allocationsKey: `{"10.0.0.0/16":{"prod":"10.0.7.0/24","prod.public":"10.0.8.0/24"}}`},
+		}).Build()
+
+		allocator := NewAllocator(context.TODO(), c, "checkout", "prod", false).WithLegacyNamespace("checkout-prod")
+
+		subnet, err := allocator.Allocate("10.0.0.0/16", 8)
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.7.0/24", subnet)
+
+		public, err := allocator.Allocate("10.0.0.0/16", 8, "public")
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.8.0/24", public)
+	})
+
+	t.Run("We should allocate again when another deployment of the group creates the ConfigMap first", func(t *testing.T) {
+		created := false
+		c := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if !created {
+					created = true
+					// staging takes the first subnet meanwhile
+					if err := c.Create(ctx, &corev1.ConfigMap{
+						ObjectMeta: metav1.ObjectMeta{Namespace: DefaultNamespace, Name: ConfigMapNameOf("checkout")},
+						Data:       map[string]string{"staging": `{"10.0.0.0/16":{"":"10.0.0.0/24"}}`},
+					}); err != nil {
+						return err
+					}
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+
+		subnet, err := NewAllocator(context.TODO(), c, "checkout", "prod", false).Allocate("10.0.0.0/16", 8)
+		assert.NoError(t, err)
+		assert.Equal(t, "10.0.1.0/24", subnet)
+	})
+}
+
+func Test_Release(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for _, placement := range []string{"prod", "staging"} {
+		_, err := NewAllocator(context.TODO(), c, "checkout", placement, false).Allocate("10.0.0.0/16", 8)
+		assert.NoError(t, err)
+	}
+
+	t.Run("We should release the subnets of a placement", func(t *testing.T) {
+		assert.NoError(t, Release(context.TODO(), c, "checkout", "staging"))
+
+		configMap := &corev1.ConfigMap{}
+		assert.NoError(t, c.Get(context.TODO(), client.ObjectKey{Namespace: DefaultNamespace, Name: ConfigMapNameOf("checkout")}, configMap))
+		assert.Contains(t, configMap.Data, "prod")
+		assert.NotContains(t, configMap.Data, "staging")
+
+		t.Run("...so they can be allocated again", func(t *testing.T) {
+			subnet, err := NewAllocator(context.TODO(), c, "checkout", "qa", false).Allocate("10.0.0.0/16", 8)
+			assert.NoError(t, err)
+			assert.Equal(t, "10.0.1.0/24", subnet)
+		})
+	})
+
+	t.Run("We should delete the ConfigMap along with the last placement", func(t *testing.T) {
+		assert.NoError(t, Release(context.TODO(), c, "checkout", "prod"))
+		assert.NoError(t, Release(context.TODO(), c, "checkout", "qa"))
+
+		err := c.Get(context.TODO(), client.ObjectKey{Namespace: DefaultNamespace, Name: ConfigMapNameOf("checkout")}, &corev1.ConfigMap{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+}
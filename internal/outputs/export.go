@@ -0,0 +1,188 @@
+package outputs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// the objects written by Export are labeled with the Resource, since the ones in other namespaces can't be owned by it
+const (
+	exportedByNameLabel      = resourcesv1alpha1.Group + "/exportedBy.name"
+	exportedByNamespaceLabel = resourcesv1alpha1.Group + "/exportedBy.namespace"
+)
+
+// exportNamespaces are the namespaces, besides its own, a Resource may write its outputs into
+var exportNamespaces []string
+
+// SetExportNamespaces allows Resources to write their outputs into the namespaces; by default, a Resource only writes
+// into its own namespace
+func SetExportNamespaces(namespaces []string) {
+	exportNamespaces = slices.Clone(namespaces)
+}
+
+// Export writes the outputs into the Secret or ConfigMap declared by spec.writeOutputsTo, and deletes any object the
+// Resource wrote before to another target. Objects not written by klaudio are never overwritten. Sensitive outputs
+// are only written to Secrets; a ConfigMap gets them redacted. The target must be in the namespace of the Resource, or
+// in one allowed by SetExportNamespaces.
+func Export(ctx context.Context, c client.Client, resource *resourcesv1alpha1.Resource, outputs resourcesv1alpha1.ResourceOutputs, sensitive []string) error {
+	target := resource.Spec.WriteOutputsTo
+	if target == nil {
+		return Unexport(ctx, c, resource)
+	}
+
+	if target.Namespace != "" && target.Namespace != resource.Namespace && !slices.Contains(exportNamespaces, target.Namespace) {
+		return fmt.Errorf("outputs can't be written into namespace %s; only the namespace of the Resource and the ones allowed by the operator are", target.Namespace)
+	}
+
+	if target.Kind != resourcesv1alpha1.ResourceOutputsTargetSecret {
+		outputs = Redact(outputs, sensitive)
+	}
+
+	data := make(map[string]string)
+	for name, value := range outputs {
+		if s, ok := value.(string); ok {
+			data[name] = s
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		data[name] = string(encoded)
+	}
+
+	obj := targetObject(resource, target)
+	if obj == nil {
+		return fmt.Errorf("unsupported kind to write outputs to: %s", target.Kind)
+	}
+
+	current := targetObject(resource, target)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(current), current); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		setTargetData(obj, data)
+		if err := c.Create(ctx, obj); err != nil {
+			return err
+		}
+	} else {
+		if !exportedBy(current, resource) {
+			return fmt.Errorf("%s %s/%s already exists, and it wasn't written by Resource %s", target.Kind, current.GetNamespace(), current.GetName(), resource.Name)
+		}
+		setTargetData(current, data)
+		if err := c.Update(ctx, current); err != nil {
+			return err
+		}
+	}
+
+	return prune(ctx, c, resource, obj)
+}
+
+// Unexport deletes every object the Resource wrote its outputs to
+func Unexport(ctx context.Context, c client.Client, resource *resourcesv1alpha1.Resource) error {
+	return prune(ctx, c, resource, nil)
+}
+
+func targetObject(resource *resourcesv1alpha1.Resource, target *resourcesv1alpha1.ResourceOutputsTarget) client.Object {
+	var obj client.Object
+	switch target.Kind {
+	case resourcesv1alpha1.ResourceOutputsTargetSecret:
+		obj = &corev1.Secret{Type: corev1.SecretTypeOpaque}
+	case resourcesv1alpha1.ResourceOutputsTargetConfigMap:
+		obj = &corev1.ConfigMap{}
+	default:
+		return nil
+	}
+
+	namespace := target.Namespace
+	if namespace == "" {
+		namespace = resource.Namespace
+	}
+	obj.SetNamespace(namespace)
+	obj.SetName(target.Name)
+	obj.SetLabels(map[string]string{
+		exportedByNameLabel:      resource.Name,
+		exportedByNamespaceLabel: resource.Namespace,
+	})
+
+	// the objects in the namespace of the Resource go away along with it
+	if namespace == resource.Namespace && resource.UID != "" {
+		obj.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: resourcesv1alpha1.GroupVersion.String(),
+			Kind:       "Resource",
+			Name:       resource.Name,
+			UID:        resource.UID,
+		}})
+	}
+
+	return obj
+}
+
+func setTargetData(obj client.Object, data map[string]string) {
+	switch o := obj.(type) {
+	case *corev1.Secret:
+		o.Data = make(map[string][]byte)
+		for name, value := range data {
+			o.Data[name] = []byte(value)
+		}
+	case *corev1.ConfigMap:
+		o.Data = data
+	}
+}
+
+func exportedBy(obj client.Object, resource *resourcesv1alpha1.Resource) bool {
+	labels := obj.GetLabels()
+	return labels[exportedByNameLabel] == resource.Name && labels[exportedByNamespaceLabel] == resource.Namespace
+}
+
+// prune deletes the objects written by the Resource, except the current target; they're only looked for in the
+// namespaces the Resource may write into
+func prune(ctx context.Context, c client.Client, resource *resourcesv1alpha1.Resource, keep client.Object) error {
+	selector := client.MatchingLabels{
+		exportedByNameLabel:      resource.Name,
+		exportedByNamespaceLabel: resource.Namespace,
+	}
+
+	namespaces := append([]string{resource.Namespace}, exportNamespaces...)
+	slices.Sort(namespaces)
+
+	for _, namespace := range slices.Compact(namespaces) {
+		secrets := &corev1.SecretList{}
+		if err := c.List(ctx, secrets, selector, client.InNamespace(namespace)); err != nil {
+			return err
+		}
+		for i := range secrets.Items {
+			if err := deleteUnless(ctx, c, &secrets.Items[i], keep); err != nil {
+				return err
+			}
+		}
+
+		configMaps := &corev1.ConfigMapList{}
+		if err := c.List(ctx, configMaps, selector, client.InNamespace(namespace)); err != nil {
+			return err
+		}
+		for i := range configMaps.Items {
+			if err := deleteUnless(ctx, c, &configMaps.Items[i], keep); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func deleteUnless(ctx context.Context, c client.Client, obj client.Object, keep client.Object) error {
+	if keep != nil && fmt.Sprintf("%T", obj) == fmt.Sprintf("%T", keep) &&
+		obj.GetNamespace() == keep.GetNamespace() && obj.GetName() == keep.GetName() {
+		return nil
+	}
+	return client.IgnoreNotFound(c.Delete(ctx, obj))
+}
@@ -0,0 +1,118 @@
+package outputs
+
+import (
+	"context"
+	"testing"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_ExportOutputs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	allOutputs := resourcesv1alpha1.ResourceOutputs{
+		"endpoint": "checkout-prod.rds",
+		"port":     float64(5432),
+	}
+
+	newResource := func(target *resourcesv1alpha1.ResourceOutputsTarget) *resourcesv1alpha1.Resource {
+		return &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod.database", Namespace: "checkout"},
+			Spec:       resourcesv1alpha1.ResourceSpec{ResourceRef: "database", WriteOutputsTo: target},
+		}
+	}
+
+	ctx := context.TODO()
+
+	t.Run("We should be able to write outputs into a Secret in another namespace, allowed by the operator", func(t *testing.T) {
+		SetExportNamespaces([]string{"checkout-app"})
+		defer SetExportNamespaces(nil)
+
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		resource := newResource(&resourcesv1alpha1.ResourceOutputsTarget{Kind: resourcesv1alpha1.ResourceOutputsTargetSecret, Name: "database", Namespace: "checkout-app"})
+		assert.NoError(t, Export(ctx, c, resource, allOutputs, nil))
+
+		secret := &corev1.Secret{}
+		assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "checkout-app", Name: "database"}, secret))
+		assert.Equal(t, "checkout-prod.rds", string(secret.Data["endpoint"]))
+		assert.Equal(t, "5432", string(secret.Data["port"]))
+
+		assert.NoError(t, Unexport(ctx, c, resource))
+
+		err := c.Get(ctx, types.NamespacedName{Namespace: "checkout-app", Name: "database"}, &corev1.Secret{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("We should not write outputs into a namespace not allowed by the operator", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		resource := newResource(&resourcesv1alpha1.ResourceOutputsTarget{Kind: resourcesv1alpha1.ResourceOutputsTargetSecret, Name: "database", Namespace: "kube-system"})
+		assert.ErrorContains(t, Export(ctx, c, resource, allOutputs, nil), "can't be written into namespace kube-system")
+
+		err := c.Get(ctx, types.NamespacedName{Namespace: "kube-system", Name: "database"}, &corev1.Secret{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("Outputs written into the namespace of the Resource should be owned by it", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		resource := newResource(&resourcesv1alpha1.ResourceOutputsTarget{Kind: resourcesv1alpha1.ResourceOutputsTargetConfigMap, Name: "database"})
+		resource.UID = "a-uid"
+		assert.NoError(t, Export(ctx, c, resource, allOutputs, nil))
+
+		configMap := &corev1.ConfigMap{}
+		assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: "database"}, configMap))
+		if assert.Len(t, configMap.OwnerReferences, 1) {
+			assert.Equal(t, "Resource", configMap.OwnerReferences[0].Kind)
+			assert.Equal(t, "prod.database", configMap.OwnerReferences[0].Name)
+			assert.Equal(t, types.UID("a-uid"), configMap.OwnerReferences[0].UID)
+		}
+	})
+
+	t.Run("We should be able to move outputs to another target, deleting the previous one", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+		resource := newResource(&resourcesv1alpha1.ResourceOutputsTarget{Kind: resourcesv1alpha1.ResourceOutputsTargetSecret, Name: "database"})
+		assert.NoError(t, Export(ctx, c, resource, allOutputs, nil))
+
+		resource.Spec.WriteOutputsTo = &resourcesv1alpha1.ResourceOutputsTarget{Kind: resourcesv1alpha1.ResourceOutputsTargetConfigMap, Name: "database"}
+		assert.NoError(t, Export(ctx, c, resource, allOutputs, nil))
+
+		configMap := &corev1.ConfigMap{}
+		assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: "database"}, configMap))
+		assert.Equal(t, "checkout-prod.rds", configMap.Data["endpoint"])
+
+		err := c.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: "database"}, &corev1.Secret{})
+		assert.True(t, apierrors.IsNotFound(err))
+
+		assert.NoError(t, Unexport(ctx, c, resource))
+
+		err = c.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: "database"}, &corev1.ConfigMap{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("We should not overwrite objects that weren't written by the Resource", func(t *testing.T) {
+		existing := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "database", Namespace: "checkout"},
+			Data:       map[string]string{"endpoint": "somewhere-else"},
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+
+		resource := newResource(&resourcesv1alpha1.ResourceOutputsTarget{Kind: resourcesv1alpha1.ResourceOutputsTargetConfigMap, Name: "database"})
+		assert.Error(t, Export(ctx, c, resource, allOutputs, nil))
+
+		configMap := &corev1.ConfigMap{}
+		assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: "database"}, configMap))
+		assert.Equal(t, "somewhere-else", configMap.Data["endpoint"])
+	})
+}
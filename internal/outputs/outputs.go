@@ -0,0 +1,149 @@
+package outputs
+
+import (
+	"context"
+	"sort"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Entry holds the outputs published by a single Resource, with enough metadata to locate it
+type Entry struct {
+	ResourceGroup string         `json:"resourceGroup"`
+	Namespace     string         `json:"namespace"`
+	Resource      string         `json:"resource"`
+	ResourceRef   string         `json:"resourceRef"`
+	Placement     string         `json:"placement"`
+	Outputs       map[string]any `json:"outputs"`
+}
+
+// Query filters the indexed outputs
+type Query struct {
+	// Selector matches labels of ResourceGroups
+	Selector labels.Selector
+	// Placement restricts entries to a single placement; empty means every placement
+	Placement string
+	// Names restricts the outputs to the given keys; empty means every output
+	Names []string
+}
+
+// Index aggregates the outputs of every Resource across ResourceGroups and placements
+type Index struct {
+	client client.Client
+}
+
+func NewIndex(c client.Client) *Index {
+	return &Index{client: c}
+}
+
+func (i *Index) Search(ctx context.Context, query Query) ([]Entry, error) {
+	selector := query.Selector
+	if selector == nil {
+		selector = labels.Everything()
+	}
+
+	resourceGroups := &resourcesv1alpha1.ResourceGroupList{}
+	if err := i.client.List(ctx, resourceGroups, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0)
+
+	for _, resourceGroup := range resourceGroups.Items {
+		namespaces, err := i.namespacesOf(ctx, &resourceGroup, query.Placement)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, namespace := range namespaces {
+			listOptions := []client.ListOption{client.InNamespace(namespace)}
+			if query.Placement != "" {
+				listOptions = append(listOptions, client.MatchingLabels{resourcesv1alpha1.Group + "/placement": query.Placement})
+			}
+
+			resources := &resourcesv1alpha1.ResourceList{}
+			if err := i.client.List(ctx, resources, listOptions...); err != nil {
+				return nil, err
+			}
+
+			for _, resource := range resources.Items {
+				store, err := StoreOf(ctx, i.client, &resource)
+				if err != nil {
+					return nil, err
+				}
+
+				outputs, err := store.Load(ctx, &resource)
+				if err != nil {
+					return nil, err
+				}
+
+				outputs = filter(outputs, query.Names)
+				if len(outputs) == 0 {
+					continue
+				}
+
+				entries = append(entries, Entry{
+					ResourceGroup: resourceGroup.Name,
+					Namespace:     resource.Namespace,
+					Resource:      resource.Name,
+					ResourceRef:   resource.Spec.ResourceRef,
+					Placement:     resource.Spec.Placement,
+					Outputs:       outputs,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(a, b int) bool {
+		if entries[a].ResourceGroup != entries[b].ResourceGroup {
+			return entries[a].ResourceGroup < entries[b].ResourceGroup
+		}
+		return entries[a].Resource < entries[b].Resource
+	})
+
+	return entries, nil
+}
+
+// namespacesOf returns the namespaces a ResourceGroup deploys its resources into: one with the same name, or one to
+// each placement with the Placement namespace strategy
+func (i *Index) namespacesOf(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup, placement string) ([]string, error) {
+	if resourceGroup.Spec.NamespaceStrategy != resourcesv1alpha1.NamespacePerPlacement {
+		return []string{resourceGroup.Name}, nil
+	}
+	if placement != "" {
+		return []string{resourceGroup.NamespaceOf(placement)}, nil
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := i.client.List(ctx, namespaces, client.MatchingLabels{
+		resourcesv1alpha1.Group + "/managedBy.kind": "ResourceGroup",
+		resourcesv1alpha1.Group + "/managedBy.name": resourceGroup.Name,
+	}); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(namespaces.Items))
+	for _, namespace := range namespaces.Items {
+		names = append(names, namespace.Name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func filter(outputs map[string]any, names []string) map[string]any {
+	if len(names) == 0 {
+		return outputs
+	}
+
+	filtered := make(map[string]any)
+	for _, name := range names {
+		if value, ok := outputs[name]; ok {
+			filtered[name] = value
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,163 @@
+package outputs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_SearchOutputs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+
+	newResourceGroup := func(name string, team string) *resourcesv1alpha1.ResourceGroup {
+		return &resourcesv1alpha1.ResourceGroup{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": team}},
+		}
+	}
+
+	newResource := func(namespace, name, placement string, outputs map[string]any) *resourcesv1alpha1.Resource {
+		outputsAsJson, err := json.Marshal(outputs)
+		assert.NoError(t, err)
+
+		return &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{resourcesv1alpha1.Group + "/placement": placement},
+			},
+			Spec: resourcesv1alpha1.ResourceSpec{
+				Placement:   placement,
+				ResourceRef: "database",
+			},
+			Status: resourcesv1alpha1.ResourceStatus{
+				Outputs: &runtime.RawExtension{Raw: outputsAsJson},
+			},
+		}
+	}
+
+	objects := []client.Object{
+		newResourceGroup("checkout", "payments"),
+		newResourceGroup("billing", "payments"),
+		newResourceGroup("feed", "social"),
+		newResource("checkout", "dev.database", "dev", map[string]any{"endpoint": "checkout-dev.rds", "port": "5432"}),
+		newResource("checkout", "prod.database", "prod", map[string]any{"endpoint": "checkout-prod.rds", "port": "5432"}),
+		newResource("billing", "prod.database", "prod", map[string]any{"endpoint": "billing-prod.rds"}),
+		newResource("feed", "prod.database", "prod", map[string]any{"endpoint": "feed-prod.rds"}),
+	}
+
+	index := NewIndex(fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build())
+
+	t.Run("We should be able to find outputs using a ResourceGroup selector", func(t *testing.T) {
+		entries, err := index.Search(context.TODO(), Query{Selector: labels.SelectorFromSet(labels.Set{"team": "payments"})})
+		assert.NoError(t, err)
+
+		assert.Len(t, entries, 3)
+
+		assert.Equal(t, "billing", entries[0].ResourceGroup)
+		assert.Equal(t, "prod.database", entries[0].Resource)
+		assert.Equal(t, "billing-prod.rds", entries[0].Outputs["endpoint"])
+
+		assert.Equal(t, "checkout", entries[1].ResourceGroup)
+		assert.Equal(t, "dev.database", entries[1].Resource)
+		assert.Equal(t, "dev", entries[1].Placement)
+
+		assert.Equal(t, "checkout", entries[2].ResourceGroup)
+		assert.Equal(t, "prod.database", entries[2].Resource)
+	})
+
+	t.Run("We should be able to restrict outputs to a placement", func(t *testing.T) {
+		entries, err := index.Search(context.TODO(), Query{Placement: "prod"})
+		assert.NoError(t, err)
+
+		assert.Len(t, entries, 3)
+		for _, entry := range entries {
+			assert.Equal(t, "prod", entry.Placement)
+		}
+	})
+
+	t.Run("We should be able to restrict outputs by name", func(t *testing.T) {
+		entries, err := index.Search(context.TODO(), Query{
+			Selector: labels.SelectorFromSet(labels.Set{"team": "payments"}),
+			Names:    []string{"port"},
+		})
+		assert.NoError(t, err)
+
+		assert.Len(t, entries, 2)
+		for _, entry := range entries {
+			assert.Equal(t, map[string]any{"port": "5432"}, entry.Outputs)
+		}
+	})
+}
+
+func Test_SearchOutputsWithNamespacePerPlacement(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	newNamespace := func(name, placement string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Labels: map[string]string{
+					resourcesv1alpha1.Group + "/managedBy.kind": "ResourceGroup",
+					resourcesv1alpha1.Group + "/managedBy.name": "checkout",
+					resourcesv1alpha1.Group + "/placement":      placement,
+				},
+			},
+		}
+	}
+
+	newResource := func(namespace, placement, endpoint string) *resourcesv1alpha1.Resource {
+		return &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "checkout." + placement + ".database",
+				Namespace: namespace,
+				Labels:    map[string]string{resourcesv1alpha1.Group + "/placement": placement},
+			},
+			Spec: resourcesv1alpha1.ResourceSpec{Placement: placement, ResourceRef: "database"},
+			Status: resourcesv1alpha1.ResourceStatus{
+				Outputs: &runtime.RawExtension{Raw: []byte(`{"endpoint":"` + endpoint + `"}`)},
+			},
+		}
+	}
+
+	objects := []client.Object{
+		&resourcesv1alpha1.ResourceGroup{
+			ObjectMeta: metav1.ObjectMeta{Name: "checkout"},
+			Spec:       resourcesv1alpha1.ResourceGroupSpec{NamespaceStrategy: resourcesv1alpha1.NamespacePerPlacement},
+		},
+		newNamespace("checkout-dev", "dev"),
+		newNamespace("checkout-prod", "prod"),
+		newResource("checkout-dev", "dev", "checkout-dev.rds"),
+		newResource("checkout-prod", "prod", "checkout-prod.rds"),
+	}
+
+	index := NewIndex(fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build())
+
+	t.Run("We should be able to find outputs from the namespace of every placement", func(t *testing.T) {
+		entries, err := index.Search(context.TODO(), Query{})
+		assert.NoError(t, err)
+
+		assert.Len(t, entries, 2)
+		assert.Equal(t, "checkout-dev", entries[0].Namespace)
+		assert.Equal(t, "checkout-prod", entries[1].Namespace)
+	})
+
+	t.Run("We should be able to restrict outputs to a placement", func(t *testing.T) {
+		entries, err := index.Search(context.TODO(), Query{Placement: "prod"})
+		assert.NoError(t, err)
+
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "checkout-prod.rds", entries[0].Outputs["endpoint"])
+	})
+}
@@ -0,0 +1,131 @@
+package outputs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// RedactedOutput replaces the value of sensitive outputs everywhere but their Secret
+const RedactedOutput = "***"
+
+// sensitiveStore keeps the sensitive outputs declared by a ResourceRef in a Secret owned by the Resource; the wrapped
+// store only gets them redacted. Expressions read the actual values through Resolve.
+type sensitiveStore struct {
+	Store
+	client client.Client
+	names  []string
+}
+
+// SelectStoreOf creates the store declared by the ResourceRef, keeping its sensitive outputs apart
+func SelectStoreOf(c client.Client, resourceRef *resourcesv1alpha1.ResourceRef) (Store, error) {
+	store, err := SelectStore(c, resourceRef.Spec.OutputStore)
+	if err != nil {
+		return nil, err
+	}
+	if len(resourceRef.Spec.SensitiveOutputs) == 0 {
+		return store, nil
+	}
+	return &sensitiveStore{Store: store, client: c, names: resourceRef.Spec.SensitiveOutputs}, nil
+}
+
+// SensitiveOutputsSecretName is the name of the Secret holding the sensitive outputs of a Resource
+func SensitiveOutputsSecretName(resource *resourcesv1alpha1.Resource) string {
+	return fmt.Sprintf("%s-sensitive-outputs", resource.Name)
+}
+
+func (s *sensitiveStore) Save(ctx context.Context, resource *resourcesv1alpha1.Resource, outputs resourcesv1alpha1.ResourceOutputs) error {
+	sensitive := make(map[string][]byte)
+	for _, name := range s.names {
+		value, ok := outputs[name]
+		if !ok {
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		sensitive[name] = encoded
+	}
+
+	secret := &corev1.Secret{}
+	secret.Namespace = resource.Namespace
+	secret.Name = SensitiveOutputsSecretName(resource)
+
+	_, err := controllerutil.CreateOrUpdate(ctx, s.client, secret, func() error {
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = sensitive
+		secret.Labels = storedByLabelsOf(resource)
+		return controllerutil.SetControllerReference(resource, secret, s.client.Scheme())
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Store.Save(ctx, resource, Redact(outputs, s.names))
+}
+
+func (s *sensitiveStore) Delete(ctx context.Context, resource *resourcesv1alpha1.Resource) error {
+	secret := &corev1.Secret{}
+	secret.Namespace = resource.Namespace
+	secret.Name = SensitiveOutputsSecretName(resource)
+
+	if err := client.IgnoreNotFound(s.client.Delete(ctx, secret)); err != nil {
+		return err
+	}
+	return s.Store.Delete(ctx, resource)
+}
+
+// reveal loads the outputs with the actual values of the sensitive ones
+func (s *sensitiveStore) reveal(ctx context.Context, resource *resourcesv1alpha1.Resource) (resourcesv1alpha1.ResourceOutputs, error) {
+	outputs, err := s.Store.Load(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{}
+	if err := s.client.Get(ctx, types.NamespacedName{Namespace: resource.Namespace, Name: SensitiveOutputsSecretName(resource)}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return outputs, nil
+		}
+		return nil, err
+	}
+
+	revealed := make(resourcesv1alpha1.ResourceOutputs)
+	for name, value := range outputs {
+		revealed[name] = value
+	}
+	for name, encoded := range secret.Data {
+		var value any
+		if err := json.Unmarshal(encoded, &value); err != nil {
+			value = string(encoded)
+		}
+		revealed[name] = value
+	}
+
+	return revealed, nil
+}
+
+// Redact returns a copy of the outputs with the value of the sensitive ones replaced by RedactedOutput
+func Redact(outputs resourcesv1alpha1.ResourceOutputs, sensitive []string) resourcesv1alpha1.ResourceOutputs {
+	if outputs == nil || len(sensitive) == 0 {
+		return outputs
+	}
+
+	redacted := make(resourcesv1alpha1.ResourceOutputs)
+	for name, value := range outputs {
+		if slices.Contains(sensitive, name) {
+			value = RedactedOutput
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
@@ -0,0 +1,92 @@
+package outputs
+
+import (
+	"context"
+	"testing"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_SensitiveOutputs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	resourceRef := &resourcesv1alpha1.ResourceRef{
+		ObjectMeta: metav1.ObjectMeta{Name: "database"},
+		Spec:       resourcesv1alpha1.ResourceRefSpec{SensitiveOutputs: []string{"password"}},
+	}
+
+	allOutputs := resourcesv1alpha1.ResourceOutputs{
+		"endpoint": "checkout-prod.rds",
+		"password": "s3cr3t",
+	}
+
+	newResource := func() *resourcesv1alpha1.Resource {
+		return &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod.database", Namespace: "checkout", UID: "my-uid"},
+			Spec:       resourcesv1alpha1.ResourceSpec{ResourceRef: resourceRef.Name},
+		}
+	}
+
+	ctx := context.TODO()
+
+	t.Run("We should keep sensitive outputs in a Secret, and redact them in the store", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(resourceRef).Build()
+		resource := newResource()
+
+		store, err := SelectStoreOf(c, resourceRef)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Save(ctx, resource, allOutputs))
+
+		inStatus, err := resource.Status.GetOutputs()
+		assert.NoError(t, err)
+		assert.Equal(t, "checkout-prod.rds", inStatus["endpoint"])
+		assert.Equal(t, RedactedOutput, inStatus["password"])
+
+		secret := &corev1.Secret{}
+		assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: "prod.database-sensitive-outputs"}, secret))
+		assert.Equal(t, `"s3cr3t"`, string(secret.Data["password"]))
+		assert.NotContains(t, secret.Data, "endpoint")
+
+		t.Run("...and resolve their actual values to expressions", func(t *testing.T) {
+			resolved, err := Resolve(ctx, c, resource)
+			assert.NoError(t, err)
+
+			outputs, err := resolved.Status.GetOutputs()
+			assert.NoError(t, err)
+			assert.Equal(t, "s3cr3t", outputs["password"])
+			assert.Equal(t, "checkout-prod.rds", outputs["endpoint"])
+
+			inStatus, err := resource.Status.GetOutputs()
+			assert.NoError(t, err)
+			assert.Equal(t, RedactedOutput, inStatus["password"])
+		})
+
+		t.Run("...and delete the Secret with the outputs", func(t *testing.T) {
+			assert.NoError(t, store.Delete(ctx, resource))
+
+			err := c.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: "prod.database-sensitive-outputs"}, &corev1.Secret{})
+			assert.True(t, apierrors.IsNotFound(err))
+		})
+	})
+
+	t.Run("We should only write sensitive outputs to Secrets", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		resource := newResource()
+		resource.Spec.WriteOutputsTo = &resourcesv1alpha1.ResourceOutputsTarget{Kind: resourcesv1alpha1.ResourceOutputsTargetConfigMap, Name: "database"}
+
+		assert.NoError(t, Export(ctx, c, resource, allOutputs, resourceRef.Spec.SensitiveOutputs))
+
+		configMap := &corev1.ConfigMap{}
+		assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: "database"}, configMap))
+		assert.Equal(t, RedactedOutput, configMap.Data["password"])
+	})
+}
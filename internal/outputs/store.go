@@ -0,0 +1,303 @@
+package outputs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	StatusStoreName    = resourcesv1alpha1.ResourceRefStatusOutputStore
+	SecretStoreName    = resourcesv1alpha1.ResourceRefSecretOutputStore
+	ConfigMapStoreName = resourcesv1alpha1.ResourceRefConfigMapOutputStore
+)
+
+// Store persists the outputs published by Resources. Save is called on every provisioning run, before the Resource
+// status is updated, and Delete after the provisioned infrastructure is destroyed.
+type Store interface {
+	Save(ctx context.Context, resource *resourcesv1alpha1.Resource, outputs resourcesv1alpha1.ResourceOutputs) error
+	Load(ctx context.Context, resource *resourcesv1alpha1.Resource) (resourcesv1alpha1.ResourceOutputs, error)
+	Delete(ctx context.Context, resource *resourcesv1alpha1.Resource) error
+}
+
+// StoreFactory creates a Store with the properties declared by the ResourceRef
+type StoreFactory func(client.Client, *runtime.RawExtension) (Store, error)
+
+type storeRegistry struct {
+	sync.RWMutex
+	all          map[string]StoreFactory
+	defaultStore string
+}
+
+var stores = &storeRegistry{
+	all: map[string]StoreFactory{
+		StatusStoreName:    newStatusStore,
+		SecretStoreName:    newSecretStore,
+		ConfigMapStoreName: newConfigMapStore,
+	},
+	defaultStore: StatusStoreName,
+}
+
+// builtinStores can't be replaced
+var builtinStores = []string{StatusStoreName, SecretStoreName, ConfigMapStoreName}
+
+// RegisterStore makes an external output store (like Vault or SSM) available to ResourceRefs
+func RegisterStore(name string, factory StoreFactory) error {
+	if slices.Contains(builtinStores, name) {
+		return fmt.Errorf("output store %s is built-in and can't be replaced", name)
+	}
+
+	stores.Lock()
+	defer stores.Unlock()
+
+	stores.all[name] = factory
+
+	return nil
+}
+
+// SetDefaultStore selects the store used by ResourceRefs without an output store
+func SetDefaultStore(name string) error {
+	stores.Lock()
+	defer stores.Unlock()
+
+	if _, ok := stores.all[name]; !ok {
+		return fmt.Errorf("unsupported output store: %s", name)
+	}
+	stores.defaultStore = name
+
+	return nil
+}
+
+// SelectStore creates the store declared by the ResourceRef, or the default one
+func SelectStore(c client.Client, outputStore *resourcesv1alpha1.ResourceRefOutputStore) (Store, error) {
+	stores.RLock()
+	name := stores.defaultStore
+	var properties *runtime.RawExtension
+	if outputStore != nil && outputStore.Name != "" {
+		name = outputStore.Name
+		properties = outputStore.Properties
+	}
+	factory, ok := stores.all[name]
+	stores.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported output store: %s", name)
+	}
+
+	return factory(c, properties)
+}
+
+// StoreOf creates the store holding the outputs of a Resource, from its ResourceRef
+func StoreOf(ctx context.Context, c client.Client, resource *resourcesv1alpha1.Resource) (Store, error) {
+	resourceRef := &resourcesv1alpha1.ResourceRef{}
+	if err := c.Get(ctx, types.NamespacedName{Name: resource.Spec.ResourceRef}, resourceRef); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		return SelectStore(c, nil)
+	}
+	return SelectStoreOf(c, resourceRef)
+}
+
+// statusStore keeps outputs in the Resource status; anyone able to read the Resource can read them
+type statusStore struct{}
+
+func newStatusStore(client.Client, *runtime.RawExtension) (Store, error) {
+	return &statusStore{}, nil
+}
+
+func (s *statusStore) Save(_ context.Context, resource *resourcesv1alpha1.Resource, outputs resourcesv1alpha1.ResourceOutputs) error {
+	return resource.Status.SetOutputs(outputs)
+}
+
+func (s *statusStore) Load(_ context.Context, resource *resourcesv1alpha1.Resource) (resourcesv1alpha1.ResourceOutputs, error) {
+	return resource.Status.GetOutputs()
+}
+
+func (s *statusStore) Delete(context.Context, *resourcesv1alpha1.Resource) error {
+	return nil
+}
+
+// objectStore keeps outputs in a Secret or a ConfigMap owned by the Resource, one key per output; values are encoded
+// as JSON to keep their types. The Resource status doesn't keep any output.
+type objectStore struct {
+	client    client.Client
+	newObject func() client.Object
+	data      func(client.Object) map[string][]byte
+	setData   func(client.Object, map[string][]byte)
+}
+
+func newSecretStore(c client.Client, _ *runtime.RawExtension) (Store, error) {
+	return &objectStore{
+		client:    c,
+		newObject: func() client.Object { return &corev1.Secret{} },
+		data:      func(obj client.Object) map[string][]byte { return obj.(*corev1.Secret).Data },
+		setData: func(obj client.Object, data map[string][]byte) {
+			obj.(*corev1.Secret).Type = corev1.SecretTypeOpaque
+			obj.(*corev1.Secret).Data = data
+		},
+	}, nil
+}
+
+func newConfigMapStore(c client.Client, _ *runtime.RawExtension) (Store, error) {
+	return &objectStore{
+		client:    c,
+		newObject: func() client.Object { return &corev1.ConfigMap{} },
+		data: func(obj client.Object) map[string][]byte {
+			data := make(map[string][]byte)
+			for name, value := range obj.(*corev1.ConfigMap).Data {
+				data[name] = []byte(value)
+			}
+			return data
+		},
+		setData: func(obj client.Object, data map[string][]byte) {
+			all := make(map[string]string)
+			for name, value := range data {
+				all[name] = string(value)
+			}
+			obj.(*corev1.ConfigMap).Data = all
+		},
+	}, nil
+}
+
+// OutputsObjectName is the name of the Secret or ConfigMap holding the outputs of a Resource; it can't be the
+// <name>-outputs Secret tf-controller writes the outputs of a Terraform object named after the Resource to
+func OutputsObjectName(resource *resourcesv1alpha1.Resource) string {
+	return fmt.Sprintf("%s-klaudio-outputs", resource.Name)
+}
+
+// legacyOutputsObjectName is the name outputs were stored under by older releases; it's still read until the next
+// provisioning run saves them again, and collected with the Resource owning it
+func legacyOutputsObjectName(resource *resourcesv1alpha1.Resource) string {
+	return fmt.Sprintf("%s-outputs", resource.Name)
+}
+
+func (s *objectStore) Save(ctx context.Context, resource *resourcesv1alpha1.Resource, outputs resourcesv1alpha1.ResourceOutputs) error {
+	data := make(map[string][]byte)
+	for name, value := range outputs {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		data[name] = encoded
+	}
+
+	obj := s.newObject()
+	obj.SetNamespace(resource.Namespace)
+	obj.SetName(OutputsObjectName(resource))
+
+	_, err := controllerutil.CreateOrUpdate(ctx, s.client, obj, func() error {
+		s.setData(obj, data)
+		obj.SetLabels(storedByLabelsOf(resource))
+		return controllerutil.SetControllerReference(resource, obj, s.client.Scheme())
+	})
+	if err != nil {
+		return err
+	}
+
+	resource.Status.Outputs = nil
+
+	return nil
+}
+
+func (s *objectStore) Load(ctx context.Context, resource *resourcesv1alpha1.Resource) (resourcesv1alpha1.ResourceOutputs, error) {
+	obj := s.newObject()
+	if err := s.client.Get(ctx, types.NamespacedName{Namespace: resource.Namespace, Name: OutputsObjectName(resource)}, obj); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
+		// the legacy object is only trusted when it was written by the store, not by a provisioner
+		obj = s.newObject()
+		if err := s.client.Get(ctx, types.NamespacedName{Namespace: resource.Namespace, Name: legacyOutputsObjectName(resource)}, obj); err != nil {
+			if apierrors.IsNotFound(err) {
+				return make(resourcesv1alpha1.ResourceOutputs), nil
+			}
+			return nil, err
+		}
+		if !metav1.IsControlledBy(obj, resource) {
+			return make(resourcesv1alpha1.ResourceOutputs), nil
+		}
+	}
+
+	outputs := make(resourcesv1alpha1.ResourceOutputs)
+	for name, encoded := range s.data(obj) {
+		var value any
+		if err := json.Unmarshal(encoded, &value); err != nil {
+			value = string(encoded)
+		}
+		outputs[name] = value
+	}
+
+	return outputs, nil
+}
+
+func (s *objectStore) Delete(ctx context.Context, resource *resourcesv1alpha1.Resource) error {
+	obj := s.newObject()
+	obj.SetNamespace(resource.Namespace)
+	obj.SetName(OutputsObjectName(resource))
+
+	return client.IgnoreNotFound(s.client.Delete(ctx, obj))
+}
+
+// storedByLabelsOf are the labels of the objects written by the stores; the operator only deletes Secrets labeled
+// by klaudio (see config/rbac/secret_delete_policy.yaml)
+func storedByLabelsOf(resource *resourcesv1alpha1.Resource) map[string]string {
+	return map[string]string{
+		resourcesv1alpha1.Group + "/managedBy.name": resource.Name,
+	}
+}
+
+// Resolve returns a copy of the Resource with the outputs from its store in the status, so they can be evaluated like
+// any other field
+func Resolve(ctx context.Context, c client.Client, resource *resourcesv1alpha1.Resource) (*resourcesv1alpha1.Resource, error) {
+	store, err := StoreOf(ctx, c, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := store.(*statusStore); ok {
+		return resource, nil
+	}
+
+	outputs, err := load(ctx, store, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := resource.DeepCopy()
+	if len(outputs) != 0 {
+		if err := resolved.Status.SetOutputs(outputs); err != nil {
+			return nil, err
+		}
+	}
+
+	return resolved, nil
+}
+
+// Reveal loads the outputs of a Resource from its store, with the actual values of the sensitive ones
+func Reveal(ctx context.Context, c client.Client, resource *resourcesv1alpha1.Resource) (resourcesv1alpha1.ResourceOutputs, error) {
+	store, err := StoreOf(ctx, c, resource)
+	if err != nil {
+		return nil, err
+	}
+	return load(ctx, store, resource)
+}
+
+func load(ctx context.Context, store Store, resource *resourcesv1alpha1.Resource) (resourcesv1alpha1.ResourceOutputs, error) {
+	if sensitive, ok := store.(*sensitiveStore); ok {
+		return sensitive.reveal(ctx, resource)
+	}
+	return store.Load(ctx, resource)
+}
@@ -0,0 +1,180 @@
+package outputs
+
+import (
+	"context"
+	"testing"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_OutputStores(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	newResourceRef := func(name string, outputStore *resourcesv1alpha1.ResourceRefOutputStore) *resourcesv1alpha1.ResourceRef {
+		return &resourcesv1alpha1.ResourceRef{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       resourcesv1alpha1.ResourceRefSpec{OutputStore: outputStore},
+		}
+	}
+
+	newResource := func(resourceRef string) *resourcesv1alpha1.Resource {
+		return &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod.database", Namespace: "checkout", UID: "my-uid"},
+			Spec:       resourcesv1alpha1.ResourceSpec{ResourceRef: resourceRef},
+		}
+	}
+
+	allOutputs := resourcesv1alpha1.ResourceOutputs{
+		"endpoint": "checkout-prod.rds",
+		"port":     float64(5432),
+		"public":   false,
+	}
+
+	t.Run("We should be able to keep outputs in the Resource status", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newResourceRef("database", nil)).Build()
+
+		resource := newResource("database")
+
+		store, err := StoreOf(context.TODO(), c, resource)
+		assert.NoError(t, err)
+
+		assert.NoError(t, store.Save(context.TODO(), resource, allOutputs))
+		assert.NotNil(t, resource.Status.Outputs)
+
+		loaded, err := store.Load(context.TODO(), resource)
+		assert.NoError(t, err)
+		assert.Equal(t, allOutputs, loaded)
+	})
+
+	for _, outputStore := range []string{SecretStoreName, ConfigMapStoreName} {
+		t.Run("We should be able to keep outputs in a "+outputStore+" owned by the Resource", func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(newResourceRef("database", &resourcesv1alpha1.ResourceRefOutputStore{Name: outputStore})).
+				Build()
+
+			resource := newResource("database")
+			assert.NoError(t, resource.Status.SetOutputs(resourcesv1alpha1.ResourceOutputs{"stale": "value"}))
+
+			store, err := StoreOf(context.TODO(), c, resource)
+			assert.NoError(t, err)
+
+			assert.NoError(t, store.Save(context.TODO(), resource, allOutputs))
+			assert.Nil(t, resource.Status.Outputs)
+
+			var obj client.Object = &corev1.Secret{}
+			if outputStore == ConfigMapStoreName {
+				obj = &corev1.ConfigMap{}
+			}
+			assert.NoError(t, c.Get(context.TODO(), types.NamespacedName{Namespace: "checkout", Name: "prod.database-klaudio-outputs"}, obj))
+			assert.Equal(t, types.UID("my-uid"), obj.GetOwnerReferences()[0].UID)
+			assert.Equal(t, "prod.database", obj.GetLabels()[resourcesv1alpha1.Group+"/managedBy.name"])
+
+			loaded, err := store.Load(context.TODO(), resource)
+			assert.NoError(t, err)
+			assert.Equal(t, allOutputs, loaded)
+
+			resolved, err := Resolve(context.TODO(), c, resource)
+			assert.NoError(t, err)
+
+			resolvedOutputs, err := resolved.Status.GetOutputs()
+			assert.NoError(t, err)
+			assert.Equal(t, allOutputs, resolvedOutputs)
+
+			assert.NoError(t, store.Delete(context.TODO(), resource))
+
+			loaded, err = store.Load(context.TODO(), resource)
+			assert.NoError(t, err)
+			assert.Empty(t, loaded)
+		})
+	}
+
+	t.Run("We should read outputs kept by older releases, but never the ones written by tf-controller", func(t *testing.T) {
+		resource := newResource("database")
+
+		newLegacySecret := func(owner metav1.OwnerReference) *corev1.Secret {
+			return &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "prod.database-outputs", Namespace: "checkout", OwnerReferences: []metav1.OwnerReference{owner}},
+				Data:       map[string][]byte{"endpoint": []byte(`"checkout-prod.rds"`)},
+			}
+		}
+
+		legacy := newLegacySecret(metav1.OwnerReference{
+			APIVersion: resourcesv1alpha1.GroupVersion.String(), Kind: "Resource", Name: resource.Name, UID: resource.UID, Controller: ptr.To(true),
+		})
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(newResourceRef("database", &resourcesv1alpha1.ResourceRefOutputStore{Name: SecretStoreName}), legacy).
+			Build()
+
+		store, err := StoreOf(context.TODO(), c, resource)
+		assert.NoError(t, err)
+
+		loaded, err := store.Load(context.TODO(), resource)
+		assert.NoError(t, err)
+		assert.Equal(t, resourcesv1alpha1.ResourceOutputs{"endpoint": "checkout-prod.rds"}, loaded)
+
+		terraformOutputs := newLegacySecret(metav1.OwnerReference{
+			APIVersion: "infra.contrib.fluxcd.io/v1alpha2", Kind: "Terraform", Name: resource.Name, UID: "terraform-uid", Controller: ptr.To(true),
+		})
+		c = fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(newResourceRef("database", &resourcesv1alpha1.ResourceRefOutputStore{Name: SecretStoreName}), terraformOutputs).
+			Build()
+
+		store, err = StoreOf(context.TODO(), c, resource)
+		assert.NoError(t, err)
+
+		loaded, err = store.Load(context.TODO(), resource)
+		assert.NoError(t, err)
+		assert.Empty(t, loaded)
+	})
+
+	t.Run("We should be able to search outputs kept in a Secret", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(
+				&resourcesv1alpha1.ResourceGroup{ObjectMeta: metav1.ObjectMeta{Name: "checkout"}},
+				newResourceRef("database", &resourcesv1alpha1.ResourceRefOutputStore{Name: SecretStoreName}),
+				newResource("database")).
+			Build()
+
+		resource := newResource("database")
+
+		store, err := StoreOf(context.TODO(), c, resource)
+		assert.NoError(t, err)
+		assert.NoError(t, store.Save(context.TODO(), resource, allOutputs))
+
+		entries, err := NewIndex(c).Search(context.TODO(), Query{})
+		assert.NoError(t, err)
+
+		assert.Len(t, entries, 1)
+		assert.Equal(t, map[string]any(allOutputs), entries[0].Outputs)
+	})
+
+	t.Run("Built-in output stores can't be replaced", func(t *testing.T) {
+		err := RegisterStore(SecretStoreName, newStatusStore)
+		assert.Error(t, err)
+	})
+
+	t.Run("We should be able to register an external output store", func(t *testing.T) {
+		assert.NoError(t, RegisterStore("vault", newStatusStore))
+
+		store, err := SelectStore(nil, &resourcesv1alpha1.ResourceRefOutputStore{Name: "vault"})
+		assert.NoError(t, err)
+		assert.NotNil(t, store)
+	})
+
+	t.Run("An unknown output store is an error", func(t *testing.T) {
+		_, err := SelectStore(nil, &resourcesv1alpha1.ResourceRefOutputStore{Name: "unknown"})
+		assert.Error(t, err)
+
+		assert.Error(t, SetDefaultStore("unknown"))
+	})
+}
@@ -1,20 +1,21 @@
 package provisioning
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
 
 	"github.com/go-logr/logr"
-	"github.com/gobuffalo/flect"
 	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
@@ -26,10 +27,11 @@ const CrossplaneProvisionerName = "crossplane"
 
 type CrossplaneProvisioner struct {
 	client        client.Client
-	dynamicClient *dynamic.DynamicClient
+	dynamicClient dynamic.Interface
 	scheme        *runtime.Scheme
 	log           logr.Logger
 	properties    *crossplaneProvisionerProperties
+	overrides     objectOverrides
 }
 
 type crossplaneProvisionerProperties struct {
@@ -47,12 +49,18 @@ func newCrossplaneProvisioner(c client.Client, d *dynamic.DynamicClient, scheme
 		return nil, err
 	}
 
+	overrides, err := newObjectOverrides(provisioner)
+	if err != nil {
+		return nil, err
+	}
+
 	crossplaneProvisioner := &CrossplaneProvisioner{
 		client:        c,
 		dynamicClient: d,
 		scheme:        scheme,
 		log:           log,
 		properties:    properties,
+		overrides:     overrides,
 	}
 
 	return crossplaneProvisioner, nil
@@ -77,7 +85,7 @@ func (provisioner *CrossplaneProvisioner) Run(ctx context.Context, resource *res
 
 	provisionedResource := &ProvisionedResource{
 		GroupVersionKind: obj.GroupVersionKind(),
-		Name:             resource.Name,
+		Name:             obj.GetName(),
 	}
 
 	switch objStatus.Status {
@@ -120,10 +128,16 @@ func (provisioner *CrossplaneProvisioner) Run(ctx context.Context, resource *res
 			conditionType := conditionAsMap["type"].(string)
 			conditionStatus := conditionAsMap["status"].(string)
 			if conditionType == "Ready" && conditionStatus == string(corev1.ConditionTrue) {
+				inventory, err := provisioner.readInventory(obj)
+				if err != nil {
+					return nil, err
+				}
+
 				status := &ProvisionedResourceStatus{
-					Resource: provisionedResource,
-					State:    ProvisionedResourceSuccessState,
-					Outputs:  outputs,
+					Resource:  provisionedResource,
+					State:     ProvisionedResourceSuccessState,
+					Outputs:   outputs,
+					Inventory: inventory,
 				}
 				return status, nil
 			}
@@ -141,47 +155,96 @@ func (provisioner *CrossplaneProvisioner) Run(ctx context.Context, resource *res
 	return resourceStatus, nil
 }
 
+// Destroy deletes the Crossplane object; with the Delete policy, the external resources are deleted as well.
+// With Retain, or when the Resource is protected, the object is switched to Crossplane's Orphan deletion policy first,
+// keeping the external resources.
+func (provisioner *CrossplaneProvisioner) Destroy(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
+	mapping, err := provisioner.mappingOf()
+	if err != nil {
+		return nil, err
+	}
+	objGvk := mapping.GroupVersionKind
+	key := crossplaneObjectKeyOf(mapping, resource)
+
+	policy := deletionPolicyOf(resource)
+
+	provisioner.log.Info(fmt.Sprintf("finalizing Crossplane object from %s/%s with deletion policy %s...", resource.Namespace, resource.Name, policy))
+
+	switch policy {
+	case resourcesv1alpha1.DeletionPolicyOrphan:
+		return releaseObject(ctx, provisioner.client, objGvk, key, resource)
+	case resourcesv1alpha1.DeletionPolicyRetain:
+		return deleteAndWait(ctx, provisioner.client, objGvk, key, func(obj *unstructured.Unstructured) bool {
+			unstructured.SetNestedField(obj.Object, "Orphan", "spec", "deletionPolicy")
+			return true
+		})
+	}
+
+	// the object keeps the Orphan policy written while the Resource was protected; the declared one is restored
+	specProperties := make(map[string]any)
+	if err := json.Unmarshal(resource.Spec.Properties.Raw, &specProperties); err != nil {
+		return nil, err
+	}
+	specProperties = provisioner.overrides.apply(provisioner.properties.ObjectRef.Kind, specProperties)
+	declared, _ := specProperties["deletionPolicy"].(string)
+	declared = cmp.Or(declared, "Delete")
+
+	return deleteAndWait(ctx, provisioner.client, objGvk, key, func(obj *unstructured.Unstructured) bool {
+		current, _, _ := unstructured.NestedString(obj.Object, "spec", "deletionPolicy")
+		if cmp.Or(current, "Delete") == declared {
+			return false
+		}
+		unstructured.SetNestedField(obj.Object, declared, "spec", "deletionPolicy")
+		return true
+	})
+}
+
 func (provisioner *CrossplaneProvisioner) getOrNewObj(ctx context.Context, resource *resourcesv1alpha1.Resource) (*unstructured.Unstructured, error) {
 	specProperties := make(map[string]any)
 	if err := json.Unmarshal(resource.Spec.Properties.Raw, &specProperties); err != nil {
 		return nil, err
 	}
+	specProperties = provisioner.overrides.apply(provisioner.properties.ObjectRef.Kind, specProperties)
+	// the external resources of a protected Resource are kept whatever deletes the object
+	if resource.Spec.Protect {
+		specProperties["deletionPolicy"] = "Orphan"
+	}
 
-	objGv, err := schema.ParseGroupVersion(provisioner.properties.ObjectRef.ApiVersion)
+	mapping, err := provisioner.mappingOf()
 	if err != nil {
 		return nil, err
 	}
+	objGvk := mapping.GroupVersionKind
+	key := crossplaneObjectKeyOf(mapping, resource)
 
-	nameAsPlural := flect.Pluralize(provisioner.properties.ObjectRef.Kind)
-	objResourceName := strings.ToLower(nameAsPlural)
-
-	objGvWithResource := objGv.WithResource(objResourceName)
-	objGvk := objGv.WithKind(provisioner.properties.ObjectRef.Kind)
-
-	provisioner.log.Info(fmt.Sprintf("trying to get object: %s, name %s", objGvk.String(), resource.Name))
+	provisioner.log.Info(fmt.Sprintf("trying to get object: %s, name %s", objGvk.String(), key))
 
 	obj, err := provisioner.dynamicClient.
-		Resource(objGvWithResource).
-		Namespace(resource.Namespace).
-		Get(ctx, resource.Name, metav1.GetOptions{})
+		Resource(mapping.Resource).
+		Namespace(key.Namespace).
+		Get(ctx, key.Name, metav1.GetOptions{})
 
 	if err != nil {
 		if !apierrors.IsNotFound(err) {
 			return nil, err
 		}
 
-		provisioner.log.Info(fmt.Sprintf("object %s not found. creating...", objGvWithResource.String()))
+		provisioner.log.Info(fmt.Sprintf("object %s not found. creating...", mapping.Resource.String()))
 
 		obj = &unstructured.Unstructured{}
-		obj.SetGroupVersionKind(objGv.WithKind(provisioner.properties.ObjectRef.Kind))
+		obj.SetGroupVersionKind(objGvk)
+
+		metadata := map[string]any{
+			"name": key.Name,
+		}
+		if key.Namespace != "" {
+			metadata["namespace"] = key.Namespace
+		}
 
 		content := make(map[string]any)
 		content["apiVersion"] = provisioner.properties.ObjectRef.ApiVersion
 		content["kind"] = provisioner.properties.ObjectRef.Kind
-		content["metadata"] = map[string]any{
-			"name":      resource.Name,
-			"namespace": resource.Namespace,
-		}
+		content["metadata"] = metadata
 		content["spec"] = specProperties
 
 		obj.SetUnstructuredContent(content)
@@ -198,26 +261,107 @@ func (provisioner *CrossplaneProvisioner) getOrNewObj(ctx context.Context, resou
 			resourcesv1alpha1.Group + "/managedBy.name":    resource.Name,
 			resourcesv1alpha1.Group + "/placement":         resource.Spec.Placement,
 		})
-		obj.SetOwnerReferences([]metav1.OwnerReference{
-			{
-				APIVersion:         resourceGkv.GroupVersion().String(),
-				Kind:               resourceGkv.Kind,
-				Name:               resource.Name,
-				UID:                resource.UID,
-				BlockOwnerDeletion: ptr.To(true),
-				Controller:         ptr.To(true),
-			},
-		})
+		// a cluster-scoped object can't be owned by a namespaced Resource; it's only tracked by its labels, and deleted
+		// by Destroy
+		if key.Namespace != "" {
+			obj.SetOwnerReferences([]metav1.OwnerReference{
+				{
+					APIVersion:         resourceGkv.GroupVersion().String(),
+					Kind:               resourceGkv.Kind,
+					Name:               resource.Name,
+					UID:                resource.UID,
+					BlockOwnerDeletion: ptr.To(true),
+					Controller:         ptr.To(true),
+				},
+			})
+		}
 
-		if err := provisioner.client.Create(ctx, obj); err != nil {
+		applyPassThroughMetadata(obj, resource)
+
+		obj, err = provisioner.dynamicClient.Resource(mapping.Resource).Namespace(key.Namespace).Create(ctx, obj, metav1.CreateOptions{})
+		if err != nil {
 			return nil, err
 		}
 	} else {
 		obj.Object["spec"] = specProperties
-		if err := provisioner.client.Update(ctx, obj); err != nil {
+		applyPassThroughMetadata(obj, resource)
+		obj, err = provisioner.dynamicClient.Resource(mapping.Resource).Namespace(key.Namespace).Update(ctx, obj, metav1.UpdateOptions{})
+		if err != nil {
 			return nil, err
 		}
 	}
 
 	return obj, nil
 }
+
+// mappingOf resolves the resource and scope of the objectRef through the RESTMapper, so irregular plurals and
+// cluster-scoped composites and managed resources are handled like the API server does
+func (provisioner *CrossplaneProvisioner) mappingOf() (*meta.RESTMapping, error) {
+	objGv, err := schema.ParseGroupVersion(provisioner.properties.ObjectRef.ApiVersion)
+	if err != nil {
+		return nil, err
+	}
+	objGvk := objGv.WithKind(provisioner.properties.ObjectRef.Kind)
+
+	mapping, err := provisioner.client.RESTMapper().RESTMapping(objGvk.GroupKind(), objGvk.Version)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find the resource of %s; are its CRDs installed? %w", objGvk, err)
+	}
+	return mapping, nil
+}
+
+// crossplaneObjectKeyOf is the object of the Resource. Cluster-scoped objects are shared by every namespace, so their
+// names are prefixed by the namespace of the Resource, unless the Resource names its object.
+func crossplaneObjectKeyOf(mapping *meta.RESTMapping, resource *resourcesv1alpha1.Resource) types.NamespacedName {
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return types.NamespacedName{Namespace: resource.Namespace, Name: objectNameOf(resource)}
+	}
+	if resource.Spec.ProvisionerObjectName != "" {
+		return types.NamespacedName{Name: resource.Spec.ProvisionerObjectName}
+	}
+	return types.NamespacedName{Name: fmt.Sprintf("%s-%s", resource.Namespace, resource.Name)}
+}
+
+// readInventory collects the managed resources behind a Crossplane object: composed resources for a composite,
+// the bound composite for a claim, or the object itself when it's a managed resource.
+func (provisioner *CrossplaneProvisioner) readInventory(obj *unstructured.Unstructured) ([]ProvisionedInventoryEntry, error) {
+	newEntry := func(ref map[string]any) ProvisionedInventoryEntry {
+		apiVersion, _, _ := unstructured.NestedString(ref, "apiVersion")
+		kind, _, _ := unstructured.NestedString(ref, "kind")
+		name, _, _ := unstructured.NestedString(ref, "name")
+		return ProvisionedInventoryEntry{
+			Type: fmt.Sprintf("%s/%s", apiVersion, kind),
+			Name: name,
+		}
+	}
+
+	resourceRefs, exists, err := unstructured.NestedSlice(obj.Object, "spec", "resourceRefs")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		inventory := make([]ProvisionedInventoryEntry, 0, len(resourceRefs))
+		for _, ref := range resourceRefs {
+			if refAsMap, ok := ref.(map[string]any); ok {
+				inventory = append(inventory, newEntry(refAsMap))
+			}
+		}
+		return inventory, nil
+	}
+
+	resourceRef, exists, err := unstructured.NestedMap(obj.Object, "spec", "resourceRef")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return []ProvisionedInventoryEntry{newEntry(resourceRef)}, nil
+	}
+
+	return []ProvisionedInventoryEntry{
+		{
+			Type: fmt.Sprintf("%s/%s", obj.GetAPIVersion(), obj.GetKind()),
+			Name: obj.GetName(),
+			ID:   obj.GetAnnotations()["crossplane.io/external-name"],
+		},
+	}, nil
+}
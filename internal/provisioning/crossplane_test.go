@@ -0,0 +1,138 @@
+package provisioning
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_CrossplaneObjects(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+
+	ctx := context.TODO()
+
+	newResource := func() *resourcesv1alpha1.Resource {
+		return &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders-database", Namespace: "checkout", UID: "8d0c1f5e"},
+			Spec: resourcesv1alpha1.ResourceSpec{
+				ResourceRef: "database",
+				Placement:   "prod",
+				Properties:  &runtime.RawExtension{Raw: []byte(`{"engine":"postgres"}`)},
+			},
+		}
+	}
+
+	// a claim, with a plural flect wouldn't guess, and a cluster-scoped composite
+	claimGvk := schema.GroupVersionKind{Group: "database.nubank.io", Version: "v1alpha1", Kind: "PostgreSQLInstance"}
+	claimGvr := claimGvk.GroupVersion().WithResource("pginstances")
+	compositeGvk := schema.GroupVersionKind{Group: "database.nubank.io", Version: "v1alpha1", Kind: "XPostgreSQLInstance"}
+	compositeGvr := compositeGvk.GroupVersion().WithResource("xpostgresqlinstances")
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.AddSpecific(claimGvk, claimGvr, claimGvk.GroupVersion().WithResource("pginstance"), meta.RESTScopeNamespace)
+	mapper.Add(compositeGvk, meta.RESTScopeRoot)
+
+	newProvisioner := func(t *testing.T, kind string) (*CrossplaneProvisioner, *dynamicfake.FakeDynamicClient) {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).Build()
+
+		provisioner, err := newCrossplaneProvisioner(c, nil, scheme, logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       CrossplaneProvisionerName,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"objectRef":{"apiVersion":"database.nubank.io/v1alpha1","kind":"` + kind + `"}}`)},
+		})
+		assert.NoError(t, err)
+
+		dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			claimGvr:     "PostgreSQLInstanceList",
+			compositeGvr: "XPostgreSQLInstanceList",
+		})
+
+		crossplaneProvisioner := provisioner.(*CrossplaneProvisioner)
+		crossplaneProvisioner.dynamicClient = dynamicClient
+		return crossplaneProvisioner, dynamicClient
+	}
+
+	t.Run("We should resolve the resource of the object with the RESTMapper", func(t *testing.T) {
+		provisioner, dynamicClient := newProvisioner(t, "PostgreSQLInstance")
+
+		obj, err := provisioner.getOrNewObj(ctx, newResource())
+		assert.NoError(t, err)
+		assert.Equal(t, "checkout", obj.GetNamespace())
+		assert.Len(t, obj.GetOwnerReferences(), 1)
+
+		stored, err := dynamicClient.Resource(claimGvr).Namespace("checkout").Get(ctx, "orders-database", metav1.GetOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "PostgreSQLInstance", stored.GetKind())
+
+		// the next run updates the same object
+		_, err = provisioner.getOrNewObj(ctx, newResource())
+		assert.NoError(t, err)
+	})
+
+	t.Run("We should create cluster-scoped objects without a namespace or owners", func(t *testing.T) {
+		provisioner, dynamicClient := newProvisioner(t, "XPostgreSQLInstance")
+
+		obj, err := provisioner.getOrNewObj(ctx, newResource())
+		assert.NoError(t, err)
+		assert.Equal(t, "", obj.GetNamespace())
+		assert.Equal(t, "checkout-orders-database", obj.GetName())
+		assert.Empty(t, obj.GetOwnerReferences())
+		assert.Equal(t, "orders-database", obj.GetLabels()[resourcesv1alpha1.Group+"/managedBy.name"])
+
+		_, err = dynamicClient.Resource(compositeGvr).Get(ctx, "checkout-orders-database", metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("We should orphan the external resources of a protected Resource", func(t *testing.T) {
+		provisioner, _ := newProvisioner(t, "PostgreSQLInstance")
+
+		resource := newResource()
+		resource.Spec.Protect = true
+
+		obj, err := provisioner.getOrNewObj(ctx, resource)
+		assert.NoError(t, err)
+
+		deletionPolicy, _, _ := unstructured.NestedString(obj.Object, "spec", "deletionPolicy")
+		assert.Equal(t, "Orphan", deletionPolicy)
+	})
+
+	t.Run("We should restore the declared deletion policy once the Resource isn't protected anymore", func(t *testing.T) {
+		provisioner, _ := newProvisioner(t, "PostgreSQLInstance")
+
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(claimGvk)
+		obj.SetNamespace("checkout")
+		obj.SetName("orders-database")
+		// held by Crossplane while the external resources are deleted
+		obj.SetFinalizers([]string{"finalizer.managedresource.crossplane.io"})
+		assert.NoError(t, unstructured.SetNestedField(obj.Object, "Orphan", "spec", "deletionPolicy"))
+		assert.NoError(t, provisioner.client.Create(ctx, obj))
+
+		status, err := provisioner.Destroy(ctx, newResource())
+		assert.NoError(t, err)
+		assert.True(t, status.IsRunning())
+
+		assert.NoError(t, provisioner.client.Get(ctx, client.ObjectKeyFromObject(obj), obj))
+		deletionPolicy, _, _ := unstructured.NestedString(obj.Object, "spec", "deletionPolicy")
+		assert.Equal(t, "Delete", deletionPolicy)
+		assert.NotNil(t, obj.GetDeletionTimestamp())
+	})
+
+	t.Run("We should fail when the CRDs of the object aren't installed", func(t *testing.T) {
+		provisioner, _ := newProvisioner(t, "MySQLInstance")
+
+		_, err := provisioner.getOrNewObj(ctx, newResource())
+		assert.ErrorContains(t, err, "are its CRDs installed?")
+	})
+}
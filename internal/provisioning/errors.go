@@ -0,0 +1,99 @@
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// transientError marks an error that is expected to go away by itself, like API throttling
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// terminalError marks an error that retrying won't fix, like an invalid configuration
+type terminalError struct {
+	err error
+}
+
+func (e *terminalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *terminalError) Unwrap() error {
+	return e.err
+}
+
+// Transient marks an error from a provisioner as worth retrying
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// Terminal marks an error from a provisioner as not worth retrying
+func Terminal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &terminalError{err: err}
+}
+
+// IsTransient classifies an error returned by a provisioner. Errors explicitly marked by Transient or Terminal are
+// classified as such; otherwise, throttling, timeouts and unavailability from the Kubernetes API, the network or
+// plugins are transient. Anything else is considered terminal.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var terminal *terminalError
+	if errors.As(err, &terminal) {
+		return false
+	}
+
+	var transient *transientError
+	if errors.As(err, &transient) {
+		return true
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	if apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsConflict(err) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded, codes.Aborted:
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,44 @@
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func Test_IsTransient(t *testing.T) {
+
+	t.Run("Throttling and unavailability from the Kubernetes API are transient", func(t *testing.T) {
+		assert.True(t, IsTransient(apierrors.NewTooManyRequests("slow down", 1)))
+		assert.True(t, IsTransient(apierrors.NewServiceUnavailable("unavailable")))
+		assert.True(t, IsTransient(fmt.Errorf("unable to create Terraform object: %w", apierrors.NewTimeoutError("timeout", 1))))
+	})
+
+	t.Run("Invalid objects from the Kubernetes API are terminal", func(t *testing.T) {
+		assert.False(t, IsTransient(apierrors.NewBadRequest("invalid")))
+		assert.False(t, IsTransient(apierrors.NewForbidden(schema.GroupResource{Resource: "stacks"}, "my-stack", errors.New("forbidden"))))
+	})
+
+	t.Run("Unavailable plugins are transient", func(t *testing.T) {
+		assert.True(t, IsTransient(fmt.Errorf("plugin my-plugin failed: %w", status.Error(codes.Unavailable, "connection refused"))))
+		assert.False(t, IsTransient(fmt.Errorf("plugin my-plugin failed: %w", status.Error(codes.InvalidArgument, "invalid properties"))))
+	})
+
+	t.Run("Deadlines are transient", func(t *testing.T) {
+		assert.True(t, IsTransient(fmt.Errorf("request failed: %w", context.DeadlineExceeded)))
+	})
+
+	t.Run("Errors can be explicitly marked", func(t *testing.T) {
+		assert.True(t, IsTransient(Transient(errors.New("try again"))))
+		assert.False(t, IsTransient(Terminal(apierrors.NewTooManyRequests("slow down", 1))))
+		assert.False(t, IsTransient(errors.New("unknown error")))
+		assert.False(t, IsTransient(nil))
+	})
+}
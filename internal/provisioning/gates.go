@@ -0,0 +1,39 @@
+package provisioning
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// ErrProvisionerDisabled is returned by SelectByName for provisioners disabled by the feature gates of the installation
+var ErrProvisionerDisabled = errors.New("provisioner is disabled in this installation")
+
+type provisionerGates struct {
+	sync.RWMutex
+	disabled sets.Set[string]
+}
+
+var gates = &provisionerGates{disabled: sets.New[string]()}
+
+// SetDisabledProvisioners replaces the provisioners disabled cluster-wide; SelectByName refuses them
+func SetDisabledProvisioners(names ...string) {
+	gates.Lock()
+	defer gates.Unlock()
+
+	gates.disabled = sets.New(names...)
+}
+
+// IsProvisionerDisabled tells whether a provisioner is disabled cluster-wide
+func IsProvisionerDisabled(name string) bool {
+	gates.RLock()
+	defer gates.RUnlock()
+
+	return gates.disabled.Has(name)
+}
+
+func disabledProvisionerError(name string) error {
+	return fmt.Errorf("%w: %s", ErrProvisionerDisabled, name)
+}
@@ -0,0 +1,402 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const HelmProvisionerName = "helm"
+
+// helmDefaultInterval is used when the provisioner doesn't set one; Flux requires spec.interval on both objects.
+const helmDefaultInterval = "10m"
+
+type HelmProvisioner struct {
+	client        client.Client
+	dynamicClient *dynamic.DynamicClient
+	scheme        *runtime.Scheme
+	log           logr.Logger
+	properties    *helmProvisionerProperties
+	overrides     objectOverrides
+}
+
+type helmProvisionerProperties struct {
+	Chart    helmProvisionerChartProperties `json:"chart"`
+	Interval *string                        `json:"interval"`
+}
+
+type helmProvisionerChartProperties struct {
+	Repo    string  `json:"repo"`
+	Name    string  `json:"name"`
+	Version *string `json:"version"`
+}
+
+// Destroy deletes the HelmRelease, uninstalling the chart; the HelmRepository is shared by the ResourceRef and kept.
+// With Retain, the release is suspended first: helm-controller doesn't uninstall suspended releases.
+func (provisioner *HelmProvisioner) Destroy(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
+	releaseGvk := schema.GroupVersionKind{
+		Group:   "helm.toolkit.fluxcd.io",
+		Version: "v2",
+		Kind:    "HelmRelease",
+	}
+	key := types.NamespacedName{Namespace: resource.Namespace, Name: objectNameOf(resource)}
+
+	policy := deletionPolicyOf(resource)
+
+	provisioner.log.Info(fmt.Sprintf("finalizing HelmRelease from %s/%s with deletion policy %s...", resource.Namespace, resource.Name, policy))
+
+	switch policy {
+	case resourcesv1alpha1.DeletionPolicyOrphan:
+		return releaseObject(ctx, provisioner.client, releaseGvk, key, resource)
+	case resourcesv1alpha1.DeletionPolicyRetain:
+		return deleteAndWait(ctx, provisioner.client, releaseGvk, key, func(release *unstructured.Unstructured) bool {
+			unstructured.SetNestedField(release.Object, true, "spec", "suspend")
+			return true
+		})
+	}
+
+	return deleteAndWait(ctx, provisioner.client, releaseGvk, key, nil)
+}
+
+func newHelmProvisioner(c client.Client, d *dynamic.DynamicClient, scheme *runtime.Scheme, log logr.Logger, provisioner *resourcesv1alpha1.ResourceRefProvisioner) (Provisioner, error) {
+	properties := &helmProvisionerProperties{}
+	if err := json.Unmarshal(provisioner.Properties.Raw, properties); err != nil {
+		return nil, err
+	}
+	if properties.Interval == nil {
+		properties.Interval = ptr.To(helmDefaultInterval)
+	}
+
+	overrides, err := newObjectOverrides(provisioner)
+	if err != nil {
+		return nil, err
+	}
+
+	helmProvisioner := &HelmProvisioner{
+		client:        c,
+		dynamicClient: d,
+		scheme:        scheme,
+		log:           log,
+		properties:    properties,
+		overrides:     overrides,
+	}
+
+	return helmProvisioner, nil
+}
+
+func (provisioner *HelmProvisioner) Run(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
+	provisioner.log.Info(fmt.Sprintf("starting Helm provisioner to resource %s/%s...", resource.Namespace, resource.Name))
+
+	repo, err := provisioner.getOrNewRepo(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	provisioner.log.Info(fmt.Sprintf("using HelmRepository: %s", repo.GetName()))
+
+	release, err := provisioner.getOrNewRelease(ctx, repo.GetName(), resource)
+	if err != nil {
+		return nil, err
+	}
+
+	provisioner.log.Info(fmt.Sprintf("running HelmRelease: %s", release.GetName()))
+
+	releaseStatus, err := status.Compute(release)
+	if err != nil {
+		return nil, err
+	}
+
+	provisioner.log.Info(fmt.Sprintf("status from HelmRelease object %s is: %+v", release.GetName(), releaseStatus))
+
+	provisionedResource := &ProvisionedResource{
+		GroupVersionKind: release.GroupVersionKind(),
+		Name:             release.GetName(),
+	}
+
+	switch releaseStatus.Status {
+
+	case status.InProgressStatus:
+		status := &ProvisionedResourceStatus{
+			Resource: provisionedResource,
+			State:    ProvisionedResourceRunningState,
+			Outputs:  make(map[string]any),
+		}
+		return status, nil
+
+	case status.FailedStatus:
+		status := &ProvisionedResourceStatus{
+			Resource: provisionedResource,
+			State:    ProvisionedResourceFailedState,
+			Outputs:  make(map[string]any),
+		}
+		return status, nil
+	}
+
+	conditions, exists, err := unstructured.NestedSlice(release.Object, "status", "conditions")
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		for _, condition := range conditions {
+			conditionAsMap := condition.(map[string]any)
+
+			conditionType := conditionAsMap["type"].(string)
+			conditionStatus := conditionAsMap["status"].(string)
+			if conditionType == "Ready" && conditionStatus == string(corev1.ConditionTrue) {
+				outputs, err := provisioner.readReleaseOutputs(release)
+				if err != nil {
+					return nil, err
+				}
+
+				status := &ProvisionedResourceStatus{
+					Resource: provisionedResource,
+					State:    ProvisionedResourceSuccessState,
+					Outputs:  outputs,
+
+					HandledReconcileRequest: handledReconcileRequestOf(release),
+				}
+				return status, nil
+			}
+		}
+	}
+
+	provisioner.log.Info(fmt.Sprintf("can't determine the Helm provisioning status for object %s yet; keep running...", release.GetName()))
+
+	resourceStatus := &ProvisionedResourceStatus{
+		Resource: provisionedResource,
+		State:    ProvisionedResourceRunningState,
+		Outputs:  make(map[string]any),
+	}
+
+	return resourceStatus, nil
+}
+
+func (provisioner *HelmProvisioner) getOrNewRepo(ctx context.Context, resource *resourcesv1alpha1.Resource) (*unstructured.Unstructured, error) {
+	repoGvk := schema.GroupVersionKind{
+		Group:   "source.toolkit.fluxcd.io",
+		Version: "v1",
+		Kind:    "HelmRepository",
+	}
+
+	repoGvWithResource := repoGvk.GroupVersion().WithResource("helmrepositories")
+
+	newSpec := func() map[string]any {
+		spec := map[string]any{
+			"interval": *provisioner.properties.Interval,
+			"url":      provisioner.properties.Chart.Repo,
+		}
+		if strings.HasPrefix(provisioner.properties.Chart.Repo, "oci://") {
+			spec["type"] = "oci"
+		}
+		return provisioner.overrides.apply("HelmRepository", spec)
+	}
+
+	repo, err := provisioner.dynamicClient.
+		Resource(repoGvWithResource).
+		Namespace(resource.Namespace).
+		Get(ctx, resource.Spec.ResourceRef, metav1.GetOptions{})
+
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
+		repo = &unstructured.Unstructured{}
+		repo.SetGroupVersionKind(repoGvk)
+
+		content := make(map[string]any)
+		content["apiVersion"] = "source.toolkit.fluxcd.io/v1"
+		content["kind"] = "HelmRepository"
+		content["metadata"] = map[string]any{
+			"name":      resource.Spec.ResourceRef,
+			"namespace": resource.Namespace,
+		}
+		content["spec"] = newSpec()
+
+		repo.SetUnstructuredContent(content)
+
+		resourceRef := &resourcesv1alpha1.ResourceRef{}
+		if err := provisioner.client.Get(ctx, types.NamespacedName{Name: resource.Spec.ResourceRef}, resourceRef); err != nil {
+			provisioner.log.Error(err, fmt.Sprintf("unable to fetch ResourceRef %s", resource.Spec.ResourceRef))
+			return nil, err
+		}
+
+		resourceRefGvk := resourceRef.GroupVersionKind()
+
+		repo.SetLabels(map[string]string{
+			"name":      resource.Name,
+			"namespace": resource.Namespace,
+			resourcesv1alpha1.Group + "/managedBy.group":   resourceRefGvk.Group,
+			resourcesv1alpha1.Group + "/managedBy.version": resourceRefGvk.Version,
+			resourcesv1alpha1.Group + "/managedBy.kind":    resourceRefGvk.Kind,
+			resourcesv1alpha1.Group + "/managedBy.name":    resourceRef.Name,
+		})
+		repo.SetOwnerReferences([]metav1.OwnerReference{
+			{
+				APIVersion:         resourceRefGvk.GroupVersion().String(),
+				Kind:               resourceRefGvk.Kind,
+				Name:               resourceRef.Name,
+				UID:                resourceRef.UID,
+				Controller:         ptr.To(true),
+				BlockOwnerDeletion: ptr.To(true),
+			},
+		})
+
+		if err := provisioner.client.Create(ctx, repo); err != nil {
+			return nil, err
+		}
+	} else {
+		repo.Object["spec"] = newSpec()
+		if err := provisioner.client.Update(ctx, repo); err != nil {
+			return nil, err
+		}
+	}
+
+	return repo, nil
+}
+
+func (provisioner *HelmProvisioner) getOrNewRelease(ctx context.Context, helmRepoRef string, resource *resourcesv1alpha1.Resource) (*unstructured.Unstructured, error) {
+	values := make(map[string]any)
+	if err := json.Unmarshal(resource.Spec.Properties.Raw, &values); err != nil {
+		return nil, err
+	}
+
+	newSpec := func() map[string]any {
+		return provisioner.overrides.apply("HelmRelease", map[string]any{
+			"interval": *provisioner.properties.Interval,
+			"chart": map[string]any{
+				"spec": map[string]any{
+					"chart":   provisioner.properties.Chart.Name,
+					"version": provisioner.properties.Chart.Version,
+					"sourceRef": map[string]any{
+						"kind":      "HelmRepository",
+						"name":      helmRepoRef,
+						"namespace": resource.Namespace,
+					},
+				},
+			},
+			"values": values,
+		})
+	}
+
+	releaseGvk := schema.GroupVersionKind{
+		Group:   "helm.toolkit.fluxcd.io",
+		Version: "v2",
+		Kind:    "HelmRelease",
+	}
+
+	releaseGvWithResource := releaseGvk.GroupVersion().WithResource("helmreleases")
+
+	release, err := provisioner.dynamicClient.
+		Resource(releaseGvWithResource).
+		Namespace(resource.Namespace).
+		Get(ctx, objectNameOf(resource), metav1.GetOptions{})
+
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
+		release = &unstructured.Unstructured{}
+		release.SetGroupVersionKind(releaseGvk)
+
+		object := make(map[string]any)
+
+		object["apiVersion"] = "helm.toolkit.fluxcd.io/v2"
+		object["kind"] = "HelmRelease"
+		object["metadata"] = map[string]any{
+			"name":      objectNameOf(resource),
+			"namespace": resource.Namespace,
+		}
+		object["spec"] = newSpec()
+
+		release.SetUnstructuredContent(object)
+
+		resourceGkv, err := apiutil.GVKForObject(resource, provisioner.scheme)
+		if err != nil {
+			return nil, err
+		}
+		release.SetLabels(map[string]string{
+			"name":      resource.Name,
+			"namespace": resource.Namespace,
+			resourcesv1alpha1.Group + "/managedBy.group":   resourceGkv.Group,
+			resourcesv1alpha1.Group + "/managedBy.version": resourceGkv.Version,
+			resourcesv1alpha1.Group + "/managedBy.kind":    resourceGkv.Kind,
+			resourcesv1alpha1.Group + "/managedBy.name":    resource.Name,
+			resourcesv1alpha1.Group + "/placement":         resource.Spec.Placement,
+		})
+		release.SetOwnerReferences([]metav1.OwnerReference{
+			{
+				APIVersion:         resourceGkv.GroupVersion().String(),
+				Kind:               resourceGkv.Kind,
+				Name:               resource.Name,
+				UID:                resource.UID,
+				BlockOwnerDeletion: ptr.To(true),
+				Controller:         ptr.To(true),
+			},
+		})
+
+		applyPassThroughMetadata(release, resource)
+
+		if err := provisioner.client.Create(ctx, release); err != nil {
+			return nil, err
+		}
+	} else {
+		release.Object["spec"] = newSpec()
+		applyPassThroughMetadata(release, resource)
+		if err := provisioner.client.Update(ctx, release); err != nil {
+			return nil, err
+		}
+	}
+
+	return release, nil
+}
+
+// readReleaseOutputs exposes the latest release snapshot from the HelmRelease history as outputs;
+// rendered chart notes are not kept by Flux, so they are not available here.
+func (provisioner *HelmProvisioner) readReleaseOutputs(release *unstructured.Unstructured) (map[string]any, error) {
+	outputs := map[string]any{
+		"releaseName":      release.GetName(),
+		"releaseNamespace": release.GetNamespace(),
+	}
+
+	history, exists, err := unstructured.NestedSlice(release.Object, "status", "history")
+	if err != nil {
+		return nil, err
+	}
+	if !exists || len(history) == 0 {
+		return outputs, nil
+	}
+
+	latest, ok := history[0].(map[string]any)
+	if !ok {
+		return outputs, nil
+	}
+
+	for _, field := range []string{"chartName", "chartVersion", "appVersion", "version", "status"} {
+		if value, ok := latest[field]; ok {
+			outputs[field] = value
+		}
+	}
+
+	provisioner.log.Info(fmt.Sprintf("outputs available from HelmRelease object %s are: %+v", release.GetName(), outputs))
+
+	return outputs, nil
+}
@@ -0,0 +1,481 @@
+package provisioning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// HttpProvisionerName integrates services that are only reachable through HTTP (ticketing systems, legacy APIs).
+//
+// For every new generation of the Resource spec, the evaluated properties are sent to the configured endpoint:
+//
+//	POST <url> {"name": "...", "namespace": "...", "placement": "...", "generation": 1, "properties": {...}}
+//
+// The service answers with the URL to be polled, as a "statusUrl" field or a Location header; the status URL must answer
+//
+//	GET <statusUrl> {"state": "Running|Success|Failed", "outputs": {...}, "message": "..."}
+//
+// When the service doesn't return a status URL, the submission response itself is the final status (Success, if absent).
+// The headers read from headersFrom are only sent to a status URL with the scheme, host and port of the url, and are
+// dropped when the service redirects a request to another origin.
+//
+// When a destroyUrl is configured, deleting the Resource (with the Delete policy) sends the same payload to it, with the DELETE method, until
+// the service stops answering with a Running state (or answers 404); the service must handle repeated calls.
+const HttpProvisionerName = "http"
+
+const (
+	httpGenerationAnnotation = resourcesv1alpha1.Group + "/http.generation"
+	httpStatusUrlAnnotation  = resourcesv1alpha1.Group + "/http.statusUrl"
+	httpStatusAnnotation     = resourcesv1alpha1.Group + "/http.status"
+
+	defaultHttpTimeout = 30 * time.Second
+
+	// maxHttpResponseSize bounds the answers read from the service
+	maxHttpResponseSize = 1 << 20
+	// maxHttpMessageLength bounds the messages kept in the Resource annotations
+	maxHttpMessageLength = 1024
+	maxHttpRedirects     = 10
+)
+
+type HttpProvisioner struct {
+	client     client.Client
+	log        logr.Logger
+	httpClient *http.Client
+	properties *httpProvisionerProperties
+}
+
+type httpProvisionerProperties struct {
+	Url     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// HeadersFrom is a Secret, in the Resource namespace, whose keys are sent as headers (credentials, tokens)
+	HeadersFrom *httpHeadersFrom `json:"headersFrom,omitempty"`
+	Timeout     string           `json:"timeout,omitempty"`
+	DestroyUrl  string           `json:"destroyUrl,omitempty"`
+}
+
+type httpHeadersFrom struct {
+	SecretName string `json:"secretName"`
+}
+
+type httpSubmission struct {
+	Name       string         `json:"name"`
+	Namespace  string         `json:"namespace"`
+	Placement  string         `json:"placement"`
+	Generation int64          `json:"generation"`
+	Properties map[string]any `json:"properties"`
+}
+
+type httpStatus struct {
+	StatusUrl string         `json:"statusUrl,omitempty"`
+	State     string         `json:"state,omitempty"`
+	Outputs   map[string]any `json:"outputs,omitempty"`
+	Message   string         `json:"message,omitempty"`
+}
+
+func newHttpProvisioner(c client.Client, d *dynamic.DynamicClient, scheme *runtime.Scheme, log logr.Logger, provisioner *resourcesv1alpha1.ResourceRefProvisioner) (Provisioner, error) {
+	properties := &httpProvisionerProperties{}
+	if provisioner.Properties != nil {
+		if err := json.Unmarshal(provisioner.Properties.Raw, properties); err != nil {
+			return nil, err
+		}
+	}
+
+	if properties.Url == "" {
+		return nil, fmt.Errorf("http provisioner requires an url")
+	}
+	if properties.Method == "" {
+		properties.Method = http.MethodPost
+	}
+
+	timeout := defaultHttpTimeout
+	if properties.Timeout != "" {
+		d, err := time.ParseDuration(properties.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http provisioner timeout %s: %w", properties.Timeout, err)
+		}
+		timeout = d
+	}
+
+	httpProvisioner := &HttpProvisioner{
+		client:     c,
+		log:        log,
+		properties: properties,
+	}
+	httpProvisioner.httpClient = &http.Client{Timeout: timeout, CheckRedirect: httpProvisioner.checkRedirect}
+
+	return httpProvisioner, nil
+}
+
+func (provisioner *HttpProvisioner) Run(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
+	headers, err := provisioner.headers(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := resource.GetAnnotations()
+
+	var status *httpStatus
+	switch {
+	case annotations[httpGenerationAnnotation] != strconv.FormatInt(resource.Generation, 10):
+		status, err = provisioner.submit(ctx, resource, headers)
+	case annotations[httpStatusUrlAnnotation] != "":
+		// this generation was already submitted; just poll it
+		status, err = provisioner.poll(ctx, annotations[httpStatusUrlAnnotation], headers)
+	default:
+		// the service answered synchronously; the answer was kept in the Resource
+		status = &httpStatus{}
+		err = json.Unmarshal([]byte(annotations[httpStatusAnnotation]), status)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provisionedResourceStatus := &ProvisionedResourceStatus{
+		Resource: &ProvisionedResource{
+			GroupVersionKind: schema.GroupVersionKind{Group: resourcesv1alpha1.Group, Version: "v1alpha1", Kind: "Http"},
+			Name:             resource.Name,
+		},
+		Outputs: status.Outputs,
+	}
+
+	switch status.State {
+	case "", string(ProvisionedResourceRunningState):
+		provisionedResourceStatus.State = ProvisionedResourceRunningState
+	case string(ProvisionedResourceSuccessState):
+		provisionedResourceStatus.State = ProvisionedResourceSuccessState
+	case string(ProvisionedResourceFailedState):
+		provisionedResourceStatus.State = ProvisionedResourceFailedState
+	default:
+		return nil, fmt.Errorf("unknown state returned by %s: %s", provisioner.properties.Url, status.State)
+	}
+
+	if status.Message != "" {
+		provisioner.log.Info(fmt.Sprintf("resource %s is %s: %s", resource.Name, provisionedResourceStatus.State, status.Message))
+	}
+
+	return provisionedResourceStatus, nil
+}
+
+func (provisioner *HttpProvisioner) Destroy(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
+	provisionedResourceStatus := &ProvisionedResourceStatus{
+		Resource: &ProvisionedResource{
+			GroupVersionKind: schema.GroupVersionKind{Group: resourcesv1alpha1.Group, Version: "v1alpha1", Kind: "Http"},
+			Name:             resource.Name,
+		},
+		State: ProvisionedResourceSuccessState,
+	}
+
+	if provisioner.properties.DestroyUrl == "" || deletionPolicyOf(resource) != resourcesv1alpha1.DeletionPolicyDelete {
+		// nothing to notify
+		return provisionedResourceStatus, nil
+	}
+
+	provisioner.log.Info(fmt.Sprintf("destroying resource %s/%s through %s...", resource.Namespace, resource.Name, provisioner.properties.DestroyUrl))
+
+	headers, err := provisioner.headers(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := provisioner.payload(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := provisioner.do(ctx, http.MethodDelete, provisioner.properties.DestroyUrl, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return provisionedResourceStatus, nil
+	case response.StatusCode >= 400 && response.StatusCode < 500:
+		message, _ := readHttpBody(response)
+		provisioner.log.Info(fmt.Sprintf("destruction of resource %s rejected with %s: %s", resource.Name, response.Status, truncateHttpMessage(string(message))))
+		provisionedResourceStatus.State = ProvisionedResourceFailedState
+		return provisionedResourceStatus, nil
+	}
+
+	status, err := readHttpStatus(response)
+	if err != nil {
+		return nil, err
+	}
+
+	switch status.State {
+	case "", string(ProvisionedResourceSuccessState):
+		provisionedResourceStatus.State = ProvisionedResourceSuccessState
+	case string(ProvisionedResourceRunningState):
+		provisionedResourceStatus.State = ProvisionedResourceRunningState
+	case string(ProvisionedResourceFailedState):
+		provisionedResourceStatus.State = ProvisionedResourceFailedState
+	default:
+		return nil, fmt.Errorf("unknown state returned by %s: %s", provisioner.properties.DestroyUrl, status.State)
+	}
+
+	return provisionedResourceStatus, nil
+}
+
+func (provisioner *HttpProvisioner) payload(resource *resourcesv1alpha1.Resource) ([]byte, error) {
+	properties := make(map[string]any)
+	if resource.Spec.Properties != nil {
+		if err := json.Unmarshal(resource.Spec.Properties.Raw, &properties); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(httpSubmission{
+		Name:       resource.Name,
+		Namespace:  resource.Namespace,
+		Placement:  resource.Spec.Placement,
+		Generation: resource.Generation,
+		Properties: properties,
+	})
+}
+
+// submit sends the evaluated properties to the service. The submission is recorded as annotations, so each
+// generation is sent only once; a rejected submission (4xx, except throttling) fails the Resource, while other errors
+// are retried.
+func (provisioner *HttpProvisioner) submit(ctx context.Context, resource *resourcesv1alpha1.Resource, headers http.Header) (*httpStatus, error) {
+	provisioner.log.Info(fmt.Sprintf("submitting resource %s/%s to %s...", resource.Namespace, resource.Name, provisioner.properties.Url))
+
+	body, err := provisioner.payload(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := provisioner.do(ctx, provisioner.properties.Method, provisioner.properties.Url, headers, body)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var status *httpStatus
+	if response.StatusCode >= 400 && response.StatusCode < 500 && response.StatusCode != http.StatusTooManyRequests {
+		message, _ := readHttpBody(response)
+		status = &httpStatus{State: string(ProvisionedResourceFailedState), Message: fmt.Sprintf("submission rejected with %s: %s", response.Status, message)}
+	} else {
+		status, err = readHttpStatus(response)
+		if err != nil {
+			return nil, err
+		}
+		if status.StatusUrl == "" {
+			status.StatusUrl = response.Header.Get("Location")
+		}
+	}
+
+	patch := client.MergeFrom(resource.DeepCopy())
+
+	annotations := resource.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[httpGenerationAnnotation] = strconv.FormatInt(resource.Generation, 10)
+	delete(annotations, httpStatusUrlAnnotation)
+	delete(annotations, httpStatusAnnotation)
+
+	if status.StatusUrl != "" {
+		// relative locations are resolved against the submission url
+		base, err := url.Parse(provisioner.properties.Url)
+		if err != nil {
+			return nil, err
+		}
+		location, err := base.Parse(status.StatusUrl)
+		if err != nil {
+			return nil, err
+		}
+		annotations[httpStatusUrlAnnotation] = location.String()
+
+		if status.State == "" {
+			status.State = string(ProvisionedResourceRunningState)
+		}
+	} else {
+		if status.State == "" {
+			status.State = string(ProvisionedResourceSuccessState)
+		}
+		status.Message = truncateHttpMessage(status.Message)
+		statusAsJson, err := json.Marshal(status)
+		if err != nil {
+			return nil, err
+		}
+		annotations[httpStatusAnnotation] = string(statusAsJson)
+	}
+
+	resource.SetAnnotations(annotations)
+
+	if err := provisioner.client.Patch(ctx, resource, patch); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// poll reads the status of a submission. The status url is chosen by the service, so the headers from the Secret
+// are only sent to it when it has the same scheme, host and port of the configured url.
+func (provisioner *HttpProvisioner) poll(ctx context.Context, statusUrl string, headers http.Header) (*httpStatus, error) {
+	sameOrigin, err := sameOriginOf(provisioner.properties.Url, statusUrl)
+	if err != nil {
+		return nil, err
+	}
+	if !sameOrigin {
+		provisioner.log.Info(fmt.Sprintf("status url %s is not served by %s; polling it without the headers from the secret", statusUrl, provisioner.properties.Url))
+		headers = provisioner.staticHeaders()
+	}
+
+	response, err := provisioner.do(ctx, http.MethodGet, statusUrl, headers, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	return readHttpStatus(response)
+}
+
+func (provisioner *HttpProvisioner) do(ctx context.Context, method string, url string, headers http.Header, body []byte) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header = headers.Clone()
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	return provisioner.httpClient.Do(request)
+}
+
+// checkRedirect follows the redirects of the service, but a redirect to another origin only carries the static headers;
+// the headers from the Secret stay with the origin they were sent to.
+func (provisioner *HttpProvisioner) checkRedirect(request *http.Request, via []*http.Request) error {
+	if len(via) >= maxHttpRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxHttpRedirects)
+	}
+
+	sameOrigin, err := sameOriginOf(via[0].URL.String(), request.URL.String())
+	if err != nil {
+		return err
+	}
+	if !sameOrigin {
+		headers := provisioner.staticHeaders()
+		for _, name := range []string{"Accept", "Content-Type"} {
+			if value := request.Header.Get(name); value != "" {
+				headers.Set(name, value)
+			}
+		}
+		request.Header = headers
+	}
+	return nil
+}
+
+func (provisioner *HttpProvisioner) staticHeaders() http.Header {
+	headers := make(http.Header)
+	for name, value := range provisioner.properties.Headers {
+		headers.Set(name, value)
+	}
+	return headers
+}
+
+func (provisioner *HttpProvisioner) headers(ctx context.Context, resource *resourcesv1alpha1.Resource) (http.Header, error) {
+	headers := provisioner.staticHeaders()
+
+	if provisioner.properties.HeadersFrom != nil {
+		secret := &corev1.Secret{}
+		if err := provisioner.client.Get(ctx, types.NamespacedName{Namespace: resource.Namespace, Name: provisioner.properties.HeadersFrom.SecretName}, secret); err != nil {
+			return nil, fmt.Errorf("unable to read headers from secret %s: %w", provisioner.properties.HeadersFrom.SecretName, err)
+		}
+		for name, value := range secret.Data {
+			headers.Set(name, string(value))
+		}
+	}
+
+	return headers, nil
+}
+
+// sameOriginOf tells whether two urls share scheme, host and port; default ports are made explicit before comparing
+func sameOriginOf(a string, b string) (bool, error) {
+	urlA, err := url.Parse(a)
+	if err != nil {
+		return false, err
+	}
+	urlB, err := url.Parse(b)
+	if err != nil {
+		return false, err
+	}
+
+	portOf := func(u *url.URL) string {
+		if port := u.Port(); port != "" {
+			return port
+		}
+		if strings.EqualFold(u.Scheme, "https") {
+			return "443"
+		}
+		return "80"
+	}
+
+	return strings.EqualFold(urlA.Scheme, urlB.Scheme) &&
+		strings.EqualFold(urlA.Hostname(), urlB.Hostname()) &&
+		portOf(urlA) == portOf(urlB), nil
+}
+
+func readHttpStatus(response *http.Response) (*httpStatus, error) {
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		err := fmt.Errorf("unexpected response from %s: %s", response.Request.URL, response.Status)
+		// throttling and unavailability can be retried; any other failure is up to the API
+		if response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500 {
+			return nil, Transient(err)
+		}
+		return nil, Terminal(err)
+	}
+
+	body, err := readHttpBody(response)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &httpStatus{}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return status, nil
+	}
+	if err := json.Unmarshal(body, status); err != nil {
+		return nil, fmt.Errorf("unable to read response from %s: %w", response.Request.URL, err)
+	}
+	return status, nil
+}
+
+func readHttpBody(response *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxHttpResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxHttpResponseSize {
+		return nil, fmt.Errorf("the response from %s is larger than %d bytes", response.Request.URL, maxHttpResponseSize)
+	}
+	return body, nil
+}
+
+func truncateHttpMessage(message string) string {
+	if len(message) <= maxHttpMessageLength {
+		return message
+	}
+	// the cut may split a multi-byte character
+	return strings.ToValidUTF8(message[:maxHttpMessageLength], "") + "..."
+}
@@ -0,0 +1,263 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_HttpProvisioner(t *testing.T) {
+
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	submissions := 0
+	destructions := 0
+
+	// a status server of another origin must not receive the credentials read from the secret
+	var foreignHeaders http.Header
+	foreignServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		foreignHeaders = r.Header.Clone()
+		json.NewEncoder(w).Encode(map[string]any{"state": "Success"})
+	}))
+	defer foreignServer.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/async":
+			submissions++
+			submission := &httpSubmission{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(submission))
+			assert.Equal(t, "my-resource", submission.Name)
+			assert.Equal(t, "value", submission.Properties["field"])
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+			w.Header().Set("Location", "/status/1")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodGet && r.URL.Path == "/status/1":
+			json.NewEncoder(w).Encode(map[string]any{"state": "Success", "outputs": map[string]any{"ticket": "OPS-1"}})
+		case r.Method == http.MethodPost && r.URL.Path == "/foreign":
+			w.Header().Set("Location", foreignServer.URL+"/status/1")
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPost && r.URL.Path == "/sync":
+			submissions++
+			json.NewEncoder(w).Encode(map[string]any{"outputs": map[string]any{"ticket": "OPS-2"}})
+		case r.Method == http.MethodDelete && r.URL.Path == "/destroy":
+			destructions++
+		case r.Method == http.MethodPost && r.URL.Path == "/invalid":
+			submissions++
+			w.WriteHeader(http.StatusBadRequest)
+		case r.Method == http.MethodPost && r.URL.Path == "/throttled":
+			submissions++
+			w.WriteHeader(http.StatusTooManyRequests)
+		case r.Method == http.MethodPost && r.URL.Path == "/redirect":
+			w.Header().Set("Location", foreignServer.URL+"/sync")
+			w.WriteHeader(http.StatusTemporaryRedirect)
+		case r.Method == http.MethodPost && r.URL.Path == "/verbose":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(strings.Repeat("e", 4*maxHttpMessageLength)))
+		case r.Method == http.MethodPost && r.URL.Path == "/huge":
+			w.Write([]byte(`{"outputs":{"ticket":"` + strings.Repeat("x", maxHttpResponseSize) + `"}}`))
+		}
+	}))
+	defer server.Close()
+
+	newResource := func() *resourcesv1alpha1.Resource {
+		return &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "sample", Name: "my-resource", Generation: 1},
+			Spec: resourcesv1alpha1.ResourceSpec{
+				Properties: &runtime.RawExtension{Raw: []byte(`{"field":"value"}`)},
+			},
+		}
+	}
+
+	newProvisioner := func(c *fake.ClientBuilder, properties string) Provisioner {
+		provisioner, err := newHttpProvisioner(c.Build(), nil, scheme, logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       resourcesv1alpha1.ResourceRefHttpProvisioner,
+			Properties: &runtime.RawExtension{Raw: []byte(properties)},
+		})
+		assert.NoError(t, err)
+		return provisioner
+	}
+
+	t.Run("We should be able to submit a resource and poll its status", func(t *testing.T) {
+		submissions = 0
+		resource := newResource()
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(resource)
+		provisioner := newProvisioner(c, `{"url":"`+server.URL+`/async","headers":{"Authorization":"Bearer token"}}`)
+
+		status, err := provisioner.Run(context.TODO(), resource)
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceRunningState, status.State)
+		assert.Equal(t, server.URL+"/status/1", resource.Annotations[httpStatusUrlAnnotation])
+
+		status, err = provisioner.Run(context.TODO(), resource)
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceSuccessState, status.State)
+		assert.Equal(t, "OPS-1", status.Outputs["ticket"])
+
+		assert.Equal(t, 1, submissions)
+	})
+
+	t.Run("We should only send the headers from the secret to a status url of the same origin", func(t *testing.T) {
+		resource := newResource()
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "sample", Name: "credentials"},
+			Data:       map[string][]byte{"X-Api-Key": []byte("secret")},
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(resource, secret)
+		provisioner := newProvisioner(c, `{"url":"`+server.URL+`/foreign","headers":{"X-Team":"platform"},"headersFrom":{"secretName":"credentials"}}`)
+
+		_, err := provisioner.Run(context.TODO(), resource)
+		assert.NoError(t, err)
+
+		status, err := provisioner.Run(context.TODO(), resource)
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceSuccessState, status.State)
+
+		assert.Equal(t, "platform", foreignHeaders.Get("X-Team"))
+		assert.Empty(t, foreignHeaders.Get("X-Api-Key"))
+	})
+
+	t.Run("We should not send the headers from the secret along a redirect to another origin", func(t *testing.T) {
+		foreignHeaders = nil
+		resource := newResource()
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "sample", Name: "credentials"},
+			Data:       map[string][]byte{"X-Api-Key": []byte("secret")},
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(resource, secret)
+		provisioner := newProvisioner(c, `{"url":"`+server.URL+`/redirect","headers":{"X-Team":"platform"},"headersFrom":{"secretName":"credentials"}}`)
+
+		status, err := provisioner.Run(context.TODO(), resource)
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceSuccessState, status.State)
+
+		assert.Equal(t, "platform", foreignHeaders.Get("X-Team"))
+		assert.Empty(t, foreignHeaders.Get("X-Api-Key"))
+	})
+
+	t.Run("We should compare origins with their default ports", func(t *testing.T) {
+		for _, tc := range []struct {
+			a, b     string
+			expected bool
+		}{
+			{"https://api.internal/tickets", "https://api.internal:443/status/1", true},
+			{"https://api.internal/tickets", "http://api.internal/status/1", false},
+			{"https://api.internal/tickets", "https://api.internal:8443/status/1", false},
+			{"https://api.internal/tickets", "https://attacker.example/status/1", false},
+		} {
+			sameOrigin, err := sameOriginOf(tc.a, tc.b)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, sameOrigin, "%s and %s", tc.a, tc.b)
+		}
+	})
+
+	t.Run("We should be able to read a synchronous answer only once", func(t *testing.T) {
+		submissions = 0
+		resource := newResource()
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(resource)
+		provisioner := newProvisioner(c, `{"url":"`+server.URL+`/sync"}`)
+
+		for range 2 {
+			status, err := provisioner.Run(context.TODO(), resource)
+			assert.NoError(t, err)
+			assert.Equal(t, ProvisionedResourceSuccessState, status.State)
+			assert.Equal(t, "OPS-2", status.Outputs["ticket"])
+		}
+
+		assert.Equal(t, 1, submissions)
+	})
+
+	t.Run("A rejected submission should fail the resource", func(t *testing.T) {
+		submissions = 0
+		resource := newResource()
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(resource)
+		provisioner := newProvisioner(c, `{"url":"`+server.URL+`/invalid"}`)
+
+		status, err := provisioner.Run(context.TODO(), resource)
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceFailedState, status.State)
+
+		status, err = provisioner.Run(context.TODO(), resource)
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceFailedState, status.State)
+
+		assert.Equal(t, 1, submissions)
+	})
+
+	t.Run("We should keep only the beginning of a long rejection message", func(t *testing.T) {
+		resource := newResource()
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(resource)
+		provisioner := newProvisioner(c, `{"url":"`+server.URL+`/verbose"}`)
+
+		status, err := provisioner.Run(context.TODO(), resource)
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceFailedState, status.State)
+
+		kept := &httpStatus{}
+		assert.NoError(t, json.Unmarshal([]byte(resource.Annotations[httpStatusAnnotation]), kept))
+		assert.LessOrEqual(t, len(kept.Message), maxHttpMessageLength+len("..."))
+	})
+
+	t.Run("We should refuse a response larger than the limit", func(t *testing.T) {
+		resource := newResource()
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(resource)
+		provisioner := newProvisioner(c, `{"url":"`+server.URL+`/huge"}`)
+
+		_, err := provisioner.Run(context.TODO(), resource)
+		assert.ErrorContains(t, err, "larger than")
+	})
+
+	t.Run("A throttled submission should be retried", func(t *testing.T) {
+		submissions = 0
+		resource := newResource()
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(resource)
+		provisioner := newProvisioner(c, `{"url":"`+server.URL+`/throttled"}`)
+
+		_, err := provisioner.Run(context.TODO(), resource)
+		assert.Error(t, err)
+		assert.True(t, IsTransient(err))
+		assert.Empty(t, resource.Annotations[httpGenerationAnnotation])
+
+		_, err = provisioner.Run(context.TODO(), resource)
+		assert.Error(t, err)
+
+		assert.Equal(t, 2, submissions)
+	})
+
+	t.Run("We should call the destroy url only with the Delete policy", func(t *testing.T) {
+		for _, policy := range []resourcesv1alpha1.DeletionPolicy{"", resourcesv1alpha1.DeletionPolicyOrphan, resourcesv1alpha1.DeletionPolicyRetain, resourcesv1alpha1.DeletionPolicyDelete} {
+			resource := newResource()
+			resource.Spec.DeletionPolicy = policy
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(resource)
+			provisioner := newProvisioner(c, `{"url":"`+server.URL+`/async","destroyUrl":"`+server.URL+`/destroy"}`)
+
+			status, err := provisioner.Destroy(context.TODO(), resource)
+			assert.NoError(t, err)
+			assert.Equal(t, ProvisionedResourceSuccessState, status.State)
+		}
+
+		assert.Equal(t, 2, destructions)
+	})
+
+	t.Run("We should not be able to create the provisioner without an url", func(t *testing.T) {
+		_, err := newHttpProvisioner(nil, nil, scheme, logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       resourcesv1alpha1.ResourceRefHttpProvisioner,
+			Properties: &runtime.RawExtension{Raw: []byte(`{}`)},
+		})
+		assert.Error(t, err)
+	})
+}
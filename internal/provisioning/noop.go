@@ -0,0 +1,190 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NoopProvisionerName is a provisioner that doesn't touch any real infrastructure: properties are just echoed as outputs.
+// Failure scenarios can be scripted, so platform teams can test how groups behave under partial failure.
+const NoopProvisionerName = "noop"
+
+const (
+	noopAttemptsAnnotation   = resourcesv1alpha1.Group + "/noop.attempts"
+	noopStartedAtAnnotation  = resourcesv1alpha1.Group + "/noop.startedAt"
+	noopGenerationAnnotation = resourcesv1alpha1.Group + "/noop.generation"
+
+	// noopScenarioProperty allows a Resource to override the scenario declared in the ResourceRef
+	noopScenarioProperty = "noopScenario"
+)
+
+type NoopProvisioner struct {
+	client     client.Client
+	log        logr.Logger
+	properties *noopProvisionerProperties
+}
+
+type noopProvisionerProperties struct {
+	Scenario *noopScenario `json:"scenario"`
+}
+
+type noopScenario struct {
+	// Fail makes every attempt fail
+	Fail bool `json:"fail,omitempty"`
+	// FailOnAttempt makes only the Nth attempt (the Nth generation of the Resource spec) fail
+	FailOnAttempt int `json:"failOnAttempt,omitempty"`
+	// Latency keeps the resource running for the given duration before finishing
+	Latency string `json:"latency,omitempty"`
+	// Flapping alternates between success and failure on each attempt (even attempts fail)
+	Flapping bool `json:"flapping,omitempty"`
+}
+
+func newNoopProvisioner(c client.Client, d *dynamic.DynamicClient, scheme *runtime.Scheme, log logr.Logger, provisioner *resourcesv1alpha1.ResourceRefProvisioner) (Provisioner, error) {
+	properties := &noopProvisionerProperties{}
+	if provisioner.Properties != nil {
+		if err := json.Unmarshal(provisioner.Properties.Raw, properties); err != nil {
+			return nil, err
+		}
+	}
+
+	noopProvisioner := &NoopProvisioner{
+		client:     c,
+		log:        log,
+		properties: properties,
+	}
+
+	return noopProvisioner, nil
+}
+
+func (provisioner *NoopProvisioner) Run(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
+	provisioner.log.Info(fmt.Sprintf("starting noop provisioner to resource %s/%s...", resource.Namespace, resource.Name))
+
+	properties := make(map[string]any)
+	if resource.Spec.Properties != nil {
+		if err := json.Unmarshal(resource.Spec.Properties.Raw, &properties); err != nil {
+			return nil, err
+		}
+	}
+
+	scenario := provisioner.properties.Scenario
+	if candidate, ok := properties[noopScenarioProperty]; ok {
+		scenarioAsJson, err := json.Marshal(candidate)
+		if err != nil {
+			return nil, err
+		}
+		scenario = &noopScenario{}
+		if err := json.Unmarshal(scenarioAsJson, scenario); err != nil {
+			return nil, err
+		}
+		delete(properties, noopScenarioProperty)
+	}
+
+	attempt, startedAt, err := provisioner.nextAttempt(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := scenario.evaluate(attempt, time.Since(startedAt))
+	if err != nil {
+		return nil, err
+	}
+
+	provisioner.log.Info(fmt.Sprintf("noop provisioner attempt %d to resource %s finished as %s", attempt, resource.Name, state))
+
+	status := &ProvisionedResourceStatus{
+		Resource: &ProvisionedResource{
+			GroupVersionKind: schema.GroupVersionKind{Group: resourcesv1alpha1.Group, Version: "v1alpha1", Kind: "Noop"},
+			Name:             resource.Name,
+		},
+		State:   state,
+		Outputs: make(map[string]any),
+	}
+	if state == ProvisionedResourceSuccessState {
+		status.Outputs = properties
+	}
+
+	return status, nil
+}
+
+// Destroy has nothing to tear down
+func (provisioner *NoopProvisioner) Destroy(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
+	provisioner.log.Info(fmt.Sprintf("destroying noop resource %s/%s...", resource.Namespace, resource.Name))
+
+	return &ProvisionedResourceStatus{
+		Resource: &ProvisionedResource{
+			GroupVersionKind: schema.GroupVersionKind{Group: resourcesv1alpha1.Group, Version: "v1alpha1", Kind: "Noop"},
+			Name:             resource.Name,
+		},
+		State: ProvisionedResourceSuccessState,
+	}, nil
+}
+
+// nextAttempt counts the applies of the resource: every new generation of the spec is a new attempt.
+// The counter is kept as annotations, so it survives controller restarts.
+func (provisioner *NoopProvisioner) nextAttempt(ctx context.Context, resource *resourcesv1alpha1.Resource) (int, time.Time, error) {
+	annotations := resource.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	attempt, _ := strconv.Atoi(annotations[noopAttemptsAnnotation])
+
+	startedAt, err := time.Parse(time.RFC3339, annotations[noopStartedAtAnnotation])
+	if err == nil && annotations[noopGenerationAnnotation] == strconv.FormatInt(resource.Generation, 10) {
+		// still the same attempt
+		return attempt, startedAt, nil
+	}
+
+	attempt++
+	startedAt = time.Now()
+
+	patch := client.MergeFrom(resource.DeepCopy())
+
+	annotations[noopAttemptsAnnotation] = strconv.Itoa(attempt)
+	annotations[noopGenerationAnnotation] = strconv.FormatInt(resource.Generation, 10)
+	annotations[noopStartedAtAnnotation] = startedAt.Format(time.RFC3339)
+	resource.SetAnnotations(annotations)
+
+	if err := provisioner.client.Patch(ctx, resource, patch); err != nil {
+		return 0, startedAt, err
+	}
+
+	return attempt, startedAt, nil
+}
+
+func (scenario *noopScenario) evaluate(attempt int, elapsed time.Duration) (ProvisionedResourceStateDescription, error) {
+	if scenario == nil {
+		return ProvisionedResourceSuccessState, nil
+	}
+
+	if scenario.Latency != "" {
+		latency, err := time.ParseDuration(scenario.Latency)
+		if err != nil {
+			return "", fmt.Errorf("invalid noop scenario latency %s: %w", scenario.Latency, err)
+		}
+		if elapsed < latency {
+			return ProvisionedResourceRunningState, nil
+		}
+	}
+
+	switch {
+	case scenario.Fail:
+		return ProvisionedResourceFailedState, nil
+	case scenario.FailOnAttempt == attempt:
+		return ProvisionedResourceFailedState, nil
+	case scenario.Flapping && attempt%2 == 0:
+		return ProvisionedResourceFailedState, nil
+	}
+
+	return ProvisionedResourceSuccessState, nil
+}
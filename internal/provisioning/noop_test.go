@@ -0,0 +1,74 @@
+package provisioning
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NoopScenario(t *testing.T) {
+
+	t.Run("Without a scenario, every attempt should succeed", func(t *testing.T) {
+		var scenario *noopScenario
+
+		state, err := scenario.evaluate(1, time.Duration(0))
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceSuccessState, state)
+	})
+
+	t.Run("We should be able to fail every attempt", func(t *testing.T) {
+		scenario := &noopScenario{Fail: true}
+
+		for attempt := 1; attempt <= 3; attempt++ {
+			state, err := scenario.evaluate(attempt, time.Duration(0))
+			assert.NoError(t, err)
+			assert.Equal(t, ProvisionedResourceFailedState, state)
+		}
+	})
+
+	t.Run("We should be able to fail only the Nth attempt", func(t *testing.T) {
+		scenario := &noopScenario{FailOnAttempt: 2}
+
+		state, _ := scenario.evaluate(1, time.Duration(0))
+		assert.Equal(t, ProvisionedResourceSuccessState, state)
+
+		state, _ = scenario.evaluate(2, time.Duration(0))
+		assert.Equal(t, ProvisionedResourceFailedState, state)
+
+		state, _ = scenario.evaluate(3, time.Duration(0))
+		assert.Equal(t, ProvisionedResourceSuccessState, state)
+	})
+
+	t.Run("We should be able to slow down an apply", func(t *testing.T) {
+		scenario := &noopScenario{Latency: "30s"}
+
+		state, err := scenario.evaluate(1, 10*time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceRunningState, state)
+
+		state, err = scenario.evaluate(2, 31*time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceSuccessState, state)
+	})
+
+	t.Run("We should be able to flap between success and failure", func(t *testing.T) {
+		scenario := &noopScenario{Flapping: true}
+
+		state, _ := scenario.evaluate(1, time.Duration(0))
+		assert.Equal(t, ProvisionedResourceSuccessState, state)
+
+		state, _ = scenario.evaluate(2, time.Duration(0))
+		assert.Equal(t, ProvisionedResourceFailedState, state)
+
+		state, _ = scenario.evaluate(3, time.Duration(0))
+		assert.Equal(t, ProvisionedResourceSuccessState, state)
+	})
+
+	t.Run("An invalid latency should be reported", func(t *testing.T) {
+		scenario := &noopScenario{Latency: "whatever"}
+
+		_, err := scenario.evaluate(1, time.Duration(0))
+		assert.Error(t, err)
+	})
+}
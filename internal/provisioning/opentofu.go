@@ -1,9 +1,12 @@
 package provisioning
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/go-logr/logr"
 	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
@@ -23,23 +26,102 @@ import (
 
 const OpenTofuProvisionerName = "opentofu"
 
+const (
+	terraformGroup = "infra.contrib.fluxcd.io"
+	// defaultTerraformVersion is the tf-controller API used when the properties don't declare one
+	defaultTerraformVersion = "v1alpha2"
+)
+
+var gitRepositoryGvk = schema.GroupVersionKind{
+	Group:   "source.toolkit.fluxcd.io",
+	Version: "v1",
+	Kind:    "GitRepository",
+}
+
 type OpenTofuProvisioner struct {
 	client        client.Client
-	dynamicClient *dynamic.DynamicClient
+	dynamicClient dynamic.Interface
 	scheme        *runtime.Scheme
 	log           logr.Logger
 	properties    *openTofuProvisionerProperties
+	overrides     objectOverrides
 }
 
 type openTofuProvisionerProperties struct {
-	Git openTofuProvisionerGitProperties `json:"git"`
+	// the module is read from one of Git, OCI or Bucket
+	Git         openTofuProvisionerGitProperties          `json:"git"`
+	OCI         *openTofuProvisionerOCIProperties         `json:"oci"`
+	Bucket      *openTofuProvisionerBucketProperties      `json:"bucket"`
+	RemoteState *openTofuProvisionerRemoteStateProperties `json:"remoteState"`
+	// TerraformVersion is the version of the tf-controller API the Terraform objects are written with
+	TerraformVersion *string `json:"terraformVersion"`
+
+	// ApprovePlan is auto, the default, or manual: then plans wait for an approval on the Terraform object
+	ApprovePlan string `json:"approvePlan"`
+	// Interval is how often tf-controller reconciles the Terraform objects; the interval of the source by default
+	Interval *string `json:"interval"`
+	// DestroyResourcesOnDeletion is used when the Resource doesn't declare a deletion policy; true by default
+	DestroyResourcesOnDeletion *bool `json:"destroyResourcesOnDeletion"`
+	// BackendConfig and RunnerPodTemplate are written as they are to the Terraform objects
+	BackendConfig      map[string]any `json:"backendConfig"`
+	RunnerPodTemplate  map[string]any `json:"runnerPodTemplate"`
+	ServiceAccountName string         `json:"serviceAccountName"`
+	// VarsFrom are var files kept in Secrets or ConfigMaps, read by tf-controller before the Resource properties
+	VarsFrom []openTofuProvisionerVarsFrom `json:"varsFrom"`
+	// PreventDestroyVar is a variable of the module set to true on protected Resources. OpenTofu can't read
+	// prevent_destroy from a variable, so modules declaring it keep protected copies of their critical resources and
+	// pick them with count = var.<name> ? 1 : 0; without it, modules receive no variable.
+	PreventDestroyVar *string `json:"preventDestroyVar"`
+}
+
+type openTofuProvisionerVarsFrom struct {
+	Kind     string   `json:"kind"`
+	Name     string   `json:"name"`
+	VarsKeys []string `json:"varsKeys"`
+	Optional bool     `json:"optional"`
+}
+
+const (
+	openTofuApprovePlanAuto   = "auto"
+	openTofuApprovePlanManual = "manual"
+)
+
+func (properties *openTofuProvisionerProperties) validate() error {
+	switch properties.ApprovePlan {
+	case "", openTofuApprovePlanAuto, openTofuApprovePlanManual:
+	default:
+		return fmt.Errorf("approvePlan must be %s or %s, got %s", openTofuApprovePlanAuto, openTofuApprovePlanManual, properties.ApprovePlan)
+	}
+	if err := properties.validateSource(); err != nil {
+		return err
+	}
+	for i, varsFrom := range properties.VarsFrom {
+		if varsFrom.Kind != "Secret" && varsFrom.Kind != "ConfigMap" {
+			return fmt.Errorf("varsFrom[%d].kind must be Secret or ConfigMap, got %s", i, varsFrom.Kind)
+		}
+		if varsFrom.Name == "" {
+			return fmt.Errorf("varsFrom[%d] requires a name", i)
+		}
+	}
+	return nil
 }
 
 type openTofuProvisionerGitProperties struct {
-	Repo     string  `json:"repo"`
-	Branch   *string `json:"branch"`
-	Dir      *string `json:"dir"`
+	Repo   string  `json:"repo"`
+	Branch *string `json:"branch"`
+	openTofuProvisionerSourceProperties
+}
+
+// openTofuProvisionerSourceProperties are shared by every kind of source
+type openTofuProvisionerSourceProperties struct {
+	// Dir is the path of the module in the source
+	Dir *string `json:"dir"`
+	// Interval is how often the source is fetched; 1m by default
 	Interval *string `json:"interval"`
+	// Namespace keeps the sources shared by the Resources of every namespace; without it, each namespace has its own.
+	// Terraform objects then read a source from another namespace, which tf-controller refuses when it runs with
+	// --no-cross-namespace-refs; leave it empty there.
+	Namespace *string `json:"namespace"`
 }
 
 func newOpenTofuProvisioner(c client.Client, d *dynamic.DynamicClient, scheme *runtime.Scheme, log logr.Logger, provisioner *resourcesv1alpha1.ResourceRefProvisioner) (Provisioner, error) {
@@ -47,6 +129,14 @@ func newOpenTofuProvisioner(c client.Client, d *dynamic.DynamicClient, scheme *r
 	if err := json.Unmarshal(provisioner.Properties.Raw, properties); err != nil {
 		return nil, err
 	}
+	if err := properties.validate(); err != nil {
+		return nil, err
+	}
+
+	overrides, err := newObjectOverrides(provisioner)
+	if err != nil {
+		return nil, err
+	}
 
 	openTofuProvisioner := &OpenTofuProvisioner{
 		client:        c,
@@ -54,6 +144,7 @@ func newOpenTofuProvisioner(c client.Client, d *dynamic.DynamicClient, scheme *r
 		scheme:        scheme,
 		log:           log,
 		properties:    properties,
+		overrides:     overrides,
 	}
 
 	return openTofuProvisioner, nil
@@ -62,18 +153,20 @@ func newOpenTofuProvisioner(c client.Client, d *dynamic.DynamicClient, scheme *r
 func (provisioner *OpenTofuProvisioner) Run(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
 	provisioner.log.Info(fmt.Sprintf("starting OpenTofu provisioner to resource %s/%s...", resource.Namespace, resource.Name))
 
-	repo, err := provisioner.getOrNewRepo(ctx, resource)
+	// the Terraform object is written before the source is read, so a source released meanwhile is seen as read by it
+	// or created again; see releaseRepos
+	terraform, newReader, err := provisioner.getOrNewTerraform(ctx, provisioner.repoKeyOf(resource), resource)
 	if err != nil {
 		return nil, err
 	}
 
-	provisioner.log.Info(fmt.Sprintf("using GitRepository: %s", repo.GetName()))
-
-	terraform, err := provisioner.getOrNewTerraform(ctx, repo.GetName(), resource)
+	repo, err := provisioner.getOrNewRepo(ctx, resource, newReader)
 	if err != nil {
 		return nil, err
 	}
 
+	provisioner.log.Info(fmt.Sprintf("using %s: %s/%s", repo.GetKind(), repo.GetNamespace(), repo.GetName()))
+
 	provisioner.log.Info(fmt.Sprintf("running Terraform: %s", terraform.GetName()))
 
 	terraformStatus, err := status.Compute(terraform)
@@ -85,7 +178,31 @@ func (provisioner *OpenTofuProvisioner) Run(ctx context.Context, resource *resou
 
 	provisionedResource := &ProvisionedResource{
 		GroupVersionKind: terraform.GroupVersionKind(),
-		Name:             resource.Name,
+		Name:             terraform.GetName(),
+	}
+
+	drifted, err := provisioner.isDrifted(terraform)
+	if err != nil {
+		return nil, err
+	}
+
+	if drifted {
+		provisioner.log.Info(fmt.Sprintf("drift detected in Terraform object %s", terraform.GetName()))
+
+		outputs, err := provisioner.readTerraformOutputs(ctx, terraform)
+		if err != nil {
+			return nil, err
+		}
+
+		status := &ProvisionedResourceStatus{
+			Resource: provisionedResource,
+			State:    ProvisionedResourceSuccessState,
+			Outputs:  outputs,
+			Drifted:  true,
+
+			HandledReconcileRequest: handledReconcileRequestOf(terraform),
+		}
+		return status, nil
 	}
 
 	switch terraformStatus.Status {
@@ -124,10 +241,24 @@ func (provisioner *OpenTofuProvisioner) Run(ctx context.Context, resource *resou
 					return nil, err
 				}
 
+				if provisioner.properties.RemoteState != nil {
+					if err := provisioner.publishRemoteState(ctx, resource, outputs); err != nil {
+						return nil, err
+					}
+				}
+
+				inventory, err := provisioner.readTerraformInventory(terraform)
+				if err != nil {
+					return nil, err
+				}
+
 				status := &ProvisionedResourceStatus{
-					Resource: provisionedResource,
-					State:    ProvisionedResourceSuccessState,
-					Outputs:  outputs,
+					Resource:  provisionedResource,
+					State:     ProvisionedResourceSuccessState,
+					Outputs:   outputs,
+					Inventory: inventory,
+
+					HandledReconcileRequest: handledReconcileRequestOf(terraform),
 				}
 				return status, nil
 			}
@@ -146,148 +277,249 @@ func (provisioner *OpenTofuProvisioner) Run(ctx context.Context, resource *resou
 
 }
 
-func (provisioner *OpenTofuProvisioner) getOrNewRepo(ctx context.Context, resource *resourcesv1alpha1.Resource) (*unstructured.Unstructured, error) {
-	repoGvk := schema.GroupVersionKind{
-		Group:   "source.toolkit.fluxcd.io",
-		Version: "v1",
-		Kind:    "GitRepository",
-	}
+// Destroy deletes the Terraform object; with the Delete policy, tf-controller runs a destroy plan before releasing it.
+// With Retain, the state secret is kept by tf-controller, so a new Terraform object with the same name adopts it.
+func (provisioner *OpenTofuProvisioner) Destroy(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
+	terraformGvk := provisioner.terraformGvk()
+	key := types.NamespacedName{Namespace: resource.Namespace, Name: objectNameOf(resource)}
 
-	repoGvWithResource := repoGvk.GroupVersion().WithResource("gitrepositories")
+	policy := deletionPolicyOf(resource)
 
-	repo, err := provisioner.dynamicClient.
-		Resource(repoGvWithResource).
-		Namespace(resource.Namespace).
-		Get(ctx, resource.Spec.ResourceRef, metav1.GetOptions{})
+	provisioner.log.Info(fmt.Sprintf("finalizing OpenTofu resources from %s/%s with deletion policy %s...", resource.Namespace, resource.Name, policy))
 
-	if err != nil {
-		if !apierrors.IsNotFound(err) {
+	// orphaned infrastructure keeps its remote state, so legacy consumers can still read it
+	if policy == resourcesv1alpha1.DeletionPolicyOrphan {
+		if err := provisioner.releaseTerraformObjects(ctx, key, resource); err != nil {
 			return nil, err
 		}
+		return releaseObject(ctx, provisioner.client, terraformGvk, key, resource)
+	}
 
-		repo = &unstructured.Unstructured{}
-		repo.SetGroupVersionKind(repoGvk)
-
-		content := make(map[string]any)
-		content["apiVersion"] = "source.toolkit.fluxcd.io/v1"
-		content["kind"] = "GitRepository"
-		content["metadata"] = map[string]any{
-			"name":      resource.Spec.ResourceRef,
-			"namespace": resource.Namespace,
+	status, err := deleteAndWait(ctx, provisioner.client, terraformGvk, key, func(terraform *unstructured.Unstructured) bool {
+		destroy := provisioner.destroyResourcesOnDeletion(resource)
+		current, _, _ := unstructured.NestedBool(terraform.Object, "spec", "destroyResourcesOnDeletion")
+		if current == destroy {
+			return false
 		}
-		content["spec"] = map[string]any{
-			"interval": provisioner.properties.Git.Interval,
-			"url":      provisioner.properties.Git.Repo,
-			"ref": map[string]any{
-				"branch": provisioner.properties.Git.Branch,
-			},
-		}
-
-		repo.SetUnstructuredContent(content)
+		unstructured.SetNestedField(terraform.Object, destroy, "spec", "destroyResourcesOnDeletion")
+		return true
+	})
+	if err != nil || status.State != ProvisionedResourceSuccessState {
+		return status, err
+	}
 
-		resourceRef := &resourcesv1alpha1.ResourceRef{}
-		if err := provisioner.client.Get(ctx, types.NamespacedName{Name: resource.Spec.ResourceRef}, resourceRef); err != nil {
-			provisioner.log.Error(err, fmt.Sprintf("unable to fetch ResourceRef %s", resource.Spec.ResourceRef))
+	if provisioner.properties.RemoteState != nil {
+		if err := provisioner.deleteRemoteState(ctx, resource); err != nil {
 			return nil, err
 		}
+	}
 
-		resourceRefGvk := resourceRef.GroupVersionKind()
+	// the Terraform object is gone, so the source it read may not be used anymore
+	if err := provisioner.releaseRepos(ctx, provisioner.repoNamespaceOf(resource)); err != nil {
+		return nil, err
+	}
 
-		repo.SetLabels(map[string]string{
-			"name":      resource.Name,
-			"namespace": resource.Namespace,
-			resourcesv1alpha1.Group + "/managedBy.group":   resourceRefGvk.Group,
-			resourcesv1alpha1.Group + "/managedBy.version": resourceRefGvk.Version,
-			resourcesv1alpha1.Group + "/managedBy.kind":    resourceRefGvk.Kind,
-			resourcesv1alpha1.Group + "/managedBy.name":    resourceRef.Name,
-		})
-		repo.SetOwnerReferences([]metav1.OwnerReference{
-			{
-				APIVersion:         resourceRefGvk.GroupVersion().String(),
-				Kind:               resourceRefGvk.Kind,
-				Name:               resourceRef.Name,
-				UID:                resourceRef.UID,
-				Controller:         ptr.To(true),
-				BlockOwnerDeletion: ptr.To(true),
-			},
-		})
+	return status, nil
+}
 
-		if err := provisioner.client.Create(ctx, repo); err != nil {
-			return nil, err
+// releaseTerraformObjects releases the source read by an orphaned Terraform object and the Secret its outputs are
+// written to: sources created by older releases and Secrets adopted by the output store are owned by the Resource, so
+// they would be garbage collected under the Terraform object that still uses them.
+func (provisioner *OpenTofuProvisioner) releaseTerraformObjects(ctx context.Context, key types.NamespacedName, resource *resourcesv1alpha1.Resource) error {
+	terraform := &unstructured.Unstructured{}
+	terraform.SetGroupVersionKind(provisioner.terraformGvk())
+
+	if err := provisioner.client.Get(ctx, key, terraform); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	sourceRef, _, _ := unstructured.NestedStringMap(terraform.Object, "spec", "sourceRef")
+	for _, sourceGvk := range openTofuSourceKinds {
+		if sourceGvk.Kind != sourceRef["kind"] {
+			continue
+		}
+		sourceKey := types.NamespacedName{Namespace: cmp.Or(sourceRef["namespace"], key.Namespace), Name: sourceRef["name"]}
+		if err := releaseOwned(ctx, provisioner.client, sourceGvk, sourceKey, resource); err != nil {
+			return err
+		}
+	}
+
+	if outputsSecretName, _, _ := unstructured.NestedString(terraform.Object, "spec", "writeOutputsToSecret", "name"); outputsSecretName != "" {
+		secretKey := types.NamespacedName{Namespace: key.Namespace, Name: outputsSecretName}
+		if err := releaseOwned(ctx, provisioner.client, corev1.SchemeGroupVersion.WithKind("Secret"), secretKey, resource); err != nil {
+			return err
 		}
 	}
 
-	return repo, nil
+	return nil
 }
 
-func (provisioner *OpenTofuProvisioner) getOrNewTerraform(ctx context.Context, gitRepoRef string, resource *resourcesv1alpha1.Resource) (*unstructured.Unstructured, error) {
+// Preview renders the Terraform object that would be applied to the resource; the plan itself is computed by tf-controller
+func (provisioner *OpenTofuProvisioner) Preview(ctx context.Context, resource *resourcesv1alpha1.Resource) (*unstructured.Unstructured, error) {
+	spec, err := provisioner.terraformSpec(provisioner.repoKeyOf(resource), resource)
+	if err != nil {
+		return nil, err
+	}
+
+	terraform := &unstructured.Unstructured{}
+	terraform.SetUnstructuredContent(map[string]any{
+		"apiVersion": provisioner.terraformGvk().GroupVersion().String(),
+		"kind":       "Terraform",
+		"metadata": map[string]any{
+			"name":      objectNameOf(resource),
+			"namespace": resource.Namespace,
+		},
+		"spec": spec,
+	})
+
+	return terraform, nil
+}
+
+func (provisioner *OpenTofuProvisioner) terraformSpec(repo types.NamespacedName, resource *resourcesv1alpha1.Resource) (map[string]any, error) {
 	inputs := make(map[string]any)
 	if err := json.Unmarshal(resource.Spec.Properties.Raw, &inputs); err != nil {
 		return nil, err
 	}
 
-	terraformVars := make([]map[string]any, 0, len(inputs))
-	for name, input := range inputs {
+	if preventDestroyVar := provisioner.properties.PreventDestroyVar; resource.Spec.Protect && preventDestroyVar != nil && *preventDestroyVar != "" {
+		inputs[*preventDestroyVar] = true
+	}
+
+	// sorted, so the same inputs always render the same spec
+	terraformVars := make([]any, 0, len(inputs))
+	for _, name := range slices.Sorted(maps.Keys(inputs)) {
 		terraformVars = append(terraformVars, map[string]any{
 			"name":  name,
-			"value": input,
+			"value": inputs[name],
 		})
 	}
 
-	newSpec := func() map[string]any {
-		return map[string]any{
-			"interval":    provisioner.properties.Git.Interval,
-			"approvePlan": "auto",
-			"path":        provisioner.properties.Git.Dir,
-			"sourceRef": map[string]any{
-				"kind":      "GitRepository",
-				"name":      gitRepoRef,
-				"namespace": resource.Namespace,
-			},
-			"vars": terraformVars,
-			"writeOutputsToSecret": map[string]any{
-				"name": fmt.Sprintf("%s-outputs", resource.Name),
-			},
+	// with the Warn policy, plans are only auto-approved until the current spec is deployed; after that,
+	// a drift is just planned and reported, never applied. With manual approvals, no plan is auto-approved.
+	approvePlan := "auto"
+	if provisioner.properties.ApprovePlan == openTofuApprovePlanManual {
+		approvePlan = ""
+	} else if resource.Spec.DriftPolicy == resourcesv1alpha1.DriftPolicyWarn &&
+		resource.Status.Phase == resourcesv1alpha1.DeploymentDonePhase &&
+		resource.Status.ObservedGeneration == resource.Generation {
+		approvePlan = ""
+	}
+
+	sourceGvk, source := provisioner.source()
+
+	spec := map[string]any{
+		"approvePlan":           approvePlan,
+		"disableDriftDetection": resource.Spec.DriftPolicy == resourcesv1alpha1.DriftPolicyIgnore,
+		"sourceRef": map[string]any{
+			"kind":      sourceGvk.Kind,
+			"name":      repo.Name,
+			"namespace": repo.Namespace,
+		},
+		"vars":                       terraformVars,
+		"enableInventory":            true,
+		"destroyResourcesOnDeletion": provisioner.destroyResourcesOnDeletion(resource),
+		"writeOutputsToSecret": map[string]any{
+			"name": fmt.Sprintf("%s-outputs", objectNameOf(resource)),
+		},
+	}
+
+	// the spec is kept to JSON values, so it can be copied like any unstructured object
+	if interval := cmp.Or(provisioner.properties.Interval, source.Interval); interval != nil {
+		spec["interval"] = *interval
+	}
+	if dir := source.Dir; dir != nil {
+		spec["path"] = *dir
+	}
+	if provisioner.properties.BackendConfig != nil {
+		spec["backendConfig"] = runtime.DeepCopyJSON(provisioner.properties.BackendConfig)
+	}
+	if provisioner.properties.RunnerPodTemplate != nil {
+		spec["runnerPodTemplate"] = runtime.DeepCopyJSON(provisioner.properties.RunnerPodTemplate)
+	}
+	if provisioner.properties.ServiceAccountName != "" {
+		spec["serviceAccountName"] = provisioner.properties.ServiceAccountName
+	}
+	if len(provisioner.properties.VarsFrom) > 0 {
+		varsFrom := make([]any, 0, len(provisioner.properties.VarsFrom))
+		for _, source := range provisioner.properties.VarsFrom {
+			entry := map[string]any{
+				"kind": source.Kind,
+				"name": source.Name,
+			}
+			if len(source.VarsKeys) > 0 {
+				varsKeys := make([]any, 0, len(source.VarsKeys))
+				for _, key := range source.VarsKeys {
+					varsKeys = append(varsKeys, key)
+				}
+				entry["varsKeys"] = varsKeys
+			}
+			if source.Optional {
+				entry["optional"] = true
+			}
+			varsFrom = append(varsFrom, entry)
 		}
+		spec["varsFrom"] = varsFrom
+	}
+
+	return provisioner.overrides.apply("Terraform", spec), nil
+}
 
+// ConsumedSecrets are the Secrets the Terraform object reads variables and backend configs from, usually declared
+// through overrides
+func (provisioner *OpenTofuProvisioner) ConsumedSecrets(resource *resourcesv1alpha1.Resource) ([]types.NamespacedName, error) {
+	spec, err := provisioner.terraformSpec(types.NamespacedName{}, resource)
+	if err != nil {
+		return nil, err
+	}
+	return secretsReadBy(spec, resource.Namespace, "varsFrom", "backendConfigsFrom"), nil
+}
+
+// getOrNewTerraform creates or updates the Terraform object of the Resource, reading the source of the repo; it returns
+// true when the object didn't read it before
+func (provisioner *OpenTofuProvisioner) getOrNewTerraform(ctx context.Context, repo types.NamespacedName, resource *resourcesv1alpha1.Resource) (*unstructured.Unstructured, bool, error) {
+	newSpec := func() (map[string]any, error) {
+		return provisioner.terraformSpec(repo, resource)
 	}
 
-	terraformGvk := schema.GroupVersionKind{
-		Group:   "infra.contrib.fluxcd.io",
-		Version: "v1alpha2",
-		Kind:    "GitRepository",
+	terraformGvk := provisioner.terraformGvk()
+	sourceGvk, _ := provisioner.source()
+	sourceLabel := sourceLabelOf(sourceGvk.Kind, repo.Namespace, repo.Name)
+
+	terraformGvr, err := provisioner.resourceOf(terraformGvk)
+	if err != nil {
+		return nil, false, err
 	}
 
-	terraformGvWithResource := terraformGvk.GroupVersion().WithResource("terraforms")
+	terraforms := provisioner.dynamicClient.Resource(terraformGvr).Namespace(resource.Namespace)
 
-	terraform, err := provisioner.dynamicClient.
-		Resource(terraformGvWithResource).
-		Namespace(resource.Namespace).
-		Get(ctx, resource.Name, metav1.GetOptions{})
+	terraform, err := terraforms.Get(ctx, objectNameOf(resource), metav1.GetOptions{})
 
 	if err != nil {
 		if !apierrors.IsNotFound(err) {
-			return nil, err
+			return nil, false, err
 		}
 
 		terraform = &unstructured.Unstructured{}
-		terraform.SetGroupVersionKind(terraformGvk)
 
 		object := make(map[string]any)
 
-		object["apiVersion"] = "infra.contrib.fluxcd.io/v1alpha2"
-		object["kind"] = "Terraform"
+		object["apiVersion"] = terraformGvk.GroupVersion().String()
+		object["kind"] = terraformGvk.Kind
 		object["metadata"] = map[string]any{
-			"name":      resource.Name,
+			"name":      objectNameOf(resource),
 			"namespace": resource.Namespace,
 		}
-		object["spec"] = newSpec()
+		spec, err := newSpec()
+		if err != nil {
+			return nil, false, err
+		}
+		object["spec"] = spec
 
 		terraform.SetUnstructuredContent(object)
 
 		resourceGkv, err := apiutil.GVKForObject(resource, provisioner.scheme)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		terraform.SetLabels(map[string]string{
 			"name":      resource.Name,
@@ -297,6 +529,7 @@ func (provisioner *OpenTofuProvisioner) getOrNewTerraform(ctx context.Context, g
 			resourcesv1alpha1.Group + "/managedBy.kind":      resourceGkv.Kind,
 			resourcesv1alpha1.Group + "/managedBy.name":      resource.Name,
 			resourcesv1alpha1.Group + "/managedBy.placement": resource.Spec.Placement,
+			openTofuSourceLabel:                              sourceLabel,
 		})
 		terraform.SetOwnerReferences([]metav1.OwnerReference{
 			{
@@ -309,17 +542,74 @@ func (provisioner *OpenTofuProvisioner) getOrNewTerraform(ctx context.Context, g
 			},
 		})
 
-		if err := provisioner.client.Create(ctx, terraform); err != nil {
-			return nil, err
+		applyPassThroughMetadata(terraform, resource)
+
+		terraform, err = terraforms.Create(ctx, terraform, metav1.CreateOptions{})
+		if err != nil {
+			return nil, false, err
 		}
+		return terraform, true, nil
 	} else {
-		terraform.Object["spec"] = newSpec()
-		if err := provisioner.client.Update(ctx, terraform); err != nil {
-			return nil, err
+		spec, err := newSpec()
+		if err != nil {
+			return nil, false, err
+		}
+		previousRepo, _, _ := unstructured.NestedStringMap(terraform.Object, "spec", "sourceRef")
+		sourceKind, _, _ := unstructured.NestedString(spec, "sourceRef", "kind")
+
+		terraform.Object["spec"] = spec
+		labels := terraform.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[openTofuSourceLabel] = sourceLabel
+		terraform.SetLabels(labels)
+		applyPassThroughMetadata(terraform, resource)
+		terraform, err = terraforms.Update(ctx, terraform, metav1.UpdateOptions{})
+		if err != nil {
+			return nil, false, err
+		}
+
+		// the properties of the ResourceRef changed the source, so the previous one may not be used anymore
+		if previousRepo["kind"] != sourceKind || previousRepo["name"] != repo.Name || cmp.Or(previousRepo["namespace"], resource.Namespace) != repo.Namespace {
+			if err := provisioner.releaseRepos(ctx, cmp.Or(previousRepo["namespace"], resource.Namespace)); err != nil {
+				return nil, false, err
+			}
+			return terraform, true, nil
 		}
 	}
 
-	return terraform, nil
+	return terraform, false, nil
+}
+
+// destroyResourcesOnDeletion follows the deletion policy of the Resource; without one, the properties decide. A
+// protected Resource is never destroyed.
+func (provisioner *OpenTofuProvisioner) destroyResourcesOnDeletion(resource *resourcesv1alpha1.Resource) bool {
+	if resource.Spec.Protect {
+		return false
+	}
+	if resource.Spec.DeletionPolicy == "" && provisioner.properties.DestroyResourcesOnDeletion != nil {
+		return *provisioner.properties.DestroyResourcesOnDeletion
+	}
+	return deletionPolicyOf(resource) == resourcesv1alpha1.DeletionPolicyDelete
+}
+
+// terraformGvk is the kind of the Terraform objects, in the tf-controller API version of the properties
+func (provisioner *OpenTofuProvisioner) terraformGvk() schema.GroupVersionKind {
+	version := defaultTerraformVersion
+	if provisioner.properties.TerraformVersion != nil && *provisioner.properties.TerraformVersion != "" {
+		version = *provisioner.properties.TerraformVersion
+	}
+	return schema.GroupVersionKind{Group: terraformGroup, Version: version, Kind: "Terraform"}
+}
+
+// resourceOf resolves the resource of a kind with the RESTMapper of the cluster, instead of guessing its plural
+func (provisioner *OpenTofuProvisioner) resourceOf(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error) {
+	mapping, err := provisioner.client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return schema.GroupVersionResource{}, fmt.Errorf("unable to find the resource of %s; are its CRDs installed? %w", gvk, err)
+	}
+	return mapping.Resource, nil
 }
 
 func (provisioner *OpenTofuProvisioner) readTerraformOutputs(ctx context.Context, terraform *unstructured.Unstructured) (map[string]any, error) {
@@ -347,16 +637,91 @@ func (provisioner *OpenTofuProvisioner) readTerraformOutputs(ctx context.Context
 
 	provisioner.log.Info(fmt.Sprintf("outputs available from Terraform object %s are: %s", terraform.GetName(), outputsAvailable))
 
+	outputTypes, err := provisioner.readTerraformOutputTypes(ctx, terraform)
+	if err != nil {
+		return nil, err
+	}
+
 	outputs := make(map[string]any)
 	for _, outputName := range outputsAvailable {
 		if rawValue, ok := outputsSecret.Data[outputName]; ok {
-			// value, err := base64.StdEncoding.DecodeString(string(rawValue))
-			// if err != nil {
-			// 	return nil, err
-			// }
-			outputs[outputName] = string(rawValue)
+			outputs[outputName] = typedOutputValue(rawValue, outputTypes[outputName])
 		}
 	}
 
 	return outputs, nil
 }
+
+// readTerraformOutputTypes reads the types of the outputs from the state kept by tf-controller in its default
+// kubernetes backend; with a custom backend, or before the state is written, the types aren't known
+func (provisioner *OpenTofuProvisioner) readTerraformOutputTypes(ctx context.Context, terraform *unstructured.Unstructured) (map[string]any, error) {
+	if _, custom, _ := unstructured.NestedMap(terraform.Object, "spec", "backendConfig"); custom {
+		return nil, nil
+	}
+
+	stateSecret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: terraform.GetNamespace(), Name: fmt.Sprintf("tfstate-%s-%s", openTofuRemoteStateWorkspace, terraform.GetName())}
+	if err := provisioner.client.Get(ctx, key, stateSecret); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+
+	state, err := decodeRemoteState(stateSecret.Data[openTofuRemoteStateKey])
+	if err != nil || state == nil {
+		return nil, err
+	}
+
+	outputTypes := make(map[string]any)
+	for name, output := range state.Outputs {
+		outputTypes[name] = output.Type
+	}
+
+	return outputTypes, nil
+}
+
+func (provisioner *OpenTofuProvisioner) isDrifted(terraform *unstructured.Unstructured) (bool, error) {
+	conditions, exists, err := unstructured.NestedSlice(terraform.Object, "status", "conditions")
+	if err != nil || !exists {
+		return false, err
+	}
+
+	for _, condition := range conditions {
+		conditionAsMap := condition.(map[string]any)
+		if conditionAsMap["type"] == "Ready" && conditionAsMap["reason"] == "DriftDetected" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (provisioner *OpenTofuProvisioner) readTerraformInventory(terraform *unstructured.Unstructured) ([]ProvisionedInventoryEntry, error) {
+	entries, exists, err := unstructured.NestedSlice(terraform.Object, "status", "inventory", "entries")
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	provisioner.log.Info(fmt.Sprintf("Terraform object %s reported %d resources in its state", terraform.GetName(), len(entries)))
+
+	inventory := make([]ProvisionedInventoryEntry, 0, len(entries))
+	for _, entry := range entries {
+		entryAsMap, ok := entry.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		name, _, _ := unstructured.NestedString(entryAsMap, "name")
+		resourceType, _, _ := unstructured.NestedString(entryAsMap, "type")
+		identifier, _, _ := unstructured.NestedString(entryAsMap, "identifier")
+
+		inventory = append(inventory, ProvisionedInventoryEntry{
+			Type: resourceType,
+			Name: name,
+			ID:   identifier,
+		})
+	}
+
+	return inventory, nil
+}
@@ -0,0 +1,242 @@
+package provisioning
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// the Terraform kubernetes backend stores each workspace state in a Secret named tfstate-<workspace>-<secret_suffix>
+const (
+	openTofuRemoteStateWorkspace = "default"
+	openTofuRemoteStateKey       = "tfstate"
+)
+
+type openTofuProvisionerRemoteStateProperties struct {
+	Namespace    *string `json:"namespace"`
+	SecretSuffix *string `json:"secretSuffix"`
+}
+
+type openTofuRemoteState struct {
+	Version          int                                  `json:"version"`
+	TerraformVersion string                               `json:"terraform_version"`
+	Serial           int64                                `json:"serial"`
+	Lineage          string                               `json:"lineage"`
+	Outputs          map[string]openTofuRemoteStateOutput `json:"outputs"`
+	Resources        []any                                `json:"resources"`
+}
+
+type openTofuRemoteStateOutput struct {
+	Value any `json:"value"`
+	// Type is a cty type, in its JSON form: a primitive name ("string") or a constructor (["list","string"])
+	Type any `json:"type"`
+}
+
+// publishRemoteState writes the resource outputs as a state compatible with the Terraform kubernetes backend,
+// so Terraform code outside klaudio can read them through a terraform_remote_state data source
+func (provisioner *OpenTofuProvisioner) publishRemoteState(ctx context.Context, resource *resourcesv1alpha1.Resource, outputs map[string]any) error {
+	key, secretSuffix := provisioner.remoteStateKeyOf(resource)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+		},
+	}
+
+	operation, err := controllerutil.CreateOrUpdate(ctx, provisioner.client, secret, func() error {
+		current, err := decodeRemoteState(secret.Data[openTofuRemoteStateKey])
+		if err != nil {
+			return err
+		}
+
+		state := newRemoteState(string(resource.UID), outputs)
+		if current != nil {
+			if equalRemoteStateOutputs(current.Outputs, state.Outputs) {
+				return nil
+			}
+			state.Serial = current.Serial + 1
+		}
+
+		encoded, err := encodeRemoteState(state)
+		if err != nil {
+			return err
+		}
+
+		secret.Labels = map[string]string{
+			"app.kubernetes.io/managed-by":                   "terraform",
+			"tfstate":                                        "true",
+			"tfstateSecretSuffix":                            secretSuffix,
+			"tfstateWorkspace":                               openTofuRemoteStateWorkspace,
+			resourcesv1alpha1.Group + "/managedBy.name":      resource.Name,
+			resourcesv1alpha1.Group + "/managedBy.placement": resource.Spec.Placement,
+		}
+		secret.Data = map[string][]byte{
+			openTofuRemoteStateKey: encoded,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	provisioner.log.Info(fmt.Sprintf("outputs from resource %s were published to remote state %s/%s (%s)", resource.Name, secret.Namespace, secret.Name, operation))
+
+	return nil
+}
+
+// deleteRemoteState removes the published state; it may live in another namespace, so no owner reference collects it
+func (provisioner *OpenTofuProvisioner) deleteRemoteState(ctx context.Context, resource *resourcesv1alpha1.Resource) error {
+	key, _ := provisioner.remoteStateKeyOf(resource)
+
+	secret := &corev1.Secret{}
+	if err := provisioner.client.Get(ctx, key, secret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	// the suffix can be chosen freely, so don't delete a state that was not published from this resource
+	if secret.Labels[resourcesv1alpha1.Group+"/managedBy.name"] != resource.Name {
+		return nil
+	}
+
+	if err := provisioner.client.Delete(ctx, secret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	provisioner.log.Info(fmt.Sprintf("remote state %s/%s from resource %s was deleted", key.Namespace, key.Name, resource.Name))
+
+	return nil
+}
+
+func (provisioner *OpenTofuProvisioner) remoteStateKeyOf(resource *resourcesv1alpha1.Resource) (types.NamespacedName, string) {
+	remoteState := provisioner.properties.RemoteState
+
+	namespace := resource.Namespace
+	if remoteState.Namespace != nil {
+		namespace = *remoteState.Namespace
+	}
+
+	secretSuffix := strings.ReplaceAll(resource.Name, ".", "-")
+	if remoteState.SecretSuffix != nil {
+		secretSuffix = *remoteState.SecretSuffix
+	}
+
+	key := types.NamespacedName{
+		Namespace: namespace,
+		Name:      fmt.Sprintf("tfstate-%s-%s", openTofuRemoteStateWorkspace, secretSuffix),
+	}
+
+	return key, secretSuffix
+}
+
+func newRemoteState(lineage string, outputs map[string]any) *openTofuRemoteState {
+	state := &openTofuRemoteState{
+		Version:          4,
+		TerraformVersion: "1.5.7",
+		Serial:           1,
+		Lineage:          lineage,
+		Outputs:          make(map[string]openTofuRemoteStateOutput),
+		Resources:        []any{},
+	}
+
+	for name, value := range outputs {
+		state.Outputs[name] = openTofuRemoteStateOutput{
+			Value: value,
+			Type:  remoteStateOutputType(value),
+		}
+	}
+
+	return state
+}
+
+// remoteStateOutputType infers the cty type of an output from its JSON value, so terraform_remote_state
+// exposes numbers, booleans and collections with their own types
+func remoteStateOutputType(value any) any {
+	switch value := value.(type) {
+	case bool:
+		return "bool"
+	case float64, float32, int, int32, int64, json.Number:
+		return "number"
+	case map[string]any:
+		attributes := make(map[string]any)
+		for name, attribute := range value {
+			attributes[name] = remoteStateOutputType(attribute)
+		}
+		return []any{"object", attributes}
+	case []any:
+		elements := make([]any, 0, len(value))
+		for _, element := range value {
+			elements = append(elements, remoteStateOutputType(element))
+		}
+		return []any{"tuple", elements}
+	}
+	return "string"
+}
+
+func equalRemoteStateOutputs(a map[string]openTofuRemoteStateOutput, b map[string]openTofuRemoteStateOutput) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, output := range a {
+		other, ok := b[name]
+		if !ok || fmt.Sprint(output.Value) != fmt.Sprint(other.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeRemoteState serializes the state as the kubernetes backend does: gzipped JSON
+func encodeRemoteState(state *openTofuRemoteState) ([]byte, error) {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(content); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func decodeRemoteState(content []byte) (*openTofuRemoteState, error) {
+	if len(content) == 0 {
+		return nil, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &openTofuRemoteState{}
+	if err := json.Unmarshal(decompressed, state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
@@ -0,0 +1,178 @@
+package provisioning
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_RemoteState(t *testing.T) {
+
+	t.Run("We should be able to encode outputs as a Terraform state", func(t *testing.T) {
+		state := newRemoteState("my-lineage", map[string]any{
+			"endpoint": "my-database.rds.amazonaws.com",
+		})
+
+		encoded, err := encodeRemoteState(state)
+		assert.NoError(t, err)
+
+		decoded, err := decodeRemoteState(encoded)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 4, decoded.Version)
+		assert.Equal(t, "my-lineage", decoded.Lineage)
+		assert.Equal(t, int64(1), decoded.Serial)
+		assert.Equal(t, "my-database.rds.amazonaws.com", decoded.Outputs["endpoint"].Value)
+		assert.Equal(t, "string", decoded.Outputs["endpoint"].Type)
+		assert.Empty(t, decoded.Resources)
+	})
+
+	t.Run("We should be able to keep the type of each output", func(t *testing.T) {
+		state := newRemoteState("my-lineage", map[string]any{
+			"port":     float64(5432),
+			"public":   false,
+			"endpoint": "my-database.rds.amazonaws.com",
+			"tags":     map[string]any{"team": "platform"},
+			"zones":    []any{"us-east-1a", "us-east-1b"},
+		})
+
+		assert.Equal(t, "number", state.Outputs["port"].Type)
+		assert.Equal(t, "bool", state.Outputs["public"].Type)
+		assert.Equal(t, "string", state.Outputs["endpoint"].Type)
+		assert.Equal(t, []any{"object", map[string]any{"team": "string"}}, state.Outputs["tags"].Type)
+		assert.Equal(t, []any{"tuple", []any{"string", "string"}}, state.Outputs["zones"].Type)
+	})
+
+	t.Run("We should be able to compare outputs from two states", func(t *testing.T) {
+		a := newRemoteState("my-lineage", map[string]any{"endpoint": "a"})
+		b := newRemoteState("my-lineage", map[string]any{"endpoint": "a"})
+		c := newRemoteState("my-lineage", map[string]any{"endpoint": "c"})
+
+		assert.True(t, equalRemoteStateOutputs(a.Outputs, b.Outputs))
+		assert.False(t, equalRemoteStateOutputs(a.Outputs, c.Outputs))
+	})
+
+	t.Run("An empty content should be decoded to no state at all", func(t *testing.T) {
+		decoded, err := decodeRemoteState(nil)
+		assert.NoError(t, err)
+		assert.Nil(t, decoded)
+	})
+}
+
+func Test_RemoteStateSecret(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	ctx := context.TODO()
+
+	newProvisioner := func(t *testing.T, objects ...runtime.Object) *OpenTofuProvisioner {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+
+		provisioner, err := newOpenTofuProvisioner(c, nil, scheme, logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       OpenTofuProvisionerName,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"git":{"repo":"https://github.com/nubank/modules"},"remoteState":{"namespace":"legacy"}}`)},
+		})
+		assert.NoError(t, err)
+		return provisioner.(*OpenTofuProvisioner)
+	}
+
+	resource := &resourcesv1alpha1.Resource{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders-bucket", Namespace: "checkout", UID: "orders-bucket-uid"},
+		Spec:       resourcesv1alpha1.ResourceSpec{Placement: "prod"},
+	}
+	key := types.NamespacedName{Namespace: "legacy", Name: "tfstate-default-orders-bucket"}
+
+	t.Run("We should delete the remote state published in another namespace", func(t *testing.T) {
+		provisioner := newProvisioner(t)
+
+		assert.NoError(t, provisioner.publishRemoteState(ctx, resource, map[string]any{"endpoint": "a"}))
+		assert.NoError(t, provisioner.client.Get(ctx, key, &corev1.Secret{}))
+
+		assert.NoError(t, provisioner.deleteRemoteState(ctx, resource))
+
+		err := provisioner.client.Get(ctx, key, &corev1.Secret{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("We should keep a state with the same name that was not published from the resource", func(t *testing.T) {
+		provisioner := newProvisioner(t, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+		})
+
+		assert.NoError(t, provisioner.deleteRemoteState(ctx, resource))
+		assert.NoError(t, provisioner.client.Get(ctx, key, &corev1.Secret{}))
+	})
+
+	t.Run("A missing remote state should not be an error", func(t *testing.T) {
+		assert.NoError(t, newProvisioner(t).deleteRemoteState(ctx, resource))
+	})
+}
+
+func Test_TerraformOutputTypes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+
+	ctx := context.TODO()
+
+	terraform := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"writeOutputsToSecret": map[string]any{"name": "orders-bucket-outputs"},
+		},
+		"status": map[string]any{
+			"availableOutputs": []any{"account_id", "port"},
+		},
+	}}
+	terraform.SetNamespace("checkout")
+	terraform.SetName("orders-bucket")
+
+	outputsSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders-bucket-outputs", Namespace: "checkout"},
+		Data: map[string][]byte{
+			"account_id": []byte("001234567890"),
+			"port":       []byte("5432"),
+		},
+	}
+
+	newProvisioner := func(t *testing.T, objects ...runtime.Object) *OpenTofuProvisioner {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+
+		provisioner, err := newOpenTofuProvisioner(c, nil, scheme, logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       OpenTofuProvisionerName,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"git":{"repo":"https://github.com/nubank/modules"}}`)},
+		})
+		assert.NoError(t, err)
+		return provisioner.(*OpenTofuProvisioner)
+	}
+
+	t.Run("We should read outputs with the types reported by the tf-controller state", func(t *testing.T) {
+		state := newRemoteState("my-lineage", map[string]any{"account_id": "001234567890", "port": float64(5432)})
+		encoded, err := encodeRemoteState(state)
+		assert.NoError(t, err)
+
+		provisioner := newProvisioner(t, outputsSecret, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "tfstate-default-orders-bucket", Namespace: "checkout"},
+			Data:       map[string][]byte{openTofuRemoteStateKey: encoded},
+		})
+
+		outputs, err := provisioner.readTerraformOutputs(ctx, terraform)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]any{"account_id": "001234567890", "port": float64(5432)}, outputs)
+	})
+
+	t.Run("Without the state, numeric outputs should be kept as strings", func(t *testing.T) {
+		outputs, err := newProvisioner(t, outputsSecret).readTerraformOutputs(ctx, terraform)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]any{"account_id": "001234567890", "port": "5432"}, outputs)
+	})
+}
@@ -0,0 +1,359 @@
+package provisioning
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+)
+
+// Sources (GitRepositories, OCIRepositories or Buckets) are shared by every Terraform object reading the same module:
+// their names are derived from their spec, so the Resources of all ResourceRefs using the same repo and branch read a
+// single source. They aren't owned by any object; once no Terraform object created by the provisioner reads one
+// anymore, it's deleted.
+const (
+	defaultOpenTofuSourceInterval = "1m"
+
+	// openTofuManagedByLabel marks the sources shared by the OpenTofu provisioner; other ones are never deleted
+	openTofuManagedByLabel = resourcesv1alpha1.Group + "/managedBy.provisioner"
+
+	// openTofuSourceLabel indexes the Terraform objects by the source they read, so its readers are listed without
+	// listing every Terraform object of the cluster
+	openTofuSourceLabel = resourcesv1alpha1.Group + "/source"
+
+	// openTofuNewReaderAnnotation is changed on a source each time a Terraform object starts reading it, so a deletion
+	// of the source decided before fails
+	openTofuNewReaderAnnotation = resourcesv1alpha1.Group + "/newReaderAt"
+)
+
+var (
+	ociRepositoryGvk = schema.GroupVersionKind{
+		Group:   "source.toolkit.fluxcd.io",
+		Version: "v1beta2",
+		Kind:    "OCIRepository",
+	}
+	bucketGvk = schema.GroupVersionKind{
+		Group:   "source.toolkit.fluxcd.io",
+		Version: "v1beta2",
+		Kind:    "Bucket",
+	}
+
+	// openTofuSourceKinds are the kinds of source the Terraform objects may read
+	openTofuSourceKinds = []schema.GroupVersionKind{gitRepositoryGvk, ociRepositoryGvk, bucketGvk}
+)
+
+type openTofuProvisionerOCIProperties struct {
+	// URL is the OCI repository of the module, like oci://ghcr.io/nubank/modules
+	URL string `json:"url"`
+	// one of Tag, Semver or Digest is pulled; latest by default
+	Tag    *string `json:"tag"`
+	Semver *string `json:"semver"`
+	Digest *string `json:"digest"`
+	// Provider authenticates to the registry: generic, the default, aws, azure or gcp
+	Provider *string `json:"provider"`
+	// SecretRef is the Secret with the credentials of the registry
+	SecretRef *string `json:"secretRef"`
+	Insecure  bool    `json:"insecure"`
+	openTofuProvisionerSourceProperties
+}
+
+type openTofuProvisionerBucketProperties struct {
+	BucketName string `json:"bucketName"`
+	Endpoint   string `json:"endpoint"`
+	// Provider authenticates to the bucket: generic, the default, aws, azure or gcp
+	Provider *string `json:"provider"`
+	Region   *string `json:"region"`
+	// Prefix only fetches the objects under it
+	Prefix *string `json:"prefix"`
+	// SecretRef is the Secret with the credentials of the bucket
+	SecretRef *string `json:"secretRef"`
+	Insecure  bool    `json:"insecure"`
+	openTofuProvisionerSourceProperties
+}
+
+// validateSource checks that exactly one source is declared, with the fields it requires
+func (properties *openTofuProvisionerProperties) validateSource() error {
+	declared := 0
+	if properties.Git.Repo != "" {
+		declared++
+	}
+	if properties.OCI != nil {
+		declared++
+		if properties.OCI.URL == "" {
+			return fmt.Errorf("oci requires a url")
+		}
+	}
+	if properties.Bucket != nil {
+		declared++
+		if properties.Bucket.BucketName == "" || properties.Bucket.Endpoint == "" {
+			return fmt.Errorf("bucket requires a bucketName and an endpoint")
+		}
+	}
+	if declared > 1 {
+		return fmt.Errorf("only one of git, oci or bucket may be declared")
+	}
+	return nil
+}
+
+// source is the kind of source read by the Terraform objects of the ResourceRef, and where the module is in it
+func (provisioner *OpenTofuProvisioner) source() (schema.GroupVersionKind, *openTofuProvisionerSourceProperties) {
+	switch {
+	case provisioner.properties.OCI != nil:
+		return ociRepositoryGvk, &provisioner.properties.OCI.openTofuProvisionerSourceProperties
+	case provisioner.properties.Bucket != nil:
+		return bucketGvk, &provisioner.properties.Bucket.openTofuProvisionerSourceProperties
+	default:
+		return gitRepositoryGvk, &provisioner.properties.Git.openTofuProvisionerSourceProperties
+	}
+}
+
+// repoSpec is the spec of the source read by the Terraform objects of the ResourceRef
+func (provisioner *OpenTofuProvisioner) repoSpec() map[string]any {
+	sourceGvk, source := provisioner.source()
+
+	spec := map[string]any{
+		"interval": defaultOpenTofuSourceInterval,
+	}
+	if interval := source.Interval; interval != nil && *interval != "" {
+		spec["interval"] = *interval
+	}
+
+	switch sourceGvk {
+	case ociRepositoryGvk:
+		oci := provisioner.properties.OCI
+		spec["url"] = oci.URL
+		ref := make(map[string]any)
+		for name, value := range map[string]*string{"tag": oci.Tag, "semver": oci.Semver, "digest": oci.Digest} {
+			if value != nil {
+				ref[name] = *value
+			}
+		}
+		if len(ref) > 0 {
+			spec["ref"] = ref
+		}
+		setSourceAccess(spec, oci.Provider, oci.SecretRef, oci.Insecure)
+
+	case bucketGvk:
+		bucket := provisioner.properties.Bucket
+		spec["bucketName"] = bucket.BucketName
+		spec["endpoint"] = bucket.Endpoint
+		if region := bucket.Region; region != nil {
+			spec["region"] = *region
+		}
+		if prefix := bucket.Prefix; prefix != nil {
+			spec["prefix"] = *prefix
+		}
+		setSourceAccess(spec, bucket.Provider, bucket.SecretRef, bucket.Insecure)
+
+	default:
+		spec["url"] = provisioner.properties.Git.Repo
+		if branch := provisioner.properties.Git.Branch; branch != nil {
+			spec["ref"] = map[string]any{"branch": *branch}
+		}
+	}
+
+	return provisioner.overrides.apply(sourceGvk.Kind, spec)
+}
+
+// setSourceAccess writes how Flux authenticates to an OCI repository or a bucket
+func setSourceAccess(spec map[string]any, provider *string, secretRef *string, insecure bool) {
+	if provider != nil {
+		spec["provider"] = *provider
+	}
+	if secretRef != nil {
+		spec["secretRef"] = map[string]any{"name": *secretRef}
+	}
+	if insecure {
+		spec["insecure"] = true
+	}
+}
+
+// repoNamespaceOf is the namespace of the source read by the Resource
+func (provisioner *OpenTofuProvisioner) repoNamespaceOf(resource *resourcesv1alpha1.Resource) string {
+	if _, source := provisioner.source(); source.Namespace != nil && *source.Namespace != "" {
+		return *source.Namespace
+	}
+	return resource.Namespace
+}
+
+// repoKeyOf is the source read by the Resource; maps are encoded with sorted keys, so the same spec always has the
+// same name
+func (provisioner *OpenTofuProvisioner) repoKeyOf(resource *resourcesv1alpha1.Resource) types.NamespacedName {
+	encoded, _ := json.Marshal(provisioner.repoSpec())
+	sum := sha256.Sum256(encoded)
+	return types.NamespacedName{
+		Namespace: provisioner.repoNamespaceOf(resource),
+		Name:      fmt.Sprintf("klaudio-%s", hex.EncodeToString(sum[:])[:10]),
+	}
+}
+
+// getOrNewRepo returns the source read by the Resource, creating it when it doesn't exist. A newReader is a Terraform
+// object that was just written to read it: the source is changed then, so releaseRepos can't delete it in between.
+func (provisioner *OpenTofuProvisioner) getOrNewRepo(ctx context.Context, resource *resourcesv1alpha1.Resource, newReader bool) (*unstructured.Unstructured, error) {
+	sourceGvk, _ := provisioner.source()
+
+	repoGvr, err := provisioner.resourceOf(sourceGvk)
+	if err != nil {
+		return nil, err
+	}
+
+	key := provisioner.repoKeyOf(resource)
+
+	repos := provisioner.dynamicClient.Resource(repoGvr).Namespace(key.Namespace)
+
+	repo, err := repos.Get(ctx, key.Name, metav1.GetOptions{})
+	if err == nil {
+		if !newReader {
+			return repo, nil
+		}
+
+		annotations := repo.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[openTofuNewReaderAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+		repo.SetAnnotations(annotations)
+
+		updated, err := repos.Update(ctx, repo, metav1.UpdateOptions{})
+		switch {
+		case err == nil:
+			return updated, nil
+		// changed by someone else since it was read, which fails a pending deletion as well
+		case apierrors.IsConflict(err):
+			return repos.Get(ctx, key.Name, metav1.GetOptions{})
+		// released meanwhile; it's created again
+		case !apierrors.IsNotFound(err):
+			return nil, err
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	repo = &unstructured.Unstructured{}
+	repo.SetUnstructuredContent(map[string]any{
+		"apiVersion": sourceGvk.GroupVersion().String(),
+		"kind":       sourceGvk.Kind,
+		"metadata": map[string]any{
+			"name":      key.Name,
+			"namespace": key.Namespace,
+		},
+		"spec": provisioner.repoSpec(),
+	})
+	repo.SetLabels(map[string]string{
+		openTofuManagedByLabel: OpenTofuProvisionerName,
+	})
+
+	created, err := repos.Create(ctx, repo, metav1.CreateOptions{})
+	if err != nil {
+		// created by another Resource reading the same source in the meantime
+		if apierrors.IsAlreadyExists(err) {
+			return repos.Get(ctx, key.Name, metav1.GetOptions{})
+		}
+		return nil, err
+	}
+
+	provisioner.log.Info(fmt.Sprintf("%s %s/%s created", sourceGvk.Kind, key.Namespace, key.Name))
+
+	return created, nil
+}
+
+// releaseRepos deletes the sources of the namespace shared by the provisioner that no Terraform object, from any
+// namespace, reads anymore; kinds of source whose CRDs aren't installed are skipped. Readers are found by the source
+// label of the Terraform objects; the ones written by older releases, without it, are still listed, until their
+// Resources run again. Each source is deleted only if it didn't change since it was listed, so a Terraform object that
+// started reading it meanwhile keeps it.
+func (provisioner *OpenTofuProvisioner) releaseRepos(ctx context.Context, namespace string) error {
+	terraformGvr, err := provisioner.resourceOf(provisioner.terraformGvk())
+	if err != nil {
+		return err
+	}
+	terraforms := provisioner.dynamicClient.Resource(terraformGvr)
+
+	var unlabeled map[string]int
+	for _, sourceGvk := range openTofuSourceKinds {
+		repoGvr, err := provisioner.resourceOf(sourceGvk)
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+			return err
+		}
+
+		repos, err := provisioner.dynamicClient.Resource(repoGvr).Namespace(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", openTofuManagedByLabel, OpenTofuProvisionerName),
+		})
+		if err != nil {
+			return err
+		}
+		if len(repos.Items) == 0 {
+			continue
+		}
+
+		// the Terraform objects without the source label are only listed once there is something to release
+		if unlabeled == nil {
+			legacy, err := terraforms.List(ctx, metav1.ListOptions{
+				LabelSelector: fmt.Sprintf("%s=Resource,!%s", resourcesv1alpha1.Group+"/managedBy.kind", openTofuSourceLabel),
+			})
+			if err != nil {
+				return err
+			}
+
+			unlabeled = make(map[string]int)
+			for _, terraform := range legacy.Items {
+				sourceRef, _, _ := unstructured.NestedStringMap(terraform.Object, "spec", "sourceRef")
+				unlabeled[sourceKeyOf(sourceRef["kind"], cmp.Or(sourceRef["namespace"], terraform.GetNamespace()), sourceRef["name"])]++
+			}
+		}
+
+		for _, repo := range repos.Items {
+			if unlabeled[sourceKeyOf(sourceGvk.Kind, repo.GetNamespace(), repo.GetName())] > 0 {
+				continue
+			}
+
+			readers, err := terraforms.List(ctx, metav1.ListOptions{
+				LabelSelector: fmt.Sprintf("%s=%s", openTofuSourceLabel, sourceLabelOf(sourceGvk.Kind, repo.GetNamespace(), repo.GetName())),
+				Limit:         1,
+			})
+			if err != nil {
+				return err
+			}
+			if len(readers.Items) > 0 {
+				continue
+			}
+
+			provisioner.log.Info(fmt.Sprintf("%s %s/%s isn't read by any Terraform object anymore; deleting it...", sourceGvk.Kind, repo.GetNamespace(), repo.GetName()))
+
+			err = provisioner.dynamicClient.Resource(repoGvr).Namespace(repo.GetNamespace()).Delete(ctx, repo.GetName(), metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: ptr.To(repo.GetResourceVersion())},
+			})
+			if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func sourceKeyOf(kind string, namespace string, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
+}
+
+// sourceLabelOf is the value of the source label of the Terraform objects reading a source; names don't fit label
+// values, so they're hashed
+func sourceLabelOf(kind string, namespace string, name string) string {
+	sum := sha256.Sum256([]byte(sourceKeyOf(kind, namespace, name)))
+	return hex.EncodeToString(sum[:])[:32]
+}
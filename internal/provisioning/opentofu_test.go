@@ -0,0 +1,462 @@
+package provisioning
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_OpenTofuTerraformSpec(t *testing.T) {
+	newProvisioner := func(t *testing.T, properties string) *OpenTofuProvisioner {
+		provisioner, err := newOpenTofuProvisioner(nil, nil, nil, logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       OpenTofuProvisionerName,
+			Properties: &runtime.RawExtension{Raw: []byte(properties)},
+		})
+		assert.NoError(t, err)
+		return provisioner.(*OpenTofuProvisioner)
+	}
+
+	newResource := func(deletionPolicy resourcesv1alpha1.DeletionPolicy) *resourcesv1alpha1.Resource {
+		return &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders-bucket", Namespace: "checkout"},
+			Spec: resourcesv1alpha1.ResourceSpec{
+				Placement:      "prod",
+				DeletionPolicy: deletionPolicy,
+				Properties:     &runtime.RawExtension{Raw: []byte(`{"name":"orders"}`)},
+			},
+		}
+	}
+
+	t.Run("Without knobs, we should auto-approve plans and destroy resources on deletion", func(t *testing.T) {
+		spec, err := newProvisioner(t, `{"git":{"repo":"https://github.com/nubank/modules","interval":"5m"}}`).terraformSpec(types.NamespacedName{Namespace: "checkout", Name: "bucket"}, newResource(""))
+		assert.NoError(t, err)
+
+		assert.Equal(t, "auto", spec["approvePlan"])
+		assert.Equal(t, "5m", spec["interval"])
+		assert.Equal(t, true, spec["destroyResourcesOnDeletion"])
+		assert.NotContains(t, spec, "backendConfig")
+		assert.NotContains(t, spec, "runnerPodTemplate")
+		assert.NotContains(t, spec, "serviceAccountName")
+		assert.NotContains(t, spec, "varsFrom")
+	})
+
+	t.Run("We should write the knobs of the properties to the Terraform spec", func(t *testing.T) {
+		provisioner := newProvisioner(t, `{
+			"git": {"repo": "https://github.com/nubank/modules", "interval": "5m"},
+			"approvePlan": "manual",
+			"interval": "1h",
+			"destroyResourcesOnDeletion": false,
+			"backendConfig": {"customConfiguration": "backend \"s3\" {}"},
+			"runnerPodTemplate": {"spec": {"nodeSelector": {"pool": "terraform"}}},
+			"serviceAccountName": "tf-runner",
+			"varsFrom": [
+				{"kind": "ConfigMap", "name": "prod-tfvars"},
+				{"kind": "Secret", "name": "prod-credentials", "varsKeys": ["token"], "optional": true}
+			]
+		}`)
+
+		spec, err := provisioner.terraformSpec(types.NamespacedName{Namespace: "checkout", Name: "bucket"}, newResource(""))
+		assert.NoError(t, err)
+
+		assert.Equal(t, "", spec["approvePlan"])
+		assert.Equal(t, "1h", spec["interval"])
+		assert.Equal(t, false, spec["destroyResourcesOnDeletion"])
+		assert.Equal(t, map[string]any{"customConfiguration": `backend "s3" {}`}, spec["backendConfig"])
+		assert.Equal(t, map[string]any{"spec": map[string]any{"nodeSelector": map[string]any{"pool": "terraform"}}}, spec["runnerPodTemplate"])
+		assert.Equal(t, "tf-runner", spec["serviceAccountName"])
+		assert.Equal(t, []any{
+			map[string]any{"kind": "ConfigMap", "name": "prod-tfvars"},
+			map[string]any{"kind": "Secret", "name": "prod-credentials", "varsKeys": []any{"token"}, "optional": true},
+		}, spec["varsFrom"])
+
+		secrets, err := provisioner.ConsumedSecrets(newResource(""))
+		assert.NoError(t, err)
+		assert.Equal(t, []types.NamespacedName{{Namespace: "checkout", Name: "prod-credentials"}}, secrets)
+	})
+
+	t.Run("We should read the module from a Bucket", func(t *testing.T) {
+		provisioner := newProvisioner(t, `{"bucket":{"bucketName":"modules","endpoint":"s3.amazonaws.com","region":"us-east-1","secretRef":"bucket-credentials","interval":"10m","dir":"queue"}}`)
+
+		assert.Equal(t, map[string]any{
+			"bucketName": "modules",
+			"endpoint":   "s3.amazonaws.com",
+			"region":     "us-east-1",
+			"secretRef":  map[string]any{"name": "bucket-credentials"},
+			"interval":   "10m",
+		}, provisioner.repoSpec())
+
+		spec, err := provisioner.terraformSpec(types.NamespacedName{Namespace: "checkout", Name: "modules"}, newResource(""))
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]any{"kind": "Bucket", "name": "modules", "namespace": "checkout"}, spec["sourceRef"])
+		assert.Equal(t, "10m", spec["interval"])
+		assert.Equal(t, "queue", spec["path"])
+	})
+
+	t.Run("The deletion policy of the Resource should win over destroyResourcesOnDeletion", func(t *testing.T) {
+		provisioner := newProvisioner(t, `{"git":{"repo":"https://github.com/nubank/modules"},"destroyResourcesOnDeletion":false}`)
+
+		spec, err := provisioner.terraformSpec(types.NamespacedName{Namespace: "checkout", Name: "bucket"}, newResource(resourcesv1alpha1.DeletionPolicyDelete))
+		assert.NoError(t, err)
+		assert.Equal(t, true, spec["destroyResourcesOnDeletion"])
+	})
+
+	t.Run("A protected Resource should never be destroyed, without variables the module doesn't declare", func(t *testing.T) {
+		provisioner := newProvisioner(t, `{"git":{"repo":"https://github.com/nubank/modules"},"destroyResourcesOnDeletion":true}`)
+
+		resource := newResource(resourcesv1alpha1.DeletionPolicyDelete)
+		resource.Spec.Protect = true
+
+		spec, err := provisioner.terraformSpec(types.NamespacedName{Namespace: "checkout", Name: "bucket"}, resource)
+		assert.NoError(t, err)
+		assert.Equal(t, false, spec["destroyResourcesOnDeletion"])
+		assert.Equal(t, []any{
+			map[string]any{"name": "name", "value": "orders"},
+		}, spec["vars"])
+	})
+
+	t.Run("A protected Resource should set the prevent destroy variable declared by the properties", func(t *testing.T) {
+		provisioner := newProvisioner(t, `{"git":{"repo":"https://github.com/nubank/modules"},"preventDestroyVar":"prevent_destroy"}`)
+
+		resource := newResource(resourcesv1alpha1.DeletionPolicyDelete)
+		resource.Spec.Protect = true
+
+		spec, err := provisioner.terraformSpec(types.NamespacedName{Namespace: "checkout", Name: "bucket"}, resource)
+		assert.NoError(t, err)
+		assert.Equal(t, []any{
+			map[string]any{"name": "name", "value": "orders"},
+			map[string]any{"name": "prevent_destroy", "value": true},
+		}, spec["vars"])
+
+		spec, err = provisioner.terraformSpec(types.NamespacedName{Namespace: "checkout", Name: "bucket"}, newResource(resourcesv1alpha1.DeletionPolicyDelete))
+		assert.NoError(t, err)
+		assert.Equal(t, []any{
+			map[string]any{"name": "name", "value": "orders"},
+		}, spec["vars"])
+	})
+}
+
+func Test_OpenTofuObjects(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+
+	ctx := context.TODO()
+
+	newResource := func(properties string) *resourcesv1alpha1.Resource {
+		return &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "orders-bucket", Namespace: "checkout"},
+			Spec: resourcesv1alpha1.ResourceSpec{
+				ResourceRef: "bucket",
+				Placement:   "prod",
+				Properties:  &runtime.RawExtension{Raw: []byte(properties)},
+			},
+		}
+	}
+
+	// the RESTMapper knows the tf-controller API in the given versions, as a cluster with its CRDs installed
+	newMapper := func(terraformVersions ...string) meta.RESTMapper {
+		mapper := meta.NewDefaultRESTMapper(nil)
+		mapper.Add(gitRepositoryGvk, meta.RESTScopeNamespace)
+		mapper.Add(ociRepositoryGvk, meta.RESTScopeNamespace)
+		for _, version := range terraformVersions {
+			mapper.Add(schema.GroupVersionKind{Group: terraformGroup, Version: version, Kind: "Terraform"}, meta.RESTScopeNamespace)
+		}
+		return mapper
+	}
+
+	newProvisioner := func(t *testing.T, mapper meta.RESTMapper, properties string) (*OpenTofuProvisioner, *dynamicfake.FakeDynamicClient) {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).Build()
+
+		provisioner, err := newOpenTofuProvisioner(c, nil, scheme, logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       OpenTofuProvisionerName,
+			Properties: &runtime.RawExtension{Raw: []byte(properties)},
+		})
+		assert.NoError(t, err)
+
+		dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+			gitRepositoryGvk.GroupVersion().WithResource("gitrepositories"):      "GitRepositoryList",
+			ociRepositoryGvk.GroupVersion().WithResource("ocirepositories"):      "OCIRepositoryList",
+			{Group: terraformGroup, Version: "v1alpha2", Resource: "terraforms"}: "TerraformList",
+			{Group: terraformGroup, Version: "v1alpha1", Resource: "terraforms"}: "TerraformList",
+		})
+
+		openTofuProvisioner := provisioner.(*OpenTofuProvisioner)
+		openTofuProvisioner.dynamicClient = dynamicClient
+		return openTofuProvisioner, dynamicClient
+	}
+
+	readTerraform := func(t *testing.T, dynamicClient *dynamicfake.FakeDynamicClient, version string) *unstructured.Unstructured {
+		terraform, err := dynamicClient.
+			Resource(schema.GroupVersionResource{Group: terraformGroup, Version: version, Resource: "terraforms"}).
+			Namespace("checkout").
+			Get(ctx, "orders-bucket", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return terraform
+	}
+
+	t.Run("We should create the GitRepository and the Terraform objects, resolving their resources with the RESTMapper", func(t *testing.T) {
+		provisioner, dynamicClient := newProvisioner(t, newMapper(defaultTerraformVersion), `{"git":{"repo":"https://github.com/nubank/modules","dir":"bucket"}}`)
+		resource := newResource(`{"name":"orders"}`)
+
+		repo, err := provisioner.getOrNewRepo(ctx, resource, false)
+		assert.NoError(t, err)
+		assert.Equal(t, "GitRepository", repo.GetKind())
+		assert.Equal(t, "checkout", repo.GetNamespace())
+
+		interval, _, _ := unstructured.NestedString(repo.Object, "spec", "interval")
+		assert.Equal(t, "1m", interval)
+
+		terraform, newReader, err := provisioner.getOrNewTerraform(ctx, client.ObjectKeyFromObject(repo), resource)
+		assert.NoError(t, err)
+		assert.True(t, newReader)
+		assert.Equal(t, schema.GroupVersionKind{Group: terraformGroup, Version: "v1alpha2", Kind: "Terraform"}, terraform.GroupVersionKind())
+		assert.Equal(t, sourceLabelOf("GitRepository", "checkout", repo.GetName()), terraform.GetLabels()[openTofuSourceLabel])
+
+		_, newReader, err = provisioner.getOrNewTerraform(ctx, client.ObjectKeyFromObject(repo), resource)
+		assert.NoError(t, err)
+		assert.False(t, newReader)
+
+		stored := readTerraform(t, dynamicClient, "v1alpha2")
+		sourceRef, _, _ := unstructured.NestedStringMap(stored.Object, "spec", "sourceRef")
+		assert.Equal(t, map[string]string{"kind": "GitRepository", "name": repo.GetName(), "namespace": "checkout"}, sourceRef)
+	})
+
+	t.Run("We should share a GitRepository between the Resources reading the same repo and branch", func(t *testing.T) {
+		provisioner, dynamicClient := newProvisioner(t, newMapper(defaultTerraformVersion), `{"git":{"repo":"https://github.com/nubank/modules","branch":"main","dir":"bucket"}}`)
+		other, _ := newProvisioner(t, newMapper(defaultTerraformVersion), `{"git":{"repo":"https://github.com/nubank/modules","branch":"main","dir":"queue"}}`)
+		other.dynamicClient = dynamicClient
+		otherBranch, _ := newProvisioner(t, newMapper(defaultTerraformVersion), `{"git":{"repo":"https://github.com/nubank/modules","branch":"next"}}`)
+		otherBranch.dynamicClient = dynamicClient
+
+		repo, err := provisioner.getOrNewRepo(ctx, newResource(`{"name":"orders"}`), false)
+		assert.NoError(t, err)
+
+		shared, err := other.getOrNewRepo(ctx, newResource(`{"name":"payments"}`), false)
+		assert.NoError(t, err)
+		assert.Equal(t, repo.GetName(), shared.GetName())
+
+		notShared, err := otherBranch.getOrNewRepo(ctx, newResource(`{"name":"payments"}`), false)
+		assert.NoError(t, err)
+		assert.NotEqual(t, repo.GetName(), notShared.GetName())
+	})
+
+	t.Run("We should keep shared GitRepositories in the namespace of the properties", func(t *testing.T) {
+		provisioner, dynamicClient := newProvisioner(t, newMapper(defaultTerraformVersion), `{"git":{"repo":"https://github.com/nubank/modules","namespace":"flux-system"}}`)
+		resource := newResource(`{"name":"orders"}`)
+
+		repo, err := provisioner.getOrNewRepo(ctx, resource, false)
+		assert.NoError(t, err)
+		assert.Equal(t, "flux-system", repo.GetNamespace())
+
+		_, _, err = provisioner.getOrNewTerraform(ctx, client.ObjectKeyFromObject(repo), resource)
+		assert.NoError(t, err)
+
+		namespace, _, _ := unstructured.NestedString(readTerraform(t, dynamicClient, "v1alpha2").Object, "spec", "sourceRef", "namespace")
+		assert.Equal(t, "flux-system", namespace)
+
+		preview, err := provisioner.Preview(ctx, resource)
+		assert.NoError(t, err)
+		name, _, _ := unstructured.NestedString(preview.Object, "spec", "sourceRef", "name")
+		assert.Equal(t, repo.GetName(), name)
+	})
+
+	t.Run("We should read the module from an OCIRepository", func(t *testing.T) {
+		provisioner, dynamicClient := newProvisioner(t, newMapper(defaultTerraformVersion), `{"oci":{"url":"oci://ghcr.io/nubank/modules","tag":"v1.2.0","provider":"aws","dir":"bucket"}}`)
+		resource := newResource(`{"name":"orders"}`)
+
+		repo, err := provisioner.getOrNewRepo(ctx, resource, false)
+		assert.NoError(t, err)
+		assert.Equal(t, "OCIRepository", repo.GetKind())
+
+		spec, _, _ := unstructured.NestedMap(repo.Object, "spec")
+		assert.Equal(t, map[string]any{
+			"url":      "oci://ghcr.io/nubank/modules",
+			"interval": "1m",
+			"ref":      map[string]any{"tag": "v1.2.0"},
+			"provider": "aws",
+		}, spec)
+
+		_, _, err = provisioner.getOrNewTerraform(ctx, client.ObjectKeyFromObject(repo), resource)
+		assert.NoError(t, err)
+
+		stored := readTerraform(t, dynamicClient, "v1alpha2")
+		sourceRef, _, _ := unstructured.NestedStringMap(stored.Object, "spec", "sourceRef")
+		assert.Equal(t, map[string]string{"kind": "OCIRepository", "name": repo.GetName(), "namespace": "checkout"}, sourceRef)
+		path, _, _ := unstructured.NestedString(stored.Object, "spec", "path")
+		assert.Equal(t, "bucket", path)
+
+		// Buckets aren't installed in this cluster, so they are skipped
+		assert.NoError(t, provisioner.releaseRepos(ctx, "checkout"))
+		_, err = dynamicClient.Resource(ociRepositoryGvk.GroupVersion().WithResource("ocirepositories")).Namespace("checkout").Get(ctx, repo.GetName(), metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("We should delete the GitRepositories no Terraform object reads anymore", func(t *testing.T) {
+		provisioner, dynamicClient := newProvisioner(t, newMapper(defaultTerraformVersion), `{"git":{"repo":"https://github.com/nubank/modules"}}`)
+		resource := newResource(`{"name":"orders"}`)
+
+		repo, err := provisioner.getOrNewRepo(ctx, resource, false)
+		assert.NoError(t, err)
+		_, _, err = provisioner.getOrNewTerraform(ctx, client.ObjectKeyFromObject(repo), resource)
+		assert.NoError(t, err)
+
+		repos := dynamicClient.Resource(gitRepositoryGvk.GroupVersion().WithResource("gitrepositories")).Namespace("checkout")
+
+		// still read by the Terraform object
+		assert.NoError(t, provisioner.releaseRepos(ctx, "checkout"))
+		_, err = repos.Get(ctx, repo.GetName(), metav1.GetOptions{})
+		assert.NoError(t, err)
+
+		assert.NoError(t, dynamicClient.
+			Resource(schema.GroupVersionResource{Group: terraformGroup, Version: "v1alpha2", Resource: "terraforms"}).
+			Namespace("checkout").
+			Delete(ctx, "orders-bucket", metav1.DeleteOptions{}))
+
+		assert.NoError(t, provisioner.releaseRepos(ctx, "checkout"))
+		_, err = repos.Get(ctx, repo.GetName(), metav1.GetOptions{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("We should keep the GitRepositories read by Terraform objects written by older releases, without the source label", func(t *testing.T) {
+		provisioner, dynamicClient := newProvisioner(t, newMapper(defaultTerraformVersion), `{"git":{"repo":"https://github.com/nubank/modules"}}`)
+		resource := newResource(`{"name":"orders"}`)
+
+		repo, err := provisioner.getOrNewRepo(ctx, resource, false)
+		assert.NoError(t, err)
+		_, _, err = provisioner.getOrNewTerraform(ctx, client.ObjectKeyFromObject(repo), resource)
+		assert.NoError(t, err)
+
+		terraforms := dynamicClient.Resource(schema.GroupVersionResource{Group: terraformGroup, Version: "v1alpha2", Resource: "terraforms"}).Namespace("checkout")
+		legacy := readTerraform(t, dynamicClient, "v1alpha2")
+		labels := legacy.GetLabels()
+		delete(labels, openTofuSourceLabel)
+		legacy.SetLabels(labels)
+		_, err = terraforms.Update(ctx, legacy, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+
+		assert.NoError(t, provisioner.releaseRepos(ctx, "checkout"))
+		_, err = dynamicClient.Resource(gitRepositoryGvk.GroupVersion().WithResource("gitrepositories")).Namespace("checkout").Get(ctx, repo.GetName(), metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("We should mark the GitRepository read by a new Terraform object, or create it again when it was released meanwhile", func(t *testing.T) {
+		provisioner, dynamicClient := newProvisioner(t, newMapper(defaultTerraformVersion), `{"git":{"repo":"https://github.com/nubank/modules"}}`)
+		resource := newResource(`{"name":"orders"}`)
+
+		repo, err := provisioner.getOrNewRepo(ctx, resource, false)
+		assert.NoError(t, err)
+		assert.NotContains(t, repo.GetAnnotations(), openTofuNewReaderAnnotation)
+
+		marked, err := provisioner.getOrNewRepo(ctx, resource, true)
+		assert.NoError(t, err)
+		assert.Contains(t, marked.GetAnnotations(), openTofuNewReaderAnnotation)
+
+		repos := dynamicClient.Resource(gitRepositoryGvk.GroupVersion().WithResource("gitrepositories")).Namespace("checkout")
+		assert.NoError(t, repos.Delete(ctx, repo.GetName(), metav1.DeleteOptions{}))
+
+		_, err = provisioner.getOrNewRepo(ctx, resource, true)
+		assert.NoError(t, err)
+		_, err = repos.Get(ctx, repo.GetName(), metav1.GetOptions{})
+		assert.NoError(t, err)
+	})
+
+	t.Run("With the Orphan policy, we should release the Terraform object, its source and its outputs Secret", func(t *testing.T) {
+		mapper := newMapper(defaultTerraformVersion)
+		mapper.(*meta.DefaultRESTMapper).Add(corev1.SchemeGroupVersion.WithKind("Secret"), meta.RESTScopeNamespace)
+
+		provisioner, _ := newProvisioner(t, mapper, `{"git":{"repo":"https://github.com/nubank/modules"}}`)
+		resource := newResource(`{"name":"orders"}`)
+		resource.UID = "orders-bucket-uid"
+		resource.Spec.DeletionPolicy = resourcesv1alpha1.DeletionPolicyOrphan
+
+		owners := []metav1.OwnerReference{
+			{APIVersion: resourcesv1alpha1.GroupVersion.String(), Kind: "Resource", Name: resource.Name, UID: resource.UID},
+			{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "other-uid"},
+		}
+		newObject := func(gvk schema.GroupVersionKind, name string, spec map[string]any) *unstructured.Unstructured {
+			obj := &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+			obj.SetGroupVersionKind(gvk)
+			obj.SetNamespace("checkout")
+			obj.SetName(name)
+			obj.SetOwnerReferences(owners)
+			return obj
+		}
+
+		terraformGvk := schema.GroupVersionKind{Group: terraformGroup, Version: defaultTerraformVersion, Kind: "Terraform"}
+		secretGvk := corev1.SchemeGroupVersion.WithKind("Secret")
+
+		for _, obj := range []*unstructured.Unstructured{
+			newObject(terraformGvk, "orders-bucket", map[string]any{
+				"sourceRef":            map[string]any{"kind": "GitRepository", "name": "orders-bucket"},
+				"writeOutputsToSecret": map[string]any{"name": "orders-bucket-outputs"},
+			}),
+			newObject(gitRepositoryGvk, "orders-bucket", map[string]any{}),
+			newObject(secretGvk, "orders-bucket-outputs", nil),
+		} {
+			assert.NoError(t, provisioner.client.Create(ctx, obj))
+		}
+
+		status, err := provisioner.Destroy(ctx, resource)
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceSuccessState, status.State)
+
+		for _, gvk := range []schema.GroupVersionKind{terraformGvk, gitRepositoryGvk, secretGvk} {
+			name := "orders-bucket"
+			if gvk == secretGvk {
+				name = "orders-bucket-outputs"
+			}
+
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(gvk)
+			assert.NoError(t, provisioner.client.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: name}, obj))
+			assert.Equal(t, owners[1:], obj.GetOwnerReferences(), gvk.Kind)
+		}
+	})
+
+	t.Run("We should update the spec of an existing Terraform object", func(t *testing.T) {
+		provisioner, dynamicClient := newProvisioner(t, newMapper(defaultTerraformVersion), `{"git":{"repo":"https://github.com/nubank/modules"}}`)
+
+		_, _, err := provisioner.getOrNewTerraform(ctx, types.NamespacedName{Namespace: "checkout", Name: "bucket"}, newResource(`{"name":"orders"}`))
+		assert.NoError(t, err)
+
+		_, _, err = provisioner.getOrNewTerraform(ctx, types.NamespacedName{Namespace: "checkout", Name: "bucket"}, newResource(`{"name":"payments"}`))
+		assert.NoError(t, err)
+
+		vars, _, _ := unstructured.NestedSlice(readTerraform(t, dynamicClient, "v1alpha2").Object, "spec", "vars")
+		assert.Equal(t, []any{map[string]any{"name": "name", "value": "payments"}}, vars)
+	})
+
+	t.Run("We should write Terraform objects in the configured tf-controller API version", func(t *testing.T) {
+		provisioner, dynamicClient := newProvisioner(t, newMapper("v1alpha1", defaultTerraformVersion), `{"git":{"repo":"https://github.com/nubank/modules"},"terraformVersion":"v1alpha1"}`)
+
+		terraform, _, err := provisioner.getOrNewTerraform(ctx, types.NamespacedName{Namespace: "checkout", Name: "bucket"}, newResource(`{"name":"orders"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, "infra.contrib.fluxcd.io/v1alpha1", terraform.GetAPIVersion())
+
+		assert.Equal(t, "v1alpha1", readTerraform(t, dynamicClient, "v1alpha1").GroupVersionKind().Version)
+
+		preview, err := provisioner.Preview(ctx, newResource(`{"name":"orders"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, "infra.contrib.fluxcd.io/v1alpha1", preview.GetAPIVersion())
+	})
+
+	t.Run("Without the tf-controller CRDs, we should fail to resolve the Terraform resource", func(t *testing.T) {
+		provisioner, _ := newProvisioner(t, newMapper(), `{"git":{"repo":"https://github.com/nubank/modules"}}`)
+
+		_, _, err := provisioner.getOrNewTerraform(ctx, types.NamespacedName{Namespace: "checkout", Name: "bucket"}, newResource(`{"name":"orders"}`))
+		assert.ErrorContains(t, err, "unable to find the resource of infra.contrib.fluxcd.io/v1alpha2, Kind=Terraform")
+	})
+}
@@ -0,0 +1,58 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"fmt"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// objectOverrides are the spec overrides declared by a ResourceRef, by kind of generated object
+type objectOverrides map[string]map[string]any
+
+func newObjectOverrides(provisioner *resourcesv1alpha1.ResourceRefProvisioner) (objectOverrides, error) {
+	overrides := make(objectOverrides)
+	for _, override := range provisioner.Overrides {
+		spec := make(map[string]any)
+		if override.Spec != nil && len(override.Spec.Raw) != 0 {
+			if err := json.Unmarshal(override.Spec.Raw, &spec); err != nil {
+				return nil, fmt.Errorf("invalid overrides to %s: %w", override.Kind, err)
+			}
+		}
+
+		if current, ok := overrides[override.Kind]; ok {
+			mergeSpec(current, spec)
+			continue
+		}
+		overrides[override.Kind] = spec
+	}
+	return overrides, nil
+}
+
+// apply merges the overrides to the kind into a generated spec
+func (o objectOverrides) apply(kind string, spec map[string]any) map[string]any {
+	if patch, ok := o[kind]; ok {
+		mergeSpec(spec, runtime.DeepCopyJSON(patch))
+	}
+	return spec
+}
+
+// mergeSpec follows the JSON merge patch semantics; CRDs don't declare patch strategies, so lists are replaced
+func mergeSpec(target map[string]any, patch map[string]any) {
+	for name, value := range patch {
+		if value == nil {
+			delete(target, name)
+			continue
+		}
+
+		if patchObject, ok := value.(map[string]any); ok {
+			if targetObject, ok := target[name].(map[string]any); ok {
+				mergeSpec(targetObject, patchObject)
+				continue
+			}
+		}
+
+		target[name] = value
+	}
+}
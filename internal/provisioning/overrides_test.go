@@ -0,0 +1,67 @@
+package provisioning
+
+import (
+	"testing"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_ObjectOverrides(t *testing.T) {
+	provisioner := &resourcesv1alpha1.ResourceRefProvisioner{
+		Name: OpenTofuProvisionerName,
+		Overrides: []resourcesv1alpha1.ResourceRefProvisionerOverride{
+			{Kind: "Terraform", Spec: &runtime.RawExtension{Raw: []byte(`{"parallelism":4,"writeOutputsToSecret":{"labels":{"team":"payments"}},"enableInventory":null}`)}},
+			{Kind: "Terraform", Spec: &runtime.RawExtension{Raw: []byte(`{"cliConfigSecretRef":{"name":"tofu-cli"}}`)}},
+			{Kind: "GitRepository", Spec: &runtime.RawExtension{Raw: []byte(`{"ignore":"docs/"}`)}},
+		},
+	}
+
+	overrides, err := newObjectOverrides(provisioner)
+	assert.NoError(t, err)
+
+	t.Run("We should merge the overrides into the generated spec", func(t *testing.T) {
+		spec := overrides.apply("Terraform", map[string]any{
+			"path":            "modules/rds",
+			"enableInventory": true,
+			"writeOutputsToSecret": map[string]any{
+				"name": "database-outputs",
+			},
+		})
+
+		assert.Equal(t, map[string]any{
+			"path":        "modules/rds",
+			"parallelism": float64(4),
+			"writeOutputsToSecret": map[string]any{
+				"name":   "database-outputs",
+				"labels": map[string]any{"team": "payments"},
+			},
+			"cliConfigSecretRef": map[string]any{"name": "tofu-cli"},
+		}, spec)
+	})
+
+	t.Run("Overrides should only be applied to their kind", func(t *testing.T) {
+		spec := overrides.apply("Stack", map[string]any{"stack": "prod.database"})
+
+		assert.Equal(t, map[string]any{"stack": "prod.database"}, spec)
+	})
+
+	t.Run("Overrides should be kept intact between objects", func(t *testing.T) {
+		first := overrides.apply("Terraform", map[string]any{})
+		first["writeOutputsToSecret"].(map[string]any)["labels"].(map[string]any)["team"] = "checkout"
+
+		second := overrides.apply("Terraform", map[string]any{})
+		assert.Equal(t, "payments", second["writeOutputsToSecret"].(map[string]any)["labels"].(map[string]any)["team"])
+	})
+
+	t.Run("We should reject overrides that aren't objects", func(t *testing.T) {
+		_, err := newObjectOverrides(&resourcesv1alpha1.ResourceRefProvisioner{
+			Overrides: []resourcesv1alpha1.ResourceRefProvisionerOverride{
+				{Kind: "Stack", Spec: &runtime.RawExtension{Raw: []byte(`[]`)}},
+			},
+		})
+
+		assert.ErrorContains(t, err, "invalid overrides to Stack")
+	})
+}
@@ -0,0 +1,242 @@
+package provisioning
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/pkg/plugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// builtinProvisioners can't be replaced by plugins
+var builtinProvisioners = []string{
+	PulumiProvisionerName,
+	OpenTofuProvisionerName,
+	CrossplaneProvisionerName,
+	HelmProvisionerName,
+	NoopProvisionerName,
+	HttpProvisionerName,
+}
+
+const defaultPluginTimeout = 30 * time.Second
+
+type registeredPlugin struct {
+	endpoint string
+	timeout  time.Duration
+	revision string
+	client   *plugin.Client
+}
+
+// PluginTransport secures the connection to a plugin. Revision identifies the credentials in TLS, so the plugin is
+// connected again when they change.
+type PluginTransport struct {
+	TLS      *tls.Config
+	Revision string
+}
+
+type pluginRegistry struct {
+	sync.RWMutex
+	all map[string]*registeredPlugin
+}
+
+var plugins = &pluginRegistry{all: make(map[string]*registeredPlugin)}
+
+// RegisterPlugin makes an out-of-process provisioner available to SelectByName; without a transport, the plugin is
+// called over plaintext. Registering the same name again replaces the previous endpoint.
+func RegisterPlugin(name string, endpoint string, timeout time.Duration, transport *PluginTransport) error {
+	if slices.Contains(builtinProvisioners, name) {
+		return fmt.Errorf("provisioner %s is built-in and can't be replaced by a plugin", name)
+	}
+
+	if timeout <= 0 {
+		timeout = defaultPluginTimeout
+	}
+
+	var revision string
+	var opts []grpc.DialOption
+	if transport != nil {
+		revision = transport.Revision
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(transport.TLS)))
+	}
+
+	plugins.Lock()
+	defer plugins.Unlock()
+
+	// provisioners already built keep a reference to the registered plugin, so it's replaced instead of changed
+	if current, ok := plugins.all[name]; ok {
+		if current.endpoint == endpoint && current.revision == revision {
+			plugins.all[name] = &registeredPlugin{endpoint: endpoint, timeout: timeout, revision: revision, client: current.client}
+			return nil
+		}
+		current.client.Close()
+		delete(plugins.all, name)
+	}
+
+	c, err := plugin.NewClient(endpoint, opts...)
+	if err != nil {
+		return fmt.Errorf("unable to create a client to plugin %s at %s: %w", name, endpoint, err)
+	}
+
+	plugins.all[name] = &registeredPlugin{endpoint: endpoint, timeout: timeout, revision: revision, client: c}
+
+	return nil
+}
+
+// NewPluginTLS builds the TLS configuration to call a plugin from PEM encoded certificates; the client certificate
+// and key are only needed when the plugin requires mutual TLS.
+func NewPluginTLS(ca []byte, cert []byte, key []byte, serverName string) (*tls.Config, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no valid CA certificate was found")
+	}
+
+	config := &tls.Config{
+		RootCAs:    pool,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if len(cert) > 0 || len(key) > 0 {
+		certificate, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+// UnregisterPlugin removes a plugin previously registered with RegisterPlugin
+func UnregisterPlugin(name string) {
+	plugins.Lock()
+	defer plugins.Unlock()
+
+	if current, ok := plugins.all[name]; ok {
+		current.client.Close()
+		delete(plugins.all, name)
+	}
+}
+
+func (r *pluginRegistry) lookup(name string) (*registeredPlugin, bool) {
+	r.RLock()
+	defer r.RUnlock()
+
+	p, ok := r.all[name]
+	return p, ok
+}
+
+// PluginProvisioner delegates provisioning to a plugin through gRPC
+type PluginProvisioner struct {
+	name       string
+	plugin     *registeredPlugin
+	log        logr.Logger
+	properties *runtime.RawExtension
+}
+
+func newPluginProvisioner(name string, p *registeredPlugin) ProvisionerFactory {
+	return func(c client.Client, d *dynamic.DynamicClient, scheme *runtime.Scheme, log logr.Logger, provisioner *resourcesv1alpha1.ResourceRefProvisioner) (Provisioner, error) {
+		return &PluginProvisioner{
+			name:       name,
+			plugin:     p,
+			log:        log,
+			properties: provisioner.Properties,
+		}, nil
+	}
+}
+
+func (provisioner *PluginProvisioner) Run(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
+	provisioner.log.Info(fmt.Sprintf("calling plugin %s at %s to resource %s/%s...", provisioner.name, provisioner.plugin.endpoint, resource.Namespace, resource.Name))
+
+	ctx, cancel := context.WithTimeout(ctx, provisioner.plugin.timeout)
+	defer cancel()
+
+	response, err := provisioner.plugin.client.Run(ctx, &plugin.RunRequest{
+		Resource:   resource,
+		Properties: provisioner.properties,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s failed: %w", provisioner.name, err)
+	}
+
+	return pluginResponseToStatus(response)
+}
+
+func (provisioner *PluginProvisioner) Destroy(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
+	provisioner.log.Info(fmt.Sprintf("calling plugin %s at %s to destroy resource %s/%s...", provisioner.name, provisioner.plugin.endpoint, resource.Namespace, resource.Name))
+
+	ctx, cancel := context.WithTimeout(ctx, provisioner.plugin.timeout)
+	defer cancel()
+
+	response, err := provisioner.plugin.client.Destroy(ctx, &plugin.RunRequest{
+		Resource:   resource,
+		Properties: provisioner.properties,
+	})
+	if status.Code(err) == codes.Unimplemented {
+		// the plugin isn't a plugin.Destroyer, so there is nothing it can tear down
+		provisioner.log.Info(fmt.Sprintf("plugin %s doesn't destroy resources; releasing resource %s/%s...", provisioner.name, resource.Namespace, resource.Name))
+		return &ProvisionedResourceStatus{State: ProvisionedResourceSuccessState}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s failed to destroy: %w", provisioner.name, err)
+	}
+
+	return pluginResponseToStatus(response)
+}
+
+func pluginResponseToStatus(response *plugin.RunResponse) (*ProvisionedResourceStatus, error) {
+	status := &ProvisionedResourceStatus{
+		Outputs: response.Outputs,
+		Drifted: response.Drifted,
+	}
+
+	switch response.State {
+	case plugin.RunningState:
+		status.State = ProvisionedResourceRunningState
+	case plugin.SuccessState:
+		status.State = ProvisionedResourceSuccessState
+	case plugin.FailedState:
+		status.State = ProvisionedResourceFailedState
+	default:
+		return nil, fmt.Errorf("unknown state returned by plugin: %s", response.State)
+	}
+
+	if response.Resource != nil {
+		status.Resource = &ProvisionedResource{
+			GroupVersionKind: schema.GroupVersionKind{
+				Group:   response.Resource.Group,
+				Version: response.Resource.Version,
+				Kind:    response.Resource.Kind,
+			},
+			Name: response.Resource.Name,
+		}
+	}
+
+	if response.Inventory != nil {
+		status.Inventory = make([]ProvisionedInventoryEntry, 0, len(response.Inventory))
+		for _, entry := range response.Inventory {
+			status.Inventory = append(status.Inventory, ProvisionedInventoryEntry{
+				Type: entry.Type,
+				Name: entry.Name,
+				ID:   entry.ID,
+			})
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,255 @@
+package provisioning
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type echoPlugin struct{}
+
+func (echoPlugin) Run(ctx context.Context, request *plugin.RunRequest) (*plugin.RunResponse, error) {
+	properties := make(map[string]any)
+	if err := json.Unmarshal(request.Properties.Raw, &properties); err != nil {
+		return nil, err
+	}
+	return &plugin.RunResponse{
+		State: plugin.SuccessState,
+		Resource: &plugin.ProvisionedResource{
+			Kind: "Echo",
+			Name: request.Resource.Name,
+		},
+		Outputs: properties,
+		Inventory: []plugin.InventoryEntry{
+			{Type: "echo", Name: request.Resource.Name, ID: "1"},
+		},
+	}, nil
+}
+
+func (echoPlugin) Destroy(ctx context.Context, request *plugin.RunRequest) (*plugin.RunResponse, error) {
+	return &plugin.RunResponse{State: plugin.SuccessState}, nil
+}
+
+// runOnlyPlugin is not a plugin.Destroyer
+type runOnlyPlugin struct{}
+
+func (runOnlyPlugin) Run(ctx context.Context, request *plugin.RunRequest) (*plugin.RunResponse, error) {
+	return &plugin.RunResponse{State: plugin.SuccessState}, nil
+}
+
+func Test_PluginProvisioner(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	go plugin.Serve(listener, echoPlugin{})
+
+	t.Run("We should be able to run a provisioner registered as a plugin", func(t *testing.T) {
+		err := RegisterPlugin("echo", listener.Addr().String(), time.Duration(5)*time.Second, nil)
+		assert.NoError(t, err)
+		defer UnregisterPlugin("echo")
+
+		factory, err := SelectByName("echo")
+		assert.NoError(t, err)
+
+		provisioner, err := factory(nil, nil, runtime.NewScheme(), logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       "echo",
+			Properties: &runtime.RawExtension{Raw: []byte(`{"message":"hello"}`)},
+		})
+		assert.NoError(t, err)
+		assert.IsType(t, &PluginProvisioner{}, provisioner)
+
+		status, err := provisioner.Run(context.TODO(), &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "sample", Name: "my-resource"},
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, ProvisionedResourceSuccessState, status.State)
+		assert.Equal(t, "hello", status.Outputs["message"])
+		assert.Equal(t, "Echo", status.Resource.Kind)
+		assert.Equal(t, "my-resource", status.Resource.Name)
+		assert.Equal(t, []ProvisionedInventoryEntry{{Type: "echo", Name: "my-resource", ID: "1"}}, status.Inventory)
+
+		status, err = provisioner.Destroy(context.TODO(), &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "sample", Name: "my-resource"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceSuccessState, status.State)
+	})
+
+	t.Run("We should release a resource when the plugin doesn't destroy anything", func(t *testing.T) {
+		runOnlyListener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		go plugin.Serve(runOnlyListener, runOnlyPlugin{})
+
+		err = RegisterPlugin("run-only", runOnlyListener.Addr().String(), time.Duration(5)*time.Second, nil)
+		assert.NoError(t, err)
+		defer UnregisterPlugin("run-only")
+
+		factory, err := SelectByName("run-only")
+		assert.NoError(t, err)
+
+		provisioner, err := factory(nil, nil, runtime.NewScheme(), logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{Name: "run-only"})
+		assert.NoError(t, err)
+
+		status, err := provisioner.Destroy(context.TODO(), &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "sample", Name: "my-resource"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, ProvisionedResourceSuccessState, status.State)
+	})
+
+	t.Run("We should not be able to select a plugin after it is unregistered", func(t *testing.T) {
+		err := RegisterPlugin("echo", listener.Addr().String(), 0, nil)
+		assert.NoError(t, err)
+
+		UnregisterPlugin("echo")
+
+		factory, err := SelectByName("echo")
+		assert.Error(t, err)
+		assert.Nil(t, factory)
+	})
+
+	t.Run("We should not be able to replace a built-in provisioner with a plugin", func(t *testing.T) {
+		err := RegisterPlugin(NoopProvisionerName, listener.Addr().String(), 0, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("We should keep the same connection when only the timeout of a plugin changes", func(t *testing.T) {
+		err := RegisterPlugin("echo", listener.Addr().String(), time.Duration(5)*time.Second, nil)
+		assert.NoError(t, err)
+		defer UnregisterPlugin("echo")
+
+		registered, _ := plugins.lookup("echo")
+
+		err = RegisterPlugin("echo", listener.Addr().String(), time.Duration(10)*time.Second, nil)
+		assert.NoError(t, err)
+
+		updated, _ := plugins.lookup("echo")
+		assert.Same(t, registered.client, updated.client)
+		assert.Equal(t, time.Duration(5)*time.Second, registered.timeout)
+		assert.Equal(t, time.Duration(10)*time.Second, updated.timeout)
+	})
+}
+
+func Test_PluginProvisionerWithTLS(t *testing.T) {
+	ca, caKey, caPEM, _ := newCertificate(t, nil, nil)
+	_, _, serverPEM, serverKeyPEM := newCertificate(t, ca, caKey)
+
+	serverCertificate, err := tls.X509KeyPair(serverPEM, serverKeyPEM)
+	assert.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	go plugin.Serve(listener, echoPlugin{}, grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{serverCertificate}})))
+
+	t.Run("We should be able to call a plugin over TLS", func(t *testing.T) {
+		config, err := NewPluginTLS(caPEM, nil, nil, "echo.klaudio.svc")
+		assert.NoError(t, err)
+
+		err = RegisterPlugin("echo-tls", listener.Addr().String(), time.Duration(5)*time.Second, &PluginTransport{TLS: config, Revision: "1"})
+		assert.NoError(t, err)
+		defer UnregisterPlugin("echo-tls")
+
+		factory, err := SelectByName("echo-tls")
+		assert.NoError(t, err)
+
+		provisioner, err := factory(nil, nil, runtime.NewScheme(), logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       "echo-tls",
+			Properties: &runtime.RawExtension{Raw: []byte(`{"message":"hello"}`)},
+		})
+		assert.NoError(t, err)
+
+		status, err := provisioner.Run(context.TODO(), &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "sample", Name: "my-resource"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", status.Outputs["message"])
+	})
+
+	t.Run("We should not be able to call a plugin whose certificate is not trusted", func(t *testing.T) {
+		_, _, otherCAPEM, _ := newCertificate(t, nil, nil)
+
+		config, err := NewPluginTLS(otherCAPEM, nil, nil, "echo.klaudio.svc")
+		assert.NoError(t, err)
+
+		err = RegisterPlugin("echo-tls", listener.Addr().String(), time.Duration(5)*time.Second, &PluginTransport{TLS: config, Revision: "2"})
+		assert.NoError(t, err)
+		defer UnregisterPlugin("echo-tls")
+
+		factory, err := SelectByName("echo-tls")
+		assert.NoError(t, err)
+
+		provisioner, err := factory(nil, nil, runtime.NewScheme(), logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       "echo-tls",
+			Properties: &runtime.RawExtension{Raw: []byte(`{"message":"hello"}`)},
+		})
+		assert.NoError(t, err)
+
+		_, err = provisioner.Run(context.TODO(), &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "sample", Name: "my-resource"},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("We should not be able to build a TLS configuration without a CA", func(t *testing.T) {
+		_, err := NewPluginTLS([]byte("not a certificate"), nil, nil, "")
+		assert.Error(t, err)
+	})
+}
+
+// newCertificate creates a self-signed CA certificate when parent is nil, or a certificate signed by parent otherwise;
+// both the certificate and its key are returned PEM encoded too
+func newCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "echo.klaudio.svc"},
+		DNSNames:     []string{"echo.klaudio.svc"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	return certificate, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
@@ -1,23 +1,101 @@
 package provisioning
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/go-logr/logr"
 	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ErrUnsupportedProvisioner is returned by SelectByName for names that are neither built-in nor a registered plugin
+var ErrUnsupportedProvisioner = errors.New("unsupported provisioner")
+
 type Provisioner interface {
 	Run(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error)
+	// Destroy tears down the infrastructure provisioned to the resource; a running state means the destruction
+	// is still in progress, and Destroy will be called again.
+	Destroy(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error)
+}
+
+// Previewer is implemented by provisioners able to render the object they would apply to a resource, without touching
+// the cluster; deployments in Plan mode publish it.
+type Previewer interface {
+	Preview(ctx context.Context, resource *resourcesv1alpha1.Resource) (*unstructured.Unstructured, error)
+}
+
+// SecretConsumer is implemented by provisioners whose objects read Secrets by themselves, like the varsFrom of a
+// Terraform object; the infrastructure only picks up a rotation of them when the provisioner object runs again.
+type SecretConsumer interface {
+	ConsumedSecrets(resource *resourcesv1alpha1.Resource) ([]types.NamespacedName, error)
+}
+
+// ReconcileRequestAnnotations ask the controllers of the provisioner objects to run them again, even when their specs
+// didn't change; like other annotations of the Resource, they're copied to its provisioner object
+var ReconcileRequestAnnotations = []string{"reconcile.fluxcd.io/requestedAt", "pulumi.com/reconciliation-request"}
+
+// handledReconcileRequestOf reads the last of ReconcileRequestAnnotations handled by the controller of a provisioner
+// object: Flux controllers report it as lastHandledReconcileAt, and the Pulumi operator as observedReconcileRequest
+func handledReconcileRequestOf(obj *unstructured.Unstructured) string {
+	if handled, _, _ := unstructured.NestedString(obj.Object, "status", "lastHandledReconcileAt"); handled != "" {
+		return handled
+	}
+	handled, _, _ := unstructured.NestedString(obj.Object, "status", "observedReconcileRequest")
+	return handled
 }
 
+// secretsReadBy collects the Secrets referenced by the spec of a provisioner object through entries of the given
+// fields, like varsFrom: [{kind: Secret, name: credentials}]; namespaces default to the one of the object
+func secretsReadBy(spec map[string]any, namespace string, fields ...string) []types.NamespacedName {
+	secrets := make([]types.NamespacedName, 0)
+	for _, field := range fields {
+		entries, _ := spec[field].([]any)
+		for _, entry := range entries {
+			ref, _ := entry.(map[string]any)
+			if kind, _ := ref["kind"].(string); kind != "Secret" {
+				continue
+			}
+			name, _ := ref["name"].(string)
+			if name == "" {
+				continue
+			}
+			refNamespace, _ := ref["namespace"].(string)
+			secrets = append(secrets, types.NamespacedName{Namespace: cmp.Or(refNamespace, namespace), Name: name})
+		}
+	}
+	return secrets
+}
+
+// ControlledKinds are the kinds of the objects created by provisioners and controlled by the Resource; changes on them
+// can be watched instead of polled
+var ControlledKinds = []schema.GroupVersionKind{
+	{Group: terraformGroup, Version: defaultTerraformVersion, Kind: "Terraform"},
+	{Group: "pulumi.com", Version: "v1", Kind: "Stack"},
+	{Group: "helm.toolkit.fluxcd.io", Version: "v2", Kind: "HelmRelease"},
+}
+
+// ControlledObjectsSelector matches the objects of ControlledKinds created by provisioners, so their informers don't
+// keep every Terraform, Stack or HelmRelease of the cluster
+var ControlledObjectsSelector = labels.SelectorFromSet(labels.Set{
+	resourcesv1alpha1.Group + "/managedBy.kind": "Resource",
+})
+
 type ProvisionerFactory func(client.Client, *dynamic.DynamicClient, *runtime.Scheme, logr.Logger, *resourcesv1alpha1.ResourceRefProvisioner) (Provisioner, error)
 
 func SelectByName(name string) (ProvisionerFactory, error) {
+	if IsProvisionerDisabled(name) {
+		return nil, disabledProvisionerError(name)
+	}
+
 	switch name {
 	case PulumiProvisionerName:
 		return newPulumiProvisioner, nil
@@ -25,9 +103,18 @@ func SelectByName(name string) (ProvisionerFactory, error) {
 		return newOpenTofuProvisioner, nil
 	case CrossplaneProvisionerName:
 		return newCrossplaneProvisioner, nil
+	case HelmProvisionerName:
+		return newHelmProvisioner, nil
+	case NoopProvisionerName:
+		return newNoopProvisioner, nil
+	case HttpProvisionerName:
+		return newHttpProvisioner, nil
 
 	default:
-		return nil, fmt.Errorf("unsupported provisioner: %s", name)
+		if p, ok := plugins.lookup(name); ok {
+			return newPluginProvisioner(name, p), nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedProvisioner, name)
 	}
 
 }
@@ -0,0 +1,172 @@
+package provisioning
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_SelectByName(t *testing.T) {
+
+	t.Run("We should be able to select the pulumi provisioner", func(t *testing.T) {
+		factory, err := SelectByName(resourcesv1alpha1.ResourceRefPulumiProvisioner)
+		assert.NoError(t, err)
+
+		provisioner, err := factory(nil, nil, runtime.NewScheme(), logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       resourcesv1alpha1.ResourceRefPulumiProvisioner,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"git":{"repo":"https://github.com/nubank/sample"}}`)},
+		})
+		assert.NoError(t, err)
+		assert.IsType(t, &PulumiProvisioner{}, provisioner)
+	})
+
+	t.Run("We should be able to select the opentofu provisioner", func(t *testing.T) {
+		factory, err := SelectByName(resourcesv1alpha1.ResourceRefOpenTofuProvisioner)
+		assert.NoError(t, err)
+
+		provisioner, err := factory(nil, nil, runtime.NewScheme(), logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       resourcesv1alpha1.ResourceRefOpenTofuProvisioner,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"git":{"repo":"https://github.com/nubank/sample"}}`)},
+		})
+		assert.NoError(t, err)
+		assert.IsType(t, &OpenTofuProvisioner{}, provisioner)
+	})
+
+	t.Run("We should be able to select the crossplane provisioner", func(t *testing.T) {
+		factory, err := SelectByName(resourcesv1alpha1.ResourceRefCrossplaneProvisioner)
+		assert.NoError(t, err)
+
+		provisioner, err := factory(nil, nil, runtime.NewScheme(), logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       resourcesv1alpha1.ResourceRefCrossplaneProvisioner,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"objectRef":{"apiVersion":"s3.aws.upbound.io/v1beta1","kind":"Bucket"}}`)},
+		})
+		assert.NoError(t, err)
+		assert.IsType(t, &CrossplaneProvisioner{}, provisioner)
+
+		crossplaneProvisioner := provisioner.(*CrossplaneProvisioner)
+		assert.Equal(t, "s3.aws.upbound.io/v1beta1", crossplaneProvisioner.properties.ObjectRef.ApiVersion)
+		assert.Equal(t, "Bucket", crossplaneProvisioner.properties.ObjectRef.Kind)
+	})
+
+	t.Run("We should be able to select the helm provisioner", func(t *testing.T) {
+		factory, err := SelectByName(resourcesv1alpha1.ResourceRefHelmProvisioner)
+		assert.NoError(t, err)
+
+		provisioner, err := factory(nil, nil, runtime.NewScheme(), logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       resourcesv1alpha1.ResourceRefHelmProvisioner,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"chart":{"repo":"https://stefanprodan.github.io/podinfo","name":"podinfo","version":"6.x"},"interval":"5m"}`)},
+		})
+		assert.NoError(t, err)
+		assert.IsType(t, &HelmProvisioner{}, provisioner)
+
+		helmProvisioner := provisioner.(*HelmProvisioner)
+		assert.Equal(t, "podinfo", helmProvisioner.properties.Chart.Name)
+		assert.Equal(t, "6.x", *helmProvisioner.properties.Chart.Version)
+	})
+
+	t.Run("We should default the helm interval when it is not set", func(t *testing.T) {
+		factory, err := SelectByName(resourcesv1alpha1.ResourceRefHelmProvisioner)
+		assert.NoError(t, err)
+
+		provisioner, err := factory(nil, nil, runtime.NewScheme(), logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       resourcesv1alpha1.ResourceRefHelmProvisioner,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"chart":{"repo":"https://stefanprodan.github.io/podinfo","name":"podinfo"}}`)},
+		})
+		assert.NoError(t, err)
+
+		helmProvisioner := provisioner.(*HelmProvisioner)
+		assert.Equal(t, helmDefaultInterval, *helmProvisioner.properties.Interval)
+	})
+
+	t.Run("We should be able to select the noop provisioner", func(t *testing.T) {
+		factory, err := SelectByName(resourcesv1alpha1.ResourceRefNoopProvisioner)
+		assert.NoError(t, err)
+
+		provisioner, err := factory(nil, nil, runtime.NewScheme(), logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       resourcesv1alpha1.ResourceRefNoopProvisioner,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"scenario":{"failOnAttempt":2,"latency":"10s"}}`)},
+		})
+		assert.NoError(t, err)
+		assert.IsType(t, &NoopProvisioner{}, provisioner)
+
+		noopProvisioner := provisioner.(*NoopProvisioner)
+		assert.Equal(t, 2, noopProvisioner.properties.Scenario.FailOnAttempt)
+		assert.Equal(t, "10s", noopProvisioner.properties.Scenario.Latency)
+	})
+
+	t.Run("We should be able to select the http provisioner", func(t *testing.T) {
+		factory, err := SelectByName(resourcesv1alpha1.ResourceRefHttpProvisioner)
+		assert.NoError(t, err)
+
+		provisioner, err := factory(nil, nil, runtime.NewScheme(), logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       resourcesv1alpha1.ResourceRefHttpProvisioner,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"url":"https://tickets.sample.org/api/requests"}`)},
+		})
+		assert.NoError(t, err)
+		assert.IsType(t, &HttpProvisioner{}, provisioner)
+
+		httpProvisioner := provisioner.(*HttpProvisioner)
+		assert.Equal(t, "https://tickets.sample.org/api/requests", httpProvisioner.properties.Url)
+		assert.Equal(t, "POST", httpProvisioner.properties.Method)
+	})
+
+	t.Run("We should not be able to select an unknown provisioner", func(t *testing.T) {
+		factory, err := SelectByName("unknown")
+		assert.Error(t, err)
+		assert.Nil(t, factory)
+	})
+
+	t.Run("We should not be able to select a disabled provisioner", func(t *testing.T) {
+		SetDisabledProvisioners(resourcesv1alpha1.ResourceRefPulumiProvisioner)
+		defer SetDisabledProvisioners()
+
+		factory, err := SelectByName(resourcesv1alpha1.ResourceRefPulumiProvisioner)
+		assert.ErrorIs(t, err, ErrProvisionerDisabled)
+		assert.Nil(t, factory)
+
+		_, err = SelectByName(resourcesv1alpha1.ResourceRefOpenTofuProvisioner)
+		assert.NoError(t, err)
+	})
+}
+
+func Test_ControlledObjectsSelector(t *testing.T) {
+
+	t.Run("We should select the objects created for a Resource", func(t *testing.T) {
+		assert.True(t, ControlledObjectsSelector.Matches(labels.Set{
+			resourcesv1alpha1.Group + "/managedBy.group": resourcesv1alpha1.GroupVersion.Group,
+			resourcesv1alpha1.Group + "/managedBy.kind":  "Resource",
+			resourcesv1alpha1.Group + "/managedBy.name":  "my-resource",
+		}))
+	})
+
+	t.Run("We should skip objects not created by klaudio", func(t *testing.T) {
+		assert.False(t, ControlledObjectsSelector.Matches(labels.Set{"app": "something"}))
+		assert.False(t, ControlledObjectsSelector.Matches(labels.Set{resourcesv1alpha1.Group + "/managedBy.kind": "ResourceRef"}))
+	})
+}
+
+func Test_HandledReconcileRequestOf(t *testing.T) {
+
+	t.Run("We should read the request handled by a Flux controller", func(t *testing.T) {
+		terraform := &unstructured.Unstructured{Object: map[string]any{
+			"status": map[string]any{"lastHandledReconcileAt": "2024-05-01T10:00:00Z"},
+		}}
+		assert.Equal(t, "2024-05-01T10:00:00Z", handledReconcileRequestOf(terraform))
+	})
+
+	t.Run("We should read the request handled by the Pulumi operator", func(t *testing.T) {
+		stack := &unstructured.Unstructured{Object: map[string]any{
+			"status": map[string]any{"observedReconcileRequest": "2024-05-01T10:00:00Z"},
+		}}
+		assert.Equal(t, "2024-05-01T10:00:00Z", handledReconcileRequestOf(stack))
+	})
+
+	t.Run("An object that never handled a request reports nothing", func(t *testing.T) {
+		assert.Empty(t, handledReconcileRequestOf(&unstructured.Unstructured{Object: map[string]any{}}))
+	})
+}
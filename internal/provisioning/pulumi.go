@@ -1,9 +1,13 @@
 package provisioning
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/go-logr/logr"
 	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
@@ -12,7 +16,9 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
@@ -26,10 +32,98 @@ type PulumiProvisioner struct {
 	scheme        *runtime.Scheme
 	log           logr.Logger
 	properties    *pulumiProvisionerProperties
+	overrides     objectOverrides
 }
 
 type pulumiProvisionerProperties struct {
 	Git pulumiProvisionerGitProperties `json:"git"`
+	// GitAuth is how the Pulumi operator authenticates to the git repository; without it, the access token in the
+	// github-access-token Secret of the default namespace is used. An empty gitAuth reads a public repository.
+	GitAuth *pulumiProvisionerGitAuthProperties `json:"gitAuth,omitempty"`
+	// EnvRefs are environment variables of the Pulumi program, like cloud credentials
+	EnvRefs map[string]pulumiProvisionerEnvRef `json:"envRefs,omitempty"`
+	// Backend is the Pulumi state backend, like s3://bucket; the operator's default when empty
+	Backend string `json:"backend,omitempty"`
+	// SecretsProvider encrypts the secrets of the stack, like awskms://alias/pulumi; passphrase when empty
+	SecretsProvider string `json:"secretsProvider,omitempty"`
+	// Passphrase is read by the passphrase secrets provider; empty when not declared
+	Passphrase *pulumiProvisionerSecretRef `json:"passphrase,omitempty"`
+	// ServiceAccountName runs the workspace of the stack; the runner generated by klaudio in the namespace by default,
+	// which must match the serviceAccountName of the ResourceRef permissions, when they declare one
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+}
+
+type pulumiProvisionerGitAuthProperties struct {
+	// SecretRef is the key of a Secret holding a git access token
+	SecretRef *pulumiProvisionerSecretRef `json:"secretRef,omitempty"`
+}
+
+type pulumiProvisionerEnvRef struct {
+	Value     *string                     `json:"value,omitempty"`
+	SecretRef *pulumiProvisionerSecretRef `json:"secretRef,omitempty"`
+}
+
+// pulumiProvisionerSecretRef is a key of a Secret; the Stack namespace is used when the namespace is empty
+type pulumiProvisionerSecretRef struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Key       string `json:"key"`
+}
+
+const pulumiPassphraseEnv = "PULUMI_CONFIG_PASSPHRASE"
+
+// pulumiRunnerServiceAccountName is the runner generated to the Pulumi provisioner inside the namespace of the group,
+// bound to the pulumi-runner-role ClusterRole or to the Role of the ResourceRef permissions
+const pulumiRunnerServiceAccountName = "pulumi"
+
+func (properties *pulumiProvisionerProperties) validate() error {
+	if properties.GitAuth != nil && properties.GitAuth.SecretRef != nil {
+		if err := properties.GitAuth.SecretRef.validate("gitAuth.secretRef"); err != nil {
+			return err
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(properties.EnvRefs)) {
+		ref := properties.EnvRefs[name]
+		if (ref.Value == nil) == (ref.SecretRef == nil) {
+			return fmt.Errorf("envRefs.%s requires either a value or a secretRef", name)
+		}
+		if ref.SecretRef != nil {
+			if err := ref.SecretRef.validate(fmt.Sprintf("envRefs.%s.secretRef", name)); err != nil {
+				return err
+			}
+		}
+	}
+	if properties.Passphrase != nil {
+		if properties.SecretsProvider != "" && properties.SecretsProvider != "passphrase" {
+			return fmt.Errorf("passphrase is only read by the passphrase secrets provider, not by %s", properties.SecretsProvider)
+		}
+		if err := properties.Passphrase.validate("passphrase"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ref *pulumiProvisionerSecretRef) validate(path string) error {
+	if ref.Name == "" || ref.Key == "" {
+		return fmt.Errorf("%s requires a name and a key", path)
+	}
+	return nil
+}
+
+// resourceRef is the Stack reference to the Secret key
+func (ref *pulumiProvisionerSecretRef) resourceRef() map[string]any {
+	secret := map[string]any{
+		"name": ref.Name,
+		"key":  ref.Key,
+	}
+	if ref.Namespace != "" {
+		secret["namespace"] = ref.Namespace
+	}
+	return map[string]any{
+		"type":   "Secret",
+		"secret": secret,
+	}
 }
 
 type pulumiProvisionerGitProperties struct {
@@ -44,6 +138,14 @@ func newPulumiProvisioner(c client.Client, d *dynamic.DynamicClient, scheme *run
 	if err := json.Unmarshal(provisioner.Properties.Raw, properties); err != nil {
 		return nil, err
 	}
+	if err := properties.validate(); err != nil {
+		return nil, err
+	}
+
+	overrides, err := newObjectOverrides(provisioner)
+	if err != nil {
+		return nil, err
+	}
 
 	pulumiProvisioner := &PulumiProvisioner{
 		client:        c,
@@ -51,11 +153,41 @@ func newPulumiProvisioner(c client.Client, d *dynamic.DynamicClient, scheme *run
 		scheme:        scheme,
 		log:           log,
 		properties:    properties,
+		overrides:     overrides,
 	}
 
 	return pulumiProvisioner, nil
 }
 
+// Destroy deletes the Stack; with the Delete policy, the Pulumi operator runs `pulumi destroy` before releasing it.
+// With Retain, the stack state is kept in the Pulumi backend.
+func (provisioner *PulumiProvisioner) Destroy(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
+	stackGvk := schema.GroupVersionKind{
+		Group:   "pulumi.com",
+		Version: "v1",
+		Kind:    "Stack",
+	}
+	key := types.NamespacedName{Namespace: resource.Namespace, Name: objectNameOf(resource)}
+
+	policy := deletionPolicyOf(resource)
+
+	provisioner.log.Info(fmt.Sprintf("finalizing Pulumi stack from %s/%s with deletion policy %s...", resource.Namespace, resource.Name, policy))
+
+	if policy == resourcesv1alpha1.DeletionPolicyOrphan {
+		return releaseObject(ctx, provisioner.client, stackGvk, key, resource)
+	}
+
+	return deleteAndWait(ctx, provisioner.client, stackGvk, key, func(stack *unstructured.Unstructured) bool {
+		destroy := policy == resourcesv1alpha1.DeletionPolicyDelete
+		current, _, _ := unstructured.NestedBool(stack.Object, "spec", "destroyOnFinalize")
+		if current == destroy {
+			return false
+		}
+		unstructured.SetNestedField(stack.Object, destroy, "spec", "destroyOnFinalize")
+		return true
+	})
+}
+
 func (provisioner *PulumiProvisioner) Run(ctx context.Context, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
 	provisioner.log.Info(fmt.Sprintf("starting OpenTofu provisioner to resource %s/%s...", resource.Namespace, resource.Name))
 
@@ -75,7 +207,7 @@ func (provisioner *PulumiProvisioner) Run(ctx context.Context, resource *resourc
 
 	provisionedResource := &ProvisionedResource{
 		GroupVersionKind: stack.GroupVersionKind(),
-		Name:             resource.Name,
+		Name:             stack.GetName(),
 	}
 
 	if exists {
@@ -88,10 +220,13 @@ func (provisioner *PulumiProvisioner) Run(ctx context.Context, resource *resourc
 			switch lastUpdate["state"] {
 
 			case "succeeded":
+				// the Stack object doesn't expose the resources owned by the stack, so there is no inventory to report
 				status := &ProvisionedResourceStatus{
 					Resource: provisionedResource,
 					State:    ProvisionedResourceSuccessState,
 					Outputs:  outputs,
+
+					HandledReconcileRequest: handledReconcileRequestOf(stack),
 				}
 				return status, nil
 
@@ -115,40 +250,144 @@ func (provisioner *PulumiProvisioner) Run(ctx context.Context, resource *resourc
 	return status, nil
 }
 
-func (provisioner *PulumiProvisioner) getOrNewStack(ctx context.Context, resource *resourcesv1alpha1.Resource) (*unstructured.Unstructured, error) {
+// Preview renders the Stack that would be applied to the resource; the preview itself is run by the Pulumi operator
+func (provisioner *PulumiProvisioner) Preview(ctx context.Context, resource *resourcesv1alpha1.Resource) (*unstructured.Unstructured, error) {
+	spec, err := provisioner.stackSpec(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	stack := &unstructured.Unstructured{}
+	stack.SetUnstructuredContent(map[string]any{
+		"apiVersion": "pulumi.com/v1",
+		"kind":       "Stack",
+		"metadata": map[string]any{
+			"name":      objectNameOf(resource),
+			"namespace": resource.Namespace,
+		},
+		"spec": spec,
+	})
+
+	return stack, nil
+}
+
+func (provisioner *PulumiProvisioner) stackSpec(resource *resourcesv1alpha1.Resource) (map[string]any, error) {
 	stackConfig := make(map[string]any)
 	if err := json.Unmarshal(resource.Spec.Properties.Raw, &stackConfig); err != nil {
 		return nil, err
 	}
 
-	newSpec := func() map[string]any {
-		return map[string]any{
-			"envRefs": map[string]any{
-				"PULUMI_CONFIG_PASSPHRASE": map[string]any{
-					"type": "Literal",
-					"literal": map[string]any{
-						"value": "",
-					},
-				},
-			},
-			"gitAuth": map[string]any{
-				"accessToken": map[string]any{
-					"type": "Secret",
-					"secret": map[string]any{
-						"name":      "github-access-token",
-						"namespace": "default",
-						"key":       "accessToken",
-					},
+	spec := map[string]any{
+		"envRefs":            provisioner.envRefs(),
+		"stack":              fmt.Sprintf("%s.%s", resource.Spec.Placement, resource.Name),
+		"projectRepo":        provisioner.properties.Git.Repo,
+		"config":             stackConfig,
+		"destroyOnFinalize":  deletionPolicyOf(resource) == resourcesv1alpha1.DeletionPolicyDelete,
+		"serviceAccountName": cmp.Or(provisioner.properties.ServiceAccountName, pulumiRunnerServiceAccountName),
+	}
+	// the spec is kept to JSON values, so it can be compared with the Stack read from the cluster
+	if branch := provisioner.properties.Git.Branch; branch != nil {
+		spec["branch"] = *branch
+	}
+	if dir := provisioner.properties.Git.Dir; dir != nil {
+		spec["repoDir"] = *dir
+	}
+	if interval := provisioner.properties.Git.IntervalInSeconds; interval != nil {
+		spec["resyncFrequencySeconds"] = int64(*interval)
+	}
+	if gitAuth := provisioner.gitAuth(); gitAuth != nil {
+		spec["gitAuth"] = gitAuth
+	}
+	if provisioner.properties.Backend != "" {
+		spec["backend"] = provisioner.properties.Backend
+	}
+	if provisioner.properties.SecretsProvider != "" {
+		spec["secretsProvider"] = provisioner.properties.SecretsProvider
+	}
+
+	return provisioner.overrides.apply("Stack", spec), nil
+}
+
+// envRefs are the declared environment variables, plus the passphrase read by the passphrase secrets provider
+func (provisioner *PulumiProvisioner) envRefs() map[string]any {
+	envRefs := make(map[string]any, len(provisioner.properties.EnvRefs)+1)
+
+	if secretsProvider := provisioner.properties.SecretsProvider; secretsProvider == "" || secretsProvider == "passphrase" {
+		if passphrase := provisioner.properties.Passphrase; passphrase != nil {
+			envRefs[pulumiPassphraseEnv] = passphrase.resourceRef()
+		} else {
+			envRefs[pulumiPassphraseEnv] = map[string]any{
+				"type": "Literal",
+				"literal": map[string]any{
+					"value": "",
 				},
+			}
+		}
+	}
+
+	for name, ref := range provisioner.properties.EnvRefs {
+		if ref.SecretRef != nil {
+			envRefs[name] = ref.SecretRef.resourceRef()
+			continue
+		}
+		envRefs[name] = map[string]any{
+			"type": "Literal",
+			"literal": map[string]any{
+				"value": *ref.Value,
 			},
-			"stack":                  fmt.Sprintf("%s.%s", resource.Spec.Placement, resource.Name),
-			"projectRepo":            provisioner.properties.Git.Repo,
-			"branch":                 provisioner.properties.Git.Branch,
-			"repoDir":                provisioner.properties.Git.Dir,
-			"resyncFrequencySeconds": ptr.To(provisioner.properties.Git.IntervalInSeconds),
-			"config":                 stackConfig,
 		}
 	}
+	return envRefs
+}
+
+// gitAuth is the access token the Pulumi operator reads the repository with; nil for public repositories
+func (provisioner *PulumiProvisioner) gitAuth() map[string]any {
+	gitAuth := provisioner.properties.GitAuth
+	if gitAuth == nil {
+		gitAuth = &pulumiProvisionerGitAuthProperties{
+			SecretRef: &pulumiProvisionerSecretRef{Name: "github-access-token", Namespace: "default", Key: "accessToken"},
+		}
+	}
+	if gitAuth.SecretRef == nil {
+		return nil
+	}
+	return map[string]any{
+		"accessToken": gitAuth.SecretRef.resourceRef(),
+	}
+}
+
+// ConsumedSecrets are the Secrets the Stack reads environment variables and git credentials from
+func (provisioner *PulumiProvisioner) ConsumedSecrets(resource *resourcesv1alpha1.Resource) ([]types.NamespacedName, error) {
+	spec, err := provisioner.stackSpec(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]types.NamespacedName, 0)
+	for _, field := range []string{"envRefs", "gitAuth"} {
+		refs, _ := spec[field].(map[string]any)
+		for _, name := range slices.Sorted(maps.Keys(refs)) {
+			ref, _ := refs[name].(map[string]any)
+			if kind, _ := ref["type"].(string); kind != "Secret" {
+				continue
+			}
+			secret, _ := ref["secret"].(map[string]any)
+			secretName, _ := secret["name"].(string)
+			if secretName == "" {
+				continue
+			}
+			secretNamespace, _ := secret["namespace"].(string)
+			secrets = append(secrets, types.NamespacedName{Namespace: cmp.Or(secretNamespace, resource.Namespace), Name: secretName})
+		}
+	}
+	return secrets, nil
+}
+
+func (provisioner *PulumiProvisioner) getOrNewStack(ctx context.Context, resource *resourcesv1alpha1.Resource) (*unstructured.Unstructured, error) {
+	spec, err := provisioner.stackSpec(resource)
+	if err != nil {
+		return nil, err
+	}
 
 	stackGvk := schema.GroupVersionKind{
 		Group:   "pulumi.com",
@@ -156,30 +395,23 @@ func (provisioner *PulumiProvisioner) getOrNewStack(ctx context.Context, resourc
 		Kind:    "Stack",
 	}
 
-	stackGvWithResource := stackGvk.GroupVersion().WithResource("stacks")
-
-	stack, err := provisioner.dynamicClient.
-		Resource(stackGvWithResource).
-		Namespace(resource.Namespace).
-		Get(ctx, resource.Name, metav1.GetOptions{})
+	stack := &unstructured.Unstructured{}
+	stack.SetGroupVersionKind(stackGvk)
 
-	if err != nil {
+	if err := provisioner.client.Get(ctx, types.NamespacedName{Namespace: resource.Namespace, Name: objectNameOf(resource)}, stack); err != nil {
 		if !apierrors.IsNotFound(err) {
 			return nil, err
 		}
 
-		stack = &unstructured.Unstructured{}
-		stack.SetGroupVersionKind(stackGvk)
-
 		object := make(map[string]any)
 
 		object["apiVersion"] = "pulumi.com/v1"
 		object["kind"] = "Stack"
 		object["metadata"] = map[string]any{
-			"name":      resource.Name,
+			"name":      objectNameOf(resource),
 			"namespace": resource.Namespace,
 		}
-		object["spec"] = newSpec()
+		object["spec"] = spec
 
 		stack.SetUnstructuredContent(object)
 
@@ -208,12 +440,48 @@ func (provisioner *PulumiProvisioner) getOrNewStack(ctx context.Context, resourc
 			},
 		})
 
+		applyPassThroughMetadata(stack, resource)
+
 		if err := provisioner.client.Create(ctx, stack); err != nil {
 			return nil, err
 		}
-	} else {
-		stack.Object["spec"] = newSpec()
+	} else if err := provisioner.updateStack(ctx, stack, spec, resource); err != nil {
+		return nil, err
 	}
 
 	return stack, nil
 }
+
+// updateStack writes the spec to an existing Stack, leaving it as is when nothing changed; on a conflict, like the
+// Pulumi operator writing its status in the meantime, the Stack is read again
+func (provisioner *PulumiProvisioner) updateStack(ctx context.Context, stack *unstructured.Unstructured, spec map[string]any, resource *resourcesv1alpha1.Resource) error {
+	fresh := true
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if !fresh {
+			if err := provisioner.client.Get(ctx, client.ObjectKeyFromObject(stack), stack); err != nil {
+				return err
+			}
+		}
+		fresh = false
+
+		// numbers read from the cluster are integers, and the ones from properties are floats; JSON tells them apart
+		// only by value
+		current, err := json.Marshal(stack.Object)
+		if err != nil {
+			return err
+		}
+		stack.Object["spec"] = runtime.DeepCopyJSON(spec)
+		applyPassThroughMetadata(stack, resource)
+		desired, err := json.Marshal(stack.Object)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(current, desired) {
+			return nil
+		}
+
+		provisioner.log.Info(fmt.Sprintf("updating Stack %s/%s...", stack.GetNamespace(), stack.GetName()))
+
+		return provisioner.client.Update(ctx, stack)
+	})
+}
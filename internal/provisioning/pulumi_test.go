@@ -0,0 +1,225 @@
+package provisioning
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_PulumiStackSpec(t *testing.T) {
+	resource := &resourcesv1alpha1.Resource{
+		ObjectMeta: metav1.ObjectMeta{Name: "bucket", Namespace: "checkout"},
+		Spec: resourcesv1alpha1.ResourceSpec{
+			Placement:  "prod",
+			Properties: &runtime.RawExtension{Raw: []byte(`{"name":"orders"}`)},
+		},
+	}
+
+	newProvisioner := func(t *testing.T, properties string) *PulumiProvisioner {
+		provisioner, err := newPulumiProvisioner(nil, nil, nil, logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       PulumiProvisionerName,
+			Properties: &runtime.RawExtension{Raw: []byte(properties)},
+		})
+		assert.NoError(t, err)
+		return provisioner.(*PulumiProvisioner)
+	}
+
+	t.Run("Without auth properties, we should read the default access token and an empty passphrase", func(t *testing.T) {
+		spec, err := newProvisioner(t, `{"git":{"repo":"https://github.com/nubank/stacks"}}`).stackSpec(resource)
+		assert.NoError(t, err)
+
+		assert.Equal(t, map[string]any{
+			"accessToken": map[string]any{
+				"type":   "Secret",
+				"secret": map[string]any{"name": "github-access-token", "namespace": "default", "key": "accessToken"},
+			},
+		}, spec["gitAuth"])
+		assert.Equal(t, map[string]any{
+			"PULUMI_CONFIG_PASSPHRASE": map[string]any{"type": "Literal", "literal": map[string]any{"value": ""}},
+		}, spec["envRefs"])
+		assert.NotContains(t, spec, "backend")
+		assert.NotContains(t, spec, "secretsProvider")
+		assert.Equal(t, "pulumi", spec["serviceAccountName"])
+	})
+
+	t.Run("We should run the workspace with the declared service account", func(t *testing.T) {
+		spec, err := newProvisioner(t, `{"git":{"repo":"https://github.com/nubank/stacks"},"serviceAccountName":"stacks-runner"}`).stackSpec(resource)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "stacks-runner", spec["serviceAccountName"])
+	})
+
+	t.Run("We should be able to configure the auth, the env refs, the backend and the passphrase", func(t *testing.T) {
+		provisioner := newProvisioner(t, `{
+			"git": {"repo": "https://github.com/nubank/stacks"},
+			"gitAuth": {"secretRef": {"name": "stacks-token", "key": "token"}},
+			"envRefs": {
+				"AWS_REGION": {"value": "us-east-1"},
+				"AWS_SECRET_ACCESS_KEY": {"secretRef": {"name": "aws", "key": "secretAccessKey"}}
+			},
+			"backend": "s3://nubank-pulumi-state",
+			"passphrase": {"name": "pulumi", "key": "passphrase"}
+		}`)
+
+		spec, err := provisioner.stackSpec(resource)
+		assert.NoError(t, err)
+
+		assert.Equal(t, map[string]any{
+			"accessToken": map[string]any{"type": "Secret", "secret": map[string]any{"name": "stacks-token", "key": "token"}},
+		}, spec["gitAuth"])
+		assert.Equal(t, map[string]any{
+			"AWS_REGION":               map[string]any{"type": "Literal", "literal": map[string]any{"value": "us-east-1"}},
+			"AWS_SECRET_ACCESS_KEY":    map[string]any{"type": "Secret", "secret": map[string]any{"name": "aws", "key": "secretAccessKey"}},
+			"PULUMI_CONFIG_PASSPHRASE": map[string]any{"type": "Secret", "secret": map[string]any{"name": "pulumi", "key": "passphrase"}},
+		}, spec["envRefs"])
+		assert.Equal(t, "s3://nubank-pulumi-state", spec["backend"])
+
+		secrets, err := provisioner.ConsumedSecrets(resource)
+		assert.NoError(t, err)
+		assert.Equal(t, []types.NamespacedName{
+			{Namespace: "checkout", Name: "aws"},
+			{Namespace: "checkout", Name: "pulumi"},
+			{Namespace: "checkout", Name: "stacks-token"},
+		}, secrets)
+	})
+
+	t.Run("We should be able to read public repositories with another secrets provider", func(t *testing.T) {
+		spec, err := newProvisioner(t, `{"git":{"repo":"https://github.com/nubank/stacks"},"gitAuth":{},"secretsProvider":"awskms://alias/pulumi"}`).stackSpec(resource)
+		assert.NoError(t, err)
+
+		assert.NotContains(t, spec, "gitAuth")
+		assert.Empty(t, spec["envRefs"])
+		assert.Equal(t, "awskms://alias/pulumi", spec["secretsProvider"])
+	})
+
+	t.Run("A protected Resource should not destroy the stack resources", func(t *testing.T) {
+		protected := resource.DeepCopy()
+		protected.Spec.Protect = true
+
+		spec, err := newProvisioner(t, `{"git":{"repo":"https://github.com/nubank/stacks"}}`).stackSpec(protected)
+		assert.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"name": "orders"}, spec["config"])
+		assert.Equal(t, false, spec["destroyOnFinalize"])
+	})
+
+	t.Run("We should reject incomplete auth properties", func(t *testing.T) {
+		for properties, expected := range map[string]string{
+			`{"git":{"repo":"r"},"gitAuth":{"secretRef":{"name":"token"}}}`:                                      "gitAuth.secretRef requires a name and a key",
+			`{"git":{"repo":"r"},"envRefs":{"AWS_REGION":{}}}`:                                                   "envRefs.AWS_REGION requires either a value or a secretRef",
+			`{"git":{"repo":"r"},"secretsProvider":"awskms://alias/pulumi","passphrase":{"name":"p","key":"k"}}`: "passphrase is only read by the passphrase secrets provider, not by awskms://alias/pulumi",
+		} {
+			_, err := newPulumiProvisioner(nil, nil, nil, logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+				Name:       PulumiProvisionerName,
+				Properties: &runtime.RawExtension{Raw: []byte(properties)},
+			})
+			assert.EqualError(t, err, expected)
+		}
+	})
+}
+
+func Test_PulumiStackUpdate(t *testing.T) {
+	stackGvk := schema.GroupVersionKind{Group: "pulumi.com", Version: "v1", Kind: "Stack"}
+
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(stackGvk, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(stackGvk.GroupVersion().WithKind("StackList"), &unstructured.UnstructuredList{})
+
+	ctx := context.TODO()
+
+	newResource := func(properties string) *resourcesv1alpha1.Resource {
+		return &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "bucket", Namespace: "checkout"},
+			Spec: resourcesv1alpha1.ResourceSpec{
+				Placement:  "prod",
+				Properties: &runtime.RawExtension{Raw: []byte(properties)},
+			},
+		}
+	}
+
+	newProvisioner := func(t *testing.T, c client.Client) *PulumiProvisioner {
+		provisioner, err := newPulumiProvisioner(c, nil, scheme, logr.Discard(), &resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       PulumiProvisionerName,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"git":{"repo":"https://github.com/nubank/stacks","branch":"main","intervalInSeconds":60}}`)},
+		})
+		assert.NoError(t, err)
+		return provisioner.(*PulumiProvisioner)
+	}
+
+	readStack := func(t *testing.T, c client.Client) *unstructured.Unstructured {
+		stack := &unstructured.Unstructured{}
+		stack.SetGroupVersionKind(stackGvk)
+		assert.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "checkout", Name: "bucket"}, stack))
+		return stack
+	}
+
+	t.Run("We should update the spec of an existing Stack", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		provisioner := newProvisioner(t, c)
+
+		_, err := provisioner.getOrNewStack(ctx, newResource(`{"name":"orders","replicas":2}`))
+		assert.NoError(t, err)
+
+		_, err = provisioner.getOrNewStack(ctx, newResource(`{"name":"payments","replicas":2}`))
+		assert.NoError(t, err)
+
+		config, _, _ := unstructured.NestedMap(readStack(t, c).Object, "spec", "config")
+		assert.Equal(t, "payments", config["name"])
+
+		branch, _, _ := unstructured.NestedString(readStack(t, c).Object, "spec", "branch")
+		assert.Equal(t, "main", branch)
+	})
+
+	t.Run("We should leave a Stack as is when its spec didn't change", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).Build()
+		provisioner := newProvisioner(t, c)
+
+		_, err := provisioner.getOrNewStack(ctx, newResource(`{"name":"orders","replicas":2}`))
+		assert.NoError(t, err)
+		resourceVersion := readStack(t, c).GetResourceVersion()
+
+		_, err = provisioner.getOrNewStack(ctx, newResource(`{"name":"orders","replicas":2}`))
+		assert.NoError(t, err)
+
+		assert.Equal(t, resourceVersion, readStack(t, c).GetResourceVersion())
+	})
+
+	t.Run("We should write the deletion policy to a Stack created without it", func(t *testing.T) {
+		stack := &unstructured.Unstructured{}
+		stack.SetGroupVersionKind(stackGvk)
+		stack.SetNamespace("checkout")
+		stack.SetName("bucket")
+		unstructured.SetNestedField(stack.Object, "prod.bucket", "spec", "stack")
+
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(stack).Build()
+		provisioner := newProvisioner(t, c)
+
+		resource := newResource(`{"name":"orders"}`)
+		resource.Spec.DeletionPolicy = resourcesv1alpha1.DeletionPolicyDelete
+
+		_, err := provisioner.getOrNewStack(ctx, resource)
+		assert.NoError(t, err)
+
+		destroyOnFinalize, _, _ := unstructured.NestedBool(readStack(t, c).Object, "spec", "destroyOnFinalize")
+		assert.True(t, destroyOnFinalize)
+
+		resource.Spec.DeletionPolicy = resourcesv1alpha1.DeletionPolicyRetain
+
+		_, err = provisioner.getOrNewStack(ctx, resource)
+		assert.NoError(t, err)
+
+		destroyOnFinalize, _, _ = unstructured.NestedBool(readStack(t, c).Object, "spec", "destroyOnFinalize")
+		assert.False(t, destroyOnFinalize)
+	})
+}
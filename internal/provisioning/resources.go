@@ -1,6 +1,19 @@
 package provisioning
 
-import "k8s.io/apimachinery/pkg/runtime/schema"
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"strings"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
 
 type ProvisionedResourceStateDescription string
 
@@ -11,9 +24,22 @@ const (
 )
 
 type ProvisionedResourceStatus struct {
-	Resource *ProvisionedResource
-	State    ProvisionedResourceStateDescription
-	Outputs  map[string]any
+	Resource  *ProvisionedResource
+	State     ProvisionedResourceStateDescription
+	Outputs   map[string]any
+	Inventory []ProvisionedInventoryEntry
+	// Drifted means the provisioned resource diverges from the declared state
+	Drifted bool
+	// HandledReconcileRequest is the last value of ReconcileRequestAnnotations handled by the controller of the
+	// provisioner object; it's empty when the provisioner doesn't report it
+	HandledReconcileRequest string
+}
+
+// ProvisionedInventoryEntry is a cloud resource created by the provisioner on behalf of a Resource
+type ProvisionedInventoryEntry struct {
+	Type string
+	Name string
+	ID   string
 }
 
 type ProvisionedResource struct {
@@ -24,3 +50,149 @@ type ProvisionedResource struct {
 func (p *ProvisionedResourceStatus) IsRunning() bool {
 	return p.State == ProvisionedResourceRunningState
 }
+
+// typedOutputValue reads an output written as text back to its type. tf-controller writes string outputs as they are
+// and any other type as JSON, so the type reported for the output decides: a string like "007" is never read as a
+// number. Without a reported type, only JSON objects and arrays are decoded; numbers and booleans are kept as strings.
+func typedOutputValue(raw []byte, outputType any) any {
+	if outputType == "string" {
+		return string(raw)
+	}
+
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw)
+	}
+
+	switch value.(type) {
+	case map[string]any, []any:
+		return value
+	case float64, bool:
+		if outputType != nil {
+			return value
+		}
+	}
+	return string(raw)
+}
+
+// objectNameOf returns the name of the provisioner object of the Resource; the Resource name by default
+func objectNameOf(resource *resourcesv1alpha1.Resource) string {
+	if resource.Spec.ProvisionerObjectName != "" {
+		return resource.Spec.ProvisionerObjectName
+	}
+	return resource.Name
+}
+
+// deletionPolicyOf returns the Resource's deletion policy, Delete by default; a protected Resource never deletes its
+// infrastructure, so Delete becomes Retain
+func deletionPolicyOf(resource *resourcesv1alpha1.Resource) resourcesv1alpha1.DeletionPolicy {
+	policy := resource.Spec.DeletionPolicy
+	if policy == "" {
+		policy = resourcesv1alpha1.DeletionPolicyDelete
+	}
+	if resource.Spec.Protect && policy == resourcesv1alpha1.DeletionPolicyDelete {
+		return resourcesv1alpha1.DeletionPolicyRetain
+	}
+	return policy
+}
+
+// deleteAndWait deletes the provisioner object, reporting it as running until it's actually gone;
+// provisioner controllers keep their own finalizers while the infrastructure behind the object is destroyed.
+// prepare, when not nil, can change the object right before the deletion (e.g. to keep the infrastructure);
+// it returns true when the object must be updated.
+func deleteAndWait(ctx context.Context, c client.Client, gvk schema.GroupVersionKind, key types.NamespacedName, prepare func(*unstructured.Unstructured) bool) (*ProvisionedResourceStatus, error) {
+	provisionedResource := &ProvisionedResource{GroupVersionKind: gvk, Name: key.Name}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+
+	if err := c.Get(ctx, key, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return &ProvisionedResourceStatus{Resource: provisionedResource, State: ProvisionedResourceSuccessState}, nil
+		}
+		return nil, err
+	}
+
+	if obj.GetDeletionTimestamp() == nil {
+		if prepare != nil && prepare(obj) {
+			if err := c.Update(ctx, obj); err != nil {
+				return nil, err
+			}
+		}
+		if err := c.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationForeground)); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+	}
+
+	return &ProvisionedResourceStatus{Resource: provisionedResource, State: ProvisionedResourceRunningState}, nil
+}
+
+// releaseObject removes the Resource from the owners of the provisioner object, so it survives the Resource
+func releaseObject(ctx context.Context, c client.Client, gvk schema.GroupVersionKind, key types.NamespacedName, resource *resourcesv1alpha1.Resource) (*ProvisionedResourceStatus, error) {
+	if err := releaseOwned(ctx, c, gvk, key, resource); err != nil {
+		return nil, err
+	}
+
+	return &ProvisionedResourceStatus{
+		Resource: &ProvisionedResource{GroupVersionKind: gvk, Name: key.Name},
+		State:    ProvisionedResourceSuccessState,
+	}, nil
+}
+
+// releaseOwned removes the Resource from the owners of any object; missing objects are ignored
+func releaseOwned(ctx context.Context, c client.Client, gvk schema.GroupVersionKind, key types.NamespacedName, resource *resourcesv1alpha1.Resource) error {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+
+	if err := c.Get(ctx, key, obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	owners := obj.GetOwnerReferences()
+	remaining := make([]metav1.OwnerReference, 0, len(owners))
+	for _, owner := range owners {
+		if owner.UID != resource.UID {
+			remaining = append(remaining, owner)
+		}
+	}
+	if len(remaining) == len(owners) {
+		return nil
+	}
+
+	obj.SetOwnerReferences(remaining)
+
+	return c.Update(ctx, obj)
+}
+
+// applyPassThroughMetadata copies the labels and annotations declared to the Resource into the provisioner object;
+// keys owned by klaudio are never copied, so they can't clash with the ones set by the provisioner itself.
+func applyPassThroughMetadata(obj *unstructured.Unstructured, resource *resourcesv1alpha1.Resource) {
+	passThrough := func(source map[string]string) map[string]string {
+		r := make(map[string]string)
+		for k, v := range source {
+			if strings.HasPrefix(k, resourcesv1alpha1.Group+"/") || strings.HasPrefix(k, "kubectl.kubernetes.io/") {
+				continue
+			}
+			r[k] = v
+		}
+		return r
+	}
+
+	if labels := passThrough(resource.GetLabels()); len(labels) > 0 {
+		current := obj.GetLabels()
+		if current == nil {
+			current = make(map[string]string)
+		}
+		maps.Copy(current, labels)
+		obj.SetLabels(current)
+	}
+
+	if annotations := passThrough(resource.GetAnnotations()); len(annotations) > 0 {
+		current := obj.GetAnnotations()
+		if current == nil {
+			current = make(map[string]string)
+		}
+		maps.Copy(current, annotations)
+		obj.SetAnnotations(current)
+	}
+}
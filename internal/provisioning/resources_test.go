@@ -0,0 +1,116 @@
+package provisioning
+
+import (
+	"testing"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_applyPassThroughMetadata(t *testing.T) {
+
+	t.Run("We should be able to copy the Resource's labels and annotations to the provisioner object", func(t *testing.T) {
+		resource := &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					"cost-center": "payments",
+					resourcesv1alpha1.Group + "/managedBy.name": "my-deployment",
+				},
+				Annotations: map[string]string{
+					"backup.velero.io/backup-volumes":                  "data",
+					"kubectl.kubernetes.io/last-applied-configuration": "{}",
+				},
+			},
+		}
+
+		obj := &unstructured.Unstructured{}
+		obj.SetLabels(map[string]string{
+			resourcesv1alpha1.Group + "/managedBy.name": "my-resource",
+		})
+
+		applyPassThroughMetadata(obj, resource)
+
+		assert.Equal(t, map[string]string{
+			"cost-center": "payments",
+			resourcesv1alpha1.Group + "/managedBy.name": "my-resource",
+		}, obj.GetLabels())
+		assert.Equal(t, map[string]string{
+			"backup.velero.io/backup-volumes": "data",
+		}, obj.GetAnnotations())
+	})
+
+	t.Run("We should not touch the provisioner object when there is nothing to pass through", func(t *testing.T) {
+		obj := &unstructured.Unstructured{}
+
+		applyPassThroughMetadata(obj, &resourcesv1alpha1.Resource{})
+
+		assert.Nil(t, obj.GetLabels())
+		assert.Nil(t, obj.GetAnnotations())
+	})
+}
+
+func Test_typedOutputValue(t *testing.T) {
+
+	t.Run("We should be able to read numbers, booleans and collections written as text", func(t *testing.T) {
+		assert.Equal(t, float64(5432), typedOutputValue([]byte("5432"), "number"))
+		assert.Equal(t, true, typedOutputValue([]byte("true"), "bool"))
+		assert.Equal(t, map[string]any{"team": "platform"}, typedOutputValue([]byte(`{"team":"platform"}`), []any{"object", map[string]any{"team": "string"}}))
+		assert.Equal(t, []any{"a", "b"}, typedOutputValue([]byte(`["a","b"]`), []any{"list", "string"}))
+	})
+
+	t.Run("Strings should be kept as they are, even when they look like numbers", func(t *testing.T) {
+		assert.Equal(t, "001234567890", typedOutputValue([]byte("001234567890"), "string"))
+		assert.Equal(t, "5432", typedOutputValue([]byte("5432"), "string"))
+		assert.Equal(t, "true", typedOutputValue([]byte("true"), "string"))
+		assert.Equal(t, `{"team":"platform"}`, typedOutputValue([]byte(`{"team":"platform"}`), "string"))
+	})
+
+	t.Run("Without a reported type, only collections should be decoded", func(t *testing.T) {
+		assert.Equal(t, "5432", typedOutputValue([]byte("5432"), nil))
+		assert.Equal(t, "true", typedOutputValue([]byte("true"), nil))
+		assert.Equal(t, map[string]any{"team": "platform"}, typedOutputValue([]byte(`{"team":"platform"}`), nil))
+	})
+
+	t.Run("Anything else should be kept as a string", func(t *testing.T) {
+		assert.Equal(t, "my-database.rds.amazonaws.com", typedOutputValue([]byte("my-database.rds.amazonaws.com"), nil))
+		assert.Equal(t, `"quoted"`, typedOutputValue([]byte(`"quoted"`), nil))
+		assert.Equal(t, "null", typedOutputValue([]byte("null"), nil))
+	})
+}
+
+func Test_objectNameOf(t *testing.T) {
+
+	t.Run("The provisioner object should be named after the Resource by default", func(t *testing.T) {
+		resource := &resourcesv1alpha1.Resource{ObjectMeta: metav1.ObjectMeta{Name: "my-deployment.my-resource"}}
+
+		assert.Equal(t, "my-deployment.my-resource", objectNameOf(resource))
+	})
+
+	t.Run("We should be able to choose the name of the provisioner object", func(t *testing.T) {
+		resource := &resourcesv1alpha1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-deployment.my-resource"},
+			Spec:       resourcesv1alpha1.ResourceSpec{ProvisionerObjectName: "legacy-bucket"},
+		}
+
+		assert.Equal(t, "legacy-bucket", objectNameOf(resource))
+	})
+}
+
+func Test_deletionPolicyOf(t *testing.T) {
+
+	t.Run("The infrastructure should be deleted by default", func(t *testing.T) {
+		assert.Equal(t, resourcesv1alpha1.DeletionPolicyDelete, deletionPolicyOf(&resourcesv1alpha1.Resource{}))
+	})
+
+	t.Run("A protected Resource should retain its infrastructure instead of deleting it", func(t *testing.T) {
+		protected := func(policy resourcesv1alpha1.DeletionPolicy) *resourcesv1alpha1.Resource {
+			return &resourcesv1alpha1.Resource{Spec: resourcesv1alpha1.ResourceSpec{DeletionPolicy: policy, Protect: true}}
+		}
+
+		assert.Equal(t, resourcesv1alpha1.DeletionPolicyRetain, deletionPolicyOf(protected("")))
+		assert.Equal(t, resourcesv1alpha1.DeletionPolicyRetain, deletionPolicyOf(protected(resourcesv1alpha1.DeletionPolicyDelete)))
+		assert.Equal(t, resourcesv1alpha1.DeletionPolicyOrphan, deletionPolicyOf(protected(resourcesv1alpha1.DeletionPolicyOrphan)))
+	})
+}
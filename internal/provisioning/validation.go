@@ -0,0 +1,80 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// ValidateProperties checks that the properties of a built-in provisioner deserialize and declare the fields required
+// to run it. Provisioners from plugins are only known at runtime, so their properties aren't checked.
+func ValidateProperties(provisioner *resourcesv1alpha1.ResourceRefProvisioner) error {
+	switch provisioner.Name {
+	case OpenTofuProvisionerName:
+		properties := &openTofuProvisionerProperties{}
+		if err := unmarshalProperties(provisioner, properties); err != nil {
+			return err
+		}
+		if properties.Git.Repo == "" && properties.OCI == nil && properties.Bucket == nil {
+			return errors.New("opentofu provisioner requires git.repo, oci or bucket")
+		}
+		if err := properties.validate(); err != nil {
+			return fmt.Errorf("invalid opentofu provisioner properties: %w", err)
+		}
+
+	case PulumiProvisionerName:
+		properties := &pulumiProvisionerProperties{}
+		if err := unmarshalProperties(provisioner, properties); err != nil {
+			return err
+		}
+		if properties.Git.Repo == "" {
+			return errors.New("pulumi provisioner requires git.repo")
+		}
+		if err := properties.validate(); err != nil {
+			return fmt.Errorf("invalid pulumi provisioner properties: %w", err)
+		}
+
+	case HelmProvisionerName:
+		properties := &helmProvisionerProperties{}
+		if err := unmarshalProperties(provisioner, properties); err != nil {
+			return err
+		}
+		if properties.Chart.Repo == "" || properties.Chart.Name == "" {
+			return errors.New("helm provisioner requires chart.repo and chart.name")
+		}
+
+	case CrossplaneProvisionerName:
+		properties := &crossplaneProvisionerProperties{}
+		if err := unmarshalProperties(provisioner, properties); err != nil {
+			return err
+		}
+		if properties.ObjectRef.ApiVersion == "" || properties.ObjectRef.Kind == "" {
+			return errors.New("crossplane provisioner requires objectRef.apiVersion and objectRef.kind")
+		}
+
+	case HttpProvisionerName, NoopProvisionerName:
+		// both are built without any client, checking their own properties
+		factory, err := SelectByName(string(provisioner.Name))
+		if err != nil {
+			return err
+		}
+		if _, err := factory(nil, nil, nil, logr.Discard(), provisioner); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unmarshalProperties(provisioner *resourcesv1alpha1.ResourceRefProvisioner, properties any) error {
+	if provisioner.Properties == nil || len(provisioner.Properties.Raw) == 0 {
+		return fmt.Errorf("%s provisioner requires properties", provisioner.Name)
+	}
+	if err := json.Unmarshal(provisioner.Properties.Raw, properties); err != nil {
+		return fmt.Errorf("invalid %s provisioner properties: %w", provisioner.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package provisioning
+
+import (
+	"testing"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_ValidateProperties(t *testing.T) {
+	provisioner := func(name string, properties string) *resourcesv1alpha1.ResourceRefProvisioner {
+		p := &resourcesv1alpha1.ResourceRefProvisioner{Name: resourcesv1alpha1.ResourceRefProvisionerName(name)}
+		if properties != "" {
+			p.Properties = &runtime.RawExtension{Raw: []byte(properties)}
+		}
+		return p
+	}
+
+	t.Run("We should accept the properties required by each provisioner", func(t *testing.T) {
+		assert.NoError(t, ValidateProperties(provisioner("opentofu", `{"git":{"repo":"https://github.com/nubank/modules"}}`)))
+		assert.NoError(t, ValidateProperties(provisioner("pulumi", `{"git":{"repo":"https://github.com/nubank/stacks"}}`)))
+		assert.NoError(t, ValidateProperties(provisioner("helm", `{"chart":{"repo":"oci://charts","name":"redis"}}`)))
+		assert.NoError(t, ValidateProperties(provisioner("crossplane", `{"objectRef":{"apiVersion":"aws.crossplane.io/v1","kind":"Bucket"}}`)))
+		assert.NoError(t, ValidateProperties(provisioner("http", `{"url":"https://tickets.nubank.io"}`)))
+		assert.NoError(t, ValidateProperties(provisioner("noop", "")))
+	})
+
+	t.Run("We should reject missing required properties", func(t *testing.T) {
+		assert.EqualError(t, ValidateProperties(provisioner("opentofu", `{"git":{"branch":"main"}}`)), "opentofu provisioner requires git.repo, oci or bucket")
+		assert.EqualError(t, ValidateProperties(provisioner("pulumi", "")), "pulumi provisioner requires properties")
+		assert.EqualError(t, ValidateProperties(provisioner("helm", `{"chart":{"name":"redis"}}`)), "helm provisioner requires chart.repo and chart.name")
+		assert.EqualError(t, ValidateProperties(provisioner("http", `{}`)), "http provisioner requires an url")
+	})
+
+	t.Run("We should reject invalid Terraform knobs", func(t *testing.T) {
+		assert.EqualError(t, ValidateProperties(provisioner("opentofu", `{"git":{"repo":"r"},"approvePlan":"never"}`)),
+			"invalid opentofu provisioner properties: approvePlan must be auto or manual, got never")
+		assert.EqualError(t, ValidateProperties(provisioner("opentofu", `{"git":{"repo":"r"},"varsFrom":[{"kind":"Pod","name":"vars"}]}`)),
+			"invalid opentofu provisioner properties: varsFrom[0].kind must be Secret or ConfigMap, got Pod")
+	})
+
+	t.Run("We should accept a single OpenTofu source, with the fields it requires", func(t *testing.T) {
+		assert.NoError(t, ValidateProperties(provisioner("opentofu", `{"oci":{"url":"oci://ghcr.io/nubank/modules","tag":"v1"}}`)))
+		assert.NoError(t, ValidateProperties(provisioner("opentofu", `{"bucket":{"bucketName":"modules","endpoint":"s3.amazonaws.com"}}`)))
+
+		assert.EqualError(t, ValidateProperties(provisioner("opentofu", `{"oci":{"tag":"v1"}}`)),
+			"invalid opentofu provisioner properties: oci requires a url")
+		assert.EqualError(t, ValidateProperties(provisioner("opentofu", `{"bucket":{"bucketName":"modules"}}`)),
+			"invalid opentofu provisioner properties: bucket requires a bucketName and an endpoint")
+		assert.EqualError(t, ValidateProperties(provisioner("opentofu", `{"git":{"repo":"r"},"oci":{"url":"oci://ghcr.io/nubank/modules"}}`)),
+			"invalid opentofu provisioner properties: only one of git, oci or bucket may be declared")
+	})
+
+	t.Run("We should reject properties that don't deserialize", func(t *testing.T) {
+		err := ValidateProperties(provisioner("opentofu", `{"git":"https://github.com/nubank/modules"}`))
+
+		assert.ErrorContains(t, err, "invalid opentofu provisioner properties")
+	})
+
+	t.Run("Provisioners from plugins aren't checked", func(t *testing.T) {
+		assert.NoError(t, ValidateProperties(provisioner("vault", `[]`)))
+	})
+}
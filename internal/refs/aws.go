@@ -0,0 +1,126 @@
+package refs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/awsauth"
+)
+
+// AWSParameterStoreProvider reads a parameter from AWS Systems Manager Parameter Store, decrypted, as value
+type AWSParameterStoreProvider struct {
+	Client *http.Client
+}
+
+func (p *AWSParameterStoreProvider) Read(ctx context.Context, ref resourcesv1alpha1.ExternalRef, credentials map[string]string) (map[string]any, error) {
+	response := struct {
+		Parameter struct {
+			Value string `json:"Value"`
+		} `json:"Parameter"`
+	}{}
+
+	request := map[string]any{"Name": ref.Path, "WithDecryption": true}
+	if err := callAWS(ctx, p.Client, ref, credentials, "ssm", "AmazonSSM.GetParameter", request, &response); err != nil {
+		return nil, err
+	}
+
+	return map[string]any{"value": response.Parameter.Value}, nil
+}
+
+// AWSSecretsManagerProvider reads a secret from AWS Secrets Manager; a secret holding a JSON object is read key by
+// key, anything else as value
+type AWSSecretsManagerProvider struct {
+	Client *http.Client
+}
+
+func (p *AWSSecretsManagerProvider) Read(ctx context.Context, ref resourcesv1alpha1.ExternalRef, credentials map[string]string) (map[string]any, error) {
+	response := struct {
+		SecretString string `json:"SecretString"`
+	}{}
+
+	request := map[string]any{"SecretId": ref.Path}
+	if err := callAWS(ctx, p.Client, ref, credentials, "secretsmanager", "secretsmanager.GetSecretValue", request, &response); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]any)
+	if err := json.Unmarshal([]byte(response.SecretString), &values); err != nil {
+		return map[string]any{"value": response.SecretString}, nil
+	}
+	return values, nil
+}
+
+// awsRegionPattern is what a region may look like, like us-east-1; the region becomes part of the endpoint host
+var awsRegionPattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// callAWS calls an action of an AWS JSON API, signed with Signature Version 4
+func callAWS(ctx context.Context, c *http.Client, ref resourcesv1alpha1.ExternalRef, credentials map[string]string, service string, target string, input any, output any) error {
+	if ref.Region == "" {
+		return fmt.Errorf("the region of %s is required", service)
+	}
+	if !awsRegionPattern.MatchString(ref.Region) {
+		return fmt.Errorf("invalid region of %s %q; it must have only lowercase letters, digits and dashes, like us-east-1", service, ref.Region)
+	}
+
+	accessKeyId, secretAccessKey := credentials["accessKeyId"], credentials["secretAccessKey"]
+	if accessKeyId == "" || secretAccessKey == "" {
+		return fmt.Errorf("credentials to AWS must have accessKeyId and secretAccessKey keys")
+	}
+
+	endpoint := ref.Address
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com", service, ref.Region)
+	}
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint of %s: %w", service, err)
+	}
+	if endpointUrl.Path == "" {
+		endpointUrl.Path = "/"
+	}
+
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointUrl.String(), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	request.Header.Set("X-Amz-Target", target)
+
+	signingCredentials := awsauth.Credentials{AccessKeyID: accessKeyId, SecretAccessKey: secretAccessKey, SessionToken: credentials["sessionToken"]}
+	if err := awsauth.SignRequest(ctx, request, payload, signingCredentials, ref.Region, service, time.Now().UTC()); err != nil {
+		return fmt.Errorf("unable to sign the request to %s: %w", service, err)
+	}
+
+	response, err := c.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := readBody(response)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded %s to %s: %s", service, response.Status, ref.Path, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.Unmarshal(body, output); err != nil {
+		return fmt.Errorf("unable to read the response of %s to %s: %w", service, ref.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package refs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DefaultExternalRefTTL is how long values read from external stores are cached when the ref doesn't choose a TTL
+	DefaultExternalRefTTL = 5 * time.Minute
+
+	// ExternalRefTimeout bounds every request to an external store, so a store that doesn't answer doesn't hold the
+	// deployment
+	ExternalRefTimeout = 30 * time.Second
+
+	// maxExternalResponseSize bounds the responses read from external stores
+	maxExternalResponseSize = 1 << 20
+
+	maxExternalRedirects = 10
+)
+
+// Provider reads External refs from a store outside the cluster; credentials are the keys of the Secret named by
+// the ref, already decoded from base64
+type Provider interface {
+	Read(ctx context.Context, ref resourcesv1alpha1.ExternalRef, credentials map[string]string) (map[string]any, error)
+}
+
+var (
+	externalClient = &http.Client{Timeout: ExternalRefTimeout, CheckRedirect: checkExternalRedirect}
+
+	providersMu sync.RWMutex
+	providers   = map[resourcesv1alpha1.ExternalRefProvider]Provider{
+		resourcesv1alpha1.ExternalRefVault:             &VaultProvider{Client: externalClient},
+		resourcesv1alpha1.ExternalRefAWSParameterStore: &AWSParameterStoreProvider{Client: externalClient},
+		resourcesv1alpha1.ExternalRefAWSSecretsManager: &AWSSecretsManagerProvider{Client: externalClient},
+	}
+
+	externalCache = newExternalRefCache()
+
+	// externalAddresses are the addresses External refs may read from; the default endpoints of AWS are always allowed
+	externalAddresses []*url.URL
+)
+
+// SetExternalAddresses allows External refs to read from the addresses, like https://vault.example.com; a ref reads
+// from an address when its scheme and host match one of them. Without any, only the default endpoints of AWS are
+// allowed.
+func SetExternalAddresses(addresses []string) error {
+	allowed := make([]*url.URL, 0, len(addresses))
+	for _, address := range addresses {
+		parsed, err := url.Parse(strings.TrimSpace(address))
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("invalid address of external refs %q; it must be an URL, like https://vault.example.com", address)
+		}
+		allowed = append(allowed, parsed)
+	}
+	externalAddresses = allowed
+	return nil
+}
+
+func isAllowedAddress(address string) bool {
+	parsed, err := url.Parse(address)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(externalAddresses, func(allowed *url.URL) bool {
+		return strings.EqualFold(allowed.Scheme, parsed.Scheme) && strings.EqualFold(allowed.Host, parsed.Host)
+	})
+}
+
+// checkExternalRedirect only follows redirects that stay in the scheme and host of the first request, or that go to an
+// address allowed by SetExternalAddresses; the credentials of the request must not reach anywhere else
+func checkExternalRedirect(request *http.Request, via []*http.Request) error {
+	if len(via) >= maxExternalRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxExternalRedirects)
+	}
+	first := via[0].URL
+	if strings.EqualFold(first.Scheme, request.URL.Scheme) && strings.EqualFold(first.Host, request.URL.Host) {
+		return nil
+	}
+	if isAllowedAddress(request.URL.String()) {
+		return nil
+	}
+	return fmt.Errorf("refusing the redirect to %s; the address isn't allowed by the operator", request.URL.Redacted())
+}
+
+// readBody reads the response of an external store, up to maxExternalResponseSize
+func readBody(response *http.Response) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxExternalResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxExternalResponseSize {
+		return nil, fmt.Errorf("the response is larger than %d bytes", maxExternalResponseSize)
+	}
+	return body, nil
+}
+
+// RegisterProvider makes a Provider available to External refs, replacing the current one with the same name
+func RegisterProvider(name resourcesv1alpha1.ExternalRefProvider, provider Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = provider
+}
+
+func providerOf(name resourcesv1alpha1.ExternalRefProvider) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	provider, ok := providers[name]
+	return provider, ok
+}
+
+// readExternal reads an External ref of a deployment in the namespace through its provider, or from the cache while
+// the values are fresh. Values are cached by the store location and the version of the credentials, so rotated
+// credentials are used right away. Credentials are only read from the namespace, and addresses must be allowed by
+// SetExternalAddresses.
+func readExternal(ctx context.Context, c client.Client, namespace string, ref resourcesv1alpha1.ResourceGroupRef) (map[string]any, error) {
+	external := ref.External
+	if external == nil {
+		return nil, fmt.Errorf("external ref %s must describe where it's read from in external", ref.Name)
+	}
+
+	provider, ok := providerOf(external.Provider)
+	if !ok {
+		return nil, fmt.Errorf("external ref %s has an unknown provider %s", ref.Name, external.Provider)
+	}
+
+	if ref.Namespace != "" && ref.Namespace != namespace {
+		return nil, fmt.Errorf("the credentials of external ref %s must be in namespace %s, the one of the deployment", ref.Name, namespace)
+	}
+	if external.Address != "" && !isAllowedAddress(external.Address) {
+		return nil, fmt.Errorf("external ref %s can't read from %s; the address isn't allowed by the operator", ref.Name, external.Address)
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: external.CredentialsSecret}, secret); err != nil {
+		return nil, fmt.Errorf("unable to find the credentials of external ref %s in namespace %s: %w", ref.Name, namespace, err)
+	}
+
+	key := fmt.Sprintf("%s|%s|%s|%s|%s/%s@%s", external.Provider, external.Address, external.Region, external.Path,
+		secret.Namespace, secret.Name, secret.ResourceVersion)
+
+	if values, ok := externalCache.get(key); ok {
+		return values, nil
+	}
+
+	credentials := make(map[string]string)
+	for name, value := range secret.Data {
+		credentials[name] = string(value)
+	}
+	maps.Copy(credentials, secret.StringData)
+
+	values, err := provider.Read(ctx, *external, credentials)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read external ref %s from %s: %w", ref.Name, external.Provider, err)
+	}
+
+	ttl := DefaultExternalRefTTL
+	if external.TTL != nil {
+		ttl = external.TTL.Duration
+	}
+	externalCache.put(key, values, ttl)
+
+	return values, nil
+}
+
+type externalRefCacheEntry struct {
+	values    map[string]any
+	expiresAt time.Time
+}
+
+// externalRefCache keeps the values read from external stores, so they aren't read again on every reconciliation
+type externalRefCache struct {
+	mu      sync.Mutex
+	entries map[string]externalRefCacheEntry
+	now     func() time.Time
+}
+
+func newExternalRefCache() *externalRefCache {
+	return &externalRefCache{entries: make(map[string]externalRefCacheEntry), now: time.Now}
+}
+
+func (c *externalRefCache) get(key string) (map[string]any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return maps.Clone(entry.values), true
+}
+
+func (c *externalRefCache) put(key string, values map[string]any, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// expired entries are dropped here too, so values of refs no longer read don't stay around
+	now := c.now()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+
+	if ttl <= 0 {
+		return
+	}
+	c.entries[key] = externalRefCacheEntry{values: maps.Clone(values), expiresAt: now.Add(ttl)}
+}
@@ -0,0 +1,260 @@
+package refs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_ExternalRefs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	vaultCredentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "vault-credentials", Namespace: "checkout"},
+		Data:       map[string][]byte{"token": []byte("vault-token")},
+	}
+	awsCredentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws-credentials", Namespace: "checkout"},
+		Data:       map[string][]byte{"accessKeyId": []byte("AKID"), "secretAccessKey": []byte("s3cr3t")},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vaultCredentials, awsCredentials).Build()
+
+	ctx := context.TODO()
+
+	t.Run("We should be able to read a KV v2 secret from Vault", func(t *testing.T) {
+		reads := 0
+		vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reads++
+			assert.Equal(t, "/v1/secret/data/checkout", r.URL.Path)
+			assert.Equal(t, "vault-token", r.Header.Get("X-Vault-Token"))
+			w.Write([]byte(`{"data":{"data":{"password":"s3cr3t"},"metadata":{"version":1}}}`))
+		}))
+		defer vault.Close()
+
+		allowAddresses(t, vault.URL)
+
+		ref := resourcesv1alpha1.ResourceGroupRef{
+			Name: "database", Kind: resourcesv1alpha1.ResourceGroupRefExternal, Namespace: "checkout",
+			External: &resourcesv1alpha1.ExternalRef{
+				Provider:          resourcesv1alpha1.ExternalRefVault,
+				Address:           vault.URL,
+				Path:              "secret/data/checkout",
+				CredentialsSecret: "vault-credentials",
+			},
+		}
+
+		secrets, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{ref})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]any{"database": map[string]any{"password": "s3cr3t"}}, secrets)
+
+		// cached, so vault isn't called again
+		_, err = Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{ref})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, reads)
+	})
+
+	t.Run("We should be able to read a parameter from AWS Parameter Store with a signed request", func(t *testing.T) {
+		ssm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "AmazonSSM.GetParameter", r.Header.Get("X-Amz-Target"))
+			assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+
+			request := make(map[string]any)
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			assert.Equal(t, "/checkout/database/host", request["Name"])
+
+			w.Write([]byte(`{"Parameter":{"Value":"checkout.rds"}}`))
+		}))
+		defer ssm.Close()
+
+		allowAddresses(t, ssm.URL)
+
+		ref := resourcesv1alpha1.ResourceGroupRef{
+			Name: "host", Kind: resourcesv1alpha1.ResourceGroupRefExternal, Namespace: "checkout",
+			External: &resourcesv1alpha1.ExternalRef{
+				Provider:          resourcesv1alpha1.ExternalRefAWSParameterStore,
+				Address:           ssm.URL,
+				Region:            "us-east-1",
+				Path:              "/checkout/database/host",
+				CredentialsSecret: "aws-credentials",
+			},
+		}
+
+		secrets, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{ref})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]any{"host": map[string]any{"value": "checkout.rds"}}, secrets)
+	})
+
+	t.Run("We should be able to read a JSON secret from AWS Secrets Manager key by key", func(t *testing.T) {
+		secretsManager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+			w.Write([]byte(`{"SecretString":"{\"username\":\"checkout\",\"password\":\"s3cr3t\"}"}`))
+		}))
+		defer secretsManager.Close()
+
+		allowAddresses(t, secretsManager.URL)
+
+		ref := resourcesv1alpha1.ResourceGroupRef{
+			Name: "database", Kind: resourcesv1alpha1.ResourceGroupRefExternal, Namespace: "checkout",
+			External: &resourcesv1alpha1.ExternalRef{
+				Provider:          resourcesv1alpha1.ExternalRefAWSSecretsManager,
+				Address:           secretsManager.URL,
+				Region:            "us-east-1",
+				Path:              "checkout/database",
+				CredentialsSecret: "aws-credentials",
+			},
+		}
+
+		secrets, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{ref})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]any{"database": map[string]any{"username": "checkout", "password": "s3cr3t"}}, secrets)
+	})
+
+	t.Run("We should not read from addresses not allowed by the operator", func(t *testing.T) {
+		reads := 0
+		vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reads++
+		}))
+		defer vault.Close()
+
+		ref := resourcesv1alpha1.ResourceGroupRef{
+			Name: "database", Kind: resourcesv1alpha1.ResourceGroupRefExternal, Namespace: "checkout",
+			External: &resourcesv1alpha1.ExternalRef{
+				Provider:          resourcesv1alpha1.ExternalRefVault,
+				Address:           vault.URL,
+				Path:              "secret/data/checkout",
+				CredentialsSecret: "vault-credentials",
+			},
+		}
+
+		_, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{ref})
+		assert.ErrorContains(t, err, "the address isn't allowed by the operator")
+		assert.Equal(t, 0, reads)
+	})
+
+	t.Run("We should not follow redirects to addresses not allowed by the operator", func(t *testing.T) {
+		reads := 0
+		elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reads++
+		}))
+		defer elsewhere.Close()
+
+		vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, elsewhere.URL+r.URL.Path, http.StatusTemporaryRedirect)
+		}))
+		defer vault.Close()
+
+		allowAddresses(t, vault.URL)
+
+		ref := resourcesv1alpha1.ResourceGroupRef{
+			Name: "redirected", Kind: resourcesv1alpha1.ResourceGroupRefExternal, Namespace: "checkout",
+			External: &resourcesv1alpha1.ExternalRef{
+				Provider:          resourcesv1alpha1.ExternalRefVault,
+				Address:           vault.URL,
+				Path:              "secret/data/redirected",
+				CredentialsSecret: "vault-credentials",
+			},
+		}
+
+		_, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{ref})
+		assert.ErrorContains(t, err, "the address isn't allowed by the operator")
+		assert.Equal(t, 0, reads)
+	})
+
+	t.Run("We should reject an AWS region that isn't a region", func(t *testing.T) {
+		ref := resourcesv1alpha1.ResourceGroupRef{
+			Name: "region", Kind: resourcesv1alpha1.ResourceGroupRefExternal, Namespace: "checkout",
+			External: &resourcesv1alpha1.ExternalRef{
+				Provider:          resourcesv1alpha1.ExternalRefAWSSecretsManager,
+				Region:            "attacker.example/x#",
+				Path:              "checkout/database",
+				CredentialsSecret: "aws-credentials",
+			},
+		}
+
+		_, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{ref})
+		assert.ErrorContains(t, err, "invalid region")
+	})
+
+	t.Run("We should only read credentials from the namespace of the deployment", func(t *testing.T) {
+		ref := resourcesv1alpha1.ResourceGroupRef{
+			Name: "database", Kind: resourcesv1alpha1.ResourceGroupRefExternal, Namespace: "checkout",
+			External: &resourcesv1alpha1.ExternalRef{
+				Provider:          resourcesv1alpha1.ExternalRefAWSSecretsManager,
+				Region:            "us-east-1",
+				Path:              "checkout/database",
+				CredentialsSecret: "aws-credentials",
+			},
+		}
+
+		_, err := Secrets(ctx, c, "payments", []resourcesv1alpha1.ResourceGroupRef{ref})
+		assert.ErrorContains(t, err, "must be in namespace payments")
+	})
+
+	t.Run("We should reject responses too large to be secrets", func(t *testing.T) {
+		vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"data":{"password":"` + strings.Repeat("x", maxExternalResponseSize) + `"}}`))
+		}))
+		defer vault.Close()
+
+		allowAddresses(t, vault.URL)
+
+		ref := resourcesv1alpha1.ResourceGroupRef{
+			Name: "large", Kind: resourcesv1alpha1.ResourceGroupRefExternal, Namespace: "checkout",
+			External: &resourcesv1alpha1.ExternalRef{
+				Provider:          resourcesv1alpha1.ExternalRefVault,
+				Address:           vault.URL,
+				Path:              "secret/data/large",
+				CredentialsSecret: "vault-credentials",
+			},
+		}
+
+		_, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{ref})
+		assert.ErrorContains(t, err, "the response is larger than")
+	})
+
+	t.Run("We should fail when the provider is unknown", func(t *testing.T) {
+		ref := resourcesv1alpha1.ResourceGroupRef{
+			Name: "unknown", Kind: resourcesv1alpha1.ResourceGroupRefExternal, Namespace: "checkout",
+			External: &resourcesv1alpha1.ExternalRef{Provider: "Consul", Path: "checkout", CredentialsSecret: "vault-credentials"},
+		}
+
+		_, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{ref})
+		assert.Error(t, err)
+	})
+
+	t.Run("Cached values should expire after their TTL", func(t *testing.T) {
+		now := time.Now()
+		cache := newExternalRefCache()
+		cache.now = func() time.Time { return now }
+
+		cache.put("key", map[string]any{"value": "sample"}, time.Minute)
+
+		values, ok := cache.get("key")
+		assert.True(t, ok)
+		assert.Equal(t, map[string]any{"value": "sample"}, values)
+
+		now = now.Add(time.Minute)
+
+		_, ok = cache.get("key")
+		assert.False(t, ok)
+	})
+}
+
+// allowAddresses allows External refs to read from the addresses until the end of the test
+func allowAddresses(t *testing.T, addresses ...string) {
+	assert.NoError(t, SetExternalAddresses(addresses))
+	t.Cleanup(func() { _ = SetExternalAddresses(nil) })
+}
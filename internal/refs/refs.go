@@ -1,11 +1,16 @@
 package refs
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
 	"iter"
+	"slices"
+	"strings"
 
 	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
@@ -35,11 +40,20 @@ type ReferenceObject interface{}
 type ReferenceValue any
 
 func (r *References) NewReference(ctx context.Context, client client.Client, ref resourcesv1alpha1.ResourceGroupRef) (ReferenceObject, error) {
-	unknown := &unstructured.Unstructured{}
+	if IsSecret(ref) {
+		return nil, fmt.Errorf("ref %s is a Secret; it must be read with Secrets, so it isn't kept with the other refs", ref.Name)
+	}
+
 	groupVersion, err := schema.ParseGroupVersion(ref.ApiVersion)
 	if err != nil {
 		return nil, err
 	}
+
+	if ref.Selector != nil {
+		return r.newListReference(ctx, client, groupVersion, ref)
+	}
+
+	unknown := &unstructured.Unstructured{}
 	unknown.SetGroupVersionKind(groupVersion.WithKind(string(ref.Kind)))
 
 	objectKey := types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}
@@ -54,3 +68,67 @@ func (r *References) NewReference(ctx context.Context, client client.Client, ref
 
 	return value, nil
 }
+
+// newListReference reads every object matching the selector of the ref, ordered by name
+func (r *References) newListReference(ctx context.Context, c client.Client, groupVersion schema.GroupVersion, ref resourcesv1alpha1.ResourceGroupRef) (ReferenceObject, error) {
+	selector, err := metav1.LabelSelectorAsSelector(ref.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of ref %s: %w", ref.Name, err)
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(groupVersion.WithKind(string(ref.Kind) + "List"))
+
+	options := []client.ListOption{client.MatchingLabelsSelector{Selector: selector}}
+	if ref.Namespace != "" {
+		options = append(options, client.InNamespace(ref.Namespace))
+	}
+
+	if err := c.List(ctx, list, options...); err != nil {
+		return nil, fmt.Errorf("unable to list refs %s from kind %s in namespace %s: %w", ref.Name, ref.Kind, ref.Namespace, err)
+	}
+
+	slices.SortFunc(list.Items, func(a, b unstructured.Unstructured) int {
+		return cmp.Or(strings.Compare(a.GetNamespace(), b.GetNamespace()), strings.Compare(a.GetName(), b.GetName()))
+	})
+
+	objects := make([]any, 0, len(list.Items))
+	for _, item := range list.Items {
+		objects = append(objects, item.Object)
+	}
+
+	value := ReferenceValue(objects)
+
+	r.all[ref.Name] = value
+
+	return value, nil
+}
+
+// Snapshot serializes all resolved references, so they can be reused later without reading them from the cluster again
+func (r *References) Snapshot() ([]byte, error) {
+	snapshot := make(map[string]ReferenceObject)
+	for name, value := range r.all {
+		// managed fields are noise; there is no reason to keep them
+		switch object := value.(type) {
+		case map[string]any:
+			unstructured.RemoveNestedField(object, "metadata", "managedFields")
+		case []any:
+			for _, item := range object {
+				if itemObject, ok := item.(map[string]any); ok {
+					unstructured.RemoveNestedField(itemObject, "metadata", "managedFields")
+				}
+			}
+		}
+		snapshot[name] = value
+	}
+	return json.Marshal(snapshot)
+}
+
+// NewReferencesFromSnapshot restores references previously serialized by Snapshot
+func NewReferencesFromSnapshot(snapshot []byte) (*References, error) {
+	all := make(map[string]ReferenceObject)
+	if err := json.Unmarshal(snapshot, &all); err != nil {
+		return nil, err
+	}
+	return &References{all: all}, nil
+}
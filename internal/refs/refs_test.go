@@ -0,0 +1,109 @@
+package refs
+
+import (
+	"context"
+	"testing"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_References(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	owner := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "checkout"},
+		Data:       map[string]string{"team": "payments"},
+	}
+
+	newFeatureFlags := func(name string, region string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "checkout", Labels: map[string]string{"klaudio/feature-flags": "true"}},
+			Data:       map[string]string{"region": region},
+		}
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(owner, newFeatureFlags("flags-us", "us-east-1"), newFeatureFlags("flags-br", "sa-east-1")).
+		Build()
+
+	ctx := context.TODO()
+
+	t.Run("We should be able to read a ConfigMap ref", func(t *testing.T) {
+		references := NewReferences()
+
+		_, err := references.NewReference(ctx, c, resourcesv1alpha1.ResourceGroupRef{Name: "owner", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefConfigMap, Namespace: "checkout"})
+		assert.NoError(t, err)
+
+		all := make(map[string]ReferenceObject)
+		for name, value := range references.All() {
+			all[name] = value
+		}
+		assert.Equal(t, "payments", all["owner"].(map[string]any)["data"].(map[string]any)["team"])
+
+		t.Run("...and restore it from a snapshot", func(t *testing.T) {
+			snapshot, err := references.Snapshot()
+			assert.NoError(t, err)
+
+			restored, err := NewReferencesFromSnapshot(snapshot)
+			assert.NoError(t, err)
+
+			for name, value := range restored.All() {
+				assert.Equal(t, "owner", name)
+				assert.Equal(t, "payments", value.(map[string]any)["data"].(map[string]any)["team"])
+			}
+		})
+	})
+
+	t.Run("We should be able to read every object matching a selector, ordered by name", func(t *testing.T) {
+		references := NewReferences()
+
+		value, err := references.NewReference(ctx, c, resourcesv1alpha1.ResourceGroupRef{
+			Name:       "flags",
+			ApiVersion: "v1",
+			Kind:       resourcesv1alpha1.ResourceGroupRefConfigMap,
+			Namespace:  "checkout",
+			Selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"klaudio/feature-flags": "true"}},
+		})
+		assert.NoError(t, err)
+
+		objects, ok := value.([]any)
+		if assert.True(t, ok) && assert.Len(t, objects, 2) {
+			assert.Equal(t, "sa-east-1", objects[0].(map[string]any)["data"].(map[string]any)["region"])
+			assert.Equal(t, "us-east-1", objects[1].(map[string]any)["data"].(map[string]any)["region"])
+		}
+
+		t.Run("...and get an empty list when nothing matches", func(t *testing.T) {
+			value, err := references.NewReference(ctx, c, resourcesv1alpha1.ResourceGroupRef{
+				Name:       "none",
+				ApiVersion: "v1",
+				Kind:       resourcesv1alpha1.ResourceGroupRefConfigMap,
+				Namespace:  "checkout",
+				Selector:   &metav1.LabelSelector{MatchLabels: map[string]string{"klaudio/unknown": "true"}},
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []any{}, value)
+		})
+	})
+
+	t.Run("We should fail when the ref doesn't exist", func(t *testing.T) {
+		_, err := NewReferences().NewReference(ctx, c, resourcesv1alpha1.ResourceGroupRef{Name: "unknown", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefConfigMap, Namespace: "checkout"})
+		assert.Error(t, err)
+	})
+
+	t.Run("We should not keep Secret refs with the other ones", func(t *testing.T) {
+		references := NewReferences()
+
+		_, err := references.NewReference(ctx, c, resourcesv1alpha1.ResourceGroupRef{Name: "credentials", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefSecret, Namespace: "checkout"})
+		assert.Error(t, err)
+
+		snapshot, err := references.Snapshot()
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{}`, string(snapshot))
+	})
+}
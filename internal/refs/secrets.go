@@ -0,0 +1,86 @@
+package refs
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// IsSecret tells refs read through Secrets apart from the ones kept by References
+func IsSecret(ref resourcesv1alpha1.ResourceGroupRef) bool {
+	return ref.Kind == resourcesv1alpha1.ResourceGroupRefSecret || ref.Kind == resourcesv1alpha1.ResourceGroupRefExternal
+}
+
+// Secrets reads the Secret and External refs of a deployment in the namespace as secrets.<name>.<key>. Unlike other
+// refs, they are never frozen in a Snapshot: they are read when properties are rendered, so their values don't end up
+// in the deployment status. Secret refs default to the namespace; a Secret from another one must be shared with it.
+func Secrets(ctx context.Context, c client.Client, namespace string, refs []resourcesv1alpha1.ResourceGroupRef) (map[string]any, error) {
+	secrets := make(map[string]any)
+
+	for _, ref := range refs {
+		if !IsSecret(ref) {
+			continue
+		}
+
+		if ref.Kind == resourcesv1alpha1.ResourceGroupRefExternal {
+			values, err := readExternal(ctx, c, namespace, ref)
+			if err != nil {
+				return nil, err
+			}
+			secrets[ref.Name] = values
+			continue
+		}
+
+		if ref.Selector != nil {
+			return nil, fmt.Errorf("secret ref %s can't have a selector; secrets must be referenced one by one", ref.Name)
+		}
+		if ref.ApiVersion != "" && ref.ApiVersion != corev1.SchemeGroupVersion.String() {
+			return nil, fmt.Errorf("secret ref %s must have apiVersion %s, got %s", ref.Name, corev1.SchemeGroupVersion.String(), ref.ApiVersion)
+		}
+
+		secretNamespace := cmp.Or(ref.Namespace, namespace)
+
+		// typed, so the values come decoded from base64
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: secretNamespace, Name: ref.Name}, secret); err != nil {
+			return nil, fmt.Errorf("unable to find a secret ref %s in namespace %s: %w", ref.Name, secretNamespace, err)
+		}
+
+		if !isSharedWith(secret, namespace) {
+			return nil, fmt.Errorf("secret ref %s, in namespace %s, isn't shared with namespace %s; the Secret must list it in the annotation %s", ref.Name, secretNamespace, namespace, resourcesv1alpha1.SecretSharedWithAnnotation)
+		}
+
+		data := make(map[string]any)
+		for key, value := range secret.Data {
+			data[key] = string(value)
+		}
+		for key, value := range secret.StringData {
+			data[key] = value
+		}
+
+		secrets[ref.Name] = data
+	}
+
+	return secrets, nil
+}
+
+// isSharedWith tells whether deployments of the namespace may read the Secret: it's in the same namespace, or its
+// annotation shares it
+func isSharedWith(secret *corev1.Secret, namespace string) bool {
+	if secret.Namespace == namespace {
+		return true
+	}
+
+	for _, shared := range strings.Split(secret.Annotations[resourcesv1alpha1.SecretSharedWithAnnotation], ",") {
+		if shared = strings.TrimSpace(shared); shared == "*" || shared == namespace {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package refs
+
+import (
+	"context"
+	"testing"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_Secrets(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+
+	credentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "checkout"},
+		Data:       map[string][]byte{"password": []byte("s3cr3t")},
+	}
+	shared := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "shared",
+			Namespace:   "platform",
+			Annotations: map[string]string{resourcesv1alpha1.SecretSharedWithAnnotation: "payments, checkout"},
+		},
+		Data: map[string][]byte{"token": []byte("t0k3n")},
+	}
+	private := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "private", Namespace: "platform"},
+		Data:       map[string][]byte{"token": []byte("t0k3n")},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(credentials, shared, private).Build()
+
+	ctx := context.TODO()
+
+	secretRef := resourcesv1alpha1.ResourceGroupRef{Name: "credentials", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefSecret, Namespace: "checkout"}
+
+	t.Run("We should be able to read the decoded values of Secret refs", func(t *testing.T) {
+		configMapRef := resourcesv1alpha1.ResourceGroupRef{Name: "owner", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefConfigMap, Namespace: "checkout"}
+
+		secrets, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{secretRef, configMapRef})
+		assert.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"credentials": map[string]any{"password": "s3cr3t"}}, secrets)
+	})
+
+	t.Run("We should read Secret refs from the namespace of the deployment by default", func(t *testing.T) {
+		withoutNamespace := secretRef
+		withoutNamespace.Namespace = ""
+
+		secrets, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{withoutNamespace})
+		assert.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"credentials": map[string]any{"password": "s3cr3t"}}, secrets)
+	})
+
+	t.Run("We should be able to read Secrets from other namespaces, when they're shared with the deployment", func(t *testing.T) {
+		sharedRef := resourcesv1alpha1.ResourceGroupRef{Name: "shared", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefSecret, Namespace: "platform"}
+
+		secrets, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{sharedRef})
+		assert.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"shared": map[string]any{"token": "t0k3n"}}, secrets)
+	})
+
+	t.Run("We should reject Secrets from other namespaces not shared with the deployment", func(t *testing.T) {
+		privateRef := resourcesv1alpha1.ResourceGroupRef{Name: "private", ApiVersion: "v1", Kind: resourcesv1alpha1.ResourceGroupRefSecret, Namespace: "platform"}
+
+		_, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{privateRef})
+		assert.ErrorContains(t, err, "isn't shared with namespace checkout")
+	})
+
+	t.Run("We should fail when the Secret doesn't exist", func(t *testing.T) {
+		unknown := secretRef
+		unknown.Name = "unknown"
+
+		_, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{unknown})
+		assert.Error(t, err)
+	})
+
+	t.Run("We should reject Secret refs with another apiVersion", func(t *testing.T) {
+		invalid := secretRef
+		invalid.ApiVersion = "v2"
+
+		_, err := Secrets(ctx, c, "checkout", []resourcesv1alpha1.ResourceGroupRef{invalid})
+		assert.Error(t, err)
+	})
+}
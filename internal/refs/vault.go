@@ -0,0 +1,67 @@
+package refs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// VaultProvider reads secrets from the HTTP API of HashiCorp Vault, authenticated by the token key of the credentials
+type VaultProvider struct {
+	Client *http.Client
+}
+
+func (p *VaultProvider) Read(ctx context.Context, ref resourcesv1alpha1.ExternalRef, credentials map[string]string) (map[string]any, error) {
+	if ref.Address == "" {
+		return nil, fmt.Errorf("the address of the Vault server is required")
+	}
+
+	token, ok := credentials["token"]
+	if !ok {
+		return nil, fmt.Errorf("credentials to Vault must have a token key")
+	}
+
+	url := fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(ref.Address, "/"), strings.TrimPrefix(ref.Path, "/"))
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Vault-Token", token)
+
+	response, err := p.Client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := readBody(response)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault responded %s to %s: %s", response.Status, ref.Path, strings.TrimSpace(string(body)))
+	}
+
+	secret := struct {
+		Data map[string]any `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return nil, fmt.Errorf("unable to read the response of vault to %s: %w", ref.Path, err)
+	}
+
+	// KV v2 wraps the values in data.data, next to their metadata
+	values := secret.Data
+	if data, ok := values["data"].(map[string]any); ok {
+		if _, ok := values["metadata"]; ok {
+			values = data
+		}
+	}
+
+	return values, nil
+}
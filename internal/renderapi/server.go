@@ -0,0 +1,127 @@
+package renderapi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/changeset"
+	"github.com/nubank/klaudio/internal/httpserver"
+)
+
+// maxRequestSize limits the parameter overrides sent to a render
+const maxRequestSize = 1 << 20
+
+// RenderRequest overrides the parameters of the deployment being rendered; it can be empty
+type RenderRequest struct {
+	Parameters *runtime.RawExtension `json:"parameters,omitempty"`
+}
+
+// RenderResponse lists the Resources of the deployment as they would be applied, and what would change in them
+type RenderResponse struct {
+	Resources []resourcesv1alpha1.ResourceGroupDeploymentPlannedResource `json:"resources"`
+}
+
+// Server renders the resources of a ResourceGroupDeployment on demand, at
+// POST /namespaces/{namespace}/resourcegroupdeployments/{name}/render, so UIs can preview changes to parameters
+// interactively. Nothing is persisted: refs are read again, subnets are only previewed, and values read from Secret
+// refs are redacted. The resource query parameter, repeatable, restricts the response to some resources. The API
+// is served over TLS, to clients allowed to post to the render path as a non-resource URL by the Filter.
+type Server struct {
+	Addr    string
+	Client  client.Client
+	Filter  metricsserver.Filter
+	TLSOpts []func(*tls.Config)
+}
+
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	server := &httpserver.Server{Name: "render-api", Addr: s.Addr, Handler: s.Handler(), Filter: s.Filter, TLSOpts: s.TLSOpts}
+	return server.Start(ctx)
+}
+
+// Handler serves the renders of each ResourceGroupDeployment
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /namespaces/{namespace}/resourcegroupdeployments/{name}/render", s.serveRender)
+	return mux
+}
+
+func (s *Server) serveRender(w http.ResponseWriter, r *http.Request) {
+	request := &RenderRequest{}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(body) != 0 {
+		if err := json.Unmarshal(body, request); err != nil {
+			http.Error(w, fmt.Sprintf("invalid render request: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	deployment := &resourcesv1alpha1.ResourceGroupDeployment{}
+	if err := s.Client.Get(r.Context(), types.NamespacedName{Namespace: r.PathValue("namespace"), Name: r.PathValue("name")}, deployment); err != nil {
+		status := http.StatusInternalServerError
+		if apierrors.IsNotFound(err) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	rendered, err := Render(r.Context(), s.Client, deployment, request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if selected := r.URL.Query()["resource"]; len(selected) != 0 {
+		rendered.Resources = slices.DeleteFunc(rendered.Resources, func(resource resourcesv1alpha1.ResourceGroupDeploymentPlannedResource) bool {
+			return !slices.ContainsFunc(selected, func(name string) bool {
+				return resource.Name == name || resource.Name == fmt.Sprintf("%s.%s", deployment.Name, name)
+			})
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rendered); err != nil {
+		log.FromContext(r.Context()).Error(err, "unable to write the render response")
+	}
+}
+
+// Render expands the resources of a deployment with the overridden parameters, comparing them with the deployed
+// Resources; parameters that aren't overridden keep the values of the deployment
+func Render(ctx context.Context, c client.Client, deployment *resourcesv1alpha1.ResourceGroupDeployment, request *RenderRequest) (*RenderResponse, error) {
+	group := &resourcesv1alpha1.ResourceGroupSpec{
+		Parameters:         request.Parameters,
+		Resources:          deployment.Spec.Resources,
+		DriftPolicy:        deployment.Spec.DriftPolicy,
+		ExpressionLanguage: deployment.Spec.ExpressionLanguage,
+	}
+
+	changes, err := changeset.OfDeployment(ctx, c, group, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RenderResponse{Resources: changes}, nil
+}
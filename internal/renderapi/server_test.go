@@ -0,0 +1,103 @@
+package renderapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_Server(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+
+	newResourceRef := func(name string) *resourcesv1alpha1.ResourceRef {
+		return &resourcesv1alpha1.ResourceRef{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       resourcesv1alpha1.ResourceRefSpec{Schema: resourcesv1alpha1.ResourceRefSchema{Type: "object"}},
+		}
+	}
+
+	deployment := &resourcesv1alpha1.ResourceGroupDeployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "checkout.prod", Namespace: "checkout"},
+		Spec: resourcesv1alpha1.ResourceGroupDeploymentSpec{
+			Placement:  "prod",
+			Parameters: &runtime.RawExtension{Raw: []byte(`{"size":10,"engine":"postgres"}`)},
+			Resources: []resourcesv1alpha1.ResourceGroupElement{
+				{Name: "database", ResourceRef: "database", Properties: &runtime.RawExtension{Raw: []byte(`{"size":"${parameters.size}","engine":"${parameters.engine}"}`)}},
+				{Name: "bucket", ResourceRef: "bucket", Properties: &runtime.RawExtension{Raw: []byte(`{"name":"checkout-${parameters.engine}"}`)}},
+			},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(newResourceRef("database"), newResourceRef("bucket"), deployment).
+		Build()
+
+	server := httptest.NewServer((&Server{Client: c}).Handler())
+	defer server.Close()
+
+	render := func(path string, body string) (*http.Response, *RenderResponse) {
+		response, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		if !assert.NoError(t, err) {
+			return nil, nil
+		}
+		defer response.Body.Close()
+
+		rendered := &RenderResponse{}
+		if response.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(response.Body).Decode(rendered))
+		}
+		return response, rendered
+	}
+
+	t.Run("We should render the resources with the overridden parameters", func(t *testing.T) {
+		response, rendered := render("/namespaces/checkout/resourcegroupdeployments/checkout.prod/render", `{"parameters":{"size":20}}`)
+
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Len(t, rendered.Resources, 2)
+		for _, resource := range rendered.Resources {
+			assert.Equal(t, resourcesv1alpha1.PlanActionCreate, resource.Action)
+			if resource.Name == "checkout.prod.database" {
+				assert.JSONEq(t, `{"size":20,"engine":"postgres"}`, string(resource.Spec.Properties.Raw))
+			}
+		}
+
+		t.Run("...and nothing should be persisted", func(t *testing.T) {
+			resources := &resourcesv1alpha1.ResourceList{}
+			assert.NoError(t, c.List(context.TODO(), resources))
+			assert.Empty(t, resources.Items)
+		})
+	})
+
+	t.Run("We should be able to render only some resources", func(t *testing.T) {
+		response, rendered := render("/namespaces/checkout/resourcegroupdeployments/checkout.prod/render?resource=bucket", "")
+
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		if assert.Len(t, rendered.Resources, 1) {
+			assert.Equal(t, "checkout.prod.bucket", rendered.Resources[0].Name)
+			assert.JSONEq(t, `{"name":"checkout-postgres"}`, string(rendered.Resources[0].Spec.Properties.Raw))
+		}
+	})
+
+	t.Run("We should answer not found to unknown deployments", func(t *testing.T) {
+		response, _ := render("/namespaces/checkout/resourcegroupdeployments/checkout.staging/render", "")
+
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	})
+
+	t.Run("We should reject invalid render requests", func(t *testing.T) {
+		response, _ := render("/namespaces/checkout/resourcegroupdeployments/checkout.prod/render", `{"parameters":`)
+
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	})
+}
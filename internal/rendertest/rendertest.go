@@ -0,0 +1,175 @@
+package rendertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	api "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/expression"
+	"github.com/nubank/klaudio/internal/generated"
+	"github.com/nubank/klaudio/internal/ipam"
+	"github.com/nubank/klaudio/internal/refs"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// Run renders the resources of a ResourceGroup from the fixtures of each test case, without touching the cluster
+func Run(group *api.ResourceGroupSpec, cases []api.ResourceGroupTestCase) []api.ResourceGroupTestResult {
+	results := make([]api.ResourceGroupTestResult, 0, len(cases))
+	for _, testCase := range cases {
+		results = append(results, RunCase(group, testCase))
+	}
+	return results
+}
+
+// RunCase renders the resources of a ResourceGroup from the fixtures of a test case, comparing them with the
+// expected properties
+func RunCase(group *api.ResourceGroupSpec, testCase api.ResourceGroupTestCase) api.ResourceGroupTestResult {
+	result := api.ResourceGroupTestResult{Name: testCase.Name}
+
+	failures, err := runCase(group, testCase)
+	if err != nil {
+		failures = append(failures, err.Error())
+	}
+
+	result.Failures = failures
+	result.Passed = len(failures) == 0
+
+	return result
+}
+
+func runCase(group *api.ResourceGroupSpec, testCase api.ResourceGroupTestCase) ([]string, error) {
+	parameters, err := objectOf(group.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid group parameters: %w", err)
+	}
+	caseParameters, err := objectOf(testCase.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	maps.Copy(parameters, caseParameters)
+
+	references := refs.NewReferences()
+	if testCase.Refs != nil && len(testCase.Refs.Raw) != 0 {
+		references, err = refs.NewReferencesFromSnapshot(testCase.Refs.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid refs: %w", err)
+		}
+	}
+
+	allOutputs, err := objectOf(testCase.Outputs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid outputs: %w", err)
+	}
+
+	expected, err := objectOf(testCase.Expected)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expected properties: %w", err)
+	}
+
+	forEachArgs := resources.NewResourcePropertiesArgs(parameters, references)
+
+	resourceGroup := resources.NewResourceGroup().WithExpressionLanguage(expression.Language(group.ExpressionLanguage))
+	if err := resourceGroup.NewElements(group.Resources, forEachArgs, nil); err != nil {
+		return nil, err
+	}
+
+	dag, err := resourceGroup.Graph()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate a graph from the group resources: %w", err)
+	}
+
+	failures := make([]string, 0)
+
+	// resources are rendered in the same order of a deployment; the outputs fixtures stand for the deployed ones
+	placement := &api.Placement{ObjectMeta: metav1.ObjectMeta{Name: testCase.Name}}
+	if testCase.Placement != nil {
+		placement.Spec = *testCase.Placement
+	}
+	args := resources.NewResourcePropertiesArgs(parameters, references).
+		WithCIDRAllocator(ipam.NewMemoryAllocator(testCase.Name)).
+		WithValueGenerator(generated.NewMemoryGenerator(testCase.Name)).
+		WithPlacement(placement)
+	rendered := make(map[string]map[string]any)
+	err = resourceGroup.Render(dag, args, func(step *resources.RenderStep) (*api.Resource, error) {
+		resource := step.Resource
+		if step.Err != nil {
+			failures = append(failures, fmt.Sprintf("%s: unable to render properties: %s", resource.Name, step.Err.Error()))
+			return nil, nil
+		}
+
+		normalized := make(map[string]any)
+		if err := json.Unmarshal(step.RawProperties, &normalized); err != nil {
+			return nil, err
+		}
+		rendered[resource.Name] = normalized
+
+		deployed := &api.Resource{Spec: api.ResourceSpec{Properties: &runtime.RawExtension{Raw: step.RawProperties}}}
+		if outputs, ok := allOutputs[resource.Name].(map[string]any); ok {
+			if err := deployed.Status.SetOutputs(outputs); err != nil {
+				return nil, fmt.Errorf("invalid outputs from %s: %w", resource.Name, err)
+			}
+		}
+		return deployed, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, resourceName := range slices.Sorted(maps.Keys(expected)) {
+		expectedProperties, ok := expected[resourceName].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected properties from %s must be an object", resourceName)
+		}
+
+		// resources stamped out by a forEach are declared too
+		if _, err := resourceGroup.Get(resourceName); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: resource isn't declared by the group", resourceName))
+			continue
+		}
+
+		renderedProperties, ok := rendered[resourceName]
+		if !ok {
+			// the reason was already reported
+			continue
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(expectedProperties)) {
+			expectedValue := expectedProperties[name]
+
+			renderedValue, ok := renderedProperties[name]
+			switch {
+			case !ok:
+				failures = append(failures, fmt.Sprintf("%s.%s: expected %s, but it wasn't rendered", resourceName, name, asJson(expectedValue)))
+			case !equality.Semantic.DeepEqual(expectedValue, renderedValue):
+				failures = append(failures, fmt.Sprintf("%s.%s: expected %s, got %s", resourceName, name, asJson(expectedValue), asJson(renderedValue)))
+			}
+		}
+	}
+
+	return failures, nil
+}
+
+func objectOf(raw *runtime.RawExtension) (map[string]any, error) {
+	object := make(map[string]any)
+	if raw == nil || len(raw.Raw) == 0 {
+		return object, nil
+	}
+	if err := json.Unmarshal(raw.Raw, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func asJson(value any) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(encoded)
+}
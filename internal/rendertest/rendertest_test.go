@@ -0,0 +1,95 @@
+package rendertest
+
+import (
+	"testing"
+
+	api "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_RunCase(t *testing.T) {
+	raw := func(value string) *runtime.RawExtension {
+		return &runtime.RawExtension{Raw: []byte(value)}
+	}
+
+	group := &api.ResourceGroupSpec{
+		Parameters: raw(`{"env":"staging","size":10}`),
+		Resources: []api.ResourceGroupElement{
+			{Name: "database", ResourceRef: "rds", Properties: raw(`{"name":"checkout-${parameters.env}","size":"${parameters.size}","team":"${refs.owner.data.team}"}`)},
+			{Name: "app", ResourceRef: "service", Properties: raw(`{"endpoint":"${resources.database.status.outputs.endpoint}","replicas":"2"}`)},
+		},
+	}
+
+	refs := raw(`{"owner":{"data":{"team":"payments"}}}`)
+	outputs := raw(`{"database":{"endpoint":"checkout-prod.rds"}}`)
+
+	t.Run("We should be able to render resources from fixtures", func(t *testing.T) {
+		result := RunCase(group, api.ResourceGroupTestCase{
+			Name:       "prod",
+			Parameters: raw(`{"env":"prod"}`),
+			Refs:       refs,
+			Outputs:    outputs,
+			Expected:   raw(`{"database":{"name":"checkout-prod","size":10,"team":"payments"},"app":{"endpoint":"checkout-prod.rds"}}`),
+		})
+
+		assert.True(t, result.Passed, result.Failures)
+		assert.Equal(t, "prod", result.Name)
+		assert.Empty(t, result.Failures)
+	})
+
+	t.Run("We should be able to render resources to a placement", func(t *testing.T) {
+		placementGroup := &api.ResourceGroupSpec{
+			Resources: []api.ResourceGroupElement{
+				{Name: "bucket", ResourceRef: "s3", Properties: raw(`{"name":"checkout-${placement.region}","account":"${placement.account}"}`)},
+			},
+		}
+
+		result := RunCase(placementGroup, api.ResourceGroupTestCase{
+			Name:      "prod-us",
+			Placement: &api.PlacementSpec{Account: "prod", Region: "us-east-1"},
+			Expected:  raw(`{"bucket":{"name":"checkout-us-east-1","account":"prod"}}`),
+		})
+
+		assert.True(t, result.Passed, result.Failures)
+	})
+
+	t.Run("Mismatches should be reported by property", func(t *testing.T) {
+		result := RunCase(group, api.ResourceGroupTestCase{
+			Name:     "regression",
+			Refs:     refs,
+			Outputs:  outputs,
+			Expected: raw(`{"database":{"name":"checkout-prod","zone":"us-east-1a"},"cache":{"size":1}}`),
+		})
+
+		assert.False(t, result.Passed)
+		assert.Equal(t, []string{
+			"cache: resource isn't declared by the group",
+			`database.name: expected "checkout-prod", got "checkout-staging"`,
+			`database.zone: expected "us-east-1a", but it wasn't rendered`,
+		}, result.Failures)
+	})
+
+	t.Run("Expressions that can't be evaluated should fail the case", func(t *testing.T) {
+		result := RunCase(group, api.ResourceGroupTestCase{
+			Name:     "without refs",
+			Outputs:  outputs,
+			Expected: raw(`{"app":{"replicas":"2"}}`),
+		})
+
+		// the app depends on the database, so it can't be rendered either
+		assert.False(t, result.Passed)
+		assert.Contains(t, result.Failures[0], "database: unable to render properties")
+	})
+
+	t.Run("Invalid fixtures should fail the case", func(t *testing.T) {
+		result := RunCase(group, api.ResourceGroupTestCase{
+			Name:     "invalid",
+			Outputs:  raw(`[]`),
+			Expected: raw(`{}`),
+		})
+
+		assert.False(t, result.Passed)
+		assert.Contains(t, result.Failures[0], "invalid outputs")
+	})
+}
@@ -0,0 +1,32 @@
+package resources
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dominikbraun/graph"
+)
+
+// CycleError reports resources depending on each other through their expressions; the path starts and ends with the
+// same resource (e.g. resources.a -> resources.b -> resources.a)
+type CycleError struct {
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle between resources: %s", strings.Join(e.Path, " -> "))
+}
+
+// newCycleError finds the path closed by the edge from source to target: target already reaches source
+func newCycleError(dag graph.Graph[string, string], source string, target string) *CycleError {
+	if source == target {
+		return &CycleError{Path: []string{source, target}}
+	}
+
+	path, err := graph.ShortestPath(dag, target, source)
+	if err != nil {
+		return &CycleError{Path: []string{source, target, source}}
+	}
+
+	return &CycleError{Path: append([]string{source}, path...)}
+}
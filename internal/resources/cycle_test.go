@@ -0,0 +1,57 @@
+package resources
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_ResourcesCycle(t *testing.T) {
+	newResourceGroup := func(resources map[string]string) *ResourceGroup {
+		resourceGroup := NewResourceGroup()
+		for name, properties := range resources {
+			_, err := resourceGroup.NewResource(name, &runtime.RawExtension{Raw: []byte(properties)})
+			assert.NoError(t, err)
+		}
+		return resourceGroup
+	}
+
+	t.Run("We should report the full path of a cycle", func(t *testing.T) {
+		resourceGroup := newResourceGroup(map[string]string{
+			"a": `{"field":"${resources.b.status.outputs.value}"}`,
+			"b": `{"field":"${resources.a.status.outputs.value}"}`,
+		})
+
+		_, err := resourceGroup.Graph()
+
+		var cycle *CycleError
+		assert.True(t, errors.As(err, &cycle))
+		assert.Equal(t, []string{"resources.a", "resources.b", "resources.a"}, cycle.Path)
+		assert.EqualError(t, err, "dependency cycle between resources: resources.a -> resources.b -> resources.a")
+	})
+
+	t.Run("We should report cycles through several resources", func(t *testing.T) {
+		resourceGroup := newResourceGroup(map[string]string{
+			"a": `{"field":"${resources.c.status.outputs.value}"}`,
+			"b": `{"field":"${resources.a.status.outputs.value}"}`,
+			"c": `{"field":"${resources.b.status.outputs.value}"}`,
+			"d": `{"field":"${resources.a.status.outputs.value}"}`,
+		})
+
+		_, err := resourceGroup.DeploymentLevels()
+
+		assert.EqualError(t, err, "dependency cycle between resources: resources.b -> resources.c -> resources.a -> resources.b")
+	})
+
+	t.Run("We should report resources depending on themselves", func(t *testing.T) {
+		resourceGroup := newResourceGroup(map[string]string{
+			"a": `{"field":"${resources.a.status.outputs.value}"}`,
+		})
+
+		_, err := resourceGroup.Graph()
+
+		assert.EqualError(t, err, "dependency cycle between resources: resources.a -> resources.a")
+	})
+}
@@ -0,0 +1,189 @@
+package resources
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	api "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/expression"
+)
+
+var (
+	forEachKeyRe = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+// Each is an item of the forEach of an element, visible to the expressions of the resource stamped out of it as
+// each.key and each.value
+type Each struct {
+	// Element is the name of the element the resource was stamped out of
+	Element string
+	Key     string
+	Value   any
+}
+
+// Name is the name of the resource stamped out to the item; other resources read it as resources["<element>-<key>"]
+func (e Each) Name() string {
+	return fmt.Sprintf("%s-%s", e.Element, e.Key)
+}
+
+// ParseForEach checks the forEach of an element: a single expression that doesn't read other resources, since the
+// items are stamped out before anything is deployed
+func ParseForEach(source string) (expression.Expression, error) {
+	return parseForEach("", source)
+}
+
+// ParseForEach checks the forEach of an element in the expression language of the group
+func (r *ResourceGroup) ParseForEach(source string) (expression.Expression, error) {
+	return parseForEach(r.language, source)
+}
+
+func parseForEach(language expression.Language, source string) (expression.Expression, error) {
+	e, err := expression.ParseWith(language, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if !expression.IsSingle(e) {
+		return nil, fmt.Errorf("forEach must be a single expression, like ${parameters.zones}: %s", source)
+	}
+
+	for _, dependency := range e.Dependencies() {
+		if strings.HasPrefix(dependency, "resources.") {
+			return nil, fmt.Errorf("forEach can't read other resources: %s", source)
+		}
+	}
+
+	return e, nil
+}
+
+// ForEach evaluates the forEach of an element to its items, sorted by key. A list must hold strings or numbers, which
+// are the keys too, so removing an item doesn't rename the resources of the others; a map is keyed by its own keys.
+func ForEach(element string, source string, args *ResourcePropertiesArgs) ([]Each, error) {
+	return forEach("", element, source, args)
+}
+
+func forEach(language expression.Language, element string, source string, args *ResourcePropertiesArgs) ([]Each, error) {
+	e, err := parseForEach(language, source)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := e.Evaluate(args.all)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[string]any)
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			item := v.Index(i).Interface()
+
+			key, err := forEachKeyOf(item)
+			if err != nil {
+				return nil, fmt.Errorf("unable to stamp out item %d of %s: %w", i, element, err)
+			}
+			if _, ok := items[key]; ok {
+				return nil, fmt.Errorf("item %s of %s is duplicated", key, element)
+			}
+			items[key] = item
+		}
+
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			key, ok := k.Interface().(string)
+			if !ok {
+				return nil, fmt.Errorf("keys of the forEach map of %s must be strings, got %v", element, k.Interface())
+			}
+			items[key] = v.MapIndex(k).Interface()
+		}
+
+	default:
+		return nil, fmt.Errorf("forEach of %s must be a list or a map, got %v", element, value)
+	}
+
+	each := make([]Each, 0, len(items))
+	for _, key := range slices.Sorted(maps.Keys(items)) {
+		if !forEachKeyRe.MatchString(key) {
+			return nil, fmt.Errorf("key %s of the forEach of %s isn't a valid DNS label; use a map to choose the keys", key, element)
+		}
+		each = append(each, Each{Element: element, Key: key, Value: items[key]})
+	}
+
+	return each, nil
+}
+
+func forEachKeyOf(item any) (string, error) {
+	switch item := item.(type) {
+	case string:
+		return item, nil
+	case int:
+		return strconv.Itoa(item), nil
+	case int64:
+		// CEL evaluates integers to int64, and unsigned ones to uint64
+		return strconv.FormatInt(item, 10), nil
+	case uint64:
+		return strconv.FormatUint(item, 10), nil
+	case float64:
+		// numbers from parameters are decoded from JSON as float64
+		return strconv.FormatFloat(item, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("items of a list must be strings or numbers, got %v; use a map instead", item)
+	}
+}
+
+// ForEachConflictOf returns the element with a forEach whose stamped out resources could be named like the given
+// one: they're named <element>-<key>, so the name of no other element may start with that prefix, whatever the keys
+// evaluate to
+func ForEachConflictOf(elements []api.ResourceGroupElement, name string) (string, bool) {
+	for _, element := range elements {
+		if element.ForEach != "" && element.Name != name && strings.HasPrefix(name, element.Name+"-") {
+			return element.Name, true
+		}
+	}
+	return "", false
+}
+
+// NewResources registers the resources of an element: one to each item of its forEach, or just one when it has none
+func (r *ResourceGroup) NewResources(element api.ResourceGroupElement, args *ResourcePropertiesArgs) ([]*Resource, error) {
+	if element.ForEach == "" {
+		resource, err := r.NewResource(element.Name, element.Properties)
+		if err != nil {
+			return nil, err
+		}
+		return []*Resource{resource}, nil
+	}
+
+	items, err := forEach(r.language, element.Name, element.ForEach, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to evaluate forEach from resource %s: %w", element.Name, err)
+	}
+
+	stamped := make([]*Resource, 0, len(items))
+	for _, each := range items {
+		resource, err := r.NewResource(each.Name(), element.Properties)
+		if err != nil {
+			return nil, err
+		}
+		resource.Each = &each
+		stamped = append(stamped, resource)
+	}
+
+	return stamped, nil
+}
+
+// ProvisionerObjectNameOf returns the provisioner object name declared to the element of the resource; a resource
+// stamped out by a forEach is suffixed with its key, so each item gets its own object
+func (r *Resource) ProvisionerObjectNameOf(name string) string {
+	if name == "" || r.Each == nil {
+		return name
+	}
+	return fmt.Sprintf("%s-%s", name, r.Each.Key)
+}
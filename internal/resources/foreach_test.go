@@ -0,0 +1,183 @@
+package resources
+
+import (
+	"testing"
+
+	api "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/expression"
+	"github.com/nubank/klaudio/internal/refs"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_ForEach(t *testing.T) {
+
+	args := NewResourcePropertiesArgs(map[string]any{
+		"zones":   []any{"us-east-1b", "us-east-1a"},
+		"shards":  []any{float64(2), float64(1)},
+		"tenants": map[string]any{"acme": map[string]any{"size": "large"}, "globex": map[string]any{"size": "small"}},
+		"cidrs":   []any{"10.0.0.0/24"},
+	}, refs.NewReferences())
+
+	t.Run("We should stamp out one item to each string of a list, keyed by the string", func(t *testing.T) {
+		items, err := ForEach("subnet", "${parameters.zones}", args)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Each{
+			{Element: "subnet", Key: "us-east-1a", Value: "us-east-1a"},
+			{Element: "subnet", Key: "us-east-1b", Value: "us-east-1b"},
+		}, items)
+		assert.Equal(t, "subnet-us-east-1a", items[0].Name())
+	})
+
+	t.Run("We should stamp out one item to each number of a list", func(t *testing.T) {
+		items, err := ForEach("shard", "${parameters.shards}", args)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "shard-1", items[0].Name())
+		assert.Equal(t, "shard-2", items[1].Name())
+	})
+
+	t.Run("We should stamp out one item to each integer of a list, as evaluated by CEL", func(t *testing.T) {
+		items, err := forEach(expression.LanguageCEL, "shard", "${[int(2), int(1)]}", args)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "shard-1", items[0].Name())
+		assert.Equal(t, "shard-2", items[1].Name())
+	})
+
+	t.Run("We should stamp out one item to each entry of a map, keyed by the map keys", func(t *testing.T) {
+		items, err := ForEach("database", "${parameters.tenants}", args)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Each{
+			{Element: "database", Key: "acme", Value: map[string]any{"size": "large"}},
+			{Element: "database", Key: "globex", Value: map[string]any{"size": "small"}},
+		}, items)
+	})
+
+	t.Run("We should reject keys that can't name a resource", func(t *testing.T) {
+		_, err := ForEach("subnet", "${parameters.cidrs}", args)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("We should reject a forEach that isn't a list or a map", func(t *testing.T) {
+		_, err := ForEach("subnet", `${"us-east-1a"}`, args)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("We should reject a forEach reading other resources", func(t *testing.T) {
+		_, err := ParseForEach("${resources.vpc.status.outputs.zones}")
+
+		assert.Error(t, err)
+	})
+}
+
+func Test_ForEachConflictOf(t *testing.T) {
+	elements := []api.ResourceGroupElement{
+		{Name: "subnet", ForEach: "${parameters.zones}"},
+		{Name: "subnet-routes"},
+		{Name: "subnets"},
+		{Name: "vpc"},
+	}
+
+	t.Run("We should find the forEach whose stamped out resources could be named like an element", func(t *testing.T) {
+		conflict, ok := ForEachConflictOf(elements, "subnet-routes")
+
+		assert.True(t, ok)
+		assert.Equal(t, "subnet", conflict)
+	})
+
+	t.Run("We should accept names not prefixed by an element with a forEach", func(t *testing.T) {
+		for _, name := range []string{"subnet", "subnets", "vpc"} {
+			_, ok := ForEachConflictOf(elements, name)
+			assert.False(t, ok, name)
+		}
+	})
+
+	t.Run("We should refuse to register elements whose names could clash", func(t *testing.T) {
+		err := NewResourceGroup().NewElements(elements, NewResourcePropertiesArgs(map[string]any{"zones": []any{"a"}}, refs.NewReferences()), nil)
+
+		assert.ErrorContains(t, err, "resource subnet-routes could be named like the resources stamped out of subnet")
+	})
+}
+
+func Test_ResourcesWithForEach(t *testing.T) {
+
+	args := NewResourcePropertiesArgs(map[string]any{
+		"zones": []any{"us-east-1a", "us-east-1b"},
+	}, refs.NewReferences())
+
+	resourceGroup := NewResourceGroup()
+
+	stamped, err := resourceGroup.NewResources(api.ResourceGroupElement{
+		Name:       "subnet",
+		ForEach:    "${parameters.zones}",
+		Properties: &runtime.RawExtension{Raw: []byte(`{"name":"subnet-${each.key}","zone":"${each.value}"}`)},
+	}, args)
+	assert.NoError(t, err)
+
+	_, err = resourceGroup.NewResource("app", &runtime.RawExtension{Raw: []byte(`{"subnet":"${resources[\"subnet-us-east-1b\"].status.outputs.id}"}`)})
+	assert.NoError(t, err)
+
+	t.Run("We should register one resource to each item", func(t *testing.T) {
+		if assert.Len(t, stamped, 2) {
+			assert.Equal(t, "subnet-us-east-1a", stamped[0].Name)
+			assert.Equal(t, "subnet-us-east-1a", stamped[0].NameAsKebabCase())
+			assert.Equal(t, "subnet-us-east-1b", stamped[1].Name)
+		}
+	})
+
+	t.Run("We should be able to read the item in the properties", func(t *testing.T) {
+		properties, err := stamped[1].Evaluate(args)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "subnet-us-east-1b", properties["name"])
+		assert.Equal(t, "us-east-1b", properties["zone"])
+	})
+
+	t.Run("We should be able to depend on a stamped out resource", func(t *testing.T) {
+		dag, err := resourceGroup.Graph()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"resources.subnet-us-east-1a", "resources.subnet-us-east-1b", "resources.app"}, dag)
+	})
+
+	t.Run("We should suffix the provisioner object name of a stamped out resource with its key", func(t *testing.T) {
+		assert.Equal(t, "shared-subnet-us-east-1a", stamped[0].ProvisionerObjectNameOf("shared-subnet"))
+		assert.Equal(t, "", stamped[0].ProvisionerObjectNameOf(""))
+	})
+}
+
+func Test_ResourcesWithExpressionLanguage(t *testing.T) {
+
+	args := NewResourcePropertiesArgs(map[string]any{
+		"zones": []any{"us-east-1a", "us-east-1b"},
+	}, refs.NewReferences())
+
+	resourceGroup := NewResourceGroup().WithExpressionLanguage(expression.LanguageCEL)
+
+	t.Run("We should read forEach and properties in the language of the group", func(t *testing.T) {
+		stamped, err := resourceGroup.NewResources(api.ResourceGroupElement{
+			Name:       "subnet",
+			ForEach:    "${parameters.zones.filter(z, z.endsWith('b'))}",
+			Properties: &runtime.RawExtension{Raw: []byte(`{"name":"${'subnet-' + each.key}"}`)},
+		}, args)
+
+		assert.NoError(t, err)
+		if assert.Len(t, stamped, 1) {
+			properties, err := stamped[0].Evaluate(args)
+
+			assert.NoError(t, err)
+			assert.Equal(t, "subnet-us-east-1b", properties["name"])
+		}
+	})
+
+	t.Run("We should reject a forEach that isn't a single expression", func(t *testing.T) {
+		_, err := resourceGroup.ParseForEach("zones: ${parameters.zones}")
+
+		assert.Error(t, err)
+	})
+}
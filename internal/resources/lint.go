@@ -0,0 +1,293 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	api "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/expression"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// LintError is an expression reading a path the resource it reads will never have, like a typo in
+// ${resources.database.status.outpts.host}
+type LintError struct {
+	// Resource and Property are where the expression is declared
+	Resource string
+	Property string
+	// Reference is the path read by the expression
+	Reference string
+	Detail    string
+}
+
+// Reason is what is wrong with the expression, without where it's declared
+func (e *LintError) Reason() string {
+	return fmt.Sprintf("reads %s, but %s", e.Reference, e.Detail)
+}
+
+func (e *LintError) Error() string {
+	return fmt.Sprintf("property %s of resource %s %s", e.Property, e.Resource, e.Reason())
+}
+
+// Lint checks the paths read by the expressions of every resource against the shape of the resources they read: the
+// fields of a Resource, whose properties are the ones declared by its ResourceRef schema or by the resource itself,
+// and whose outputs are the exported ones. Outputs aren't declared anywhere, so any output is accepted from resources
+// exporting all of them; so is anything below a value only known once evaluated, like a property set by an expression.
+// Resources without a ResourceRef have untyped properties, and resources missing from the group are left to Graph.
+func (r *ResourceGroup) Lint() []*LintError {
+	shapes := make(map[string]*shape, len(r.all))
+	for name, resource := range r.all {
+		shapes[name] = shapeOfResource(resource)
+	}
+
+	errs := make([]*LintError, 0)
+	for _, name := range slices.Sorted(maps.Keys(r.all)) {
+		resource := r.all[name]
+		if resource.properties == nil {
+			continue
+		}
+
+		for _, property := range expressionPropertiesOf(resource.properties.properties) {
+			for _, reference := range expression.ReferencesOf(property.expression) {
+				path := strings.Split(reference, ".")
+				if path[0] != "resources" || len(path) < 2 {
+					continue
+				}
+
+				// resources missing from the group are reported by its graph
+				target, ok := shapes[path[1]]
+				if !ok {
+					continue
+				}
+
+				if detail := target.lint(strings.Join(path[:2], "."), path[2:]); detail != "" {
+					errs = append(errs, &LintError{Resource: name, Property: property.name, Reference: reference, Detail: detail})
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// expressionPropertiesOf are the properties holding expressions, nested ones included, sorted by name
+func expressionPropertiesOf(properties map[string]ResourceProperty) []*ExpressionResourceProperty {
+	all := make([]*ExpressionResourceProperty, 0)
+
+	var collect func(property ResourceProperty)
+	collect = func(property ResourceProperty) {
+		switch p := property.(type) {
+		case *ExpressionResourceProperty:
+			all = append(all, p)
+		case *ObjectResourceProperty:
+			for _, name := range slices.Sorted(maps.Keys(p.properties)) {
+				collect(p.properties[name])
+			}
+		case *ArrayResourceProperty:
+			for _, element := range p.properties {
+				collect(element)
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(properties)) {
+		collect(properties[name])
+	}
+	return all
+}
+
+// shape is what can be read below a value: the fields of an object, or anything at all when it's open, like maps,
+// lists and values only known once deployed. Scalars are closed and have no fields.
+type shape struct {
+	open   bool
+	fields map[string]*shape
+}
+
+var openShape = &shape{open: true}
+
+// lint returns why the path can't be read below the shape; empty when it can
+func (s *shape) lint(prefix string, path []string) string {
+	current := s
+	for _, name := range path {
+		if current.open {
+			return ""
+		}
+
+		next, ok := current.fields[name]
+		if !ok {
+			if len(current.fields) == 0 {
+				return fmt.Sprintf("%s has no fields", prefix)
+			}
+			return fmt.Sprintf("%s has no field %s; expected one of %s", prefix, name, strings.Join(slices.Sorted(maps.Keys(current.fields)), ", "))
+		}
+
+		prefix = prefix + "." + name
+		current = next
+	}
+	return ""
+}
+
+// with returns a copy of the shape where the value at the path has another shape
+func (s *shape) with(path []string, replacement *shape) *shape {
+	if len(path) == 0 {
+		return replacement
+	}
+	if s.open {
+		return s
+	}
+
+	copied := &shape{fields: maps.Clone(s.fields)}
+	if child, ok := s.fields[path[0]]; ok {
+		copied.fields[path[0]] = child.with(path[1:], replacement)
+	}
+	return copied
+}
+
+// merge combines two shapes of the same value, like the properties declared by a schema and by a resource
+func merge(a *shape, b *shape) *shape {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	if a.open || b.open {
+		return openShape
+	}
+
+	merged := &shape{fields: maps.Clone(a.fields)}
+	if merged.fields == nil {
+		merged.fields = make(map[string]*shape)
+	}
+	for name, field := range b.fields {
+		merged.fields[name] = merge(merged.fields[name], field)
+	}
+	return merged
+}
+
+// resourceShape is the shape of any Resource, read from its type
+var resourceShape = shapeOfType(reflect.TypeOf(api.Resource{}))
+
+func shapeOfResource(resource *Resource) *shape {
+	properties := openShape
+	if resource.Ref != nil {
+		declared := &shape{fields: make(map[string]*shape)}
+		if resource.properties != nil {
+			for name, property := range resource.properties.properties {
+				declared.fields[name] = shapeOfProperty(property)
+			}
+		}
+		properties = merge(shapeOfSchema(&resource.Ref.Spec.Schema), declared)
+	}
+
+	outputs := openShape
+	if resource.ExportedOutputs != nil {
+		outputs = &shape{fields: make(map[string]*shape)}
+		for _, name := range resource.ExportedOutputs {
+			outputs.fields[name] = openShape
+		}
+	}
+
+	return resourceShape.
+		with([]string{"spec", "properties"}, properties).
+		with([]string{"status", "outputs"}, outputs)
+}
+
+// shapeOfSchema is the shape of a value declared by a ResourceRef schema; objects without declared properties and
+// arrays are open
+func shapeOfSchema(schema *api.ResourceRefSchema) *shape {
+	switch schema.Type {
+	case "object":
+		if len(schema.Properties) == 0 {
+			return openShape
+		}
+		object := &shape{fields: make(map[string]*shape)}
+		for name, property := range schema.Properties {
+			object.fields[name] = shapeOfSchema(&property)
+		}
+		return object
+
+	case "string", "integer", "number", "boolean":
+		return &shape{}
+
+	default:
+		return openShape
+	}
+}
+
+// shapeOfProperty is the shape of a value declared by a resource; expressions are only known once evaluated
+func shapeOfProperty(property ResourceProperty) *shape {
+	switch p := property.(type) {
+	case *ObjectResourceProperty:
+		object := &shape{fields: make(map[string]*shape)}
+		for name, child := range p.properties {
+			object.fields[name] = shapeOfProperty(child)
+		}
+		return object
+
+	case *ExpressionResourceProperty:
+		switch p.expression.(type) {
+		case expression.ConstantExpression, expression.SimpleExpression:
+			return &shape{}
+		default:
+			return openShape
+		}
+
+	default:
+		return openShape
+	}
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	rawExtensionType  = reflect.TypeOf(runtime.RawExtension{})
+)
+
+// shapeOfType is the shape of the JSON encoding of a Go type: structs are closed to their fields, maps, lists and raw
+// values are open, and anything encoding itself, like timestamps, is a scalar
+func shapeOfType(t reflect.Type) *shape {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == rawExtensionType:
+		return openShape
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		return &shape{}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object := &shape{fields: make(map[string]*shape)}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if field.Anonymous && (name == "" || strings.Contains(options, "inline")) {
+				maps.Copy(object.fields, shapeOfType(field.Type).fields)
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			object.fields[name] = shapeOfType(field.Type)
+		}
+		return object
+
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+		return openShape
+
+	default:
+		return &shape{}
+	}
+}
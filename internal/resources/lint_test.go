@@ -0,0 +1,104 @@
+package resources
+
+import (
+	"testing"
+
+	api "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_Lint(t *testing.T) {
+	rds := &api.ResourceRef{
+		Spec: api.ResourceRefSpec{
+			Schema: api.ResourceRefSchema{
+				Type: "object",
+				Properties: map[string]api.ResourceRefSchema{
+					"name":   {Type: "string"},
+					"engine": {Type: "object", Properties: map[string]api.ResourceRefSchema{"version": {Type: "string"}}},
+					"tags":   {Type: "object"},
+				},
+			},
+		},
+	}
+
+	newResourceGroup := func(t *testing.T, properties string) *ResourceGroup {
+		resourceGroup := NewResourceGroup()
+
+		database, err := resourceGroup.NewResource("database", &runtime.RawExtension{Raw: []byte(`{"name":"orders","size":"large"}`)})
+		assert.NoError(t, err)
+		database.Ref = rds
+		database.ExportedOutputs = []string{"endpoint", "port"}
+
+		_, err = resourceGroup.NewResource("app", &runtime.RawExtension{Raw: []byte(properties)})
+		assert.NoError(t, err)
+
+		return resourceGroup
+	}
+
+	t.Run("We should accept expressions reading paths the resources have", func(t *testing.T) {
+		resourceGroup := newResourceGroup(t, `{
+			"host": "${resources.database.status.outputs.endpoint}",
+			"name": "${resources.database.spec.properties.name}",
+			"size": "${resources.database.spec.properties.size}",
+			"version": "${resources.database.spec.properties.engine.version}",
+			"team": "${resources.database.spec.properties.tags.team}",
+			"namespace": "${resources.database.metadata.namespace}",
+			"owner": "${resources.database.metadata.labels.owner}",
+			"phase": "${resources.database.status.phase}"
+		}`)
+
+		assert.Empty(t, resourceGroup.Lint())
+	})
+
+	t.Run("We should reject a typo in the outputs", func(t *testing.T) {
+		errs := newResourceGroup(t, `{"host": "${resources.database.status.outputs.endpont}"}`).Lint()
+
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, "app", errs[0].Resource)
+			assert.Equal(t, "host", errs[0].Property)
+			assert.Equal(t, "resources.database.status.outputs.endpont", errs[0].Reference)
+			assert.Equal(t, "resources.database.status.outputs has no field endpont; expected one of endpoint, port", errs[0].Detail)
+		}
+	})
+
+	t.Run("We should reject properties the schema doesn't declare", func(t *testing.T) {
+		errs := newResourceGroup(t, `{"engine": {"version": "${resources.database.spec.properties.engine.versoin}"}}`).Lint()
+
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, "engine.version", errs[0].Property)
+			assert.Contains(t, errs[0].Detail, "has no field versoin; expected one of version")
+		}
+	})
+
+	t.Run("We should reject reading fields below scalars", func(t *testing.T) {
+		errs := newResourceGroup(t, `{"name": "${resources.database.spec.properties.name.value}"}`).Lint()
+
+		if assert.Len(t, errs, 1) {
+			assert.Equal(t, "resources.database.spec.properties.name has no fields", errs[0].Detail)
+		}
+	})
+
+	t.Run("We should reject fields a Resource never has", func(t *testing.T) {
+		errs := newResourceGroup(t, `{"name": "${resources.database.stats.outputs.endpoint}"}`).Lint()
+
+		if assert.Len(t, errs, 1) {
+			assert.Contains(t, errs[0].Detail, "resources.database has no field stats")
+		}
+	})
+
+	t.Run("We should accept anything from resources without a ResourceRef, exporting all of their outputs", func(t *testing.T) {
+		resourceGroup := NewResourceGroup()
+
+		_, err := resourceGroup.NewResource("database", &runtime.RawExtension{Raw: []byte(`{}`)})
+		assert.NoError(t, err)
+
+		_, err = resourceGroup.NewResource("app", &runtime.RawExtension{Raw: []byte(`{
+			"host": "${resources.database.status.outputs.anything}",
+			"name": "${resources.database.spec.properties.whatever.below}"
+		}`)})
+		assert.NoError(t, err)
+
+		assert.Empty(t, resourceGroup.Lint())
+	})
+}
@@ -0,0 +1,98 @@
+package resources
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/nubank/klaudio/internal/expression"
+)
+
+const (
+	// ProvenanceLiteral is the source of a property written as is in the ResourceGroup
+	ProvenanceLiteral = "literal"
+	// ProvenanceDefault is the source of a property filled in with the default from the ResourceRef schema
+	ProvenanceDefault = "default"
+)
+
+// sourceVariables are the variables a property can be read from; names bound inside an expression, like the ones of
+// comprehensions, aren't sources
+var sourceVariables = []string{"parameters", "refs", "resources", "each", "placement", "secrets"}
+
+// Provenance lists, to each top-level property of the expanded properties, where its value came from: the parameters,
+// refs and resource outputs read by its expressions, or a literal or a default when it reads none.
+func (r *Resource) Provenance(expanded ExpandedResourceProperties) map[string][]string {
+	provenance := make(map[string][]string)
+
+	var declared map[string]ResourceProperty
+	if r.properties != nil {
+		declared = r.properties.properties
+	}
+
+	for name := range expanded {
+		property, ok := declared[name]
+		if !ok {
+			provenance[name] = []string{ProvenanceDefault}
+			continue
+		}
+
+		sources := sourcesOf(property)
+		if len(sources) == 0 {
+			sources = []string{ProvenanceLiteral}
+		}
+		provenance[name] = sources
+	}
+
+	return provenance
+}
+
+// SecretProperties lists the top-level properties whose value is read from a Secret ref; they must never be shown
+// outside the Resource itself
+func (r *Resource) SecretProperties() []string {
+	if r.properties == nil {
+		return nil
+	}
+
+	secret := make([]string, 0)
+	for name, property := range r.properties.properties {
+		if slices.ContainsFunc(sourcesOf(property), isSecretSource) {
+			secret = append(secret, name)
+		}
+	}
+	slices.Sort(secret)
+
+	return secret
+}
+
+func isSecretSource(source string) bool {
+	return strings.HasPrefix(source, "secrets.")
+}
+
+func sourcesOf(property ResourceProperty) []string {
+	sources := make(map[string]struct{})
+
+	var collect func(property ResourceProperty)
+	collect = func(property ResourceProperty) {
+		switch p := property.(type) {
+		case *ObjectResourceProperty:
+			for _, child := range p.properties {
+				collect(child)
+			}
+		case *ArrayResourceProperty:
+			for _, child := range p.properties {
+				collect(child)
+			}
+		case *ExpressionResourceProperty:
+			// a path is read from the syntax tree, in dot syntax, like resources.subnet-a.status.outputs.id
+			for _, variable := range expression.VariablesOf(p.expression) {
+				root, _, _ := strings.Cut(variable, ".")
+				if slices.Contains(sourceVariables, root) {
+					sources[variable] = struct{}{}
+				}
+			}
+		}
+	}
+	collect(property)
+
+	return slices.Sorted(maps.Keys(sources))
+}
@@ -0,0 +1,43 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_ResourceProvenance(t *testing.T) {
+
+	resourceGroup := NewResourceGroup()
+
+	resource, err := resourceGroup.NewResource("app", &runtime.RawExtension{Raw: []byte(`{
+		"name": "checkout-${parameters.env}",
+		"subnet": "${resources[\"subnet-a\"].status.outputs.id}",
+		"tags": ["${refs.owner.data.team}", "${parameters.env}"],
+		"replicas": 2
+	}`)})
+	assert.NoError(t, err)
+
+	provenance := resource.Provenance(ExpandedResourceProperties{
+		"name":     "checkout-prod",
+		"subnet":   "subnet-123",
+		"tags":     []any{"payments", "prod"},
+		"replicas": 2,
+		"port":     8080,
+	})
+
+	t.Run("We should find the variables read by each property", func(t *testing.T) {
+		assert.Equal(t, []string{"parameters.env"}, provenance["name"])
+		assert.Equal(t, []string{"resources.subnet-a.status.outputs.id"}, provenance["subnet"])
+
+		t.Run("...including the nested ones", func(t *testing.T) {
+			assert.Equal(t, []string{"parameters.env", "refs.owner.data.team"}, provenance["tags"])
+		})
+	})
+
+	t.Run("We should tell literals from defaults", func(t *testing.T) {
+		assert.Equal(t, []string{ProvenanceLiteral}, provenance["replicas"])
+		assert.Equal(t, []string{ProvenanceDefault}, provenance["port"])
+	})
+}
@@ -0,0 +1,83 @@
+package resources
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Mermaid renders the dependencies between the resources as a Mermaid flowchart; each arrow goes from a resource to
+// the ones depending on it, following the deployment order.
+func (r *ResourceGroup) Mermaid() (string, error) {
+	adjacency, err := r.sortedAdjacency()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	for _, vertex := range adjacency.vertices {
+		fmt.Fprintf(&b, "    %s[\"%s\"]\n", mermaidIdOf(vertex), vertex)
+	}
+	for _, vertex := range adjacency.vertices {
+		for _, dependent := range adjacency.edges[vertex] {
+			fmt.Fprintf(&b, "    %s --> %s\n", mermaidIdOf(vertex), mermaidIdOf(dependent))
+		}
+	}
+	return b.String(), nil
+}
+
+// DOT renders the dependencies between the resources in the Graphviz DOT language; each arrow goes from a resource to
+// the ones depending on it, following the deployment order.
+func (r *ResourceGroup) DOT() (string, error) {
+	adjacency, err := r.sortedAdjacency()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("digraph {\n")
+	for _, vertex := range adjacency.vertices {
+		fmt.Fprintf(&b, "    %q;\n", vertex)
+	}
+	for _, vertex := range adjacency.vertices {
+		for _, dependent := range adjacency.edges[vertex] {
+			fmt.Fprintf(&b, "    %q -> %q;\n", vertex, dependent)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+type sortedAdjacency struct {
+	vertices []string
+	edges    map[string][]string
+}
+
+// sortedAdjacency lists vertices and edges in a stable order, so the same group is always rendered the same way
+func (r *ResourceGroup) sortedAdjacency() (*sortedAdjacency, error) {
+	resourcesDag, err := r.dag()
+	if err != nil {
+		return nil, err
+	}
+
+	adjacencyMap, err := resourcesDag.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+
+	adjacency := &sortedAdjacency{
+		vertices: slices.Sorted(maps.Keys(adjacencyMap)),
+		edges:    make(map[string][]string),
+	}
+	for vertex, edges := range adjacencyMap {
+		adjacency.edges[vertex] = slices.Sorted(maps.Keys(edges))
+	}
+	return adjacency, nil
+}
+
+// mermaidIdOf replaces the characters Mermaid doesn't accept in node ids; the vertex name is kept as label
+func mermaidIdOf(vertex string) string {
+	return strings.NewReplacer(".", "_", "-", "_").Replace(vertex)
+}
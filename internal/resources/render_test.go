@@ -0,0 +1,54 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_ResourcesRender(t *testing.T) {
+	resourceGroup := NewResourceGroup()
+	for name, properties := range map[string]string{
+		"database":  `{"name":"sample"}`,
+		"app-role":  `{"database":"${resources.database.status.outputs.name}"}`,
+		"dashboard": `{"database":"${resources.database.status.outputs.name}"}`,
+	} {
+		_, err := resourceGroup.NewResource(name, &runtime.RawExtension{Raw: []byte(properties)})
+		assert.NoError(t, err)
+	}
+
+	t.Run("We should render the graph as a Mermaid flowchart", func(t *testing.T) {
+		rendered, err := resourceGroup.Mermaid()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "flowchart TD\n"+
+			"    resources_app_role[\"resources.app-role\"]\n"+
+			"    resources_dashboard[\"resources.dashboard\"]\n"+
+			"    resources_database[\"resources.database\"]\n"+
+			"    resources_database --> resources_app_role\n"+
+			"    resources_database --> resources_dashboard\n", rendered)
+	})
+
+	t.Run("We should render the graph in the DOT language", func(t *testing.T) {
+		rendered, err := resourceGroup.DOT()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "digraph {\n"+
+			"    \"resources.app-role\";\n"+
+			"    \"resources.dashboard\";\n"+
+			"    \"resources.database\";\n"+
+			"    \"resources.database\" -> \"resources.app-role\";\n"+
+			"    \"resources.database\" -> \"resources.dashboard\";\n"+
+			"}\n", rendered)
+	})
+
+	t.Run("We should not render a graph with cycles", func(t *testing.T) {
+		cyclic := NewResourceGroup()
+		_, err := cyclic.NewResource("a", &runtime.RawExtension{Raw: []byte(`{"field":"${resources.a.status.outputs.value}"}`)})
+		assert.NoError(t, err)
+
+		_, err = cyclic.Mermaid()
+		assert.Error(t, err)
+	})
+}
@@ -2,9 +2,12 @@ package resources
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"maps"
 	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/dominikbraun/graph"
 	"github.com/gobuffalo/flect"
@@ -21,6 +24,8 @@ var (
 
 type ResourceGroup struct {
 	all map[string]*Resource
+	// language of the expressions of the resources; empty is the default one
+	language expression.Language
 }
 
 func (r ResourceGroup) Get(name string) (*Resource, error) {
@@ -37,13 +42,15 @@ func (r ResourceGroup) Get(name string) (*Resource, error) {
 	return resource, nil
 }
 
+// ResourcePropertiesArgs is the variable scope of the expressions. It's never changed after created: WithResource
+// returns a new scope, so the same args can be shared by concurrent evaluations.
 type ResourcePropertiesArgs struct {
 	all map[string]any
 }
 
 func NewResourcePropertiesArgs(parameters map[string]any, refs *refs.References) *ResourcePropertiesArgs {
 	variables := make(map[string]any)
-	variables["parameters"] = parameters
+	variables["parameters"] = maps.Clone(parameters)
 
 	newRefs := make(map[string]any)
 	for name, value := range refs.All() {
@@ -54,12 +61,8 @@ func NewResourcePropertiesArgs(parameters map[string]any, refs *refs.References)
 	return &ResourcePropertiesArgs{all: variables}
 }
 
-func (r *ResourcePropertiesArgs) WithResource(name string, resource *api.Resource) (*ResourcePropertiesArgs, error) {
-	resources, ok := r.all["resources"].(map[string]any)
-	if !ok {
-		resources = make(map[string]any)
-	}
-
+// WithResource returns a new scope with a deployed resource; only exported outputs are visible to other resources.
+func (r *ResourcePropertiesArgs) WithResource(source *Resource, resource *api.Resource) (*ResourcePropertiesArgs, error) {
 	resourceAsJson, err := json.Marshal(resource)
 	if err != nil {
 		return nil, err
@@ -81,9 +84,9 @@ func (r *ResourcePropertiesArgs) WithResource(name string, resource *api.Resourc
 		}
 	}
 
-	if outputs := resource.Status.Outputs; outputs != nil {
-		allStatusOutputs := make(map[string]any)
-		if err := json.Unmarshal(outputs.Raw, &allStatusOutputs); err != nil {
+	if resource.Status.Outputs != nil {
+		allStatusOutputs, err := resource.Status.GetOutputs()
+		if err != nil {
 			return nil, err
 		}
 
@@ -92,33 +95,229 @@ func (r *ResourcePropertiesArgs) WithResource(name string, resource *api.Resourc
 				resourceAsMap["Status"].(map[string]any)["Outputs"] = allStatusOutputs
 			}
 		}
+
+		if status, isSafe := resourceAsMap["status"].(map[string]any); isSafe {
+			status["outputs"] = source.exported(allStatusOutputs)
+		}
+	}
+
+	// copy-on-write: the receiver is kept as is
+	resources := make(map[string]any)
+	if current, ok := r.all["resources"].(map[string]any); ok {
+		maps.Copy(resources, current)
+	}
+	resources[source.Name] = resourceAsMap
+
+	all := maps.Clone(r.all)
+	all["resources"] = resources
+
+	return &ResourcePropertiesArgs{all: all}, nil
+}
+
+// unknownsOf are the references to resources that can't be found in the scope yet, like resources that weren't
+// deployed or outputs they haven't produced; paths through lists or values other than objects are left to the expression
+func (r *ResourcePropertiesArgs) unknownsOf(references []string) []string {
+	unknowns := make([]string, 0)
+	for _, reference := range references {
+		path := strings.Split(reference, ".")
+		if path[0] != "resources" {
+			continue
+		}
+
+		var current any = r.all
+		for _, name := range path {
+			object, ok := current.(map[string]any)
+			if !ok {
+				break
+			}
+			if current, ok = object[name]; !ok {
+				unknowns = append(unknowns, reference)
+				break
+			}
+		}
+	}
+	return unknowns
+}
+
+// withEach returns a new scope where expressions read the item of a forEach as each.key and each.value
+func (r *ResourcePropertiesArgs) withEach(each *Each) *ResourcePropertiesArgs {
+	all := maps.Clone(r.all)
+	all["each"] = map[string]any{"key": each.Key, "value": each.Value}
+
+	return &ResourcePropertiesArgs{all: all}
+}
+
+// CIDRAllocator allocates subnets to the placement being deployed, keeping the same subnet across deployments
+type CIDRAllocator interface {
+	Allocate(pool string, newbits int, name ...string) (string, error)
+}
+
+// WithCIDRAllocator returns a new scope where expressions can allocate subnets with cidralloc(pool, newbits[, name])
+func (r *ResourcePropertiesArgs) WithCIDRAllocator(allocator CIDRAllocator) *ResourcePropertiesArgs {
+	all := maps.Clone(r.all)
+	all["cidralloc"] = func(pool string, newbits any, name ...string) (string, error) {
+		// numbers from parameters are decoded from JSON as float64
+		switch n := newbits.(type) {
+		case int:
+			return allocator.Allocate(pool, n, name...)
+		case float64:
+			return allocator.Allocate(pool, int(n), name...)
+		default:
+			return "", fmt.Errorf("newbits must be a number, got %v", newbits)
+		}
+	}
+
+	return &ResourcePropertiesArgs{all: all}
+}
+
+// ValueGenerator generates values to the placement being deployed, keeping the same value across deployments
+type ValueGenerator interface {
+	Now(name ...string) (string, error)
+	RandomSuffix(length int, name ...string) (string, error)
+}
+
+// WithValueGenerator returns a new scope where expressions can generate values that don't change on every render:
+// now([name]) is the time of the first render, and randomsuffix(length[, name]) lowercase letters and digits
+func (r *ResourcePropertiesArgs) WithValueGenerator(generator ValueGenerator) *ResourcePropertiesArgs {
+	all := maps.Clone(r.all)
+	all["now"] = generator.Now
+	all["randomsuffix"] = func(length any, name ...string) (string, error) {
+		// numbers from parameters are decoded from JSON as float64
+		switch n := length.(type) {
+		case int:
+			return generator.RandomSuffix(n, name...)
+		case float64:
+			return generator.RandomSuffix(int(n), name...)
+		default:
+			return "", fmt.Errorf("length must be a number, got %v", length)
+		}
 	}
 
-	resources[name] = resourceAsMap
-	r.all["resources"] = resources
+	return &ResourcePropertiesArgs{all: all}
+}
 
-	return r, nil
+// WithPlacement returns a new scope where expressions read the details of the placement being deployed, like
+// placement.account and placement.region
+func (r *ResourcePropertiesArgs) WithPlacement(placement *api.Placement) *ResourcePropertiesArgs {
+	details := map[string]any{
+		"name":    placement.Name,
+		"account": placement.Spec.Account,
+		"region":  placement.Spec.Region,
+		"labels":  maps.Clone(placement.Labels),
+	}
+	if cluster := placement.Spec.Cluster; cluster != nil {
+		details["cluster"] = map[string]any{"name": cluster.Name, "server": cluster.Server}
+	}
+	if secretRef := placement.Spec.CredentialsSecretRef; secretRef != nil {
+		details["credentialsSecretRef"] = map[string]any{"name": secretRef.Name, "namespace": secretRef.Namespace}
+	}
+
+	all := maps.Clone(r.all)
+	all["placement"] = details
+
+	return &ResourcePropertiesArgs{all: all}
+}
+
+// WithSecrets returns a new scope where expressions read the values of Secret refs, like secrets.database.password
+func (r *ResourcePropertiesArgs) WithSecrets(secrets map[string]any) *ResourcePropertiesArgs {
+	all := maps.Clone(r.all)
+	all["secrets"] = maps.Clone(secrets)
+
+	return &ResourcePropertiesArgs{all: all}
 }
 
 type Resource struct {
-	Name         string
-	Ref          *api.ResourceRef
+	Name string
+	Ref  *api.ResourceRef
+	// ExportedOutputs restricts the outputs visible to other resources; nil means all outputs are exported
+	ExportedOutputs []string
+	// Each is the item the resource was stamped out to, when its element has a forEach
+	Each         *Each
 	properties   *ResourceProperties
 	dependencies []string
 }
 
+// Dependencies are the resources whose outputs are used by the expressions of this one
+func (r *Resource) Dependencies() []string {
+	return slices.Clone(r.dependencies)
+}
+
+// References are the paths into resources and refs read by the expressions of this one, like
+// resources.database.status.outputs.host, sorted
+func (r *Resource) References() []string {
+	references := make([]string, 0)
+	if r.properties == nil {
+		return references
+	}
+
+	for _, property := range expressionPropertiesOf(r.properties.properties) {
+		for _, reference := range expression.ReferencesOf(property.expression) {
+			if !slices.Contains(references, reference) {
+				references = append(references, reference)
+			}
+		}
+	}
+	slices.Sort(references)
+
+	return references
+}
+
 func (r *Resource) NameAsKebabCase() string {
 	return flect.Dasherize(r.Name)
 }
 
+func (r *Resource) exported(outputs map[string]any) map[string]any {
+	if r.ExportedOutputs == nil {
+		return outputs
+	}
+	exported := make(map[string]any)
+	for _, name := range r.ExportedOutputs {
+		if value, ok := outputs[name]; ok {
+			exported[name] = value
+		}
+	}
+	return exported
+}
+
 func (r *Resource) Evaluate(args *ResourcePropertiesArgs) (ExpandedResourceProperties, error) {
+	if r.Each != nil {
+		args = args.withEach(r.Each)
+	}
+
 	newProperties := make(map[string]any)
-	for name, property := range r.properties.properties {
-		expanded, err := property.Evaluate(args)
-		if err != nil {
-			return nil, err
+	if r.properties != nil {
+		// every property is evaluated, so all of the unknowns the resource is waiting on are reported at once
+		unknowns := make([]string, 0)
+
+		// sorted, so functions with side effects, like cidralloc, are called in the same order every time
+		for _, name := range slices.Sorted(maps.Keys(r.properties.properties)) {
+			expanded, err := r.properties.properties[name].Evaluate(args)
+
+			var unknown *expression.UnknownError
+			if errors.As(err, &unknown) {
+				for _, u := range unknown.Unknowns {
+					if !slices.Contains(unknowns, u) {
+						unknowns = append(unknowns, u)
+					}
+				}
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			newProperties[name] = expanded
+		}
+
+		if len(unknowns) != 0 {
+			return nil, &expression.UnknownError{Unknowns: unknowns}
+		}
+	}
+
+	// properties that weren't declared are filled in with the defaults from the ResourceRef schema
+	if r.Ref != nil {
+		if err := applyDefaults(&r.Ref.Spec.Schema, newProperties); err != nil {
+			return nil, fmt.Errorf("unable to apply defaults from ResourceRef %s: %w", r.Ref.Name, err)
 		}
-		newProperties[name] = expanded
 	}
 
 	return ExpandedResourceProperties(newProperties), nil
@@ -153,8 +352,8 @@ func (p ObjectResourceProperty) Dependencies() []string {
 
 func (p ObjectResourceProperty) Evaluate(args *ResourcePropertiesArgs) (any, error) {
 	newMap := make(map[string]any)
-	for name, property := range p.properties {
-		newValue, err := property.Evaluate(args)
+	for _, name := range slices.Sorted(maps.Keys(p.properties)) {
+		newValue, err := p.properties[name].Evaluate(args)
 		if err != nil {
 			return nil, err
 		}
@@ -204,14 +403,109 @@ func (p ExpressionResourceProperty) Dependencies() []string {
 }
 
 func (p ExpressionResourceProperty) Evaluate(args *ResourcePropertiesArgs) (any, error) {
-	return p.expression.Evaluate(args.all)
+	unknowns := args.unknownsOf(expression.ReferencesOf(p.expression))
+	if len(unknowns) == 0 {
+		return p.expression.Evaluate(args.all)
+	}
+	return expression.EvaluateWithUnknowns(p.expression, unknowns, args.all)
 }
 
 func NewResourceGroup() *ResourceGroup {
 	return &ResourceGroup{all: make(map[string]*Resource)}
 }
 
+// WithExpressionLanguage chooses the language the expressions of the resources are parsed in
+func (r *ResourceGroup) WithExpressionLanguage(language expression.Language) *ResourceGroup {
+	r.language = language
+	return r
+}
+
 func (r *ResourceGroup) Graph() ([]string, error) {
+	resourcesDag, err := r.dag()
+	if err != nil {
+		return nil, err
+	}
+
+	return graph.StableTopologicalSort(resourcesDag, func(a, b string) bool {
+		return a < b
+	})
+}
+
+// DeploymentLevels groups the resources in the order they must be deployed: the first level holds the resources
+// without dependencies, and each next level holds the resources whose dependencies are all in the previous levels.
+// Resources in the same level are independent of each other.
+func (r *ResourceGroup) DeploymentLevels() ([][]string, error) {
+	resourcesDag, err := r.dag()
+	if err != nil {
+		return nil, err
+	}
+
+	ordered, err := graph.StableTopologicalSort(resourcesDag, func(a, b string) bool {
+		return a < b
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dependencies, err := resourcesDag.PredecessorMap()
+	if err != nil {
+		return nil, err
+	}
+
+	return levelsOf(ordered, dependencies), nil
+}
+
+// TeardownLevels groups the resources in the order they must be destroyed: the first level holds the resources no
+// one depends on, and each next level holds the resources whose dependents are all in the previous levels.
+func (r *ResourceGroup) TeardownLevels() ([][]string, error) {
+	resourcesDag, err := r.dag()
+	if err != nil {
+		return nil, err
+	}
+
+	ordered, err := graph.StableTopologicalSort(resourcesDag, func(a, b string) bool {
+		return a < b
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dependents, err := resourcesDag.AdjacencyMap()
+	if err != nil {
+		return nil, err
+	}
+
+	// walking the dag backwards, every dependent is visited before the resources it depends on
+	slices.Reverse(ordered)
+
+	return levelsOf(ordered, dependents), nil
+}
+
+// levelsOf puts each resource one level after the last of its edges; every edge must come before in the order
+func levelsOf(ordered []string, edges map[string]map[string]graph.Edge[string]) [][]string {
+	levelOf := make(map[string]int)
+	levels := make([][]string, 0)
+	for _, name := range ordered {
+		level := 0
+		for edge := range edges[name] {
+			level = max(level, levelOf[edge]+1)
+		}
+		levelOf[name] = level
+
+		if level == len(levels) {
+			levels = append(levels, make([]string, 0))
+		}
+		levels[level] = append(levels[level], name)
+	}
+
+	for _, level := range levels {
+		slices.Sort(level)
+	}
+
+	return levels
+}
+
+func (r *ResourceGroup) dag() (graph.Graph[string, string], error) {
 	resourcesDag := graph.New(graph.StringHash, graph.Directed(), graph.PreventCycles())
 
 	vertexNameFn := func(name string) string {
@@ -225,18 +519,24 @@ func (r *ResourceGroup) Graph() ([]string, error) {
 		}
 	}
 
-	for name, resource := range r.all {
-		for _, dependency := range resource.dependencies {
+	// sorted, so the same cycle is always reported the same way
+	for _, name := range slices.Sorted(maps.Keys(r.all)) {
+		for _, dependency := range slices.Sorted(slices.Values(r.all[name].dependencies)) {
+			// refs are resolved before any resource is deployed, so they don't order anything
+			if !strings.HasPrefix(dependency, "resources.") {
+				continue
+			}
 			err := resourcesDag.AddEdge(dependency, vertexNameFn(name))
+			if errors.Is(err, graph.ErrEdgeCreatesCycle) {
+				return nil, newCycleError(resourcesDag, dependency, vertexNameFn(name))
+			}
 			if err != nil {
 				return nil, err
 			}
 		}
 	}
 
-	return graph.StableTopologicalSort(resourcesDag, func(a, b string) bool {
-		return a < b
-	})
+	return resourcesDag, nil
 }
 
 func (r *ResourceGroup) NewResource(name string, properties *runtime.RawExtension) (*Resource, error) {
@@ -253,7 +553,7 @@ func (r *ResourceGroup) NewResource(name string, properties *runtime.RawExtensio
 			return nil, fmt.Errorf("unable to unmarshall properties: %w", err)
 		}
 
-		resourcePropertiesAsExpressions, err := newResourceProperties(propertiesToExpressions)
+		resourcePropertiesAsExpressions, err := newResourceProperties(r.language, propertiesToExpressions)
 		if err != nil {
 			return nil, fmt.Errorf("unable to read resource properties from %s: %w", name, err)
 		}
@@ -264,12 +564,12 @@ func (r *ResourceGroup) NewResource(name string, properties *runtime.RawExtensio
 	return resource, nil
 }
 
-func newResourceProperties(properties map[string]any) (*ResourceProperties, error) {
+func newResourceProperties(language expression.Language, properties map[string]any) (*ResourceProperties, error) {
 	propertiesWithExpressions := make(map[string]ResourceProperty)
 	dependencies := sets.NewString()
 
 	for name, value := range properties {
-		elementWithExpressions, err := readProperty(name, value)
+		elementWithExpressions, err := readProperty(language, name, value)
 		if err != nil {
 			return nil, fmt.Errorf("unable to read properties from field %s: %w", name, err)
 		}
@@ -287,14 +587,14 @@ func newResourceProperties(properties map[string]any) (*ResourceProperties, erro
 	return resourceProperties, nil
 }
 
-func readProperty(name string, value any) (ResourceProperty, error) {
+func readProperty(language expression.Language, name string, value any) (ResourceProperty, error) {
 	switch value := value.(type) {
 	case map[string]any:
-		return readObjectProperty(name, value)
+		return readObjectProperty(language, name, value)
 	case []any:
-		return readArrayProperty(name, value)
+		return readArrayProperty(language, name, value)
 	default:
-		e, err := expression.Parse(value)
+		e, err := expression.ParseWith(language, value)
 		if err != nil {
 			return nil, err
 		}
@@ -307,11 +607,11 @@ func readProperty(name string, value any) (ResourceProperty, error) {
 	}
 }
 
-func readObjectProperty(name string, value map[string]any) (ResourceProperty, error) {
+func readObjectProperty(language expression.Language, name string, value map[string]any) (ResourceProperty, error) {
 	properties := make(map[string]ResourceProperty)
 	dependencies := make([]string, 0)
 	for propertyName, element := range value {
-		newElement, err := readProperty(fmt.Sprintf("%s.%s", name, propertyName), element)
+		newElement, err := readProperty(language, fmt.Sprintf("%s.%s", name, propertyName), element)
 		if err != nil {
 			return nil, err
 		}
@@ -326,11 +626,11 @@ func readObjectProperty(name string, value map[string]any) (ResourceProperty, er
 	return objectResourceProperty, nil
 }
 
-func readArrayProperty(name string, value []any) (ResourceProperty, error) {
+func readArrayProperty(language expression.Language, name string, value []any) (ResourceProperty, error) {
 	values := make([]ResourceProperty, len(value))
 	dependencies := make([]string, 0)
 	for i, element := range value {
-		newElement, err := readProperty(fmt.Sprintf("%s[%d]", name, i), element)
+		newElement, err := readProperty(language, fmt.Sprintf("%s[%d]", name, i), element)
 		if err != nil {
 			return nil, err
 		}
@@ -3,9 +3,16 @@ package resources
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
 	"testing"
 
+	api "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/expression"
+	"github.com/nubank/klaudio/internal/generated"
+	"github.com/nubank/klaudio/internal/ipam"
+	"github.com/nubank/klaudio/internal/refs"
 	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -268,3 +275,340 @@ func Test_ResourcesGraph(t *testing.T) {
 
 	assert.Equal(t, expected, dag)
 }
+
+func Test_ResourcesGraphWithRefs(t *testing.T) {
+
+	t.Run("We should not order resources by the refs they read", func(t *testing.T) {
+		resourceGroup := NewResourceGroup()
+
+		_, err := resourceGroup.NewResource("database", &runtime.RawExtension{Raw: []byte(`{"team":"${refs.owner.data.team}"}`)})
+		assert.NoError(t, err)
+
+		_, err = resourceGroup.NewResource("app", &runtime.RawExtension{Raw: []byte(`{"endpoint":"${resources.database.status.outputs.endpoint}"}`)})
+		assert.NoError(t, err)
+
+		dag, err := resourceGroup.Graph()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"resources.database", "resources.app"}, dag)
+	})
+}
+
+func Test_ResourcesTeardownLevels(t *testing.T) {
+	resourceGroup := NewResourceGroup()
+
+	newResource := func(name string, properties map[string]any) {
+		propertiesAsBytes, err := json.Marshal(properties)
+		assert.NoError(t, err)
+
+		_, err = resourceGroup.NewResource(name, &runtime.RawExtension{Raw: propertiesAsBytes})
+		assert.NoError(t, err)
+	}
+
+	newResource("vpc", map[string]any{})
+	newResource("subnet", map[string]any{"vpc": "${resources.vpc.id}"})
+	newResource("database", map[string]any{"subnet": "${resources.subnet.id}", "vpc": "${resources.vpc.id}"})
+	newResource("bucket", map[string]any{})
+
+	levels, err := resourceGroup.TeardownLevels()
+	assert.NoError(t, err)
+
+	expected := [][]string{
+		{"resources.bucket", "resources.database"},
+		{"resources.subnet"},
+		{"resources.vpc"},
+	}
+
+	assert.Equal(t, expected, levels)
+}
+
+func Test_ResourcesDeploymentLevels(t *testing.T) {
+	resourceGroup := NewResourceGroup()
+
+	newResource := func(name string, properties map[string]any) {
+		propertiesAsBytes, err := json.Marshal(properties)
+		assert.NoError(t, err)
+
+		_, err = resourceGroup.NewResource(name, &runtime.RawExtension{Raw: propertiesAsBytes})
+		assert.NoError(t, err)
+	}
+
+	newResource("vpc", map[string]any{})
+	newResource("subnet", map[string]any{"vpc": "${resources.vpc.id}"})
+	newResource("database", map[string]any{"subnet": "${resources.subnet.id}", "vpc": "${resources.vpc.id}"})
+	newResource("cache", map[string]any{"subnet": "${resources.subnet.id}"})
+	newResource("bucket", map[string]any{})
+
+	levels, err := resourceGroup.DeploymentLevels()
+	assert.NoError(t, err)
+
+	expected := [][]string{
+		{"resources.bucket", "resources.vpc"},
+		{"resources.subnet"},
+		{"resources.cache", "resources.database"},
+	}
+
+	assert.Equal(t, expected, levels)
+}
+
+func Test_ResourceOutputsExport(t *testing.T) {
+
+	deployed := &api.Resource{
+		Spec: api.ResourceSpec{
+			Properties: &runtime.RawExtension{Raw: []byte(`{"name":"database"}`)},
+		},
+		Status: api.ResourceStatus{
+			Outputs: &runtime.RawExtension{Raw: []byte(`{"endpoint":"db.internal:5432","password_hash":"abc"}`)},
+		},
+	}
+
+	outputsOf := func(args *ResourcePropertiesArgs, name string) map[string]any {
+		resource := args.all["resources"].(map[string]any)[name].(map[string]any)
+		return resource["status"].(map[string]any)["outputs"].(map[string]any)
+	}
+
+	t.Run("We should be able to see all outputs when the resource doesn't restrict them", func(t *testing.T) {
+		args, err := NewResourcePropertiesArgs(map[string]any{}, refs.NewReferences()).WithResource(&Resource{Name: "database"}, deployed)
+		assert.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"endpoint": "db.internal:5432", "password_hash": "abc"}, outputsOf(args, "database"))
+	})
+
+	t.Run("We should be able to see only the exported outputs", func(t *testing.T) {
+		source := &Resource{Name: "database", ExportedOutputs: []string{"endpoint"}}
+
+		args, err := NewResourcePropertiesArgs(map[string]any{}, refs.NewReferences()).WithResource(source, deployed)
+		assert.NoError(t, err)
+
+		assert.Equal(t, map[string]any{"endpoint": "db.internal:5432"}, outputsOf(args, "database"))
+	})
+
+	t.Run("We should not see any output when the resource exports none of them", func(t *testing.T) {
+		source := &Resource{Name: "database", ExportedOutputs: []string{}}
+
+		args, err := NewResourcePropertiesArgs(map[string]any{}, refs.NewReferences()).WithResource(source, deployed)
+		assert.NoError(t, err)
+
+		assert.Empty(t, outputsOf(args, "database"))
+	})
+}
+
+func Test_ResourcePropertiesArgsCopyOnWrite(t *testing.T) {
+
+	newDeployed := func(endpoint string) *api.Resource {
+		return &api.Resource{
+			Spec: api.ResourceSpec{
+				Properties: &runtime.RawExtension{Raw: []byte(`{}`)},
+			},
+			Status: api.ResourceStatus{
+				Outputs: &runtime.RawExtension{Raw: []byte(fmt.Sprintf(`{"endpoint":"%s"}`, endpoint))},
+			},
+		}
+	}
+
+	base := NewResourcePropertiesArgs(map[string]any{"env": "dev"}, refs.NewReferences())
+
+	t.Run("We should not change the original scope when adding a resource", func(t *testing.T) {
+		args, err := base.WithResource(&Resource{Name: "database"}, newDeployed("db.internal:5432"))
+		assert.NoError(t, err)
+
+		assert.Contains(t, args.all, "resources")
+		assert.NotContains(t, base.all, "resources")
+
+		other, err := args.WithResource(&Resource{Name: "cache"}, newDeployed("cache.internal:6379"))
+		assert.NoError(t, err)
+
+		assert.Len(t, other.all["resources"], 2)
+		assert.Len(t, args.all["resources"], 1)
+	})
+
+	t.Run("We should be able to share the same scope between concurrent evaluations", func(t *testing.T) {
+		resourceGroup := NewResourceGroup()
+
+		resource, err := resourceGroup.NewResource("app", &runtime.RawExtension{Raw: []byte(`{"endpoint":"${resources.database.status.outputs.endpoint}"}`)})
+		assert.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				endpoint := fmt.Sprintf("db-%d.internal:5432", i)
+
+				args, err := base.WithResource(&Resource{Name: "database"}, newDeployed(endpoint))
+				assert.NoError(t, err)
+
+				properties, err := resource.Evaluate(args)
+				assert.NoError(t, err)
+				assert.Equal(t, endpoint, properties["endpoint"])
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func Test_ResourcePropertiesArgsCIDRAllocator(t *testing.T) {
+
+	resourceGroup := NewResourceGroup()
+
+	resource, err := resourceGroup.NewResource("network", &runtime.RawExtension{Raw: []byte(`{"private":"${cidralloc(parameters.vpc, parameters.bits, \"private\")}","public":"${cidralloc(parameters.vpc, 8)}"}`)})
+	assert.NoError(t, err)
+
+	args := NewResourcePropertiesArgs(map[string]any{"vpc": "10.0.0.0/16", "bits": float64(8)}, refs.NewReferences()).
+		WithCIDRAllocator(ipam.NewMemoryAllocator("prod"))
+
+	t.Run("We should be able to allocate subnets in expressions", func(t *testing.T) {
+		properties, err := resource.Evaluate(args)
+		assert.NoError(t, err)
+
+		assert.NotEqual(t, properties["private"], properties["public"])
+		assert.ElementsMatch(t, []any{"10.0.0.0/24", "10.0.1.0/24"}, []any{properties["private"], properties["public"]})
+
+		t.Run("...and get the same subnets again", func(t *testing.T) {
+			again, err := resource.Evaluate(args)
+			assert.NoError(t, err)
+
+			assert.Equal(t, properties, again)
+		})
+	})
+}
+
+func Test_ResourcePropertiesArgsValueGenerator(t *testing.T) {
+
+	resourceGroup := NewResourceGroup()
+
+	resource, err := resourceGroup.NewResource("bucket", &runtime.RawExtension{Raw: []byte(`{"name":"orders-${randomsuffix(parameters.length)}","logs":"logs-${randomsuffix(6, \"logs\")}","createdAt":"${now()}"}`)})
+	assert.NoError(t, err)
+
+	args := NewResourcePropertiesArgs(map[string]any{"length": float64(6)}, refs.NewReferences()).
+		WithValueGenerator(generated.NewMemoryGenerator("prod"))
+
+	t.Run("We should be able to generate values in expressions", func(t *testing.T) {
+		properties, err := resource.Evaluate(args)
+		assert.NoError(t, err)
+
+		assert.Regexp(t, `^orders-[a-z0-9]{6}$`, properties["name"])
+		assert.Regexp(t, `^logs-[a-z0-9]{6}$`, properties["logs"])
+		assert.NotEqual(t, properties["name"].(string)[len("orders-"):], properties["logs"].(string)[len("logs-"):])
+		assert.Equal(t, "1970-01-01T00:00:00Z", properties["createdAt"])
+
+		t.Run("...and get the same values again", func(t *testing.T) {
+			again, err := resource.Evaluate(args)
+			assert.NoError(t, err)
+
+			assert.Equal(t, properties, again)
+		})
+	})
+
+	t.Run("Without a generator, the time of each render is not available", func(t *testing.T) {
+		_, err := resource.Evaluate(NewResourcePropertiesArgs(map[string]any{"length": float64(6)}, refs.NewReferences()))
+		assert.Error(t, err)
+	})
+}
+
+func Test_ResourcePropertiesArgsPlacement(t *testing.T) {
+
+	resourceGroup := NewResourceGroup()
+
+	resource, err := resourceGroup.NewResource("bucket", &runtime.RawExtension{Raw: []byte(`{"name":"${placement.account}-${placement.region}","cluster":"${placement.cluster.name}"}`)})
+	assert.NoError(t, err)
+
+	placement := &api.Placement{
+		ObjectMeta: metav1.ObjectMeta{Name: "prod-us"},
+		Spec: api.PlacementSpec{
+			Account: "prod",
+			Region:  "us-east-1",
+			Cluster: &api.PlacementCluster{Name: "prod-us-1"},
+		},
+	}
+
+	args := NewResourcePropertiesArgs(map[string]any{}, refs.NewReferences()).WithPlacement(placement)
+
+	t.Run("We should be able to read the placement details in expressions", func(t *testing.T) {
+		properties, err := resource.Evaluate(args)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "prod-us-east-1", properties["name"])
+		assert.Equal(t, "prod-us-1", properties["cluster"])
+	})
+
+	t.Run("We should not order resources by the placement they read", func(t *testing.T) {
+		dag, err := resourceGroup.Graph()
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"resources.bucket"}, dag)
+	})
+}
+
+func Test_ResourcePropertiesArgsSecrets(t *testing.T) {
+
+	resourceGroup := NewResourceGroup()
+
+	resource, err := resourceGroup.NewResource("database", &runtime.RawExtension{Raw: []byte(`{"username":"${parameters.username}","password":"${secrets.database.password}"}`)})
+	assert.NoError(t, err)
+
+	secrets := map[string]any{"database": map[string]any{"password": "s3cr3t"}}
+
+	args := NewResourcePropertiesArgs(map[string]any{"username": "checkout"}, refs.NewReferences()).WithSecrets(secrets)
+
+	t.Run("We should be able to read secret refs in expressions", func(t *testing.T) {
+		properties, err := resource.Evaluate(args)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "s3cr3t", properties["password"])
+	})
+
+	t.Run("We should know which properties are read from secret refs", func(t *testing.T) {
+		assert.Equal(t, []string{"password"}, resource.SecretProperties())
+	})
+}
+
+func Test_ResourcesWithUnknowns(t *testing.T) {
+
+	deployed := &api.Resource{
+		Spec: api.ResourceSpec{
+			Properties: &runtime.RawExtension{Raw: []byte(`{"name":"database"}`)},
+		},
+		Status: api.ResourceStatus{
+			Outputs: &runtime.RawExtension{Raw: []byte(`{"host":"db.internal"}`)},
+		},
+	}
+
+	args, err := NewResourcePropertiesArgs(map[string]any{}, refs.NewReferences()).WithResource(&Resource{Name: "database"}, deployed)
+	assert.NoError(t, err)
+
+	t.Run("We should report every output the resource is waiting on", func(t *testing.T) {
+		resource, err := NewResourceGroup().NewResource("app", &runtime.RawExtension{Raw: []byte(`{"url":"postgres://${resources.database.status.outputs.host}:${resources.database.status.outputs.port}","cache":"${resources.cache.status.outputs.host}"}`)})
+		assert.NoError(t, err)
+
+		_, err = resource.Evaluate(args)
+
+		var unknown *expression.UnknownError
+		if assert.ErrorAs(t, err, &unknown) {
+			assert.Equal(t, []string{"resources.cache.status.outputs.host", "resources.database.status.outputs.port"}, unknown.Unknowns)
+			assert.Equal(t, "waiting on resources.cache.status.outputs.host, resources.database.status.outputs.port", unknown.Error())
+		}
+	})
+
+	t.Run("We should evaluate expressions whose result doesn't depend on the unknowns", func(t *testing.T) {
+		resource, err := NewResourceGroup().NewResource("app", &runtime.RawExtension{Raw: []byte(`{"port":"${resources.database.status.outputs.port ?? 5432}"}`)})
+		assert.NoError(t, err)
+
+		properties, err := resource.Evaluate(args)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 5432, properties["port"])
+	})
+
+	t.Run("We should partially evaluate CEL expressions", func(t *testing.T) {
+		resource, err := NewResourceGroup().WithExpressionLanguage(expression.LanguageCEL).NewResource("app", &runtime.RawExtension{Raw: []byte(`{"public":"${resources.database.status.outputs.public || true}","host":"${resources.database.status.outputs.host}"}`)})
+		assert.NoError(t, err)
+
+		properties, err := resource.Evaluate(args)
+
+		assert.NoError(t, err)
+		assert.Equal(t, true, properties["public"])
+		assert.Equal(t, "db.internal", properties["host"])
+	})
+}
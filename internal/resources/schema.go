@@ -0,0 +1,150 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+
+	api "github.com/nubank/klaudio/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ValidateSchema checks expanded properties against the schema declared by a ResourceRef: the type of each declared
+// property, nested ones included, and the required ones. Properties not declared by the schema are accepted.
+func ValidateSchema(schema *api.ResourceRefSchema, rawProperties []byte) field.ErrorList {
+	properties := make(map[string]any)
+	if len(rawProperties) != 0 {
+		if err := json.Unmarshal(rawProperties, &properties); err != nil {
+			return field.ErrorList{field.Invalid(field.NewPath("properties"), field.OmitValueType{}, err.Error())}
+		}
+	}
+
+	return validateValue(field.NewPath("properties"), schema, properties)
+}
+
+func validateValue(path *field.Path, schema *api.ResourceRefSchema, value any) field.ErrorList {
+	var errs field.ErrorList
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return append(errs, typeInvalid(path, value, schema.Type))
+		}
+
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				errs = append(errs, field.Required(path.Child(name), "required by the ResourceRef schema"))
+			}
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(schema.Properties)) {
+			property, ok := object[name]
+			if !ok {
+				continue
+			}
+			propertySchema := schema.Properties[name]
+			errs = append(errs, validateValue(path.Child(name), &propertySchema, property)...)
+		}
+
+	case "array":
+		if _, ok := value.([]any); !ok {
+			errs = append(errs, typeInvalid(path, value, schema.Type))
+		}
+
+	case "string":
+		if _, ok := value.(string); !ok {
+			errs = append(errs, typeInvalid(path, value, schema.Type))
+		}
+
+	case "integer":
+		if number, ok := value.(float64); !ok || number != math.Trunc(number) {
+			errs = append(errs, typeInvalid(path, value, schema.Type))
+		}
+
+	case "number":
+		if _, ok := value.(float64); !ok {
+			errs = append(errs, typeInvalid(path, value, schema.Type))
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			errs = append(errs, typeInvalid(path, value, schema.Type))
+		}
+	}
+
+	return errs
+}
+
+// typeInvalid only reports scalar values; objects and arrays would flood the message
+func typeInvalid(path *field.Path, value any, expected string) *field.Error {
+	detail := fmt.Sprintf("must be %s %s", article(expected), expected)
+
+	switch value.(type) {
+	case map[string]any, []any:
+		return field.TypeInvalid(path, field.OmitValueType{}, detail)
+	default:
+		return field.TypeInvalid(path, value, detail)
+	}
+}
+
+func article(schemaType string) string {
+	switch schemaType {
+	case "object", "array", "integer":
+		return "an"
+	default:
+		return "a"
+	}
+}
+
+// ValidateDefaults checks that the defaults declared by a schema are valid JSON and match the type of their property
+func ValidateDefaults(path *field.Path, schema *api.ResourceRefSchema) field.ErrorList {
+	var errs field.ErrorList
+
+	if schema.Default != nil {
+		var value any
+		if err := json.Unmarshal(schema.Default.Raw, &value); err != nil {
+			errs = append(errs, field.Invalid(path.Child("default"), field.OmitValueType{}, err.Error()))
+		} else {
+			errs = append(errs, validateValue(path.Child("default"), schema, value)...)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(schema.Properties)) {
+		property := schema.Properties[name]
+		errs = append(errs, ValidateDefaults(path.Child("properties").Key(name), &property)...)
+	}
+
+	return errs
+}
+
+// applyDefaults fills in the properties that weren't declared with the defaults of the schema; nested objects are only
+// defaulted when they are declared
+func applyDefaults(schema *api.ResourceRefSchema, properties map[string]any) error {
+	for _, name := range slices.Sorted(maps.Keys(schema.Properties)) {
+		property := schema.Properties[name]
+
+		value, ok := properties[name]
+		if !ok {
+			if property.Default == nil {
+				continue
+			}
+
+			var defaultValue any
+			if err := json.Unmarshal(property.Default.Raw, &defaultValue); err != nil {
+				return fmt.Errorf("invalid default to property %s: %w", name, err)
+			}
+			properties[name] = defaultValue
+			continue
+		}
+
+		if object, ok := value.(map[string]any); ok {
+			if err := applyDefaults(&property, object); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
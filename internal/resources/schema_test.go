@@ -0,0 +1,120 @@
+package resources
+
+import (
+	"testing"
+
+	api "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/refs"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func Test_ValidateSchema(t *testing.T) {
+	schema := &api.ResourceRefSchema{
+		Type:     "object",
+		Required: []string{"name", "size"},
+		Properties: map[string]api.ResourceRefSchema{
+			"name":     {Type: "string"},
+			"size":     {Type: "integer"},
+			"ratio":    {Type: "number"},
+			"public":   {Type: "boolean"},
+			"subnets":  {Type: "array"},
+			"database": {Type: "object", Required: []string{"engine"}, Properties: map[string]api.ResourceRefSchema{"engine": {Type: "string"}}},
+		},
+	}
+
+	t.Run("We should accept properties matching the schema", func(t *testing.T) {
+		errs := ValidateSchema(schema, []byte(`{"name":"checkout","size":10,"ratio":0.5,"public":false,"subnets":["a","b"],"database":{"engine":"postgres"},"team":"payments"}`))
+
+		assert.Empty(t, errs)
+	})
+
+	t.Run("We should report required properties that are missing", func(t *testing.T) {
+		errs := ValidateSchema(schema, []byte(`{"name":"checkout","database":{}}`))
+
+		assert.Equal(t, `[properties.size: Required value: required by the ResourceRef schema, properties.database.engine: Required value: required by the ResourceRef schema]`, errs.ToAggregate().Error())
+	})
+
+	t.Run("We should report properties with the wrong type by path", func(t *testing.T) {
+		errs := ValidateSchema(schema, []byte(`{"name":"checkout","size":2.5,"public":"yes","subnets":"a","database":{"engine":5}}`))
+
+		assert.Len(t, errs, 4)
+		assert.Equal(t, "properties.database.engine", errs[0].Field)
+		assert.Equal(t, "properties.public", errs[1].Field)
+		assert.Equal(t, "properties.size", errs[2].Field)
+		assert.Equal(t, "properties.subnets", errs[3].Field)
+		assert.Contains(t, errs[2].Error(), "must be an integer")
+	})
+
+	t.Run("Objects and arrays shouldn't be reported as values", func(t *testing.T) {
+		errs := ValidateSchema(schema, []byte(`{"name":{"first":"checkout"},"size":1}`))
+
+		assert.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), "properties.name: Invalid value")
+		assert.NotContains(t, errs[0].Error(), "first")
+	})
+}
+
+func Test_SchemaDefaults(t *testing.T) {
+	raw := func(value string) *runtime.RawExtension {
+		return &runtime.RawExtension{Raw: []byte(value)}
+	}
+
+	ref := &api.ResourceRef{
+		Spec: api.ResourceRefSpec{
+			Schema: api.ResourceRefSchema{
+				Type: "object",
+				Properties: map[string]api.ResourceRefSchema{
+					"name":    {Type: "string"},
+					"size":    {Type: "integer", Default: raw(`10`)},
+					"engine":  {Type: "string", Default: raw(`"postgres"`)},
+					"backup":  {Type: "object", Properties: map[string]api.ResourceRefSchema{"retention": {Type: "integer", Default: raw(`7`)}}},
+					"logging": {Type: "object", Properties: map[string]api.ResourceRefSchema{"level": {Type: "string", Default: raw(`"info"`)}}},
+				},
+			},
+		},
+	}
+
+	t.Run("We should fill in undeclared properties with their defaults", func(t *testing.T) {
+		resourceGroup := NewResourceGroup()
+		resource, err := resourceGroup.NewResource("database", raw(`{"name":"checkout","engine":"mysql","backup":{}}`))
+		assert.NoError(t, err)
+		resource.Ref = ref
+
+		properties, err := resource.Evaluate(NewResourcePropertiesArgs(map[string]any{}, refs.NewReferences()))
+		assert.NoError(t, err)
+
+		// nested objects are only defaulted when they are declared
+		assert.Equal(t, ExpandedResourceProperties{
+			"name":   "checkout",
+			"engine": "mysql",
+			"size":   float64(10),
+			"backup": map[string]any{"retention": float64(7)},
+		}, properties)
+	})
+
+	t.Run("We should be able to default resources without properties", func(t *testing.T) {
+		resourceGroup := NewResourceGroup()
+		resource, err := resourceGroup.NewResource("database", nil)
+		assert.NoError(t, err)
+		resource.Ref = ref
+
+		properties, err := resource.Evaluate(NewResourcePropertiesArgs(map[string]any{}, refs.NewReferences()))
+		assert.NoError(t, err)
+		assert.Equal(t, ExpandedResourceProperties{"size": float64(10), "engine": "postgres"}, properties)
+	})
+
+	t.Run("Defaults must match the type of their property", func(t *testing.T) {
+		errs := ValidateDefaults(field.NewPath("spec", "schema"), &api.ResourceRefSchema{
+			Type: "object",
+			Properties: map[string]api.ResourceRefSchema{
+				"size":   {Type: "integer", Default: raw(`"ten"`)},
+				"engine": {Type: "string", Default: raw(`"postgres"`)},
+			},
+		})
+
+		assert.Len(t, errs, 1)
+		assert.Equal(t, "spec.schema.properties[size].default", errs[0].Field)
+	})
+}
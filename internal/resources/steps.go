@@ -0,0 +1,84 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+
+	api "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// NewElements registers the resources of every element, as a deployment run does: elements with a forEach are
+// stamped out to each item read from forEachArgs, and each resource only exports the outputs chosen by its element.
+// Visit, when given, is called to each resource with the element it came from.
+func (r *ResourceGroup) NewElements(elements []api.ResourceGroupElement, forEachArgs *ResourcePropertiesArgs, visit func(*Resource, api.ResourceGroupElement) error) error {
+	for _, element := range elements {
+		if conflict, ok := ForEachConflictOf(elements, element.Name); ok {
+			return fmt.Errorf("resource %s could be named like the resources stamped out of %s, %s-<key>; rename it", element.Name, conflict, conflict)
+		}
+	}
+
+	for _, element := range elements {
+		stamped, err := r.NewResources(element, forEachArgs)
+		if err != nil {
+			return fmt.Errorf("unable to read resource %s: %w", element.Name, err)
+		}
+
+		for _, resource := range stamped {
+			resource.ExportedOutputs = element.ExportedOutputs
+
+			if visit == nil {
+				continue
+			}
+			if err := visit(resource, element); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RenderStep is a resource rendered from the outputs of the ones before it in the graph
+type RenderStep struct {
+	Resource   *Resource
+	Properties ExpandedResourceProperties
+	// RawProperties are the rendered properties as JSON, the way they're written to the spec of the Resource
+	RawProperties []byte
+	// Err is why the properties couldn't be rendered; an *expression.UnknownError means they depend on outputs that
+	// aren't produced yet
+	Err error
+}
+
+// Render walks the resources in the order of the graph, rendering each one with the args. The Resource returned by
+// step stands for the deployed resource, whose outputs are read by the expressions of the next ones; without it, the
+// resources depending on this one can't be rendered.
+func (r *ResourceGroup) Render(dag []string, args *ResourcePropertiesArgs, step func(*RenderStep) (*api.Resource, error)) error {
+	for _, name := range dag {
+		resource, err := r.Get(name)
+		if err != nil {
+			return err
+		}
+
+		rendered := &RenderStep{Resource: resource}
+		rendered.Properties, rendered.Err = resource.Evaluate(args)
+		if rendered.Err == nil {
+			rendered.RawProperties, err = json.Marshal(rendered.Properties)
+			if err != nil {
+				return fmt.Errorf("unable to serialize properties from resource %s: %w", resource.Name, err)
+			}
+		}
+
+		deployed, err := step(rendered)
+		if err != nil {
+			return err
+		}
+		if deployed == nil {
+			continue
+		}
+
+		args, err = args.WithResource(resource, deployed)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package resources
+
+import (
+	"errors"
+	"testing"
+
+	api "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/expression"
+	"github.com/nubank/klaudio/internal/refs"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func Test_RenderSteps(t *testing.T) {
+
+	elements := []api.ResourceGroupElement{
+		{Name: "vpc", ResourceRef: "aws-vpc", Properties: &runtime.RawExtension{Raw: []byte(`{"cidr": "${parameters.cidr}"}`)}, ExportedOutputs: []string{"id"}},
+		{Name: "subnet", ResourceRef: "aws-subnet", ForEach: "${parameters.zones}", Properties: &runtime.RawExtension{Raw: []byte(`{"vpc": "${resources.vpc.status.outputs.id}", "zone": "${each.value}"}`)}},
+	}
+
+	args := NewResourcePropertiesArgs(map[string]any{"cidr": "10.0.0.0/16", "zones": []any{"us-east-1a", "us-east-1b"}}, refs.NewReferences())
+
+	t.Run("We should stamp out the resources of every element, with the outputs they export", func(t *testing.T) {
+		group := NewResourceGroup()
+
+		visited := make(map[string]string)
+		err := group.NewElements(elements, args, func(resource *Resource, element api.ResourceGroupElement) error {
+			visited[resource.Name] = element.ResourceRef
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"vpc": "aws-vpc", "subnet-us-east-1a": "aws-subnet", "subnet-us-east-1b": "aws-subnet"}, visited)
+
+		vpc, err := group.Get("vpc")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"id"}, vpc.ExportedOutputs)
+	})
+
+	t.Run("We should render each resource with the outputs of the deployed ones before it", func(t *testing.T) {
+		group := NewResourceGroup()
+		assert.NoError(t, group.NewElements(elements, args, nil))
+
+		dag, err := group.Graph()
+		assert.NoError(t, err)
+
+		rendered := make(map[string]string)
+		err = group.Render(dag, args, func(step *RenderStep) (*api.Resource, error) {
+			assert.NoError(t, step.Err)
+			rendered[step.Resource.Name] = string(step.RawProperties)
+
+			deployed := &api.Resource{Spec: api.ResourceSpec{Properties: &runtime.RawExtension{Raw: step.RawProperties}}}
+			if step.Resource.Name == "vpc" {
+				assert.NoError(t, deployed.Status.SetOutputs(map[string]any{"id": "vpc-123"}))
+			}
+			return deployed, nil
+		})
+		assert.NoError(t, err)
+
+		assert.JSONEq(t, `{"cidr": "10.0.0.0/16"}`, rendered["vpc"])
+		assert.JSONEq(t, `{"vpc": "vpc-123", "zone": "us-east-1a"}`, rendered["subnet-us-east-1a"])
+		assert.JSONEq(t, `{"vpc": "vpc-123", "zone": "us-east-1b"}`, rendered["subnet-us-east-1b"])
+	})
+
+	t.Run("Resources depending on one that wasn't deployed should only be known after the apply", func(t *testing.T) {
+		group := NewResourceGroup()
+		assert.NoError(t, group.NewElements(elements, args, nil))
+
+		dag, err := group.Graph()
+		assert.NoError(t, err)
+
+		unknown := make([]string, 0)
+		err = group.Render(dag, args, func(step *RenderStep) (*api.Resource, error) {
+			var unknownErr *expression.UnknownError
+			if errors.As(step.Err, &unknownErr) {
+				unknown = append(unknown, step.Resource.Name)
+			}
+			return nil, nil
+		})
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"subnet-us-east-1a", "subnet-us-east-1b"}, unknown)
+	})
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var placementlog = logf.Log.WithName("placement-resource")
+
+// SetupPlacementWebhookWithManager registers the webhook for Placement in the manager.
+func SetupPlacementWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&resourcesv1alpha1.Placement{}).
+		WithValidator(&PlacementCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-resources-klaudio-nubank-io-v1alpha1-placement,mutating=false,failurePolicy=fail,sideEffects=None,groups=resources.klaudio.nubank.io,resources=placements,verbs=create;update,versions=v1alpha1,name=vplacement-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// PlacementCustomValidator rejects Placements whose name can't be used where placements end up: label values,
+// and the names of deployments, Resources and namespaces.
+type PlacementCustomValidator struct{}
+
+var _ webhook.CustomValidator = &PlacementCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type Placement.
+func (v *PlacementCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	placement, ok := obj.(*resourcesv1alpha1.Placement)
+	if !ok {
+		return nil, fmt.Errorf("expected a Placement object but got %T", obj)
+	}
+	placementlog.Info("Validation for Placement upon creation", "name", placement.GetName())
+
+	return nil, v.validate(placement)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type Placement.
+func (v *PlacementCustomValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	placement, ok := newObj.(*resourcesv1alpha1.Placement)
+	if !ok {
+		return nil, fmt.Errorf("expected a Placement object for the newObj but got %T", newObj)
+	}
+	placementlog.Info("Validation for Placement upon update", "name", placement.GetName())
+
+	return nil, v.validate(placement)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type Placement.
+func (v *PlacementCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *PlacementCustomValidator) validate(placement *resourcesv1alpha1.Placement) error {
+	var errs field.ErrorList
+
+	for _, msg := range validation.IsDNS1123Label(placement.Name) {
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), placement.Name, msg))
+	}
+
+	windowsPath := field.NewPath("spec", "freezeWindows")
+	for i, window := range placement.Spec.FreezeWindows {
+		if !window.End.After(window.Start.Time) {
+			errs = append(errs, field.Invalid(windowsPath.Index(i).Child("end"), window.End, "must be after the start of the window"))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(resourcesv1alpha1.GroupVersion.WithKind("Placement").GroupKind(), placement.Name, errs)
+}
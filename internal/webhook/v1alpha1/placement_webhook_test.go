@@ -0,0 +1,51 @@
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_PlacementCustomValidator(t *testing.T) {
+	validator := &PlacementCustomValidator{}
+
+	t.Run("We should accept a placement named as a label value", func(t *testing.T) {
+		placement := &resourcesv1alpha1.Placement{ObjectMeta: metav1.ObjectMeta{Name: "prod-us-east-1"}}
+
+		_, err := validator.ValidateCreate(context.TODO(), placement)
+		assert.NoError(t, err)
+
+		_, err = validator.ValidateUpdate(context.TODO(), placement, placement)
+		assert.NoError(t, err)
+	})
+
+	t.Run("We should reject names that can't be used in labels or namespaces", func(t *testing.T) {
+		for _, name := range []string{"prod.us-east-1", "Prod", "prod-us-east-1-with-a-name-longer-than-sixty-three-characters-long"} {
+			_, err := validator.ValidateCreate(context.TODO(), &resourcesv1alpha1.Placement{ObjectMeta: metav1.ObjectMeta{Name: name}})
+
+			assert.True(t, apierrors.IsInvalid(err), name)
+			assert.ErrorContains(t, err, "metadata.name: Invalid value")
+		}
+	})
+
+	t.Run("We should reject freeze windows ending before they start", func(t *testing.T) {
+		now := time.Now()
+		placement := &resourcesv1alpha1.Placement{
+			ObjectMeta: metav1.ObjectMeta{Name: "prod"},
+			Spec: resourcesv1alpha1.PlacementSpec{
+				FreezeWindows: []resourcesv1alpha1.PlacementFreezeWindow{
+					{Start: metav1.NewTime(now), End: metav1.NewTime(now.Add(-time.Hour))},
+				},
+			},
+		}
+
+		_, err := validator.ValidateCreate(context.TODO(), placement)
+		assert.ErrorContains(t, err, "spec.freezeWindows[0].end: Invalid value")
+	})
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/expression"
+	"github.com/nubank/klaudio/internal/refs"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var resourcegrouplog = logf.Log.WithName("resourcegroup-resource")
+
+// MissingResourceRefPolicy is what the ResourceGroup webhook does with resources referencing ResourceRefs that don't
+// exist yet
+type MissingResourceRefPolicy string
+
+const (
+	// MissingResourceRefReject rejects the ResourceGroup
+	MissingResourceRefReject MissingResourceRefPolicy = "reject"
+	// MissingResourceRefWarn accepts the ResourceGroup with a warning, so it can be applied together with its
+	// ResourceRefs in any order; it's deployed once they exist
+	MissingResourceRefWarn MissingResourceRefPolicy = "warn"
+)
+
+// SetupResourceGroupWebhookWithManager registers the webhook for ResourceGroup in the manager.
+func SetupResourceGroupWebhookWithManager(mgr ctrl.Manager, missingResourceRefs MissingResourceRefPolicy) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&resourcesv1alpha1.ResourceGroup{}).
+		WithValidator(&ResourceGroupCustomValidator{Client: mgr.GetAPIReader(), MissingResourceRefs: missingResourceRefs}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-resources-klaudio-nubank-io-v1alpha1-resourcegroup,mutating=false,failurePolicy=fail,sideEffects=None,groups=resources.klaudio.nubank.io,resources=resourcegroups,verbs=create;update,versions=v1alpha1,name=vresourcegroup-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ResourceGroupCustomValidator rejects ResourceGroups that would only fail later, at deployment time: resources
+// referencing unknown ResourceRefs, duplicated names, properties with invalid expressions, expressions reading paths
+// the resources they read will never have, and dependency cycles. Unknown ResourceRefs are only warned about with the MissingResourceRefWarn policy.
+type ResourceGroupCustomValidator struct {
+	Client              client.Reader
+	MissingResourceRefs MissingResourceRefPolicy
+}
+
+var _ webhook.CustomValidator = &ResourceGroupCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type ResourceGroup.
+func (v *ResourceGroupCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	resourceGroup, ok := obj.(*resourcesv1alpha1.ResourceGroup)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResourceGroup object but got %T", obj)
+	}
+	resourcegrouplog.Info("Validation for ResourceGroup upon creation", "name", resourceGroup.GetName())
+
+	return v.validate(ctx, nil, resourceGroup)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type ResourceGroup.
+func (v *ResourceGroupCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	resourceGroup, ok := newObj.(*resourcesv1alpha1.ResourceGroup)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResourceGroup object for the newObj but got %T", newObj)
+	}
+	oldResourceGroup, ok := oldObj.(*resourcesv1alpha1.ResourceGroup)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResourceGroup object for the oldObj but got %T", oldObj)
+	}
+	resourcegrouplog.Info("Validation for ResourceGroup upon update", "name", resourceGroup.GetName())
+
+	// metadata changes, like removing finalizers, must go through even when the ResourceRefs of the group are gone
+	if resourceGroup.DeletionTimestamp != nil || equality.Semantic.DeepEqual(oldResourceGroup.Spec, resourceGroup.Spec) {
+		return nil, nil
+	}
+
+	return v.validate(ctx, oldResourceGroup, resourceGroup)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type ResourceGroup.
+func (v *ResourceGroupCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *ResourceGroupCustomValidator) validate(ctx context.Context, oldResourceGroup, resourceGroup *resourcesv1alpha1.ResourceGroup) (admission.Warnings, error) {
+	var errs field.ErrorList
+	var warnings admission.Warnings
+
+	resourcesPath := field.NewPath("spec", "resources")
+
+	parameters := make(map[string]any)
+	if resourceGroup.Spec.Parameters != nil && len(resourceGroup.Spec.Parameters.Raw) != 0 {
+		if err := json.Unmarshal(resourceGroup.Spec.Parameters.Raw, &parameters); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("spec", "parameters"), field.OmitValueType{}, err.Error()))
+		}
+	}
+	forEachArgs := resources.NewResourcePropertiesArgs(parameters, refs.NewReferences())
+
+	if selector := resourceGroup.Spec.PlacementSelector; selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("spec", "placementSelector"), selector, err.Error()))
+		}
+	}
+
+	if namespaceStrategyOf(resourceGroup) == resourcesv1alpha1.NamespacePerPlacement && resourceGroup.Spec.ReadOnlyAccess {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "readOnlyAccess"), "read-only access is only available with the Group namespace strategy"))
+	}
+
+	// the namespace of each placement is named after the group and the placement, so it must still be a valid name
+	if namespaceStrategyOf(resourceGroup) == resourcesv1alpha1.NamespacePerPlacement {
+		placementErrs, err := v.validatePlacementNamespaces(ctx, resourceGroup)
+		if err != nil {
+			return nil, err
+		}
+		errs = append(errs, placementErrs...)
+	}
+
+	// deployments aren't moved between namespaces; another strategy would deploy every placement twice
+	if oldResourceGroup != nil && namespaceStrategyOf(oldResourceGroup) != namespaceStrategyOf(resourceGroup) {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "namespaceStrategy"), "the namespace strategy can't be changed after the ResourceGroup is created"))
+	}
+
+	names := sets.New[string]()
+	paths := make(map[string]*field.Path)
+	group := resources.NewResourceGroup().WithExpressionLanguage(expression.Language(resourceGroup.Spec.ExpressionLanguage))
+	for i, element := range resourceGroup.Spec.Resources {
+		elementPath := resourcesPath.Index(i)
+
+		if names.Has(element.Name) {
+			errs = append(errs, field.Duplicate(elementPath.Child("name"), element.Name))
+			continue
+		}
+		names.Insert(element.Name)
+
+		if conflict, ok := resources.ForEachConflictOf(resourceGroup.Spec.Resources, element.Name); ok {
+			errs = append(errs, field.Invalid(elementPath.Child("name"), element.Name, fmt.Sprintf("the resources stamped out of %s are named %s-<key>, so they could clash with this one; rename it", conflict, conflict)))
+			continue
+		}
+
+		resourceRef := &resourcesv1alpha1.ResourceRef{}
+		if err := v.Client.Get(ctx, types.NamespacedName{Name: element.ResourceRef}, resourceRef); err != nil {
+			resourceRef = nil
+			if !apierrors.IsNotFound(err) {
+				return nil, apierrors.NewInternalError(fmt.Errorf("unable to fetch ResourceRef %s: %w", element.ResourceRef, err))
+			}
+			if v.MissingResourceRefs == MissingResourceRefWarn {
+				warnings = append(warnings, fmt.Sprintf("%s: ResourceRef %s doesn't exist yet; resources are only deployed once it's created", elementPath.Child("resourceRef"), element.ResourceRef))
+			} else {
+				errs = append(errs, field.NotFound(elementPath.Child("resourceRef"), element.ResourceRef))
+			}
+		}
+
+		if element.ForEach != "" {
+			if _, err := group.ParseForEach(element.ForEach); err != nil {
+				errs = append(errs, field.Invalid(elementPath.Child("forEach"), element.ForEach, err.Error()))
+				continue
+			}
+
+			// items depending on refs, or on parameters of the placement, are only known to each deployment; then
+			// the element is checked as a whole
+			if stamped, err := group.NewResources(element, forEachArgs); err == nil {
+				for _, resource := range stamped {
+					resource.Ref = resourceRef
+					resource.ExportedOutputs = element.ExportedOutputs
+					paths[resource.Name] = elementPath
+				}
+				continue
+			}
+		}
+
+		resource, err := group.NewResource(element.Name, element.Properties)
+		if err != nil {
+			errs = append(errs, field.Invalid(elementPath.Child("properties"), field.OmitValueType{}, err.Error()))
+			continue
+		}
+		resource.Ref = resourceRef
+		resource.ExportedOutputs = element.ExportedOutputs
+		paths[resource.Name] = elementPath
+	}
+
+	// the graph is only meaningful when every resource could be read
+	if len(errs) == 0 {
+		if _, err := group.Graph(); err != nil {
+			errs = append(errs, field.Invalid(resourcesPath, field.OmitValueType{}, fmt.Sprintf("unable to generate a graph from the group resources: %s", err.Error())))
+		}
+	}
+
+	if len(errs) == 0 {
+		for _, err := range group.Lint() {
+			errs = append(errs, field.Invalid(paths[err.Resource].Child("properties", err.Property), field.OmitValueType{}, err.Reason()))
+		}
+	}
+
+	if len(errs) == 0 {
+		return warnings, nil
+	}
+
+	return warnings, apierrors.NewInvalid(resourcesv1alpha1.GroupVersion.WithKind("ResourceGroup").GroupKind(), resourceGroup.Name, errs)
+}
+
+func namespaceStrategyOf(resourceGroup *resourcesv1alpha1.ResourceGroup) resourcesv1alpha1.NamespaceStrategy {
+	if resourceGroup.Spec.NamespaceStrategy == "" {
+		return resourcesv1alpha1.NamespacePerGroup
+	}
+	return resourceGroup.Spec.NamespaceStrategy
+}
+
+// validatePlacementNamespaces checks the namespaces generated to the placements selected by the group
+func (v *ResourceGroupCustomValidator) validatePlacementNamespaces(ctx context.Context, resourceGroup *resourcesv1alpha1.ResourceGroup) (field.ErrorList, error) {
+	var errs field.ErrorList
+
+	selector := labels.Everything()
+	if resourceGroup.Spec.PlacementSelector != nil {
+		s, err := metav1.LabelSelectorAsSelector(resourceGroup.Spec.PlacementSelector)
+		if err != nil {
+			// already reported
+			return nil, nil
+		}
+		selector = s
+	}
+
+	placements := &resourcesv1alpha1.PlacementList{}
+	if err := v.Client.List(ctx, placements, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+
+	for _, placement := range placements.Items {
+		namespace := resourceGroup.NamespaceOf(placement.Name)
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), resourceGroup.Name,
+				fmt.Sprintf("the namespace %s, generated to placement %s, is invalid: %s", namespace, placement.Name, msg)))
+		}
+	}
+
+	return errs, nil
+}
@@ -0,0 +1,246 @@
+package v1alpha1
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_ResourceGroupCustomValidator(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+
+	validator := &ResourceGroupCustomValidator{
+		Client: fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(&resourcesv1alpha1.ResourceRef{ObjectMeta: metav1.ObjectMeta{Name: "rds"}}).
+			Build(),
+	}
+
+	newResourceGroup := func(elements ...resourcesv1alpha1.ResourceGroupElement) *resourcesv1alpha1.ResourceGroup {
+		return &resourcesv1alpha1.ResourceGroup{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample"},
+			Spec:       resourcesv1alpha1.ResourceGroupSpec{Resources: elements},
+		}
+	}
+
+	element := func(name, resourceRef, properties string) resourcesv1alpha1.ResourceGroupElement {
+		return resourcesv1alpha1.ResourceGroupElement{
+			Name:        name,
+			ResourceRef: resourceRef,
+			Properties:  &runtime.RawExtension{Raw: []byte(properties)},
+		}
+	}
+
+	t.Run("We should accept a valid ResourceGroup", func(t *testing.T) {
+		resourceGroup := newResourceGroup(
+			element("database", "rds", `{"name":"sample"}`),
+			element("replica", "rds", `{"source":"${resources.database.status.outputs.arn}"}`),
+		)
+
+		_, err := validator.ValidateCreate(context.TODO(), resourceGroup)
+		assert.NoError(t, err)
+
+		_, err = validator.ValidateUpdate(context.TODO(), resourceGroup, resourceGroup)
+		assert.NoError(t, err)
+	})
+
+	t.Run("We should reject resources referencing unknown ResourceRefs", func(t *testing.T) {
+		_, err := validator.ValidateCreate(context.TODO(), newResourceGroup(element("cache", "elasticache", `{}`)))
+
+		assert.Error(t, err)
+		assert.True(t, apierrors.IsInvalid(err))
+		assert.Contains(t, err.Error(), `spec.resources[0].resourceRef: Not found: "elasticache"`)
+	})
+
+	t.Run("We should only warn about unknown ResourceRefs when they may be applied later", func(t *testing.T) {
+		warnOnly := &ResourceGroupCustomValidator{Client: validator.Client, MissingResourceRefs: MissingResourceRefWarn}
+
+		warnings, err := warnOnly.ValidateCreate(context.TODO(), newResourceGroup(element("cache", "elasticache", `{}`)))
+
+		assert.NoError(t, err)
+		if assert.Len(t, warnings, 1) {
+			assert.Contains(t, warnings[0], "ResourceRef elasticache doesn't exist yet")
+		}
+
+		t.Run("...but still reject everything else", func(t *testing.T) {
+			_, err := warnOnly.ValidateCreate(context.TODO(), newResourceGroup(element("cache", "elasticache", `[]`)))
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "spec.resources[0].properties: Invalid value")
+		})
+	})
+
+	t.Run("We should reject duplicated resource names", func(t *testing.T) {
+		_, err := validator.ValidateCreate(context.TODO(), newResourceGroup(
+			element("database", "rds", `{}`),
+			element("database", "rds", `{}`),
+		))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `spec.resources[1].name: Duplicate value: "database"`)
+	})
+
+	t.Run("We should reject properties that can't be read", func(t *testing.T) {
+		_, err := validator.ValidateCreate(context.TODO(), newResourceGroup(element("database", "rds", `[]`)))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "spec.resources[0].properties: Invalid value")
+	})
+
+	t.Run("We should reject dependency cycles", func(t *testing.T) {
+		_, err := validator.ValidateCreate(context.TODO(), newResourceGroup(
+			element("database", "rds", `{"name":"${resources.replica.status.outputs.name}"}`),
+			element("replica", "rds", `{"name":"${resources.database.status.outputs.name}"}`),
+		))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to generate a graph from the group resources")
+	})
+
+	t.Run("We should accept resources depending on resources stamped out by a forEach", func(t *testing.T) {
+		resourceGroup := newResourceGroup(
+			element("app", "rds", `{"subnet":"${resources[\"subnet-a\"].status.outputs.id}"}`),
+		)
+		resourceGroup.Spec.Parameters = &runtime.RawExtension{Raw: []byte(`{"zones":["a","b"]}`)}
+
+		subnet := element("subnet", "rds", `{"zone":"${each.value}"}`)
+		subnet.ForEach = "${parameters.zones}"
+		resourceGroup.Spec.Resources = append(resourceGroup.Spec.Resources, subnet)
+
+		_, err := validator.ValidateCreate(context.TODO(), resourceGroup)
+		assert.NoError(t, err)
+	})
+
+	t.Run("We should reject a forEach reading other resources", func(t *testing.T) {
+		subnet := element("subnet", "rds", `{"zone":"${each.value}"}`)
+		subnet.ForEach = "${resources.vpc.status.outputs.zones}"
+
+		_, err := validator.ValidateCreate(context.TODO(), newResourceGroup(subnet))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "spec.resources[0].forEach: Invalid value")
+	})
+
+	t.Run("We should reject names that could clash with the resources stamped out by a forEach", func(t *testing.T) {
+		subnet := element("subnet", "rds", `{"zone":"${each.value}"}`)
+		subnet.ForEach = `${["a", "b"]}`
+
+		_, err := validator.ValidateCreate(context.TODO(), newResourceGroup(subnet, element("subnet-routes", "rds", `{}`)))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "spec.resources[1].name: Invalid value")
+	})
+
+	t.Run("We should reject expressions reading paths the resources will never have", func(t *testing.T) {
+		database := element("database", "rds", `{"name":"sample"}`)
+		database.ExportedOutputs = []string{"arn", "endpoint"}
+
+		_, err := validator.ValidateCreate(context.TODO(), newResourceGroup(
+			database,
+			element("replica", "rds", `{"source":"${resources.database.status.outputs.endpont}"}`),
+		))
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "spec.resources[1].properties.source: Invalid value")
+		assert.Contains(t, err.Error(), "resources.database.status.outputs has no field endpont; expected one of arn, endpoint")
+	})
+
+	t.Run("We should skip the validation of updates not changing the spec", func(t *testing.T) {
+		oldResourceGroup := newResourceGroup(element("cache", "elasticache", `{}`))
+		oldResourceGroup.Finalizers = []string{resourcesv1alpha1.Group + "/cleanup"}
+
+		resourceGroup := oldResourceGroup.DeepCopy()
+		resourceGroup.Finalizers = nil
+		resourceGroup.Labels = map[string]string{"team": "payments"}
+
+		_, err := validator.ValidateUpdate(context.TODO(), oldResourceGroup, resourceGroup)
+		assert.NoError(t, err)
+
+		t.Run("...or of groups being deleted", func(t *testing.T) {
+			resourceGroup.Spec.Resources = append(resourceGroup.Spec.Resources, element("queue", "sqs", `{}`))
+			resourceGroup.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+			_, err := validator.ValidateUpdate(context.TODO(), oldResourceGroup, resourceGroup)
+			assert.NoError(t, err)
+		})
+	})
+
+	t.Run("We should reject a change of the namespace strategy", func(t *testing.T) {
+		oldResourceGroup := newResourceGroup(element("database", "rds", `{"name":"sample"}`))
+
+		resourceGroup := oldResourceGroup.DeepCopy()
+		resourceGroup.Spec.NamespaceStrategy = resourcesv1alpha1.NamespacePerPlacement
+
+		_, err := validator.ValidateUpdate(context.TODO(), oldResourceGroup, resourceGroup)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "spec.namespaceStrategy: Forbidden")
+
+		t.Run("...but accept the default strategy set explicitly", func(t *testing.T) {
+			resourceGroup.Spec.NamespaceStrategy = resourcesv1alpha1.NamespacePerGroup
+
+			_, err := validator.ValidateUpdate(context.TODO(), oldResourceGroup, resourceGroup)
+			assert.NoError(t, err)
+		})
+	})
+
+	t.Run("We should reject read-only access with a namespace to each placement", func(t *testing.T) {
+		resourceGroup := newResourceGroup(element("database", "rds", `{"name":"sample"}`))
+		resourceGroup.Spec.NamespaceStrategy = resourcesv1alpha1.NamespacePerPlacement
+		resourceGroup.Spec.ReadOnlyAccess = true
+
+		_, err := validator.ValidateCreate(context.TODO(), resourceGroup)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "spec.readOnlyAccess: Forbidden")
+	})
+
+	t.Run("We should reject a group whose namespace to some placement would be too long", func(t *testing.T) {
+		longPlacement := strings.Repeat("p", 60)
+		withPlacement := &ResourceGroupCustomValidator{
+			Client: fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(
+					&resourcesv1alpha1.ResourceRef{ObjectMeta: metav1.ObjectMeta{Name: "rds"}},
+					&resourcesv1alpha1.Placement{ObjectMeta: metav1.ObjectMeta{Name: longPlacement}},
+				).
+				Build(),
+		}
+
+		resourceGroup := newResourceGroup(element("database", "rds", `{"name":"sample"}`))
+		resourceGroup.Spec.NamespaceStrategy = resourcesv1alpha1.NamespacePerPlacement
+
+		_, err := withPlacement.ValidateCreate(context.TODO(), resourceGroup)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "metadata.name: Invalid value")
+		assert.Contains(t, err.Error(), longPlacement)
+
+		t.Run("...but accept it with a single namespace", func(t *testing.T) {
+			resourceGroup.Spec.NamespaceStrategy = resourcesv1alpha1.NamespacePerGroup
+
+			_, err := withPlacement.ValidateCreate(context.TODO(), resourceGroup)
+			assert.NoError(t, err)
+		})
+	})
+
+	t.Run("We should reject an invalid placement selector", func(t *testing.T) {
+		resourceGroup := newResourceGroup(element("database", "rds", `{"name":"sample"}`))
+		resourceGroup.Spec.PlacementSelector = &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "region", Operator: "Near"}},
+		}
+
+		_, err := validator.ValidateCreate(context.TODO(), resourceGroup)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "spec.placementSelector: Invalid value")
+	})
+}
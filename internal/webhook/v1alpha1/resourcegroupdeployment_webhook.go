@@ -0,0 +1,92 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var resourcegroupdeploymentlog = logf.Log.WithName("resourcegroupdeployment-resource")
+
+// SetupResourceGroupDeploymentWebhookWithManager registers the webhook for ResourceGroupDeployment in the manager.
+func SetupResourceGroupDeploymentWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&resourcesv1alpha1.ResourceGroupDeployment{}).
+		WithDefaulter(&ResourceGroupDeploymentCustomDefaulter{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-resources-klaudio-nubank-io-v1alpha1-resourcegroupdeployment,mutating=true,failurePolicy=fail,sideEffects=None,groups=resources.klaudio.nubank.io,resources=resourcegroupdeployments,verbs=create;update,versions=v1alpha1,name=mresourcegroupdeployment-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ResourceGroupDeploymentCustomDefaulter records who approved a deployment run: the approvedBy annotation is written
+// from the user of the request that sets the approved annotation, so it can't be claimed by anyone else.
+type ResourceGroupDeploymentCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &ResourceGroupDeploymentCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type ResourceGroupDeployment.
+func (d *ResourceGroupDeploymentCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	deployment, ok := obj.(*resourcesv1alpha1.ResourceGroupDeployment)
+	if !ok {
+		return fmt.Errorf("expected a ResourceGroupDeployment object but got %T", obj)
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	old := &resourcesv1alpha1.ResourceGroupDeployment{}
+	if req.Operation == admissionv1.Update {
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return fmt.Errorf("unable to read the current ResourceGroupDeployment: %w", err)
+		}
+	}
+
+	approved := deployment.Annotations[resourcesv1alpha1.ApprovedAnnotation]
+	approvedBy := old.Annotations[resourcesv1alpha1.ApprovedByAnnotation]
+	if approved != "" && approved != old.Annotations[resourcesv1alpha1.ApprovedAnnotation] {
+		approvedBy = req.UserInfo.Username
+
+		resourcegroupdeploymentlog.Info("Approval of ResourceGroupDeployment", "name", deployment.GetName(), "generation", approved, "approvedBy", approvedBy)
+	}
+
+	annotations := deployment.GetAnnotations()
+	if approvedBy == "" {
+		delete(annotations, resourcesv1alpha1.ApprovedByAnnotation)
+	} else {
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[resourcesv1alpha1.ApprovedByAnnotation] = approvedBy
+	}
+	deployment.SetAnnotations(annotations)
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_ResourceGroupDeploymentCustomDefaulter(t *testing.T) {
+	defaulter := &ResourceGroupDeploymentCustomDefaulter{}
+
+	newDeployment := func(annotations map[string]string) *resourcesv1alpha1.ResourceGroupDeployment {
+		return &resourcesv1alpha1.ResourceGroupDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "checkout.prod", Namespace: "checkout", Annotations: annotations},
+		}
+	}
+
+	requestOf := func(t *testing.T, username string, old *resourcesv1alpha1.ResourceGroupDeployment) context.Context {
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+		}}
+		if old != nil {
+			raw, err := json.Marshal(old)
+			assert.NoError(t, err)
+			req.Operation = admissionv1.Update
+			req.OldObject = runtime.RawExtension{Raw: raw}
+		}
+		return admission.NewContextWithRequest(context.TODO(), req)
+	}
+
+	t.Run("We should record the user approving a generation", func(t *testing.T) {
+		old := newDeployment(nil)
+		deployment := newDeployment(map[string]string{resourcesv1alpha1.ApprovedAnnotation: "2"})
+
+		assert.NoError(t, defaulter.Default(requestOf(t, "alice@nubank.com.br", old), deployment))
+		assert.Equal(t, "alice@nubank.com.br", deployment.Annotations[resourcesv1alpha1.ApprovedByAnnotation])
+	})
+
+	t.Run("The approver set in the request should be replaced by the user of the request", func(t *testing.T) {
+		old := newDeployment(map[string]string{resourcesv1alpha1.ApprovedAnnotation: "1", resourcesv1alpha1.ApprovedByAnnotation: "alice@nubank.com.br"})
+		deployment := newDeployment(map[string]string{resourcesv1alpha1.ApprovedAnnotation: "2", resourcesv1alpha1.ApprovedByAnnotation: "alice@nubank.com.br"})
+
+		assert.NoError(t, defaulter.Default(requestOf(t, "mallory@nubank.com.br", old), deployment))
+		assert.Equal(t, "mallory@nubank.com.br", deployment.Annotations[resourcesv1alpha1.ApprovedByAnnotation])
+	})
+
+	t.Run("We should keep the recorded approver when the approval doesn't change", func(t *testing.T) {
+		old := newDeployment(map[string]string{resourcesv1alpha1.ApprovedAnnotation: "2", resourcesv1alpha1.ApprovedByAnnotation: "alice@nubank.com.br"})
+		deployment := newDeployment(map[string]string{resourcesv1alpha1.ApprovedAnnotation: "2", resourcesv1alpha1.ApprovedByAnnotation: "bob@nubank.com.br"})
+
+		assert.NoError(t, defaulter.Default(requestOf(t, "bob@nubank.com.br", old), deployment))
+		assert.Equal(t, "alice@nubank.com.br", deployment.Annotations[resourcesv1alpha1.ApprovedByAnnotation])
+	})
+
+	t.Run("An approver without an approval should be discarded", func(t *testing.T) {
+		deployment := newDeployment(map[string]string{resourcesv1alpha1.ApprovedByAnnotation: "alice@nubank.com.br"})
+
+		assert.NoError(t, defaulter.Default(requestOf(t, "bob@nubank.com.br", nil), deployment))
+		assert.NotContains(t, deployment.Annotations, resourcesv1alpha1.ApprovedByAnnotation)
+	})
+}
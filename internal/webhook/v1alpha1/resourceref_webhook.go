@@ -0,0 +1,242 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+	"github.com/nubank/klaudio/internal/provisioning"
+	"github.com/nubank/klaudio/internal/resources"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var resourcereflog = logf.Log.WithName("resourceref-resource")
+
+// schemaTypes are the types accepted by a ResourceRef schema
+var schemaTypes = []string{"object", "array", "string", "integer", "number", "boolean"}
+
+// SetupResourceRefWebhookWithManager registers the webhook for ResourceRef in the manager.
+func SetupResourceRefWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&resourcesv1alpha1.ResourceRef{}).
+		WithValidator(&ResourceRefCustomValidator{Client: mgr.GetAPIReader()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-resources-klaudio-nubank-io-v1alpha1-resourceref,mutating=false,failurePolicy=fail,sideEffects=None,groups=resources.klaudio.nubank.io,resources=resourcerefs,verbs=create;update,versions=v1alpha1,name=vresourceref-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ResourceRefCustomValidator rejects ResourceRefs with a broken schema, or with provisioner properties that wouldn't
+// be accepted by the provisioner when a Resource is deployed. Provisioners disabled by the KlaudioConfig of the
+// installation are refused too.
+type ResourceRefCustomValidator struct {
+	// Client reads the KlaudioConfig of the installation; without it, every provisioner is accepted
+	Client client.Reader
+}
+
+var _ webhook.CustomValidator = &ResourceRefCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type ResourceRef.
+func (v *ResourceRefCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	resourceRef, ok := obj.(*resourcesv1alpha1.ResourceRef)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResourceRef object but got %T", obj)
+	}
+	resourcereflog.Info("Validation for ResourceRef upon creation", "name", resourceRef.GetName())
+
+	if err := v.validateProvisionerEnabled(ctx, resourceRef); err != nil {
+		return nil, err
+	}
+
+	return nil, v.validate(resourceRef)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type ResourceRef.
+func (v *ResourceRefCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	resourceRef, ok := newObj.(*resourcesv1alpha1.ResourceRef)
+	if !ok {
+		return nil, fmt.Errorf("expected a ResourceRef object for the newObj but got %T", newObj)
+	}
+	resourcereflog.Info("Validation for ResourceRef upon update", "name", resourceRef.GetName())
+
+	var warnings admission.Warnings
+
+	// ResourceRefs already using a disabled provisioner can still be fixed; only switching to one is refused
+	if oldResourceRef, ok := oldObj.(*resourcesv1alpha1.ResourceRef); !ok || oldResourceRef.Spec.Provisioner.Name != resourceRef.Spec.Provisioner.Name {
+		if err := v.validateProvisionerEnabled(ctx, resourceRef); err != nil {
+			return nil, err
+		}
+
+		if ok {
+			w, err := v.validateProvisionerChange(ctx, oldResourceRef, resourceRef)
+			if err != nil {
+				return nil, err
+			}
+			warnings = w
+		}
+	}
+
+	return warnings, v.validate(resourceRef)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type ResourceRef.
+func (v *ResourceRefCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateProvisionerEnabled refuses provisioners disabled by the feature gates of the KlaudioConfig of the
+// installation; it's read here, instead of from the controller, since any replica may serve the webhook
+func (v *ResourceRefCustomValidator) validateProvisionerEnabled(ctx context.Context, resourceRef *resourcesv1alpha1.ResourceRef) error {
+	if v.Client == nil {
+		return nil
+	}
+
+	klaudioConfig := &resourcesv1alpha1.KlaudioConfig{}
+	if err := v.Client.Get(ctx, types.NamespacedName{Name: resourcesv1alpha1.KlaudioConfigName}, klaudioConfig); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	name := string(resourceRef.Spec.Provisioner.Name)
+	if enabled, ok := klaudioConfig.Spec.FeatureGates.Provisioners[name]; !ok || enabled {
+		return nil
+	}
+
+	return apierrors.NewInvalid(resourcesv1alpha1.GroupVersion.WithKind("ResourceRef").GroupKind(), resourceRef.Name, field.ErrorList{
+		field.Forbidden(field.NewPath("spec", "provisioner", "name"),
+			fmt.Sprintf("provisioner %s is disabled by KlaudioConfig %s", name, resourcesv1alpha1.KlaudioConfigName)),
+	})
+}
+
+// validateProvisionerChange refuses to switch the provisioner of a ResourceRef still used by Resources: the new
+// provisioner doesn't know the objects created by the previous one, which would be stranded with the infrastructure
+// behind them. The change is accepted, with a warning, once approved by annotation.
+func (v *ResourceRefCustomValidator) validateProvisionerChange(ctx context.Context, oldResourceRef, resourceRef *resourcesv1alpha1.ResourceRef) (admission.Warnings, error) {
+	if v.Client == nil {
+		return nil, nil
+	}
+
+	resources := &resourcesv1alpha1.ResourceList{}
+	if err := v.Client.List(ctx, resources); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for _, resource := range resources.Items {
+		if resource.Spec.ResourceRef == resourceRef.Name {
+			names = append(names, client.ObjectKeyFromObject(&resource).String())
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	slices.Sort(names)
+
+	used := fmt.Sprintf("%d Resources, like %s", len(names), strings.Join(names[:min(len(names), 3)], ", "))
+
+	if resourceRef.Annotations[resourcesv1alpha1.ResourceRefProvisionerChangeApprovalAnnotation] == "true" {
+		return admission.Warnings{
+			fmt.Sprintf("provisioner changed from %s to %s while used by %s; objects created by %s are left behind",
+				oldResourceRef.Spec.Provisioner.Name, resourceRef.Spec.Provisioner.Name, used, oldResourceRef.Spec.Provisioner.Name),
+		}, nil
+	}
+
+	return nil, apierrors.NewInvalid(resourcesv1alpha1.GroupVersion.WithKind("ResourceRef").GroupKind(), resourceRef.Name, field.ErrorList{
+		field.Forbidden(field.NewPath("spec", "provisioner", "name"),
+			fmt.Sprintf("provisioner can't be changed from %s to %s while used by %s; delete them first, or approve the change with the %s annotation",
+				oldResourceRef.Spec.Provisioner.Name, resourceRef.Spec.Provisioner.Name, used, resourcesv1alpha1.ResourceRefProvisionerChangeApprovalAnnotation)),
+	})
+}
+
+func (v *ResourceRefCustomValidator) validate(resourceRef *resourcesv1alpha1.ResourceRef) error {
+	var errs field.ErrorList
+
+	schemaPath := field.NewPath("spec", "schema")
+
+	// resources are always declared with an object of properties
+	if resourceRef.Spec.Schema.Type != "object" {
+		errs = append(errs, field.NotSupported(schemaPath.Child("type"), resourceRef.Spec.Schema.Type, []string{"object"}))
+	} else {
+		errs = append(errs, validateSchema(schemaPath, &resourceRef.Spec.Schema)...)
+	}
+
+	// defaults are only checked against a consistent schema
+	if len(errs) == 0 {
+		errs = append(errs, resources.ValidateDefaults(schemaPath, &resourceRef.Spec.Schema)...)
+	}
+
+	if err := provisioning.ValidateProperties(&resourceRef.Spec.Provisioner); err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("spec", "provisioner", "properties"), field.OmitValueType{}, err.Error()))
+	}
+
+	overridesPath := field.NewPath("spec", "provisioner", "overrides")
+	for i, override := range resourceRef.Spec.Provisioner.Overrides {
+		if override.Kind == "" {
+			errs = append(errs, field.Required(overridesPath.Index(i).Child("kind"), "the kind of the overridden objects is required"))
+		}
+		spec := make(map[string]any)
+		if override.Spec == nil || json.Unmarshal(override.Spec.Raw, &spec) != nil {
+			errs = append(errs, field.Invalid(overridesPath.Index(i).Child("spec"), field.OmitValueType{}, "must be an object"))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(resourcesv1alpha1.GroupVersion.WithKind("ResourceRef").GroupKind(), resourceRef.Name, errs)
+}
+
+// validateSchema checks the type of a schema and of its nested properties; only objects declare properties
+func validateSchema(path *field.Path, schema *resourcesv1alpha1.ResourceRefSchema) field.ErrorList {
+	var errs field.ErrorList
+
+	if !slices.Contains(schemaTypes, schema.Type) {
+		return append(errs, field.NotSupported(path.Child("type"), schema.Type, schemaTypes))
+	}
+
+	if schema.Type != "object" && len(schema.Properties) != 0 {
+		errs = append(errs, field.Forbidden(path.Child("properties"), fmt.Sprintf("properties are only allowed in objects, not in %s", schema.Type)))
+	}
+	if schema.Type != "object" && len(schema.Required) != 0 {
+		errs = append(errs, field.Forbidden(path.Child("required"), fmt.Sprintf("required properties are only allowed in objects, not in %s", schema.Type)))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(schema.Properties)) {
+		property := schema.Properties[name]
+		errs = append(errs, validateSchema(path.Child("properties").Key(name), &property)...)
+	}
+
+	return errs
+}
@@ -0,0 +1,182 @@
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+func Test_ResourceRefCustomValidator(t *testing.T) {
+	validator := &ResourceRefCustomValidator{}
+
+	newResourceRef := func(schema resourcesv1alpha1.ResourceRefSchema) *resourcesv1alpha1.ResourceRef {
+		return &resourcesv1alpha1.ResourceRef{
+			ObjectMeta: metav1.ObjectMeta{Name: "rds"},
+			Spec: resourcesv1alpha1.ResourceRefSpec{
+				Provisioner: resourcesv1alpha1.ResourceRefProvisioner{
+					Name:       resourcesv1alpha1.ResourceRefOpenTofuProvisioner,
+					Properties: &runtime.RawExtension{Raw: []byte(`{"git":{"repo":"https://github.com/nubank/modules"}}`)},
+				},
+				Schema: schema,
+			},
+		}
+	}
+
+	t.Run("We should accept a valid ResourceRef", func(t *testing.T) {
+		resourceRef := newResourceRef(resourcesv1alpha1.ResourceRefSchema{
+			Type: "object",
+			Properties: map[string]resourcesv1alpha1.ResourceRefSchema{
+				"name": {Type: "string"},
+				"tags": {Type: "object", Properties: map[string]resourcesv1alpha1.ResourceRefSchema{"team": {Type: "string"}}},
+			},
+		})
+
+		_, err := validator.ValidateCreate(context.TODO(), resourceRef)
+		assert.NoError(t, err)
+
+		_, err = validator.ValidateUpdate(context.TODO(), resourceRef, resourceRef)
+		assert.NoError(t, err)
+	})
+
+	t.Run("We should reject unknown types", func(t *testing.T) {
+		_, err := validator.ValidateCreate(context.TODO(), newResourceRef(resourcesv1alpha1.ResourceRefSchema{
+			Type:       "object",
+			Properties: map[string]resourcesv1alpha1.ResourceRefSchema{"size": {Type: "int"}},
+		}))
+
+		assert.True(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, `spec.schema.properties[size].type: Unsupported value: "int"`)
+	})
+
+	t.Run("We should reject schemas that aren't objects", func(t *testing.T) {
+		_, err := validator.ValidateCreate(context.TODO(), newResourceRef(resourcesv1alpha1.ResourceRefSchema{Type: "string"}))
+
+		assert.ErrorContains(t, err, `spec.schema.type: Unsupported value: "string"`)
+	})
+
+	t.Run("We should reject properties nested in types other than objects", func(t *testing.T) {
+		_, err := validator.ValidateCreate(context.TODO(), newResourceRef(resourcesv1alpha1.ResourceRefSchema{
+			Type: "object",
+			Properties: map[string]resourcesv1alpha1.ResourceRefSchema{
+				"name": {Type: "string", Properties: map[string]resourcesv1alpha1.ResourceRefSchema{"first": {Type: "string"}}},
+			},
+		}))
+
+		assert.ErrorContains(t, err, "spec.schema.properties[name].properties: Forbidden: properties are only allowed in objects, not in string")
+	})
+
+	t.Run("We should reject provisioner properties that the provisioner wouldn't accept", func(t *testing.T) {
+		resourceRef := newResourceRef(resourcesv1alpha1.ResourceRefSchema{Type: "object"})
+		resourceRef.Spec.Provisioner.Properties = &runtime.RawExtension{Raw: []byte(`{"git":{"branch":"main"}}`)}
+
+		_, err := validator.ValidateCreate(context.TODO(), resourceRef)
+
+		assert.ErrorContains(t, err, "spec.provisioner.properties: Invalid value")
+		assert.ErrorContains(t, err, "opentofu provisioner requires git.repo")
+	})
+	t.Run("We should reject overrides that aren't objects", func(t *testing.T) {
+		resourceRef := newResourceRef(resourcesv1alpha1.ResourceRefSchema{Type: "object"})
+		resourceRef.Spec.Provisioner.Overrides = []resourcesv1alpha1.ResourceRefProvisionerOverride{
+			{Kind: "Terraform", Spec: &runtime.RawExtension{Raw: []byte(`{"runnerPodTemplate":{"spec":{"nodeSelector":{"pool":"infra"}}}}`)}},
+			{Kind: "Terraform", Spec: &runtime.RawExtension{Raw: []byte(`["parallelism"]`)}},
+		}
+
+		_, err := validator.ValidateCreate(context.TODO(), resourceRef)
+
+		assert.ErrorContains(t, err, "spec.provisioner.overrides[1].spec: Invalid value")
+		assert.NotContains(t, err.Error(), "overrides[0]")
+	})
+
+	t.Run("We should reject provisioners disabled by the KlaudioConfig of the installation", func(t *testing.T) {
+		scheme := runtime.NewScheme()
+		assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+
+		validator := &ResourceRefCustomValidator{
+			Client: fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(&resourcesv1alpha1.KlaudioConfig{
+					ObjectMeta: metav1.ObjectMeta{Name: resourcesv1alpha1.KlaudioConfigName},
+					Spec: resourcesv1alpha1.KlaudioConfigSpec{
+						FeatureGates: resourcesv1alpha1.KlaudioFeatureGates{
+							Provisioners: map[string]bool{resourcesv1alpha1.ResourceRefOpenTofuProvisioner: false, resourcesv1alpha1.ResourceRefHelmProvisioner: true},
+						},
+					},
+				}).
+				Build(),
+		}
+
+		resourceRef := newResourceRef(resourcesv1alpha1.ResourceRefSchema{Type: "object"})
+
+		_, err := validator.ValidateCreate(context.TODO(), resourceRef)
+
+		assert.True(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.provisioner.name: Forbidden: provisioner opentofu is disabled")
+
+		// already using it, so it can still be updated
+		_, err = validator.ValidateUpdate(context.TODO(), resourceRef, resourceRef)
+		assert.NoError(t, err)
+	})
+
+	t.Run("We should refuse to change the provisioner of a ResourceRef still used by Resources", func(t *testing.T) {
+		scheme := runtime.NewScheme()
+		assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+
+		validator := &ResourceRefCustomValidator{
+			Client: fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(
+					&resourcesv1alpha1.Resource{
+						ObjectMeta: metav1.ObjectMeta{Name: "orders-db", Namespace: "checkout"},
+						Spec:       resourcesv1alpha1.ResourceSpec{ResourceRef: "rds", Placement: "prod"},
+					},
+					&resourcesv1alpha1.Resource{
+						ObjectMeta: metav1.ObjectMeta{Name: "orders-bucket", Namespace: "checkout"},
+						Spec:       resourcesv1alpha1.ResourceSpec{ResourceRef: "s3", Placement: "prod"},
+					},
+				).
+				Build(),
+		}
+
+		oldResourceRef := newResourceRef(resourcesv1alpha1.ResourceRefSchema{Type: "object"})
+		resourceRef := oldResourceRef.DeepCopy()
+		resourceRef.Spec.Provisioner = resourcesv1alpha1.ResourceRefProvisioner{
+			Name:       resourcesv1alpha1.ResourceRefPulumiProvisioner,
+			Properties: &runtime.RawExtension{Raw: []byte(`{"git":{"repo":"https://github.com/nubank/stacks"}}`)},
+		}
+
+		_, err := validator.ValidateUpdate(context.TODO(), oldResourceRef, resourceRef)
+		assert.True(t, apierrors.IsInvalid(err))
+		assert.ErrorContains(t, err, "spec.provisioner.name: Forbidden: provisioner can't be changed from opentofu to pulumi while used by 1 Resources, like checkout/orders-db")
+
+		// other changes are accepted
+		_, err = validator.ValidateUpdate(context.TODO(), oldResourceRef, oldResourceRef)
+		assert.NoError(t, err)
+
+		resourceRef.Annotations = map[string]string{resourcesv1alpha1.ResourceRefProvisionerChangeApprovalAnnotation: "true"}
+
+		warnings, err := validator.ValidateUpdate(context.TODO(), oldResourceRef, resourceRef)
+		assert.NoError(t, err)
+		assert.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "objects created by opentofu are left behind")
+	})
+
+	t.Run("We should accept a change of provisioner when no Resource uses the ResourceRef", func(t *testing.T) {
+		scheme := runtime.NewScheme()
+		assert.NoError(t, resourcesv1alpha1.AddToScheme(scheme))
+
+		validator := &ResourceRefCustomValidator{Client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+
+		oldResourceRef := newResourceRef(resourcesv1alpha1.ResourceRefSchema{Type: "object"})
+		resourceRef := oldResourceRef.DeepCopy()
+		resourceRef.Spec.Provisioner = resourcesv1alpha1.ResourceRefProvisioner{Name: resourcesv1alpha1.ResourceRefNoopProvisioner}
+
+		warnings, err := validator.ValidateUpdate(context.TODO(), oldResourceRef, resourceRef)
+		assert.NoError(t, err)
+		assert.Empty(t, warnings)
+	})
+}
@@ -0,0 +1,45 @@
+package plugin
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Client calls a provisioner plugin
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+// NewClient creates a client to the plugin listening on target. Without options, the connection is plaintext.
+func NewClient(target string, opts ...grpc.DialOption) (*Client, error) {
+	if len(opts) == 0 {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	conn, err := grpc.NewClient(target, append(opts, grpc.WithDefaultCallOptions(grpc.ForceCodec(Codec{})))...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn}, nil
+}
+
+func (c *Client) Run(ctx context.Context, request *RunRequest) (*RunResponse, error) {
+	return c.invoke(ctx, RunMethodName, request)
+}
+
+func (c *Client) Destroy(ctx context.Context, request *RunRequest) (*RunResponse, error) {
+	return c.invoke(ctx, DestroyMethodName, request)
+}
+
+func (c *Client) invoke(ctx context.Context, methodName string, request *RunRequest) (*RunResponse, error) {
+	response := &RunResponse{}
+	if err := c.conn.Invoke(ctx, "/"+ServiceName+"/"+methodName, request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,18 @@
+package plugin
+
+import "encoding/json"
+
+// Codec encodes plugin messages as JSON
+type Codec struct{}
+
+func (Codec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (Codec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (Codec) Name() string {
+	return "json"
+}
@@ -0,0 +1,131 @@
+// Package plugin defines the gRPC protocol used by out-of-process provisioners.
+//
+// A plugin is a gRPC server exposing the klaudio.provisioning.v1.Provisioner service. Messages are encoded as JSON,
+// so plugins don't need any generated code: implementing the Provisioner interface and calling Serve is enough.
+// Plugins are made available to ResourceRefs through a ProvisionerPlugin object pointing to the server's address.
+package plugin
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	resourcesv1alpha1 "github.com/nubank/klaudio/api/v1alpha1"
+)
+
+const (
+	ServiceName       = "klaudio.provisioning.v1.Provisioner"
+	RunMethodName     = "Run"
+	DestroyMethodName = "Destroy"
+)
+
+// States a plugin can report for a provisioned resource
+const (
+	RunningState = "Running"
+	FailedState  = "Failed"
+	SuccessState = "Success"
+)
+
+// RunRequest asks the plugin to provision a Resource
+type RunRequest struct {
+	Resource *resourcesv1alpha1.Resource `json:"resource"`
+	// Properties are the provisioner properties declared by the ResourceRef
+	Properties *runtime.RawExtension `json:"properties,omitempty"`
+}
+
+// RunResponse is the current state of the provisioned Resource
+type RunResponse struct {
+	State     string               `json:"state"`
+	Resource  *ProvisionedResource `json:"resource,omitempty"`
+	Outputs   map[string]any       `json:"outputs,omitempty"`
+	Inventory []InventoryEntry     `json:"inventory,omitempty"`
+	Drifted   bool                 `json:"drifted,omitempty"`
+}
+
+// ProvisionedResource is the object created by the plugin on behalf of a Resource, if any
+type ProvisionedResource struct {
+	Group   string `json:"group,omitempty"`
+	Version string `json:"version,omitempty"`
+	Kind    string `json:"kind,omitempty"`
+	Name    string `json:"name"`
+}
+
+type InventoryEntry struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	ID   string `json:"id,omitempty"`
+}
+
+// Provisioner is implemented by plugins. Run is called on every reconciliation of a Resource, so it must be idempotent;
+// a Running state makes klaudio call it again a few seconds later.
+type Provisioner interface {
+	Run(ctx context.Context, request *RunRequest) (*RunResponse, error)
+}
+
+// Destroyer is implemented by plugins that tear down what they provisioned. Destroy is called when the Resource is
+// deleted, until it reports a state other than Running, so it must be idempotent too; it must honor the Resource's
+// spec.deletionPolicy. For plugins without it, deleting a Resource leaves its infrastructure as is.
+type Destroyer interface {
+	Destroy(ctx context.Context, request *RunRequest) (*RunResponse, error)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*Provisioner)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: RunMethodName,
+			Handler: handlerOf(RunMethodName, func(srv any, ctx context.Context, request *RunRequest) (*RunResponse, error) {
+				return srv.(Provisioner).Run(ctx, request)
+			}),
+		},
+		{
+			MethodName: DestroyMethodName,
+			Handler: handlerOf(DestroyMethodName, func(srv any, ctx context.Context, request *RunRequest) (*RunResponse, error) {
+				destroyer, ok := srv.(Destroyer)
+				if !ok {
+					return nil, status.Errorf(codes.Unimplemented, "method %s not implemented", DestroyMethodName)
+				}
+				return destroyer.Destroy(ctx, request)
+			}),
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "klaudio/provisioning/v1/provisioner",
+}
+
+func handlerOf(methodName string, method func(any, context.Context, *RunRequest) (*RunResponse, error)) func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		request := &RunRequest{}
+		if err := dec(request); err != nil {
+			return nil, err
+		}
+		if interceptor == nil {
+			return method(srv, ctx, request)
+		}
+		info := &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: "/" + ServiceName + "/" + methodName,
+		}
+		handler := func(ctx context.Context, req any) (any, error) {
+			return method(srv, ctx, req.(*RunRequest))
+		}
+		return interceptor(ctx, request, info, handler)
+	}
+}
+
+// Register adds the provisioner service to an existing gRPC server
+func Register(server *grpc.Server, provisioner Provisioner) {
+	server.RegisterService(&serviceDesc, provisioner)
+}
+
+// Serve starts a gRPC server exposing the provisioner on the given listener; it blocks until the server stops
+func Serve(listener net.Listener, provisioner Provisioner, opts ...grpc.ServerOption) error {
+	server := grpc.NewServer(append(opts, grpc.ForceServerCodec(Codec{}))...)
+	Register(server, provisioner)
+	return server.Serve(listener)
+}